@@ -0,0 +1,5 @@
+fn main() -> Result<(), Box<dyn std::error::Error>> {
+    #[cfg(feature = "grpc")]
+    tonic_build::compile_protos("proto/eule.proto")?;
+    Ok(())
+}
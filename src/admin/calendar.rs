@@ -0,0 +1,172 @@
+//! iCalendar feeds of each guild's upcoming purges.
+//!
+//! Feeds are served at `/calendar/{guild_id}.ics?token=…` on the admin
+//! listener. Calendar apps can't send headers, so each guild's feed is guarded
+//! by a token in the URL instead: an HMAC of the guild ID under a key kept in
+//! the store. Tokens need no bookkeeping, can't be guessed for other guilds,
+//! and are all revoked at once by deleting the key.
+
+use crate::{
+    error::EuleError,
+    store::KvStore,
+    tasks::CleanupTask,
+    utils::{Crypto, SerializableInstant},
+};
+use hmac::{Hmac, Mac};
+use hyper::StatusCode;
+use poise::serenity_prelude::{ChannelId, GuildId};
+use sha2::Sha256;
+use tokio::time::Duration;
+
+/// The key the feed key is stored under.
+const CALENDAR_KEY: &str = "calendar_key";
+/// How far ahead feeds list purges.
+pub const FEED_HORIZON: Duration = Duration::from_secs(30 * 24 * 60 * 60);
+/// The most purges listed per task, so short intervals don't flood calendars.
+const MAX_OCCURRENCES: usize = 200;
+
+/// Returns the key feed tokens are derived from, creating it on first use.
+///
+/// # Arguments
+/// * `store` - The store the key is kept in
+pub async fn calendar_key(store: &KvStore) -> Result<String, EuleError> {
+    if let Some(key) = store.get(CALENDAR_KEY).await.map_err(EuleError::Miette)? {
+        return Ok(key);
+    }
+    let key = Crypto::random_token();
+    store
+        .set(CALENDAR_KEY, &key)
+        .await
+        .map_err(EuleError::Miette)?;
+    Ok(key)
+}
+
+/// Returns the token that unlocks a guild's feed.
+pub fn feed_token(key: &str, guild_id: GuildId) -> String {
+    let mut mac =
+        Hmac::<Sha256>::new_from_slice(key.as_bytes()).expect("HMAC accepts keys of any length");
+    mac.update(b"calendar:");
+    mac.update(guild_id.to_string().as_bytes());
+    mac.finalize()
+        .into_bytes()
+        .iter()
+        .take(16)
+        .map(|byte| format!("{:02x}", byte))
+        .collect()
+}
+
+/// Returns the address of a guild's feed.
+///
+/// # Arguments
+/// * `public_url` - The address the admin listener is reached at
+/// * `key` - The feed key
+/// * `guild_id` - The guild whose feed to link
+pub fn feed_url(public_url: &str, key: &str, guild_id: GuildId) -> String {
+    format!(
+        "{}/calendar/{}.ics?token={}",
+        public_url.trim_end_matches('/'),
+        guild_id,
+        feed_token(key, guild_id)
+    )
+}
+
+/// Escapes text for an iCalendar property value.
+fn escape(text: &str) -> String {
+    text.replace('\\', "\\\\")
+        .replace(';', "\\;")
+        .replace(',', "\\,")
+        .replace('\n', "\\n")
+}
+
+/// Renders a guild's upcoming purges as an iCalendar document.
+///
+/// # Arguments
+/// * `guild_id` - The guild the tasks belong to
+/// * `tasks` - The guild's tasks
+/// * `now` - The time the feed is generated at
+pub fn render(
+    guild_id: GuildId,
+    tasks: &[(ChannelId, CleanupTask)],
+    now: SerializableInstant,
+) -> String {
+    let mut lines = vec![
+        "BEGIN:VCALENDAR".to_string(),
+        "VERSION:2.0".to_string(),
+        "PRODID:-//eule//purge schedule//EN".to_string(),
+        "CALSCALE:GREGORIAN".to_string(),
+        format!(
+            "X-WR-CALNAME:{}",
+            escape(&format!("Purges in guild {}", guild_id))
+        ),
+    ];
+    let until = now + FEED_HORIZON;
+    let stamp = now.utc_basic();
+    for (channel_id, task) in tasks {
+        // Forum and thread cleanups archive and delete threads rather than purging messages
+        let summary = match (&task.forum, &task.threads) {
+            (Some(_), _) => format!("Forum cleanup of channel {}", channel_id),
+            (None, Some(_)) => format!("Thread cleanup of channel {}", channel_id),
+            (None, None) => format!("Purge of channel {}", channel_id),
+        };
+        let mut at = task.next_cleanup();
+        for _ in 0..MAX_OCCURRENCES {
+            if at > until {
+                break;
+            }
+            lines.extend([
+                "BEGIN:VEVENT".to_string(),
+                format!("UID:{}-{}@eule", channel_id, at.unix_secs()),
+                format!("DTSTAMP:{}", stamp),
+                format!("DTSTART:{}", at.utc_basic()),
+                format!("SUMMARY:{}", escape(&summary)),
+                "TRANSP:TRANSPARENT".to_string(),
+                "END:VEVENT".to_string(),
+            ]);
+            at = at + task.interval;
+        }
+    }
+    lines.push("END:VCALENDAR".to_string());
+    lines.join("\r\n") + "\r\n"
+}
+
+/// Answers a request for a feed.
+///
+/// # Arguments
+/// * `key` - The feed key, or `None` if feeds are disabled
+/// * `tasks` - Looks up a guild's tasks
+/// * `path` - The request path, including the query string
+///
+/// # Returns
+/// The status and body to send, which is the feed on success.
+pub async fn handle<F, Fut>(key: Option<&str>, tasks: F, path: &str) -> (StatusCode, String)
+where
+    F: FnOnce(GuildId) -> Fut,
+    Fut: std::future::Future<Output = Vec<(ChannelId, CleanupTask)>>,
+{
+    let not_found = (StatusCode::NOT_FOUND, "Not found".to_string());
+    let Some(key) = key else {
+        return not_found;
+    };
+    let (path, query) = path.split_once('?').unwrap_or((path, ""));
+    let Some(guild_id) = path
+        .strip_prefix("/calendar/")
+        .and_then(|rest| rest.strip_suffix(".ics"))
+        .and_then(|id| id.parse::<u64>().ok())
+        .filter(|id| *id != 0)
+        .map(GuildId::new)
+    else {
+        return not_found;
+    };
+    let token = query
+        .split('&')
+        .find_map(|pair| pair.strip_prefix("token="))
+        .unwrap_or_default();
+    if !super::tokens_match(&feed_token(key, guild_id), token) {
+        return not_found;
+    }
+    let tasks = tasks(guild_id).await;
+    (
+        StatusCode::OK,
+        render(guild_id, &tasks, SerializableInstant::now()),
+    )
+}
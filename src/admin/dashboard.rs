@@ -0,0 +1,507 @@
+//! The web dashboard.
+//!
+//! Server owners log in with Discord and see the guilds they may manage that
+//! have cleanup tasks, with each task's schedule, recent runs and statistics,
+//! and forms to change or remove schedules. New tasks are still added with
+//! `/autoclean add`, which checks that the channel belongs to the guild.
+//!
+//! Guild permissions are read once at login, so a session keeps the access its
+//! user had then until it expires.
+
+use crate::{
+    admin::{
+        html::{self, escape, parse_query, relative_time},
+        oauth::{OAuthApi, OAuthUser, UserGuild},
+        AdminState, MIN_INTERVAL_SECS,
+    },
+    lifecycle,
+    tasks::CleanupTask,
+    utils::{Crypto, SerializableInstant},
+};
+use hyper::{Method, StatusCode};
+use poise::serenity_prelude::{ChannelId, GuildId};
+use std::{collections::HashMap, sync::Arc};
+use tokio::{
+    sync::Mutex,
+    time::{Duration, Instant},
+};
+
+/// The name of the session cookie.
+const SESSION_COOKIE: &str = "eule_session";
+
+/// How long a login stays valid.
+const SESSION_TTL: Duration = Duration::from_secs(12 * 60 * 60);
+
+/// How long a user has to complete the Discord authorization.
+const LOGIN_TTL: Duration = Duration::from_secs(10 * 60);
+
+/// The number of recent runs shown per task.
+const RECENT_RUNS: usize = 5;
+
+/// A page, redirect or error produced by the dashboard.
+#[derive(Debug, PartialEq)]
+pub struct DashboardResponse {
+    pub status: StatusCode,
+    /// Where to redirect to, for `303 See Other` responses.
+    pub location: Option<String>,
+    /// A `Set-Cookie` header value.
+    pub set_cookie: Option<String>,
+    /// The HTML page.
+    pub body: String,
+}
+
+impl DashboardResponse {
+    fn page(status: StatusCode, title: &str, body: &str) -> Self {
+        Self {
+            status,
+            location: None,
+            set_cookie: None,
+            body: html::page(title, body),
+        }
+    }
+
+    fn redirect(location: impl Into<String>) -> Self {
+        Self {
+            status: StatusCode::SEE_OTHER,
+            location: Some(location.into()),
+            set_cookie: None,
+            body: String::new(),
+        }
+    }
+
+    fn error(status: StatusCode, message: &str) -> Self {
+        Self::page(
+            status,
+            status.canonical_reason().unwrap_or("Error"),
+            &format!(r#"<p class="error">{}</p>"#, escape(message)),
+        )
+    }
+}
+
+/// A logged-in user.
+struct Session {
+    user: OAuthUser,
+    /// The guilds the user may manage, as of the login.
+    guilds: Vec<UserGuild>,
+    expires: Instant,
+}
+
+/// The dashboard's login state.
+pub struct Dashboard {
+    oauth: Arc<dyn OAuthApi>,
+    /// Whether cookies are marked `Secure`, for dashboards served over HTTPS.
+    secure_cookies: bool,
+    sessions: Mutex<HashMap<String, Session>>,
+    /// Authorization requests in progress, by OAuth2 state value.
+    logins: Mutex<HashMap<String, Instant>>,
+}
+
+impl Dashboard {
+    /// Creates a dashboard that logs users in through `oauth`.
+    ///
+    /// # Arguments
+    /// * `oauth` - The Discord OAuth2 client
+    /// * `secure_cookies` - Whether the dashboard is served over HTTPS
+    pub fn new(oauth: Arc<dyn OAuthApi>, secure_cookies: bool) -> Self {
+        Self {
+            oauth,
+            secure_cookies,
+            sessions: Mutex::new(HashMap::new()),
+            logins: Mutex::new(HashMap::new()),
+        }
+    }
+
+    fn session_cookie(&self, value: &str, max_age: Duration) -> String {
+        format!(
+            "{}={}; Path=/; Max-Age={}; HttpOnly; SameSite=Lax{}",
+            SESSION_COOKIE,
+            value,
+            max_age.as_secs(),
+            if self.secure_cookies { "; Secure" } else { "" }
+        )
+    }
+
+    /// Answers a single dashboard request.
+    ///
+    /// # Arguments
+    /// * `state` - The admin state, for the autoclean manager
+    /// * `method` - The request method
+    /// * `path` - The request path, including the query string
+    /// * `cookie` - The value of the `Cookie` header, if present
+    /// * `body` - The request body, form encoded for `POST` requests
+    pub async fn handle(
+        &self,
+        state: &AdminState,
+        method: &Method,
+        path: &str,
+        cookie: Option<&str>,
+        body: &[u8],
+    ) -> DashboardResponse {
+        let (path, query) = path.split_once('?').unwrap_or((path, ""));
+        let segments: Vec<&str> = path
+            .trim_matches('/')
+            .split('/')
+            .filter(|segment| !segment.is_empty())
+            .collect();
+        let session_id = cookie.and_then(session_id);
+
+        match (method, segments.as_slice()) {
+            (&Method::GET, []) => self.home(state, session_id).await,
+            (&Method::GET, ["login"]) => self.login().await,
+            (&Method::GET, ["callback"]) => self.callback(&parse_query(query)).await,
+            (&Method::GET, ["logout"]) => self.logout(session_id).await,
+            (_, ["guilds", guild, rest @ ..]) => {
+                let Some(guild_id) = guild.parse().ok().filter(|id| *id != 0).map(GuildId::new)
+                else {
+                    return DashboardResponse::error(StatusCode::NOT_FOUND, "No such server.");
+                };
+                let form = parse_query(&String::from_utf8_lossy(body));
+                self.guild_route(state, session_id, guild_id, method, rest, &form)
+                    .await
+            }
+            _ => DashboardResponse::error(StatusCode::NOT_FOUND, "No such page."),
+        }
+    }
+
+    async fn home(&self, state: &AdminState, session_id: Option<&str>) -> DashboardResponse {
+        let sessions = self.sessions.lock().await;
+        let Some(session) = session_id.and_then(|id| live_session(&sessions, id)) else {
+            return DashboardResponse::page(
+                StatusCode::OK,
+                "Dashboard",
+                r#"<p>Log in to manage the cleanups of your servers.</p>
+<p><a href="/login">Log in with Discord</a></p>"#,
+            );
+        };
+
+        let mut rows = String::new();
+        for guild in &session.guilds {
+            let tasks = state.manager.guild_tasks(guild.id).await;
+            if tasks.is_empty() {
+                continue;
+            }
+            let deleted_today = deleted_today(&tasks);
+            rows.push_str(&format!(
+                r#"<tr><td><a href="/guilds/{}">{}</a></td><td>{}</td><td>{}</td></tr>"#,
+                guild.id,
+                escape(&guild.name),
+                tasks.len(),
+                deleted_today
+            ));
+        }
+        let guilds = if rows.is_empty() {
+            "<p>None of your servers have cleanups yet. Add one with <code>/autoclean add</code>.</p>"
+                .to_string()
+        } else {
+            format!(
+                "<table><tr><th>Server</th><th>Tasks</th><th>Deleted today</th></tr>{}</table>",
+                rows
+            )
+        };
+        DashboardResponse::page(
+            StatusCode::OK,
+            "Your servers",
+            &format!(
+                r#"<p>Logged in as {}. <a href="/logout">Log out</a></p>{}"#,
+                escape(&session.user.username),
+                guilds
+            ),
+        )
+    }
+
+    async fn login(&self) -> DashboardResponse {
+        let state = Crypto::random_token();
+        let mut logins = self.logins.lock().await;
+        let now = Instant::now();
+        logins.retain(|_, expires| *expires > now);
+        logins.insert(state.clone(), now + LOGIN_TTL);
+        DashboardResponse::redirect(self.oauth.authorize_url(&state))
+    }
+
+    async fn callback(&self, query: &HashMap<String, String>) -> DashboardResponse {
+        let (Some(code), Some(state)) = (query.get("code"), query.get("state")) else {
+            return DashboardResponse::error(
+                StatusCode::BAD_REQUEST,
+                "The login was cancelled or is incomplete.",
+            );
+        };
+        let expected = self.logins.lock().await.remove(state);
+        if !expected.is_some_and(|expires| expires > Instant::now()) {
+            return DashboardResponse::error(
+                StatusCode::BAD_REQUEST,
+                "The login expired. Please try again.",
+            );
+        }
+
+        let result = async {
+            let token = self.oauth.exchange_code(code).await?;
+            let user = self.oauth.current_user(&token).await?;
+            let guilds = self.oauth.user_guilds(&token).await?;
+            Ok::<_, crate::EuleError>((user, guilds))
+        }
+        .await;
+        let (user, guilds) = match result {
+            Ok(login) => login,
+            Err(e) => {
+                tracing::warn!("Dashboard login failed: {:?}", e);
+                return DashboardResponse::error(
+                    StatusCode::BAD_GATEWAY,
+                    "Discord did not accept the login. Please try again.",
+                );
+            }
+        };
+        tracing::info!("User {} logged in to the dashboard", user.id);
+
+        let session_id = Crypto::random_token();
+        let session = Session {
+            user,
+            guilds: guilds.into_iter().filter(UserGuild::can_manage).collect(),
+            expires: Instant::now() + SESSION_TTL,
+        };
+        let mut sessions = self.sessions.lock().await;
+        let now = Instant::now();
+        sessions.retain(|_, session| session.expires > now);
+        sessions.insert(session_id.clone(), session);
+
+        let mut response = DashboardResponse::redirect("/");
+        response.set_cookie = Some(self.session_cookie(&session_id, SESSION_TTL));
+        response
+    }
+
+    async fn logout(&self, session_id: Option<&str>) -> DashboardResponse {
+        if let Some(session_id) = session_id {
+            self.sessions.lock().await.remove(session_id);
+        }
+        let mut response = DashboardResponse::redirect("/");
+        response.set_cookie = Some(self.session_cookie("", Duration::ZERO));
+        response
+    }
+
+    async fn guild_route(
+        &self,
+        state: &AdminState,
+        session_id: Option<&str>,
+        guild_id: GuildId,
+        method: &Method,
+        rest: &[&str],
+        form: &HashMap<String, String>,
+    ) -> DashboardResponse {
+        let guild = {
+            let sessions = self.sessions.lock().await;
+            let Some(session) = session_id.and_then(|id| live_session(&sessions, id)) else {
+                return DashboardResponse::redirect("/login");
+            };
+            session
+                .guilds
+                .iter()
+                .find(|guild| guild.id == guild_id)
+                .cloned()
+        };
+        let Some(guild) = guild else {
+            return DashboardResponse::error(
+                StatusCode::FORBIDDEN,
+                "You need the Manage Messages permission in this server.",
+            );
+        };
+
+        match (method, rest) {
+            (&Method::GET, []) => guild_page(state, &guild).await,
+            (&Method::POST, ["tasks", channel]) => {
+                update_task(state, guild_id, channel, form).await
+            }
+            (&Method::POST, ["tasks", channel, "delete"]) => {
+                delete_task(state, guild_id, channel).await
+            }
+            _ => DashboardResponse::error(StatusCode::NOT_FOUND, "No such page."),
+        }
+    }
+}
+
+/// Extracts the session ID from a `Cookie` header.
+fn session_id(cookie: &str) -> Option<&str> {
+    cookie
+        .split(';')
+        .filter_map(|pair| pair.trim().split_once('='))
+        .find(|(name, _)| *name == SESSION_COOKIE)
+        .map(|(_, value)| value)
+        .filter(|value| !value.is_empty())
+}
+
+fn live_session<'a>(sessions: &'a HashMap<String, Session>, id: &str) -> Option<&'a Session> {
+    sessions
+        .get(id)
+        .filter(|session| session.expires > Instant::now())
+}
+
+/// Sums the messages a guild's tasks deleted today.
+fn deleted_today(tasks: &[(ChannelId, CleanupTask)]) -> u64 {
+    let today = SerializableInstant::now().utc_day();
+    tasks.iter().map(|(_, task)| task.deleted_on(today)).sum()
+}
+
+/// Renders a task's recent runs, newest first.
+fn recent_runs(task: &CleanupTask) -> String {
+    if task.history.is_empty() {
+        return "No runs yet".to_string();
+    }
+    task.history
+        .iter()
+        .rev()
+        .take(RECENT_RUNS)
+        .map(|run| {
+            let outcome = match (&run.error, run.cancelled) {
+                (Some(e), _) => format!(r#"<span class="error">failed: {}</span>"#, escape(e)),
+                (None, true) => format!("cancelled after {} deleted", run.deleted),
+                (None, false) => format!("{} deleted", run.deleted),
+            };
+            format!("{}: {}", relative_time(run.at), outcome)
+        })
+        .collect::<Vec<_>>()
+        .join("<br>")
+}
+
+async fn guild_page(state: &AdminState, guild: &UserGuild) -> DashboardResponse {
+    let tasks = state.manager.guild_tasks(guild.id).await;
+    if tasks.is_empty() {
+        return DashboardResponse::page(
+            StatusCode::OK,
+            &guild.name,
+            "<p>This server has no cleanups. Add one with <code>/autoclean add</code>.</p>",
+        );
+    }
+
+    let today = SerializableInstant::now().utc_day();
+    let mut rows = String::new();
+    for (channel_id, task) in &tasks {
+        let schedule = match (&task.forum, &task.threads) {
+            (Some(_), _) => "Forum cleanup, daily".to_string(),
+            (None, Some(_)) => "Thread cleanup, daily".to_string(),
+            (None, None) => format!(
+                r#"<form method="post" action="/guilds/{0}/tasks/{1}">
+every <input type="number" name="interval_minutes" min="1" value="{2}"> min
+<label><input type="checkbox" name="include_threads"{3}> threads</label>
+<button>Save</button></form>"#,
+                guild.id,
+                channel_id,
+                task.interval.as_secs() / 60,
+                if task.include_threads { " checked" } else { "" }
+            ),
+        };
+        rows.push_str(&format!(
+            r#"<tr><td>#{1}</td><td>{2}</td><td>{3}<br>next {4}</td><td>{5}</td><td>{6}</td>
+<td><form method="post" action="/guilds/{0}/tasks/{1}/delete"><button>Remove</button></form></td></tr>"#,
+            guild.id,
+            channel_id,
+            schedule,
+            relative_time(task.last_cleanup),
+            relative_time(task.next_cleanup()),
+            task.deleted_on(today),
+            recent_runs(task)
+        ));
+    }
+    DashboardResponse::page(
+        StatusCode::OK,
+        &guild.name,
+        &format!(
+            r#"<p>{} cleanup tasks, {} messages deleted today.</p>
+<table><tr><th>Channel</th><th>Schedule</th><th>Last run</th><th>Deleted today</th><th>Recent runs</th><th></th></tr>
+{}</table>"#,
+            tasks.len(),
+            deleted_today(&tasks),
+            rows
+        ),
+    )
+}
+
+/// Looks up a task of the guild, so forms can't reach other guilds' tasks.
+async fn guild_task(
+    state: &AdminState,
+    guild_id: GuildId,
+    channel: &str,
+) -> Result<ChannelId, DashboardResponse> {
+    let channel_id = channel
+        .parse()
+        .ok()
+        .filter(|id| *id != 0)
+        .map(ChannelId::new)
+        .ok_or_else(|| DashboardResponse::error(StatusCode::NOT_FOUND, "No such task."))?;
+    match state.manager.task(guild_id, channel_id).await {
+        Some(_) => Ok(channel_id),
+        None => Err(DashboardResponse::error(
+            StatusCode::NOT_FOUND,
+            "No such task.",
+        )),
+    }
+}
+
+async fn update_task(
+    state: &AdminState,
+    guild_id: GuildId,
+    channel: &str,
+    form: &HashMap<String, String>,
+) -> DashboardResponse {
+    if lifecycle::in_maintenance() {
+        return DashboardResponse::error(
+            StatusCode::SERVICE_UNAVAILABLE,
+            "The bot is in maintenance mode. Try again later.",
+        );
+    }
+    let channel_id = match guild_task(state, guild_id, channel).await {
+        Ok(channel_id) => channel_id,
+        Err(response) => return response,
+    };
+    // The same minimum as the JSON and gRPC APIs, and no overflow on huge input
+    let Some(interval_secs) = form
+        .get("interval_minutes")
+        .and_then(|minutes| minutes.trim().parse::<u64>().ok())
+        .and_then(|minutes| minutes.checked_mul(60))
+        .filter(|secs| *secs >= MIN_INTERVAL_SECS)
+    else {
+        return DashboardResponse::error(
+            StatusCode::BAD_REQUEST,
+            "The interval must be a whole number of minutes.",
+        );
+    };
+    let include_threads = form.contains_key("include_threads");
+
+    let result = async {
+        state
+            .manager
+            .set_interval(guild_id, channel_id, Duration::from_secs(interval_secs))
+            .await?;
+        state
+            .manager
+            .set_include_threads(guild_id, channel_id, include_threads)
+            .await
+    }
+    .await;
+    if let Err(e) = result {
+        tracing::error!("Failed to update task from the dashboard: {:?}", e);
+        return DashboardResponse::error(
+            StatusCode::INTERNAL_SERVER_ERROR,
+            "The task could not be saved.",
+        );
+    }
+    DashboardResponse::redirect(format!("/guilds/{}", guild_id))
+}
+
+async fn delete_task(state: &AdminState, guild_id: GuildId, channel: &str) -> DashboardResponse {
+    if lifecycle::in_maintenance() {
+        return DashboardResponse::error(
+            StatusCode::SERVICE_UNAVAILABLE,
+            "The bot is in maintenance mode. Try again later.",
+        );
+    }
+    let channel_id = match guild_task(state, guild_id, channel).await {
+        Ok(channel_id) => channel_id,
+        Err(response) => return response,
+    };
+    if let Err(e) = state.manager.remove_task(guild_id, channel_id).await {
+        tracing::error!("Failed to remove task from the dashboard: {:?}", e);
+        return DashboardResponse::error(
+            StatusCode::INTERNAL_SERVER_ERROR,
+            "The task could not be removed.",
+        );
+    }
+    DashboardResponse::redirect(format!("/guilds/{}", guild_id))
+}
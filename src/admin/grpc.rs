@@ -0,0 +1,334 @@
+//! The gRPC control plane, built with the `grpc` feature.
+//!
+//! The service in `proto/eule.proto` mirrors the REST API, authenticated with
+//! the same admin token, and adds a streaming `Purge` call that reports
+//! progress as messages are deleted.
+
+use crate::{
+    admin::{tokens_match, AdminState, MIN_INTERVAL_SECS},
+    lifecycle,
+    purge::PurgeReport,
+    tasks::{CleanupTask, RunRecord},
+    utils::SerializableInstant,
+};
+use poise::serenity_prelude::{ChannelId, GuildId};
+use std::{pin::Pin, sync::Arc};
+use tokio::{
+    net::TcpListener,
+    sync::{mpsc, watch},
+    time::Duration,
+};
+use tokio_stream::{
+    wrappers::{ReceiverStream, TcpListenerStream},
+    Stream,
+};
+use tonic::{service::Interceptor, transport::Server, Request, Response, Status};
+
+tonic::include_proto!("eule.v1");
+
+use control_server::{Control, ControlServer};
+
+/// How many progress updates may queue for a slow client before some are skipped.
+const PROGRESS_BUFFER: usize = 16;
+
+/// Serves the control plane on a listener until the task is aborted.
+///
+/// # Arguments
+/// * `listener` - The bound listener to accept connections on
+/// * `state` - The state shared with the REST API; its token must be set
+pub async fn serve(listener: TcpListener, state: Arc<AdminState>) {
+    let auth = BearerAuth {
+        token: state.token.clone(),
+    };
+    let service = ControlServer::with_interceptor(ControlService { state }, auth);
+    if let Err(e) = Server::builder()
+        .add_service(service)
+        .serve_with_incoming(TcpListenerStream::new(listener))
+        .await
+    {
+        tracing::error!("gRPC control plane stopped: {:?}", e);
+    }
+}
+
+/// Rejects calls without the admin token.
+#[derive(Clone)]
+struct BearerAuth {
+    token: Option<String>,
+}
+
+impl Interceptor for BearerAuth {
+    fn call(&mut self, request: Request<()>) -> Result<Request<()>, Status> {
+        let Some(expected) = &self.token else {
+            return Err(Status::unavailable("The control plane is disabled"));
+        };
+        let token = request
+            .metadata()
+            .get("authorization")
+            .and_then(|value| value.to_str().ok())
+            .and_then(|value| value.strip_prefix("Bearer "));
+        match token {
+            Some(token) if tokens_match(expected, token) => Ok(request),
+            _ => Err(Status::unauthenticated("Missing or invalid token")),
+        }
+    }
+}
+
+struct ControlService {
+    state: Arc<AdminState>,
+}
+
+impl Task {
+    fn new(guild_id: GuildId, channel_id: ChannelId, task: &CleanupTask) -> Self {
+        Self {
+            guild_id: guild_id.get(),
+            channel_id: channel_id.get(),
+            interval_secs: task.interval.as_secs(),
+            include_threads: task.include_threads,
+            forum: task.forum.is_some(),
+            thread_cleanup: task.threads.is_some(),
+            last_cleanup: task.last_cleanup.unix_secs(),
+            next_cleanup: task.next_cleanup().unix_secs(),
+            deleted_today: task.deleted_on(SerializableInstant::now().utc_day()),
+            running: task.running.is_some(),
+        }
+    }
+}
+
+impl From<&RunRecord> for Run {
+    fn from(record: &RunRecord) -> Self {
+        Self {
+            at: record.at.unix_secs(),
+            deleted: record.deleted as u64,
+            cancelled: record.cancelled,
+            error: record.error.clone(),
+        }
+    }
+}
+
+impl PurgeProgress {
+    fn new(report: &PurgeReport) -> Self {
+        Self {
+            deleted: report.deleted as u64,
+            pending: report.pending as u64,
+            done: false,
+            cancelled: false,
+        }
+    }
+}
+
+fn parse_key(key: &TaskKey) -> Result<(GuildId, ChannelId), Status> {
+    if key.guild_id == 0 || key.channel_id == 0 {
+        return Err(Status::invalid_argument("Invalid guild or channel ID"));
+    }
+    Ok((GuildId::new(key.guild_id), ChannelId::new(key.channel_id)))
+}
+
+fn check_interval(interval_secs: u64) -> Result<Duration, Status> {
+    if interval_secs < MIN_INTERVAL_SECS {
+        return Err(Status::invalid_argument(format!(
+            "interval_secs must be at least {}",
+            MIN_INTERVAL_SECS
+        )));
+    }
+    Ok(Duration::from_secs(interval_secs))
+}
+
+/// Turns away calls that would change tasks or delete messages while the bot
+/// is in maintenance mode, as its commands are.
+fn check_maintenance() -> Result<(), Status> {
+    if lifecycle::in_maintenance() {
+        return Err(Status::unavailable("The bot is in maintenance mode"));
+    }
+    Ok(())
+}
+
+fn internal_error(e: impl std::fmt::Debug) -> Status {
+    tracing::error!("gRPC control plane request failed: {:?}", e);
+    Status::internal("Internal error")
+}
+
+impl ControlService {
+    async fn task(&self, guild_id: GuildId, channel_id: ChannelId) -> Result<CleanupTask, Status> {
+        self.state
+            .manager
+            .task(guild_id, channel_id)
+            .await
+            .ok_or_else(|| Status::not_found("No such task"))
+    }
+}
+
+#[tonic::async_trait]
+impl Control for ControlService {
+    type PurgeStream = Pin<Box<dyn Stream<Item = Result<PurgeProgress, Status>> + Send>>;
+
+    async fn list_tasks(
+        &self,
+        _request: Request<ListTasksRequest>,
+    ) -> Result<Response<ListTasksResponse>, Status> {
+        let manager = &self.state.manager;
+        let mut guild_ids: Vec<GuildId> = manager
+            .all_tasks()
+            .await
+            .into_iter()
+            .map(|(guild_id, _, _)| guild_id)
+            .collect();
+        guild_ids.dedup();
+
+        let mut tasks = Vec::new();
+        for guild_id in guild_ids {
+            for (channel_id, task) in manager.guild_tasks(guild_id).await {
+                tasks.push(Task::new(guild_id, channel_id, &task));
+            }
+        }
+        Ok(Response::new(ListTasksResponse { tasks }))
+    }
+
+    async fn get_task(&self, request: Request<TaskKey>) -> Result<Response<Task>, Status> {
+        let (guild_id, channel_id) = parse_key(request.get_ref())?;
+        let task = self.task(guild_id, channel_id).await?;
+        Ok(Response::new(Task::new(guild_id, channel_id, &task)))
+    }
+
+    async fn create_task(
+        &self,
+        request: Request<CreateTaskRequest>,
+    ) -> Result<Response<Task>, Status> {
+        check_maintenance()?;
+        let request = request.into_inner();
+        let (guild_id, channel_id) = parse_key(&TaskKey {
+            guild_id: request.guild_id,
+            channel_id: request.channel_id,
+        })?;
+        let interval = check_interval(request.interval_secs)?;
+        let manager = &self.state.manager;
+        if manager.task(guild_id, channel_id).await.is_some() {
+            return Err(Status::already_exists("The channel already has a task"));
+        }
+
+        manager
+            .add_task(guild_id, channel_id, interval)
+            .await
+            .map_err(internal_error)?;
+        if request.include_threads {
+            manager
+                .set_include_threads(guild_id, channel_id, true)
+                .await
+                .map_err(internal_error)?;
+        }
+        let task = self.task(guild_id, channel_id).await?;
+        Ok(Response::new(Task::new(guild_id, channel_id, &task)))
+    }
+
+    async fn update_task(
+        &self,
+        request: Request<UpdateTaskRequest>,
+    ) -> Result<Response<Task>, Status> {
+        check_maintenance()?;
+        let request = request.into_inner();
+        let key = request
+            .key
+            .ok_or_else(|| Status::invalid_argument("key is required"))?;
+        let (guild_id, channel_id) = parse_key(&key)?;
+        let interval = request.interval_secs.map(check_interval).transpose()?;
+        self.task(guild_id, channel_id).await?;
+
+        let manager = &self.state.manager;
+        if let Some(interval) = interval {
+            manager
+                .set_interval(guild_id, channel_id, interval)
+                .await
+                .map_err(internal_error)?;
+        }
+        if let Some(include_threads) = request.include_threads {
+            manager
+                .set_include_threads(guild_id, channel_id, include_threads)
+                .await
+                .map_err(internal_error)?;
+        }
+        let task = self.task(guild_id, channel_id).await?;
+        Ok(Response::new(Task::new(guild_id, channel_id, &task)))
+    }
+
+    async fn delete_task(
+        &self,
+        request: Request<TaskKey>,
+    ) -> Result<Response<DeleteTaskResponse>, Status> {
+        check_maintenance()?;
+        let (guild_id, channel_id) = parse_key(request.get_ref())?;
+        let removed = self
+            .state
+            .manager
+            .remove_task(guild_id, channel_id)
+            .await
+            .map_err(internal_error)?;
+        if !removed {
+            return Err(Status::not_found("No such task"));
+        }
+        Ok(Response::new(DeleteTaskResponse {}))
+    }
+
+    async fn get_history(&self, request: Request<TaskKey>) -> Result<Response<History>, Status> {
+        let (guild_id, channel_id) = parse_key(request.get_ref())?;
+        let task = self.task(guild_id, channel_id).await?;
+        let runs = task.history.iter().rev().map(Run::from).collect();
+        Ok(Response::new(History { runs }))
+    }
+
+    async fn purge(
+        &self,
+        request: Request<TaskKey>,
+    ) -> Result<Response<Self::PurgeStream>, Status> {
+        check_maintenance()?;
+        let (guild_id, channel_id) = parse_key(request.get_ref())?;
+        if self.task(guild_id, channel_id).await?.running.is_some() {
+            return Err(Status::failed_precondition("A cleanup is already running"));
+        }
+
+        let (sender, receiver) = mpsc::channel(PROGRESS_BUFFER);
+        let state = Arc::clone(&self.state);
+        tokio::spawn(async move {
+            let (progress, mut updates) = watch::channel(PurgeReport::default());
+            let purge =
+                state
+                    .manager
+                    .purge_now_with_progress(&*state.api, guild_id, channel_id, progress);
+            tokio::pin!(purge);
+
+            // The purge keeps running if the client goes away, as it would
+            // have on a schedule; updates are only skipped when they can't keep up
+            let result = loop {
+                tokio::select! {
+                    result = &mut purge => break result,
+                    changed = updates.changed() => {
+                        if changed.is_err() {
+                            break purge.await;
+                        }
+                        let report = *updates.borrow_and_update();
+                        let _ = sender.try_send(Ok(PurgeProgress::new(&report)));
+                    }
+                }
+            };
+
+            let last = match result {
+                Ok(()) => {
+                    let run = state
+                        .manager
+                        .task(guild_id, channel_id)
+                        .await
+                        .and_then(|task| task.history.back().cloned());
+                    let mut last = PurgeProgress::new(&updates.borrow());
+                    last.done = true;
+                    if let Some(run) = run {
+                        last.deleted = run.deleted as u64;
+                        last.cancelled = run.cancelled;
+                    }
+                    Ok(last)
+                }
+                Err(e) => Err(internal_error(e)),
+            };
+            let _ = sender.send(last).await;
+        });
+
+        Ok(Response::new(Box::pin(ReceiverStream::new(receiver))))
+    }
+}
@@ -0,0 +1,144 @@
+//! HTML and URL encoding helpers for the dashboard.
+
+use crate::utils::SerializableInstant;
+use std::collections::HashMap;
+
+/// Escapes text for use in HTML content and quoted attribute values.
+///
+/// # Examples
+///
+/// ```
+/// use eule::admin::html::escape;
+///
+/// assert_eq!(escape("<b>\"Tom\" & 'Jerry'</b>"), "&lt;b&gt;&quot;Tom&quot; &amp; &#39;Jerry&#39;&lt;/b&gt;");
+/// ```
+pub fn escape(text: &str) -> String {
+    let mut escaped = String::with_capacity(text.len());
+    for c in text.chars() {
+        match c {
+            '&' => escaped.push_str("&amp;"),
+            '<' => escaped.push_str("&lt;"),
+            '>' => escaped.push_str("&gt;"),
+            '"' => escaped.push_str("&quot;"),
+            '\'' => escaped.push_str("&#39;"),
+            _ => escaped.push(c),
+        }
+    }
+    escaped
+}
+
+/// Percent-encodes text for use in a URL query.
+pub fn url_encode(text: &str) -> String {
+    text.bytes()
+        .map(|byte| match byte {
+            b'A'..=b'Z' | b'a'..=b'z' | b'0'..=b'9' | b'-' | b'_' | b'.' | b'~' => {
+                (byte as char).to_string()
+            }
+            _ => format!("%{:02X}", byte),
+        })
+        .collect()
+}
+
+/// Decodes a percent-encoded query or form value, treating `+` as a space.
+pub fn url_decode(text: &str) -> String {
+    let bytes = text.as_bytes();
+    let mut decoded = Vec::with_capacity(bytes.len());
+    let mut i = 0;
+    while i < bytes.len() {
+        match bytes[i] {
+            b'+' => decoded.push(b' '),
+            b'%' if i + 2 < bytes.len() => {
+                let hex = |byte: u8| (byte as char).to_digit(16);
+                match (hex(bytes[i + 1]), hex(bytes[i + 2])) {
+                    (Some(high), Some(low)) => {
+                        decoded.push((high * 16 + low) as u8);
+                        i += 2;
+                    }
+                    _ => decoded.push(b'%'),
+                }
+            }
+            byte => decoded.push(byte),
+        }
+        i += 1;
+    }
+    String::from_utf8_lossy(&decoded).into_owned()
+}
+
+/// Parses a query string or `application/x-www-form-urlencoded` body.
+///
+/// # Examples
+///
+/// ```
+/// use eule::admin::html::parse_query;
+///
+/// let query = parse_query("code=abc&state=x%2Fy&empty=");
+/// assert_eq!(query["code"], "abc");
+/// assert_eq!(query["state"], "x/y");
+/// assert_eq!(query["empty"], "");
+/// ```
+pub fn parse_query(query: &str) -> HashMap<String, String> {
+    query
+        .split('&')
+        .filter(|pair| !pair.is_empty())
+        .map(|pair| {
+            let (key, value) = pair.split_once('=').unwrap_or((pair, ""));
+            (url_decode(key), url_decode(value))
+        })
+        .collect()
+}
+
+/// Describes an instant relative to now, such as "5 min ago" or "in 2 h".
+pub fn relative_time(at: SerializableInstant) -> String {
+    let now = SerializableInstant::now();
+    let (secs, past) = if at <= now {
+        (now.duration_since(at).as_secs(), true)
+    } else {
+        (at.duration_since(now).as_secs(), false)
+    };
+    let amount = match secs {
+        0..=59 => return if past { "just now" } else { "now" }.to_string(),
+        60..=3_599 => format!("{} min", secs / 60),
+        3_600..=86_399 => format!("{} h", secs / 3_600),
+        _ => format!("{} d", secs / 86_400),
+    };
+    if past {
+        format!("{} ago", amount)
+    } else {
+        format!("in {}", amount)
+    }
+}
+
+/// Wraps page content in the dashboard's layout.
+///
+/// # Arguments
+/// * `title` - The page title, which is escaped
+/// * `body` - The page content as HTML
+pub fn page(title: &str, body: &str) -> String {
+    format!(
+        r#"<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{0} · Eule</title>
+<style>
+body {{ font-family: system-ui, sans-serif; max-width: 60rem; margin: 2rem auto; padding: 0 1rem; color: #222; }}
+table {{ border-collapse: collapse; width: 100%; margin-bottom: 1.5rem; }}
+th, td {{ text-align: left; padding: .4rem .6rem; border-bottom: 1px solid #ddd; vertical-align: top; }}
+form {{ display: inline; }}
+input[type=number] {{ width: 6rem; }}
+.error {{ color: #b00020; }}
+nav {{ margin-bottom: 1.5rem; }}
+</style>
+</head>
+<body>
+<nav><a href="/">🦉 Eule</a></nav>
+<h1>{0}</h1>
+{1}
+</body>
+</html>
+"#,
+        escape(title),
+        body
+    )
+}
@@ -0,0 +1,147 @@
+//! Startup of the admin API, dashboard and gRPC control plane.
+
+use crate::{
+    admin::{AdminState, Dashboard, DiscordOAuth},
+    config::{BotConfig, ADMIN_TOKEN_ENV_VAR, OAUTH_SECRET_ENV_VAR},
+    credentials::TokenRotation,
+    error::EuleError,
+    store::KvStore,
+    tasks::{AutocleanManager, BotApi},
+};
+use std::sync::Arc;
+use tokio::net::TcpListener;
+
+/// The admin listeners, bound before the bot connects so that configuration
+/// mistakes and unavailable ports fail startup instead of going unnoticed.
+pub struct AdminListeners {
+    http: Option<TcpListener>,
+    grpc: Option<TcpListener>,
+    token: Option<String>,
+    dashboard: Option<Arc<Dashboard>>,
+    calendar_key: Option<String>,
+}
+
+/// Creates the web dashboard if it is configured.
+fn dashboard(config: &BotConfig) -> Result<Option<Arc<Dashboard>>, EuleError> {
+    let config = &config.dashboard;
+    let (Some(client_id), Some(public_url)) = (config.client_id, &config.public_url) else {
+        return Ok(None);
+    };
+    let secret = config
+        .resolve_secret(std::env::var(OAUTH_SECRET_ENV_VAR).ok())
+        .ok_or_else(|| {
+            EuleError::InvalidConfig(format!(
+                "the dashboard needs an OAuth2 client secret; set dashboard.client_secret or {}",
+                OAUTH_SECRET_ENV_VAR
+            ))
+        })?;
+    let oauth = DiscordOAuth::new(client_id, secret, public_url);
+    Ok(Some(Arc::new(Dashboard::new(
+        Arc::new(oauth),
+        public_url.starts_with("https://"),
+    ))))
+}
+
+impl AdminListeners {
+    /// Binds the listeners the configuration asks for.
+    ///
+    /// # Arguments
+    /// * `config` - The bot configuration
+    /// * `store` - The store holding the calendar feed key
+    ///
+    /// # Returns
+    /// `None` if neither `admin.listen` nor `admin.grpc_listen` is set.
+    ///
+    /// # Errors
+    ///
+    /// Returns `EuleError::InvalidConfig` if a listener has nothing to serve or
+    /// lacks the admin token, and `EuleError::Io` if an address can't be bound.
+    pub async fn bind(config: &BotConfig, store: &KvStore) -> Result<Option<Self>, EuleError> {
+        let admin = &config.admin;
+        if admin.listen.is_none() && admin.grpc_listen.is_none() {
+            return Ok(None);
+        }
+        let token = admin.resolve_token(std::env::var(ADMIN_TOKEN_ENV_VAR).ok());
+        let dashboard = dashboard(config)?;
+
+        let http = match admin.listen {
+            Some(address) => {
+                if token.is_none() && dashboard.is_none() {
+                    return Err(EuleError::InvalidConfig(format!(
+                        "admin.listen is set but neither an admin token nor the dashboard is configured; set admin.token or {}",
+                        ADMIN_TOKEN_ENV_VAR
+                    )));
+                }
+                let listener = TcpListener::bind(address).await?;
+                tracing::info!("Serving the admin API and dashboard on {}", address);
+                Some(listener)
+            }
+            None => None,
+        };
+        // Calendar feeds are only reachable over HTTP
+        let calendar_key = match http {
+            Some(_) => Some(crate::admin::calendar::calendar_key(store).await?),
+            None => None,
+        };
+
+        let grpc = match admin.grpc_listen {
+            Some(_) if !cfg!(feature = "grpc") => {
+                return Err(EuleError::InvalidConfig(
+                    "admin.grpc_listen is set but eule was built without the grpc feature"
+                        .to_string(),
+                ));
+            }
+            Some(_) if token.is_none() => {
+                return Err(EuleError::InvalidConfig(format!(
+                    "the gRPC control plane needs an admin token; set admin.token or {}",
+                    ADMIN_TOKEN_ENV_VAR
+                )));
+            }
+            Some(address) => {
+                let listener = TcpListener::bind(address).await?;
+                tracing::info!("Serving the gRPC control plane on {}", address);
+                Some(listener)
+            }
+            None => None,
+        };
+
+        Ok(Some(Self {
+            http,
+            grpc,
+            token,
+            dashboard,
+            calendar_key,
+        }))
+    }
+
+    /// Starts serving on the bound listeners.
+    ///
+    /// # Arguments
+    /// * `manager` - The manager whose tasks are exposed
+    /// * `api` - The Discord client for purges started remotely
+    /// * `rotation` - Replaces the bot's Discord token, if it can be rotated
+    pub fn spawn(
+        self,
+        manager: AutocleanManager,
+        api: Arc<dyn BotApi>,
+        rotation: Option<Arc<TokenRotation>>,
+    ) {
+        let state = Arc::new(AdminState {
+            manager,
+            api,
+            token: self.token,
+            dashboard: self.dashboard,
+            calendar_key: self.calendar_key,
+            rotation,
+        });
+        if let Some(listener) = self.http {
+            tokio::spawn(crate::admin::serve(listener, Arc::clone(&state)));
+        }
+        #[cfg(feature = "grpc")]
+        if let Some(listener) = self.grpc {
+            tokio::spawn(crate::admin::grpc::serve(listener, state));
+        }
+        #[cfg(not(feature = "grpc"))]
+        let _ = self.grpc;
+    }
+}
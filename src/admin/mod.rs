@@ -0,0 +1,83 @@
+//! HTTP admin API.
+//!
+//! A small JSON API for managing cleanup tasks without Discord, for dashboards
+//! and scripts. It is served only when `[admin] listen` and a token are
+//! configured, and every request must carry the token as
+//! `Authorization: Bearer <token>`.
+//!
+//! | Method   | Path                                         | Action                       |
+//! |----------|----------------------------------------------|------------------------------|
+//! | `GET`    | `/api/tasks`                                 | List all tasks               |
+//! | `POST`   | `/api/tasks`                                 | Create a task                |
+//! | `GET`    | `/api/tasks/{guild_id}/{channel_id}`         | Show a task                  |
+//! | `PATCH`  | `/api/tasks/{guild_id}/{channel_id}`         | Change a task's settings     |
+//! | `DELETE` | `/api/tasks/{guild_id}/{channel_id}`         | Delete a task                |
+//! | `POST`   | `/api/tasks/{guild_id}/{channel_id}/purge`   | Start a cleanup right now    |
+//! | `GET`    | `/api/tasks/{guild_id}/{channel_id}/history` | List past runs, newest first |
+//! | `GET`    | `/api/rate_limits`                           | Count rate-limit waits       |
+//! | `GET`    | `/api/retry_queue`                           | Count deletes set aside      |
+//! | `GET`    | `/api/commands`                              | Count command uses, errors   |
+//! | `GET`    | `/api/invite`                                | Show the bot's invite link   |
+//! | `POST`   | `/api/token`                                 | Rotate the Discord token     |
+//!
+//! `/calendar/{guild_id}.ics` serves iCalendar feeds of upcoming purges, which
+//! are unlocked by a per-guild token in the URL instead; see `calendar`.
+//!
+//! The same listener serves the web dashboard in `dashboard` on all other
+//! paths when it is configured.
+//!
+//! Routing is kept separate from the HTTP server in `routes`, so it can be
+//! exercised without opening a socket. Builds with the `grpc` feature can also
+//! serve the same operations over gRPC; see `grpc`.
+
+pub mod calendar;
+pub mod dashboard;
+#[cfg(feature = "grpc")]
+pub mod grpc;
+pub mod html;
+mod listeners;
+pub mod oauth;
+mod routes;
+mod server;
+
+pub use dashboard::{Dashboard, DashboardResponse};
+pub use listeners::AdminListeners;
+pub use oauth::{DiscordOAuth, OAuthApi, OAuthUser, UserGuild};
+pub use routes::{handle, ApiResponse, QueuedDeletes, RetryQueueView, RunView, TaskView};
+pub use server::serve;
+
+use crate::{
+    credentials::TokenRotation,
+    tasks::{AutocleanManager, BotApi},
+};
+use std::sync::Arc;
+
+/// The shortest task interval accepted remotely, matching the scheduler's tick.
+pub(crate) const MIN_INTERVAL_SECS: u64 = 60;
+
+/// Compares two tokens in time independent of where they first differ.
+pub(crate) fn tokens_match(expected: &str, given: &str) -> bool {
+    expected.len() == given.len()
+        && expected
+            .bytes()
+            .zip(given.bytes())
+            .fold(0, |diff, (a, b)| diff | (a ^ b))
+            == 0
+}
+
+/// Everything the admin API needs to answer requests.
+#[derive(Clone)]
+pub struct AdminState {
+    /// The manager whose tasks the API exposes.
+    pub manager: AutocleanManager,
+    /// The Discord client used for purges started through the API.
+    pub api: Arc<dyn BotApi>,
+    /// The bearer token clients must present, or `None` to disable the JSON API.
+    pub token: Option<String>,
+    /// The web dashboard, if configured.
+    pub dashboard: Option<Arc<Dashboard>>,
+    /// The key calendar feed tokens are derived from, or `None` to disable feeds.
+    pub calendar_key: Option<String>,
+    /// Replaces the bot's Discord token, or `None` if it can't be rotated.
+    pub rotation: Option<Arc<TokenRotation>>,
+}
@@ -0,0 +1,163 @@
+//! Discord OAuth2 for dashboard logins.
+//!
+//! Users authorize with the `identify` and `guilds` scopes, which is enough to
+//! learn who they are and which servers they may manage. The access token is
+//! only used during the login itself and is not kept.
+
+use crate::error::EuleError;
+use async_trait::async_trait;
+use poise::serenity_prelude::{GuildId, Permissions, UserId};
+use serde::Deserialize;
+
+/// Discord's OAuth2 authorization page.
+const AUTHORIZE_URL: &str = "https://discord.com/oauth2/authorize";
+
+/// The endpoint authorization codes are exchanged at.
+const TOKEN_URL: &str = "https://discord.com/api/v10/oauth2/token";
+
+/// The base of the REST endpoints used with the user's access token.
+const API_URL: &str = "https://discord.com/api/v10";
+
+/// The logged-in Discord user.
+#[derive(Clone, Debug, Deserialize, PartialEq, Eq)]
+pub struct OAuthUser {
+    pub id: UserId,
+    pub username: String,
+}
+
+/// A guild the logged-in user is a member of, with their permissions in it.
+#[derive(Clone, Debug, Deserialize, PartialEq, Eq)]
+pub struct UserGuild {
+    pub id: GuildId,
+    pub name: String,
+    #[serde(default)]
+    pub owner: bool,
+    /// The user's permissions, as the decimal string Discord sends.
+    #[serde(default)]
+    pub permissions: String,
+}
+
+impl UserGuild {
+    /// Returns whether the user may manage cleanups in this guild.
+    ///
+    /// This mirrors the slash commands, which require `MANAGE_MESSAGES`.
+    pub fn can_manage(&self) -> bool {
+        let permissions =
+            Permissions::from_bits_truncate(self.permissions.parse().unwrap_or_default());
+        self.owner || permissions.administrator() || permissions.manage_messages()
+    }
+}
+
+/// The parts of Discord's OAuth2 API a dashboard login needs.
+///
+/// Implemented over HTTPS by `DiscordOAuth`, and by a mock in tests.
+#[async_trait]
+pub trait OAuthApi: Send + Sync {
+    /// Returns the URL to send a user to so they can authorize the dashboard.
+    fn authorize_url(&self, state: &str) -> String;
+
+    /// Exchanges an authorization code for an access token.
+    async fn exchange_code(&self, code: &str) -> Result<String, EuleError>;
+
+    /// Fetches the user an access token belongs to.
+    async fn current_user(&self, access_token: &str) -> Result<OAuthUser, EuleError>;
+
+    /// Fetches the guilds the user of an access token is a member of.
+    async fn user_guilds(&self, access_token: &str) -> Result<Vec<UserGuild>, EuleError>;
+}
+
+/// Talks to Discord's OAuth2 endpoints.
+pub struct DiscordOAuth {
+    client: reqwest::Client,
+    client_id: u64,
+    client_secret: String,
+    redirect_uri: String,
+}
+
+#[derive(Deserialize)]
+struct TokenResponse {
+    access_token: String,
+}
+
+impl DiscordOAuth {
+    /// Creates a client for a Discord application.
+    ///
+    /// # Arguments
+    /// * `client_id` - The application's OAuth2 client ID
+    /// * `client_secret` - The application's OAuth2 client secret
+    /// * `public_url` - The address the dashboard is reached at, without a trailing slash
+    pub fn new(client_id: u64, client_secret: String, public_url: &str) -> Self {
+        Self {
+            client: crate::proxy::client(),
+            client_id,
+            client_secret,
+            redirect_uri: format!("{}/callback", public_url.trim_end_matches('/')),
+        }
+    }
+}
+
+fn oauth_error(e: reqwest::Error) -> EuleError {
+    EuleError::OAuth(e.to_string())
+}
+
+#[async_trait]
+impl OAuthApi for DiscordOAuth {
+    fn authorize_url(&self, state: &str) -> String {
+        format!(
+            "{}?response_type=code&client_id={}&scope=identify%20guilds&state={}&redirect_uri={}",
+            AUTHORIZE_URL,
+            self.client_id,
+            state,
+            crate::admin::html::url_encode(&self.redirect_uri)
+        )
+    }
+
+    async fn exchange_code(&self, code: &str) -> Result<String, EuleError> {
+        let client_id = self.client_id.to_string();
+        let params = [
+            ("grant_type", "authorization_code"),
+            ("code", code),
+            ("redirect_uri", self.redirect_uri.as_str()),
+            ("client_id", client_id.as_str()),
+            ("client_secret", self.client_secret.as_str()),
+        ];
+        let token: TokenResponse = self
+            .client
+            .post(TOKEN_URL)
+            .form(&params)
+            .send()
+            .await
+            .and_then(reqwest::Response::error_for_status)
+            .map_err(oauth_error)?
+            .json()
+            .await
+            .map_err(oauth_error)?;
+        Ok(token.access_token)
+    }
+
+    async fn current_user(&self, access_token: &str) -> Result<OAuthUser, EuleError> {
+        self.client
+            .get(format!("{}/users/@me", API_URL))
+            .bearer_auth(access_token)
+            .send()
+            .await
+            .and_then(reqwest::Response::error_for_status)
+            .map_err(oauth_error)?
+            .json()
+            .await
+            .map_err(oauth_error)
+    }
+
+    async fn user_guilds(&self, access_token: &str) -> Result<Vec<UserGuild>, EuleError> {
+        self.client
+            .get(format!("{}/users/@me/guilds", API_URL))
+            .bearer_auth(access_token)
+            .send()
+            .await
+            .and_then(reqwest::Response::error_for_status)
+            .map_err(oauth_error)?
+            .json()
+            .await
+            .map_err(oauth_error)
+    }
+}
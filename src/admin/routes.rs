@@ -0,0 +1,488 @@
+//! Request routing for the admin API.
+
+use crate::{
+    admin::{tokens_match, AdminState, MIN_INTERVAL_SECS},
+    commands::usage::command_usage,
+    error::EuleError,
+    lifecycle,
+    onboarding::{invite_permissions, invite_url, INVITE_SCOPES},
+    purge::rate_limit_stats,
+    tasks::{CleanupTask, RunRecord},
+    utils::SerializableInstant,
+};
+use hyper::{Method, StatusCode};
+use poise::serenity_prelude::{ChannelId, GuildId};
+use serde::{Deserialize, Serialize};
+use serde_json::{json, Value};
+use std::collections::BTreeSet;
+use tokio::time::Duration;
+
+/// A status code and JSON body to send back to the client.
+#[derive(Debug, PartialEq)]
+pub struct ApiResponse {
+    pub status: StatusCode,
+    pub body: Value,
+}
+
+impl ApiResponse {
+    fn new(status: StatusCode, body: impl Serialize) -> Self {
+        Self {
+            status,
+            body: serde_json::to_value(body).unwrap_or(Value::Null),
+        }
+    }
+
+    fn error(status: StatusCode, message: impl Into<String>) -> Self {
+        Self::new(status, json!({ "error": message.into() }))
+    }
+}
+
+/// A cleanup task as returned by the API.
+///
+/// Times are Unix timestamps in seconds; IDs are strings, as elsewhere in
+/// Discord's API.
+#[derive(Debug, Serialize, Deserialize, PartialEq, Eq)]
+pub struct TaskView {
+    pub guild_id: GuildId,
+    pub channel_id: ChannelId,
+    pub interval_secs: u64,
+    pub include_threads: bool,
+    /// Whether the task cleans up forum posts instead of messages.
+    pub forum: bool,
+    /// Whether the task cleans up stale threads instead of messages.
+    #[serde(default)]
+    pub thread_cleanup: bool,
+    pub last_cleanup: u64,
+    pub next_cleanup: u64,
+    pub deleted_today: u64,
+    /// Whether a cleanup is in progress.
+    pub running: bool,
+    /// The task's labels.
+    #[serde(default)]
+    pub labels: BTreeSet<String>,
+}
+
+impl TaskView {
+    fn new(guild_id: GuildId, channel_id: ChannelId, task: &CleanupTask) -> Self {
+        Self {
+            guild_id,
+            channel_id,
+            interval_secs: task.interval.as_secs(),
+            include_threads: task.include_threads,
+            forum: task.forum.is_some(),
+            thread_cleanup: task.threads.is_some(),
+            last_cleanup: task.last_cleanup.unix_secs(),
+            next_cleanup: task.next_cleanup().unix_secs(),
+            deleted_today: task.deleted_on(SerializableInstant::now().utc_day()),
+            running: task.running.is_some(),
+            labels: task.labels.clone(),
+        }
+    }
+}
+
+/// A past cleanup run as returned by the API.
+#[derive(Debug, Serialize, Deserialize, PartialEq, Eq)]
+pub struct RunView {
+    pub at: u64,
+    pub deleted: usize,
+    pub cancelled: bool,
+    pub error: Option<String>,
+}
+
+impl From<&RunRecord> for RunView {
+    fn from(record: &RunRecord) -> Self {
+        Self {
+            at: record.at.unix_secs(),
+            deleted: record.deleted,
+            cancelled: record.cancelled,
+            error: record.error.clone(),
+        }
+    }
+}
+
+/// The body of `POST /api/tasks`.
+#[derive(Deserialize)]
+#[serde(deny_unknown_fields)]
+struct CreateTask {
+    guild_id: GuildId,
+    channel_id: ChannelId,
+    interval_secs: u64,
+    #[serde(default)]
+    include_threads: bool,
+}
+
+/// The body of `POST /api/token`.
+#[derive(Deserialize)]
+#[serde(deny_unknown_fields)]
+struct RotateToken {
+    token: String,
+}
+
+/// The body of `PATCH /api/tasks/{guild_id}/{channel_id}`; absent fields are left unchanged.
+#[derive(Deserialize)]
+#[serde(deny_unknown_fields)]
+struct UpdateTask {
+    interval_secs: Option<u64>,
+    include_threads: Option<bool>,
+}
+
+fn parse_body<T: for<'de> Deserialize<'de>>(body: &[u8]) -> Result<T, ApiResponse> {
+    serde_json::from_slice(body)
+        .map_err(|e| ApiResponse::error(StatusCode::BAD_REQUEST, format!("Invalid body: {}", e)))
+}
+
+fn check_interval(interval_secs: u64) -> Result<Duration, ApiResponse> {
+    if interval_secs < MIN_INTERVAL_SECS {
+        return Err(ApiResponse::error(
+            StatusCode::BAD_REQUEST,
+            format!("interval_secs must be at least {}", MIN_INTERVAL_SECS),
+        ));
+    }
+    Ok(Duration::from_secs(interval_secs))
+}
+
+fn parse_ids(guild: &str, channel: &str) -> Option<(GuildId, ChannelId)> {
+    let guild = guild.parse::<u64>().ok().filter(|id| *id != 0)?;
+    let channel = channel.parse::<u64>().ok().filter(|id| *id != 0)?;
+    Some((GuildId::new(guild), ChannelId::new(channel)))
+}
+
+fn internal_error(e: impl std::fmt::Debug) -> ApiResponse {
+    tracing::error!("Admin API request failed: {:?}", e);
+    ApiResponse::error(StatusCode::INTERNAL_SERVER_ERROR, "Internal error")
+}
+
+/// Turns away requests that would change tasks or delete messages while the
+/// bot is in maintenance mode, as its commands are.
+fn check_maintenance() -> Result<(), ApiResponse> {
+    if lifecycle::in_maintenance() {
+        return Err(ApiResponse::error(
+            StatusCode::SERVICE_UNAVAILABLE,
+            "The bot is in maintenance mode",
+        ));
+    }
+    Ok(())
+}
+
+fn not_found() -> ApiResponse {
+    ApiResponse::error(StatusCode::NOT_FOUND, "No such task")
+}
+
+/// Answers a single admin API request.
+///
+/// # Arguments
+/// * `state` - The manager, Discord client and token to serve with
+/// * `method` - The request method
+/// * `path` - The request path, optionally with a query string, which is ignored
+/// * `authorization` - The value of the `Authorization` header, if present
+/// * `body` - The request body
+pub async fn handle(
+    state: &AdminState,
+    method: &Method,
+    path: &str,
+    authorization: Option<&str>,
+    body: &[u8],
+) -> ApiResponse {
+    let Some(expected) = &state.token else {
+        return ApiResponse::error(StatusCode::NOT_FOUND, "The admin API is disabled");
+    };
+    let token = authorization.and_then(|value| value.strip_prefix("Bearer "));
+    if !token.is_some_and(|token| tokens_match(expected, token)) {
+        return ApiResponse::error(StatusCode::UNAUTHORIZED, "Missing or invalid token");
+    }
+
+    let path = path.split('?').next().unwrap_or_default();
+    let segments: Vec<&str> = path
+        .trim_matches('/')
+        .split('/')
+        .filter(|segment| !segment.is_empty())
+        .collect();
+    let result = match segments.as_slice() {
+        ["api", "tasks"] => match *method {
+            Method::GET => Ok(list_tasks(state).await),
+            Method::POST => create_task(state, body).await,
+            _ => Err(method_not_allowed()),
+        },
+        ["api", "rate_limits"] => match *method {
+            Method::GET => Ok(ApiResponse::new(StatusCode::OK, rate_limit_stats())),
+            _ => Err(method_not_allowed()),
+        },
+        ["api", "commands"] => match *method {
+            Method::GET => Ok(ApiResponse::new(StatusCode::OK, command_usage())),
+            _ => Err(method_not_allowed()),
+        },
+        ["api", "retry_queue"] => match *method {
+            Method::GET => Ok(retry_queue(state).await),
+            _ => Err(method_not_allowed()),
+        },
+        ["api", "invite"] => match *method {
+            Method::GET => invite(state),
+            _ => Err(method_not_allowed()),
+        },
+        ["api", "token"] => match *method {
+            Method::POST => rotate_token(state, body).await,
+            _ => Err(method_not_allowed()),
+        },
+        ["api", "tasks", guild, channel, rest @ ..] => {
+            let Some((guild_id, channel_id)) = parse_ids(guild, channel) else {
+                return ApiResponse::error(StatusCode::BAD_REQUEST, "Invalid guild or channel ID");
+            };
+            match (rest, method) {
+                ([], &Method::GET) => show_task(state, guild_id, channel_id).await,
+                ([], &Method::PATCH) => update_task(state, guild_id, channel_id, body).await,
+                ([], &Method::DELETE) => delete_task(state, guild_id, channel_id).await,
+                (["purge"], &Method::POST) => start_purge(state, guild_id, channel_id).await,
+                (["history"], &Method::GET) => history(state, guild_id, channel_id).await,
+                ([] | ["purge"] | ["history"], _) => Err(method_not_allowed()),
+                _ => Err(ApiResponse::error(StatusCode::NOT_FOUND, "Not found")),
+            }
+        }
+        _ => Err(ApiResponse::error(StatusCode::NOT_FOUND, "Not found")),
+    };
+    result.unwrap_or_else(|response| response)
+}
+
+fn method_not_allowed() -> ApiResponse {
+    ApiResponse::error(StatusCode::METHOD_NOT_ALLOWED, "Method not allowed")
+}
+
+async fn list_tasks(state: &AdminState) -> ApiResponse {
+    let mut guild_ids: Vec<GuildId> = state
+        .manager
+        .all_tasks()
+        .await
+        .into_iter()
+        .map(|(guild_id, _, _)| guild_id)
+        .collect();
+    guild_ids.dedup();
+
+    let mut views = Vec::new();
+    for guild_id in guild_ids {
+        for (channel_id, task) in state.manager.guild_tasks(guild_id).await {
+            views.push(TaskView::new(guild_id, channel_id, &task));
+        }
+    }
+    ApiResponse::new(StatusCode::OK, views)
+}
+
+async fn create_task(state: &AdminState, body: &[u8]) -> Result<ApiResponse, ApiResponse> {
+    check_maintenance()?;
+    let request: CreateTask = parse_body(body)?;
+    let interval = check_interval(request.interval_secs)?;
+    let (guild_id, channel_id) = (request.guild_id, request.channel_id);
+    if state.manager.task(guild_id, channel_id).await.is_some() {
+        return Err(ApiResponse::error(
+            StatusCode::CONFLICT,
+            "The channel already has a task",
+        ));
+    }
+
+    state
+        .manager
+        .add_task(guild_id, channel_id, interval)
+        .await
+        .map_err(internal_error)?;
+    if request.include_threads {
+        state
+            .manager
+            .set_include_threads(guild_id, channel_id, true)
+            .await
+            .map_err(internal_error)?;
+    }
+    let task = state
+        .manager
+        .task(guild_id, channel_id)
+        .await
+        .ok_or_else(not_found)?;
+    Ok(ApiResponse::new(
+        StatusCode::CREATED,
+        TaskView::new(guild_id, channel_id, &task),
+    ))
+}
+
+async fn show_task(
+    state: &AdminState,
+    guild_id: GuildId,
+    channel_id: ChannelId,
+) -> Result<ApiResponse, ApiResponse> {
+    let task = state
+        .manager
+        .task(guild_id, channel_id)
+        .await
+        .ok_or_else(not_found)?;
+    Ok(ApiResponse::new(
+        StatusCode::OK,
+        TaskView::new(guild_id, channel_id, &task),
+    ))
+}
+
+async fn update_task(
+    state: &AdminState,
+    guild_id: GuildId,
+    channel_id: ChannelId,
+    body: &[u8],
+) -> Result<ApiResponse, ApiResponse> {
+    check_maintenance()?;
+    let request: UpdateTask = parse_body(body)?;
+    let interval = request.interval_secs.map(check_interval).transpose()?;
+    if state.manager.task(guild_id, channel_id).await.is_none() {
+        return Err(not_found());
+    }
+
+    if let Some(interval) = interval {
+        state
+            .manager
+            .set_interval(guild_id, channel_id, interval)
+            .await
+            .map_err(internal_error)?;
+    }
+    if let Some(include_threads) = request.include_threads {
+        state
+            .manager
+            .set_include_threads(guild_id, channel_id, include_threads)
+            .await
+            .map_err(internal_error)?;
+    }
+    show_task(state, guild_id, channel_id).await
+}
+
+async fn delete_task(
+    state: &AdminState,
+    guild_id: GuildId,
+    channel_id: ChannelId,
+) -> Result<ApiResponse, ApiResponse> {
+    check_maintenance()?;
+    let removed = state
+        .manager
+        .remove_task(guild_id, channel_id)
+        .await
+        .map_err(internal_error)?;
+    if !removed {
+        return Err(not_found());
+    }
+    Ok(ApiResponse::new(StatusCode::OK, json!({ "deleted": true })))
+}
+
+async fn start_purge(
+    state: &AdminState,
+    guild_id: GuildId,
+    channel_id: ChannelId,
+) -> Result<ApiResponse, ApiResponse> {
+    check_maintenance()?;
+    let task = state
+        .manager
+        .task(guild_id, channel_id)
+        .await
+        .ok_or_else(not_found)?;
+    if task.running.is_some() {
+        return Err(ApiResponse::error(
+            StatusCode::CONFLICT,
+            "A cleanup is already running",
+        ));
+    }
+
+    // Purges can take far longer than a client will wait, so report progress
+    // through the task's history instead
+    let manager = state.manager.clone();
+    let api = state.api.clone();
+    tokio::spawn(async move {
+        if let Err(e) = manager.purge_now(&*api, guild_id, channel_id).await {
+            tracing::error!("Purge started through the admin API failed: {:?}", e);
+        }
+    });
+    Ok(ApiResponse::new(
+        StatusCode::ACCEPTED,
+        json!({ "started": true }),
+    ))
+}
+
+/// The messages set aside to retry, in total and by task.
+#[derive(Debug, Serialize, Deserialize, PartialEq, Eq)]
+pub struct RetryQueueView {
+    /// Set-aside messages across all tasks.
+    pub depth: usize,
+    /// The tasks with messages set aside.
+    pub tasks: Vec<QueuedDeletes>,
+}
+
+/// A task's set-aside messages.
+#[derive(Debug, Serialize, Deserialize, PartialEq, Eq)]
+pub struct QueuedDeletes {
+    pub guild_id: GuildId,
+    pub channel_id: ChannelId,
+    pub queued: usize,
+}
+
+async fn retry_queue(state: &AdminState) -> ApiResponse {
+    let tasks: Vec<QueuedDeletes> = state
+        .manager
+        .retry_queues()
+        .await
+        .into_iter()
+        .map(|(guild_id, channel_id, queued)| QueuedDeletes {
+            guild_id,
+            channel_id,
+            queued,
+        })
+        .collect();
+    let depth = tasks.iter().map(|task| task.queued).sum();
+    ApiResponse::new(StatusCode::OK, RetryQueueView { depth, tasks })
+}
+
+fn invite(state: &AdminState) -> Result<ApiResponse, ApiResponse> {
+    let application_id = state
+        .rotation
+        .as_ref()
+        .and_then(|rotation| rotation.application())
+        .ok_or_else(|| {
+            ApiResponse::error(
+                StatusCode::SERVICE_UNAVAILABLE,
+                "The bot hasn't connected to Discord yet",
+            )
+        })?;
+    let permissions = invite_permissions();
+    Ok(ApiResponse::new(
+        StatusCode::OK,
+        json!({
+            "url": invite_url(application_id, permissions),
+            "scopes": INVITE_SCOPES,
+            "permissions": permissions.bits().to_string(),
+            "permission_names": permissions.get_permission_names(),
+        }),
+    ))
+}
+
+async fn rotate_token(state: &AdminState, body: &[u8]) -> Result<ApiResponse, ApiResponse> {
+    let Some(rotation) = &state.rotation else {
+        return Err(ApiResponse::error(
+            StatusCode::NOT_FOUND,
+            "The token can't be rotated",
+        ));
+    };
+    let request: RotateToken = parse_body(body)?;
+    match rotation.rotate(&request.token).await {
+        Ok(rotated) => Ok(ApiResponse::new(
+            StatusCode::OK,
+            json!({ "rotated": rotated }),
+        )),
+        Err(EuleError::AuthenticationFailed(reason)) => Err(ApiResponse::error(
+            StatusCode::BAD_REQUEST,
+            format!("The token was rejected: {}", reason),
+        )),
+        Err(e) => Err(internal_error(e)),
+    }
+}
+
+async fn history(
+    state: &AdminState,
+    guild_id: GuildId,
+    channel_id: ChannelId,
+) -> Result<ApiResponse, ApiResponse> {
+    let task = state
+        .manager
+        .task(guild_id, channel_id)
+        .await
+        .ok_or_else(not_found)?;
+    let runs: Vec<RunView> = task.history.iter().rev().map(RunView::from).collect();
+    Ok(ApiResponse::new(StatusCode::OK, runs))
+}
@@ -0,0 +1,124 @@
+//! The HTTP/1 server in front of the admin API's routes and the dashboard.
+
+use crate::admin::{calendar, handle, AdminState};
+use http_body_util::{BodyExt, Full, Limited};
+use hyper::{
+    body::{Bytes, Incoming},
+    header::{HeaderName, AUTHORIZATION, CONTENT_TYPE, COOKIE, LOCATION, SET_COOKIE},
+    server::conn::http1,
+    service::service_fn,
+    Request, Response, StatusCode,
+};
+use hyper_util::rt::TokioIo;
+use std::{convert::Infallible, sync::Arc};
+use tokio::net::TcpListener;
+
+/// The largest request body accepted, far more than any valid request needs.
+const MAX_BODY_BYTES: usize = 64 * 1024;
+
+/// Serves the admin API on a listener until the task is aborted.
+///
+/// Each connection is handled on its own task, so a slow client cannot hold up
+/// others.
+///
+/// # Arguments
+/// * `listener` - The bound listener to accept connections on
+/// * `state` - The state shared by all requests
+pub async fn serve(listener: TcpListener, state: Arc<AdminState>) {
+    loop {
+        let (stream, peer) = match listener.accept().await {
+            Ok(accepted) => accepted,
+            Err(e) => {
+                tracing::warn!("Failed to accept admin API connection: {:?}", e);
+                continue;
+            }
+        };
+        let state = Arc::clone(&state);
+        tokio::spawn(async move {
+            let service = service_fn(move |request| {
+                let state = Arc::clone(&state);
+                async move { Ok::<_, Infallible>(respond(&state, request).await) }
+            });
+            if let Err(e) = http1::Builder::new()
+                .serve_connection(TokioIo::new(stream), service)
+                .await
+            {
+                tracing::debug!("Admin API connection from {} failed: {:?}", peer, e);
+            }
+        });
+    }
+}
+
+/// Reads a request and hands it to the API or the dashboard.
+///
+/// Paths under `/api/` belong to the API and paths under `/calendar/` to the
+/// calendar feeds; everything else goes to the dashboard if it is configured.
+async fn respond(state: &AdminState, request: Request<Incoming>) -> Response<Full<Bytes>> {
+    let (parts, body) = request.into_parts();
+    let Ok(collected) = Limited::new(body, MAX_BODY_BYTES).collect().await else {
+        return json_response(
+            StatusCode::PAYLOAD_TOO_LARGE,
+            r#"{"error":"Request body too large"}"#.to_string(),
+        );
+    };
+    let body = collected.to_bytes();
+    let header = |name: HeaderName| {
+        parts
+            .headers
+            .get(name)
+            .and_then(|value| value.to_str().ok())
+    };
+    let path = parts
+        .uri
+        .path_and_query()
+        .map(|path| path.as_str())
+        .unwrap_or("/");
+
+    if path.starts_with("/calendar/") {
+        let (status, body) = calendar::handle(
+            state.calendar_key.as_deref(),
+            |guild_id| state.manager.guild_tasks(guild_id),
+            path,
+        )
+        .await;
+        let mut response = Response::new(Full::new(Bytes::from(body)));
+        *response.status_mut() = status;
+        response.headers_mut().insert(
+            CONTENT_TYPE,
+            "text/calendar; charset=utf-8".parse().unwrap(),
+        );
+        return response;
+    }
+
+    match &state.dashboard {
+        Some(dashboard) if !path.starts_with("/api/") => {
+            let page = dashboard
+                .handle(state, &parts.method, path, header(COOKIE), &body)
+                .await;
+            let mut response = Response::new(Full::new(Bytes::from(page.body)));
+            *response.status_mut() = page.status;
+            let headers = response.headers_mut();
+            headers.insert(CONTENT_TYPE, "text/html; charset=utf-8".parse().unwrap());
+            if let Some(location) = page.location.and_then(|value| value.parse().ok()) {
+                headers.insert(LOCATION, location);
+            }
+            if let Some(cookie) = page.set_cookie.and_then(|value| value.parse().ok()) {
+                headers.insert(SET_COOKIE, cookie);
+            }
+            response
+        }
+        _ => {
+            let response = handle(state, &parts.method, path, header(AUTHORIZATION), &body).await;
+            json_response(response.status, response.body.to_string())
+        }
+    }
+}
+
+fn json_response(status: StatusCode, body: String) -> Response<Full<Bytes>> {
+    let mut response = Response::new(Full::new(Bytes::from(body)));
+    *response.status_mut() = status;
+    response
+        .headers_mut()
+        .insert(CONTENT_TYPE, "application/json".parse().unwrap());
+    response
+}
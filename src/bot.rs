@@ -1,17 +1,56 @@
 use crate::{
-    commands::{autoclean, clean, status},
+    admin::AdminListeners,
+    bot_lists,
+    commands::{
+        autoclean, automod, clean, config,
+        config::holds_admin_role,
+        debug, exclude_me, language, maintenance,
+        maintenance::outside_maintenance,
+        policy, purge, reload, restart, setup, shutdown, status,
+        sync::{sync_commands, SyncPlan},
+        usage,
+    },
+    config::{
+        BotConfig, PresenceConfig, MATRIX_TOKEN_ENV_VAR, SMTP_PASSWORD_ENV_VAR,
+        WEBHOOK_SECRET_ENV_VAR,
+    },
+    credentials::{TokenRotation, TOKEN_KEY},
     error::EuleError,
+    handlers::{handle_error, handle_event},
+    lifecycle,
+    notify::{
+        alerts::{self, AlertSink, MatrixSink, SlackSink},
+        bus,
+        email::{EmailReporter, SmtpMailer},
+        WebhookNotifier,
+    },
+    onboarding, phishing,
+    presence::{self, PresenceStats},
+    proxy,
+    purge::{ChannelSupport, DiscordApi, SharedHttp},
     store::KvStore,
-    tasks::AutocleanManager,
+    tasks::{creator_notice, summary, topic, AutocleanManager, BotApi},
+    utils::SerializableInstant,
     Data,
 };
-use poise::serenity_prelude::{ActivityData, ClientBuilder, GatewayIntents, Http};
+use poise::serenity_prelude::{
+    ActivityData, ChannelId, Client, ClientBuilder, Command, GuildId, Http,
+};
 use rpassword::read_password;
-use std::sync::{
-    atomic::{AtomicBool, AtomicUsize, Ordering},
-    Arc,
+use std::{
+    fs,
+    io::IsTerminal,
+    path::{Path, PathBuf},
+    sync::{
+        atomic::{AtomicBool, AtomicUsize, Ordering},
+        Arc, Mutex, OnceLock, PoisonError, RwLock,
+    },
+    time::SystemTime,
+};
+use tokio::{
+    task::JoinHandle,
+    time::{Duration, Instant},
 };
-use tokio::time::{Duration, Instant};
 
 /// A trait for reading a token from user input.
 pub trait TokenInput {
@@ -27,6 +66,83 @@ impl TokenInput for DiscordToken {
     }
 }
 
+/// Returns the activity shown when the bot first connects.
+///
+/// This is the first activity of the rotation, with all statistics at zero
+/// until the rotation task fills them in.
+pub(crate) fn activity(config: &PresenceConfig) -> ActivityData {
+    config
+        .activities
+        .first()
+        .map(|first| presence::activity_data(first, &PresenceStats::default()))
+        .unwrap_or_else(|| ActivityData::listening("Cigarette Wife"))
+}
+
+/// The environment variable consulted for a Discord token.
+pub const TOKEN_ENV_VAR: &str = "EULE_TOKEN";
+
+/// Resolves a Discord token supplied outside the key-value store.
+///
+/// Sources are checked in order of precedence: an explicit token, a token file
+/// (as mounted by Docker or Kubernetes secrets), then the `EULE_TOKEN` value.
+///
+/// # Arguments
+/// * `token` - A token passed directly on the command line
+/// * `token_file` - A path to a file containing the token
+/// * `env_token` - The value of the `EULE_TOKEN` environment variable, if set
+///
+/// # Returns
+/// `Ok(None)` if no source provided a token, or an error if a source was given
+/// but is unreadable or empty.
+pub fn resolve_token(
+    token: Option<String>,
+    token_file: Option<&Path>,
+    env_token: Option<String>,
+) -> Result<Option<String>, EuleError> {
+    let (token, source) = if let Some(token) = token {
+        (token, "--token")
+    } else if let Some(path) = token_file {
+        let contents = fs::read_to_string(path).map_err(|e| {
+            EuleError::AuthenticationFailed(format!(
+                "Failed to read token file {}: {}",
+                path.display(),
+                e
+            ))
+        })?;
+        (contents, "--token-file")
+    } else if let Some(token) = env_token {
+        (token, TOKEN_ENV_VAR)
+    } else {
+        return Ok(None);
+    };
+
+    let token = token.trim();
+    if token.is_empty() {
+        return Err(EuleError::AuthenticationFailed(format!(
+            "Empty Discord token provided via {}",
+            source
+        )));
+    }
+    Ok(Some(token.to_string()))
+}
+
+/// What the gateway sessions of one `Bot::run` share.
+#[derive(Clone)]
+struct Sessions {
+    /// The client purges use, pointed at each new session's client.
+    api: SharedHttp,
+    /// The token sessions connect with.
+    rotation: Arc<TokenRotation>,
+    /// The bot handed to commands, created by the first session.
+    bot: Arc<OnceLock<Arc<Bot>>>,
+    /// The admin listeners, until the first session starts serving on them.
+    admin_listeners: Arc<Mutex<Option<AdminListeners>>>,
+    /// The presence rotation of the current session.
+    presence: Arc<Mutex<Option<JoinHandle<()>>>>,
+    /// The bot list posts of the current session.
+    bot_lists: Arc<Mutex<Option<JoinHandle<()>>>>,
+}
+
 /// The main struct representing the Eule bot.
 ///
 /// This struct contains the core components of the bot, including the key-value store,
@@ -37,6 +153,23 @@ pub struct Bot {
     pub start_time: Instant,
     is_connected: AtomicBool,
     connection_attempts: AtomicUsize,
+    token: Option<String>,
+    /// The file `token` was read from, read again when the token is rotated.
+    token_file: Option<PathBuf>,
+    /// Replaces the token of the running bot.
+    rotation: Option<Arc<TokenRotation>>,
+    /// The configuration, replaced as a whole when it is reloaded.
+    config: RwLock<Arc<BotConfig>>,
+    /// The configuration file given on the command line, if any.
+    config_path: Option<PathBuf>,
+    dev_guild: Option<GuildId>,
+    /// The identity's name when several bots run in one process.
+    identity: Option<String>,
+    /// Whether this bot serves the admin API and sends notifications.
+    services: bool,
+    /// The client purges and deletes go through. It has no token until the
+    /// bot connects or cleans a channel once.
+    api: SharedHttp,
 }
 
 impl Bot {
@@ -52,6 +185,27 @@ impl Bot {
     /// A Result containing the new Bot instance if successful, or an error if initialization fails.
     pub async fn with_store(kv_store: Arc<KvStore>) -> Result<Self, EuleError> {
         let autoclean_manager = AutocleanManager::new(Arc::clone(&kv_store));
+        Self::with_manager(kv_store, autoclean_manager, None).await
+    }
+
+    /// Creates one of several bots sharing a store.
+    ///
+    /// The bot's tasks are kept under its own name, so identities never see or
+    /// clean each other's channels.
+    ///
+    /// # Arguments
+    /// * `kv_store` - The store shared by all identities
+    /// * `name` - The identity's name, unique among the identities
+    pub async fn for_identity(kv_store: Arc<KvStore>, name: &str) -> Result<Self, EuleError> {
+        let autoclean_manager = AutocleanManager::new(Arc::clone(&kv_store)).with_namespace(name);
+        Self::with_manager(kv_store, autoclean_manager, Some(name.to_string())).await
+    }
+
+    async fn with_manager(
+        kv_store: Arc<KvStore>,
+        autoclean_manager: AutocleanManager,
+        identity: Option<String>,
+    ) -> Result<Self, EuleError> {
         tracing::info!("AutocleanManager initialized with KvStore");
         autoclean_manager.load_tasks().await?;
         tracing::info!("Tasks loaded into AutocleanManager");
@@ -62,9 +216,39 @@ impl Bot {
             start_time: Instant::now(),
             is_connected: AtomicBool::new(false),
             connection_attempts: AtomicUsize::new(0),
+            token: None,
+            token_file: None,
+            rotation: None,
+            config: Default::default(),
+            config_path: None,
+            dev_guild: None,
+            identity,
+            services: true,
+            api: SharedHttp::new(Arc::new(Http::new(""))),
         })
     }
 
+    /// Sets a Discord token to use instead of the stored one.
+    ///
+    /// A token supplied this way is never written to the key-value store.
+    ///
+    /// # Arguments
+    /// * `token` - The Discord API token
+    pub fn with_token(mut self, token: String) -> Self {
+        self.token = Some(token);
+        self
+    }
+
+    /// Sets the file the token given with `with_token` was read from, so a
+    /// new token can be picked up from it with SIGHUP.
+    ///
+    /// # Arguments
+    /// * `path` - The token file
+    pub fn with_token_file(mut self, path: PathBuf) -> Self {
+        self.token_file = Some(path);
+        self
+    }
+
     /// Creates a new `Bot` instance with default configuration.
     ///
     /// This constructor creates a new KvStore in the default "eule_data" directory.
@@ -88,21 +272,33 @@ impl Bot {
     /// Returns a `Result` containing the valid Discord API token as a `String` if successful,
     /// or an `Err` containing an `EuleError` if token retrieval or storage fails.
     pub async fn get_or_set_token(kv_store: Arc<KvStore>) -> Result<String, EuleError> {
-        if let Some(token) = kv_store.get("discord_token").await? {
+        if let Some(token) = kv_store.get(TOKEN_KEY).await? {
             match Self::validate_token(&token).await {
                 Ok(_) => return Ok(token),
                 Err(_) => {
                     println!("Stored token is invalid. Removing it from storage.");
-                    kv_store.delete("discord_token").await?;
+                    kv_store.delete(TOKEN_KEY).await?;
                 }
             }
         }
 
+        if !std::io::stdin().is_terminal() {
+            return Err(EuleError::AuthenticationFailed(format!(
+                "No Discord token provided; pass --token or --token-file, or set {}",
+                TOKEN_ENV_VAR
+            )));
+        }
+
         println!("Please enter your Discord API token (input will be hidden):");
         let token = DiscordToken.read_token()?;
+        if token.trim().is_empty() {
+            return Err(EuleError::AuthenticationFailed(
+                "No Discord token provided".to_string(),
+            ));
+        }
         match Self::validate_token(&token).await {
             Ok(_) => {
-                kv_store.set("discord_token", &token).await?;
+                kv_store.set(TOKEN_KEY, &token).await?;
                 println!("Token validated and saved successfully.");
                 Ok(token)
             }
@@ -126,7 +322,7 @@ impl Bot {
     /// Returns `Ok(())` if the token is valid, or an `Err` containing an `EuleError::AuthenticationFailed`
     /// if the token is invalid or the authentication process fails.
     pub async fn validate_token(token: &str) -> Result<(), EuleError> {
-        let http = Http::new(token);
+        let http = proxy::discord_http(token, proxy::DEFAULT_REQUEST_TIMEOUT);
         http.get_current_application_info()
             .await
             .map_err(|e| EuleError::AuthenticationFailed(e.to_string()))?;
@@ -139,7 +335,7 @@ impl Bot {
     ///
     /// A Result containing Ok(()) if the token was deleted successfully.
     pub async fn delete_token(&self) -> Result<(), EuleError> {
-        self.kv_store.delete("discord_token").await?;
+        self.kv_store.delete(TOKEN_KEY).await?;
         Ok(())
     }
 
@@ -165,15 +361,18 @@ impl Bot {
     pub async fn connect(&self) -> Result<(), EuleError> {
         self.connection_attempts.fetch_add(1, Ordering::SeqCst);
 
-        // Retrieve the token from the key-value store
-        let token = self.kv_store.get("discord_token").await?.ok_or_else(|| {
-            EuleError::AuthenticationFailed(
-                "Discord token not found in key-value store".to_string(),
-            )
-        })?;
+        // Prefer an explicitly supplied token, falling back to the key-value store
+        let token = match &self.token {
+            Some(token) => token.clone(),
+            None => self.kv_store.get(TOKEN_KEY).await?.ok_or_else(|| {
+                EuleError::AuthenticationFailed(
+                    "Discord token not found in key-value store".to_string(),
+                )
+            })?,
+        };
 
         // Create an HTTP client with the token
-        let http = Http::new(&token);
+        let http = proxy::discord_http(&token, self.config().purge.request_timeout());
 
         // Verify the token by fetching the current application info
         http.get_current_application_info().await.map_err(|e| {
@@ -181,11 +380,12 @@ impl Bot {
         })?;
 
         // Set up the bot's activity
-        let activity = ActivityData::listening("Cigarette Wife");
+        let config = self.config();
+        let activity = activity(&config.presence);
 
         // Create a client builder with the verified token and intents
         let _client_builder =
-            ClientBuilder::new(token, GatewayIntents::non_privileged()).activity(activity);
+            ClientBuilder::new_with_http(http, config.intents()).activity(activity);
 
         // Not starting the client here, just verifying that it can be created
         // The actual client start will happen in the `run` method
@@ -196,61 +396,574 @@ impl Bot {
         Ok(())
     }
 
+    /// Returns the configuration the bot runs with.
+    ///
+    /// The configuration may be reloaded at any time, so callers should not
+    /// hold on to it for longer than they need it.
+    pub fn config(&self) -> Arc<BotConfig> {
+        match self.config.read() {
+            Ok(config) => Arc::clone(&config),
+            Err(poisoned) => Arc::clone(&poisoned.into_inner()),
+        }
+    }
+
+    /// Sets the configuration the bot runs with.
+    ///
+    /// # Arguments
+    /// * `config` - The loaded bot configuration
+    pub fn with_config(self, config: BotConfig) -> Self {
+        self.api
+            .set_old_message_delay(config.purge.old_message_delay());
+        *self.config.write().unwrap_or_else(PoisonError::into_inner) = Arc::new(config);
+        self
+    }
+
+    /// Sets the configuration file the configuration is reloaded from.
+    ///
+    /// # Arguments
+    /// * `path` - The file given with `--config`, or `None` for the default one
+    pub fn with_config_path(mut self, path: Option<PathBuf>) -> Self {
+        self.config_path = path;
+        self
+    }
+
+    /// Reads the configuration file again and applies it.
+    ///
+    /// Settings read as they are used, such as reply deletion and purge
+    /// pacing, take effect at once. Gateway intents, the presence rotation,
+    /// the admin API, notifications and bot identities keep their old
+    /// settings until the bot restarts.
+    ///
+    /// # Errors
+    ///
+    /// Returns an `EuleError` if the file can't be read or parsed, in which case
+    /// the previous configuration stays in place.
+    pub fn reload_config(&self) -> Result<(), EuleError> {
+        let config = BotConfig::load_or_default(self.config_path.as_deref())?;
+        config.apply();
+        self.api
+            .set_old_message_delay(config.purge.old_message_delay());
+        *self.config.write().unwrap_or_else(PoisonError::into_inner) = Arc::new(config);
+        tracing::info!("Configuration reloaded");
+        Ok(())
+    }
+
+    /// Registers commands in a single guild instead of globally.
+    ///
+    /// Guild commands propagate instantly, which makes iterating on command
+    /// definitions practical. They are removed again when the bot is stopped
+    /// with Ctrl-C.
+    ///
+    /// # Arguments
+    /// * `guild_id` - The development guild
+    pub fn with_dev_guild(mut self, guild_id: GuildId) -> Self {
+        self.dev_guild = Some(guild_id);
+        self
+    }
+
+    /// Leaves the admin API and notifications to another bot in the process.
+    pub fn without_services(mut self) -> Self {
+        self.services = false;
+        self
+    }
+
+    /// Makes the bot log and count its deletes without sending them, see
+    /// `SharedHttp`.
+    pub fn with_simulated_deletes(mut self, enabled: bool) -> Self {
+        self.api = self.api.with_simulated_deletes(enabled);
+        self
+    }
+
+    /// Returns the client purges and deletes go through.
+    pub fn api(&self) -> &SharedHttp {
+        &self.api
+    }
+
+    /// Returns the means to replace the token of the running bot.
+    pub fn token_rotation(&self) -> Option<&Arc<TokenRotation>> {
+        self.rotation.as_ref()
+    }
+
+    /// Returns the identity's name if several bots run in one process.
+    pub fn identity(&self) -> Option<&str> {
+        self.identity.as_deref()
+    }
+
+    /// Returns the development guild commands are registered in, if any.
+    pub fn dev_guild(&self) -> Option<GuildId> {
+        self.dev_guild
+    }
+
+    /// Returns the slash commands the bot provides.
+    pub fn commands() -> Vec<poise::Command<Data, EuleError>> {
+        vec![
+            autoclean(),
+            automod(),
+            clean(),
+            config(),
+            debug(),
+            exclude_me(),
+            language(),
+            maintenance(),
+            policy(),
+            purge(),
+            reload(),
+            restart(),
+            setup(),
+            shutdown(),
+            status(),
+        ]
+    }
+
+    /// Returns the bot's autoclean manager.
+    pub fn autoclean_manager(&self) -> &AutocleanManager {
+        &self.autoclean_manager
+    }
+
+    /// Creates an HTTP client for one-off operations outside the gateway session.
+    ///
+    /// The token is verified and the application ID is set so application command
+    /// endpoints can be used.
+    ///
+    /// # Returns
+    ///
+    /// Returns the ready-to-use client, or an `EuleError` if no valid token is available.
+    pub async fn http(&self) -> Result<Http, EuleError> {
+        let token = match &self.token {
+            Some(token) => token.clone(),
+            None => Self::get_or_set_token(Arc::clone(&self.kv_store)).await?,
+        };
+        let http = proxy::discord_http(&token, self.config().purge.request_timeout());
+        let info = http
+            .get_current_application_info()
+            .await
+            .map_err(|e| EuleError::AuthenticationFailed(e.to_string()))?;
+        http.set_application_id(info.id);
+        Ok(http)
+    }
+
+    /// Registers the bot's slash commands without starting the bot.
+    ///
+    /// The registered commands are reconciled with the current definitions, in the
+    /// development guild if one is set and globally otherwise.
+    ///
+    /// # Returns
+    ///
+    /// The applied sync plan, or an `EuleError` if registration fails.
+    pub async fn register_commands(&self) -> Result<SyncPlan, EuleError> {
+        let http = self.http().await?;
+        let commands = poise::builtins::create_application_commands(&Self::commands());
+        sync_commands(&http, self.dev_guild, commands).await
+    }
+
+    /// Removes all of the bot's slash commands.
+    ///
+    /// Only the development guild's commands are removed if one is set.
+    pub async fn deregister_commands(&self) -> Result<(), EuleError> {
+        let http = self.http().await?;
+        match self.dev_guild {
+            Some(guild_id) => {
+                guild_id.set_commands(&http, Vec::new()).await?;
+                tracing::info!("Removed all commands from guild {}", guild_id);
+            }
+            None => {
+                Command::set_global_commands(&http, Vec::new()).await?;
+                tracing::info!("Removed all global commands");
+            }
+        }
+        Ok(())
+    }
+
+    /// Cleans a single channel once and exits, without connecting to the gateway.
+    ///
+    /// # Arguments
+    /// * `channel_id` - The channel to clean
+    ///
+    /// # Errors
+    ///
+    /// Returns `EuleError::NotInGuild` if the channel does not belong to a guild, and
+    /// `EuleError::UnsupportedChannel` if it holds no messages that can be cleaned.
+    pub async fn purge_once(&self, channel_id: ChannelId) -> Result<(), EuleError> {
+        let http = self.http().await?;
+        let channel = http
+            .get_channel(channel_id)
+            .await?
+            .guild()
+            .ok_or(EuleError::NotInGuild)?;
+        if ChannelSupport::of(channel.kind) == ChannelSupport::Unsupported {
+            return Err(EuleError::UnsupportedChannel(format!("{:?}", channel.kind)));
+        }
+        let guild_id = channel.guild_id;
+        self.api.replace(Arc::new(http));
+        self.autoclean_manager
+            .purge_now(&self.api, guild_id, channel_id)
+            .await?;
+        Ok(())
+    }
+
     /// Run the bot with the Discord API token stored in the key-value store.
     ///
     /// This method sets up the framework, registers the commands, and starts the bot
-    /// with the specified token.
+    /// with the specified token. When the token is rotated, the gateway session is
+    /// closed and a new one is opened with the new token, while the scheduler and
+    /// purges in progress carry on.
     ///
     /// # Returns
     ///
     /// Returns `Ok(())` if the bot runs successfully, or an `Err` if an error occurs.
     pub async fn run(&self) -> Result<(), EuleError> {
-        let token = Self::get_or_set_token(Arc::clone(&self.kv_store)).await?;
+        let token = match &self.token {
+            Some(token) => token.clone(),
+            None => Self::get_or_set_token(Arc::clone(&self.kv_store)).await?,
+        };
+        lifecycle::load_maintenance(&self.kv_store).await?;
+
+        let mut rotation = TokenRotation::new(token.clone());
+        if self.token.is_none() {
+            rotation = rotation.with_store(Arc::clone(&self.kv_store));
+        }
+        if let Some(path) = &self.token_file {
+            rotation = rotation.with_file(path.clone());
+        }
+
+        // Bind the admin listeners up front so a bad address fails startup
+        let admin_listeners = if self.services {
+            let listeners = AdminListeners::bind(&self.config(), &self.kv_store).await?;
+            self.start_webhooks()?;
+            self.start_bus()?;
+            self.start_alerts()?;
+            self.start_email_reports()?;
+            self.start_phishing_feed();
+            listeners
+        } else {
+            None
+        };
 
+        proxy::check_gateway().await?;
+        self.api.replace(Arc::new(proxy::discord_http(
+            &token,
+            self.config().purge.request_timeout(),
+        )));
+        let sessions = Sessions {
+            api: self.api.clone(),
+            rotation: Arc::new(rotation),
+            bot: Default::default(),
+            admin_listeners: Arc::new(Mutex::new(admin_listeners)),
+            presence: Default::default(),
+            bot_lists: Default::default(),
+        };
+        loop {
+            // Subscribing first means no rotation can slip in unnoticed
+            let mut rotations = sessions.rotation.subscribe();
+            let token = sessions.rotation.token();
+            let mut client = self.client(&token, sessions.clone()).await?;
+
+            if let Some(guild_id) = self.dev_guild {
+                let http = Arc::clone(&client.http);
+                let shard_manager = Arc::clone(&client.shard_manager);
+                tokio::spawn(async move {
+                    if tokio::signal::ctrl_c().await.is_err() {
+                        return;
+                    }
+                    tracing::info!("Removing development commands from guild {}", guild_id);
+                    if let Err(e) = guild_id.set_commands(&http, Vec::new()).await {
+                        tracing::error!("Failed to remove development commands: {:?}", e);
+                    }
+                    shard_manager.shutdown_all().await;
+                });
+            }
+            let shard_manager = Arc::clone(&client.shard_manager);
+            let reconnect = tokio::spawn(async move {
+                if rotations.changed().await.is_ok() {
+                    tracing::info!("Closing the gateway session to reconnect with the new token");
+                    shard_manager.shutdown_all().await;
+                }
+            });
+
+            let result = client.start().await;
+            reconnect.abort();
+            result.map_err(EuleError::DiscordApi)?;
+            if sessions.rotation.token() == token {
+                return Ok(());
+            }
+        }
+    }
+
+    /// Creates the client for one gateway session.
+    ///
+    /// The first session starts the scheduler and everything else that lives
+    /// as long as the bot. Later sessions, opened after the token was rotated,
+    /// only point purges at their new client and catch up on cleanups that
+    /// fell due while reconnecting.
+    async fn client(&self, token: &str, sessions: Sessions) -> Result<Client, EuleError> {
         let options = poise::FrameworkOptions {
-            commands: vec![autoclean(), clean(), status()],
+            commands: Self::commands(),
+            event_handler: |ctx, event, framework, data| {
+                Box::pin(handle_event(ctx, event, framework, data))
+            },
+            on_error: |error| Box::pin(handle_error(error)),
+            pre_command: |ctx| Box::pin(usage::start(ctx)),
+            post_command: |ctx| Box::pin(usage::finish(ctx, false)),
+            command_check: Some(|ctx| {
+                Box::pin(async move {
+                    Ok(outside_maintenance(ctx).await? && holds_admin_role(ctx).await?)
+                })
+            }),
             ..Default::default()
         };
 
         let mut autoclean_manager = self.autoclean_manager.clone();
         let kv_store = Arc::clone(&self.kv_store);
+        let config = self.config();
+        let config_path = self.config_path.clone();
+        let dev_guild = self.dev_guild;
+        let identity = self.identity.clone();
+        let services = self.services;
 
         let framework = poise::Framework::builder()
             .options(options)
-            .setup(move |ctx, _ready, framework| {
+            .setup(move |ctx, ready, framework| {
                 Box::pin(async move {
-                    poise::builtins::register_globally(ctx, &framework.options().commands).await?;
-                    autoclean_manager.start(ctx.http.clone()).await;
-                    tracing::info!("AutocleanManager started");
-                    let bot = Arc::new(Bot {
-                        kv_store: Arc::clone(&kv_store),
-                        autoclean_manager: autoclean_manager.clone(),
-                        start_time: Instant::now(),
-                        is_connected: AtomicBool::new(true),
-                        connection_attempts: AtomicUsize::new(1),
-                    });
+                    let commands =
+                        poise::builtins::create_application_commands(&framework.options().commands);
+                    sync_commands(&ctx.http, dev_guild, commands).await?;
+                    sessions.rotation.connected_as(ready.application.id);
+                    sessions.api.replace(ctx.http.clone());
+                    let api: Arc<dyn DiscordApi> = Arc::new(sessions.api.clone());
+                    let bot_api: Arc<dyn BotApi> = Arc::new(sessions.api.clone());
+
+                    let bot = match sessions.bot.get() {
+                        Some(bot) => {
+                            bot.autoclean_manager.wake();
+                            Arc::clone(bot)
+                        }
+                        None => {
+                            autoclean_manager.start(Arc::clone(&bot_api)).await;
+                            tracing::info!("AutocleanManager started");
+                            tracing::info!(
+                                "Invite the bot with {}",
+                                onboarding::invite_url(
+                                    ready.application.id,
+                                    onboarding::invite_permissions()
+                                )
+                            );
+                            tokio::spawn(topic::run(
+                                autoclean_manager.clone(),
+                                Arc::clone(&api),
+                                autoclean_manager.subscribe_events(),
+                                autoclean_manager.subscribe_changes(),
+                            ));
+                            tokio::spawn(summary::run(
+                                autoclean_manager.clone(),
+                                Arc::clone(&api),
+                                autoclean_manager.subscribe_events(),
+                            ));
+                            tokio::spawn(creator_notice::run(
+                                autoclean_manager.clone(),
+                                Arc::clone(&bot_api),
+                                autoclean_manager.subscribe_events(),
+                            ));
+                            if config.phishing.feed_url.is_some() {
+                                tokio::spawn(phishing::run(
+                                    autoclean_manager.clone(),
+                                    Arc::clone(&api),
+                                    autoclean_manager.subscribe_events(),
+                                ));
+                            }
+                            let listeners = sessions
+                                .admin_listeners
+                                .lock()
+                                .unwrap_or_else(PoisonError::into_inner)
+                                .take();
+                            if let Some(listeners) = listeners {
+                                listeners.spawn(
+                                    autoclean_manager.clone(),
+                                    Arc::clone(&bot_api),
+                                    Some(Arc::clone(&sessions.rotation)),
+                                );
+                            }
+                            let bot = Arc::new(Bot {
+                                kv_store: Arc::clone(&kv_store),
+                                autoclean_manager: autoclean_manager.clone(),
+                                start_time: Instant::now(),
+                                is_connected: AtomicBool::new(true),
+                                connection_attempts: AtomicUsize::new(1),
+                                token: None,
+                                token_file: None,
+                                rotation: Some(Arc::clone(&sessions.rotation)),
+                                config: RwLock::new(Arc::clone(&config)),
+                                config_path,
+                                dev_guild,
+                                identity,
+                                services,
+                                api: sessions.api.clone(),
+                            });
+                            #[cfg(unix)]
+                            tokio::spawn(lifecycle::reload_on_hangup(Arc::clone(&bot)));
+                            let _ = sessions.bot.set(Arc::clone(&bot));
+                            bot
+                        }
+                    };
+                    // The presence is set through the session's shards
+                    let presence = tokio::spawn(presence::rotate(
+                        ctx.clone(),
+                        config.presence.clone(),
+                        bot.autoclean_manager.clone(),
+                    ));
+                    let previous = sessions
+                        .presence
+                        .lock()
+                        .unwrap_or_else(PoisonError::into_inner)
+                        .replace(presence);
+                    if let Some(previous) = previous {
+                        previous.abort();
+                    }
+                    // Guild counts are read from the session's cache as well
+                    let bot_lists = tokio::spawn(bot_lists::run(
+                        ctx.clone(),
+                        config.bot_lists.clone(),
+                        bot.identity().map(str::to_string),
+                    ));
+                    let previous = sessions
+                        .bot_lists
+                        .lock()
+                        .unwrap_or_else(PoisonError::into_inner)
+                        .replace(bot_lists);
+                    if let Some(previous) = previous {
+                        previous.abort();
+                    }
 
                     // Create and return the Data instance
-                    Ok(Data::new(autoclean_manager, Arc::clone(&kv_store), bot))
+                    Ok(Data::new(
+                        bot.autoclean_manager.clone(),
+                        Arc::clone(&kv_store),
+                        bot,
+                    ))
                 })
             })
             .build();
 
-        let intents = GatewayIntents::non_privileged();
+        let intents = self.config().intents();
+        tracing::info!("Requesting gateway intents: {:?}", intents);
 
-        let activity = ActivityData::listening("Cigarette Wife");
+        let activity = activity(&self.config().presence);
 
-        ClientBuilder::new(token, intents)
+        let http = proxy::discord_http(token, self.config().purge.request_timeout());
+        ClientBuilder::new_with_http(http, intents)
             .framework(framework)
             .activity(activity)
             .await
-            .map_err(EuleError::from)?
-            .start()
-            .await
-            .map_err(EuleError::DiscordApi)?;
+            .map_err(EuleError::from)
+    }
+
+    /// Starts delivering purge events to the configured webhooks, if any.
+    fn start_webhooks(&self) -> Result<(), EuleError> {
+        let config = self.config();
+        let webhooks = &config.webhooks;
+        if webhooks.urls.is_empty() {
+            return Ok(());
+        }
+        let secret = webhooks
+            .resolve_secret(std::env::var(WEBHOOK_SECRET_ENV_VAR).ok())
+            .ok_or_else(|| {
+                EuleError::InvalidConfig(format!(
+                    "webhooks need a signing secret; set webhooks.secret or {}",
+                    WEBHOOK_SECRET_ENV_VAR
+                ))
+            })?;
+        let notifier = WebhookNotifier::new(webhooks.urls.clone(), secret);
+        tokio::spawn(notifier.run(self.autoclean_manager.subscribe_events()));
+        tracing::info!("Sending purge events to {} webhooks", webhooks.urls.len());
         Ok(())
     }
 
+    /// Starts publishing events to the configured message bus, if any.
+    fn start_bus(&self) -> Result<(), EuleError> {
+        let config = self.config();
+        let Some(url) = &config.bus.url else {
+            return Ok(());
+        };
+        let publisher = bus::publisher(url, &config.bus.subject)?;
+        tokio::spawn(bus::run(
+            publisher,
+            self.autoclean_manager.subscribe_events(),
+            self.autoclean_manager.subscribe_changes(),
+        ));
+        tracing::info!("Publishing events to the message bus");
+        Ok(())
+    }
+
+    /// Starts sending operator alerts to the configured Slack webhook and
+    /// Matrix room, if any, and the watchdog for overdue cleanups, which logs
+    /// them even without either.
+    fn start_alerts(&self) -> Result<(), EuleError> {
+        let config = self.config();
+        let config = &config.alerts;
+        let mut sinks: Vec<Arc<dyn AlertSink>> = Vec::new();
+        if let Some(webhook_url) = &config.slack_webhook {
+            sinks.push(Arc::new(SlackSink::new(webhook_url.clone())));
+        }
+        if let Some(matrix) = &config.matrix {
+            let token = matrix
+                .resolve_token(std::env::var(MATRIX_TOKEN_ENV_VAR).ok())
+                .ok_or_else(|| {
+                    EuleError::InvalidConfig(format!(
+                        "Matrix alerts need an access token; set alerts.matrix.access_token or {}",
+                        MATRIX_TOKEN_ENV_VAR
+                    ))
+                })?;
+            sinks.push(Arc::new(MatrixSink::new(
+                &matrix.homeserver,
+                matrix.room_id.clone(),
+                token,
+            )));
+        }
+        tokio::spawn(alerts::watch_overdue(
+            self.autoclean_manager.clone(),
+            sinks.clone(),
+        ));
+        if sinks.is_empty() {
+            return Ok(());
+        }
+        tokio::spawn(alerts::run(
+            sinks,
+            self.autoclean_manager.subscribe_events(),
+            config.update_check,
+        ));
+        Ok(())
+    }
+
+    /// Starts tallying purge activity for the configured email reports, if any.
+    fn start_email_reports(&self) -> Result<(), EuleError> {
+        let config = self.config();
+        let email = &config.email;
+        let Some(smtp) = email.smtp.as_ref().filter(|_| !email.reports.is_empty()) else {
+            return Ok(());
+        };
+        let password = smtp.resolve_password(std::env::var(SMTP_PASSWORD_ENV_VAR).ok());
+        let mailer = SmtpMailer::new(smtp, password)?;
+        let reporter =
+            EmailReporter::new(Arc::clone(&self.kv_store), Arc::new(mailer), &email.reports);
+        tokio::spawn(reporter.run(self.autoclean_manager.subscribe_events()));
+        tracing::info!("Emailing reports for {} guilds", email.reports.len());
+        Ok(())
+    }
+
+    /// Starts fetching the configured phishing domain feed, if any.
+    fn start_phishing_feed(&self) {
+        let config = self.config();
+        let Some(url) = &config.phishing.feed_url else {
+            return;
+        };
+        tokio::spawn(phishing::refresh(url.clone(), config.phishing.refresh()));
+        tracing::info!("Deleting links to the phishing domains at {}", url);
+    }
+
+    /// Returns the time the bot was started.
+    pub fn started_at(&self) -> SerializableInstant {
+        SerializableInstant::from_system_time(SystemTime::now() - self.uptime())
+    }
+
     /// Returns the uptime of the bot.
     ///
     /// # Returns
@@ -0,0 +1,108 @@
+//! Posting the guild count to bot listing sites.
+//!
+//! Sites such as top.gg show how many servers a public bot is in, and expect
+//! the bot to post the number itself. Each site configured under
+//! `[bot_lists]` gets the count on a schedule, authenticated with its API key.
+//! A site that can't be reached is tried again at the next post.
+
+use crate::{
+    config::{BotListSite, BotListsConfig},
+    error::EuleError,
+};
+use poise::serenity_prelude::{self as serenity, UserId};
+use serde_json::{Map, Value};
+use tokio::time::{self, Duration, Instant};
+
+/// How often the count is posted unless configured otherwise.
+pub const DEFAULT_INTERVAL: Duration = Duration::from_secs(30 * 60);
+/// The shortest allowed interval, to stay clear of the sites' rate limits.
+pub const MIN_INTERVAL: Duration = Duration::from_secs(5 * 60);
+/// How long to wait for a site to answer.
+const REQUEST_TIMEOUT: Duration = Duration::from_secs(10);
+
+/// Returns the stats endpoint of a site for a bot.
+///
+/// # Arguments
+/// * `site` - The site to post to
+/// * `bot_id` - The bot's user ID, for `{bot_id}`
+pub fn stats_url(site: &BotListSite, bot_id: UserId) -> String {
+    site.url.replace("{bot_id}", &bot_id.to_string())
+}
+
+/// Builds the body posted to a site.
+///
+/// # Arguments
+/// * `site` - The site to post to, which names the fields
+/// * `guilds` - The number of guilds the bot is in
+/// * `shards` - The number of shards the bot runs
+pub fn stats_body(site: &BotListSite, guilds: usize, shards: u32) -> Value {
+    let mut body = Map::new();
+    body.insert(site.guild_field.clone(), guilds.into());
+    if let Some(field) = &site.shard_field {
+        body.insert(field.clone(), shards.into());
+    }
+    Value::Object(body)
+}
+
+/// Posts the guild count to a site.
+///
+/// # Errors
+///
+/// Returns `EuleError::BotList` if the site can't be reached or rejects the
+/// post.
+async fn post(
+    client: &reqwest::Client,
+    site: &BotListSite,
+    bot_id: UserId,
+    guilds: usize,
+    shards: u32,
+) -> Result<(), EuleError> {
+    let mut request = client
+        .post(stats_url(site, bot_id))
+        .timeout(REQUEST_TIMEOUT)
+        .json(&stats_body(site, guilds, shards));
+    if let Some(token) = site.resolve_token(std::env::var(site.token_env_var()).ok()) {
+        request = request.header("Authorization", token);
+    }
+    request
+        .send()
+        .await
+        .and_then(reqwest::Response::error_for_status)
+        .map(|_| ())
+        .map_err(|e| EuleError::BotList(e.to_string()))
+}
+
+/// Posts the guild count to the configured sites until the session ends.
+///
+/// # Arguments
+/// * `ctx` - The Serenity context, for the bot's ID and the guild cache
+/// * `config` - The sites to post to
+/// * `identity` - The name of the posting bot when several run, which only
+///   posts to the sites meant for it
+pub async fn run(ctx: serenity::Context, config: BotListsConfig, identity: Option<String>) {
+    let sites: Vec<BotListSite> = config
+        .sites
+        .iter()
+        .filter(|site| site.identity.is_none() || site.identity == identity)
+        .cloned()
+        .collect();
+    if sites.is_empty() {
+        return;
+    }
+    let client = crate::proxy::client();
+    let period = config.interval().max(MIN_INTERVAL);
+    // Guilds arrive after the session starts, so the first post waits a period
+    let mut interval = time::interval_at(Instant::now() + period, period);
+    loop {
+        interval.tick().await;
+        let bot_id = ctx.cache.current_user().id;
+        let guilds = ctx.cache.guild_count();
+        let shards = ctx.cache.shard_count();
+        for site in &sites {
+            match post(&client, site, bot_id, guilds, shards).await {
+                Ok(()) => tracing::debug!("Posted {} guilds to {}", guilds, site.name),
+                Err(e) => tracing::warn!("Failed to post the guild count to {}: {}", site.name, e),
+            }
+        }
+    }
+}
@@ -2,12 +2,139 @@
 //!
 //! This module contains commands for adding, removing, and listing autoclean tasks.
 //! All commands in this module require the `MANAGE_MESSAGES` permission.
+//!
+//! Commands that persist tasks defer their response first, since saving and
+//! resolving channels can exceed Discord's three second response window.
 
-use crate::{Context, EuleError};
+use crate::{
+    commands::{
+        config::{is_administrator, may_change_server_settings, may_manage_task},
+        paginate::{paginate_fields, send_paginated, task_field, FIELDS_PER_PAGE},
+        purge::describe_estimate,
+        reply,
+    },
+    i18n::{self, Language},
+    purge::{
+        estimate_purge, ChannelSupport, DeletionOrder, ForumAction, ForumOptions, PurgeOptions,
+        StarboardOptions, ThreadOptions, DEFAULT_STAR, ESTIMATE_PAGES,
+    },
+    tasks::{
+        guild_settings::TemplateKind, spam_burst::BurstRule, CleanupTask, PurgeWarning, TaskMove,
+        MAX_LABELS,
+    },
+    utils::{discord_time, humanize, serializable_instant::parse_local, SerializableInstant},
+    Context, EuleError,
+};
 use miette::Result;
-use poise::serenity_prelude::ChannelId;
+use poise::{
+    serenity_prelude::{
+        ButtonStyle, ChannelId, ChannelType, ComponentInteractionCollector, CreateActionRow,
+        CreateButton, CreateEmbed, CreateInteractionResponse, CreateInteractionResponseMessage,
+        GatewayIntents, GuildChannel, GuildId, RoleId,
+    },
+    ChoiceParameter, CreateReply,
+};
+use std::collections::BTreeSet;
 use tokio::time::Duration;
 
+/// How long the replace and keep buttons wait for a press.
+const CONFIRM_TIMEOUT: Duration = Duration::from_secs(60);
+
+/// The seconds a burst's messages are posted within, unless given.
+const DEFAULT_BURST_SECONDS: u64 = 5;
+
+/// Returns the warning shown before an existing task is replaced.
+///
+/// # Arguments
+///
+/// * `language` - The language to respond in.
+/// * `channel_id` - The channel that already has a task.
+pub fn overwrite_warning(language: Language, channel_id: ChannelId) -> String {
+    i18n::text(
+        language,
+        "autoclean.overwrite_warning",
+        &[("channel", &format!("<#{}>", channel_id))],
+    )
+}
+
+/// Asks the invoking user whether to replace a channel's existing task.
+///
+/// # Returns
+///
+/// `true` if the task should be replaced. The question is answered with "no"
+/// if nobody presses a button in time.
+async fn confirm_overwrite(
+    ctx: Context<'_>,
+    channel_id: ChannelId,
+    task: &CleanupTask,
+) -> Result<bool, EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    let state = ctx
+        .data()
+        .autoclean_manager
+        .task_state(guild_id, channel_id, task)
+        .await;
+    let language = i18n::language(ctx).await;
+    let mention = format!("<#{}>", channel_id);
+    let prefix = ctx.id().to_string();
+    let replace_id = format!("{}_replace", prefix);
+    let keep_id = format!("{}_keep", prefix);
+    reply::send(
+        ctx,
+        CreateReply::default()
+            .content(overwrite_warning(language, channel_id))
+            .embed({
+                let (name, value) = task_field(language, channel_id, task, state);
+                CreateEmbed::new()
+                    .title(i18n::text(language, "autoclean.current_task", &[]))
+                    .field(name, value, false)
+            })
+            .components(vec![CreateActionRow::Buttons(vec![
+                CreateButton::new(replace_id.clone())
+                    .label(i18n::text(language, "autoclean.replace", &[]))
+                    .style(ButtonStyle::Danger),
+                CreateButton::new(keep_id)
+                    .label(i18n::text(language, "autoclean.keep", &[]))
+                    .style(ButtonStyle::Secondary),
+            ])]),
+    )
+    .await?;
+
+    let Some(press) = ComponentInteractionCollector::new(ctx.serenity_context())
+        .author_id(ctx.author().id)
+        .filter(move |press| press.data.custom_id.starts_with(&prefix))
+        .timeout(CONFIRM_TIMEOUT)
+        .await
+    else {
+        reply::say(
+            ctx,
+            i18n::text(language, "autoclean.no_answer", &[("channel", &mention)]),
+        )
+        .await?;
+        return Ok(false);
+    };
+
+    let replace = press.data.custom_id == replace_id;
+    let key = if replace {
+        "autoclean.replacing"
+    } else {
+        "autoclean.kept"
+    };
+    let content = i18n::text(language, key, &[("channel", &mention)]);
+    press
+        .create_response(
+            ctx,
+            CreateInteractionResponse::UpdateMessage(
+                CreateInteractionResponseMessage::new()
+                    .content(content)
+                    .embeds(Vec::new())
+                    .components(Vec::new()),
+            ),
+        )
+        .await?;
+    Ok(replace)
+}
+
 /// Parent command for autoclean functionality.
 ///
 /// This command serves as a container for subcommands related to autoclean tasks.
@@ -18,7 +145,39 @@ use tokio::time::Duration;
 #[poise::command(
     slash_command,
     prefix_command,
-    subcommands("add", "remove", "list", "workers"),
+    subcommands(
+        "add",
+        "forum",
+        "threads",
+        "topic",
+        "opt_out",
+        "starboard",
+        "keep_first",
+        "keywords",
+        "labels",
+        "nuke",
+        "slowmode",
+        "messages",
+        "old_delay",
+        "order",
+        "cap",
+        "lock",
+        "dry_run",
+        "resume",
+        "summary",
+        "notify",
+        "warning",
+        "template",
+        "log",
+        "budget",
+        "burst",
+        "move_task",
+        "remove",
+        "remove_all",
+        "list",
+        "calendar",
+        "workers"
+    ),
     required_permissions = "MANAGE_MESSAGES"
 )]
 pub async fn autoclean(_: Context<'_>) -> Result<(), EuleError> {
@@ -33,18 +192,51 @@ pub async fn autoclean(_: Context<'_>) -> Result<(), EuleError> {
 /// * `channel` - The channel to autoclean.
 /// * `interval` - The interval value for cleaning.
 /// * `unit` - The time unit for the interval (minutes, hours, days).
+/// * `include_threads` - Whether to also clean the channel's threads.
+/// * `show_in_topic` - Whether to show the next cleanup time in the channel topic.
+/// * `overwrite` - Whether to replace an existing task without asking.
+/// * `expires` - When the task removes itself, as a date like `2026-06-01` or
+///   date and time like `2026-06-01 03:00` in the server's time zone.
+///
+/// If the channel already has a task, its settings are shown and it is only
+/// replaced once the user confirms, unless `overwrite` is set. If the first
+/// cleanup looks like it would take hours, the reply says so.
+///
+/// The channel may itself be a thread, in which case only that thread is cleaned,
+/// or a voice or stage channel, in which case its text chat is cleaned.
 ///
 /// # Returns
 ///
 /// A Result containing Ok(()) if the task was added successfully, or an EuleError if there was an issue.
-#[poise::command(slash_command, prefix_command)]
+#[poise::command(slash_command, prefix_command, user_cooldown = 10)]
 pub async fn add(
     ctx: Context<'_>,
-    #[description = "Channel to autoclean"] channel: ChannelId,
+    #[description = "Channel, thread, or voice channel chat to autoclean"]
+    #[channel_types(
+        "Text",
+        "News",
+        "Voice",
+        "Stage",
+        "PublicThread",
+        "PrivateThread",
+        "NewsThread"
+    )]
+    channel: GuildChannel,
     #[description = "Interval value"] interval: u64,
     #[description = "Time unit (minutes, hours, days)"] unit: String,
+    #[description = "Also clean active and archived threads in the channel"]
+    include_threads: Option<bool>,
+    #[description = "Show the next cleanup time in the channel topic"] show_in_topic: Option<bool>,
+    #[description = "Replace an existing task without asking"] overwrite: Option<bool>,
+    #[description = "Remove the task after this date, like 2026-06-01 or 2026-06-01 03:00"]
+    expires: Option<String>,
 ) -> Result<(), EuleError> {
     let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    if !may_manage_task(ctx, guild_id, channel.id).await? {
+        return Ok(());
+    }
 
     let duration = match unit.to_lowercase().as_str() {
         "minutes" | "minute" | "m" => Duration::from_secs(interval * 60),
@@ -53,99 +245,1885 @@ pub async fn add(
         _ => return Err(EuleError::InvalidTimeUnit),
     };
 
-    ctx.data()
-        .autoclean_manager
-        .add_task(guild_id, channel, duration)
+    let language = i18n::language(ctx).await;
+    let settings = ctx.data().autoclean_manager.guild_settings(guild_id).await;
+    let expires = match expires {
+        Some(text) => match parse_local(&text, settings.utc_offset) {
+            Some(at) if at > SerializableInstant::now() => Some(at),
+            Some(_) => {
+                let message = i18n::text(language, "common.date_past", &[("date", &text)]);
+                return reply::say(ctx, message).await;
+            }
+            None => {
+                let message = i18n::text(language, "common.invalid_date", &[("date", &text)]);
+                return reply::say(ctx, message).await;
+            }
+        },
+        None => None,
+    };
+    let mention = format!("<#{}>", channel.id);
+    let has_threads = match ChannelSupport::of(channel.kind) {
+        ChannelSupport::Messages { threads } => threads,
+        ChannelSupport::Forum => {
+            let message = i18n::text(
+                language,
+                "autoclean.forum_channel",
+                &[("channel", &mention)],
+            );
+            return reply::say(ctx, message).await;
+        }
+        ChannelSupport::Unsupported => {
+            let message = i18n::text(language, "autoclean.no_messages", &[("channel", &mention)]);
+            return reply::say(ctx, message).await;
+        }
+    };
+
+    let manager = &ctx.data().autoclean_manager;
+    if let Some(existing) = manager.task(guild_id, channel.id).await {
+        if !overwrite.unwrap_or(false) && !confirm_overwrite(ctx, channel.id, &existing).await? {
+            return Ok(());
+        }
+    }
+    manager.add_task(guild_id, channel.id, duration).await?;
+    manager
+        .record_creator(guild_id, channel.id, ctx.author().id)
+        .await?;
+    if include_threads.unwrap_or(false) && has_threads {
+        manager
+            .set_include_threads(guild_id, channel.id, true)
+            .await?;
+    }
+    if show_in_topic.unwrap_or(false) {
+        manager
+            .set_show_in_topic(guild_id, channel.id, true)
+            .await?;
+    }
+    if expires.is_some() {
+        manager.set_expires(guild_id, channel.id, expires).await?;
+    }
+
+    let mut message = i18n::text(
+        language,
+        "autoclean.added",
+        &[
+            ("channel", &mention),
+            ("interval", &humanize::duration(duration)),
+            (
+                "first_run",
+                &discord_time::relative(SerializableInstant::now() + duration),
+            ),
+        ],
+    );
+    if let Some(expires) = expires {
+        message.push('\n');
+        message.push_str(&i18n::text(
+            language,
+            "autoclean.expires",
+            &[("expires", &discord_time::full(expires))],
+        ));
+    }
+    // Only a warning, so a failed estimate doesn't fail the command
+    let options = PurgeOptions::default();
+    if let Ok(estimate) = estimate_purge(ctx.http(), channel.id, &options, ESTIMATE_PAGES).await {
+        if estimate.is_long(&options) {
+            message.push('\n');
+            message.push_str(&describe_estimate(
+                language, channel.id, &estimate, &options,
+            ));
+        }
+    }
+    reply::say(ctx, message).await
+}
+
+/// What to do with old forum posts.
+#[derive(Debug, poise::ChoiceParameter)]
+pub enum ForumActionChoice {
+    #[name = "archive"]
+    Archive,
+    #[name = "delete"]
+    Delete,
+}
+
+impl From<ForumActionChoice> for ForumAction {
+    fn from(choice: ForumActionChoice) -> Self {
+        match choice {
+            ForumActionChoice::Archive => ForumAction::Archive,
+            ForumActionChoice::Delete => ForumAction::Delete,
+        }
+    }
+}
+
+/// Schedules cleanup of old posts in a forum channel.
+///
+/// Forum channels have no messages of their own, so instead of purging messages
+/// the task archives or deletes posts once they are old enough. The check runs
+/// once a day.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `channel` - The forum channel to clean.
+/// * `older_than_days` - Minimum age of a post, in days, before it is cleaned up.
+/// * `action` - Whether old posts are archived or deleted.
+/// * `tag` - Only clean up posts with this tag.
+/// * `without_tag` - Leave posts with this tag alone.
+/// * `inactive_days` - Only clean up posts without messages for this many days.
+/// * `overwrite` - Whether to replace an existing task without asking.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) if the task was added successfully, or an EuleError if there was an issue.
+#[poise::command(slash_command, prefix_command, user_cooldown = 10)]
+pub async fn forum(
+    ctx: Context<'_>,
+    #[description = "Forum channel to clean"]
+    #[channel_types("Forum")]
+    channel: GuildChannel,
+    #[description = "Minimum post age in days"] older_than_days: u64,
+    #[description = "Archive or delete old posts"] action: ForumActionChoice,
+    #[description = "Only posts with this tag"] tag: Option<String>,
+    #[description = "Leave posts with this tag alone"] without_tag: Option<String>,
+    #[description = "Only posts without messages for this many days"] inactive_days: Option<u64>,
+    #[description = "Replace an existing task without asking"] overwrite: Option<bool>,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    if !may_manage_task(ctx, guild_id, channel.id).await? {
+        return Ok(());
+    }
+
+    let language = i18n::language(ctx).await;
+    let mention = format!("<#{}>", channel.id);
+    if channel.kind != ChannelType::Forum {
+        let message = i18n::text(language, "autoclean.not_forum", &[("channel", &mention)]);
+        return reply::say(ctx, message).await;
+    }
+
+    let mut options = ForumOptions::new(Duration::from_secs(older_than_days * 86400));
+    options.action = action.into();
+    options.inactive_for = inactive_days.map(|days| Duration::from_secs(days * 86400));
+    for (name, tags) in [
+        (&tag, &mut options.tags),
+        (&without_tag, &mut options.skip_tags),
+    ] {
+        let Some(name) = name else {
+            continue;
+        };
+        match channel
+            .available_tags
+            .iter()
+            .find(|available| available.name.eq_ignore_ascii_case(name))
+        {
+            Some(available) => tags.push(available.id),
+            None => {
+                let message = i18n::text(
+                    language,
+                    "autoclean.no_tag",
+                    &[("channel", &mention), ("tag", name)],
+                );
+                return reply::say(ctx, message).await;
+            }
+        }
+    }
+
+    let key = match options.action {
+        ForumAction::Archive => "autoclean.forum_archive",
+        ForumAction::Delete => "autoclean.forum_delete",
+    };
+
+    let manager = &ctx.data().autoclean_manager;
+    if let Some(existing) = manager.task(guild_id, channel.id).await {
+        if !overwrite.unwrap_or(false) && !confirm_overwrite(ctx, channel.id, &existing).await? {
+            return Ok(());
+        }
+    }
+    manager
+        .add_task(guild_id, channel.id, Duration::from_secs(86400))
+        .await?;
+    manager
+        .set_forum_options(guild_id, channel.id, Some(options))
+        .await?;
+    manager
+        .record_creator(guild_id, channel.id, ctx.author().id)
         .await?;
 
-    ctx.say(format!(
-        "Added autoclean task for channel <#{0}> every {1} {2}! ⏰",
-        channel, interval, unit
-    ))
+    reply::say(
+        ctx,
+        i18n::text(
+            language,
+            key,
+            &[
+                ("channel", &mention),
+                ("days", &older_than_days.to_string()),
+            ],
+        ),
+    )
     .await?;
 
     Ok(())
 }
 
-/// Removes an autoclean task for a specified channel.
+/// Schedules cleanup of a channel's stale threads.
+///
+/// Instead of purging messages, the task archives threads that have had no
+/// messages for a while and, optionally, deletes archived threads that have
+/// been quiet for longer still. The check runs once a day.
 ///
 /// # Arguments
 ///
 /// * `ctx` - The command context.
-/// * `channel` - The channel to remove the autoclean task from.
+/// * `channel` - The channel whose threads to clean up.
+/// * `inactive_days` - Days without messages before a thread is archived.
+/// * `delete_after_days` - Days without messages before an archived thread is deleted.
+/// * `overwrite` - Whether to replace an existing task without asking.
 ///
 /// # Returns
 ///
-/// A Result containing Ok(()) if the task was removed successfully or if no task was found,
+/// A Result containing Ok(()) if the task was added successfully, or an EuleError if there was an issue.
+#[poise::command(slash_command, prefix_command, user_cooldown = 10)]
+pub async fn threads(
+    ctx: Context<'_>,
+    #[description = "Channel whose threads to clean up"]
+    #[channel_types("Text", "News")]
+    channel: GuildChannel,
+    #[description = "Archive threads without messages for this many days"]
+    #[min = 1]
+    inactive_days: u64,
+    #[description = "Delete archived threads without messages for this many days"]
+    #[min = 1]
+    delete_after_days: Option<u64>,
+    #[description = "Replace an existing task without asking"] overwrite: Option<bool>,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    if !may_manage_task(ctx, guild_id, channel.id).await? {
+        return Ok(());
+    }
+
+    let language = i18n::language(ctx).await;
+    let mention = format!("<#{}>", channel.id);
+    if !matches!(
+        ChannelSupport::of(channel.kind),
+        ChannelSupport::Messages { threads: true }
+    ) {
+        let message = i18n::text(language, "autoclean.no_threads", &[("channel", &mention)]);
+        return reply::say(ctx, message).await;
+    }
+
+    let options = ThreadOptions {
+        archive_after: Duration::from_secs(inactive_days * 86400),
+        delete_after: delete_after_days.map(|days| Duration::from_secs(days * 86400)),
+    };
+
+    let manager = &ctx.data().autoclean_manager;
+    if let Some(existing) = manager.task(guild_id, channel.id).await {
+        if !overwrite.unwrap_or(false) && !confirm_overwrite(ctx, channel.id, &existing).await? {
+            return Ok(());
+        }
+    }
+    manager
+        .add_task(guild_id, channel.id, Duration::from_secs(86400))
+        .await?;
+    manager
+        .set_thread_options(guild_id, channel.id, Some(options))
+        .await?;
+    manager
+        .record_creator(guild_id, channel.id, ctx.author().id)
+        .await?;
+
+    let inactive_days = inactive_days.to_string();
+    let message = match delete_after_days {
+        Some(days) => i18n::text(
+            language,
+            "autoclean.threads_delete",
+            &[
+                ("channel", &mention),
+                ("days", &inactive_days),
+                ("delete_days", &days.to_string()),
+            ],
+        ),
+        None => i18n::text(
+            language,
+            "autoclean.threads_archive",
+            &[("channel", &mention), ("days", &inactive_days)],
+        ),
+    };
+    reply::say(ctx, message).await?;
+
+    Ok(())
+}
+
+/// Shows or hides the next cleanup time in a channel's topic.
+///
+/// While enabled, the topic ends with "🧹 next purge: <date>", which is updated
+/// after every cleanup and whenever the schedule changes.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `channel` - The channel whose task to change.
+/// * `enabled` - Whether the topic should show the next cleanup.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) if the setting was changed or no task was found,
 /// or an EuleError if there was an issue.
 #[poise::command(slash_command, prefix_command)]
-pub async fn remove(
+pub async fn topic(
     ctx: Context<'_>,
-    #[description = "Channel to remove autoclean task from"] channel: ChannelId,
+    #[description = "Channel with an autoclean task"] channel: ChannelId,
+    #[description = "Show the next cleanup time in the channel topic"] enabled: bool,
 ) -> Result<(), EuleError> {
     let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    if !may_manage_task(ctx, guild_id, channel).await? {
+        return Ok(());
+    }
 
-    if ctx
+    let updated = ctx
         .data()
         .autoclean_manager
-        .remove_task(guild_id, channel)
-        .await?
-    {
-        ctx.say(format!(
-            "Removed autoclean task for channel <#{0}>! ✅",
-            channel
-        ))
+        .set_show_in_topic(guild_id, channel, enabled)
         .await?;
-    } else {
-        ctx.say(format!(
-            "No autoclean task found for channel <#{0}>! ❌",
-            channel
-        ))
+    record_editor(ctx, guild_id, channel).await?;
+    let key = match (updated, enabled) {
+        (false, _) => "common.no_task",
+        (true, true) => "autoclean.topic_on",
+        (true, false) => "autoclean.topic_off",
+    };
+    let message = i18n::tr(ctx, key, &[("channel", &format!("<#{}>", channel))]).await;
+    reply::say(ctx, message).await?;
+
+    Ok(())
+}
+
+/// Allows or forbids members to keep their own messages out of a channel's cleanups.
+///
+/// While allowed, members opt out with `/exclude_me`. Forbidding it again
+/// suspends existing opt-outs until it is allowed once more.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `channel` - The channel whose task to change.
+/// * `enabled` - Whether members may opt out.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) if the setting was changed or no task was found,
+/// or an EuleError if there was an issue.
+#[poise::command(slash_command, prefix_command)]
+pub async fn opt_out(
+    ctx: Context<'_>,
+    #[description = "Channel with an autoclean task"] channel: ChannelId,
+    #[description = "Let members keep their messages with /exclude_me"] enabled: bool,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    if !may_manage_task(ctx, guild_id, channel).await? {
+        return Ok(());
+    }
+
+    let updated = ctx
+        .data()
+        .autoclean_manager
+        .set_allow_opt_out(guild_id, channel, enabled)
         .await?;
+    record_editor(ctx, guild_id, channel).await?;
+    let key = match (updated, enabled) {
+        (false, _) => "common.no_task",
+        (true, true) => "autoclean.opt_out_on",
+        (true, false) => "autoclean.opt_out_off",
+    };
+    let message = i18n::tr(ctx, key, &[("channel", &format!("<#{}>", channel))]).await;
+    reply::say(ctx, message).await?;
+
+    Ok(())
+}
+
+/// Protects starboarded messages in a channel from its cleanups.
+///
+/// Messages with at least `threshold` reactions of `emoji` are kept, as are
+/// messages linked from the `starboard` channel. Giving neither turns the
+/// protection off.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `channel` - The channel whose task to change.
+/// * `threshold` - How many star reactions keep a message.
+/// * `emoji` - The emoji that stars a message. Defaults to ⭐.
+/// * `starboard` - The channel highlights are reposted to.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) if the setting was changed or no task was found,
+/// or an EuleError if there was an issue.
+#[poise::command(slash_command, prefix_command)]
+pub async fn starboard(
+    ctx: Context<'_>,
+    #[description = "Channel with an autoclean task"] channel: ChannelId,
+    #[description = "Keep messages with at least this many stars"]
+    #[min = 1]
+    threshold: Option<u64>,
+    #[description = "Emoji that stars a message (default ⭐)"] emoji: Option<String>,
+    #[description = "Keep messages linked from this starboard channel"] starboard: Option<
+        ChannelId,
+    >,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    if !may_manage_task(ctx, guild_id, channel).await? {
+        return Ok(());
     }
 
+    let options = (threshold.is_some() || starboard.is_some()).then(|| StarboardOptions {
+        emoji: emoji.unwrap_or_else(|| DEFAULT_STAR.to_string()),
+        threshold,
+        channel: starboard,
+    });
+    let enabled = options.is_some();
+    let updated = ctx
+        .data()
+        .autoclean_manager
+        .set_starboard(guild_id, channel, options)
+        .await?;
+    record_editor(ctx, guild_id, channel).await?;
+    let key = match (updated, enabled) {
+        (false, _) => "common.no_task",
+        (true, true) => "autoclean.starboard_on",
+        (true, false) => "autoclean.starboard_off",
+    };
+    let message = i18n::tr(ctx, key, &[("channel", &format!("<#{}>", channel))]).await;
+    reply::say(ctx, message).await?;
+
     Ok(())
 }
 
-/// Lists all autoclean tasks in the current server.
+/// Keeps the oldest message in a channel, such as a rules or intro post, out of
+/// its cleanups.
+///
+/// Unlike pinning, this needs no pin slot and keeps the message even if it is
+/// unpinned later.
 ///
 /// # Arguments
 ///
 /// * `ctx` - The command context.
+/// * `channel` - The channel whose task to change.
+/// * `enabled` - Whether the oldest message should be kept.
 ///
 /// # Returns
 ///
-/// A Result containing Ok(()) if the tasks were listed successfully,
+/// A Result containing Ok(()) if the setting was changed or no task was found,
 /// or an EuleError if there was an issue.
 #[poise::command(slash_command, prefix_command)]
-pub async fn list(ctx: Context<'_>) -> Result<(), EuleError> {
+pub async fn keep_first(
+    ctx: Context<'_>,
+    #[description = "Channel with an autoclean task"] channel: ChannelId,
+    #[description = "Keep the channel's oldest message"] enabled: bool,
+) -> Result<(), EuleError> {
     let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
 
-    let tasks = ctx.data().autoclean_manager.list_tasks(guild_id).await;
+    if !may_manage_task(ctx, guild_id, channel).await? {
+        return Ok(());
+    }
 
-    if tasks.is_empty() {
-        ctx.say("No cleaning tasks scheduled for this server.")
-            .await?;
-    } else {
-        let task_list = tasks
-            .iter()
-            .map(|(channel_id, interval)| {
-                format!(
-                    "Channel: <#{0}>, Interval: {1} minutes",
-                    channel_id,
-                    interval.as_secs() / 60
-                )
-            })
-            .collect::<Vec<String>>()
-            .join("\n");
+    let updated = ctx
+        .data()
+        .autoclean_manager
+        .set_keep_first_message(guild_id, channel, enabled)
+        .await?;
+    record_editor(ctx, guild_id, channel).await?;
+    let key = match (updated, enabled) {
+        (false, _) => "common.no_task",
+        (true, true) => "autoclean.keep_first_on",
+        (true, false) => "autoclean.keep_first_off",
+    };
+    let message = i18n::tr(ctx, key, &[("channel", &format!("<#{}>", channel))]).await;
+    reply::say(ctx, message).await?;
+
+    Ok(())
+}
+
+/// Keeps messages containing any of the given keywords, such as `#keep` or
+/// `!archive`, out of a channel's cleanups.
+///
+/// Keywords are separated by commas and match anywhere in a message, ignoring
+/// case. Leaving out `keywords` removes them all. The bot only sees message
+/// text if it has the message content intent.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `channel` - The channel whose task to change.
+/// * `keywords` - The keywords, replacing any set before.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) if the keywords were changed or no task was
+/// found, or an EuleError if there was an issue.
+#[poise::command(slash_command, prefix_command)]
+pub async fn keywords(
+    ctx: Context<'_>,
+    #[description = "Channel with an autoclean task"] channel: ChannelId,
+    #[description = "Comma-separated keywords, such as #keep; leave out to remove all"]
+    #[max_length = 500]
+    keywords: Option<String>,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    if !may_manage_task(ctx, guild_id, channel).await? {
+        return Ok(());
+    }
+
+    let keywords = parse_keywords(keywords.as_deref().unwrap_or_default());
+    let listed = keywords
+        .iter()
+        .map(|keyword| format!("`{}`", keyword))
+        .collect::<Vec<_>>()
+        .join(", ");
+    let updated = ctx
+        .data()
+        .autoclean_manager
+        .set_keep_keywords(guild_id, channel, keywords)
+        .await?;
+    record_editor(ctx, guild_id, channel).await?;
+    let key = match (updated, listed.is_empty()) {
+        (false, _) => "common.no_task",
+        (true, false) => "autoclean.keywords_set",
+        (true, true) => "autoclean.keywords_cleared",
+    };
+    let message = i18n::tr(
+        ctx,
+        key,
+        &[
+            ("channel", &format!("<#{}>", channel)),
+            ("keywords", &listed),
+        ],
+    )
+    .await;
+    reply::say(ctx, message).await?;
+
+    Ok(())
+}
+
+/// Splits a comma-separated list of keywords, dropping blanks and duplicates.
+///
+/// # Arguments
+///
+/// * `list` - The keywords as entered, such as `#keep, !archive`.
+pub fn parse_keywords(list: &str) -> Vec<String> {
+    let mut keywords: Vec<String> = Vec::new();
+    for keyword in list.split(',').map(str::trim).filter(|k| !k.is_empty()) {
+        if !keywords.iter().any(|k| k.eq_ignore_ascii_case(keyword)) {
+            keywords.push(keyword.to_string());
+        }
+    }
+    keywords
+}
+
+/// Tags a channel's task with labels, such as `event`, `spam` or
+/// `compliance`, to organize servers with many tasks.
+///
+/// `/autoclean list`, `/purge top`, `/policy report` and `/policy apply_label`
+/// can be limited to the tasks with a label. Labels are separated by commas
+/// and ignore case. Leaving out `labels` removes them all.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `channel` - The channel whose task to label.
+/// * `labels` - The labels, replacing any set before.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) if the labels were changed or no task was
+/// found, or an EuleError if there was an issue.
+#[poise::command(slash_command, prefix_command)]
+pub async fn labels(
+    ctx: Context<'_>,
+    #[description = "Channel with an autoclean task"] channel: ChannelId,
+    #[description = "Comma-separated labels, such as event, spam; leave out to remove all"]
+    #[max_length = 200]
+    labels: Option<String>,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    if !may_manage_task(ctx, guild_id, channel).await? {
+        return Ok(());
+    }
 
-        ctx.say(format!(
-            "Scheduled cleaning tasks for this server:\n{}",
-            task_list
-        ))
+    let labels = parse_labels(labels.as_deref().unwrap_or_default());
+    let listed = format_labels(&labels);
+    let updated = ctx
+        .data()
+        .autoclean_manager
+        .set_labels(guild_id, channel, labels)
         .await?;
+    record_editor(ctx, guild_id, channel).await?;
+    let key = match (updated, listed.is_empty()) {
+        (false, _) => "common.no_task",
+        (true, false) => "autoclean.labels_set",
+        (true, true) => "autoclean.labels_cleared",
+    };
+    let message = i18n::tr(
+        ctx,
+        key,
+        &[("channel", &format!("<#{}>", channel)), ("labels", &listed)],
+    )
+    .await;
+    reply::say(ctx, message).await
+}
+
+/// Splits a comma-separated list of labels into lowercase labels, dropping
+/// blanks and duplicates and keeping at most `MAX_LABELS` of them.
+///
+/// # Arguments
+///
+/// * `list` - The labels as entered, such as `Event, spam`.
+pub fn parse_labels(list: &str) -> BTreeSet<String> {
+    let mut labels = BTreeSet::new();
+    for label in list.split(',').map(str::trim).filter(|l| !l.is_empty()) {
+        if labels.len() < MAX_LABELS {
+            labels.insert(label.to_lowercase());
+        }
     }
+    labels
+}
+
+/// Records the member who used a command as the last to change a channel's
+/// task, which `/autoclean list` shows.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `guild_id` - The guild the channel belongs to.
+/// * `channel` - The channel whose task was changed.
+async fn record_editor(
+    ctx: Context<'_>,
+    guild_id: GuildId,
+    channel: ChannelId,
+) -> Result<(), EuleError> {
+    ctx.data()
+        .autoclean_manager
+        .record_editor(guild_id, channel, ctx.author().id)
+        .await?;
+    Ok(())
+}
+
+/// Lists labels as inline code, separated by commas.
+pub fn format_labels(labels: &BTreeSet<String>) -> String {
+    labels
+        .iter()
+        .map(|label| format!("`{}`", label))
+        .collect::<Vec<_>>()
+        .join(", ")
+}
 
+/// Replaces a channel with an empty copy at each cleanup instead of deleting
+/// its messages one by one.
+///
+/// The copy keeps the channel's name, topic, permissions, category, and
+/// position, but gets a new ID. Links to the old channel break, and its pins,
+/// webhooks, and threads are gone with it.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `channel` - The channel whose task to change.
+/// * `enabled` - Whether the channel should be replaced instead of purged.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) if the setting was changed or no task was found,
+/// or an EuleError if there was an issue.
+#[poise::command(slash_command, prefix_command)]
+pub async fn nuke(
+    ctx: Context<'_>,
+    #[description = "Channel with an autoclean task"] channel: ChannelId,
+    #[description = "Replace the channel with an empty copy at each cleanup"] enabled: bool,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    if !may_manage_task(ctx, guild_id, channel).await? {
+        return Ok(());
+    }
+
+    let manager = &ctx.data().autoclean_manager;
+    // Replacing the channel would throw away the messages these settings keep
+    let conflicts = manager
+        .task(guild_id, channel)
+        .await
+        .map(|mut task| {
+            task.nuke = enabled;
+            task.nuke_conflicts()
+        })
+        .unwrap_or_default();
+    if !conflicts.is_empty() {
+        let message = i18n::tr(
+            ctx,
+            "autoclean.nuke_conflicts",
+            &[
+                ("channel", &format!("<#{}>", channel)),
+                ("settings", &conflicts.join(", ")),
+            ],
+        )
+        .await;
+        return reply::say(ctx, message).await;
+    }
+
+    let updated = manager.set_nuke(guild_id, channel, enabled).await?;
+    record_editor(ctx, guild_id, channel).await?;
+    let key = match (updated, enabled) {
+        (false, _) => "common.no_task",
+        (true, true) => "autoclean.nuke_on",
+        (true, false) => "autoclean.nuke_off",
+    };
+    let message = i18n::tr(ctx, key, &[("channel", &format!("<#{}>", channel))]).await;
+    reply::say(ctx, message).await?;
+
+    Ok(())
+}
+
+/// Holds a channel at a slowmode while its cleanups run, so new messages don't
+/// pour in while the history is being deleted.
+///
+/// A channel whose slowmode is already at least as long is left alone, and the
+/// previous slowmode is restored once the cleanup is over. Leaving out
+/// `seconds` turns this off.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `channel` - The channel whose task to change.
+/// * `seconds` - The slowmode to hold the channel at during cleanups.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) if the setting was changed or no task was found,
+/// or an EuleError if there was an issue.
+#[poise::command(slash_command, prefix_command)]
+pub async fn slowmode(
+    ctx: Context<'_>,
+    #[description = "Channel with an autoclean task"] channel: ChannelId,
+    #[description = "Slowmode in seconds during cleanups; leave out to turn off"]
+    #[min = 1]
+    #[max = 21600]
+    seconds: Option<u16>,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    if !may_manage_task(ctx, guild_id, channel).await? {
+        return Ok(());
+    }
+
+    let updated = ctx
+        .data()
+        .autoclean_manager
+        .set_slowmode(guild_id, channel, seconds)
+        .await?;
+    record_editor(ctx, guild_id, channel).await?;
+    let key = match (updated, seconds) {
+        (false, _) => "common.no_task",
+        (true, Some(_)) => "autoclean.slowmode_on",
+        (true, None) => "autoclean.slowmode_off",
+    };
+    let message = i18n::tr(
+        ctx,
+        key,
+        &[
+            ("channel", &format!("<#{}>", channel)),
+            ("seconds", &seconds.unwrap_or_default().to_string()),
+        ],
+    )
+    .await;
+    reply::say(ctx, message).await?;
+
+    Ok(())
+}
+
+/// Also cleans a channel whenever a number of new messages arrived since its
+/// last cleanup, for channels that fill up faster some days than others.
+///
+/// The task keeps its regular schedule as well. Leaving out `count` turns this
+/// off.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `channel` - The channel whose task to change.
+/// * `count` - The number of new messages that triggers a cleanup.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) if the setting was changed or no task was found,
+/// or an EuleError if there was an issue.
+#[poise::command(slash_command, prefix_command)]
+pub async fn messages(
+    ctx: Context<'_>,
+    #[description = "Channel with an autoclean task"] channel: ChannelId,
+    #[description = "New messages that trigger a cleanup; leave out to turn off"]
+    #[min = 1]
+    #[max = 100000]
+    count: Option<u32>,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    if !may_manage_task(ctx, guild_id, channel).await? {
+        return Ok(());
+    }
+
+    let updated = ctx
+        .data()
+        .autoclean_manager
+        .set_message_trigger(guild_id, channel, count)
+        .await?;
+    record_editor(ctx, guild_id, channel).await?;
+    let counted = ctx
+        .data()
+        .bot
+        .config()
+        .intents()
+        .contains(GatewayIntents::GUILD_MESSAGES);
+    let key = match (updated, count, counted) {
+        (false, _, _) => "common.no_task",
+        (true, Some(_), true) => "autoclean.messages_on",
+        (true, Some(_), false) => "autoclean.messages_unavailable",
+        (true, None, _) => "autoclean.messages_off",
+    };
+    let message = i18n::tr(
+        ctx,
+        key,
+        &[
+            ("channel", &format!("<#{}>", channel)),
+            ("count", &count.unwrap_or_default().to_string()),
+        ],
+    )
+    .await;
+    reply::say(ctx, message).await?;
+
+    Ok(())
+}
+
+/// Spaces out the deletes of messages older than 14 days in a channel's
+/// cleanups, which Discord only deletes one at a time.
+///
+/// Longer pauses make cleanups slower but leave more of the bot's rate limit
+/// for other channels. Leaving out `milliseconds` goes back to the bot's
+/// default.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `channel` - The channel whose task to change.
+/// * `milliseconds` - The pause after each delete of an old message.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) if the setting was changed or no task was found,
+/// or an EuleError if there was an issue.
+#[poise::command(slash_command, prefix_command)]
+pub async fn old_delay(
+    ctx: Context<'_>,
+    #[description = "Channel with an autoclean task"] channel: ChannelId,
+    #[description = "Pause after deleting each message older than 14 days; leave out for the default"]
+    #[max = 60000]
+    milliseconds: Option<u32>,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    if !may_manage_task(ctx, guild_id, channel).await? {
+        return Ok(());
+    }
+
+    let delay = milliseconds.map(|ms| Duration::from_millis(u64::from(ms)));
+    let updated = ctx
+        .data()
+        .autoclean_manager
+        .set_old_message_delay(guild_id, channel, delay)
+        .await?;
+    record_editor(ctx, guild_id, channel).await?;
+    let key = match (updated, milliseconds) {
+        (false, _) => "common.no_task",
+        (true, Some(_)) => "autoclean.old_delay_set",
+        (true, None) => "autoclean.old_delay_default",
+    };
+    let message = i18n::tr(
+        ctx,
+        key,
+        &[
+            ("channel", &format!("<#{}>", channel)),
+            (
+                "milliseconds",
+                &milliseconds.unwrap_or_default().to_string(),
+            ),
+        ],
+    )
+    .await;
+    reply::say(ctx, message).await?;
+
+    Ok(())
+}
+
+/// The order a channel's cleanups delete messages in.
+#[derive(Debug, poise::ChoiceParameter)]
+pub enum DeletionOrderChoice {
+    #[name = "newest first"]
+    NewestFirst,
+    #[name = "oldest first"]
+    OldestFirst,
+}
+
+impl From<DeletionOrderChoice> for DeletionOrder {
+    fn from(choice: DeletionOrderChoice) -> Self {
+        match choice {
+            DeletionOrderChoice::NewestFirst => DeletionOrder::NewestFirst,
+            DeletionOrderChoice::OldestFirst => DeletionOrder::OldestFirst,
+        }
+    }
+}
+
+/// Chooses whether a channel's cleanups delete its newest or its oldest
+/// messages first.
+///
+/// Oldest first suits channels with a deletion budget: a cleanup that runs out
+/// of budget stops at the same place every time, so what is left is always the
+/// newest messages. Newest first, the default, clears what people see first.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `channel` - The channel whose task to change.
+/// * `order` - The order to delete messages in.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) if the setting was changed or no task was found,
+/// or an EuleError if there was an issue.
+#[poise::command(slash_command, prefix_command)]
+pub async fn order(
+    ctx: Context<'_>,
+    #[description = "Channel with an autoclean task"] channel: ChannelId,
+    #[description = "Which messages to delete first"] order: DeletionOrderChoice,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    if !may_manage_task(ctx, guild_id, channel).await? {
+        return Ok(());
+    }
+
+    let order = DeletionOrder::from(order);
+    let updated = ctx
+        .data()
+        .autoclean_manager
+        .set_deletion_order(guild_id, channel, order)
+        .await?;
+    record_editor(ctx, guild_id, channel).await?;
+    let key = match (updated, order) {
+        (false, _) => "common.no_task",
+        (true, DeletionOrder::NewestFirst) => "autoclean.order_newest",
+        (true, DeletionOrder::OldestFirst) => "autoclean.order_oldest",
+    };
+    let message = i18n::tr(ctx, key, &[("channel", &format!("<#{}>", channel))]).await;
+    reply::say(ctx, message).await?;
+
+    Ok(())
+}
+
+/// Caps how many messages a single cleanup of a channel deletes.
+///
+/// A channel with a huge backlog is then worked off over several runs instead
+/// of taking up the bot for a whole day; each run picks up where the last one
+/// stopped. Leaving out `messages` removes the cap.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `channel` - The channel whose task to change.
+/// * `messages` - The most messages a single cleanup deletes.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) if the setting was changed or no task was found,
+/// or an EuleError if there was an issue.
+#[poise::command(slash_command, prefix_command)]
+pub async fn cap(
+    ctx: Context<'_>,
+    #[description = "Channel with an autoclean task"] channel: ChannelId,
+    #[description = "Most messages deleted per cleanup; leave out for no cap"]
+    #[min = 1]
+    messages: Option<u32>,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    if !may_manage_task(ctx, guild_id, channel).await? {
+        return Ok(());
+    }
+
+    let updated = ctx
+        .data()
+        .autoclean_manager
+        .set_max_deletions(guild_id, channel, messages)
+        .await?;
+    record_editor(ctx, guild_id, channel).await?;
+    let key = match (updated, messages) {
+        (false, _) => "common.no_task",
+        (true, Some(_)) => "autoclean.cap_set",
+        (true, None) => "autoclean.cap_off",
+    };
+    let message = i18n::tr(
+        ctx,
+        key,
+        &[
+            ("channel", &format!("<#{}>", channel)),
+            (
+                "count",
+                &humanize::count(u64::from(messages.unwrap_or_default())),
+            ),
+        ],
+    )
+    .await;
+    reply::say(ctx, message).await?;
+
+    Ok(())
+}
+
+/// Denies @everyone Send Messages in a channel while its cleanups run, so the
+/// channel is empty when they finish.
+///
+/// The channel's previous permission for @everyone is restored once the cleanup
+/// is over. The bot needs Manage Roles in the channel for this.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `channel` - The channel whose task to change.
+/// * `enabled` - Whether the channel should be locked during cleanups.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) if the setting was changed or no task was found,
+/// or an EuleError if there was an issue.
+#[poise::command(slash_command, prefix_command)]
+pub async fn lock(
+    ctx: Context<'_>,
+    #[description = "Channel with an autoclean task"] channel: ChannelId,
+    #[description = "Stop everyone from posting while the channel is cleaned"] enabled: bool,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    if !may_manage_task(ctx, guild_id, channel).await? {
+        return Ok(());
+    }
+
+    let updated = ctx
+        .data()
+        .autoclean_manager
+        .set_lock_channel(guild_id, channel, enabled)
+        .await?;
+    record_editor(ctx, guild_id, channel).await?;
+    let key = match (updated, enabled) {
+        (false, _) => "common.no_task",
+        (true, true) => "autoclean.lock_on",
+        (true, false) => "autoclean.lock_off",
+    };
+    let message = i18n::tr(ctx, key, &[("channel", &format!("<#{}>", channel))]).await;
+    reply::say(ctx, message).await?;
+
+    Ok(())
+}
+
+/// Makes a channel's next scheduled cleanup a dry run, which deletes nothing
+/// and reports what it would have deleted, or turns a pending dry run off.
+///
+/// New tasks start with a dry run. Turning one back on is useful after
+/// changing which messages a task keeps.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `channel` - The channel whose task to change.
+/// * `enabled` - Whether the next cleanup should be a dry run.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) if the setting was changed or no task was found,
+/// or an EuleError if there was an issue.
+#[poise::command(slash_command, prefix_command)]
+pub async fn dry_run(
+    ctx: Context<'_>,
+    #[description = "Channel with an autoclean task"] channel: ChannelId,
+    #[description = "Only report what the next cleanup would delete"] enabled: bool,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    if !may_manage_task(ctx, guild_id, channel).await? {
+        return Ok(());
+    }
+
+    let updated = ctx
+        .data()
+        .autoclean_manager
+        .set_dry_run(guild_id, channel, enabled)
+        .await?;
+    record_editor(ctx, guild_id, channel).await?;
+    let key = match (updated, enabled) {
+        (false, _) => "common.no_task",
+        (true, true) => "autoclean.dry_run_on",
+        (true, false) => "autoclean.dry_run_off",
+    };
+    let message = i18n::tr(ctx, key, &[("channel", &format!("<#{}>", channel))]).await;
+    reply::say(ctx, message).await?;
+
+    Ok(())
+}
+
+/// Resumes a task that was paused because its cleanups kept failing, such as
+/// after the bot lost its permissions in the channel.
+///
+/// Fix what made the cleanups fail first, or the task is paused again after a
+/// few more failures.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `channel` - The channel whose task to resume.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) if the task was resumed or wasn't paused, or an
+/// EuleError if there was an issue.
+#[poise::command(slash_command, prefix_command)]
+pub async fn resume(
+    ctx: Context<'_>,
+    #[description = "Channel with a paused autoclean task"] channel: ChannelId,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    if !may_manage_task(ctx, guild_id, channel).await? {
+        return Ok(());
+    }
+
+    let manager = &ctx.data().autoclean_manager;
+    let key = match manager.resume_task(guild_id, channel).await? {
+        true => "autoclean.resumed",
+        false if manager.task(guild_id, channel).await.is_some() => "autoclean.not_paused",
+        false => "common.no_task",
+    };
+    let message = i18n::tr(ctx, key, &[("channel", &format!("<#{}>", channel))]).await;
+    reply::say(ctx, message).await
+}
+
+/// Posts a short summary after each of a channel's cleanups, saying how many
+/// messages were deleted and when the next cleanup runs.
+///
+/// The summary goes to the cleaned channel unless `log_channel` is given, and
+/// uses the server's summary template.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `channel` - The channel whose task to change.
+/// * `enabled` - Whether summaries should be posted.
+/// * `log_channel` - The channel to post summaries in instead.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) if the setting was changed or no task was found,
+/// or an EuleError if there was an issue.
+#[poise::command(slash_command, prefix_command)]
+pub async fn summary(
+    ctx: Context<'_>,
+    #[description = "Channel with an autoclean task"] channel: ChannelId,
+    #[description = "Post a summary after each cleanup"] enabled: bool,
+    #[description = "Channel to post summaries in (default: the cleaned channel)"]
+    #[channel_types("Text", "News")]
+    log_channel: Option<ChannelId>,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    if !may_manage_task(ctx, guild_id, channel).await? {
+        return Ok(());
+    }
+
+    let target = enabled.then(|| log_channel.unwrap_or(channel));
+    let updated = ctx
+        .data()
+        .autoclean_manager
+        .set_summary(guild_id, channel, target)
+        .await?;
+    record_editor(ctx, guild_id, channel).await?;
+    let key = match (updated, enabled) {
+        (false, _) => "common.no_task",
+        (true, true) => "autoclean.summary_on",
+        (true, false) => "autoclean.summary_off",
+    };
+    let message = i18n::tr(
+        ctx,
+        key,
+        &[
+            ("channel", &format!("<#{}>", channel)),
+            ("target", &format!("<#{}>", target.unwrap_or(channel))),
+        ],
+    )
+    .await;
+    reply::say(ctx, message).await?;
+
+    Ok(())
+}
+
+/// Sends whoever created a channel's task a direct message after each of its
+/// cleanups, with how many messages were deleted, or why the cleanup failed.
+///
+/// Only the task's creator can turn this on, since the messages go to them.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `channel` - The channel whose task to change.
+/// * `enabled` - Whether the creator should get direct messages.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) if the setting was changed or no task was found,
+/// or an EuleError if there was an issue.
+#[poise::command(slash_command, prefix_command)]
+pub async fn notify(
+    ctx: Context<'_>,
+    #[description = "Channel with an autoclean task you created"] channel: ChannelId,
+    #[description = "Get a direct message when a cleanup finishes or fails"] enabled: bool,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    if !may_manage_task(ctx, guild_id, channel).await? {
+        return Ok(());
+    }
+
+    let manager = &ctx.data().autoclean_manager;
+    let key = match manager.task(guild_id, channel).await {
+        None => "common.no_task",
+        Some(task) if enabled && task.created_by != Some(ctx.author().id) => {
+            "autoclean.notify_not_creator"
+        }
+        Some(_) => {
+            manager
+                .set_notify_creator(guild_id, channel, enabled)
+                .await?;
+            record_editor(ctx, guild_id, channel).await?;
+            match enabled {
+                true => "autoclean.notify_on",
+                false => "autoclean.notify_off",
+            }
+        }
+    };
+    let message = i18n::tr(ctx, key, &[("channel", &format!("<#{}>", channel))]).await;
+    reply::say(ctx, message).await
+}
+
+/// Posts a warning in a channel some time before each of its cleanups.
+///
+/// The warning can mention a role, so its members get a notification before
+/// the channel is cleared. Leaving out `minutes_before` turns the warning off.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `channel` - The channel whose task to change.
+/// * `minutes_before` - How many minutes before each cleanup to warn.
+/// * `role` - The role to mention in the warning.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) if the setting was changed or no task was found,
+/// or an EuleError if there was an issue.
+#[poise::command(slash_command, prefix_command)]
+pub async fn warning(
+    ctx: Context<'_>,
+    #[description = "Channel with an autoclean task"] channel: ChannelId,
+    #[description = "Minutes before each cleanup to post the warning"]
+    #[min = 1]
+    minutes_before: Option<u64>,
+    #[description = "Role to mention in the warning"] role: Option<RoleId>,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    if !may_manage_task(ctx, guild_id, channel).await? {
+        return Ok(());
+    }
+
+    let warning = minutes_before.map(|minutes| PurgeWarning {
+        lead: Duration::from_secs(minutes * 60),
+        role,
+    });
+    let mention = format!("<#{}>", channel);
+    let message = match (&warning, role) {
+        (Some(warning), Some(role)) => {
+            i18n::tr(
+                ctx,
+                "autoclean.warning_on_role",
+                &[
+                    ("channel", &mention),
+                    ("lead", &humanize::duration(warning.lead)),
+                    ("role", &format!("<@&{}>", role)),
+                ],
+            )
+            .await
+        }
+        (Some(warning), None) => {
+            i18n::tr(
+                ctx,
+                "autoclean.warning_on",
+                &[
+                    ("channel", &mention),
+                    ("lead", &humanize::duration(warning.lead)),
+                ],
+            )
+            .await
+        }
+        (None, _) => i18n::tr(ctx, "autoclean.warning_off", &[("channel", &mention)]).await,
+    };
+    let updated = ctx
+        .data()
+        .autoclean_manager
+        .set_warning(guild_id, channel, warning)
+        .await?;
+    record_editor(ctx, guild_id, channel).await?;
+    if !updated {
+        let message = i18n::tr(ctx, "common.no_task", &[("channel", &mention)]).await;
+        return reply::say(ctx, message).await;
+    }
+    reply::say(ctx, message).await
+}
+
+/// Rewords one of the bot's announcement messages for this server.
+///
+/// Templates may contain placeholders, such as `{channel}`, that are filled in
+/// when the message is posted. Leaving out `text` restores the default wording.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `kind` - The message to reword.
+/// * `text` - The new wording.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) if the template was changed, or an EuleError if
+/// it couldn't be saved.
+#[poise::command(slash_command, prefix_command)]
+pub async fn template(
+    ctx: Context<'_>,
+    #[description = "Message to reword"] kind: TemplateKind,
+    #[description = "New wording; leave out to restore the default"]
+    #[max_length = 1000]
+    text: Option<String>,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    if !may_change_server_settings(ctx, guild_id).await? {
+        return Ok(());
+    }
+
+    let text = text.filter(|text| !text.trim().is_empty());
+    let message = match &text {
+        Some(_) => {
+            i18n::tr(
+                ctx,
+                "autoclean.template_set",
+                &[
+                    ("kind", kind.name()),
+                    ("placeholders", &kind.placeholders().join(", ")),
+                ],
+            )
+            .await
+        }
+        None => i18n::tr(ctx, "autoclean.template_reset", &[("kind", kind.name())]).await,
+    };
+    ctx.data()
+        .autoclean_manager
+        .update_guild_settings(guild_id, |settings| settings.templates.set(kind, text))
+        .await?;
+    reply::say(ctx, message).await
+}
+
+/// Sets the channel the bot reports what it did on its own in, such as
+/// scheduling a channel by its category's default policy.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `channel` - The log channel, or `None` to stop reporting.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) if the setting was changed, or an EuleError if
+/// it couldn't be saved.
+#[poise::command(slash_command, prefix_command)]
+pub async fn log(
+    ctx: Context<'_>,
+    #[description = "Channel to report in; leave out to stop reporting"]
+    #[channel_types("Text", "News")]
+    channel: Option<ChannelId>,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    if !may_change_server_settings(ctx, guild_id).await? {
+        return Ok(());
+    }
+
+    ctx.data()
+        .autoclean_manager
+        .update_guild_settings(guild_id, |settings| settings.log_channel = channel)
+        .await?;
+    let message = match channel {
+        Some(channel) => {
+            i18n::tr(
+                ctx,
+                "autoclean.log_set",
+                &[("channel", &format!("<#{}>", channel))],
+            )
+            .await
+        }
+        None => i18n::tr(ctx, "autoclean.log_off", &[]).await,
+    };
+    reply::say(ctx, message).await
+}
+
+/// Caps how many messages this server's purges may delete per hour.
+///
+/// Purges that use up the hour's deletions pause and pick up again once the
+/// next hour starts, so one server's retention doesn't take all of the bot's
+/// capacity. The cap counts scheduled cleanups and purges started from
+/// commands alike.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `per_hour` - The deletions allowed per hour, or `None` to lift the cap.
+#[poise::command(slash_command, prefix_command)]
+pub async fn budget(
+    ctx: Context<'_>,
+    #[description = "Messages to delete per hour at most; leave out for no cap"]
+    #[min = 1]
+    per_hour: Option<u32>,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    if !may_change_server_settings(ctx, guild_id).await? {
+        return Ok(());
+    }
+
+    ctx.data()
+        .autoclean_manager
+        .set_deletion_budget(guild_id, per_hour)
+        .await?;
+    let message = match per_hour {
+        Some(per_hour) => {
+            i18n::tr(
+                ctx,
+                "autoclean.budget_set",
+                &[("count", &humanize::count(u64::from(per_hour)))],
+            )
+            .await
+        }
+        None => i18n::tr(ctx, "autoclean.budget_off", &[]).await,
+    };
+    reply::say(ctx, message).await
+}
+
+/// Deletes a member's messages as soon as they post a burst of them in one
+/// channel, instead of leaving spam up until the channel's next cleanup.
+///
+/// A burst is `messages` messages within `seconds`. Once a member posts one,
+/// the whole burst is deleted, and so is everything they post in the channel
+/// until they go quiet for `seconds`. Channels on hold are left alone. Leaving
+/// out `messages` stops watching for bursts.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `messages` - The messages that make a burst, or `None` to stop watching.
+/// * `seconds` - The time the messages are posted within.
+#[poise::command(slash_command, prefix_command)]
+pub async fn burst(
+    ctx: Context<'_>,
+    #[description = "Messages that make a burst; leave out to turn off"]
+    #[min = 3]
+    #[max = 50]
+    messages: Option<u32>,
+    #[description = "Seconds the messages are posted within (default 5)"]
+    #[min = 1]
+    #[max = 60]
+    seconds: Option<u64>,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    if !may_change_server_settings(ctx, guild_id).await? {
+        return Ok(());
+    }
+
+    let seconds = seconds.unwrap_or(DEFAULT_BURST_SECONDS);
+    let rule = messages.map(|messages| BurstRule {
+        messages,
+        window: Duration::from_secs(seconds),
+    });
+    ctx.data()
+        .autoclean_manager
+        .set_spam_burst(guild_id, rule)
+        .await?;
+    let watched = ctx
+        .data()
+        .bot
+        .config()
+        .intents()
+        .contains(GatewayIntents::GUILD_MESSAGES);
+    let key = match (messages, watched) {
+        (Some(_), true) => "autoclean.burst_on",
+        (Some(_), false) => "autoclean.burst_unavailable",
+        (None, _) => "autoclean.burst_off",
+    };
+    let message = i18n::tr(
+        ctx,
+        key,
+        &[
+            ("count", &messages.unwrap_or_default().to_string()),
+            ("seconds", &seconds.to_string()),
+        ],
+    )
+    .await;
+    reply::say(ctx, message).await
+}
+
+/// Moves a channel's task to another channel, keeping its schedule, settings,
+/// history and statistics, for channels that were recreated and got a new ID.
+///
+/// The new channel must be of a kind the task can clean: a forum for forum
+/// cleanups, a channel with threads for thread cleanups, and any channel with
+/// messages otherwise. Threads of channels that can't have them are no longer
+/// cleaned.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `from` - The channel the task belongs to, which may already be deleted.
+/// * `to` - The channel to move the task to.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) if the task was moved or couldn't be, or an
+/// EuleError if there was an issue.
+#[poise::command(slash_command, prefix_command, rename = "move")]
+pub async fn move_task(
+    ctx: Context<'_>,
+    #[description = "Channel the task belongs to now"] from: ChannelId,
+    #[description = "Channel to move the task to"] to: GuildChannel,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    if !may_manage_task(ctx, guild_id, from).await? {
+        return Ok(());
+    }
+
+    let manager = &ctx.data().autoclean_manager;
+    let language = i18n::language(ctx).await;
+    let (from_mention, to_mention) = (format!("<#{}>", from), format!("<#{}>", to.id));
+    let Some(task) = manager.task(guild_id, from).await else {
+        let message = i18n::text(language, "common.no_task", &[("channel", &from_mention)]);
+        return reply::say(ctx, message).await;
+    };
+    let support = ChannelSupport::of(to.kind);
+    let fits = match (&task.forum, &task.threads) {
+        (Some(_), _) => support == ChannelSupport::Forum,
+        (None, Some(_)) => support == ChannelSupport::Messages { threads: true },
+        (None, None) => matches!(support, ChannelSupport::Messages { .. }),
+    };
+    if !fits {
+        let message = i18n::text(
+            language,
+            "autoclean.move_unfit",
+            &[("from", &from_mention), ("to", &to_mention)],
+        );
+        return reply::say(ctx, message).await;
+    }
+
+    let key = match manager.move_task(guild_id, from, to.id).await? {
+        TaskMove::Moved => {
+            if task.include_threads && !support.has_threads() {
+                manager.set_include_threads(guild_id, to.id, false).await?;
+            }
+            manager
+                .record_editor(guild_id, to.id, ctx.author().id)
+                .await?;
+            "autoclean.moved"
+        }
+        TaskMove::NoTask => "common.no_task",
+        TaskMove::Occupied => "autoclean.move_occupied",
+        TaskMove::Running => "autoclean.move_running",
+    };
+    let message = i18n::text(
+        language,
+        key,
+        &[
+            ("channel", &from_mention),
+            ("from", &from_mention),
+            ("to", &to_mention),
+        ],
+    );
+    reply::say(ctx, message).await
+}
+
+/// Removes an autoclean task for a specified channel.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `channel` - The channel to remove the autoclean task from.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) if the task was removed successfully or if no task was found,
+/// or an EuleError if there was an issue.
+#[poise::command(slash_command, prefix_command)]
+pub async fn remove(
+    ctx: Context<'_>,
+    #[description = "Channel to remove autoclean task from"] channel: ChannelId,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    if !may_manage_task(ctx, guild_id, channel).await? {
+        return Ok(());
+    }
+
+    let key = if ctx
+        .data()
+        .autoclean_manager
+        .remove_task(guild_id, channel)
+        .await?
+    {
+        "autoclean.removed"
+    } else {
+        "common.no_task"
+    };
+    let message = i18n::tr(ctx, key, &[("channel", &format!("<#{}>", channel))]).await;
+    reply::say(ctx, message).await?;
+
+    Ok(())
+}
+
+/// Removes every autoclean task and scheduled purge in the current server, for
+/// servers retiring the bot or starting their retention setup over.
+///
+/// The moderator is asked to confirm first, and nothing is removed if they
+/// don't in time. Cleanups in progress are stopped. Named policies are kept.
+/// Requires the `MANAGE_GUILD` permission, and on servers where members only
+/// manage their own tasks, being a server administrator.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) if the tasks were removed or kept, or an
+/// EuleError if there was an issue.
+#[poise::command(slash_command, prefix_command, required_permissions = "MANAGE_GUILD")]
+pub async fn remove_all(ctx: Context<'_>) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    let manager = &ctx.data().autoclean_manager;
+    let language = i18n::language(ctx).await;
+
+    if manager.guild_settings(guild_id).await.own_tasks_only && !is_administrator(ctx).await {
+        return reply::say(ctx, i18n::text(language, "config.not_owner_all", &[])).await;
+    }
+    let tasks = manager.task_count(guild_id).await;
+    let purges = manager.scheduled_purges(guild_id).await.len()
+        + manager.guild_settings(guild_id).await.event_purges.len();
+    if tasks == 0 && purges == 0 {
+        return reply::say(ctx, i18n::text(language, "autoclean.no_tasks", &[])).await;
+    }
+
+    let prefix = ctx.id().to_string();
+    let remove_id = format!("{}_remove_all", prefix);
+    let keep_id = format!("{}_keep", prefix);
+    reply::send(
+        ctx,
+        CreateReply::default()
+            .content(i18n::text(
+                language,
+                "autoclean.remove_all_warning",
+                &[
+                    ("tasks", &tasks.to_string()),
+                    ("purges", &purges.to_string()),
+                ],
+            ))
+            .components(vec![CreateActionRow::Buttons(vec![
+                CreateButton::new(remove_id.clone())
+                    .label(i18n::text(language, "autoclean.remove_all_button", &[]))
+                    .style(ButtonStyle::Danger),
+                CreateButton::new(keep_id)
+                    .label(i18n::text(language, "autoclean.keep", &[]))
+                    .style(ButtonStyle::Secondary),
+            ])]),
+    )
+    .await?;
+
+    let Some(press) = ComponentInteractionCollector::new(ctx.serenity_context())
+        .author_id(ctx.author().id)
+        .filter(move |press| press.data.custom_id.starts_with(&prefix))
+        .timeout(CONFIRM_TIMEOUT)
+        .await
+    else {
+        let message = i18n::text(language, "autoclean.remove_all_no_answer", &[]);
+        return reply::say(ctx, message).await;
+    };
+
+    let content = if press.data.custom_id == remove_id {
+        tracing::info!("{} removed every task of their guild", ctx.author().id);
+        let (tasks, purges) = manager.remove_guild_tasks(guild_id).await?;
+        i18n::text(
+            language,
+            "autoclean.removed_all",
+            &[
+                ("tasks", &tasks.to_string()),
+                ("purges", &purges.to_string()),
+            ],
+        )
+    } else {
+        i18n::text(language, "autoclean.remove_all_kept", &[])
+    };
+    press
+        .create_response(
+            ctx,
+            CreateInteractionResponse::UpdateMessage(
+                CreateInteractionResponseMessage::new()
+                    .content(content)
+                    .components(Vec::new()),
+            ),
+        )
+        .await?;
+    Ok(())
+}
+
+/// Lists all autoclean tasks in the current server, or only those with a
+/// label.
+///
+/// Tasks are shown as an embed with one field per channel, with page buttons
+/// when there are more tasks than fit on one page.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `label` - Only lists the tasks with this label, if given.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) if the tasks were listed successfully,
+/// or an EuleError if there was an issue.
+#[poise::command(slash_command, prefix_command)]
+pub async fn list(
+    ctx: Context<'_>,
+    #[description = "Only list tasks with this label"] label: Option<String>,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+
+    let tasks: Vec<_> = ctx
+        .data()
+        .autoclean_manager
+        .guild_tasks(guild_id)
+        .await
+        .into_iter()
+        .filter(|(_, task)| task.has_label(label.as_deref()))
+        .collect();
+
+    let language = i18n::language(ctx).await;
+    if tasks.is_empty() {
+        let message = match &label {
+            Some(label) => i18n::text(
+                language,
+                "autoclean.no_labelled_tasks",
+                &[("label", &format!("`{}`", label.trim().to_lowercase()))],
+            ),
+            None => i18n::text(language, "autoclean.no_tasks", &[]),
+        };
+        reply::say(ctx, message).await?;
+        return Ok(());
+    }
+
+    let manager = &ctx.data().autoclean_manager;
+    let mut fields = Vec::with_capacity(tasks.len());
+    for (channel_id, task) in &tasks {
+        let state = manager.task_state(guild_id, *channel_id, task).await;
+        fields.push(task_field(language, channel_id, task, state));
+    }
+    let pages = paginate_fields(fields, FIELDS_PER_PAGE)
+        .into_iter()
+        .map(|fields| {
+            CreateEmbed::new()
+                .title(i18n::text(language, "autoclean.list_title", &[]))
+                .fields(fields.into_iter().map(|(name, value)| (name, value, false)))
+        })
+        .collect();
+    send_paginated(ctx, pages).await
+}
+
+/// Links this server's calendar feed of upcoming purges.
+///
+/// The link contains the token that unlocks the feed, so it is only shown to
+/// the moderator who asked for it.
+#[poise::command(slash_command, prefix_command)]
+pub async fn calendar(ctx: Context<'_>) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    let language = i18n::language(ctx).await;
+    let config = ctx.data().bot.config();
+    let Some(base_url) = config.calendar_base_url() else {
+        ctx.send(
+            CreateReply::default()
+                .content(i18n::text(language, "autoclean.calendar_disabled", &[]))
+                .ephemeral(true),
+        )
+        .await?;
+        return Ok(());
+    };
+    let key = crate::admin::calendar::calendar_key(&ctx.data().kv_store).await?;
+    ctx.send(
+        CreateReply::default()
+            .content(i18n::text(
+                language,
+                "autoclean.calendar_link",
+                &[(
+                    "url",
+                    &crate::admin::calendar::feed_url(base_url, &key, guild_id),
+                )],
+            ))
+            .ephemeral(true),
+    )
+    .await?;
     Ok(())
 }
 
@@ -157,15 +2135,17 @@ pub async fn workers(ctx: Context<'_>) -> Result<(), EuleError> {
     let worker_count = ctx.data().autoclean_manager.worker_count().await;
 
     let message = if worker_count == 1 {
-        "I am currently the only EULR unit on duty, Commander! 🫡"
+        i18n::tr(ctx, "autoclean.workers_one", &[]).await
     } else {
-        &format!(
-            "There are currently {} EULR units on duty, Commander! 🫡",
-            worker_count
+        i18n::tr(
+            ctx,
+            "autoclean.workers_many",
+            &[("count", &worker_count.to_string())],
         )
+        .await
     };
 
-    ctx.say(message).await?;
+    reply::say(ctx, message).await?;
 
     Ok(())
 }
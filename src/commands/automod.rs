@@ -0,0 +1,231 @@
+//! Commands setting up Discord AutoMod rules for the bot's filters.
+//!
+//! Cleanups delete unwanted messages once they are posted; the rules set up
+//! here keep them from being posted in the first place, so both are
+//! configured from one place. See [`crate::tasks::automod`] for the rules
+//! themselves. All commands in this module require the `MANAGE_GUILD`
+//! permission, and the bot needs it as well.
+
+use crate::{
+    commands::{autoclean::parse_keywords, reply},
+    i18n,
+    phishing::blocked_domains,
+    tasks::automod::{actions, keyword_filters, phishing_filters, AutomodRule},
+    Context, EuleError,
+};
+use poise::serenity_prelude::{
+    automod::{EventType, Trigger},
+    EditAutoModRule,
+};
+
+/// Manages the AutoMod rules that block messages before they are posted.
+#[poise::command(
+    slash_command,
+    prefix_command,
+    guild_only,
+    subcommands("keywords", "invites", "phishing", "list"),
+    required_permissions = "MANAGE_GUILD",
+    required_bot_permissions = "MANAGE_GUILD"
+)]
+pub async fn automod(_: Context<'_>) -> Result<(), EuleError> {
+    Ok(())
+}
+
+/// Blocks messages containing any of the given keywords, anywhere in the
+/// message and ignoring case.
+///
+/// Keywords are separated by commas. Leaving out `keywords` removes the rule.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `keywords` - The keywords to block, such as `free nitro, steam gift`.
+#[poise::command(slash_command, prefix_command)]
+pub async fn keywords(
+    ctx: Context<'_>,
+    #[description = "Comma-separated keywords to block; leave out to remove the rule"]
+    keywords: Option<String>,
+) -> Result<(), EuleError> {
+    reply::defer(ctx).await?;
+
+    let Some(keywords) = keywords else {
+        remove_rule(ctx, AutomodRule::Keywords).await?;
+        let message = i18n::tr(ctx, "automod.keywords_off", &[]).await;
+        return reply::say(ctx, message).await;
+    };
+    let filters = keyword_filters(parse_keywords(&keywords));
+    if filters.is_empty() {
+        let message = i18n::tr(ctx, "automod.no_keywords", &[]).await;
+        return reply::say(ctx, message).await;
+    }
+    let count = filters.len();
+    apply_rule(ctx, AutomodRule::Keywords, filters).await?;
+    let message = i18n::tr(
+        ctx,
+        "automod.keywords_set",
+        &[("count", &count.to_string())],
+    )
+    .await;
+    reply::say(ctx, message).await
+}
+
+/// Blocks Discord invite links.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `enabled` - Whether to block invite links.
+#[poise::command(slash_command, prefix_command)]
+pub async fn invites(
+    ctx: Context<'_>,
+    #[description = "Block invite links to other servers"] enabled: bool,
+) -> Result<(), EuleError> {
+    reply::defer(ctx).await?;
+
+    let key = if enabled {
+        apply_rule(ctx, AutomodRule::Invites, Vec::new()).await?;
+        "automod.invites_on"
+    } else {
+        remove_rule(ctx, AutomodRule::Invites).await?;
+        "automod.invites_off"
+    };
+    let message = i18n::tr(ctx, key, &[]).await;
+    reply::say(ctx, message).await
+}
+
+/// Blocks links to the domains of the bot's phishing feed.
+///
+/// The rule holds the domains the feed listed when it was set up; running
+/// the command again brings it up to date. Cleanups keep deleting links to
+/// domains added since.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `enabled` - Whether to block phishing links.
+#[poise::command(slash_command, prefix_command)]
+pub async fn phishing(
+    ctx: Context<'_>,
+    #[description = "Block links to known phishing sites"] enabled: bool,
+) -> Result<(), EuleError> {
+    reply::defer(ctx).await?;
+
+    if !enabled {
+        remove_rule(ctx, AutomodRule::Phishing).await?;
+        let message = i18n::tr(ctx, "automod.phishing_off", &[]).await;
+        return reply::say(ctx, message).await;
+    }
+    let domains = blocked_domains();
+    if domains.is_empty() {
+        let message = i18n::tr(ctx, "automod.phishing_unavailable", &[]).await;
+        return reply::say(ctx, message).await;
+    }
+    let filters = phishing_filters(&domains);
+    let count = filters.len();
+    apply_rule(ctx, AutomodRule::Phishing, filters).await?;
+    let message = i18n::tr(
+        ctx,
+        "automod.phishing_on",
+        &[
+            ("count", &count.to_string()),
+            ("total", &domains.len().to_string()),
+        ],
+    )
+    .await;
+    reply::say(ctx, message).await
+}
+
+/// Lists the AutoMod rules the bot set up on this server and whether they
+/// are still active.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+#[poise::command(slash_command, prefix_command)]
+pub async fn list(ctx: Context<'_>) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    let settings = ctx.data().autoclean_manager.guild_settings(guild_id).await;
+    if settings.automod_rules.is_empty() {
+        let message = i18n::tr(ctx, "automod.none", &[]).await;
+        return reply::say(ctx, message).await;
+    }
+    let mut lines = Vec::new();
+    for (kind, rule_id) in &settings.automod_rules {
+        let line = match guild_id.automod_rule(ctx.http(), *rule_id).await {
+            Ok(rule) if rule.enabled => {
+                let count = match rule.trigger {
+                    Trigger::Keyword {
+                        strings,
+                        regex_patterns,
+                        ..
+                    } => strings.len() + regex_patterns.len(),
+                    _ => 0,
+                };
+                i18n::tr(
+                    ctx,
+                    "automod.rule_active",
+                    &[("name", &rule.name), ("count", &count.to_string())],
+                )
+                .await
+            }
+            Ok(rule) => i18n::tr(ctx, "automod.rule_disabled", &[("name", &rule.name)]).await,
+            Err(_) => i18n::tr(ctx, "automod.rule_missing", &[("name", kind.name())]).await,
+        };
+        lines.push(line);
+    }
+    reply::say(ctx, lines.join("\n")).await
+}
+
+/// Creates or updates the guild's rule for a filter.
+///
+/// The rule the bot set up before is updated if it still exists, keeping
+/// any name, exemptions or extra actions moderators gave it. Otherwise a new
+/// rule is created.
+async fn apply_rule(
+    ctx: Context<'_>,
+    kind: AutomodRule,
+    filters: Vec<String>,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    let manager = &ctx.data().autoclean_manager;
+    let settings = manager.guild_settings(guild_id).await;
+
+    if let Some(rule_id) = settings.automod_rules.get(&kind) {
+        let builder = EditAutoModRule::new()
+            .trigger(kind.trigger(filters.clone()))
+            .enabled(true);
+        match guild_id.edit_automod_rule(ctx, *rule_id, builder).await {
+            Ok(_) => return Ok(()),
+            Err(e) => tracing::debug!("Replacing AutoMod rule {}: {}", rule_id, e),
+        }
+    }
+    let builder = EditAutoModRule::new()
+        .name(kind.name())
+        .event_type(EventType::MessageSend)
+        .trigger(kind.trigger(filters))
+        .actions(actions(settings.log_channel))
+        .enabled(true);
+    let rule = guild_id.create_automod_rule(ctx, builder).await?;
+    manager
+        .set_automod_rule(guild_id, kind, Some(rule.id))
+        .await
+}
+
+/// Deletes the guild's rule for a filter, if the bot set one up.
+///
+/// A rule that was already deleted in the server settings is only
+/// forgotten.
+async fn remove_rule(ctx: Context<'_>, kind: AutomodRule) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    let manager = &ctx.data().autoclean_manager;
+    let settings = manager.guild_settings(guild_id).await;
+    let Some(rule_id) = settings.automod_rules.get(&kind) else {
+        return Ok(());
+    };
+    if let Err(e) = guild_id.delete_automod_rule(ctx.http(), *rule_id).await {
+        tracing::warn!("Failed to delete AutoMod rule {}: {}", rule_id, e);
+    }
+    manager.set_automod_rule(guild_id, kind, None).await
+}
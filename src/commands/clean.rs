@@ -3,13 +3,15 @@
 //! This module contains the `clean` command, which allows users to delete
 //! a specified number of messages from the current channel.
 
-use crate::{Data, EuleError};
+use crate::{i18n, purge::DiscordApi, Data, EuleError};
 use poise::serenity_prelude as serenity;
 
 /// Cleans up a specified number of messages in the current channel.
 ///
 /// This command allows users with the `MANAGE_MESSAGES` permission to delete
-/// a number of recent messages from the channel where it's invoked.
+/// a number of recent messages from the channel where it's invoked. The response
+/// is deferred while the messages are deleted and the confirmation is only shown
+/// to the invoking user.
 ///
 /// # Arguments
 ///
@@ -28,12 +30,31 @@ use poise::serenity_prelude as serenity;
 #[poise::command(
     slash_command,
     prefix_command,
-    required_permissions = "MANAGE_MESSAGES"
+    required_permissions = "MANAGE_MESSAGES",
+    user_cooldown = 10
 )]
 pub async fn clean(
     ctx: poise::Context<'_, Data, EuleError>,
     #[description = "Number of messages to clean"] number: Option<u64>,
 ) -> Result<(), EuleError> {
+    // Deleting can take longer than Discord's three second response window. The
+    // acknowledgement is ephemeral so it isn't among the messages being deleted.
+    ctx.defer_ephemeral().await?;
+
+    if let Some(guild_id) = ctx.guild_id() {
+        if ctx
+            .data()
+            .autoclean_manager
+            .is_held(guild_id, ctx.channel_id())
+            .await
+        {
+            let mention = format!("<#{}>", ctx.channel_id());
+            ctx.say(i18n::tr(ctx, "purge.on_hold", &[("channel", &mention)]).await)
+                .await?;
+            return Ok(());
+        }
+    }
+
     // Ensure the number of messages to clean is between 1 and 100
     let number = number.unwrap_or(10).min(100) as u8;
 
@@ -46,13 +67,17 @@ pub async fn clean(
         )
         .await?;
 
-    // Delete the messages
-    ctx.channel_id()
-        .delete_messages(&ctx.http(), &messages)
+    // Delete the messages through the bot's client, so simulated deletes apply
+    let message_ids: Vec<_> = messages.iter().map(|message| message.id).collect();
+    ctx.data()
+        .bot
+        .api()
+        .delete_messages(ctx.channel_id(), &message_ids)
         .await?;
 
     // Confirm the number of messages cleaned
-    ctx.say(format!("Cleaned {} messages! 🚮", messages.len()))
+    let count = messages.len().to_string();
+    ctx.say(i18n::tr(ctx, "clean.cleaned", &[("count", &count)]).await)
         .await?;
 
     Ok(())
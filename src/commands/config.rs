@@ -0,0 +1,580 @@
+//! Commands for settings that apply to a whole server.
+//!
+//! `/config` gathers the server's time zone, log channel, where announcements
+//! go, whether replies are private, which roles may change tasks, whether
+//! members may only manage the tasks they created, and the settings new tasks
+//! start with. The settings are kept with the rest of the server's
+//! [`GuildSettings`] and read by the features they affect.
+
+use crate::{
+    commands::{maintenance::blocked_in_maintenance, reply},
+    i18n::{self, Language},
+    tasks::guild_settings::{Announcements, GuildSettings},
+    utils::serializable_instant::{format_utc_offset, parse_utc_offset},
+    Context, EuleError,
+};
+use poise::{
+    serenity_prelude::{ChannelId, GuildId, RoleId},
+    CreateReply,
+};
+
+/// Writes an overview of a server's settings.
+///
+/// # Arguments
+/// * `language` - The language to write it in
+/// * `settings` - The server's settings
+pub fn config_overview(language: Language, settings: &GuildSettings) -> String {
+    let yes_no = |value: bool| match value {
+        true => i18n::text(language, "config.yes", &[]),
+        false => i18n::text(language, "config.no", &[]),
+    };
+    let log_channel = match settings.log_channel {
+        Some(channel) => format!("<#{}>", channel),
+        None => i18n::text(language, "config.none", &[]),
+    };
+    let admin_roles = match settings.admin_roles.is_empty() {
+        true => i18n::text(language, "config.none", &[]),
+        false => settings
+            .admin_roles
+            .iter()
+            .map(|role| format!("<@&{}>", role))
+            .collect::<Vec<_>>()
+            .join(", "),
+    };
+    let defaults = settings.task_defaults;
+    i18n::text(
+        language,
+        "config.overview",
+        &[
+            ("timezone", &format_utc_offset(settings.utc_offset)),
+            ("log", &log_channel),
+            (
+                "announcements",
+                &announcements_text(language, settings.announcements),
+            ),
+            ("ephemeral", &yes_no(settings.ephemeral_replies)),
+            ("roles", &admin_roles),
+            ("own_tasks", &yes_no(settings.own_tasks_only)),
+            ("keep_pinned", &yes_no(defaults.keep_pinned)),
+            ("keep_first", &yes_no(defaults.keep_first_message)),
+            ("show_in_topic", &yes_no(defaults.show_in_topic)),
+        ],
+    )
+}
+
+/// Describes where announcements go.
+fn announcements_text(language: Language, announcements: Announcements) -> String {
+    let key = match announcements {
+        Announcements::Channel => "config.announce_channel",
+        Announcements::LogChannel => "config.announce_log",
+        Announcements::Off => "config.announce_off",
+    };
+    i18n::text(language, key, &[])
+}
+
+/// Lets a command run unless the server limits changing tasks to admin roles
+/// and the user has none of them, in which case the user is told why.
+///
+/// Server administrators always pass, so a server can't lock itself out.
+/// Used as part of the framework's command check.
+pub async fn holds_admin_role(ctx: Context<'_>) -> Result<bool, EuleError> {
+    let Some(guild_id) = ctx.guild_id() else {
+        return Ok(true);
+    };
+    // The commands turned away during maintenance are the ones that change tasks
+    let name = &ctx.command().qualified_name;
+    if !blocked_in_maintenance(name) && !name.starts_with("config") {
+        return Ok(true);
+    }
+    let roles = ctx
+        .data()
+        .autoclean_manager
+        .guild_settings(guild_id)
+        .await
+        .admin_roles;
+    if roles.is_empty() {
+        return Ok(true);
+    }
+    if let Some(member) = ctx.author_member().await {
+        let administrator = member
+            .permissions
+            .is_some_and(|permissions| permissions.administrator());
+        if administrator || member.roles.iter().any(|role| roles.contains(role)) {
+            return Ok(true);
+        }
+    }
+    let message = i18n::tr(ctx, "config.not_admin", &[]).await;
+    ctx.send(CreateReply::default().content(message).ephemeral(true))
+        .await?;
+    Ok(false)
+}
+
+/// Lets a member change or cancel a channel's task unless the server limits
+/// members to their own tasks and someone else created it, in which case the
+/// member is told why.
+///
+/// Server administrators may manage every task. Channels without a task pass,
+/// so the command can say so itself.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `guild_id` - The guild the channel belongs to.
+/// * `channel` - The channel whose task is to be managed.
+pub async fn may_manage_task(
+    ctx: Context<'_>,
+    guild_id: GuildId,
+    channel: ChannelId,
+) -> Result<bool, EuleError> {
+    let manager = &ctx.data().autoclean_manager;
+    let settings = manager.guild_settings(guild_id).await;
+    if !settings.own_tasks_only {
+        return Ok(true);
+    }
+    let Some(task) = manager.task(guild_id, channel).await else {
+        return Ok(true);
+    };
+    let administrator = is_administrator(ctx).await;
+    if settings.may_manage(&task, ctx.author().id, administrator) {
+        return Ok(true);
+    }
+    let creator = match task.created_by {
+        Some(user) => format!("<@{}>", user),
+        None => i18n::tr(ctx, "config.unknown_creator", &[]).await,
+    };
+    let message = i18n::tr(
+        ctx,
+        "config.not_owner",
+        &[
+            ("channel", &format!("<#{}>", channel)),
+            ("creator", &creator),
+        ],
+    )
+    .await;
+    ctx.send(CreateReply::default().content(message).ephemeral(true))
+        .await?;
+    Ok(false)
+}
+
+/// Returns the channels among `channels` whose tasks whoever used a command
+/// may not change, because the server limits members to their own tasks and
+/// someone else created them.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `guild_id` - The guild the channels belong to.
+/// * `channels` - The channels whose tasks are to be changed.
+pub async fn others_tasks(
+    ctx: Context<'_>,
+    guild_id: GuildId,
+    channels: &[ChannelId],
+) -> Vec<ChannelId> {
+    let manager = &ctx.data().autoclean_manager;
+    let settings = manager.guild_settings(guild_id).await;
+    if !settings.own_tasks_only || is_administrator(ctx).await {
+        return Vec::new();
+    }
+    let mut others = Vec::new();
+    for &channel in channels {
+        if let Some(task) = manager.task(guild_id, channel).await {
+            if !settings.may_manage(&task, ctx.author().id, false) {
+                others.push(channel);
+            }
+        }
+    }
+    others
+}
+
+/// Writes the notice that a command would change tasks other members created.
+///
+/// # Arguments
+///
+/// * `language` - The language to write it in.
+/// * `others` - The channels whose tasks belong to someone else.
+pub fn not_owner_text(language: Language, others: &[ChannelId]) -> String {
+    let channels = others
+        .iter()
+        .map(|channel| format!("<#{}>", channel))
+        .collect::<Vec<_>>()
+        .join(", ");
+    i18n::text(
+        language,
+        "config.not_owner_some",
+        &[("channels", &channels)],
+    )
+}
+
+/// Lets a member change several channels' tasks at once unless any of them
+/// was created by someone else on a server that limits members to their own
+/// tasks, in which case nothing is changed and the member is told which.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `guild_id` - The guild the channels belong to.
+/// * `channels` - The channels whose tasks are to be changed.
+pub async fn may_manage_tasks(
+    ctx: Context<'_>,
+    guild_id: GuildId,
+    channels: &[ChannelId],
+) -> Result<bool, EuleError> {
+    let others = others_tasks(ctx, guild_id, channels).await;
+    if others.is_empty() {
+        return Ok(true);
+    }
+    let message = not_owner_text(i18n::language(ctx).await, &others);
+    ctx.send(CreateReply::default().content(message).ephemeral(true))
+        .await?;
+    Ok(false)
+}
+
+/// Lets a member change settings that affect every task of the server, such
+/// as its log channel, unless the server limits members to their own tasks,
+/// in which case only administrators may and the member is told why.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `guild_id` - The guild whose settings are to be changed.
+pub async fn may_change_server_settings(
+    ctx: Context<'_>,
+    guild_id: GuildId,
+) -> Result<bool, EuleError> {
+    let settings = ctx.data().autoclean_manager.guild_settings(guild_id).await;
+    if !settings.own_tasks_only || is_administrator(ctx).await {
+        return Ok(true);
+    }
+    let message = i18n::tr(ctx, "config.not_owner_settings", &[]).await;
+    ctx.send(CreateReply::default().content(message).ephemeral(true))
+        .await?;
+    Ok(false)
+}
+
+/// Returns whether whoever used a command is a server administrator.
+pub async fn is_administrator(ctx: Context<'_>) -> bool {
+    ctx.author_member().await.is_some_and(|member| {
+        member
+            .permissions
+            .is_some_and(|permissions| permissions.administrator())
+    })
+}
+
+/// Parent command for server-wide settings.
+///
+/// # Permissions
+///
+/// Requires the `MANAGE_GUILD` permission.
+#[poise::command(
+    slash_command,
+    prefix_command,
+    guild_only,
+    subcommands(
+        "show",
+        "timezone",
+        "log_channel",
+        "announcements",
+        "ephemeral",
+        "admin_role",
+        "own_tasks",
+        "defaults"
+    ),
+    required_permissions = "MANAGE_GUILD"
+)]
+pub async fn config(_: Context<'_>) -> Result<(), EuleError> {
+    Ok(())
+}
+
+/// Shows this server's settings.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) if the settings were shown, or an EuleError if
+/// there was an issue.
+#[poise::command(slash_command, prefix_command)]
+pub async fn show(ctx: Context<'_>) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    let settings = ctx.data().autoclean_manager.guild_settings(guild_id).await;
+    let language = i18n::language(ctx).await;
+    reply::say(ctx, config_overview(language, &settings)).await
+}
+
+/// Sets the time zone dates given to commands are read in, as an offset from
+/// UTC like `+02:00` or `-5`.
+///
+/// Offsets don't follow daylight saving time, so a server that observes it
+/// changes the offset when the clocks change. Leaving out `offset` goes back
+/// to UTC.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `offset` - The offset from UTC, or `None` for UTC itself.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) if the time zone was changed or the offset was
+/// invalid, or an EuleError if it couldn't be saved.
+#[poise::command(slash_command, prefix_command)]
+pub async fn timezone(
+    ctx: Context<'_>,
+    #[description = "Offset from UTC, like +02:00 or -5; leave out for UTC"] offset: Option<String>,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    let minutes = match offset.as_deref().map(parse_utc_offset) {
+        None => 0,
+        Some(Some(minutes)) => minutes,
+        Some(None) => {
+            let text = offset.unwrap_or_default();
+            let message = i18n::tr(ctx, "config.invalid_timezone", &[("offset", &text)]).await;
+            return reply::say(ctx, message).await;
+        }
+    };
+    ctx.data()
+        .autoclean_manager
+        .update_guild_settings(guild_id, |settings| settings.utc_offset = minutes)
+        .await?;
+    let message = i18n::tr(
+        ctx,
+        "config.timezone_set",
+        &[("timezone", &format_utc_offset(minutes))],
+    )
+    .await;
+    reply::say(ctx, message).await
+}
+
+/// Sets the channel the bot reports what it does on its own in, such as dry
+/// runs and expired tasks.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `channel` - The log channel, or `None` to stop reporting.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) if the setting was changed, or an EuleError if
+/// it couldn't be saved.
+#[poise::command(slash_command, prefix_command)]
+pub async fn log_channel(
+    ctx: Context<'_>,
+    #[description = "Channel to report in; leave out to stop reporting"]
+    #[channel_types("Text", "News")]
+    channel: Option<ChannelId>,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    ctx.data()
+        .autoclean_manager
+        .update_guild_settings(guild_id, |settings| settings.log_channel = channel)
+        .await?;
+    let message = match channel {
+        Some(channel) => {
+            i18n::tr(
+                ctx,
+                "autoclean.log_set",
+                &[("channel", &format!("<#{}>", channel))],
+            )
+            .await
+        }
+        None => i18n::tr(ctx, "autoclean.log_off", &[]).await,
+    };
+    reply::say(ctx, message).await
+}
+
+/// Chooses where announcements for cleaned channels, such as warnings before
+/// a cleanup, are posted: in the channel itself, in the log channel, or
+/// nowhere.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `target` - Where announcements go.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) if the setting was changed, or an EuleError if
+/// it couldn't be saved.
+#[poise::command(slash_command, prefix_command)]
+pub async fn announcements(
+    ctx: Context<'_>,
+    #[description = "Where to post announcements"] target: Announcements,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    ctx.data()
+        .autoclean_manager
+        .update_guild_settings(guild_id, |settings| settings.announcements = target)
+        .await?;
+    let language = i18n::language(ctx).await;
+    let message = i18n::text(
+        language,
+        "config.announcements_set",
+        &[("target", &announcements_text(language, target))],
+    );
+    reply::say(ctx, message).await
+}
+
+/// Sets whether the bot's replies on this server are only shown to whoever
+/// used the command.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `enabled` - Whether replies should be private.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) if the setting was changed, or an EuleError if
+/// it couldn't be saved.
+#[poise::command(slash_command, prefix_command)]
+pub async fn ephemeral(
+    ctx: Context<'_>,
+    #[description = "Only show replies to whoever used the command"] enabled: bool,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    ctx.data()
+        .autoclean_manager
+        .update_guild_settings(guild_id, |settings| settings.ephemeral_replies = enabled)
+        .await?;
+    let key = match enabled {
+        true => "config.ephemeral_on",
+        false => "config.ephemeral_off",
+    };
+    let message = i18n::tr(ctx, key, &[]).await;
+    reply::say(ctx, message).await
+}
+
+/// Limits members to changing and cancelling the tasks they created, or lets
+/// them manage every task again.
+///
+/// Server administrators can always manage every task. Tasks created before
+/// their creator was recorded, or through the admin API, are left to them.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `enabled` - Whether members may only manage their own tasks.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) if the setting was changed, or an EuleError if
+/// it couldn't be saved.
+#[poise::command(slash_command, prefix_command)]
+pub async fn own_tasks(
+    ctx: Context<'_>,
+    #[description = "Only let members change and cancel the tasks they created"] enabled: bool,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    ctx.data()
+        .autoclean_manager
+        .update_guild_settings(guild_id, |settings| settings.own_tasks_only = enabled)
+        .await?;
+    let key = match enabled {
+        true => "config.own_tasks_on",
+        false => "config.own_tasks_off",
+    };
+    let message = i18n::tr(ctx, key, &[]).await;
+    reply::say(ctx, message).await
+}
+
+/// Lets members with a role change tasks, or stops letting them.
+///
+/// Once a server has admin roles, only their members and server
+/// administrators can use the commands that change tasks or delete messages,
+/// on top of the Discord permissions those commands need.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `role` - The role.
+/// * `enabled` - Whether the role's members may change tasks.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) if the setting was changed, or an EuleError if
+/// it couldn't be saved.
+#[poise::command(slash_command, prefix_command)]
+pub async fn admin_role(
+    ctx: Context<'_>,
+    #[description = "Role to allow or disallow"] role: RoleId,
+    #[description = "Whether the role's members may change tasks"] enabled: bool,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    ctx.data()
+        .autoclean_manager
+        .update_guild_settings(guild_id, |settings| match enabled {
+            true => {
+                settings.admin_roles.insert(role);
+            }
+            false => {
+                settings.admin_roles.remove(&role);
+            }
+        })
+        .await?;
+    let key = match enabled {
+        true => "config.admin_role_added",
+        false => "config.admin_role_removed",
+    };
+    let message = i18n::tr(ctx, key, &[("role", &format!("<@&{}>", role))]).await;
+    reply::say(ctx, message).await
+}
+
+/// Sets what new tasks start with. Tasks that already exist keep their
+/// settings, and policies override these.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `keep_pinned` - Whether new tasks keep pinned messages.
+/// * `keep_first` - Whether new tasks keep the channel's oldest message.
+/// * `show_in_topic` - Whether new tasks show the next cleanup in the topic.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) if the defaults were changed, or an EuleError
+/// if they couldn't be saved.
+#[poise::command(slash_command, prefix_command)]
+pub async fn defaults(
+    ctx: Context<'_>,
+    #[description = "Keep pinned messages"] keep_pinned: Option<bool>,
+    #[description = "Keep the channel's oldest message"] keep_first: Option<bool>,
+    #[description = "Show the next cleanup time in the channel topic"] show_in_topic: Option<bool>,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    let manager = &ctx.data().autoclean_manager;
+    manager
+        .update_guild_settings(guild_id, |settings| {
+            let defaults = &mut settings.task_defaults;
+            defaults.keep_pinned = keep_pinned.unwrap_or(defaults.keep_pinned);
+            defaults.keep_first_message = keep_first.unwrap_or(defaults.keep_first_message);
+            defaults.show_in_topic = show_in_topic.unwrap_or(defaults.show_in_topic);
+        })
+        .await?;
+    let settings = manager.guild_settings(guild_id).await;
+    let language = i18n::language(ctx).await;
+    let message = format!(
+        "{}\n\n{}",
+        i18n::text(language, "config.defaults_set", &[]),
+        config_overview(language, &settings)
+    );
+    reply::say(ctx, message).await
+}
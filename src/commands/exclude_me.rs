@@ -0,0 +1,51 @@
+//! A command letting members keep their own messages out of a channel's cleanups.
+//!
+//! Moderators decide per channel whether members may opt out, with
+//! `/autoclean opt_out`. Opt-outs are kept with the channel's task and are
+//! only honoured while the channel allows them.
+
+use crate::{i18n, Context, EuleError};
+use poise::{serenity_prelude::ChannelId, CreateReply};
+
+/// Keeps your messages in a channel from being deleted by its cleanups.
+///
+/// Only works in channels where moderators allow opting out. The reply is only
+/// shown to the member who asked.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `channel` - The channel whose cleanups should leave your messages alone.
+/// * `excluded` - Whether to opt out, or back in with `false`. Defaults to `true`.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) if the reply was sent, or an EuleError if there was an issue.
+#[poise::command(slash_command, prefix_command, guild_only)]
+pub async fn exclude_me(
+    ctx: Context<'_>,
+    #[description = "Channel with an autoclean task"] channel: ChannelId,
+    #[description = "Keep your messages (false to let them be cleaned again)"] excluded: Option<
+        bool,
+    >,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    let excluded = excluded.unwrap_or(true);
+    ctx.defer_ephemeral().await?;
+
+    let updated = ctx
+        .data()
+        .autoclean_manager
+        .set_opted_out(guild_id, channel, ctx.author().id, excluded)
+        .await?;
+    let key = match (updated, excluded) {
+        (false, _) => "exclude_me.not_allowed",
+        (true, true) => "exclude_me.excluded",
+        (true, false) => "exclude_me.included",
+    };
+    let message = i18n::tr(ctx, key, &[("channel", &format!("<#{}>", channel))]).await;
+    ctx.send(CreateReply::default().content(message).ephemeral(true))
+        .await?;
+
+    Ok(())
+}
@@ -0,0 +1,51 @@
+//! A command choosing the language the bot responds in on a server.
+//!
+//! Without a choice, each member gets responses in their own Discord language
+//! if it is translated. See [`crate::i18n`] for how responses are translated.
+
+use crate::{
+    commands::reply,
+    i18n::{self, Language},
+    Context, EuleError,
+};
+
+/// Sets the language of the bot's responses on this server.
+///
+/// The language also applies to warnings and other messages the bot posts by
+/// itself. Leaving out `language` lets each member's Discord language decide
+/// again.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `language` - The language to respond in.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) if the language was changed, or an EuleError if
+/// it couldn't be saved.
+#[poise::command(
+    slash_command,
+    prefix_command,
+    guild_only,
+    required_permissions = "MANAGE_GUILD"
+)]
+pub async fn language(
+    ctx: Context<'_>,
+    #[description = "Language to respond in; leave out to follow each member's language"]
+    language: Option<Language>,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    ctx.data()
+        .autoclean_manager
+        .update_guild_settings(guild_id, |settings| settings.language = language)
+        .await?;
+    let key = match language {
+        Some(_) => "language.set",
+        None => "language.reset",
+    };
+    let message = i18n::tr(ctx, key, &[]).await;
+    reply::say(ctx, message).await
+}
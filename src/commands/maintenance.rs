@@ -0,0 +1,104 @@
+//! Maintenance mode, during which the bot keeps its hands off its tasks.
+//!
+//! While the bot is in maintenance mode, such as during a store migration or
+//! an incident, the scheduler starts no cleanups and commands that would
+//! change tasks or delete messages are turned away with a notice. Commands that
+//! only look at tasks keep working.
+
+use crate::{i18n, lifecycle, Context, EuleError};
+use poise::CreateReply;
+
+/// Top-level commands that change tasks or delete messages.
+const SCHEDULING_COMMANDS: &[&str] = &[
+    "autoclean",
+    "clean",
+    "exclude_me",
+    "policy",
+    "purge",
+    "setup",
+];
+
+/// Subcommands of those that only look at tasks, and keep working.
+const READ_ONLY_COMMANDS: &[&str] = &[
+    "autoclean list",
+    "autoclean calendar",
+    "autoclean workers",
+    "policy list",
+    "policy report",
+    "purge estimate",
+    "purge top",
+];
+
+/// Returns whether a command is turned away during maintenance.
+///
+/// # Arguments
+///
+/// * `qualified_name` - The command's full name, like `autoclean add`.
+///
+/// # Examples
+///
+/// ```
+/// use eule::commands::maintenance::blocked_in_maintenance;
+///
+/// assert!(blocked_in_maintenance("autoclean add"));
+/// assert!(!blocked_in_maintenance("autoclean list"));
+/// assert!(!blocked_in_maintenance("status"));
+/// ```
+pub fn blocked_in_maintenance(qualified_name: &str) -> bool {
+    let root = qualified_name.split(' ').next().unwrap_or_default();
+    SCHEDULING_COMMANDS.contains(&root) && !READ_ONLY_COMMANDS.contains(&qualified_name)
+}
+
+/// Lets a command run unless the bot is in maintenance mode and the command
+/// would change tasks, in which case the user is told why.
+///
+/// Used as the framework's command check.
+pub async fn outside_maintenance(ctx: Context<'_>) -> Result<bool, EuleError> {
+    if !lifecycle::in_maintenance() || !blocked_in_maintenance(&ctx.command().qualified_name) {
+        return Ok(true);
+    }
+    let message = i18n::tr(ctx, "maintenance.rejected", &[]).await;
+    ctx.send(CreateReply::default().content(message).ephemeral(true))
+        .await?;
+    Ok(false)
+}
+
+/// Turns maintenance mode on or off for every bot in the process.
+///
+/// Scheduled cleanups pause and commands that would change tasks are turned
+/// away until maintenance mode is turned off again, even across restarts.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `enabled` - Whether the bot should be in maintenance mode.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) if the mode was changed, or an EuleError if there was an issue.
+#[poise::command(slash_command, prefix_command, owners_only, hide_in_help)]
+pub async fn maintenance(
+    ctx: Context<'_>,
+    #[description = "Pause cleanups and turn away changes to tasks"] enabled: bool,
+) -> Result<(), EuleError> {
+    ctx.defer_ephemeral().await?;
+    tracing::info!(
+        "{} turned maintenance mode {}",
+        ctx.author().id,
+        if enabled { "on" } else { "off" }
+    );
+
+    lifecycle::save_maintenance(&ctx.data().kv_store, enabled).await?;
+    if !enabled {
+        // Cleanups that fell due meanwhile shouldn't wait for the next tick
+        ctx.data().autoclean_manager.wake();
+    }
+    let key = match enabled {
+        true => "maintenance.on",
+        false => "maintenance.off",
+    };
+    let message = i18n::tr(ctx, key, &[]).await;
+    ctx.send(CreateReply::default().content(message).ephemeral(true))
+        .await?;
+    Ok(())
+}
@@ -1,7 +1,29 @@
 pub mod autoclean;
+pub mod automod;
 pub mod clean;
+pub mod config;
+pub mod exclude_me;
+pub mod language;
+pub mod maintenance;
+pub mod owner;
+pub mod paginate;
+pub mod policy;
+pub mod purge;
+pub mod reply;
+pub mod setup;
 pub mod status;
+pub mod sync;
+pub mod usage;
 
 pub use autoclean::autoclean;
+pub use automod::automod;
 pub use clean::clean;
+pub use config::config;
+pub use exclude_me::exclude_me;
+pub use language::language;
+pub use maintenance::maintenance;
+pub use owner::{debug, reload, restart, shutdown};
+pub use policy::policy;
+pub use purge::purge;
+pub use setup::setup;
 pub use status::status;
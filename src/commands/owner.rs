@@ -0,0 +1,156 @@
+//! Commands for the bot's owners to stop, restart, reload or inspect it from
+//! Discord.
+//!
+//! Stopping and restarting let running purges finish, saving the tasks
+//! afterwards, before the bot disconnects, so routine maintenance needs no
+//! shell access to the host. The commands are limited to the owners of the
+//! bot's application, or the members of its team.
+
+use crate::{
+    commands::sync::sync_commands, i18n, lifecycle, tasks::state_dump::state_dump, Context,
+    EuleError,
+};
+use poise::{serenity_prelude::CreateAttachment, CreateReply};
+use tokio::time::Duration;
+
+/// How long running purges get to finish before they are cancelled.
+pub const DRAIN_TIMEOUT: Duration = Duration::from_secs(5 * 60);
+
+/// Stops the bot once its running purges have finished.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) once the bot is stopping, or an EuleError if there was an issue.
+#[poise::command(slash_command, prefix_command, owners_only, hide_in_help)]
+pub async fn shutdown(ctx: Context<'_>) -> Result<(), EuleError> {
+    stop(ctx, "owner.shutting_down").await
+}
+
+/// Restarts the bot once its running purges have finished.
+///
+/// The process replaces itself with a fresh copy of its executable, started
+/// with the same arguments, so a new build or configuration is picked up.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) once the bot is stopping, or an EuleError if there was an issue.
+#[poise::command(slash_command, prefix_command, owners_only, hide_in_help)]
+pub async fn restart(ctx: Context<'_>) -> Result<(), EuleError> {
+    lifecycle::request_restart();
+    stop(ctx, "owner.restarting").await
+}
+
+/// Reads the configuration file again and re-registers the bot's commands,
+/// without restarting.
+///
+/// Settings that are only read on startup, such as gateway intents, keep their
+/// old values until the next restart. A configuration file that fails to load
+/// leaves the current configuration in place.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) if the reply was sent, or an EuleError if there was an issue.
+#[poise::command(slash_command, prefix_command, owners_only, hide_in_help)]
+pub async fn reload(ctx: Context<'_>) -> Result<(), EuleError> {
+    ctx.defer_ephemeral().await?;
+    tracing::info!("{} asked the bot to reload", ctx.author().id);
+
+    let bot = &ctx.data().bot;
+    let message = match bot.reload_config() {
+        Ok(()) => {
+            let commands =
+                poise::builtins::create_application_commands(&ctx.framework().options().commands);
+            let plan =
+                sync_commands(&ctx.serenity_context().http, bot.dev_guild(), commands).await?;
+            i18n::tr(
+                ctx,
+                "owner.reloaded",
+                &[
+                    ("created", &plan.create.len().to_string()),
+                    ("updated", &plan.update.len().to_string()),
+                    ("deleted", &plan.delete.len().to_string()),
+                ],
+            )
+            .await
+        }
+        Err(e) => {
+            tracing::warn!("Failed to reload the configuration: {}", e);
+            i18n::tr(ctx, "owner.reload_failed", &[]).await
+        }
+    };
+    ctx.send(CreateReply::default().content(message).ephemeral(true))
+        .await?;
+    Ok(())
+}
+
+/// Sends a snapshot of the bot's internal state as a JSON file: every task,
+/// the scheduler's queue, running purges and their progress, recent errors,
+/// rate limiting and how often each command was used and failed.
+///
+/// The reply is only shown to the owner who asked, since it lists every
+/// guild's channels.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) if the snapshot was sent, or an EuleError if there was an issue.
+#[poise::command(slash_command, prefix_command, owners_only, hide_in_help)]
+pub async fn debug(ctx: Context<'_>) -> Result<(), EuleError> {
+    ctx.defer_ephemeral().await?;
+
+    let data = ctx.data();
+    let dump = state_dump(&data.autoclean_manager, data.bot.api().simulates_deletes()).await;
+    let json = serde_json::to_vec_pretty(&dump).map_err(EuleError::Serialization)?;
+    let summary = i18n::tr(
+        ctx,
+        "owner.debug",
+        &[
+            ("guilds", &dump.guilds.len().to_string()),
+            ("running", &dump.running.len().to_string()),
+            ("queued", &dump.queue.len().to_string()),
+        ],
+    )
+    .await;
+    ctx.send(
+        CreateReply::default()
+            .content(summary)
+            .attachment(CreateAttachment::bytes(json, "eule-state.json"))
+            .ephemeral(true),
+    )
+    .await?;
+    Ok(())
+}
+
+/// Tells the owner the bot is stopping, waits for running purges and
+/// disconnects every shard.
+async fn stop(ctx: Context<'_>, key: &str) -> Result<(), EuleError> {
+    let manager = &ctx.data().autoclean_manager;
+    let running = manager.running_purges().await;
+    tracing::info!(
+        "{} asked the bot to stop with {} purges running",
+        ctx.author().id,
+        running
+    );
+    let message = i18n::tr(ctx, key, &[("running", &running.to_string())]).await;
+    ctx.send(CreateReply::default().content(message).ephemeral(true))
+        .await?;
+
+    manager.drain(DRAIN_TIMEOUT).await?;
+    ctx.framework().shard_manager().shutdown_all().await;
+    Ok(())
+}
@@ -0,0 +1,326 @@
+//! Paginated embed replies.
+//!
+//! Listings are rendered as embeds with one field per entry. When there are
+//! more entries than fit on one page, previous/next buttons let the invoking
+//! user flip through the pages until the buttons time out.
+
+use crate::{
+    commands::{autoclean::format_labels, reply},
+    i18n::{self, Language},
+    purge::DeletionOrder,
+    tasks::{CleanupTask, TaskState},
+    utils::{discord_time, humanize},
+    Context, EuleError,
+};
+use poise::{
+    serenity_prelude::{
+        ButtonStyle, ComponentInteractionCollector, CreateActionRow, CreateButton, CreateEmbed,
+        CreateEmbedFooter, CreateInteractionResponse, CreateInteractionResponseMessage,
+    },
+    CreateReply,
+};
+use tokio::time::Duration;
+
+/// The maximum number of fields shown on one page.
+pub const FIELDS_PER_PAGE: usize = 10;
+
+/// The most characters Discord accepts in a field's name.
+pub const MAX_FIELD_NAME: usize = 256;
+
+/// The most characters Discord accepts in a field's value.
+pub const MAX_FIELD_VALUE: usize = 1024;
+
+/// The most characters the fields of one page take up together. Discord
+/// rejects embeds over 6000 characters, so this leaves room for the title,
+/// description and footer.
+pub const MAX_PAGE_CHARS: usize = 4000;
+
+/// The most characters of one line of a task's description, so that a long
+/// list of keywords or labels leaves room for the rest.
+pub const MAX_LINE_CHARS: usize = 200;
+
+/// How long the page buttons keep working after the last press.
+const PAGE_TIMEOUT: Duration = Duration::from_secs(300);
+
+/// A field of a paginated embed, as a name and a value.
+pub type Field = (String, String);
+
+/// Shortens text to at most `max` characters, ending it with an ellipsis if
+/// anything was cut.
+///
+/// # Examples
+///
+/// ```
+/// use eule::commands::paginate::truncate;
+///
+/// assert_eq!(truncate("keywords", 20), "keywords");
+/// assert_eq!(truncate("keywords", 5), "keyw…");
+/// ```
+pub fn truncate(text: &str, max: usize) -> String {
+    if text.chars().count() <= max {
+        return text.to_string();
+    }
+    let mut truncated: String = text.chars().take(max.saturating_sub(1)).collect();
+    truncated.push('…');
+    truncated
+}
+
+/// Splits fields into pages of at most `per_page` fields and at most
+/// `MAX_PAGE_CHARS` characters, cutting names and values that are longer
+/// than Discord allows.
+///
+/// Always returns at least one page, which is empty if there are no fields.
+///
+/// # Arguments
+///
+/// * `fields` - The fields to split.
+/// * `per_page` - The maximum number of fields per page.
+pub fn paginate_fields(fields: Vec<Field>, per_page: usize) -> Vec<Vec<Field>> {
+    let mut pages: Vec<Vec<Field>> = vec![Vec::new()];
+    let mut page_chars = 0;
+    for (name, value) in fields {
+        let field = (
+            truncate(&name, MAX_FIELD_NAME),
+            truncate(&value, MAX_FIELD_VALUE),
+        );
+        let chars = field.0.chars().count() + field.1.chars().count();
+        let filled = pages.last().map_or(0, Vec::len);
+        if filled > 0 && (filled >= per_page.max(1) || page_chars + chars > MAX_PAGE_CHARS) {
+            pages.push(Vec::new());
+            page_chars = 0;
+        }
+        page_chars += chars;
+        if let Some(page) = pages.last_mut() {
+            page.push(field);
+        }
+    }
+    pages
+}
+
+/// Describes a cleanup task as an embed field.
+///
+/// Every line is cut to `MAX_LINE_CHARS`, and the value to `MAX_FIELD_VALUE`.
+/// The lines come from the `task` section of the locale files, so new task
+/// settings add a key there rather than an English literal here.
+///
+/// # Arguments
+///
+/// * `language` - The language to describe the task in.
+/// * `channel_id` - The channel the task cleans.
+/// * `task` - The task itself.
+/// * `state` - The state the task is in, from `AutocleanManager::task_state`.
+pub fn task_field(
+    language: Language,
+    channel_id: impl std::fmt::Display,
+    task: &CleanupTask,
+    state: TaskState,
+) -> Field {
+    let text = |key: &str, values: &[(&str, &str)]| i18n::text(language, key, values);
+    let name = match (&task.forum, &task.threads) {
+        (Some(_), _) => text("task.forum", &[]),
+        (None, Some(_)) => text("task.threads", &[]),
+        (None, None) => text(
+            "task.every",
+            &[("interval", &humanize::duration(task.interval))],
+        ),
+    };
+    let mut lines = vec![
+        format!("<#{}>", channel_id),
+        text(
+            "task.last_run",
+            &[("time", &discord_time::relative(task.last_cleanup))],
+        ),
+        text(
+            "task.next_run",
+            &[("time", &discord_time::relative(task.next_cleanup()))],
+        ),
+        text("task.state", &[("state", state.describe(language))]),
+    ];
+    if task.include_threads {
+        lines.push(text("task.include_threads", &[]));
+    }
+    if task.keep_pinned {
+        lines.push(text("task.keep_pinned", &[]));
+    }
+    if let Some(age) = task.keep_newer_than {
+        lines.push(text(
+            "task.keep_newer_than",
+            &[("age", &humanize::duration(age))],
+        ));
+    }
+    if task.keep_first_message {
+        lines.push(text("task.keep_first", &[]));
+    }
+    if !task.keep_keywords.is_empty() {
+        lines.push(text(
+            "task.keep_keywords",
+            &[("keywords", &task.keep_keywords.join(", "))],
+        ));
+    }
+    if let Some(seconds) = task.slowmode {
+        lines.push(text("task.slowmode", &[("seconds", &seconds.to_string())]));
+    }
+    if task.lock_channel {
+        lines.push(text("task.lock", &[]));
+    }
+    if let Some(delay) = task.old_message_delay {
+        lines.push(text(
+            "task.old_message_delay",
+            &[("delay", &delay.as_millis().to_string())],
+        ));
+    }
+    if !task.labels.is_empty() {
+        lines.push(text(
+            "task.labels",
+            &[("labels", &format_labels(&task.labels))],
+        ));
+    }
+    if task.deletion_order == DeletionOrder::OldestFirst {
+        lines.push(text("task.oldest_first", &[]));
+    }
+    if let Some(max) = task.max_deletions {
+        lines.push(text(
+            "task.max_deletions",
+            &[("count", &humanize::count(u64::from(max)))],
+        ));
+    }
+    if let Some(target) = task.summary {
+        lines.push(text(
+            "task.summary",
+            &[("channel", &format!("<#{}>", target))],
+        ));
+    }
+    if task.nuke {
+        lines.push(text("task.nuke", &[]));
+    }
+    if let Some(policy) = &task.policy {
+        lines.push(text("task.policy", &[("policy", policy.as_str())]));
+    }
+    if let Some(threshold) = task.message_trigger {
+        lines.push(text(
+            "task.message_trigger",
+            &[("count", &threshold.to_string())],
+        ));
+    }
+    if let Some(expires) = task.expires {
+        lines.push(text(
+            "task.expires",
+            &[("time", &discord_time::relative(expires))],
+        ));
+    }
+    if let Some(user) = task.created_by {
+        let key = if task.notify_creator {
+            "task.created_by_notified"
+        } else {
+            "task.created_by"
+        };
+        lines.push(text(key, &[("user", &format!("<@{}>", user))]));
+    }
+    if let (Some(user), Some(at)) = (task.edited_by, task.edited_at) {
+        lines.push(text(
+            "task.edited_by",
+            &[
+                ("user", &format!("<@{}>", user)),
+                ("time", &discord_time::relative(at)),
+            ],
+        ));
+    }
+    let value = lines
+        .iter()
+        .map(|line| truncate(line, MAX_LINE_CHARS))
+        .collect::<Vec<_>>()
+        .join("\n");
+    (name, truncate(&value, MAX_FIELD_VALUE))
+}
+
+/// Sends embeds as a single reply, with page buttons if there is more than one.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `pages` - The embeds to show, one per page. Must not be empty.
+pub async fn send_paginated(ctx: Context<'_>, pages: Vec<CreateEmbed>) -> Result<(), EuleError> {
+    let page_count = pages.len();
+    if page_count <= 1 {
+        let embed = pages.into_iter().next().unwrap_or_default();
+        return reply::send(ctx, CreateReply::default().embed(embed)).await;
+    }
+
+    let prefix = ctx.id().to_string();
+    let prev_id = format!("{}_prev", prefix);
+    let next_id = format!("{}_next", prefix);
+    let language = i18n::language(ctx).await;
+    let page = |index: usize| {
+        pages[index]
+            .clone()
+            .footer(CreateEmbedFooter::new(i18n::text(
+                language,
+                "task.page",
+                &[
+                    ("page", &(index + 1).to_string()),
+                    ("pages", &page_count.to_string()),
+                ],
+            )))
+    };
+    let buttons = |index: usize| {
+        vec![CreateActionRow::Buttons(vec![
+            CreateButton::new(prev_id.clone())
+                .label("◀")
+                .style(ButtonStyle::Secondary)
+                .disabled(index == 0),
+            CreateButton::new(next_id.clone())
+                .label("▶")
+                .style(ButtonStyle::Secondary)
+                .disabled(index + 1 == page_count),
+        ])]
+    };
+
+    let mut current = 0;
+    let handle = ctx
+        .send(
+            CreateReply::default()
+                .embed(page(current))
+                .components(buttons(current)),
+        )
+        .await?;
+
+    loop {
+        let filter_prefix = prefix.clone();
+        let Some(press) = ComponentInteractionCollector::new(ctx.serenity_context())
+            .author_id(ctx.author().id)
+            .filter(move |press| press.data.custom_id.starts_with(&filter_prefix))
+            .timeout(PAGE_TIMEOUT)
+            .await
+        else {
+            break;
+        };
+
+        current = if press.data.custom_id == next_id {
+            (current + 1).min(page_count - 1)
+        } else {
+            current.saturating_sub(1)
+        };
+        press
+            .create_response(
+                ctx,
+                CreateInteractionResponse::UpdateMessage(
+                    CreateInteractionResponseMessage::new()
+                        .embed(page(current))
+                        .components(buttons(current)),
+                ),
+            )
+            .await?;
+    }
+
+    // Remove the buttons once they stop working
+    handle
+        .edit(
+            ctx,
+            CreateReply::default()
+                .embed(page(current))
+                .components(Vec::new()),
+        )
+        .await?;
+    // The reply only starts counting down once nobody can page through it anymore
+    reply::expire(ctx, &handle).await
+}
@@ -0,0 +1,660 @@
+//! Commands for managing a server's named retention policies.
+//!
+//! A named policy holds a task's settings under a name such as `spam-channel`.
+//! Channels that follow it get its settings, and changing the policy changes
+//! every one of them. Patterns such as `temp-*` apply a policy to every channel
+//! whose name matches, including channels created or renamed later, and a
+//! category's default policy applies to channels created in it. A report
+//! lists every channel with the retention it ends up with. Tasks can also be
+//! moved onto a policy by their label. All commands in this module require
+//! the `MANAGE_MESSAGES` permission, and the report `MANAGE_GUILD` as well.
+
+use crate::{
+    commands::{
+        config::{may_manage_task, may_manage_tasks},
+        paginate::{paginate_fields, send_paginated, FIELDS_PER_PAGE},
+        reply,
+    },
+    i18n,
+    purge::ChannelSupport,
+    tasks::{
+        policy::{
+            format_interval, parse_interval, retention_csv, retention_report, PatternOutcome,
+            Policy, Retention, MAX_PATTERN_LENGTH,
+        },
+        CleanupTask,
+    },
+    Context, EuleError,
+};
+use poise::{
+    serenity_prelude::{ChannelId, CreateAttachment, CreateEmbed, GuildChannel, GuildId},
+    CreateReply,
+};
+use std::collections::HashSet;
+
+/// The longest name a policy may have.
+pub const MAX_NAME_LENGTH: usize = 32;
+
+/// Checks whether `name` can name a policy: 1 to 32 lowercase letters, digits,
+/// dashes or underscores.
+///
+/// # Examples
+///
+/// ```
+/// use eule::commands::policy::valid_name;
+///
+/// assert!(valid_name("spam-channel"));
+/// assert!(!valid_name("Spam Channel"));
+/// assert!(!valid_name(""));
+/// ```
+pub fn valid_name(name: &str) -> bool {
+    (1..=MAX_NAME_LENGTH).contains(&name.len())
+        && name
+            .chars()
+            .all(|c| c.is_ascii_lowercase() || c.is_ascii_digit() || c == '-' || c == '_')
+}
+
+/// Describes a policy's settings, one per line.
+pub fn describe(policy: &Policy) -> String {
+    let mut value = format!("Every {}", format_interval(policy.interval));
+    if policy.include_threads {
+        value.push_str("\nIncludes threads");
+    }
+    if policy.keep_pinned {
+        value.push_str("\nKeeps pinned messages");
+    }
+    if let Some(age) = policy.keep_newer_than {
+        value.push_str(&format!("\nKeeps the last {}", format_interval(age)));
+    }
+    if policy.keep_first_message {
+        value.push_str("\nKeeps the first message");
+    }
+    if policy.lock_channel {
+        value.push_str("\nLocked while cleaning");
+    }
+    value
+}
+
+/// Manages named retention policies.
+#[poise::command(
+    slash_command,
+    prefix_command,
+    guild_only,
+    subcommands(
+        "set",
+        "apply",
+        "apply_label",
+        "pattern",
+        "category",
+        "remove",
+        "list",
+        "report"
+    ),
+    required_permissions = "MANAGE_MESSAGES"
+)]
+pub async fn policy(_: Context<'_>) -> Result<(), EuleError> {
+    Ok(())
+}
+
+/// Creates or changes a named policy.
+///
+/// Channels that already follow the policy get the new settings right away.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `name` - The policy's name.
+/// * `interval` - The interval between cleanups, like `6h` or `7d`.
+/// * `keep_pinned` - Whether to leave pinned messages in place.
+/// * `keep_newer_than` - Leave messages younger than this in place, like `30d`.
+/// * `include_threads` - Whether to also clean the channel's threads.
+/// * `keep_first_message` - Whether to leave the channel's oldest message in place.
+/// * `lock_channel` - Whether to deny @everyone Send Messages during cleanups.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) if the policy was saved, or an EuleError if
+/// there was an issue.
+#[poise::command(slash_command, prefix_command)]
+#[allow(clippy::too_many_arguments)]
+pub async fn set(
+    ctx: Context<'_>,
+    #[description = "Name of the policy, like spam-channel"] name: String,
+    #[description = "Interval between cleanups, like 30m, 6h, 7d or 2w"] interval: String,
+    #[description = "Leave pinned messages in place"] keep_pinned: Option<bool>,
+    #[description = "Leave messages younger than this in place, like 30d"] keep_newer_than: Option<
+        String,
+    >,
+    #[description = "Also clean active and archived threads in the channel"]
+    include_threads: Option<bool>,
+    #[description = "Leave the channel's oldest message in place"] keep_first_message: Option<bool>,
+    #[description = "Stop members from posting while a cleanup runs"] lock_channel: Option<bool>,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    let language = i18n::language(ctx).await;
+    if !valid_name(&name) {
+        let message = i18n::text(language, "policy.invalid_name", &[("name", &name)]);
+        return reply::say(ctx, message).await;
+    }
+    let Some(interval) = parse_interval(&interval) else {
+        let message = i18n::text(language, "policy.invalid_interval", &[("value", &interval)]);
+        return reply::say(ctx, message).await;
+    };
+    let keep_newer_than = match keep_newer_than {
+        Some(text) => match parse_interval(&text) {
+            Some(age) => Some(age),
+            None => {
+                let message = i18n::text(language, "policy.invalid_interval", &[("value", &text)]);
+                return reply::say(ctx, message).await;
+            }
+        },
+        None => None,
+    };
+
+    let followers = policy_channels(ctx, guild_id, |task| {
+        task.policy.as_deref() == Some(name.as_str())
+    })
+    .await;
+    if !may_manage_tasks(ctx, guild_id, &followers).await? {
+        return Ok(());
+    }
+
+    let mut policy = Policy::new(interval);
+    policy.keep_pinned = keep_pinned.unwrap_or(false);
+    policy.keep_newer_than = keep_newer_than;
+    policy.include_threads = include_threads.unwrap_or(false);
+    policy.keep_first_message = keep_first_message.unwrap_or(false);
+    policy.lock_channel = lock_channel.unwrap_or(false);
+    let count = ctx
+        .data()
+        .autoclean_manager
+        .set_policy(guild_id, &name, policy)
+        .await?;
+    let message = i18n::text(
+        language,
+        "policy.saved",
+        &[("name", &name), ("count", &count.to_string())],
+    );
+    reply::say(ctx, message).await
+}
+
+/// Returns the channels whose tasks a policy command would change.
+async fn policy_channels(
+    ctx: Context<'_>,
+    guild_id: GuildId,
+    changed: impl Fn(&CleanupTask) -> bool,
+) -> Vec<ChannelId> {
+    ctx.data()
+        .autoclean_manager
+        .guild_tasks(guild_id)
+        .await
+        .into_iter()
+        .filter(|(_, task)| changed(task))
+        .map(|(channel_id, _)| channel_id)
+        .collect()
+}
+
+/// Makes a channel follow a named policy.
+///
+/// The channel gets a cleanup task if it has none. Threads are only cleaned in
+/// channels that can have them.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `name` - The policy's name.
+/// * `channel` - The channel to apply it to.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) if the policy was applied, or an EuleError if
+/// there was an issue.
+#[poise::command(slash_command, prefix_command)]
+pub async fn apply(
+    ctx: Context<'_>,
+    #[description = "Name of the policy"] name: String,
+    #[description = "Channel, thread, or voice channel chat to apply it to"]
+    #[channel_types(
+        "Text",
+        "News",
+        "Voice",
+        "Stage",
+        "PublicThread",
+        "PrivateThread",
+        "NewsThread"
+    )]
+    channel: GuildChannel,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    if !may_manage_task(ctx, guild_id, channel.id).await? {
+        return Ok(());
+    }
+
+    let language = i18n::language(ctx).await;
+    let mention = format!("<#{}>", channel.id);
+    let ChannelSupport::Messages { threads } = ChannelSupport::of(channel.kind) else {
+        let message = i18n::text(language, "autoclean.no_messages", &[("channel", &mention)]);
+        return reply::say(ctx, message).await;
+    };
+
+    let manager = &ctx.data().autoclean_manager;
+    if !manager
+        .apply_named_policy(guild_id, channel.id, &name)
+        .await?
+    {
+        let message = i18n::text(language, "policy.unknown", &[("name", &name)]);
+        return reply::say(ctx, message).await;
+    }
+    if !threads {
+        manager
+            .set_include_threads(guild_id, channel.id, false)
+            .await?;
+    }
+    manager
+        .record_editor(guild_id, channel.id, ctx.author().id)
+        .await?;
+    let message = i18n::text(
+        language,
+        "policy.applied",
+        &[("name", &name), ("channel", &mention)],
+    );
+    reply::say(ctx, message).await
+}
+
+/// Makes every channel whose task has a label follow a named policy, such as
+/// all tasks labelled `event`.
+///
+/// Threads are only cleaned in channels that can have them.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `name` - The policy's name.
+/// * `label` - The label of the tasks to apply it to.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) if the policy was applied, or an EuleError if
+/// there was an issue.
+#[poise::command(slash_command, prefix_command)]
+pub async fn apply_label(
+    ctx: Context<'_>,
+    #[description = "Name of the policy"] name: String,
+    #[description = "Label of the tasks to apply it to"] label: String,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    let labelled =
+        policy_channels(ctx, guild_id, |task| task.has_label(Some(label.as_str()))).await;
+    if !may_manage_tasks(ctx, guild_id, &labelled).await? {
+        return Ok(());
+    }
+
+    let threaded: HashSet<ChannelId> = guild_id
+        .channels(ctx)
+        .await?
+        .into_iter()
+        .filter(|(_, channel)| {
+            ChannelSupport::of(channel.kind) == ChannelSupport::Messages { threads: true }
+        })
+        .map(|(channel_id, _)| channel_id)
+        .collect();
+    let applied = ctx
+        .data()
+        .autoclean_manager
+        .apply_named_policy_to_label(guild_id, &label, &name, &threaded)
+        .await?;
+    let label = format!("`{}`", label.trim().to_lowercase());
+    let message = match applied {
+        None => i18n::tr(ctx, "policy.unknown", &[("name", &name)]).await,
+        Some(channels) if channels.is_empty() => {
+            i18n::tr(ctx, "autoclean.no_labelled_tasks", &[("label", &label)]).await
+        }
+        Some(channels) => {
+            i18n::tr(
+                ctx,
+                "policy.applied_label",
+                &[
+                    ("name", &name),
+                    ("label", &label),
+                    ("count", &channels.len().to_string()),
+                ],
+            )
+            .await
+        }
+    };
+    reply::say(ctx, message).await
+}
+
+/// Applies a named policy to every channel whose name matches a pattern.
+///
+/// Matching channels that have no task yet follow the policy right away, as do
+/// channels created or renamed to match later. Their tasks are removed again
+/// when they are renamed so they no longer match. Channels with a task set up
+/// by hand are left alone.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `pattern` - The channel name pattern, like `temp-*` or `*-spam`.
+/// * `name` - The policy's name, or `None` to stop applying the pattern.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) if the pattern was saved, or an EuleError if
+/// there was an issue.
+#[poise::command(slash_command, prefix_command)]
+pub async fn pattern(
+    ctx: Context<'_>,
+    #[description = "Channel name pattern, like temp-* or *-spam"]
+    #[max_length = 100]
+    pattern: String,
+    #[description = "Name of the policy; leave out to stop applying the pattern"] name: Option<
+        String,
+    >,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    let language = i18n::language(ctx).await;
+    let pattern = pattern.trim().to_lowercase();
+    if pattern.is_empty() || pattern.len() > MAX_PATTERN_LENGTH {
+        let message = i18n::text(language, "policy.invalid_pattern", &[("pattern", &pattern)]);
+        return reply::say(ctx, message).await;
+    }
+    let manager = &ctx.data().autoclean_manager;
+    if let Some(name) = &name {
+        if !manager
+            .guild_settings(guild_id)
+            .await
+            .policies
+            .contains_key(name)
+        {
+            let message = i18n::text(language, "policy.unknown", &[("name", name)]);
+            return reply::say(ctx, message).await;
+        }
+    }
+
+    manager
+        .update_guild_settings(guild_id, |settings| match &name {
+            Some(name) => {
+                settings.patterns.insert(pattern.clone(), name.clone());
+            }
+            None => {
+                settings.patterns.remove(&pattern);
+            }
+        })
+        .await?;
+    let (followed, removed) = match_channels(ctx, guild_id).await?;
+    let message = match &name {
+        Some(name) => i18n::text(
+            language,
+            "policy.pattern_set",
+            &[
+                ("pattern", &pattern),
+                ("name", name),
+                ("count", &followed.to_string()),
+            ],
+        ),
+        None => i18n::text(
+            language,
+            "policy.pattern_removed",
+            &[("pattern", &pattern), ("count", &removed.to_string())],
+        ),
+    };
+    reply::say(ctx, message).await
+}
+
+/// Makes channels created in a category follow a named policy.
+///
+/// Only channels created from now on are scheduled, and only if no name
+/// pattern already gave them a task. Each one is announced in the server's log
+/// channel, set with `/autoclean log`.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `category` - The category.
+/// * `name` - The policy's name, or `None` to stop applying a default.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) if the default was saved, or an EuleError if
+/// there was an issue.
+#[poise::command(slash_command, prefix_command)]
+pub async fn category(
+    ctx: Context<'_>,
+    #[description = "Category whose new channels follow the policy"]
+    #[channel_types("Category")]
+    category: ChannelId,
+    #[description = "Name of the policy; leave out to stop applying a default"] name: Option<
+        String,
+    >,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    let language = i18n::language(ctx).await;
+    let manager = &ctx.data().autoclean_manager;
+    if let Some(name) = &name {
+        if !manager
+            .guild_settings(guild_id)
+            .await
+            .policies
+            .contains_key(name)
+        {
+            let message = i18n::text(language, "policy.unknown", &[("name", name)]);
+            return reply::say(ctx, message).await;
+        }
+    }
+
+    manager
+        .update_guild_settings(guild_id, |settings| match &name {
+            Some(name) => {
+                settings.category_policies.insert(category, name.clone());
+            }
+            None => {
+                settings.category_policies.remove(&category);
+            }
+        })
+        .await?;
+    let mention = format!("<#{}>", category);
+    let message = match &name {
+        Some(name) => i18n::text(
+            language,
+            "policy.category_set",
+            &[("category", &mention), ("name", name)],
+        ),
+        None => i18n::text(
+            language,
+            "policy.category_removed",
+            &[("category", &mention)],
+        ),
+    };
+    reply::say(ctx, message).await
+}
+
+/// Matches every channel of a guild against its patterns.
+///
+/// # Returns
+///
+/// How many channels started following a policy and how many automatic
+/// tasks were removed.
+async fn match_channels(ctx: Context<'_>, guild_id: GuildId) -> Result<(usize, usize), EuleError> {
+    let manager = &ctx.data().autoclean_manager;
+    let mut followed = 0;
+    let mut removed = 0;
+    for (channel_id, channel) in guild_id.channels(ctx).await? {
+        match manager
+            .match_channel_name(guild_id, channel_id, &channel.name, channel.kind)
+            .await?
+        {
+            PatternOutcome::Followed(_) => followed += 1,
+            PatternOutcome::Removed => removed += 1,
+            PatternOutcome::Unchanged => {}
+        }
+    }
+    Ok((followed, removed))
+}
+
+/// Removes a named policy.
+///
+/// Channels that followed it keep their current settings.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `name` - The policy's name.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) if the policy was removed or didn't exist, or an
+/// EuleError if there was an issue.
+#[poise::command(slash_command, prefix_command)]
+pub async fn remove(
+    ctx: Context<'_>,
+    #[description = "Name of the policy"] name: String,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    let removed = ctx
+        .data()
+        .autoclean_manager
+        .remove_policy(guild_id, &name)
+        .await?;
+    let key = if removed {
+        "policy.removed"
+    } else {
+        "policy.unknown"
+    };
+    let message = i18n::tr(ctx, key, &[("name", &name)]).await;
+    reply::say(ctx, message).await
+}
+
+/// Lists this server's named policies.
+#[poise::command(slash_command, prefix_command)]
+pub async fn list(ctx: Context<'_>) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+
+    let settings = ctx.data().autoclean_manager.guild_settings(guild_id).await;
+    let policies = settings.policies;
+
+    let language = i18n::language(ctx).await;
+    if policies.is_empty() {
+        reply::say(ctx, i18n::text(language, "policy.none", &[])).await?;
+        return Ok(());
+    }
+
+    let fields = policies
+        .iter()
+        .map(|(name, policy)| {
+            let mut value = describe(policy);
+            for (pattern, _) in settings.patterns.iter().filter(|(_, n)| *n == name) {
+                value.push_str(&format!("\nApplies to channels named `{}`", pattern));
+            }
+            for (category, _) in settings
+                .category_policies
+                .iter()
+                .filter(|(_, n)| *n == name)
+            {
+                value.push_str(&format!("\nApplies to new channels in <#{}>", category));
+            }
+            (name.clone(), value)
+        })
+        .collect();
+    let pages = paginate_fields(fields, FIELDS_PER_PAGE)
+        .into_iter()
+        .map(|fields| {
+            CreateEmbed::new()
+                .title(i18n::text(language, "policy.list_title", &[]))
+                .fields(fields.into_iter().map(|(name, value)| (name, value, false)))
+        })
+        .collect();
+    send_paginated(ctx, pages).await
+}
+
+/// Sends a report of every channel in this server and its retention: the
+/// policy it follows, its own settings, or none at all. Channels without
+/// retention are flagged and listed first.
+///
+/// The report is a CSV file only shown to the member who asked, for servers
+/// with formal data retention rules. It requires the `MANAGE_GUILD` permission.
+/// Given a label, it only covers the channels whose task has that label.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `label` - Only reports the tasks with this label, if given.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) if the report was sent, or an EuleError if
+/// there was an issue.
+#[poise::command(slash_command, prefix_command, required_permissions = "MANAGE_GUILD")]
+pub async fn report(
+    ctx: Context<'_>,
+    #[description = "Only report tasks with this label"] label: Option<String>,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    ctx.defer_ephemeral().await?;
+
+    let tasks: Vec<_> = ctx
+        .data()
+        .autoclean_manager
+        .guild_tasks(guild_id)
+        .await
+        .into_iter()
+        .filter(|(_, task)| task.has_label(label.as_deref()))
+        .collect();
+    let channels = guild_id
+        .channels(ctx)
+        .await?
+        .into_iter()
+        .filter(|(channel_id, _)| label.is_none() || tasks.iter().any(|(id, _)| id == channel_id))
+        .map(|(channel_id, channel)| (channel_id, channel.name, channel.kind));
+    let rows = retention_report(channels, &tasks);
+
+    let count = |retention: fn(&Retention) -> bool| {
+        rows.iter()
+            .filter(|row| retention(&row.retention))
+            .count()
+            .to_string()
+    };
+    let message = i18n::tr(
+        ctx,
+        "policy.report",
+        &[
+            ("channels", &rows.len().to_string()),
+            (
+                "policies",
+                &count(|retention| matches!(retention, Retention::Policy(_))),
+            ),
+            (
+                "custom",
+                &count(|retention| *retention == Retention::Custom),
+            ),
+            (
+                "flagged",
+                &count(|retention| *retention == Retention::Unlimited),
+            ),
+        ],
+    )
+    .await;
+    ctx.send(
+        CreateReply::default()
+            .content(message)
+            .attachment(CreateAttachment::bytes(
+                retention_csv(&rows),
+                "retention-report.csv",
+            ))
+            .ephemeral(true),
+    )
+    .await?;
+    Ok(())
+}
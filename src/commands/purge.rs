@@ -0,0 +1,1202 @@
+//! Commands for purging a channel on demand.
+//!
+//! `/purge now` empties a channel immediately instead of waiting for its
+//! schedule, `/purge between` and `/purge range` remove the messages between
+//! two messages or two dates, and `/purge abort` stops a purge that is in
+//! progress, whether it was started from a command or by the schedule.
+//! `/purge hold` keeps everything in a channel while a legal hold is in place.
+//! `/purge top` ranks channels by how much their cleanups delete, and
+//! `/purge apply` schedules many channels at once from an uploaded file.
+//! `/purge schedule` plans a single purge for a later date without setting up
+//! a recurring task, and `/purge event` plans one for when a scheduled event
+//! ends. `/purge estimate` tells how long purging a channel would take. All
+//! commands in this module require the `MANAGE_MESSAGES` permission, and
+//! `/purge hold` `MANAGE_GUILD` as well.
+
+use crate::{
+    commands::{
+        config::{may_manage_task, may_manage_tasks},
+        reply,
+    },
+    i18n::{self, Language},
+    purge::{
+        estimate_purge, purge_channel, CancelToken, ChannelSupport, MessageRange, PurgeEstimate,
+        PurgeOptions, PurgeReport, ESTIMATE_PAGES,
+    },
+    tasks::{
+        guild_settings::{render, EventPurge, MessageTemplates, TemplateKind},
+        policy::{format_interval, parse_bulk, parse_interval, BulkError, BulkFailure},
+    },
+    utils::{
+        discord_time, humanize,
+        serializable_instant::{from_local, parse_local, parse_utc},
+        SerializableInstant,
+    },
+    Context, EuleError,
+};
+use poise::{
+    serenity_prelude::{
+        Attachment, ButtonStyle, ChannelId, ComponentInteractionCollector, CreateActionRow,
+        CreateButton, CreateEmbed, CreateMessage, EditMessage, GuildChannel, GuildId, MessageId,
+        ScheduledEventId,
+    },
+    CreateReply,
+};
+use tokio::{
+    sync::watch,
+    time::{Duration, Instant},
+};
+
+/// Prefix of the cancel button's custom ID; the purged channel's ID is appended.
+const CANCEL_BUTTON: &str = "eule_purge_cancel";
+
+/// How often the control message is edited with the purge's progress.
+const PROGRESS_INTERVAL: Duration = Duration::from_secs(15);
+
+/// Formats the progress of a running purge for the control message.
+///
+/// # Arguments
+///
+/// * `templates` - The guild's message templates.
+/// * `language` - The language to respond in.
+/// * `channel_id` - The channel being purged.
+/// * `report` - The purge's running report.
+/// * `elapsed` - Time since the purge started.
+pub fn format_progress(
+    templates: &MessageTemplates,
+    language: Language,
+    channel_id: ChannelId,
+    report: &PurgeReport,
+    elapsed: Duration,
+) -> String {
+    let minutes = elapsed.as_secs_f64() / 60.0;
+    let rate = if minutes > 0.0 {
+        report.deleted as f64 / minutes
+    } else {
+        0.0
+    };
+    let pending = humanize::count(report.pending as u64);
+    let remaining = if rate > 0.0 && report.pending > 0 {
+        let eta = (report.pending as f64 / rate).ceil() as u64;
+        i18n::text(
+            language,
+            "purge.remaining_eta",
+            &[
+                ("count", &pending),
+                ("eta", &humanize::duration(Duration::from_secs(eta * 60))),
+            ],
+        )
+    } else {
+        i18n::text(language, "purge.remaining", &[("count", &pending)])
+    };
+    render(
+        templates.template(TemplateKind::Progress, language),
+        &[
+            ("channel", &format!("<#{}>", channel_id)),
+            ("count", &humanize::count(report.deleted as u64)),
+            ("remaining", &remaining),
+            ("rate", &humanize::count(rate.round() as u64)),
+        ],
+    )
+}
+
+/// Formats the summary of a purge that ran to the end.
+///
+/// # Arguments
+///
+/// * `templates` - The guild's message templates.
+/// * `language` - The language to respond in.
+/// * `channel_id` - The purged channel.
+/// * `report` - The purge's final report.
+/// * `next` - The channel's next scheduled cleanup, if it has a task.
+pub fn format_completion(
+    templates: &MessageTemplates,
+    language: Language,
+    channel_id: ChannelId,
+    report: &PurgeReport,
+    next: Option<SerializableInstant>,
+) -> String {
+    render(
+        templates.template(TemplateKind::Completion, language),
+        &[
+            ("channel", &format!("<#{}>", channel_id)),
+            ("count", &humanize::count(report.deleted as u64)),
+            (
+                "next_purge",
+                &next.map_or_else(
+                    || i18n::text(language, "template.not_scheduled", &[]),
+                    discord_time::relative,
+                ),
+            ),
+        ],
+    )
+}
+
+/// The largest file `/purge apply` reads, in bytes.
+const MAX_UPLOAD_BYTES: u32 = 256 * 1024;
+
+/// The most problems `/purge apply` lists before summing up the rest.
+const MAX_LISTED_FAILURES: usize = 15;
+
+/// Lists the problems that kept a bulk upload from being applied.
+///
+/// # Arguments
+///
+/// * `language` - The language to respond in.
+/// * `failures` - The entries that can't be applied.
+pub fn format_bulk_failures(language: Language, failures: &[BulkFailure]) -> String {
+    let mut lines = vec![i18n::text(
+        language,
+        "purge.apply_failed",
+        &[("count", &failures.len().to_string())],
+    )];
+    for failure in failures.iter().take(MAX_LISTED_FAILURES) {
+        let reason = match &failure.error {
+            BulkError::Invalid(reason) => reason.clone(),
+            BulkError::UnknownChannel(channel_id) => i18n::text(
+                language,
+                "purge.apply_unknown_channel",
+                &[("channel", &channel_id.to_string())],
+            ),
+            BulkError::Unsupported(channel_id) => i18n::text(
+                language,
+                "purge.apply_unsupported",
+                &[("channel", &format!("<#{}>", channel_id))],
+            ),
+            BulkError::Duplicate(channel_id) => i18n::text(
+                language,
+                "purge.apply_duplicate",
+                &[("channel", &format!("<#{}>", channel_id))],
+            ),
+        };
+        lines.push(match failure.entry {
+            Some(entry) => i18n::text(
+                language,
+                "purge.apply_entry",
+                &[("entry", &entry.to_string()), ("reason", &reason)],
+            ),
+            None => i18n::text(language, "purge.apply_file", &[("reason", &reason)]),
+        });
+    }
+    if failures.len() > MAX_LISTED_FAILURES {
+        lines.push(i18n::text(
+            language,
+            "purge.apply_more",
+            &[("count", &(failures.len() - MAX_LISTED_FAILURES).to_string())],
+        ));
+    }
+    lines.join("\n")
+}
+
+/// The most channels `/purge top` lists.
+const TOP_CHANNELS: usize = 10;
+
+/// A period `/purge top` counts deletions over.
+#[derive(Clone, Copy, Debug, PartialEq, Eq, poise::ChoiceParameter)]
+pub enum StatsPeriod {
+    #[name = "today"]
+    Day,
+    #[name = "last 7 days"]
+    Week,
+    #[name = "last 30 days"]
+    Month,
+    #[name = "last 90 days"]
+    Quarter,
+}
+
+impl StatsPeriod {
+    /// Returns the number of days in the period, including today.
+    pub fn days(self) -> u64 {
+        match self {
+            StatsPeriod::Day => 1,
+            StatsPeriod::Week => 7,
+            StatsPeriod::Month => 30,
+            StatsPeriod::Quarter => 90,
+        }
+    }
+
+    /// Returns the first UTC day of the period, given today's.
+    pub fn first_day(self, today: u64) -> u64 {
+        (today + 1).saturating_sub(self.days())
+    }
+}
+
+/// Formats the channels with the most deletions as a numbered list.
+///
+/// # Arguments
+///
+/// * `language` - The language to respond in.
+/// * `ranking` - Channels and their deletion counts, most deleted first.
+pub fn format_leaderboard(language: Language, ranking: &[(ChannelId, u64)]) -> String {
+    ranking
+        .iter()
+        .enumerate()
+        .map(|(rank, (channel_id, deleted))| {
+            let medal = match rank {
+                0 => "🥇",
+                1 => "🥈",
+                2 => "🥉",
+                _ => "▫️",
+            };
+            i18n::text(
+                language,
+                "purge.top_entry",
+                &[
+                    ("medal", medal),
+                    ("channel", &format!("<#{}>", channel_id)),
+                    ("count", &humanize::count(*deleted)),
+                ],
+            )
+        })
+        .collect::<Vec<_>>()
+        .join("\n")
+}
+
+/// Parent command for on-demand purges.
+///
+/// # Permissions
+///
+/// Requires the `MANAGE_MESSAGES` permission.
+#[poise::command(
+    slash_command,
+    prefix_command,
+    subcommands(
+        "now", "between", "range", "abort", "hold", "schedule", "event", "estimate", "top", "apply"
+    ),
+    required_permissions = "MANAGE_MESSAGES"
+)]
+pub async fn purge(_: Context<'_>) -> Result<(), EuleError> {
+    Ok(())
+}
+
+/// Purges every message in a channel right away.
+///
+/// A control message with a cancel button is posted in the invoking channel and
+/// edited periodically with the number of messages deleted, a lower bound on
+/// those remaining, and the current rate. Pressing the button stops the purge at
+/// the next batch boundary; the message then reports how many messages were
+/// deleted.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `channel` - The channel, thread, or voice channel chat to purge.
+/// * `include_threads` - Whether to also purge the channel's threads.
+/// * `slowmode` - The slowmode in seconds to hold the channel at while purging.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) if the purge finished or was cancelled, or an EuleError if it failed.
+#[poise::command(slash_command, prefix_command, user_cooldown = 10)]
+pub async fn now(
+    ctx: Context<'_>,
+    #[description = "Channel, thread, or voice channel chat to purge"]
+    #[channel_types(
+        "Text",
+        "News",
+        "Voice",
+        "Stage",
+        "PublicThread",
+        "PrivateThread",
+        "NewsThread"
+    )]
+    channel: GuildChannel,
+    #[description = "Also purge active and archived threads in the channel"]
+    include_threads: Option<bool>,
+    #[description = "Slowmode in seconds while the purge runs, restored afterwards"]
+    #[min = 1]
+    #[max = 21600]
+    slowmode: Option<u16>,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    ctx.defer_ephemeral().await?;
+
+    let mention = format!("<#{}>", channel.id);
+    let ChannelSupport::Messages { threads } = ChannelSupport::of(channel.kind) else {
+        ctx.say(i18n::tr(ctx, "purge.no_messages", &[("channel", &mention)]).await)
+            .await?;
+        return Ok(());
+    };
+
+    let manager = &ctx.data().autoclean_manager;
+    let Some(cancel) = manager.begin_purge(channel.id).await else {
+        ctx.say(i18n::tr(ctx, "purge.already_running", &[("channel", &mention)]).await)
+            .await?;
+        return Ok(());
+    };
+    let options = PurgeOptions {
+        include_threads: include_threads.unwrap_or(false) && threads,
+        slowmode,
+        ..Default::default()
+    };
+    let result = run_purge(ctx, guild_id, channel.id, options, cancel).await;
+    manager.end_purge(channel.id).await;
+    result
+}
+
+/// Reads a message link, such as
+/// `https://discord.com/channels/1/2/3`, or a bare message ID.
+///
+/// # Returns
+/// The link's channel, if it was a link, and the message's ID.
+///
+/// # Examples
+///
+/// ```
+/// use eule::commands::purge::parse_message_ref;
+/// use poise::serenity_prelude::{ChannelId, MessageId};
+///
+/// assert_eq!(
+///     parse_message_ref("https://discord.com/channels/1/2/3"),
+///     Some((Some(ChannelId::new(2)), MessageId::new(3)))
+/// );
+/// assert_eq!(parse_message_ref("3"), Some((None, MessageId::new(3))));
+/// assert_eq!(parse_message_ref("yesterday"), None);
+/// ```
+pub fn parse_message_ref(text: &str) -> Option<(Option<ChannelId>, MessageId)> {
+    let text = text.trim();
+    let id = |part: &str| part.parse::<u64>().ok().filter(|id| *id != 0);
+    if let Some(message_id) = id(text) {
+        return Some((None, MessageId::new(message_id)));
+    }
+    let path = text.split_once("/channels/")?.1;
+    let mut parts = path.trim_end_matches('/').split('/');
+    let (_guild, channel_id, message_id) = (parts.next()?, parts.next()?, parts.next()?);
+    if parts.next().is_some() {
+        return None;
+    }
+    Some((
+        Some(ChannelId::new(id(channel_id)?)),
+        MessageId::new(id(message_id)?),
+    ))
+}
+
+/// Purges every message between two messages, both included, such as a
+/// conversation that went off the rails.
+///
+/// The messages are given as links, copied with "Copy Message Link", or as IDs
+/// in the channel the command is used in. Only the part of the history between
+/// them is fetched. Messages in the channel's threads are left alone.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `first` - A link to or the ID of one end of the range.
+/// * `last` - A link to or the ID of the other end.
+#[poise::command(slash_command, prefix_command, user_cooldown = 10)]
+pub async fn between(
+    ctx: Context<'_>,
+    #[description = "Link to or ID of the first message to purge"] first: String,
+    #[description = "Link to or ID of the last message to purge"] last: String,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    ctx.defer_ephemeral().await?;
+
+    let language = i18n::language(ctx).await;
+    let (Some((first_channel, first)), Some((last_channel, last))) =
+        (parse_message_ref(&first), parse_message_ref(&last))
+    else {
+        ctx.say(i18n::text(language, "purge.invalid_message", &[]))
+            .await?;
+        return Ok(());
+    };
+    let channel_id = first_channel.or(last_channel).unwrap_or(ctx.channel_id());
+    if [first_channel, last_channel]
+        .into_iter()
+        .flatten()
+        .any(|channel| channel != channel_id)
+    {
+        ctx.say(i18n::text(language, "purge.different_channels", &[]))
+            .await?;
+        return Ok(());
+    }
+
+    let manager = &ctx.data().autoclean_manager;
+    let mention = format!("<#{}>", channel_id);
+    let Some(cancel) = manager.begin_purge(channel_id).await else {
+        ctx.say(i18n::text(
+            language,
+            "purge.already_running",
+            &[("channel", &mention)],
+        ))
+        .await?;
+        return Ok(());
+    };
+    let options = PurgeOptions {
+        range: Some(MessageRange::new(first, last)),
+        ..Default::default()
+    };
+    let result = run_purge(ctx, guild_id, channel_id, options, cancel).await;
+    manager.end_purge(channel_id).await;
+    result
+}
+
+/// Reads the end of a date range. A date alone means the whole day is
+/// included, so `2026-06-01` ends at the start of June 2nd.
+///
+/// # Examples
+///
+/// ```
+/// use eule::commands::purge::parse_range_end;
+///
+/// assert_eq!(parse_range_end("2026-06-01").unwrap().utc_basic(), "20260602T000000Z");
+/// assert_eq!(parse_range_end("2026-06-01 03:00").unwrap().utc_basic(), "20260601T030000Z");
+/// ```
+pub fn parse_range_end(text: &str) -> Option<SerializableInstant> {
+    let end = parse_utc(text)?;
+    Some(match text.contains(':') {
+        true => end,
+        false => end + Duration::from_secs(86_400),
+    })
+}
+
+/// Purges the messages a channel received within a window of time.
+///
+/// Only the part of the history inside the window is fetched, since every
+/// message ID carries the time it was posted. Dates are in the server's time
+/// zone, set with `/config timezone` and UTC by default. A date without
+/// a time starts the window at the beginning of the day, or ends it at the end
+/// of the day.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `channel` - The channel, thread, or voice channel chat to purge.
+/// * `from` - The start of the window, like `2026-06-01` or `2026-06-01 18:00`.
+/// * `to` - The end of the window, in the same format.
+#[poise::command(slash_command, prefix_command, user_cooldown = 10)]
+pub async fn range(
+    ctx: Context<'_>,
+    #[description = "Channel, thread, or voice channel chat to purge"]
+    #[channel_types(
+        "Text",
+        "News",
+        "Voice",
+        "Stage",
+        "PublicThread",
+        "PrivateThread",
+        "NewsThread"
+    )]
+    channel: GuildChannel,
+    #[description = "Start of the window, like 2026-06-01 or 2026-06-01 18:00"] from: String,
+    #[description = "End of the window, like 2026-06-02 or 2026-06-01 23:30"] to: String,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    ctx.defer_ephemeral().await?;
+
+    let language = i18n::language(ctx).await;
+    let mention = format!("<#{}>", channel.id);
+    if !matches!(
+        ChannelSupport::of(channel.kind),
+        ChannelSupport::Messages { .. }
+    ) {
+        ctx.say(i18n::text(
+            language,
+            "purge.no_messages",
+            &[("channel", &mention)],
+        ))
+        .await?;
+        return Ok(());
+    }
+    let offset = ctx
+        .data()
+        .autoclean_manager
+        .guild_settings(guild_id)
+        .await
+        .utc_offset;
+    let start = parse_local(&from, offset);
+    let end = parse_range_end(&to).map(|end| from_local(end, offset));
+    let (Some(start), Some(end)) = (start, end) else {
+        let date = if start.is_none() { &from } else { &to };
+        ctx.say(i18n::text(
+            language,
+            "common.invalid_date",
+            &[("date", date)],
+        ))
+        .await?;
+        return Ok(());
+    };
+    let Some(range) = MessageRange::posted_between(start, end) else {
+        ctx.say(i18n::text(language, "purge.empty_window", &[]))
+            .await?;
+        return Ok(());
+    };
+
+    let manager = &ctx.data().autoclean_manager;
+    let Some(cancel) = manager.begin_purge(channel.id).await else {
+        ctx.say(i18n::text(
+            language,
+            "purge.already_running",
+            &[("channel", &mention)],
+        ))
+        .await?;
+        return Ok(());
+    };
+    let options = PurgeOptions {
+        range: Some(range),
+        ..Default::default()
+    };
+    let result = run_purge(ctx, guild_id, channel.id, options, cancel).await;
+    manager.end_purge(channel.id).await;
+    result
+}
+
+/// Runs a purge with a control message that lets the invoking user cancel it.
+///
+/// The control message is kept out of the purge, and `cancel` and the progress
+/// display are wired into `options`.
+async fn run_purge(
+    ctx: Context<'_>,
+    guild_id: GuildId,
+    channel_id: ChannelId,
+    options: PurgeOptions,
+    cancel: CancelToken,
+) -> Result<(), EuleError> {
+    let manager = &ctx.data().autoclean_manager;
+    let templates = manager.guild_settings(guild_id).await.templates;
+    let language = i18n::language(ctx).await;
+    let mention = format!("<#{}>", channel_id);
+    if manager.is_held(guild_id, channel_id).await {
+        ctx.say(i18n::text(
+            language,
+            "purge.on_hold",
+            &[("channel", &mention)],
+        ))
+        .await?;
+        return Ok(());
+    }
+    let custom_id = format!("{}_{}", CANCEL_BUTTON, channel_id);
+    let button = CreateButton::new(custom_id.clone())
+        .label(i18n::text(language, "purge.cancel", &[]))
+        .style(ButtonStyle::Danger);
+    let mut control = ctx
+        .channel_id()
+        .send_message(
+            ctx,
+            CreateMessage::new()
+                .content(i18n::text(
+                    language,
+                    "purge.starting",
+                    &[("channel", &mention)],
+                ))
+                .components(vec![CreateActionRow::Buttons(vec![button])]),
+        )
+        .await?;
+    ctx.say(i18n::text(
+        language,
+        "purge.started",
+        &[("channel", &mention)],
+    ))
+    .await?;
+
+    // The control message may be in the channel being purged
+    let (progress, progress_rx) = watch::channel(PurgeReport::default());
+    let options = PurgeOptions {
+        filter: options.filter.keep_messages([control.id]),
+        budget: manager.deletion_budget(guild_id).await,
+        cancel: cancel.clone(),
+        progress: Some(progress),
+        ..options
+    };
+    let api = ctx.data().bot.api().clone();
+    let purge = purge_channel(&api, channel_id, &options);
+    tokio::pin!(purge);
+
+    let author_id = ctx.author().id;
+    let press = ComponentInteractionCollector::new(ctx.serenity_context())
+        .author_id(author_id)
+        .filter(move |press| press.data.custom_id == custom_id)
+        .next();
+    tokio::pin!(press);
+    let mut collecting = true;
+
+    let started = Instant::now();
+    let mut ticker = tokio::time::interval_at(started + PROGRESS_INTERVAL, PROGRESS_INTERVAL);
+    let result = loop {
+        tokio::select! {
+            result = &mut purge => break result,
+            pressed = &mut press, if collecting => {
+                collecting = false;
+                if let Some(pressed) = pressed {
+                    cancel.cancel();
+                    pressed.defer(ctx).await?;
+                }
+            }
+            _ = ticker.tick() => {
+                let report = *progress_rx.borrow();
+                let content = if cancel.is_cancelled() {
+                    i18n::text(language, "purge.cancelling", &[("channel", &mention)])
+                } else {
+                    format_progress(&templates, language, channel_id, &report, started.elapsed())
+                };
+                // A failed progress update shouldn't abort the purge
+                if let Err(e) = control.edit(ctx, EditMessage::new().content(content)).await {
+                    tracing::warn!("Failed to update purge progress: {:?}", e);
+                }
+            }
+        }
+    };
+
+    let summary = match &result {
+        Ok(report) if report.cancelled => i18n::text(
+            language,
+            "purge.cancelled",
+            &[
+                ("channel", &mention),
+                ("count", &humanize::count(report.deleted as u64)),
+            ],
+        ),
+        Ok(report) => {
+            let next = manager
+                .task(guild_id, channel_id)
+                .await
+                .map(|task| task.next_cleanup());
+            format_completion(&templates, language, channel_id, report, next)
+        }
+        Err(_) => i18n::text(language, "purge.failed", &[("channel", &mention)]),
+    };
+    control
+        .edit(
+            ctx,
+            EditMessage::new().content(summary).components(Vec::new()),
+        )
+        .await?;
+    reply::expire_message(ctx, control.channel_id, control.id);
+    result.map(|_| ())
+}
+
+/// Stops a purge that is in progress in a channel.
+///
+/// Works for purges started with `/purge now` and for scheduled cleanups. The
+/// purge stops before its next delete request; messages already deleted stay
+/// deleted.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `channel` - The channel being purged.
+#[poise::command(slash_command, prefix_command)]
+pub async fn abort(
+    ctx: Context<'_>,
+    #[description = "Channel being purged"] channel: ChannelId,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+
+    if !may_manage_task(ctx, guild_id, channel).await? {
+        return Ok(());
+    }
+
+    let key = if ctx
+        .data()
+        .autoclean_manager
+        .cancel_purge(guild_id, channel)
+        .await
+    {
+        "purge.stopping"
+    } else {
+        "purge.not_running"
+    };
+    let message = i18n::tr(ctx, key, &[("channel", &format!("<#{}>", channel))]).await;
+    reply::say(ctx, message).await
+}
+
+/// Places a legal hold on a channel, or lifts it.
+///
+/// While a channel is held, nothing deletes from it: its scheduled cleanups
+/// wait, one-shot purges are dropped, and purges from commands are refused. A
+/// purge running when the hold is placed is stopped. Who placed or lifted the
+/// hold is kept in the server's hold log and posted in its log channel, if it
+/// has one. Requires the `MANAGE_GUILD` permission.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `channel` - The channel to hold.
+/// * `enabled` - Whether the channel should be held.
+/// * `reason` - Why the hold is placed, for the hold log.
+#[poise::command(slash_command, prefix_command, required_permissions = "MANAGE_GUILD")]
+pub async fn hold(
+    ctx: Context<'_>,
+    #[description = "Channel whose messages must be kept"] channel: ChannelId,
+    #[description = "Keep everything in the channel until the hold is lifted"] enabled: bool,
+    #[description = "Why the messages must be kept"]
+    #[max_length = 200]
+    reason: Option<String>,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    let manager = &ctx.data().autoclean_manager;
+    let author = ctx.author().id;
+    let changed = match enabled {
+        true => {
+            manager
+                .place_hold(guild_id, channel, author, reason.clone())
+                .await?
+        }
+        false => manager.lift_hold(guild_id, channel, author).await?,
+    };
+    let mention = format!("<#{}>", channel);
+    let key = match (changed, enabled) {
+        (true, true) => "purge.hold_placed",
+        (true, false) => "purge.hold_lifted",
+        (false, true) => "purge.hold_already",
+        (false, false) => "purge.not_held",
+    };
+    let message = i18n::tr(ctx, key, &[("channel", &mention)]).await;
+    reply::say(ctx, message).await?;
+
+    let settings = manager.guild_settings(guild_id).await;
+    let (true, Some(log_channel)) = (changed, settings.log_channel) else {
+        return Ok(());
+    };
+    let language = settings.language.unwrap_or_default();
+    let text = match enabled {
+        true => i18n::text(
+            language,
+            "purge.hold_log_placed",
+            &[
+                ("user", &format!("<@{}>", author)),
+                ("channel", &mention),
+                (
+                    "reason",
+                    &reason.unwrap_or_else(|| i18n::text(language, "purge.no_reason", &[])),
+                ),
+            ],
+        ),
+        false => i18n::text(
+            language,
+            "purge.hold_log_lifted",
+            &[("user", &format!("<@{}>", author)), ("channel", &mention)],
+        ),
+    };
+    if let Err(e) = log_channel
+        .send_message(ctx, CreateMessage::new().content(text))
+        .await
+    {
+        tracing::warn!("Failed to log a hold in the log channel: {}", e);
+    }
+    Ok(())
+}
+
+/// Purges a channel once at a later date, such as ahead of a planned reset.
+///
+/// The channel doesn't need an autoclean task. If it has one, the purge uses
+/// the task's settings and moves its schedule along like any other run.
+/// Leaving out `at` cancels the channel's scheduled purge.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `channel` - The channel, thread, or voice channel chat to purge.
+/// * `at` - When to purge it, as a date and time like `2026-06-01 03:00` in
+///   the server's time zone.
+#[poise::command(slash_command, prefix_command)]
+pub async fn schedule(
+    ctx: Context<'_>,
+    #[description = "Channel, thread, or voice channel chat to purge"]
+    #[channel_types(
+        "Text",
+        "News",
+        "Voice",
+        "Stage",
+        "PublicThread",
+        "PrivateThread",
+        "NewsThread"
+    )]
+    channel: GuildChannel,
+    #[description = "Date and time, like 2026-06-01 03:00; leave out to cancel"] at: Option<String>,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    let language = i18n::language(ctx).await;
+    let mention = format!("<#{}>", channel.id);
+    let manager = &ctx.data().autoclean_manager;
+    let Some(text) = at else {
+        let message = match manager.cancel_scheduled_purge(guild_id, channel.id).await? {
+            Some(at) => i18n::text(
+                language,
+                "purge.schedule_cancelled",
+                &[("channel", &mention), ("at", &discord_time::full(at))],
+            ),
+            None => i18n::text(
+                language,
+                "purge.nothing_scheduled",
+                &[("channel", &mention)],
+            ),
+        };
+        return reply::say(ctx, message).await;
+    };
+    if !matches!(
+        ChannelSupport::of(channel.kind),
+        ChannelSupport::Messages { .. }
+    ) {
+        let message = i18n::text(language, "purge.no_messages", &[("channel", &mention)]);
+        return reply::say(ctx, message).await;
+    }
+    let offset = manager.guild_settings(guild_id).await.utc_offset;
+    let at = match parse_local(&text, offset) {
+        Some(at) if at > SerializableInstant::now() => at,
+        Some(_) => {
+            let message = i18n::text(language, "common.date_past", &[("date", &text)]);
+            return reply::say(ctx, message).await;
+        }
+        None => {
+            let message = i18n::text(language, "common.invalid_date", &[("date", &text)]);
+            return reply::say(ctx, message).await;
+        }
+    };
+
+    manager.schedule_purge(guild_id, channel.id, at).await?;
+    let message = i18n::text(
+        language,
+        "purge.scheduled",
+        &[("channel", &mention), ("at", &discord_time::full(at))],
+    );
+    reply::say(ctx, message).await
+}
+
+/// Reads a scheduled event given as a link, like
+/// `https://discord.com/events/<guild>/<event>`, or as a bare event ID.
+///
+/// # Examples
+///
+/// ```
+/// use eule::commands::purge::parse_event_ref;
+///
+/// assert_eq!(parse_event_ref("https://discord.com/events/1/22").unwrap().get(), 22);
+/// assert_eq!(parse_event_ref("22").unwrap().get(), 22);
+/// assert!(parse_event_ref("https://discord.com/channels/1/2/3").is_none());
+/// ```
+pub fn parse_event_ref(text: &str) -> Option<ScheduledEventId> {
+    let text = text.trim();
+    let id = match text.split_once("/events/") {
+        Some((_, path)) => {
+            let mut parts = path.trim_end_matches('/').split('/');
+            let (_guild, event_id) = (parts.next()?, parts.next()?);
+            if parts.next().is_some() {
+                return None;
+            }
+            event_id
+        }
+        None => text,
+    };
+    id.parse::<u64>()
+        .ok()
+        .filter(|id| *id != 0)
+        .map(ScheduledEventId::new)
+}
+
+/// Purges a channel once a scheduled event ends, so the event's chat channel
+/// resets itself.
+///
+/// The purge is planned when Discord reports the event as completed, and
+/// works like one from `/purge schedule`. Cancelled or deleted events don't
+/// purge anything. Leaving out `channel` removes the event's purge.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `event` - The event, as a link from "Copy Event Link" or as its ID.
+/// * `channel` - The channel, thread, or voice channel chat to purge.
+/// * `after` - How long after the event ends to purge, like `30m`. Defaults to right away.
+#[poise::command(slash_command, prefix_command)]
+pub async fn event(
+    ctx: Context<'_>,
+    #[description = "Event link, from \"Copy Event Link\", or its ID"] event: String,
+    #[description = "Channel, thread, or voice channel chat to purge; leave out to remove"]
+    #[channel_types(
+        "Text",
+        "News",
+        "Voice",
+        "Stage",
+        "PublicThread",
+        "PrivateThread",
+        "NewsThread"
+    )]
+    channel: Option<GuildChannel>,
+    #[description = "How long after the event ends, like 30m or 2h; right away by default"]
+    after: Option<String>,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    let language = i18n::language(ctx).await;
+    let Some(event_id) = parse_event_ref(&event) else {
+        let message = i18n::text(language, "purge.invalid_event", &[]);
+        return reply::say(ctx, message).await;
+    };
+    let Ok(event) = guild_id.scheduled_event(ctx.http(), event_id, false).await else {
+        let message = i18n::text(language, "purge.unknown_event", &[]);
+        return reply::say(ctx, message).await;
+    };
+
+    let manager = &ctx.data().autoclean_manager;
+    let Some(channel) = channel else {
+        let mut removed = None;
+        manager
+            .update_guild_settings(guild_id, |settings| {
+                removed = settings.event_purges.remove(&event_id);
+            })
+            .await?;
+        let message = match removed {
+            Some(purge) => i18n::text(
+                language,
+                "purge.event_removed",
+                &[
+                    ("event", &event.name),
+                    ("channel", &format!("<#{}>", purge.channel)),
+                ],
+            ),
+            None => i18n::text(language, "purge.event_none", &[("event", &event.name)]),
+        };
+        return reply::say(ctx, message).await;
+    };
+    let mention = format!("<#{}>", channel.id);
+    if !matches!(
+        ChannelSupport::of(channel.kind),
+        ChannelSupport::Messages { .. }
+    ) {
+        let message = i18n::text(language, "purge.no_messages", &[("channel", &mention)]);
+        return reply::say(ctx, message).await;
+    }
+    let delay = match after {
+        Some(text) => match parse_interval(&text) {
+            Some(delay) => delay,
+            None => {
+                let message = i18n::text(language, "policy.invalid_interval", &[("value", &text)]);
+                return reply::say(ctx, message).await;
+            }
+        },
+        None => Duration::ZERO,
+    };
+
+    let purge = EventPurge {
+        channel: channel.id,
+        delay,
+    };
+    manager
+        .update_guild_settings(guild_id, |settings| {
+            settings.event_purges.insert(event_id, purge);
+        })
+        .await?;
+    let message = match delay.is_zero() {
+        true => i18n::text(
+            language,
+            "purge.event_set",
+            &[("channel", &mention), ("event", &event.name)],
+        ),
+        false => i18n::text(
+            language,
+            "purge.event_set_after",
+            &[
+                ("channel", &mention),
+                ("event", &event.name),
+                ("after", &format_interval(delay)),
+            ],
+        ),
+    };
+    reply::say(ctx, message).await
+}
+
+/// Describes how long purging a channel is expected to take, warning when it
+/// would take hours.
+///
+/// # Arguments
+/// * `language` - The language to describe it in
+/// * `channel` - The channel the estimate is for
+/// * `estimate` - The estimate
+/// * `options` - The pacing settings the purge would run with
+pub fn describe_estimate(
+    language: Language,
+    channel: ChannelId,
+    estimate: &PurgeEstimate,
+    options: &PurgeOptions,
+) -> String {
+    let key = match estimate.complete {
+        true => "purge.estimate_exact",
+        false => "purge.estimate_about",
+    };
+    let mut message = i18n::text(
+        language,
+        key,
+        &[
+            ("channel", &format!("<#{}>", channel)),
+            ("count", &humanize::count(estimate.messages())),
+            ("old", &humanize::count(estimate.old)),
+            ("duration", &humanize::duration(estimate.duration(options))),
+        ],
+    );
+    if estimate.is_long(options) {
+        message.push('\n');
+        message.push_str(&i18n::text(language, "purge.estimate_long", &[]));
+    }
+    message
+}
+
+/// Estimates how long purging a channel would take, without deleting anything.
+///
+/// The newest messages are sampled; for channels with more history, the rest
+/// is assumed to be as busy. Messages older than 14 days each need a request
+/// of their own, so they dominate the estimate.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `channel` - The channel, thread, or voice channel chat to estimate.
+#[poise::command(slash_command, prefix_command, user_cooldown = 10)]
+pub async fn estimate(
+    ctx: Context<'_>,
+    #[description = "Channel, thread, or voice channel chat to estimate"]
+    #[channel_types(
+        "Text",
+        "News",
+        "Voice",
+        "Stage",
+        "PublicThread",
+        "PrivateThread",
+        "NewsThread"
+    )]
+    channel: GuildChannel,
+) -> Result<(), EuleError> {
+    ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    let language = i18n::language(ctx).await;
+    if !matches!(
+        ChannelSupport::of(channel.kind),
+        ChannelSupport::Messages { .. }
+    ) {
+        let mention = format!("<#{}>", channel.id);
+        let message = i18n::text(language, "purge.no_messages", &[("channel", &mention)]);
+        return reply::say(ctx, message).await;
+    }
+
+    let options = PurgeOptions::default();
+    let estimate = estimate_purge(ctx.http(), channel.id, &options, ESTIMATE_PAGES).await?;
+    let message = describe_estimate(language, channel.id, &estimate, &options);
+    reply::say(ctx, message).await
+}
+
+/// Shows which channels had the most messages deleted by their cleanups.
+///
+/// Channels that need frequent purging may be better served by a stricter
+/// slowmode. Deletions are counted per day for the last 90 days.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `period` - The period to count deletions over. Defaults to the last 7 days.
+/// * `label` - Only ranks the tasks with this label, if given.
+#[poise::command(slash_command, prefix_command)]
+pub async fn top(
+    ctx: Context<'_>,
+    #[description = "Period to count deletions over (default: last 7 days)"] period: Option<
+        StatsPeriod,
+    >,
+    #[description = "Only rank tasks with this label"] label: Option<String>,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    let period = period.unwrap_or(StatsPeriod::Week);
+    let first_day = period.first_day(SerializableInstant::now().utc_day());
+    let ranking = ctx
+        .data()
+        .autoclean_manager
+        .top_channels(guild_id, first_day, TOP_CHANNELS, label.as_deref())
+        .await;
+
+    let language = i18n::language(ctx).await;
+    if ranking.is_empty() {
+        return reply::say(ctx, i18n::text(language, "purge.top_empty", &[])).await;
+    }
+    let title = match period {
+        StatsPeriod::Day => i18n::text(language, "purge.top_today", &[]),
+        _ => i18n::text(
+            language,
+            "purge.top_days",
+            &[("days", &period.days().to_string())],
+        ),
+    };
+    reply::send(
+        ctx,
+        CreateReply::default().embed(
+            CreateEmbed::new()
+                .title(title)
+                .description(format_leaderboard(language, &ranking)),
+        ),
+    )
+    .await
+}
+
+/// Schedules many channels at once from an uploaded JSON file.
+///
+/// The file lists one policy per channel; see [`crate::tasks::policy`] for its
+/// format. Channels without a task get one, and channels with a task get the
+/// policy's settings while keeping their schedule and history. Every entry is
+/// checked first, and if any of them has a problem nothing is applied.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `file` - The JSON file with the policies.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) if the file was applied or its problems were
+/// listed, or an EuleError if it couldn't be read or saved.
+#[poise::command(slash_command, prefix_command, guild_only, user_cooldown = 30)]
+pub async fn apply(
+    ctx: Context<'_>,
+    #[description = "JSON file with one policy per channel"] file: Attachment,
+) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    reply::defer(ctx).await?;
+
+    let language = i18n::language(ctx).await;
+    if file.size > MAX_UPLOAD_BYTES {
+        let message = i18n::text(
+            language,
+            "purge.apply_too_large",
+            &[("size", &format!("{} KiB", MAX_UPLOAD_BYTES / 1024))],
+        );
+        return reply::say(ctx, message).await;
+    }
+    let bytes = file.download().await?;
+    let json = String::from_utf8_lossy(&bytes);
+    let channels = guild_id
+        .channels(ctx)
+        .await?
+        .into_iter()
+        .map(|(channel_id, channel)| (channel_id, channel.kind))
+        .collect();
+
+    let policies = match parse_bulk(&json, &channels) {
+        Ok(policies) if policies.is_empty() => {
+            return reply::say(ctx, i18n::text(language, "purge.apply_empty", &[])).await;
+        }
+        Ok(policies) => policies,
+        Err(failures) => {
+            return reply::say(ctx, format_bulk_failures(language, &failures)).await;
+        }
+    };
+    let targets: Vec<ChannelId> = policies.iter().map(|(channel_id, _)| *channel_id).collect();
+    if !may_manage_tasks(ctx, guild_id, &targets).await? {
+        return Ok(());
+    }
+    let (created, updated) = ctx
+        .data()
+        .autoclean_manager
+        .apply_policies(guild_id, &policies)
+        .await?;
+    let message = i18n::text(
+        language,
+        "purge.apply_done",
+        &[
+            ("count", &policies.len().to_string()),
+            ("created", &created.to_string()),
+            ("updated", &updated.to_string()),
+        ],
+    );
+    reply::say(ctx, message).await
+}
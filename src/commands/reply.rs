@@ -0,0 +1,109 @@
+//! Replies that clean up after themselves.
+//!
+//! With `replies.delete_after_secs` set, confirmations and status messages
+//! visible to everyone are deleted again after that delay. Ephemeral replies
+//! are only seen by the invoking user and are left alone. Deletions are
+//! scheduled in memory, so replies still pending when the bot restarts are kept.
+//!
+//! Servers that turned on private replies with `/config ephemeral` get every
+//! reply sent through here as an ephemeral one.
+
+use crate::{Context, EuleError};
+use poise::{
+    serenity_prelude::{ChannelId, Http, MessageId},
+    CreateReply, ReplyHandle,
+};
+use std::sync::Arc;
+
+/// Sends a text reply that is deleted after the configured delay.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `content` - The text of the reply.
+pub async fn say(ctx: Context<'_>, content: impl Into<String>) -> Result<(), EuleError> {
+    send(ctx, CreateReply::default().content(content)).await
+}
+
+/// Sends a reply that is deleted after the configured delay, or an ephemeral
+/// one if the server wants private replies.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `reply` - The reply, which must not be ephemeral.
+pub async fn send(ctx: Context<'_>, reply: CreateReply) -> Result<(), EuleError> {
+    if private_replies(ctx).await {
+        ctx.send(reply.ephemeral(true)).await?;
+        return Ok(());
+    }
+    let handle = ctx.send(reply).await?;
+    expire(ctx, &handle).await
+}
+
+/// Defers the response to a command, privately if the server wants private
+/// replies, since a deferred response can't become private later.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+pub async fn defer(ctx: Context<'_>) -> Result<(), EuleError> {
+    match private_replies(ctx).await {
+        true => ctx.defer_ephemeral().await?,
+        false => ctx.defer().await?,
+    }
+    Ok(())
+}
+
+/// Returns whether the server a command was used in wants private replies.
+async fn private_replies(ctx: Context<'_>) -> bool {
+    match ctx.guild_id() {
+        Some(guild_id) => {
+            ctx.data()
+                .autoclean_manager
+                .guild_settings(guild_id)
+                .await
+                .ephemeral_replies
+        }
+        None => false,
+    }
+}
+
+/// Schedules the deletion of a reply that was already sent.
+///
+/// Useful for replies that stay interactive for a while, which should only
+/// start their countdown once the interaction is over.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `handle` - The reply to delete.
+pub async fn expire(ctx: Context<'_>, handle: &ReplyHandle<'_>) -> Result<(), EuleError> {
+    if ctx.data().bot.config().replies.delete_after().is_none() {
+        return Ok(());
+    }
+    let message = handle.message().await?;
+    expire_message(ctx, message.channel_id, message.id);
+    Ok(())
+}
+
+/// Schedules the deletion of a message the bot posted outside a reply.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+/// * `channel_id` - The channel the message is in.
+/// * `message_id` - The message to delete.
+pub fn expire_message(ctx: Context<'_>, channel_id: ChannelId, message_id: MessageId) {
+    let Some(delay) = ctx.data().bot.config().replies.delete_after() else {
+        return;
+    };
+    let http: Arc<Http> = ctx.serenity_context().http.clone();
+    tokio::spawn(async move {
+        tokio::time::sleep(delay).await;
+        // Moderators may have deleted the reply already
+        if let Err(e) = channel_id.delete_message(&http, message_id).await {
+            tracing::debug!("Failed to delete an expired reply: {}", e);
+        }
+    });
+}
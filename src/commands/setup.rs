@@ -0,0 +1,416 @@
+//! A guided setup that configures cleanups from start to finish.
+//!
+//! `/setup` walks a new admin through the choices `/autoclean` otherwise asks
+//! for one command at a time: the channels to clean, how often, which
+//! messages to keep and where the bot reports. Each step is a menu on the
+//! same message, and nothing is saved until the last step is confirmed, so
+//! walking away from the wizard changes nothing.
+
+use crate::{
+    commands::config::{is_administrator, not_owner_text, others_tasks},
+    i18n::{self, Language},
+    tasks::{policy::parse_interval, AutocleanManager},
+    utils::humanize,
+    Context, EuleError,
+};
+use miette::Result;
+use poise::{
+    serenity_prelude::{
+        ButtonStyle, ChannelId, ChannelType, ComponentInteraction, ComponentInteractionCollector,
+        ComponentInteractionDataKind, CreateActionRow, CreateButton, CreateInteractionResponse,
+        CreateInteractionResponseMessage, CreateSelectMenu, CreateSelectMenuKind,
+        CreateSelectMenuOption, GuildId, UserId,
+    },
+    CreateReply, ReplyHandle,
+};
+use tokio::time::Duration;
+
+/// The intervals the wizard offers, shortest first.
+pub const SETUP_INTERVALS: &[&str] = &["1h", "6h", "12h", "1d", "7d"];
+
+/// The most channels one run of the wizard configures.
+const MAX_CHANNELS: u8 = 10;
+
+/// How long each step waits for an answer.
+const STEP_TIMEOUT: Duration = Duration::from_secs(120);
+
+/// A setting the wizard can turn on for every channel it configures.
+#[derive(Clone, Copy, Debug, PartialEq, Eq)]
+pub enum SetupFilter {
+    /// Leave pinned messages in place.
+    KeepPinned,
+    /// Leave the channel's oldest message in place.
+    KeepFirst,
+    /// Also clean the channel's threads.
+    IncludeThreads,
+}
+
+impl SetupFilter {
+    /// Every filter, in the order the menu lists them.
+    pub const ALL: [SetupFilter; 3] = [
+        SetupFilter::KeepPinned,
+        SetupFilter::KeepFirst,
+        SetupFilter::IncludeThreads,
+    ];
+
+    /// The value standing for the filter in the menu.
+    pub fn value(self) -> &'static str {
+        match self {
+            SetupFilter::KeepPinned => "keep_pinned",
+            SetupFilter::KeepFirst => "keep_first",
+            SetupFilter::IncludeThreads => "include_threads",
+        }
+    }
+
+    /// Finds the filter a menu value stands for.
+    pub fn from_value(value: &str) -> Option<Self> {
+        Self::ALL.into_iter().find(|filter| filter.value() == value)
+    }
+
+    fn label(self, language: Language) -> String {
+        i18n::text(language, &format!("setup.filter_{}", self.value()), &[])
+    }
+}
+
+/// What an admin picked in the wizard.
+#[derive(Clone, Debug, PartialEq, Eq)]
+pub struct SetupChoices {
+    /// The channels to clean.
+    pub channels: Vec<ChannelId>,
+    /// The time between cleanups.
+    pub interval: Duration,
+    /// The settings turned on for each channel.
+    pub filters: Vec<SetupFilter>,
+    /// The channel the bot reports in, or `None` to leave it as it is.
+    pub log_channel: Option<ChannelId>,
+}
+
+/// Writes the overview shown before the choices are saved.
+///
+/// # Arguments
+/// * `language` - The language to write it in
+/// * `choices` - What was picked
+pub fn setup_review(language: Language, choices: &SetupChoices) -> String {
+    let channels: Vec<String> = choices
+        .channels
+        .iter()
+        .map(|channel| format!("<#{}>", channel))
+        .collect();
+    let filters = match choices.filters.is_empty() {
+        true => i18n::text(language, "setup.no_filters", &[]),
+        false => choices
+            .filters
+            .iter()
+            .map(|filter| filter.label(language))
+            .collect::<Vec<_>>()
+            .join(", "),
+    };
+    let log_channel = match choices.log_channel {
+        Some(channel) => format!("<#{}>", channel),
+        None => i18n::text(language, "setup.no_log", &[]),
+    };
+    i18n::text(
+        language,
+        "setup.review",
+        &[
+            ("channels", &channels.join(", ")),
+            ("interval", &humanize::duration(choices.interval)),
+            ("filters", &filters),
+            ("log", &log_channel),
+        ],
+    )
+}
+
+/// Saves what was picked in the wizard.
+///
+/// Each channel gets a new task, replacing any it had, with the chosen
+/// filters turned on.
+///
+/// # Arguments
+/// * `manager` - The manager to add the tasks to
+/// * `guild_id` - The guild the channels belong to
+/// * `user_id` - The member who went through the wizard, recorded as the
+///   tasks' creator
+/// * `choices` - What was picked
+pub async fn apply_setup(
+    manager: &AutocleanManager,
+    guild_id: GuildId,
+    user_id: UserId,
+    choices: &SetupChoices,
+) -> Result<()> {
+    for &channel_id in &choices.channels {
+        manager
+            .add_task(guild_id, channel_id, choices.interval)
+            .await?;
+        manager
+            .record_creator(guild_id, channel_id, user_id)
+            .await?;
+        let chosen = |filter| choices.filters.contains(&filter);
+        if chosen(SetupFilter::KeepPinned) {
+            manager.set_keep_pinned(guild_id, channel_id, true).await?;
+        }
+        if chosen(SetupFilter::KeepFirst) {
+            manager
+                .set_keep_first_message(guild_id, channel_id, true)
+                .await?;
+        }
+        if chosen(SetupFilter::IncludeThreads) {
+            manager
+                .set_include_threads(guild_id, channel_id, true)
+                .await?;
+        }
+    }
+    if let Some(log_channel) = choices.log_channel {
+        manager
+            .update_guild_settings(guild_id, |settings| {
+                settings.log_channel = Some(log_channel)
+            })
+            .await?;
+    }
+    Ok(())
+}
+
+/// Walks you through setting up cleanups: which channels, how often, which
+/// messages to keep and where I report.
+///
+/// Only you see the wizard, and nothing is saved until the last step is
+/// confirmed. Channels that already have a task get the new settings instead.
+///
+/// # Arguments
+///
+/// * `ctx` - The command context.
+///
+/// # Returns
+///
+/// A Result containing Ok(()) once the wizard ends, or an EuleError if there
+/// was an issue.
+#[poise::command(slash_command, guild_only, required_permissions = "MANAGE_GUILD")]
+pub async fn setup(ctx: Context<'_>) -> Result<(), EuleError> {
+    let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
+    let language = i18n::language(ctx).await;
+    let prefix = ctx.id().to_string();
+    let text = |key: &str| i18n::text(language, key, &[]);
+    let skip_button = |id: &str| {
+        CreateActionRow::Buttons(vec![CreateButton::new(format!("{}_{}", prefix, id))
+            .label(text("setup.skip"))
+            .style(ButtonStyle::Secondary)])
+    };
+    let message_channels = Some(vec![ChannelType::Text, ChannelType::News]);
+
+    let handle = ctx
+        .send(
+            CreateReply::default()
+                .content(text("setup.pick_channels"))
+                .components(vec![CreateActionRow::SelectMenu(
+                    CreateSelectMenu::new(
+                        format!("{}_channels", prefix),
+                        CreateSelectMenuKind::Channel {
+                            channel_types: message_channels.clone(),
+                            default_channels: None,
+                        },
+                    )
+                    .min_values(1)
+                    .max_values(MAX_CHANNELS),
+                )])
+                .ephemeral(true),
+        )
+        .await?;
+    let Some(press) = next_answer(ctx, &prefix).await else {
+        return time_out(ctx, &handle, language).await;
+    };
+    let channels = match &press.data.kind {
+        ComponentInteractionDataKind::ChannelSelect { values } => values.clone(),
+        _ => Vec::new(),
+    };
+
+    let options = SETUP_INTERVALS
+        .iter()
+        .filter_map(|value| {
+            let interval = parse_interval(value)?;
+            Some(CreateSelectMenuOption::new(
+                humanize::duration(interval),
+                value.to_string(),
+            ))
+        })
+        .collect();
+    show_step(
+        ctx,
+        &press,
+        text("setup.pick_interval"),
+        vec![CreateActionRow::SelectMenu(CreateSelectMenu::new(
+            format!("{}_interval", prefix),
+            CreateSelectMenuKind::String { options },
+        ))],
+    )
+    .await?;
+    let Some(press) = next_answer(ctx, &prefix).await else {
+        return time_out(ctx, &handle, language).await;
+    };
+    let interval = match &press.data.kind {
+        ComponentInteractionDataKind::StringSelect { values } => {
+            values.first().and_then(|value| parse_interval(value))
+        }
+        _ => None,
+    };
+    let Some(interval) = interval else {
+        return show_step(ctx, &press, text("setup.cancelled"), Vec::new()).await;
+    };
+
+    let options = SetupFilter::ALL
+        .into_iter()
+        .map(|filter| CreateSelectMenuOption::new(filter.label(language), filter.value()))
+        .collect();
+    show_step(
+        ctx,
+        &press,
+        text("setup.pick_filters"),
+        vec![
+            CreateActionRow::SelectMenu(
+                CreateSelectMenu::new(
+                    format!("{}_filters", prefix),
+                    CreateSelectMenuKind::String { options },
+                )
+                .min_values(1)
+                .max_values(SetupFilter::ALL.len() as u8),
+            ),
+            skip_button("no_filters"),
+        ],
+    )
+    .await?;
+    let Some(press) = next_answer(ctx, &prefix).await else {
+        return time_out(ctx, &handle, language).await;
+    };
+    let filters = match &press.data.kind {
+        ComponentInteractionDataKind::StringSelect { values } => values
+            .iter()
+            .filter_map(|value| SetupFilter::from_value(value))
+            .collect(),
+        _ => Vec::new(),
+    };
+
+    show_step(
+        ctx,
+        &press,
+        text("setup.pick_log"),
+        vec![
+            CreateActionRow::SelectMenu(CreateSelectMenu::new(
+                format!("{}_log", prefix),
+                CreateSelectMenuKind::Channel {
+                    channel_types: message_channels,
+                    default_channels: None,
+                },
+            )),
+            skip_button("no_log"),
+        ],
+    )
+    .await?;
+    let Some(press) = next_answer(ctx, &prefix).await else {
+        return time_out(ctx, &handle, language).await;
+    };
+    let log_channel = match &press.data.kind {
+        ComponentInteractionDataKind::ChannelSelect { values } => values.first().copied(),
+        _ => None,
+    };
+
+    let choices = SetupChoices {
+        channels,
+        interval,
+        filters,
+        log_channel,
+    };
+    let save_id = format!("{}_save", prefix);
+    show_step(
+        ctx,
+        &press,
+        setup_review(language, &choices),
+        vec![CreateActionRow::Buttons(vec![
+            CreateButton::new(save_id.clone())
+                .label(text("setup.save"))
+                .style(ButtonStyle::Success),
+            CreateButton::new(format!("{}_cancel", prefix))
+                .label(text("setup.cancel"))
+                .style(ButtonStyle::Secondary),
+        ])],
+    )
+    .await?;
+    let Some(press) = next_answer(ctx, &prefix).await else {
+        return time_out(ctx, &handle, language).await;
+    };
+    if press.data.custom_id != save_id {
+        return show_step(ctx, &press, text("setup.cancelled"), Vec::new()).await;
+    }
+
+    // Ownership is checked on saving, since nothing is changed before then
+    let others = others_tasks(ctx, guild_id, &choices.channels).await;
+    if !others.is_empty() {
+        return show_step(ctx, &press, not_owner_text(language, &others), Vec::new()).await;
+    }
+    let settings = ctx.data().autoclean_manager.guild_settings(guild_id).await;
+    if choices.log_channel.is_some() && settings.own_tasks_only && !is_administrator(ctx).await {
+        let message = text("config.not_owner_settings");
+        return show_step(ctx, &press, message, Vec::new()).await;
+    }
+    apply_setup(
+        &ctx.data().autoclean_manager,
+        guild_id,
+        ctx.author().id,
+        &choices,
+    )
+    .await?;
+    let done = i18n::text(
+        language,
+        "setup.done",
+        &[
+            ("count", &choices.channels.len().to_string()),
+            ("interval", &humanize::duration(choices.interval)),
+        ],
+    );
+    show_step(ctx, &press, done, Vec::new()).await
+}
+
+/// Waits for the invoking user to answer the wizard's current step.
+async fn next_answer(ctx: Context<'_>, prefix: &str) -> Option<ComponentInteraction> {
+    let prefix = prefix.to_string();
+    ComponentInteractionCollector::new(ctx.serenity_context())
+        .author_id(ctx.author().id)
+        .filter(move |press| press.data.custom_id.starts_with(&prefix))
+        .timeout(STEP_TIMEOUT)
+        .await
+}
+
+/// Ends the wizard after a step went unanswered, removing its menus.
+async fn time_out(
+    ctx: Context<'_>,
+    handle: &ReplyHandle<'_>,
+    language: Language,
+) -> Result<(), EuleError> {
+    handle
+        .edit(
+            ctx,
+            CreateReply::default()
+                .content(i18n::text(language, "setup.timed_out", &[]))
+                .components(Vec::new()),
+        )
+        .await?;
+    Ok(())
+}
+
+/// Answers the previous step by turning the wizard's message into the next.
+async fn show_step(
+    ctx: Context<'_>,
+    press: &ComponentInteraction,
+    content: String,
+    components: Vec<CreateActionRow>,
+) -> Result<(), EuleError> {
+    press
+        .create_response(
+            ctx,
+            CreateInteractionResponse::UpdateMessage(
+                CreateInteractionResponseMessage::new()
+                    .content(content)
+                    .components(components),
+            ),
+        )
+        .await?;
+    Ok(())
+}
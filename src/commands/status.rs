@@ -1,12 +1,78 @@
 //! A command to check the bot's uptime and the number of scheduled autoclean tasks.
 
-use crate::{Context, EuleError};
+use crate::{
+    commands::paginate::{paginate_fields, send_paginated, task_field, FIELDS_PER_PAGE},
+    i18n::{self, Language},
+    utils::{discord_time, humanize},
+    Context, EuleError,
+};
+use poise::serenity_prelude::{ConnectionStage, CreateEmbed, ShardId};
+use std::time::Duration;
+
+/// Formats the health of a single shard for the status message.
+///
+/// Serenity does not expose heartbeat timestamps, so the time since the shard
+/// last received any gateway event stands in for the last heartbeat.
+///
+/// # Arguments
+///
+/// * `language` - The language to describe the shard in.
+/// * `shard_id` - The shard being described.
+/// * `stage` - The shard's connection stage.
+/// * `latency` - The round trip time of the shard's last heartbeat, if one was acknowledged.
+/// * `last_event` - Time since the shard last received a gateway event, if it has received any.
+pub fn format_shard(
+    language: Language,
+    shard_id: ShardId,
+    stage: ConnectionStage,
+    latency: Option<Duration>,
+    last_event: Option<Duration>,
+) -> String {
+    let marker = if stage == ConnectionStage::Connected {
+        "🟢"
+    } else {
+        "🔴"
+    };
+    let latency = latency.map_or_else(
+        || i18n::text(language, "status.no_heartbeat", &[]),
+        |latency| {
+            i18n::text(
+                language,
+                "status.latency",
+                &[("latency", &latency.as_millis().to_string())],
+            )
+        },
+    );
+    let last_event = last_event.map_or_else(
+        || i18n::text(language, "status.never", &[]),
+        |age| {
+            i18n::text(
+                language,
+                "status.last_event",
+                &[("seconds", &age.as_secs().to_string())],
+            )
+        },
+    );
+    i18n::text(
+        language,
+        "status.shard",
+        &[
+            ("marker", marker),
+            ("shard", &shard_id.to_string()),
+            ("stage", &stage.to_string()),
+            ("latency", &latency),
+            ("last_event", &last_event),
+        ],
+    )
+}
 
 /// Displays the bot's current status, including uptime and scheduled cleaning tasks.
 ///
-/// This command provides information about how long the bot has been running
-/// and how many cleaning tasks are currently scheduled for the guild where
-/// the command is invoked.
+/// This command provides information about when the bot was started, shown in
+/// each viewer's local time, and how many cleaning tasks are currently scheduled for the guild where
+/// the command is invoked, followed by the connection stage, latency and last
+/// gateway activity of every shard. The reply is an embed with a field for
+/// each scheduled channel, split into pages when there are many.
 ///
 /// # Arguments
 ///
@@ -36,19 +102,61 @@ use crate::{Context, EuleError};
 #[poise::command(slash_command, prefix_command)]
 pub async fn status(ctx: Context<'_>) -> Result<(), EuleError> {
     let guild_id = ctx.guild_id().ok_or(EuleError::NotInGuild)?;
-    let uptime = ctx.data().bot.uptime();
-    let task_count = ctx.data().autoclean_manager.task_count(guild_id).await;
+    let started_at = ctx.data().bot.started_at();
+    let tasks = ctx.data().autoclean_manager.guild_tasks(guild_id).await;
 
-    let days = uptime.as_secs() / 86400;
-    let hours = (uptime.as_secs() % 86400) / 3600;
-    let minutes = (uptime.as_secs() % 3600) / 60;
-    let seconds = uptime.as_secs() % 60;
+    let language = i18n::language(ctx).await;
+    let mut message = i18n::text(
+        language,
+        "status.greeting",
+        &[
+            ("started", &discord_time::full(started_at)),
+            ("started_relative", &discord_time::relative(started_at)),
+            ("count", &humanize::count(tasks.len() as u64)),
+        ],
+    );
 
-    ctx.say(format!(
-        "You look kind of familiar... have we met before? 🤔\nUptime: {} days, {} hours, {} minutes, {} seconds\nScheduled Cleaning Tasks: {} 🧹",
-        days, hours, minutes, seconds, task_count
-    ))
-    .await?;
+    let shard_manager = ctx.framework().shard_manager();
+    let mut shards: Vec<_> = shard_manager
+        .runners
+        .lock()
+        .await
+        .iter()
+        .map(|(id, runner)| (*id, runner.stage, runner.latency))
+        .collect();
+    shards.sort_by_key(|(id, _, _)| *id);
+    if !shards.is_empty() {
+        let shard_events = ctx
+            .data()
+            .shard_events
+            .lock()
+            .map_err(|e| EuleError::LockError(e.to_string()))?
+            .clone();
+        message.push_str("\n\n");
+        message.push_str(&i18n::text(language, "status.shards", &[]));
+        for (shard_id, stage, latency) in shards {
+            let last_event = shard_events.get(&shard_id).map(|at| at.elapsed());
+            message.push('\n');
+            message.push_str(&format_shard(
+                language, shard_id, stage, latency, last_event,
+            ));
+        }
+    }
 
-    Ok(())
+    let manager = &ctx.data().autoclean_manager;
+    let mut fields = Vec::with_capacity(tasks.len());
+    for (channel_id, task) in &tasks {
+        let state = manager.task_state(guild_id, *channel_id, task).await;
+        fields.push(task_field(language, channel_id, task, state));
+    }
+    let pages = paginate_fields(fields, FIELDS_PER_PAGE)
+        .into_iter()
+        .map(|fields| {
+            CreateEmbed::new()
+                .title(i18n::text(language, "status.title", &[]))
+                .description(message.clone())
+                .fields(fields.into_iter().map(|(name, value)| (name, value, false)))
+        })
+        .collect();
+    send_paginated(ctx, pages).await
 }
@@ -0,0 +1,178 @@
+//! Reconciliation of registered application commands with the bot's definitions.
+//!
+//! On startup the commands Discord currently has registered are compared with the
+//! ones the bot defines. Missing commands are created, changed ones are edited,
+//! and commands that no longer exist in the code are deleted, so renamed or
+//! removed commands don't linger.
+
+use crate::error::EuleError;
+use poise::serenity_prelude::{Command, CommandId, CreateCommand, GuildId, Http};
+use serde_json::Value;
+use std::collections::HashMap;
+
+/// Fields compared when deciding whether a registered command is up to date.
+const COMPARED_FIELDS: &[&str] = &[
+    "name",
+    "description",
+    "options",
+    "default_member_permissions",
+    "dm_permission",
+    "nsfw",
+    "name_localizations",
+    "description_localizations",
+];
+
+/// The changes needed to bring the registered commands in line with the definitions.
+#[derive(Debug, Default)]
+pub struct SyncPlan {
+    /// Commands that are not registered yet.
+    pub create: Vec<CreateCommand>,
+    /// Registered commands whose definition has changed.
+    pub update: Vec<(CommandId, CreateCommand)>,
+    /// Registered commands that are no longer defined, with their names.
+    pub delete: Vec<(CommandId, String)>,
+    /// The number of registered commands that are already up to date.
+    pub unchanged: usize,
+}
+
+impl SyncPlan {
+    /// Returns `true` if no changes are needed.
+    pub fn is_empty(&self) -> bool {
+        self.create.is_empty() && self.update.is_empty() && self.delete.is_empty()
+    }
+}
+
+/// Removes fields that Discord and the builders represent inconsistently.
+///
+/// Nulls, empty collections and `false` flags are treated as absent, so a
+/// registered command compares equal to the builder it was created from.
+fn normalize(value: Value) -> Value {
+    match value {
+        Value::Object(map) => Value::Object(
+            map.into_iter()
+                .map(|(key, value)| (key, normalize(value)))
+                .filter(|(_, value)| !is_blank(value))
+                .collect(),
+        ),
+        Value::Array(items) => Value::Array(items.into_iter().map(normalize).collect()),
+        other => other,
+    }
+}
+
+fn is_blank(value: &Value) -> bool {
+    match value {
+        Value::Null | Value::Bool(false) => true,
+        Value::Array(items) => items.is_empty(),
+        Value::Object(map) => map.is_empty(),
+        _ => false,
+    }
+}
+
+/// Extracts the compared fields of a command definition.
+fn comparable(value: &Value) -> Value {
+    let fields = COMPARED_FIELDS
+        .iter()
+        .filter_map(|field| value.get(*field).map(|v| (field.to_string(), v.clone())))
+        .collect();
+    normalize(Value::Object(fields))
+}
+
+/// Computes the changes needed to reconcile registered commands with definitions.
+///
+/// # Arguments
+/// * `registered` - The registered commands as returned by Discord, with their IDs
+/// * `desired` - The commands the bot defines
+pub fn plan(registered: &[(CommandId, Value)], desired: Vec<CreateCommand>) -> SyncPlan {
+    let mut existing: HashMap<String, (CommandId, Value)> = registered
+        .iter()
+        .filter_map(|(id, value)| {
+            let name = value.get("name")?.as_str()?.to_string();
+            Some((name, (*id, comparable(value))))
+        })
+        .collect();
+
+    let mut sync_plan = SyncPlan::default();
+    for command in desired {
+        let definition = comparable(&serde_json::to_value(&command).unwrap_or_default());
+        let name = definition
+            .get("name")
+            .and_then(Value::as_str)
+            .unwrap_or_default()
+            .to_string();
+        match existing.remove(&name) {
+            None => sync_plan.create.push(command),
+            Some((_, current)) if current == definition => sync_plan.unchanged += 1,
+            Some((id, _)) => sync_plan.update.push((id, command)),
+        }
+    }
+    sync_plan.delete = existing
+        .into_iter()
+        .map(|(name, (id, _))| (id, name))
+        .collect();
+    sync_plan.delete.sort_by(|a, b| a.1.cmp(&b.1));
+    sync_plan
+}
+
+/// Reconciles the registered commands with the bot's definitions.
+///
+/// Commands are synced in `guild_id` if given, and globally otherwise.
+///
+/// # Arguments
+/// * `http` - An HTTP client with the application ID set
+/// * `guild_id` - The guild to sync, or `None` for global commands
+/// * `desired` - The commands the bot defines
+///
+/// # Returns
+/// The plan that was applied.
+pub async fn sync_commands(
+    http: &Http,
+    guild_id: Option<GuildId>,
+    desired: Vec<CreateCommand>,
+) -> Result<SyncPlan, EuleError> {
+    let registered = match guild_id {
+        Some(guild_id) => guild_id.get_commands(http).await?,
+        None => Command::get_global_commands(http).await?,
+    };
+    let registered: Vec<(CommandId, Value)> = registered
+        .iter()
+        .map(|command| {
+            let value = serde_json::to_value(command).map_err(EuleError::Serialization)?;
+            Ok((command.id, value))
+        })
+        .collect::<Result<_, EuleError>>()?;
+
+    let sync_plan = plan(&registered, desired);
+    if sync_plan.is_empty() {
+        tracing::info!("{} commands already up to date", sync_plan.unchanged);
+        return Ok(sync_plan);
+    }
+
+    for command in &sync_plan.create {
+        match guild_id {
+            Some(guild_id) => guild_id.create_command(http, command.clone()).await?,
+            None => Command::create_global_command(http, command.clone()).await?,
+        };
+    }
+    for (id, command) in &sync_plan.update {
+        match guild_id {
+            Some(guild_id) => guild_id.edit_command(http, *id, command.clone()).await?,
+            None => Command::edit_global_command(http, *id, command.clone()).await?,
+        };
+    }
+    for (id, name) in &sync_plan.delete {
+        match guild_id {
+            Some(guild_id) => guild_id.delete_command(http, *id).await?,
+            None => Command::delete_global_command(http, *id).await?,
+        };
+        tracing::info!("Deleted stale command /{}", name);
+    }
+
+    tracing::info!(
+        "Command sync complete: {} created, {} updated, {} deleted, {} unchanged",
+        sync_plan.create.len(),
+        sync_plan.update.len(),
+        sync_plan.delete.len(),
+        sync_plan.unchanged
+    );
+    Ok(sync_plan)
+}
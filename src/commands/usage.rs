@@ -0,0 +1,85 @@
+//! Counters of how the bot's commands are used.
+//!
+//! Every command that passes its checks is counted under its full name, such
+//! as `autoclean add`, along with whether it failed and how long it took to
+//! answer. The counts cover the whole process and start over when it
+//! restarts. The admin API serves them at `/api/commands`, and `/debug`
+//! includes them in its snapshot, so it shows which commands are used and
+//! which often fail.
+
+use crate::Context;
+use serde::Serialize;
+use std::{collections::BTreeMap, sync::Mutex, time::Instant};
+use tokio::time::Duration;
+
+/// The invocations of one command.
+#[derive(Clone, Copy, Debug, Default, PartialEq, Serialize)]
+pub struct CommandStats {
+    /// The number of times the command ran.
+    pub invocations: u64,
+    /// The number of those that failed.
+    pub errors: u64,
+    /// The share of invocations that failed, from 0 to 1.
+    pub error_rate: f64,
+    /// The average time the command took, in milliseconds.
+    pub average_ms: u64,
+    /// The longest time the command took, in milliseconds.
+    pub max_ms: u64,
+    /// The total time the command took, in milliseconds.
+    #[serde(skip)]
+    total_ms: u64,
+}
+
+impl CommandStats {
+    fn add(&mut self, took: Duration, failed: bool) {
+        let took = took.as_millis() as u64;
+        self.invocations += 1;
+        self.errors += u64::from(failed);
+        self.total_ms += took;
+        self.max_ms = self.max_ms.max(took);
+        self.error_rate = self.errors as f64 / self.invocations as f64;
+        self.average_ms = self.total_ms / self.invocations;
+    }
+}
+
+static USAGE: Mutex<BTreeMap<String, CommandStats>> = Mutex::new(BTreeMap::new());
+
+/// Records one invocation of a command.
+///
+/// # Parameters
+/// - `command`: The command's full name, such as `autoclean add`.
+/// - `took`: How long the command took.
+/// - `failed`: Whether the command failed.
+pub fn record(command: &str, took: Duration, failed: bool) {
+    if let Ok(mut usage) = USAGE.lock() {
+        usage
+            .entry(command.to_string())
+            .or_default()
+            .add(took, failed);
+    }
+}
+
+/// Returns the invocations of every command used since the process started,
+/// by name.
+pub fn command_usage() -> BTreeMap<String, CommandStats> {
+    USAGE.lock().map(|usage| usage.clone()).unwrap_or_default()
+}
+
+/// Notes when a command started, before it runs.
+pub async fn start(ctx: Context<'_>) {
+    ctx.set_invocation_data(Some(Instant::now())).await;
+}
+
+/// Records a command that ran to the end or failed.
+///
+/// Commands that never started, because a check or cooldown stopped them,
+/// aren't recorded, and a command is only recorded once.
+pub async fn finish(ctx: Context<'_>, failed: bool) {
+    let started = ctx
+        .invocation_data::<Option<Instant>>()
+        .await
+        .and_then(|mut started| started.take());
+    if let Some(started) = started {
+        record(&ctx.command().qualified_name, started.elapsed(), failed);
+    }
+}
@@ -0,0 +1,878 @@
+//! Bot configuration.
+//!
+//! Settings are read from an optional TOML file. Every field has a default, so an
+//! empty or missing file yields a working configuration.
+//!
+//! ```toml
+//! [features]
+//! live_moderation = false
+//! message_triggers = false
+//! spam_bursts = false
+//!
+//! [gateway]
+//! guild_members = false
+//!
+//! [replies]
+//! delete_after_secs = 30
+//!
+//! [purge]
+//! old_message_delay_ms = 500
+//! request_timeout_secs = 30
+//!
+//! [proxy]
+//! url = "socks5://proxy.example.com:1080"
+//!
+//! [phishing]
+//! feed_url = "https://phishing.example.com/domains.json"
+//! refresh_secs = 3600
+//! live = false
+//!
+//! [presence]
+//! interval_secs = 300
+//!
+//! [[presence.activities]]
+//! kind = "watching"
+//! text = "{guilds} servers"
+//!
+//! [[presence.activities]]
+//! kind = "custom"
+//! text = "{purged_today} messages purged today"
+//!
+//! [admin]
+//! listen = "127.0.0.1:8080"
+//!
+//! [dashboard]
+//! client_id = 123456789012345678
+//! public_url = "https://eule.example.com"
+//!
+//! [webhooks]
+//! urls = ["https://siem.example.com/hooks/eule"]
+//!
+//! [bus]
+//! url = "nats://127.0.0.1:4222"
+//! subject = "eule"
+//!
+//! [alerts]
+//! update_check = true
+//!
+//! [alerts.matrix]
+//! homeserver = "https://matrix.example.com"
+//! room_id = "!ops:example.com"
+//!
+//! [email.smtp]
+//! host = "smtp.example.com"
+//! username = "eule"
+//! from = "eule@example.com"
+//!
+//! [[email.reports]]
+//! guild_id = 123456789012345678
+//! to = ["owner@example.com"]
+//! period = "monthly"
+//!
+//! [leader]
+//! url = "redis://127.0.0.1:6379"
+//! ttl_secs = 15
+//!
+//! [bot_lists]
+//! interval_secs = 1800
+//!
+//! [[bot_lists.sites]]
+//! name = "topgg"
+//! url = "https://top.gg/api/bots/{bot_id}/stats"
+//! shard_field = "shard_count"
+//!
+//! [[bots]]
+//! name = "community-a"
+//! token_file = "/run/secrets/community-a"
+//!
+//! [[bots]]
+//! name = "community-b"
+//! ```
+
+use crate::error::EuleError;
+use poise::serenity_prelude::GatewayIntents;
+use serde::Deserialize;
+use std::{
+    collections::HashSet,
+    fs,
+    net::SocketAddr,
+    path::{Path, PathBuf},
+    time::Duration,
+};
+
+/// The configuration file read when `--config` is not given, if it exists.
+pub const DEFAULT_CONFIG_PATH: &str = "eule.toml";
+
+/// Top-level bot configuration.
+#[derive(Clone, Debug, Default, Deserialize)]
+#[serde(default, deny_unknown_fields)]
+pub struct BotConfig {
+    /// Optional features that change what the bot needs from Discord.
+    pub features: FeaturesConfig,
+    /// Gateway intents requested on top of those the enabled features need.
+    pub gateway: GatewayConfig,
+    /// The activities shown in the bot's presence.
+    pub presence: PresenceConfig,
+    /// The HTTP admin API.
+    pub admin: AdminConfig,
+    /// The web dashboard, served alongside the admin API.
+    pub dashboard: DashboardConfig,
+    /// Webhooks notified as purges start and finish.
+    pub webhooks: WebhookConfig,
+    /// The message bus purge events and task changes are published to.
+    pub bus: BusConfig,
+    /// Alerts sent to operators outside Discord.
+    pub alerts: AlertsConfig,
+    /// Scheduled email reports of purge activity.
+    pub email: EmailConfig,
+    /// Leader election between replicas.
+    pub leader: LeaderConfig,
+    /// What happens to the bot's replies once they have been read.
+    pub replies: RepliesConfig,
+    /// Pacing of purges that their tasks don't override.
+    pub purge: PurgeConfig,
+    /// The proxy outbound HTTP requests go through.
+    pub proxy: ProxyConfig,
+    /// The feed of phishing domains whose links are deleted.
+    pub phishing: PhishingConfig,
+    /// Bot listing sites the bot's guild count is posted to.
+    pub bot_lists: BotListsConfig,
+    /// Bot identities to run side by side, each with its own token and tasks.
+    ///
+    /// When empty, a single bot runs with the token from the command line, the
+    /// environment or the store.
+    pub bots: Vec<BotIdentityConfig>,
+}
+
+/// Toggles for optional features.
+#[derive(Clone, Debug, Default, Deserialize)]
+#[serde(default, deny_unknown_fields)]
+pub struct FeaturesConfig {
+    /// Features that react to messages as they are posted.
+    ///
+    /// Requires the privileged message content intent.
+    pub live_moderation: bool,
+    /// Cleanups triggered by how many messages a channel received, see
+    /// `/autoclean messages`.
+    ///
+    /// Requires the message intent, which isn't privileged.
+    pub message_triggers: bool,
+    /// Deleting spam bursts as they are posted, see `/autoclean burst`.
+    ///
+    /// Requires the message intent, which isn't privileged.
+    pub spam_bursts: bool,
+}
+
+/// Extra gateway intents.
+#[derive(Clone, Debug, Default, Deserialize)]
+#[serde(default, deny_unknown_fields)]
+pub struct GatewayConfig {
+    /// Receive message create/update/delete events.
+    pub guild_messages: bool,
+    /// Receive the content of messages. This intent is privileged.
+    pub message_content: bool,
+    /// Receive member join/leave events. This intent is privileged.
+    pub guild_members: bool,
+}
+
+/// The rotation of activities shown in the bot's presence.
+#[derive(Clone, Debug, Deserialize)]
+#[serde(default, deny_unknown_fields)]
+pub struct PresenceConfig {
+    /// Seconds between switching to the next activity.
+    pub interval_secs: u64,
+    /// The activities to cycle through, in order.
+    ///
+    /// Texts may contain the placeholders `{guilds}`, `{tasks}` and
+    /// `{purged_today}`, which are filled in each time the activity is shown.
+    pub activities: Vec<PresenceActivity>,
+}
+
+impl Default for PresenceConfig {
+    fn default() -> Self {
+        Self {
+            interval_secs: 300,
+            activities: vec![PresenceActivity {
+                kind: ActivityKind::Listening,
+                text: "Cigarette Wife".to_string(),
+            }],
+        }
+    }
+}
+
+/// A single activity in the presence rotation.
+#[derive(Clone, Debug, Deserialize)]
+#[serde(deny_unknown_fields)]
+pub struct PresenceActivity {
+    /// How the activity is introduced, e.g. "Listening to".
+    #[serde(default)]
+    pub kind: ActivityKind,
+    /// The activity text, possibly containing placeholders.
+    pub text: String,
+}
+
+/// The type of a presence activity.
+#[derive(Clone, Copy, Debug, Default, PartialEq, Eq, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum ActivityKind {
+    Playing,
+    #[default]
+    Listening,
+    Watching,
+    Competing,
+    /// A custom status showing only the text.
+    Custom,
+}
+
+/// The environment variable consulted for the admin API token.
+pub const ADMIN_TOKEN_ENV_VAR: &str = "EULE_ADMIN_TOKEN";
+
+/// The HTTP admin API, disabled unless `listen` is set.
+#[derive(Clone, Debug, Default, Deserialize)]
+#[serde(default, deny_unknown_fields)]
+pub struct AdminConfig {
+    /// The address to serve the API on, such as `127.0.0.1:8080`.
+    pub listen: Option<SocketAddr>,
+    /// The address to serve the gRPC control plane on, such as `127.0.0.1:50051`.
+    ///
+    /// Requires a build with the `grpc` feature.
+    pub grpc_listen: Option<SocketAddr>,
+    /// The bearer token clients must send.
+    ///
+    /// Prefer setting `EULE_ADMIN_TOKEN` over keeping the token in the file.
+    pub token: Option<String>,
+    /// The address the listener is reached at, used in calendar feed links.
+    ///
+    /// Defaults to `dashboard.public_url`.
+    pub public_url: Option<String>,
+}
+
+impl AdminConfig {
+    /// Resolves the API token from the configuration or the environment.
+    ///
+    /// # Arguments
+    /// * `env_token` - The value of the `EULE_ADMIN_TOKEN` environment variable, if set
+    ///
+    /// # Returns
+    /// The configured token, then the environment's, or `None` if neither is
+    /// set to a non-empty value.
+    pub fn resolve_token(&self, env_token: Option<String>) -> Option<String> {
+        self.token
+            .clone()
+            .or(env_token)
+            .map(|token| token.trim().to_string())
+            .filter(|token| !token.is_empty())
+    }
+}
+
+/// The environment variable consulted for the dashboard's OAuth2 client secret.
+pub const OAUTH_SECRET_ENV_VAR: &str = "EULE_OAUTH_SECRET";
+
+/// The web dashboard, disabled unless `client_id` and `public_url` are set.
+///
+/// The dashboard is served on the admin API's listener, so `admin.listen` must
+/// be set as well.
+#[derive(Clone, Debug, Default, Deserialize)]
+#[serde(default, deny_unknown_fields)]
+pub struct DashboardConfig {
+    /// The Discord application's OAuth2 client ID.
+    pub client_id: Option<u64>,
+    /// The OAuth2 client secret.
+    ///
+    /// Prefer setting `EULE_OAUTH_SECRET` over keeping the secret in the file.
+    pub client_secret: Option<String>,
+    /// The address users reach the dashboard at, used for the OAuth2 redirect.
+    pub public_url: Option<String>,
+}
+
+impl DashboardConfig {
+    /// Resolves the client secret from the configuration or the environment.
+    ///
+    /// # Arguments
+    /// * `env_secret` - The value of the `EULE_OAUTH_SECRET` environment variable, if set
+    pub fn resolve_secret(&self, env_secret: Option<String>) -> Option<String> {
+        self.client_secret
+            .clone()
+            .or(env_secret)
+            .map(|secret| secret.trim().to_string())
+            .filter(|secret| !secret.is_empty())
+    }
+}
+
+/// The environment variable consulted for the webhook signing secret.
+pub const WEBHOOK_SECRET_ENV_VAR: &str = "EULE_WEBHOOK_SECRET";
+
+/// Outbound webhooks, disabled unless `urls` is non-empty.
+#[derive(Clone, Debug, Default, Deserialize)]
+#[serde(default, deny_unknown_fields)]
+pub struct WebhookConfig {
+    /// The URLs every purge event is POSTed to.
+    pub urls: Vec<String>,
+    /// The secret payloads are signed with.
+    ///
+    /// Prefer setting `EULE_WEBHOOK_SECRET` over keeping the secret in the file.
+    pub secret: Option<String>,
+}
+
+impl WebhookConfig {
+    /// Resolves the signing secret from the configuration or the environment.
+    ///
+    /// # Arguments
+    /// * `env_secret` - The value of the `EULE_WEBHOOK_SECRET` environment variable, if set
+    pub fn resolve_secret(&self, env_secret: Option<String>) -> Option<String> {
+        self.secret
+            .clone()
+            .or(env_secret)
+            .map(|secret| secret.trim().to_string())
+            .filter(|secret| !secret.is_empty())
+    }
+}
+
+/// Publication to a message bus, disabled unless `url` is set.
+#[derive(Clone, Debug, Deserialize)]
+#[serde(default, deny_unknown_fields)]
+pub struct BusConfig {
+    /// A `nats://` or `redis://` URL, optionally with credentials.
+    pub url: Option<String>,
+    /// The NATS subject prefix, or the name of the Redis stream.
+    pub subject: String,
+}
+
+impl Default for BusConfig {
+    fn default() -> Self {
+        Self {
+            url: None,
+            subject: "eule".to_string(),
+        }
+    }
+}
+
+/// A bot identity run alongside others in the same process.
+///
+/// Each identity connects with its own token and keeps its own cleanup tasks in
+/// the shared store. The admin API, dashboard and notifications are served for
+/// the first identity.
+#[derive(Clone, Debug, Deserialize)]
+#[serde(deny_unknown_fields)]
+pub struct BotIdentityConfig {
+    /// A short name for the identity, used in logs and to keep its tasks apart.
+    pub name: String,
+    /// The identity's Discord token.
+    ///
+    /// Prefer `token_file` or the environment variable named by
+    /// `token_env_var` over keeping the token in the file.
+    pub token: Option<String>,
+    /// A file to read the identity's Discord token from.
+    pub token_file: Option<PathBuf>,
+}
+
+impl BotIdentityConfig {
+    /// Returns the environment variable consulted for the identity's token,
+    /// such as `EULE_TOKEN_COMMUNITY_A` for an identity named `community-a`.
+    pub fn token_env_var(&self) -> String {
+        format!(
+            "EULE_TOKEN_{}",
+            self.name.to_ascii_uppercase().replace('-', "_")
+        )
+    }
+}
+
+/// Leader election, disabled unless `url` is set.
+///
+/// With election enabled, several replicas can be started and only the one
+/// holding the lease opens the store, connects to Discord and runs cleanups.
+/// The others wait to take over if it stops renewing the lease.
+#[derive(Clone, Debug, Deserialize)]
+#[serde(default, deny_unknown_fields)]
+pub struct LeaderConfig {
+    /// A `redis://` URL of the server the lease is kept on.
+    pub url: Option<String>,
+    /// The key the lease is stored under.
+    pub key: String,
+    /// How long a lease lasts without being renewed, in seconds.
+    ///
+    /// This is how long a crashed leader goes unnoticed before a standby takes over.
+    pub ttl_secs: u64,
+}
+
+impl Default for LeaderConfig {
+    fn default() -> Self {
+        Self {
+            url: None,
+            key: "eule:leader".to_string(),
+            ttl_secs: 15,
+        }
+    }
+}
+
+/// The bot's replies to commands.
+#[derive(Clone, Debug, Default, Deserialize)]
+#[serde(default, deny_unknown_fields)]
+pub struct RepliesConfig {
+    /// Confirmation and status replies visible to everyone are deleted this many
+    /// seconds after they are sent, so the bot doesn't clutter the channels it
+    /// keeps clean. Replies are kept when unset or zero.
+    pub delete_after_secs: Option<u64>,
+}
+
+impl RepliesConfig {
+    /// Returns how long replies are kept, or `None` if they are never deleted.
+    pub fn delete_after(&self) -> Option<Duration> {
+        self.delete_after_secs
+            .filter(|secs| *secs > 0)
+            .map(Duration::from_secs)
+    }
+}
+
+/// Pacing of purges.
+#[derive(Clone, Debug, Default, Deserialize)]
+#[serde(default, deny_unknown_fields)]
+pub struct PurgeConfig {
+    /// Milliseconds to wait after deleting each message older than 14 days,
+    /// which Discord only deletes one at a time. Tasks can set their own.
+    pub old_message_delay_ms: u64,
+    /// Seconds a single Discord request may take before it is given up on.
+    /// Defaults to 30. Clients pick it up when they are created, so a reload
+    /// takes effect with the next gateway session.
+    pub request_timeout_secs: Option<u64>,
+}
+
+impl PurgeConfig {
+    /// Returns the pause after each delete of a message older than 14 days.
+    pub fn old_message_delay(&self) -> Duration {
+        Duration::from_millis(self.old_message_delay_ms)
+    }
+
+    /// Returns how long a single Discord request may take.
+    pub fn request_timeout(&self) -> Duration {
+        self.request_timeout_secs
+            .filter(|secs| *secs > 0)
+            .map_or(crate::proxy::DEFAULT_REQUEST_TIMEOUT, Duration::from_secs)
+    }
+}
+
+/// An outbound proxy, see `proxy`.
+#[derive(Clone, Debug, Default, Deserialize)]
+#[serde(default, deny_unknown_fields)]
+pub struct ProxyConfig {
+    /// The proxy's URL, such as `http://proxy.example.com:3128` or
+    /// `socks5://proxy.example.com:1080`. Requests go out directly when unset.
+    ///
+    /// Only HTTP requests use it. The gateway's websocket is opened by
+    /// Serenity, which can't be given a proxy, so `gateway.discord.gg` must
+    /// still be reachable directly or through a transparent proxy. The bot
+    /// won't start if it isn't.
+    pub url: Option<String>,
+}
+
+/// Deleting links to phishing sites, see `phishing`.
+#[derive(Clone, Debug, Default, Deserialize)]
+#[serde(default, deny_unknown_fields)]
+pub struct PhishingConfig {
+    /// The address of a list of phishing domains, as a JSON array or one
+    /// domain per line. Links aren't checked when unset.
+    pub feed_url: Option<String>,
+    /// Seconds between two fetches of the feed. Defaults to an hour.
+    pub refresh_secs: Option<u64>,
+    /// Also delete phishing links as they are posted, not just in cleanups.
+    ///
+    /// Requires the privileged message content intent.
+    pub live: bool,
+}
+
+impl PhishingConfig {
+    /// Returns how often the feed is fetched.
+    pub fn refresh(&self) -> Duration {
+        self.refresh_secs
+            .map_or(crate::phishing::DEFAULT_REFRESH, Duration::from_secs)
+    }
+}
+
+/// Posting the bot's guild count to bot listing sites, disabled unless
+/// `sites` is non-empty.
+#[derive(Clone, Debug, Default, Deserialize)]
+#[serde(default, deny_unknown_fields)]
+pub struct BotListsConfig {
+    /// Seconds between two posts. Defaults to half an hour.
+    pub interval_secs: Option<u64>,
+    /// The sites to post to.
+    pub sites: Vec<BotListSite>,
+}
+
+impl BotListsConfig {
+    /// Returns how often the guild count is posted.
+    pub fn interval(&self) -> Duration {
+        self.interval_secs
+            .map_or(crate::bot_lists::DEFAULT_INTERVAL, Duration::from_secs)
+    }
+}
+
+/// A bot listing site, such as top.gg, the guild count is posted to.
+#[derive(Clone, Debug, Deserialize)]
+#[serde(deny_unknown_fields)]
+pub struct BotListSite {
+    /// A short name for the site, used in logs and for its token's
+    /// environment variable.
+    pub name: String,
+    /// The stats endpoint, where `{bot_id}` is replaced with the bot's ID.
+    pub url: String,
+    /// The API key sent in the `Authorization` header.
+    ///
+    /// Prefer the environment variable named by `token_env_var` over keeping
+    /// the key in the file.
+    pub token: Option<String>,
+    /// The JSON field the guild count is sent in.
+    #[serde(default = "default_guild_field")]
+    pub guild_field: String,
+    /// The JSON field the shard count is sent in, if the site wants it.
+    pub shard_field: Option<String>,
+    /// The identity posting to the site when several bots run, since each
+    /// listing belongs to one bot. Every bot posts when unset.
+    pub identity: Option<String>,
+}
+
+fn default_guild_field() -> String {
+    "server_count".to_string()
+}
+
+impl BotListSite {
+    /// Returns the environment variable consulted for the site's token, such
+    /// as `EULE_BOT_LIST_TOKEN_TOPGG` for a site named `topgg`.
+    pub fn token_env_var(&self) -> String {
+        format!(
+            "EULE_BOT_LIST_TOKEN_{}",
+            self.name.to_ascii_uppercase().replace('-', "_")
+        )
+    }
+
+    /// Resolves the token from the configuration or the environment.
+    ///
+    /// # Arguments
+    /// * `env_token` - The value of the variable named by `token_env_var`, if set
+    pub fn resolve_token(&self, env_token: Option<String>) -> Option<String> {
+        self.token
+            .clone()
+            .or(env_token)
+            .map(|token| token.trim().to_string())
+            .filter(|token| !token.is_empty())
+    }
+}
+
+/// The environment variable consulted for the Matrix access token.
+pub const MATRIX_TOKEN_ENV_VAR: &str = "EULE_MATRIX_TOKEN";
+
+/// Operator alerts, disabled unless a Slack webhook or Matrix room is set.
+#[derive(Clone, Debug, Default, Deserialize)]
+#[serde(default, deny_unknown_fields)]
+pub struct AlertsConfig {
+    /// A Slack incoming webhook URL.
+    pub slack_webhook: Option<String>,
+    /// A Matrix room to post alerts in.
+    pub matrix: Option<MatrixConfig>,
+    /// Whether to alert when a new release is published.
+    pub update_check: bool,
+}
+
+/// A Matrix room alerts are posted in.
+#[derive(Clone, Debug, Deserialize)]
+#[serde(deny_unknown_fields)]
+pub struct MatrixConfig {
+    /// The homeserver's base URL.
+    pub homeserver: String,
+    /// The room's ID, which the token's user must have joined.
+    pub room_id: String,
+    /// The access token to post with.
+    ///
+    /// Prefer setting `EULE_MATRIX_TOKEN` over keeping the token in the file.
+    pub access_token: Option<String>,
+}
+
+impl MatrixConfig {
+    /// Resolves the access token from the configuration or the environment.
+    ///
+    /// # Arguments
+    /// * `env_token` - The value of the `EULE_MATRIX_TOKEN` environment variable, if set
+    pub fn resolve_token(&self, env_token: Option<String>) -> Option<String> {
+        self.access_token
+            .clone()
+            .or(env_token)
+            .map(|token| token.trim().to_string())
+            .filter(|token| !token.is_empty())
+    }
+}
+
+/// The environment variable consulted for the SMTP password.
+pub const SMTP_PASSWORD_ENV_VAR: &str = "EULE_SMTP_PASSWORD";
+
+/// Scheduled email reports, disabled unless `reports` is non-empty.
+#[derive(Clone, Debug, Default, Deserialize)]
+#[serde(default, deny_unknown_fields)]
+pub struct EmailConfig {
+    /// The relay reports are sent through; required when reports are configured.
+    pub smtp: Option<SmtpConfig>,
+    /// The guilds to report on.
+    pub reports: Vec<EmailReportConfig>,
+}
+
+/// An SMTP relay.
+#[derive(Clone, Debug, Deserialize)]
+#[serde(deny_unknown_fields)]
+pub struct SmtpConfig {
+    pub host: String,
+    #[serde(default = "default_smtp_port")]
+    pub port: u16,
+    /// Whether to upgrade the connection with STARTTLS instead of connecting
+    /// over TLS directly.
+    #[serde(default = "default_starttls")]
+    pub starttls: bool,
+    pub username: Option<String>,
+    /// Prefer setting `EULE_SMTP_PASSWORD` over keeping the password in the file.
+    pub password: Option<String>,
+    /// The address reports are sent from.
+    pub from: String,
+}
+
+fn default_smtp_port() -> u16 {
+    587
+}
+
+fn default_starttls() -> bool {
+    true
+}
+
+impl SmtpConfig {
+    /// Resolves the password from the configuration or the environment.
+    ///
+    /// # Arguments
+    /// * `env_password` - The value of the `EULE_SMTP_PASSWORD` environment variable, if set
+    pub fn resolve_password(&self, env_password: Option<String>) -> Option<String> {
+        self.password
+            .clone()
+            .or(env_password)
+            .filter(|password| !password.is_empty())
+    }
+}
+
+/// How often a report is sent.
+#[derive(Clone, Copy, Debug, Default, PartialEq, Eq, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum ReportPeriod {
+    Weekly,
+    #[default]
+    Monthly,
+}
+
+/// A guild's email report.
+#[derive(Clone, Debug, Deserialize)]
+#[serde(deny_unknown_fields)]
+pub struct EmailReportConfig {
+    pub guild_id: u64,
+    /// The addresses the report is sent to.
+    pub to: Vec<String>,
+    #[serde(default)]
+    pub period: ReportPeriod,
+}
+
+impl BotConfig {
+    /// Parses a configuration from TOML.
+    ///
+    /// # Arguments
+    /// * `contents` - The TOML document
+    pub fn from_toml(contents: &str) -> Result<Self, EuleError> {
+        let config: Self =
+            toml::from_str(contents).map_err(|e| EuleError::InvalidConfig(e.to_string()))?;
+        if config.presence.activities.is_empty() {
+            return Err(EuleError::InvalidConfig(
+                "presence.activities must not be empty".to_string(),
+            ));
+        }
+        let dashboard = &config.dashboard;
+        if dashboard.client_id.is_some() != dashboard.public_url.is_some() {
+            return Err(EuleError::InvalidConfig(
+                "dashboard.client_id and dashboard.public_url must be set together".to_string(),
+            ));
+        }
+        if dashboard.client_id.is_some() && config.admin.listen.is_none() {
+            return Err(EuleError::InvalidConfig(
+                "the dashboard is served on admin.listen, which must be set".to_string(),
+            ));
+        }
+        let mut names = HashSet::new();
+        for bot in &config.bots {
+            let valid = !bot.name.is_empty()
+                && bot
+                    .name
+                    .chars()
+                    .all(|c| c.is_ascii_alphanumeric() || c == '-' || c == '_');
+            if !valid {
+                return Err(EuleError::InvalidConfig(format!(
+                    "bot name \"{}\" may only contain letters, digits, '-' and '_'",
+                    bot.name
+                )));
+            }
+            if !names.insert(bot.name.to_ascii_lowercase()) {
+                return Err(EuleError::InvalidConfig(format!(
+                    "bot name \"{}\" is used more than once",
+                    bot.name
+                )));
+            }
+        }
+        if let Some(url) = &config.proxy.url {
+            crate::proxy::parse(url)?;
+        }
+        let leader = &config.leader;
+        if leader.url.is_some() && leader.ttl_secs < 3 {
+            return Err(EuleError::InvalidConfig(
+                "leader.ttl_secs must be at least 3".to_string(),
+            ));
+        }
+        let email = &config.email;
+        if !email.reports.is_empty() && email.smtp.is_none() {
+            return Err(EuleError::InvalidConfig(
+                "email.reports need email.smtp to be set".to_string(),
+            ));
+        }
+        if email.reports.iter().any(|report| report.to.is_empty()) {
+            return Err(EuleError::InvalidConfig(
+                "every email report needs at least one address in to".to_string(),
+            ));
+        }
+        let phishing = &config.phishing;
+        if let Some(url) = phishing
+            .feed_url
+            .as_ref()
+            .filter(|url| !url.starts_with("https://") && !url.starts_with("http://"))
+        {
+            return Err(EuleError::InvalidConfig(format!(
+                "phishing.feed_url {} must start with https:// or http://",
+                url
+            )));
+        }
+        if phishing.refresh_secs.is_some_and(|secs| secs < 60) {
+            return Err(EuleError::InvalidConfig(
+                "phishing.refresh_secs must be at least 60".to_string(),
+            ));
+        }
+        if phishing.live && phishing.feed_url.is_none() {
+            return Err(EuleError::InvalidConfig(
+                "phishing.live needs phishing.feed_url to be set".to_string(),
+            ));
+        }
+        let bot_lists = &config.bot_lists;
+        if bot_lists
+            .interval_secs
+            .is_some_and(|secs| secs < crate::bot_lists::MIN_INTERVAL.as_secs())
+        {
+            return Err(EuleError::InvalidConfig(format!(
+                "bot_lists.interval_secs must be at least {}",
+                crate::bot_lists::MIN_INTERVAL.as_secs()
+            )));
+        }
+        for site in &bot_lists.sites {
+            if !site.url.starts_with("https://") && !site.url.starts_with("http://") {
+                return Err(EuleError::InvalidConfig(format!(
+                    "bot list URL {} must start with https:// or http://",
+                    site.url
+                )));
+            }
+            if let Some(identity) = site
+                .identity
+                .as_ref()
+                .filter(|identity| !config.bots.iter().any(|bot| &bot.name == *identity))
+            {
+                return Err(EuleError::InvalidConfig(format!(
+                    "bot list {} is for the identity \"{}\", which isn't in bots",
+                    site.name, identity
+                )));
+            }
+        }
+        if let Some(url) = &config.bus.url {
+            crate::notify::bus::BusUrl::parse(url)?;
+        }
+        if let Some(url) = config
+            .webhooks
+            .urls
+            .iter()
+            .find(|url| !url.starts_with("https://") && !url.starts_with("http://"))
+        {
+            return Err(EuleError::InvalidConfig(format!(
+                "webhook URL {} must start with https:// or http://",
+                url
+            )));
+        }
+        Ok(config)
+    }
+
+    /// Loads the configuration from a file.
+    ///
+    /// # Arguments
+    /// * `path` - The path of the TOML file
+    pub fn load(path: &Path) -> Result<Self, EuleError> {
+        let contents = fs::read_to_string(path).map_err(|e| {
+            EuleError::InvalidConfig(format!("Failed to read {}: {}", path.display(), e))
+        })?;
+        Self::from_toml(&contents)
+    }
+
+    /// Loads the configuration from `path`, or from `eule.toml` if it exists.
+    ///
+    /// Falls back to the defaults when no path is given and `eule.toml` is absent.
+    pub fn load_or_default(path: Option<&Path>) -> Result<Self, EuleError> {
+        match path {
+            Some(path) => Self::load(path),
+            None if Path::new(DEFAULT_CONFIG_PATH).exists() => {
+                Self::load(Path::new(DEFAULT_CONFIG_PATH))
+            }
+            None => Ok(Self::default()),
+        }
+    }
+
+    /// Applies the settings that hold for the whole process, such as the
+    /// proxy.
+    pub fn apply(&self) {
+        // Checked when the configuration was loaded
+        if let Err(e) = crate::proxy::set_proxy(self.proxy.url.as_deref()) {
+            tracing::warn!("Not using the proxy: {}", e);
+        } else if self.proxy.url.is_some() {
+            tracing::warn!(
+                "HTTP requests go through the proxy, but the gateway connects directly; \
+                 gateway.discord.gg must be reachable without it"
+            );
+        }
+    }
+
+    /// Returns the gateway intents the bot should request.
+    ///
+    /// Only `GUILDS` is needed for slash commands and purging, which go through
+    /// interactions and the REST API, and `GUILD_SCHEDULED_EVENTS` for purges
+    /// that run when an event ends. Message intents are added when live
+    /// moderation, message triggers, spam bursts or live phishing checks are
+    /// enabled or when requested explicitly.
+    pub fn intents(&self) -> GatewayIntents {
+        let mut intents = GatewayIntents::GUILDS | GatewayIntents::GUILD_SCHEDULED_EVENTS;
+        if self.features.live_moderation
+            || self.features.message_triggers
+            || self.features.spam_bursts
+            || self.phishing.live
+            || self.gateway.guild_messages
+        {
+            intents |= GatewayIntents::GUILD_MESSAGES;
+        }
+        if self.features.live_moderation || self.phishing.live || self.gateway.message_content {
+            intents |= GatewayIntents::MESSAGE_CONTENT;
+        }
+        if self.gateway.guild_members {
+            intents |= GatewayIntents::GUILD_MEMBERS;
+        }
+        intents
+    }
+
+    /// Returns the address calendar feed links point at, if feeds are served.
+    pub fn calendar_base_url(&self) -> Option<&str> {
+        self.admin.listen?;
+        self.admin
+            .public_url
+            .as_deref()
+            .or(self.dashboard.public_url.as_deref())
+    }
+}
@@ -0,0 +1,159 @@
+//! Swapping the bot's Discord token while it runs.
+//!
+//! A new token is checked with Discord first and must belong to the
+//! application the bot is connected as, so a mistyped token or one of another
+//! bot never takes a working bot down. The bot then closes its gateway
+//! session and opens a new one with the new token. Purges keep running
+//! throughout: they reach Discord through a `SharedHttp`, which switches to the
+//! new token between two requests.
+//!
+//! Tokens are rotated through the admin API, or by replacing the token file
+//! the bot was started with and sending the process SIGHUP.
+
+use crate::{error::EuleError, proxy, store::KvStore};
+use poise::serenity_prelude::ApplicationId;
+use std::{
+    fs,
+    path::PathBuf,
+    sync::{Arc, Mutex, PoisonError},
+};
+use tokio::sync::watch;
+
+/// The store key the token is kept under when it wasn't given on startup.
+pub const TOKEN_KEY: &str = "discord_token";
+
+/// The token a bot connects with, and the means to replace it.
+pub struct TokenRotation {
+    /// The current token, watched by the bot's gateway session.
+    token: watch::Sender<String>,
+    /// The file the token was read from, if any.
+    file: Option<PathBuf>,
+    /// The store the token is kept in, if it came from there.
+    store: Option<Arc<KvStore>>,
+    /// The application the bot connected as, once it has.
+    application: Mutex<Option<ApplicationId>>,
+    /// Keeps two rotations from overlapping.
+    rotating: tokio::sync::Mutex<()>,
+}
+
+impl TokenRotation {
+    /// Creates the rotation for a bot connecting with `token`.
+    pub fn new(token: String) -> Self {
+        Self {
+            token: watch::Sender::new(token),
+            file: None,
+            store: None,
+            application: Mutex::new(None),
+            rotating: tokio::sync::Mutex::new(()),
+        }
+    }
+
+    /// Reads the token from `path` again when asked to reload it.
+    pub fn with_file(mut self, path: PathBuf) -> Self {
+        self.file = Some(path);
+        self
+    }
+
+    /// Keeps new tokens in `store`, where the bot reads its token on startup.
+    pub fn with_store(mut self, store: Arc<KvStore>) -> Self {
+        self.store = Some(store);
+        self
+    }
+
+    /// Returns the token the bot connects with now.
+    pub fn token(&self) -> String {
+        self.token.borrow().clone()
+    }
+
+    /// Returns a receiver that is notified whenever the token is replaced.
+    pub fn subscribe(&self) -> watch::Receiver<String> {
+        self.token.subscribe()
+    }
+
+    /// Records the application the bot connected as, which new tokens must
+    /// belong to.
+    pub fn connected_as(&self, application: ApplicationId) {
+        *self
+            .application
+            .lock()
+            .unwrap_or_else(PoisonError::into_inner) = Some(application);
+    }
+
+    /// Returns the application the bot connected as, or `None` before it has.
+    pub fn application(&self) -> Option<ApplicationId> {
+        *self
+            .application
+            .lock()
+            .unwrap_or_else(PoisonError::into_inner)
+    }
+
+    /// Checks a new token with Discord and switches to it.
+    ///
+    /// # Arguments
+    /// * `token` - The new token
+    ///
+    /// # Returns
+    /// `true` if the bot switched to the token, `false` if it already used it.
+    ///
+    /// # Errors
+    ///
+    /// Returns `EuleError::AuthenticationFailed` if Discord rejects the token
+    /// or it belongs to another application. The bot keeps its old token then.
+    pub async fn rotate(&self, token: &str) -> Result<bool, EuleError> {
+        let _rotating = self.rotating.lock().await;
+        let token = token.trim();
+        if token.is_empty() {
+            return Err(EuleError::AuthenticationFailed(
+                "The new token is empty".to_string(),
+            ));
+        }
+        if *self.token.borrow() == token {
+            return Ok(false);
+        }
+        let application = proxy::discord_http(token, proxy::DEFAULT_REQUEST_TIMEOUT)
+            .get_current_application_info()
+            .await
+            .map_err(|e| EuleError::AuthenticationFailed(e.to_string()))?
+            .id;
+        let current = *self
+            .application
+            .lock()
+            .unwrap_or_else(PoisonError::into_inner);
+        if let Some(current) = current.filter(|current| *current != application) {
+            return Err(EuleError::AuthenticationFailed(format!(
+                "The new token belongs to application {}, not {}",
+                application, current
+            )));
+        }
+        if let Some(store) = &self.store {
+            store.set(TOKEN_KEY, token).await?;
+        }
+        self.token.send_replace(token.to_string());
+        tracing::info!("Discord token rotated, reconnecting with the new token");
+        Ok(true)
+    }
+
+    /// Reads the token file again and switches to the token in it if it changed.
+    ///
+    /// # Returns
+    /// `true` if the bot switched to a new token, `false` if the token is
+    /// unchanged or the bot wasn't started with a token file.
+    ///
+    /// # Errors
+    ///
+    /// Returns `EuleError::InvalidConfig` if the file can't be read, and the
+    /// errors of `rotate` otherwise.
+    pub async fn reload_file(&self) -> Result<bool, EuleError> {
+        let Some(path) = &self.file else {
+            return Ok(false);
+        };
+        let token = fs::read_to_string(path).map_err(|e| {
+            EuleError::InvalidConfig(format!(
+                "Failed to read token file {}: {}",
+                path.display(),
+                e
+            ))
+        })?;
+        self.rotate(&token).await
+    }
+}
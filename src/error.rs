@@ -9,7 +9,7 @@ use owo_colors::OwoColorize;
 use poise::serenity_prelude::Error as SerenityError;
 use serde_json::Error as SerdeError;
 use sled::Error as SledError;
-use std::{fmt, io::Error as IoError};
+use std::{fmt, io::Error as IoError, time::Duration};
 use tokio::{sync::mpsc::error::SendError, task::JoinError};
 
 /// Represents all possible errors in Eule.
@@ -74,6 +74,62 @@ pub enum EuleError {
     /// Represents errors related to connection handling.
     #[diagnostic(code(eule::connection))]
     Connection(ConnectionError),
+
+    /// Represents a request rejected by Discord's rate limits, with the advised wait time.
+    #[diagnostic(code(eule::rate_limited))]
+    RateLimited(Duration),
+
+    /// Represents errors while loading or validating the configuration file.
+    #[diagnostic(code(eule::invalid_config))]
+    InvalidConfig(String),
+
+    /// Represents attempts to clean a channel type that holds no purgeable messages.
+    #[diagnostic(code(eule::unsupported_channel))]
+    UnsupportedChannel(String),
+
+    /// Represents failures of the Discord OAuth2 login flow.
+    #[diagnostic(code(eule::oauth))]
+    OAuth(String),
+
+    /// Represents failures publishing to the message bus.
+    #[diagnostic(code(eule::bus))]
+    Bus(String),
+
+    /// Represents Discord refusing an action for lack of permissions.
+    #[diagnostic(code(eule::missing_permissions))]
+    MissingPermissions(String),
+
+    /// Represents failures sending operator alerts.
+    #[diagnostic(code(eule::alert))]
+    Alert(String),
+
+    /// Represents failures sending email.
+    #[diagnostic(code(eule::email))]
+    Email(String),
+
+    /// Represents this replica losing its leader lease.
+    #[diagnostic(code(eule::leadership_lost))]
+    LeadershipLost(String),
+
+    /// Represents attempts to delete messages from a channel under a legal hold.
+    #[diagnostic(code(eule::on_hold))]
+    OnHold(String),
+
+    /// Represents work that panicked and was stopped.
+    #[diagnostic(code(eule::panicked))]
+    Panicked(String),
+
+    /// Represents Discord requests that took too long and were given up on.
+    #[diagnostic(code(eule::timed_out))]
+    TimedOut(String),
+
+    /// Represents failures fetching the phishing domain feed.
+    #[diagnostic(code(eule::feed))]
+    Feed(String),
+
+    /// Represents failures posting the guild count to a bot listing site.
+    #[diagnostic(code(eule::bot_list))]
+    BotList(String),
 }
 
 /// Conversion from std::io::Error to EuleError
@@ -152,6 +208,28 @@ impl fmt::Display for EuleError {
                 write!(f, "{}: {}", "Decryption error".red().bold(), e)
             }
             EuleError::Connection(e) => write!(f, "{}: {}", "Connection error".red().bold(), e),
+            EuleError::RateLimited(d) => {
+                write!(f, "{}: retry after {:?}", "Rate limited".yellow().bold(), d)
+            }
+            EuleError::InvalidConfig(e) => {
+                write!(f, "{}: {}", "Invalid configuration".red().bold(), e)
+            }
+            EuleError::UnsupportedChannel(e) => {
+                write!(f, "{}: {}", "Unsupported channel".red().bold(), e)
+            }
+            EuleError::OAuth(e) => write!(f, "{}: {}", "OAuth error".red().bold(), e),
+            EuleError::Bus(e) => write!(f, "{}: {}", "Message bus error".red().bold(), e),
+            EuleError::MissingPermissions(e) => {
+                write!(f, "{}: {}", "Missing permissions".red().bold(), e)
+            }
+            EuleError::Alert(e) => write!(f, "{}: {}", "Alert error".red().bold(), e),
+            EuleError::Email(e) => write!(f, "{}: {}", "Email error".red().bold(), e),
+            EuleError::LeadershipLost(e) => write!(f, "{}: {}", "Leadership lost".red().bold(), e),
+            EuleError::OnHold(e) => write!(f, "{}: {}", "Channel on hold".yellow().bold(), e),
+            EuleError::Panicked(e) => write!(f, "{}: {}", "Panicked".red().bold(), e),
+            EuleError::TimedOut(e) => write!(f, "{}: {}", "Timed out".yellow().bold(), e),
+            EuleError::Feed(e) => write!(f, "{}: {}", "Feed error".red().bold(), e),
+            EuleError::BotList(e) => write!(f, "{}: {}", "Bot list error".red().bold(), e),
         }
     }
 }
@@ -0,0 +1,382 @@
+//! Gateway event handling.
+//!
+//! Serenity transparently reconnects after gateway drops, but the framework's
+//! setup only runs for the first `Ready`. These handlers bring the bot back to a
+//! fully initialized state after a resume or a fresh session: the presence is
+//! re-applied, command registration is verified, and the scheduler is woken so
+//! cleanups that fell due while disconnected run immediately.
+//!
+//! Every event also records when its shard last heard from the gateway, which
+//! `/status` reports alongside each shard's connection stage and latency.
+//!
+//! Channels that are created or renamed are matched against their guild's
+//! name patterns, so they start or stop following the patterns' policies. New
+//! channels that no pattern matched follow their category's default policy, if
+//! it has one, and the guild's log channel is told.
+//!
+//! When a scheduled event ends, the purge bound to it with `/purge event` is
+//! planned. Cancelled and deleted events just lose their purge.
+//!
+//! Messages posted in channels whose task has a message trigger are counted,
+//! and reaching the trigger schedules a cleanup right away. Guilds that watch
+//! for spam bursts have a member's burst deleted as soon as it is posted, and
+//! their log channel is told. With `phishing.live` set, messages linking to
+//! phishing sites are deleted as they are posted, see
+//! [`phishing`](crate::phishing).
+//!
+//! Guilds that add the bot are welcomed with a setup summary, see
+//! [`onboarding`](crate::onboarding).
+//!
+//! Command errors are handled here too, so users who hit a cooldown are told
+//! when they may try again.
+
+use crate::{
+    commands::{sync::sync_commands, usage},
+    i18n, onboarding,
+    presence::{self, PresenceStats},
+    tasks::{policy::PatternOutcome, spam_burst::Burst},
+    Data, EuleError,
+};
+use poise::{
+    serenity_prelude::{
+        self as serenity, ChannelId, CreateMessage, FullEvent, GuildChannel, GuildId, Message,
+        ScheduledEvent, ScheduledEventStatus,
+    },
+    CreateReply, FrameworkError,
+};
+use std::{
+    sync::atomic::Ordering,
+    time::{Duration, Instant},
+};
+
+/// Tells a user how long until a command is off cooldown.
+///
+/// The remaining time is rounded up, so users are never told to retry before
+/// the cooldown is over.
+///
+/// # Arguments
+/// * `remaining` - The time left on the cooldown
+pub fn cooldown_message(remaining: Duration) -> String {
+    let secs = remaining.as_secs() + u64::from(remaining.subsec_nanos() > 0);
+    format!("Slow down! Try again in {}s. ⏳", secs.max(1))
+}
+
+/// Handles gateway events dispatched by the framework.
+///
+/// # Arguments
+/// * `ctx` - The Serenity context for the shard that received the event
+/// * `event` - The event itself
+/// * `framework` - The framework context, for access to the command definitions
+/// * `data` - The shared bot data
+pub async fn handle_event(
+    ctx: &serenity::Context,
+    event: &FullEvent,
+    framework: poise::FrameworkContext<'_, Data, EuleError>,
+    data: &Data,
+) -> Result<(), EuleError> {
+    if let Ok(mut shard_events) = data.shard_events.lock() {
+        shard_events.insert(ctx.shard_id, Instant::now());
+    }
+
+    match event {
+        FullEvent::Ready { .. } => {
+            data.is_connected.store(true, Ordering::SeqCst);
+            // The first Ready is handled by the framework setup
+            if data.connection_attempts.fetch_add(1, Ordering::SeqCst) > 0 {
+                tracing::info!("New gateway session established, re-syncing state");
+                resync(ctx, framework, data).await?;
+            }
+        }
+        FullEvent::Resume { .. } => {
+            data.is_connected.store(true, Ordering::SeqCst);
+            tracing::info!("Gateway session resumed, re-syncing state");
+            resync(ctx, framework, data).await?;
+        }
+        // Guilds the bot was already in are sent again on every start
+        FullEvent::GuildCreate {
+            guild,
+            is_new: Some(true),
+        } => {
+            onboarding::welcome(ctx, framework, data, guild).await?;
+        }
+        FullEvent::ChannelCreate { channel } => {
+            match_patterns(data, channel).await?;
+            let followed = data
+                .autoclean_manager
+                .apply_category_default(
+                    channel.guild_id,
+                    channel.id,
+                    channel.parent_id,
+                    channel.kind,
+                )
+                .await?;
+            if let (Some(policy), Some(category)) = (followed, channel.parent_id) {
+                announce_category_default(ctx, data, channel, category, &policy).await;
+            }
+        }
+        FullEvent::ChannelUpdate { new: channel, .. } => {
+            match_patterns(data, channel).await?;
+        }
+        // The bot's own summaries and warnings don't count
+        FullEvent::Message { new_message }
+            if new_message.author.id != ctx.cache.current_user().id =>
+        {
+            if let Some(guild_id) = new_message.guild_id {
+                if data
+                    .autoclean_manager
+                    .count_message(guild_id, new_message.channel_id)
+                    .await?
+                {
+                    tracing::debug!("Enough new messages arrived to trigger a cleanup");
+                }
+                let phishing = match data.bot.config().phishing.live {
+                    true => {
+                        data.autoclean_manager
+                            .delete_phishing(
+                                data.bot.api(),
+                                guild_id,
+                                new_message.channel_id,
+                                new_message.id,
+                                &new_message.content,
+                            )
+                            .await?
+                    }
+                    false => None,
+                };
+                if let Some(host) = phishing {
+                    announce_phishing(ctx, data, guild_id, new_message, &host).await;
+                    return Ok(());
+                }
+                let burst = data
+                    .autoclean_manager
+                    .check_spam_burst(
+                        data.bot.api(),
+                        guild_id,
+                        new_message.channel_id,
+                        new_message.author.id,
+                        new_message.id,
+                    )
+                    .await?;
+                if let Burst::Started(messages) = burst {
+                    announce_burst(ctx, data, guild_id, new_message, messages.len()).await;
+                }
+            }
+        }
+        FullEvent::GuildScheduledEventUpdate { event }
+            if event.status == ScheduledEventStatus::Completed =>
+        {
+            if let Some((_, at)) = data
+                .autoclean_manager
+                .end_event(event.guild_id, event.id)
+                .await?
+            {
+                tracing::debug!(
+                    "Scheduled event ended, purging its channel at {}",
+                    at.utc_basic()
+                );
+            }
+        }
+        FullEvent::GuildScheduledEventUpdate { event }
+            if event.status == ScheduledEventStatus::Canceled =>
+        {
+            forget_event(data, event).await?;
+        }
+        FullEvent::GuildScheduledEventDelete { event } => {
+            forget_event(data, event).await?;
+        }
+        _ => {}
+    }
+    Ok(())
+}
+
+/// Matches a created or renamed channel against its guild's name patterns.
+async fn match_patterns(data: &Data, channel: &GuildChannel) -> Result<(), EuleError> {
+    let outcome = data
+        .autoclean_manager
+        .match_channel_name(channel.guild_id, channel.id, &channel.name, channel.kind)
+        .await?;
+    if outcome != PatternOutcome::Unchanged {
+        tracing::debug!(
+            "Matched a channel's name against its guild's patterns: {:?}",
+            outcome
+        );
+    }
+    Ok(())
+}
+
+/// Removes the purge bound to a scheduled event that won't end normally.
+async fn forget_event(data: &Data, event: &ScheduledEvent) -> Result<(), EuleError> {
+    data.autoclean_manager
+        .update_guild_settings(event.guild_id, |settings| {
+            settings.event_purges.remove(&event.id);
+        })
+        .await?;
+    Ok(())
+}
+
+/// Tells a guild's log channel that a new channel follows its category's
+/// default policy.
+///
+/// Guilds without a log channel aren't told, and failing to post is only
+/// logged.
+async fn announce_category_default(
+    ctx: &serenity::Context,
+    data: &Data,
+    channel: &GuildChannel,
+    category: ChannelId,
+    policy: &str,
+) {
+    let settings = data
+        .autoclean_manager
+        .guild_settings(channel.guild_id)
+        .await;
+    let Some(log_channel) = settings.log_channel else {
+        return;
+    };
+    let text = i18n::text(
+        settings.language.unwrap_or_default(),
+        "policy.category_applied",
+        &[
+            ("channel", &format!("<#{}>", channel.id)),
+            (
+                "category",
+                &format!("<#{}>", channel.parent_id.unwrap_or(channel.id)),
+            ),
+            ("name", policy),
+        ],
+    );
+    if let Err(e) = log_channel
+        .send_message(&ctx.http, CreateMessage::new().content(text))
+        .await
+    {
+        tracing::warn!(
+            "Failed to announce a category default in the log channel: {}",
+            e
+        );
+    }
+}
+
+/// Tells a guild's log channel that a member's spam burst was deleted.
+///
+/// Only the start of a burst is announced, and guilds without a log channel
+/// aren't told.
+async fn announce_burst(
+    ctx: &serenity::Context,
+    data: &Data,
+    guild_id: GuildId,
+    message: &Message,
+    count: usize,
+) {
+    let settings = data.autoclean_manager.guild_settings(guild_id).await;
+    let Some(log_channel) = settings.log_channel else {
+        return;
+    };
+    let text = i18n::text(
+        settings.language.unwrap_or_default(),
+        "autoclean.burst_deleted",
+        &[
+            ("count", &count.to_string()),
+            ("user", &format!("<@{}>", message.author.id)),
+            ("channel", &format!("<#{}>", message.channel_id)),
+        ],
+    );
+    if let Err(e) = log_channel
+        .send_message(&ctx.http, CreateMessage::new().content(text))
+        .await
+    {
+        tracing::warn!("Failed to announce a spam burst in the log channel: {}", e);
+    }
+}
+
+/// Tells a guild's log channel that a message linking to a phishing site was
+/// deleted as it was posted.
+///
+/// Guilds without a log channel aren't told.
+async fn announce_phishing(
+    ctx: &serenity::Context,
+    data: &Data,
+    guild_id: GuildId,
+    message: &Message,
+    host: &str,
+) {
+    let settings = data.autoclean_manager.guild_settings(guild_id).await;
+    let Some(log_channel) = settings.log_channel else {
+        return;
+    };
+    let text = i18n::text(
+        settings.language.unwrap_or_default(),
+        "phishing.live_deleted",
+        &[
+            ("user", &format!("<@{}>", message.author.id)),
+            ("channel", &format!("<#{}>", message.channel_id)),
+            ("domain", host),
+        ],
+    );
+    if let Err(e) = log_channel
+        .send_message(&ctx.http, CreateMessage::new().content(text))
+        .await
+    {
+        tracing::warn!(
+            "Failed to announce a phishing link in the log channel: {}",
+            e
+        );
+    }
+}
+
+/// Re-applies presence, verifies commands, and kicks the scheduler.
+async fn resync(
+    ctx: &serenity::Context,
+    framework: poise::FrameworkContext<'_, Data, EuleError>,
+    data: &Data,
+) -> Result<(), EuleError> {
+    if let Some(first) = data.bot.config().presence.activities.first() {
+        let stats = PresenceStats::collect(ctx, &data.autoclean_manager).await;
+        ctx.set_activity(Some(presence::activity_data(first, &stats)));
+    }
+
+    let commands = poise::builtins::create_application_commands(&framework.options().commands);
+    sync_commands(&ctx.http, data.bot.dev_guild(), commands).await?;
+
+    data.autoclean_manager.wake();
+    Ok(())
+}
+
+/// Handles errors raised by the framework and by commands.
+///
+/// Commands that failed are counted in their usage. Cooldown hits get a reply
+/// only the invoking user sees; everything else is left to poise's default
+/// handling.
+///
+/// # Arguments
+/// * `error` - The error to handle
+pub async fn handle_error(error: FrameworkError<'_, Data, EuleError>) {
+    if let Some(ctx) = error.ctx() {
+        usage::finish(ctx, true).await;
+    }
+    // Poise answers the user; the panic hook has already logged the backtrace
+    if let FrameworkError::CommandPanic { payload, ctx, .. } = &error {
+        tracing::error!(
+            "Command {} panicked: {}",
+            ctx.command().qualified_name,
+            payload.as_deref().unwrap_or("unknown panic")
+        );
+    }
+    let result = match error {
+        FrameworkError::CooldownHit {
+            remaining_cooldown,
+            ctx,
+            ..
+        } => ctx
+            .send(
+                CreateReply::default()
+                    .content(cooldown_message(remaining_cooldown))
+                    .ephemeral(true),
+            )
+            .await
+            .map(drop),
+        error => poise::builtins::on_error(error).await,
+    };
+    if let Err(e) = result {
+        tracing::error!("Failed to report a command error: {}", e);
+    }
+}
@@ -0,0 +1,143 @@
+//! Translated responses.
+//!
+//! User-facing strings live in the TOML files under `locales/`, one per
+//! language, which are embedded into the binary at build time. Each file groups
+//! its strings into sections, and a string is looked up by `section.name`, for
+//! example `purge.started`. Strings may contain placeholders such as
+//! `{channel}`, which are filled in the same way as message templates.
+//!
+//! A guild can pick its language with `/language`. Guilds that didn't pick one
+//! get the invoking member's Discord language if it is translated, and English
+//! otherwise. Strings missing from a translation fall back to English.
+
+use crate::{tasks::guild_settings::render, Context};
+use serde::{Deserialize, Serialize};
+use std::{collections::HashMap, sync::OnceLock};
+
+/// A language the bot's responses are translated into.
+#[derive(
+    Serialize, Deserialize, Clone, Copy, Debug, Default, PartialEq, Eq, Hash, poise::ChoiceParameter,
+)]
+#[serde(rename_all = "lowercase")]
+pub enum Language {
+    #[default]
+    #[name = "English"]
+    En,
+    #[name = "Deutsch"]
+    De,
+}
+
+impl Language {
+    /// Every translated language.
+    pub const ALL: [Language; 2] = [Language::En, Language::De];
+
+    /// Returns the language's ISO 639-1 code.
+    pub fn code(self) -> &'static str {
+        match self {
+            Language::En => "en",
+            Language::De => "de",
+        }
+    }
+
+    /// Returns the language of a Discord locale such as `en-US` or `de`, if it
+    /// is translated.
+    pub fn from_locale(locale: &str) -> Option<Self> {
+        let code = locale.split('-').next()?;
+        Self::ALL
+            .into_iter()
+            .find(|language| language.code().eq_ignore_ascii_case(code))
+    }
+
+    /// Returns the translation file embedded for the language.
+    fn source(self) -> &'static str {
+        match self {
+            Language::En => include_str!("../locales/en.toml"),
+            Language::De => include_str!("../locales/de.toml"),
+        }
+    }
+}
+
+/// The strings of one language, by `section.name`.
+type Catalog = HashMap<String, String>;
+
+/// Parses every translation file on first use.
+///
+/// The files are part of the binary, so a malformed one is a build mistake and
+/// panics rather than degrading every response.
+fn catalogs() -> &'static HashMap<Language, Catalog> {
+    static CATALOGS: OnceLock<HashMap<Language, Catalog>> = OnceLock::new();
+    CATALOGS.get_or_init(|| {
+        Language::ALL
+            .into_iter()
+            .map(|language| {
+                let sections: HashMap<String, HashMap<String, String>> =
+                    toml::from_str(language.source()).unwrap_or_else(|e| {
+                        panic!("Invalid translation file for {}: {}", language.code(), e)
+                    });
+                let catalog = sections
+                    .into_iter()
+                    .flat_map(|(section, strings)| {
+                        strings
+                            .into_iter()
+                            .map(move |(name, text)| (format!("{}.{}", section, name), text))
+                    })
+                    .collect();
+                (language, catalog)
+            })
+            .collect()
+    })
+}
+
+/// Returns a language's own wording of a string, without falling back.
+pub fn lookup(language: Language, key: &str) -> Option<&'static str> {
+    catalogs()[&language].get(key).map(String::as_str)
+}
+
+/// Returns the keys of every string a language translates.
+pub fn keys(language: Language) -> Vec<&'static str> {
+    catalogs()[&language].keys().map(String::as_str).collect()
+}
+
+/// Returns a string in a language, falling back to English and then to the key
+/// itself, so a missing string shows up in the response instead of failing it.
+pub fn raw<'a>(language: Language, key: &'a str) -> &'a str {
+    lookup(language, key)
+        .or_else(|| lookup(Language::En, key))
+        .unwrap_or(key)
+}
+
+/// Returns a string in a language with its placeholders filled in.
+///
+/// # Arguments
+/// * `language` - The language to respond in
+/// * `key` - The string's `section.name`
+/// * `values` - Each placeholder's name, without braces, and its value
+pub fn text(language: Language, key: &str, values: &[(&str, &str)]) -> String {
+    render(raw(language, key), values)
+}
+
+/// Returns the language to answer a command in.
+///
+/// # Arguments
+/// * `ctx` - The command context
+pub async fn language(ctx: Context<'_>) -> Language {
+    if let Some(guild_id) = ctx.guild_id() {
+        let settings = ctx.data().autoclean_manager.guild_settings(guild_id).await;
+        if let Some(language) = settings.language {
+            return language;
+        }
+    }
+    ctx.locale()
+        .and_then(Language::from_locale)
+        .unwrap_or_default()
+}
+
+/// Returns a string in the language to answer a command in.
+///
+/// # Arguments
+/// * `ctx` - The command context
+/// * `key` - The string's `section.name`
+/// * `values` - Each placeholder's name, without braces, and its value
+pub async fn tr(ctx: Context<'_>, key: &str, values: &[(&str, &str)]) -> String {
+    text(language(ctx).await, key, values)
+}
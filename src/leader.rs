@@ -0,0 +1,229 @@
+//! Leader election between replicas.
+//!
+//! Replicas compete for a lease kept in Redis. The holder runs the bot; the
+//! others wait and retry, so when the leader crashes its lease expires and a
+//! standby takes over within one lease period. A leader that fails to renew
+//! its lease gives up before the lease can run out, so two replicas never
+//! clean channels at the same time.
+//!
+//! The lease is taken with `SET key holder NX PX ttl`, and renewed and released
+//! with scripts that only touch the key while it still names this replica.
+
+use crate::{
+    config::LeaderConfig,
+    error::EuleError,
+    notify::bus::{BusUrl, RedisConnection, RedisReply},
+    utils::Crypto,
+};
+use async_trait::async_trait;
+use tokio::time::{sleep, timeout, Duration, Instant};
+
+/// Extends the lease if this replica still holds it.
+const RENEW_SCRIPT: &str = "if redis.call('get', KEYS[1]) == ARGV[1] then \
+     return redis.call('pexpire', KEYS[1], ARGV[2]) else return 0 end";
+/// Deletes the lease if this replica still holds it.
+const RELEASE_SCRIPT: &str = "if redis.call('get', KEYS[1]) == ARGV[1] then \
+     return redis.call('del', KEYS[1]) else return 0 end";
+
+/// Somewhere a lease can be kept.
+#[async_trait]
+pub trait LeaseStore: Send + Sync {
+    /// Takes the lease if nobody holds it.
+    ///
+    /// # Returns
+    /// `true` if `holder` now holds the lease.
+    async fn acquire(&self, holder: &str, ttl: Duration) -> Result<bool, EuleError>;
+
+    /// Extends the lease if `holder` still holds it.
+    ///
+    /// # Returns
+    /// `false` if the lease expired or was taken by someone else.
+    async fn renew(&self, holder: &str, ttl: Duration) -> Result<bool, EuleError>;
+
+    /// Gives up the lease if `holder` holds it.
+    async fn release(&self, holder: &str) -> Result<(), EuleError>;
+}
+
+/// Keeps the lease in a Redis key.
+pub struct RedisLease {
+    connection: RedisConnection,
+    key: String,
+}
+
+impl RedisLease {
+    /// Creates a lease kept under `key` on the server at `url`.
+    ///
+    /// # Errors
+    ///
+    /// Returns `EuleError::Bus` if `url` is not a valid `redis://` URL.
+    pub fn new(url: &str, key: &str) -> Result<Self, EuleError> {
+        let url = BusUrl::parse(url)?;
+        if url.scheme != "redis" {
+            return Err(EuleError::Bus(
+                "leader election needs a redis:// URL".to_string(),
+            ));
+        }
+        Ok(Self {
+            connection: RedisConnection::new(url),
+            key: key.to_string(),
+        })
+    }
+}
+
+#[async_trait]
+impl LeaseStore for RedisLease {
+    async fn acquire(&self, holder: &str, ttl: Duration) -> Result<bool, EuleError> {
+        let ttl = ttl.as_millis().to_string();
+        let reply = self
+            .connection
+            .call(&[
+                b"SET",
+                self.key.as_bytes(),
+                holder.as_bytes(),
+                b"NX",
+                b"PX",
+                ttl.as_bytes(),
+            ])
+            .await?;
+        Ok(matches!(reply, RedisReply::Status(_)))
+    }
+
+    async fn renew(&self, holder: &str, ttl: Duration) -> Result<bool, EuleError> {
+        let ttl = ttl.as_millis().to_string();
+        let reply = self
+            .connection
+            .call(&[
+                b"EVAL",
+                RENEW_SCRIPT.as_bytes(),
+                b"1",
+                self.key.as_bytes(),
+                holder.as_bytes(),
+                ttl.as_bytes(),
+            ])
+            .await?;
+        Ok(reply == RedisReply::Integer(1))
+    }
+
+    async fn release(&self, holder: &str) -> Result<(), EuleError> {
+        self.connection
+            .call(&[
+                b"EVAL",
+                RELEASE_SCRIPT.as_bytes(),
+                b"1",
+                self.key.as_bytes(),
+                holder.as_bytes(),
+            ])
+            .await?;
+        Ok(())
+    }
+}
+
+/// Competes for and holds the leader lease on behalf of this replica.
+pub struct LeaderElection {
+    store: Box<dyn LeaseStore>,
+    holder: String,
+    ttl: Duration,
+}
+
+impl LeaderElection {
+    /// Creates an election from the configuration, if election is enabled.
+    ///
+    /// # Errors
+    ///
+    /// Returns `EuleError::Bus` if `leader.url` is not a valid `redis://` URL.
+    pub fn from_config(config: &LeaderConfig) -> Result<Option<Self>, EuleError> {
+        let Some(url) = &config.url else {
+            return Ok(None);
+        };
+        let store = RedisLease::new(url, &config.key)?;
+        Ok(Some(Self::new(
+            Box::new(store),
+            Duration::from_secs(config.ttl_secs),
+        )))
+    }
+
+    /// Creates an election for a new replica.
+    ///
+    /// # Arguments
+    /// * `store` - Where the lease is kept
+    /// * `ttl` - How long the lease lasts without being renewed
+    pub fn new(store: Box<dyn LeaseStore>, ttl: Duration) -> Self {
+        // Container PIDs are all alike, so the host name alone doesn't identify a replica
+        let host = std::env::var("HOSTNAME").unwrap_or_else(|_| "eule".to_string());
+        let holder = format!("{}-{}", host, &Crypto::random_token()[..12]);
+        Self { store, holder, ttl }
+    }
+
+    /// Returns the name this replica holds the lease under.
+    pub fn holder(&self) -> &str {
+        &self.holder
+    }
+
+    fn retry_interval(&self) -> Duration {
+        self.ttl / 3
+    }
+
+    /// Waits until this replica holds the lease.
+    ///
+    /// Errors reaching the store are logged and retried.
+    pub async fn acquire(&self) {
+        let mut waiting = false;
+        loop {
+            match self.store.acquire(&self.holder, self.ttl).await {
+                Ok(true) => {
+                    tracing::info!("Became leader as {}", self.holder);
+                    return;
+                }
+                Ok(false) if !waiting => {
+                    tracing::info!("Another replica is leader; waiting to take over");
+                    waiting = true;
+                }
+                Ok(false) => {}
+                Err(e) => tracing::warn!("Failed to reach the leader lease: {}", e),
+            }
+            sleep(self.retry_interval()).await;
+        }
+    }
+
+    /// Renews the lease until it is lost, and returns why.
+    ///
+    /// The lease counts as lost once renewal has failed for long enough that it
+    /// may already have expired, leaving a margin of one retry interval.
+    pub async fn hold(&self) -> EuleError {
+        let mut renewed = Instant::now();
+        loop {
+            sleep(self.retry_interval()).await;
+            // A renewal that hangs must not outlast the lease
+            let renewal = timeout(
+                self.retry_interval(),
+                self.store.renew(&self.holder, self.ttl),
+            )
+            .await
+            .unwrap_or_else(|_| Err(EuleError::Bus("timed out".to_string())));
+            match renewal {
+                Ok(true) => renewed = Instant::now(),
+                Ok(false) => {
+                    return EuleError::LeadershipLost(
+                        "the lease expired or was taken by another replica".to_string(),
+                    )
+                }
+                Err(e) => {
+                    tracing::warn!("Failed to renew the leader lease: {}", e);
+                    if renewed.elapsed() + self.retry_interval() >= self.ttl {
+                        return EuleError::LeadershipLost(format!(
+                            "the lease could not be renewed: {}",
+                            e
+                        ));
+                    }
+                }
+            }
+        }
+    }
+
+    /// Gives up the lease so a standby can take over right away.
+    pub async fn release(&self) {
+        if let Err(e) = self.store.release(&self.holder).await {
+            tracing::warn!("Failed to release the leader lease: {}", e);
+        }
+    }
+}
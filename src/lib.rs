@@ -10,13 +10,28 @@
 
 use poise::serenity_prelude as serenity;
 use std::{
+    collections::HashMap,
     sync::atomic::{AtomicBool, AtomicUsize},
-    sync::Arc,
-    time::SystemTime,
+    sync::{Arc, Mutex},
+    time::{Instant, SystemTime},
 };
 
+pub mod admin;
+pub mod bot_lists;
 pub mod commands;
+pub mod config;
+pub mod credentials;
 pub mod error;
+pub mod handlers;
+pub mod i18n;
+pub mod leader;
+pub mod lifecycle;
+pub mod notify;
+pub mod onboarding;
+pub mod phishing;
+pub mod presence;
+pub mod proxy;
+pub mod purge;
 pub mod store;
 pub mod tasks;
 pub mod utils;
@@ -42,6 +57,9 @@ pub struct Data {
 
     /// Atomic counter tracking the number of connection attempts made.
     pub connection_attempts: AtomicUsize,
+
+    /// When each shard last received a gateway event.
+    pub shard_events: Mutex<HashMap<serenity::ShardId, Instant>>,
 }
 
 impl Data {
@@ -71,6 +89,7 @@ impl Data {
             bot,
             is_connected: AtomicBool::new(false),
             connection_attempts: AtomicUsize::new(0),
+            shard_events: Mutex::new(HashMap::new()),
         }
     }
 }
@@ -97,9 +116,10 @@ impl serenity::prelude::TypeMapKey for AutocleanTaskCount {
 }
 
 mod bot;
-pub use bot::Bot;
+pub use bot::{resolve_token, Bot, TOKEN_ENV_VAR};
 
 // Re-export only the necessary items for the main executable
 pub use commands::autoclean::{add, autoclean, list, remove};
 pub use commands::clean::clean;
+pub use commands::purge::purge;
 pub use commands::status::status;
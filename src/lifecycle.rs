@@ -0,0 +1,140 @@
+//! Restarting the bot in place, reloading its configuration and maintenance
+//! mode.
+//!
+//! A restart asked for from Discord stops the bot like a shutdown does. Once
+//! the process has wound down, it replaces itself with a fresh copy of the
+//! same executable and arguments, so it picks up a new build or configuration
+//! without anyone logging in to the host. Configuration changes that don't
+//! need a restart can be picked up with `/reload` or by sending the process
+//! SIGHUP instead. SIGHUP also picks up a new token from the token file the
+//! bot was started with, see `credentials`.
+//!
+//! In maintenance mode the scheduler starts no cleanups and commands and admin
+//! API requests that would change tasks are turned away, while tasks and
+//! settings stay as they are. It holds for every bot in the process and is kept in the store, so it
+//! survives restarts until it is turned off again.
+
+use crate::store::KvStore;
+#[cfg(unix)]
+use crate::Bot;
+#[cfg(unix)]
+use std::sync::Arc;
+use std::{
+    io,
+    process::Command,
+    sync::atomic::{AtomicBool, Ordering},
+};
+
+static RESTART: AtomicBool = AtomicBool::new(false);
+
+static MAINTENANCE: AtomicBool = AtomicBool::new(false);
+
+/// The store key maintenance mode is kept under.
+const MAINTENANCE_KEY: &str = "maintenance_mode";
+
+/// Asks for the process to start over once the bot has stopped.
+pub fn request_restart() {
+    RESTART.store(true, Ordering::SeqCst);
+}
+
+/// Returns whether a restart was asked for.
+pub fn restart_requested() -> bool {
+    RESTART.load(Ordering::SeqCst)
+}
+
+/// Returns whether the bot is in maintenance mode.
+pub fn in_maintenance() -> bool {
+    MAINTENANCE.load(Ordering::SeqCst)
+}
+
+/// Turns maintenance mode on or off for this process only.
+pub fn set_maintenance(enabled: bool) {
+    MAINTENANCE.store(enabled, Ordering::SeqCst);
+}
+
+/// Turns maintenance mode on or off and keeps the choice in the store.
+///
+/// # Arguments
+/// * `store` - The store to keep the choice in
+/// * `enabled` - Whether the bot should be in maintenance mode
+pub async fn save_maintenance(store: &KvStore, enabled: bool) -> miette::Result<()> {
+    match enabled {
+        true => store.set(MAINTENANCE_KEY, "true").await?,
+        false => store.delete(MAINTENANCE_KEY).await?,
+    }
+    set_maintenance(enabled);
+    tracing::info!("Maintenance mode {}", if enabled { "on" } else { "off" });
+    Ok(())
+}
+
+/// Turns maintenance mode on if the store says it was left on.
+///
+/// # Arguments
+/// * `store` - The store the choice is kept in
+pub async fn load_maintenance(store: &KvStore) -> miette::Result<()> {
+    if store.get(MAINTENANCE_KEY).await?.as_deref() == Some("true") {
+        tracing::warn!("Starting in maintenance mode; scheduled cleanups are paused");
+        set_maintenance(true);
+    }
+    Ok(())
+}
+
+/// Replaces the process with a fresh copy of itself, started with the same
+/// arguments and environment.
+///
+/// # Returns
+/// Only returns if the executable couldn't be started, with the reason why.
+pub fn reexec() -> io::Error {
+    let exe = match std::env::current_exe() {
+        Ok(exe) => exe,
+        Err(e) => return e,
+    };
+    let mut command = Command::new(exe);
+    command.args(std::env::args_os().skip(1));
+    tracing::info!("Restarting as {:?}", command);
+    exec(command)
+}
+
+#[cfg(unix)]
+fn exec(mut command: Command) -> io::Error {
+    use std::os::unix::process::CommandExt;
+    command.exec()
+}
+
+#[cfg(not(unix))]
+fn exec(mut command: Command) -> io::Error {
+    // Without exec, the new process runs alongside until this one exits
+    match command.spawn() {
+        Ok(_) => std::process::exit(0),
+        Err(e) => e,
+    }
+}
+
+/// Reloads the bot's configuration and token file whenever the process
+/// receives SIGHUP.
+///
+/// # Arguments
+/// * `bot` - The bot whose configuration and token to reload
+#[cfg(unix)]
+pub async fn reload_on_hangup(bot: Arc<Bot>) {
+    use tokio::signal::unix::{signal, SignalKind};
+
+    let mut hangups = match signal(SignalKind::hangup()) {
+        Ok(hangups) => hangups,
+        Err(e) => {
+            tracing::warn!("Failed to listen for SIGHUP: {}", e);
+            return;
+        }
+    };
+    while hangups.recv().await.is_some() {
+        tracing::info!("Received SIGHUP, reloading the configuration");
+        if let Err(e) = bot.reload_config() {
+            tracing::warn!("Failed to reload the configuration: {}", e);
+        }
+        if let Some(rotation) = bot.token_rotation() {
+            if let Err(e) = rotation.reload_file().await {
+                tracing::warn!("Failed to rotate the Discord token: {}", e);
+            }
+        }
+    }
+}
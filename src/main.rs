@@ -22,14 +22,25 @@
 //! This executable is responsible for setting up the environment, parsing command-line arguments,
 //! initializing the bot, and running it or performing maintenance operations like token deletion.
 
-use clap::Command;
+use clap::{Arg, ArgAction, ArgMatches, Command};
 use eule::{
+    config::BotConfig,
     error::{create_report, EuleError},
-    Bot,
+    leader::LeaderElection,
+    lifecycle, resolve_token,
+    store::KvStore,
+    utils::panics,
+    Bot, TOKEN_ENV_VAR,
 };
 use jemallocator::Jemalloc;
 use miette::Result;
-use std::env::{set_var, var};
+use poise::serenity_prelude::{ChannelId, GuildId};
+use std::{
+    env::{set_var, var},
+    path::PathBuf,
+    sync::Arc,
+};
+use tokio::task::JoinSet;
 use tracing::subscriber::set_global_default;
 use tracing_appender::rolling::daily;
 use tracing_subscriber::{fmt, fmt::time::UtcTime, EnvFilter};
@@ -42,6 +53,7 @@ static GLOBAL: Jemalloc = Jemalloc;
 async fn main() -> Result<()> {
     // Set up logging configuration
     setup_logging()?;
+    panics::log_panics();
 
     // Parse command-line arguments and execute appropriate action
     execute_cli_command().await?;
@@ -91,20 +103,230 @@ fn setup_logging() -> Result<()> {
 
 /// Parses command-line arguments and executes the appropriate action.
 ///
-/// This function sets up the CLI, parses the arguments, and either
-/// runs the bot or performs maintenance operations like deleting the Discord token.
+/// This function sets up the CLI, parses the arguments, and dispatches to the
+/// requested subcommand. Running the bot is the default when none is given.
 async fn execute_cli_command() -> Result<()> {
     let matches = Command::new("Eule")
         .version("1.0")
         .author("@ovnanova")
         .about("Einfache Uneinigkeit Leichte Replika 🦉")
+        .arg(
+            Arg::new("token")
+                .short('t')
+                .long("token")
+                .value_name("TOKEN")
+                .global(true)
+                .help("Discord API token to use instead of the stored one"),
+        )
+        .arg(
+            Arg::new("token-file")
+                .long("token-file")
+                .value_name("PATH")
+                .value_parser(clap::value_parser!(PathBuf))
+                .global(true)
+                .help("Read the Discord API token from a file (e.g. a mounted secret)"),
+        )
+        .arg(
+            Arg::new("config")
+                .short('c')
+                .long("config")
+                .value_name("PATH")
+                .value_parser(clap::value_parser!(PathBuf))
+                .global(true)
+                .help("Path to the TOML configuration file (default: eule.toml if present)"),
+        )
+        .arg(
+            Arg::new("dev-guild")
+                .long("dev-guild")
+                .value_name("ID")
+                .value_parser(clap::value_parser!(u64).range(1..))
+                .global(true)
+                .help("Register commands in this guild only; they are removed on exit"),
+        )
+        .arg(
+            Arg::new("maintenance")
+                .long("maintenance")
+                .action(ArgAction::SetTrue)
+                .global(true)
+                .help("Start in maintenance mode: no cleanups run and tasks can't be changed"),
+        )
+        .arg(
+            Arg::new("dry-run")
+                .long("dry-run")
+                .action(ArgAction::SetTrue)
+                .global(true)
+                .help("Log and count deletions without sending them to Discord"),
+        )
+        .subcommand(Command::new("run").about("Connect to Discord and run the bot (default)"))
+        .subcommand(
+            Command::new("register-commands")
+                .about("Register the slash commands and remove stale ones"),
+        )
+        .subcommand(Command::new("deregister-commands").about("Remove all global slash commands"))
+        .subcommand(
+            Command::new("purge-once")
+                .about("Clean a single channel once and exit")
+                .arg(
+                    Arg::new("channel-id")
+                        .required(true)
+                        .value_parser(clap::value_parser!(u64).range(1..))
+                        .help("ID of the channel to clean"),
+                ),
+        )
+        .subcommand(Command::new("list-tasks").about("List all scheduled cleanup tasks"))
         .subcommand(Command::new("delete-token").about("Delete the stored Discord token"))
         .get_matches();
+    if matches.get_flag("dry-run") {
+        tracing::warn!("Dry run: messages, threads, and channels will not be deleted");
+    }
 
     match matches.subcommand() {
+        Some(("register-commands", sub)) => register_commands(sub).await,
+        Some(("deregister-commands", sub)) => deregister_commands(sub).await,
+        Some(("purge-once", sub)) => {
+            let channel_id = *sub.get_one::<u64>("channel-id").expect("required argument");
+            purge_once(sub, ChannelId::new(channel_id)).await
+        }
+        Some(("list-tasks", _)) => list_tasks().await,
         Some(("delete-token", _)) => delete_token().await,
-        _ => run_bot().await,
+        Some(("run", sub)) => run_bot(sub).await,
+        _ => run_bot(&matches).await,
+    }
+}
+
+/// Resolves a Discord token from the command line or the `EULE_TOKEN` variable.
+///
+/// Returns `None` when no source was given, leaving the stored token (or the
+/// interactive prompt) to supply one.
+fn cli_token(matches: &ArgMatches) -> Result<Option<String>> {
+    resolve_token(
+        matches.get_one::<String>("token").cloned(),
+        matches
+            .get_one::<PathBuf>("token-file")
+            .map(PathBuf::as_path),
+        var(TOKEN_ENV_VAR).ok(),
+    )
+    .map_err(|e| {
+        create_report(
+            e,
+            Some("Pass a token with --token or --token-file, or set EULE_TOKEN"),
+        )
+    })
+}
+
+/// Returns the configuration file given with `--config`, if any.
+fn config_path(matches: &ArgMatches) -> Option<PathBuf> {
+    matches.get_one::<PathBuf>("config").cloned()
+}
+
+/// Loads the configuration file given with `--config`, or the default one, and
+/// applies its process-wide settings.
+fn load_config(matches: &ArgMatches) -> Result<BotConfig> {
+    let config = BotConfig::load_or_default(config_path(matches).as_deref())
+        .map_err(|e| create_report(e, Some("Check the configuration file")))?;
+    config.apply();
+    Ok(config)
+}
+
+/// Creates a Bot instance, applying the configuration file and any token given
+/// on the command line.
+async fn create_bot(matches: &ArgMatches) -> Result<Bot> {
+    let token = cli_token(matches)?;
+    let config = load_config(matches)?;
+    let mut bot = Bot::new().await.map_err(|e| {
+        create_report(
+            EuleError::Poise(format!("Failed to create bot instance: {}", e)),
+            Some("Check your bot configuration"),
+        )
+    })?;
+    if let Some(token) = token {
+        bot = bot.with_token(token);
+    }
+    // Only a token read from the file can be rotated by replacing the file
+    if !matches.contains_id("token") {
+        if let Some(path) = matches.get_one::<PathBuf>("token-file") {
+            bot = bot.with_token_file(path.clone());
+        }
+    }
+    if let Some(guild_id) = matches.get_one::<u64>("dev-guild") {
+        bot = bot.with_dev_guild(GuildId::new(*guild_id));
+    }
+    Ok(bot
+        .with_simulated_deletes(matches.get_flag("dry-run"))
+        .with_config(config)
+        .with_config_path(config_path(matches)))
+}
+
+/// Registers the slash commands globally without starting the bot.
+async fn register_commands(matches: &ArgMatches) -> Result<()> {
+    let bot = create_bot(matches).await?;
+    let plan = bot.register_commands().await.map_err(|e| {
+        create_report(
+            EuleError::Poise(format!("Failed to register commands: {}", e)),
+            Some("Check that the token belongs to the bot application"),
+        )
+    })?;
+
+    println!(
+        "Commands synced: {} created, {} updated, {} deleted, {} unchanged.",
+        plan.create.len(),
+        plan.update.len(),
+        plan.delete.len(),
+        plan.unchanged
+    );
+    Ok(())
+}
+
+/// Removes all global slash commands.
+async fn deregister_commands(matches: &ArgMatches) -> Result<()> {
+    let bot = create_bot(matches).await?;
+    bot.deregister_commands().await.map_err(|e| {
+        create_report(
+            EuleError::Poise(format!("Failed to deregister commands: {}", e)),
+            Some("Check that the token belongs to the bot application"),
+        )
+    })?;
+
+    println!("All global commands have been removed.");
+    Ok(())
+}
+
+/// Cleans a single channel once, without connecting to the gateway.
+async fn purge_once(matches: &ArgMatches, channel_id: ChannelId) -> Result<()> {
+    let bot = create_bot(matches).await?;
+    bot.purge_once(channel_id).await.map_err(|e| {
+        create_report(
+            EuleError::Poise(format!("Failed to clean channel {}: {}", channel_id, e)),
+            Some("Check that the bot can read and manage messages in the channel"),
+        )
+    })?;
+
+    println!("Channel {} has been cleaned.", channel_id);
+    Ok(())
+}
+
+/// Prints all scheduled cleanup tasks.
+async fn list_tasks() -> Result<()> {
+    let bot = Bot::new().await.map_err(|e| {
+        create_report(
+            EuleError::Poise(format!("Failed to create bot instance: {}", e)),
+            Some("Check your bot configuration"),
+        )
+    })?;
+
+    let tasks = bot.autoclean_manager().all_tasks().await;
+    if tasks.is_empty() {
+        println!("No cleanup tasks are scheduled.");
     }
+    for (guild_id, channel_id, interval) in tasks {
+        println!(
+            "guild {}  channel {}  every {} minutes",
+            guild_id,
+            channel_id,
+            interval.as_secs() / 60
+        );
+    }
+    Ok(())
 }
 
 /// Deletes the stored Discord token.
@@ -130,24 +352,147 @@ async fn delete_token() -> Result<()> {
     Ok(())
 }
 
+/// Creates a bot for each identity in `[[bots]]`, all sharing one store.
+///
+/// Only the first identity serves the admin API and sends notifications.
+async fn create_identities(matches: &ArgMatches, config: BotConfig) -> Result<Vec<Bot>> {
+    if matches.contains_id("token") || matches.contains_id("token-file") {
+        return Err(create_report(
+            EuleError::InvalidConfig(
+                "--token and --token-file can't be used with [[bots]]".to_string(),
+            ),
+            Some("Set each bot's token in its [[bots]] entry instead"),
+        ));
+    }
+    let kv_store = Arc::new(KvStore::new("eule_data").map_err(|e| {
+        create_report(
+            EuleError::Poise(format!("Failed to open the store: {}", e)),
+            Some("Check that no other process is using eule_data"),
+        )
+    })?);
+    let dev_guild = matches
+        .get_one::<u64>("dev-guild")
+        .map(|id| GuildId::new(*id));
+
+    let mut bots = Vec::new();
+    for (index, identity) in config.bots.iter().enumerate() {
+        let env_var = identity.token_env_var();
+        let token = resolve_token(
+            identity.token.clone(),
+            identity.token_file.as_deref(),
+            var(&env_var).ok(),
+        )
+        .map_err(|e| create_report(e, None))?
+        .ok_or_else(|| {
+            create_report(
+                EuleError::InvalidConfig(format!("bot {} has no token", identity.name)),
+                Some(
+                    format!(
+                        "Set token or token_file in its [[bots]] entry, or set {}",
+                        env_var
+                    )
+                    .as_str(),
+                ),
+            )
+        })?;
+        let mut bot = Bot::for_identity(Arc::clone(&kv_store), &identity.name)
+            .await
+            .map_err(|e| {
+                create_report(
+                    EuleError::Poise(format!("Failed to create bot {}: {}", identity.name, e)),
+                    Some("Check your bot configuration"),
+                )
+            })?
+            .with_token(token)
+            .with_simulated_deletes(matches.get_flag("dry-run"))
+            .with_config(config.clone())
+            .with_config_path(config_path(matches));
+        if let (None, Some(path)) = (&identity.token, &identity.token_file) {
+            bot = bot.with_token_file(path.clone());
+        }
+        if let Some(guild_id) = dev_guild {
+            bot = bot.with_dev_guild(guild_id);
+        }
+        if index > 0 {
+            bot = bot.without_services();
+        }
+        bots.push(bot);
+    }
+    Ok(bots)
+}
+
+/// Runs bots side by side until one of them stops.
+async fn run_all(bots: Vec<Bot>) -> Result<(), EuleError> {
+    let mut running = JoinSet::new();
+    for bot in bots {
+        running.spawn(async move {
+            let result = bot.run().await;
+            match bot.identity() {
+                Some(name) => result.map_err(|e| EuleError::Poise(format!("bot {}: {}", name, e))),
+                None => result,
+            }
+        });
+    }
+    match running.join_next().await {
+        Some(Ok(result)) => result,
+        Some(Err(e)) => Err(EuleError::Poise(format!("bot task failed: {}", e))),
+        None => Ok(()),
+    }
+}
+
 /// Creates a new Bot instance and runs it.
 ///
 /// This function is the main entry point for starting Eule.
 /// It creates a new Bot instance and calls its `run` method to start the bot's operation.
-async fn run_bot() -> Result<()> {
-    let bot = Bot::new().await.map_err(|e| {
-        create_report(
-            EuleError::Poise(format!("Failed to create bot instance: {}", e)),
-            Some("Check your bot configuration"),
-        )
-    })?;
+/// A token given on the command line or via `EULE_TOKEN` takes precedence over the stored one.
+///
+/// With leader election configured, the bot isn't created until this replica
+/// holds the lease, since the store can only be opened by one process. Losing
+/// the lease ends the process, so that it can be restarted as a standby.
+///
+/// With `[[bots]]` configured, every identity is run and the process ends when
+/// any of them stops. If the bot was stopped with `/restart`, the process then
+/// starts over with the same arguments.
+async fn run_bot(matches: &ArgMatches) -> Result<()> {
+    let config = load_config(matches)?;
+    if matches.get_flag("maintenance") {
+        lifecycle::set_maintenance(true);
+    }
+    let election = LeaderElection::from_config(&config.leader)
+        .map_err(|e| create_report(e, Some("Check leader.url in the configuration file")))?;
+    if let Some(election) = &election {
+        election.acquire().await;
+    }
+    let bots = if config.bots.is_empty() {
+        vec![create_bot(matches).await?]
+    } else {
+        create_identities(matches, config).await?
+    };
 
-    bot.run().await.map_err(|e| {
+    let result = match &election {
+        Some(election) => {
+            tokio::select! {
+                result = run_all(bots) => {
+                    election.release().await;
+                    result
+                }
+                lost = election.hold() => Err(lost),
+            }
+        }
+        None => run_all(bots).await,
+    };
+    result.map_err(|e| {
         create_report(
             EuleError::Poise(format!("Failed to run bot: {}", e)),
             Some("Check the logs for more details"),
         )
     })?;
 
+    if lifecycle::restart_requested() {
+        return Err(create_report(
+            EuleError::Io(lifecycle::reexec()),
+            Some("Start the bot again by hand"),
+        ));
+    }
     Ok(())
 }
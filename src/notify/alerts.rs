@@ -0,0 +1,371 @@
+//! Operator alerts sent to Slack or Matrix.
+//!
+//! Alerts cover what an operator has to act on: cleanups that fail, the bot
+//! losing its permissions in a channel, cleanups the scheduler never started,
+//! and new releases. A channel that keeps failing or stays overdue is reported
+//! once, and again only after it has recovered.
+
+use crate::{
+    error::EuleError,
+    tasks::{AutocleanManager, PurgeEvent, PurgeEventKind},
+    utils::humanize,
+};
+use async_trait::async_trait;
+use poise::serenity_prelude::{ChannelId, GuildId};
+use serde::Deserialize;
+use serde_json::json;
+use std::{
+    collections::HashSet,
+    sync::Arc,
+    time::{SystemTime, UNIX_EPOCH},
+};
+use tokio::{
+    sync::broadcast::{self, error::RecvError},
+    time::Duration,
+};
+
+/// Where new releases are looked up.
+const RELEASES_URL: &str = "https://api.github.com/repos/fklr/eule/releases/latest";
+/// How often to look for a new release.
+const UPDATE_CHECK_INTERVAL: Duration = Duration::from_secs(24 * 60 * 60);
+/// How often the watchdog looks for overdue cleanups.
+const WATCHDOG_INTERVAL: Duration = Duration::from_secs(5 * 60);
+/// How late a cleanup may start before the watchdog reports it. The scheduler
+/// checks every minute, but due cleanups can queue behind long purges.
+pub const OVERDUE_AFTER: Duration = Duration::from_secs(30 * 60);
+
+/// Something an operator should know about.
+#[derive(Clone, Debug, PartialEq, Eq)]
+pub enum Alert {
+    /// A cleanup failed.
+    CleanupFailed {
+        guild_id: GuildId,
+        channel_id: ChannelId,
+        error: String,
+    },
+    /// The bot can no longer clean a channel because it lacks permissions.
+    PermissionLost {
+        guild_id: GuildId,
+        channel_id: ChannelId,
+    },
+    /// A task's cleanup is long overdue and was never started, so the
+    /// scheduler or its workers are likely stuck.
+    TaskOverdue {
+        guild_id: GuildId,
+        channel_id: ChannelId,
+        overdue: Duration,
+    },
+    /// A newer release than the running one is available.
+    UpdateAvailable { current: String, latest: String },
+}
+
+impl Alert {
+    /// Renders the alert as a single line of plain text.
+    pub fn message(&self) -> String {
+        match self {
+            Alert::CleanupFailed {
+                guild_id,
+                channel_id,
+                error,
+            } => format!(
+                "eule: cleanup of channel {} in guild {} failed: {}",
+                channel_id, guild_id, error
+            ),
+            Alert::PermissionLost {
+                guild_id,
+                channel_id,
+            } => format!(
+                "eule: lost permission to clean channel {} in guild {}; its task will keep failing until access is restored",
+                channel_id, guild_id
+            ),
+            Alert::TaskOverdue {
+                guild_id,
+                channel_id,
+                overdue,
+            } => format!(
+                "eule: cleanup of channel {} in guild {} has been due for {} without starting; the scheduler may be stuck",
+                channel_id,
+                guild_id,
+                humanize::duration(*overdue)
+            ),
+            Alert::UpdateAvailable { current, latest } => format!(
+                "eule: version {} is available (running {})",
+                latest, current
+            ),
+        }
+    }
+}
+
+/// A destination for alerts.
+#[async_trait]
+pub trait AlertSink: Send + Sync {
+    /// Sends an alert's text.
+    async fn send(&self, text: &str) -> Result<(), EuleError>;
+}
+
+fn alert_error(e: reqwest::Error) -> EuleError {
+    EuleError::Alert(e.to_string())
+}
+
+/// Posts alerts to a Slack incoming webhook.
+pub struct SlackSink {
+    client: reqwest::Client,
+    webhook_url: String,
+}
+
+impl SlackSink {
+    pub fn new(webhook_url: String) -> Self {
+        Self {
+            client: crate::proxy::client(),
+            webhook_url,
+        }
+    }
+}
+
+#[async_trait]
+impl AlertSink for SlackSink {
+    async fn send(&self, text: &str) -> Result<(), EuleError> {
+        self.client
+            .post(&self.webhook_url)
+            .json(&json!({ "text": text }))
+            .send()
+            .await
+            .and_then(reqwest::Response::error_for_status)
+            .map_err(alert_error)?;
+        Ok(())
+    }
+}
+
+/// Sends alerts as messages to a Matrix room.
+pub struct MatrixSink {
+    client: reqwest::Client,
+    homeserver: String,
+    room_id: String,
+    access_token: String,
+}
+
+impl MatrixSink {
+    /// Creates a sink for a room the access token's user has joined.
+    ///
+    /// # Arguments
+    /// * `homeserver` - The homeserver's base URL, such as `https://matrix.example.com`
+    /// * `room_id` - The room's ID, such as `!abc:example.com`
+    /// * `access_token` - An access token of the user to post as
+    pub fn new(homeserver: &str, room_id: String, access_token: String) -> Self {
+        Self {
+            client: crate::proxy::client(),
+            homeserver: homeserver.trim_end_matches('/').to_string(),
+            room_id,
+            access_token,
+        }
+    }
+}
+
+#[async_trait]
+impl AlertSink for MatrixSink {
+    async fn send(&self, text: &str) -> Result<(), EuleError> {
+        // The transaction ID only has to be unique for this access token
+        let txn_id = SystemTime::now()
+            .duration_since(UNIX_EPOCH)
+            .unwrap_or_default()
+            .as_nanos();
+        let url = format!(
+            "{}/_matrix/client/v3/rooms/{}/send/m.room.message/eule-{}",
+            self.homeserver,
+            crate::admin::html::url_encode(&self.room_id),
+            txn_id
+        );
+        self.client
+            .put(url)
+            .bearer_auth(&self.access_token)
+            .json(&json!({ "msgtype": "m.text", "body": text }))
+            .send()
+            .await
+            .and_then(reqwest::Response::error_for_status)
+            .map_err(alert_error)?;
+        Ok(())
+    }
+}
+
+/// Decides which purge events deserve an alert.
+#[derive(Default)]
+pub struct AlertFilter {
+    /// Channels that have been alerted about and not recovered since.
+    failing: HashSet<ChannelId>,
+}
+
+impl AlertFilter {
+    /// Returns the alert for an event, if it should raise one.
+    pub fn alert_for(&mut self, event: &PurgeEvent) -> Option<Alert> {
+        match event.kind {
+            PurgeEventKind::Started => None,
+            PurgeEventKind::Completed => {
+                self.failing.remove(&event.channel_id);
+                None
+            }
+            PurgeEventKind::Failed => {
+                if !self.failing.insert(event.channel_id) {
+                    return None;
+                }
+                Some(if event.missing_permissions {
+                    Alert::PermissionLost {
+                        guild_id: event.guild_id,
+                        channel_id: event.channel_id,
+                    }
+                } else {
+                    Alert::CleanupFailed {
+                        guild_id: event.guild_id,
+                        channel_id: event.channel_id,
+                        error: event.error.clone().unwrap_or_default(),
+                    }
+                })
+            }
+        }
+    }
+}
+
+/// Decides which overdue tasks deserve an alert.
+#[derive(Default)]
+pub struct OverdueFilter {
+    /// Channels that have been alerted about and not caught up since.
+    overdue: HashSet<ChannelId>,
+}
+
+impl OverdueFilter {
+    /// Returns the alerts for the tasks overdue now.
+    ///
+    /// # Arguments
+    /// * `overdue` - The tasks overdue now, as returned by
+    ///   `AutocleanManager::overdue_tasks`
+    pub fn alerts_for(&mut self, overdue: &[(GuildId, ChannelId, Duration)]) -> Vec<Alert> {
+        self.overdue
+            .retain(|channel_id| overdue.iter().any(|(_, still, _)| still == channel_id));
+        overdue
+            .iter()
+            .filter(|(_, channel_id, _)| self.overdue.insert(*channel_id))
+            .map(|&(guild_id, channel_id, overdue)| Alert::TaskOverdue {
+                guild_id,
+                channel_id,
+                overdue,
+            })
+            .collect()
+    }
+}
+
+/// Returns whether `latest` is a newer version than `current`.
+///
+/// Versions are compared numerically component by component, ignoring a
+/// leading `v` and any pre-release suffix.
+pub fn is_newer(latest: &str, current: &str) -> bool {
+    let parse = |version: &str| -> Vec<u64> {
+        version
+            .trim_start_matches('v')
+            .split(['-', '+'])
+            .next()
+            .unwrap_or_default()
+            .split('.')
+            .map(|part| part.parse().unwrap_or(0))
+            .collect()
+    };
+    parse(latest) > parse(current)
+}
+
+#[derive(Deserialize)]
+struct Release {
+    tag_name: String,
+}
+
+async fn latest_release(client: &reqwest::Client) -> Result<String, EuleError> {
+    let release: Release = client
+        .get(RELEASES_URL)
+        .header("User-Agent", concat!("eule/", env!("CARGO_PKG_VERSION")))
+        .send()
+        .await
+        .and_then(reqwest::Response::error_for_status)
+        .map_err(alert_error)?
+        .json()
+        .await
+        .map_err(alert_error)?;
+    Ok(release.tag_name)
+}
+
+async fn send_all(sinks: &[Arc<dyn AlertSink>], alert: &Alert) {
+    let text = alert.message();
+    for sink in sinks {
+        if let Err(e) = sink.send(&text).await {
+            tracing::warn!("Failed to send operator alert: {}", e);
+        }
+    }
+}
+
+/// Sends alerts until the manager goes away.
+///
+/// # Arguments
+/// * `sinks` - Where alerts are sent
+/// * `events` - A subscription to the manager's purge events
+/// * `check_updates` - Whether to look for new releases once a day
+pub async fn run(
+    sinks: Vec<Arc<dyn AlertSink>>,
+    mut events: broadcast::Receiver<PurgeEvent>,
+    check_updates: bool,
+) {
+    let mut filter = AlertFilter::default();
+    let client = crate::proxy::client();
+    let mut announced: Option<String> = None;
+    let mut update_check = tokio::time::interval(UPDATE_CHECK_INTERVAL);
+    loop {
+        tokio::select! {
+            event = events.recv() => match event {
+                Ok(event) => {
+                    if let Some(alert) = filter.alert_for(&event) {
+                        send_all(&sinks, &alert).await;
+                    }
+                }
+                Err(RecvError::Lagged(missed)) => {
+                    tracing::warn!("Alerts fell behind and skipped {} purge events", missed);
+                }
+                Err(RecvError::Closed) => break,
+            },
+            _ = update_check.tick(), if check_updates => {
+                let current = env!("CARGO_PKG_VERSION");
+                match latest_release(&client).await {
+                    Ok(latest)
+                        if is_newer(&latest, current) && announced.as_ref() != Some(&latest) =>
+                    {
+                        let alert = Alert::UpdateAvailable {
+                            current: current.to_string(),
+                            latest: latest.clone(),
+                        };
+                        send_all(&sinks, &alert).await;
+                        announced = Some(latest);
+                    }
+                    Ok(_) => {}
+                    Err(e) => tracing::debug!("Failed to check for a new release: {}", e),
+                }
+            }
+        }
+    }
+}
+
+/// Reports cleanups the scheduler should have started but didn't, for as long
+/// as the bot runs.
+///
+/// The watchdog runs apart from the scheduler, so it still notices when the
+/// scheduler itself has stopped. Overdue cleanups are always logged, and sent
+/// to the sinks as well if there are any.
+///
+/// # Arguments
+/// * `manager` - The manager whose tasks to watch
+/// * `sinks` - Where alerts are sent
+pub async fn watch_overdue(manager: AutocleanManager, sinks: Vec<Arc<dyn AlertSink>>) {
+    let mut filter = OverdueFilter::default();
+    let mut check = tokio::time::interval(WATCHDOG_INTERVAL);
+    loop {
+        check.tick().await;
+        let overdue = manager.overdue_tasks(OVERDUE_AFTER).await;
+        for alert in filter.alerts_for(&overdue) {
+            tracing::error!("{}", alert.message());
+            send_all(&sinks, &alert).await;
+        }
+    }
+}
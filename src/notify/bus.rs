@@ -0,0 +1,439 @@
+//! Publication of purge events and task changes to a message bus.
+//!
+//! Two buses are supported, chosen by the URL's scheme:
+//!
+//! | URL                                    | Published as                                   |
+//! |----------------------------------------|------------------------------------------------|
+//! | `nats://[user:pass@]host[:4222]`       | `PUB {subject}.purge.{kind}` / `{subject}.task.{kind}` |
+//! | `redis://[:pass@]host[:6379][/db]`     | `XADD {subject} MAXLEN ~ 10000 * type … payload …` |
+//!
+//! Both protocols are small enough to speak directly, so no client library is
+//! needed. Payloads are the same JSON the webhooks receive. A publisher that
+//! loses its connection reconnects on the next event; events that fail to
+//! publish are logged and dropped.
+
+use crate::{
+    error::EuleError,
+    tasks::{PurgeEvent, TaskChange},
+};
+use async_trait::async_trait;
+use serde::Serialize;
+use std::sync::Arc;
+use tokio::{
+    io::{AsyncBufReadExt, AsyncWriteExt, BufReader},
+    net::{
+        tcp::{OwnedReadHalf, OwnedWriteHalf},
+        TcpStream,
+    },
+    sync::{broadcast, broadcast::error::RecvError, Mutex},
+    time::{timeout, Duration},
+};
+
+/// How long to wait for the bus to accept a connection or answer a command.
+const IO_TIMEOUT: Duration = Duration::from_secs(10);
+/// The approximate number of entries a Redis stream is trimmed to.
+const REDIS_STREAM_MAXLEN: u64 = 10_000;
+
+/// The parts of a bus URL.
+#[derive(Clone, Debug, PartialEq, Eq)]
+pub struct BusUrl {
+    pub scheme: String,
+    pub host: String,
+    pub port: u16,
+    pub username: Option<String>,
+    pub password: Option<String>,
+    /// The path after the address, such as a Redis database number.
+    pub path: Option<String>,
+}
+
+impl BusUrl {
+    /// Parses a `nats://` or `redis://` URL.
+    ///
+    /// # Errors
+    ///
+    /// Returns `EuleError::InvalidConfig` if the URL is malformed or names
+    /// another scheme.
+    pub fn parse(url: &str) -> Result<Self, EuleError> {
+        let invalid = |reason: &str| EuleError::InvalidConfig(format!("bus.url {}", reason));
+        let (scheme, rest) = url
+            .split_once("://")
+            .ok_or_else(|| invalid("must start with nats:// or redis://"))?;
+        let default_port = match scheme {
+            "nats" => 4222,
+            "redis" => 6379,
+            _ => return Err(invalid("must start with nats:// or redis://")),
+        };
+        let (authority, path) = match rest.split_once('/') {
+            Some((authority, path)) if !path.is_empty() => (authority, Some(path.to_string())),
+            Some((authority, _)) => (authority, None),
+            None => (rest, None),
+        };
+        let (credentials, address) = match authority.rsplit_once('@') {
+            Some((credentials, address)) => (Some(credentials), address),
+            None => (None, authority),
+        };
+        let (username, password) = match credentials.map(|c| c.split_once(':')) {
+            Some(Some((user, pass))) => (
+                Some(user.to_string()).filter(|user| !user.is_empty()),
+                Some(pass.to_string()),
+            ),
+            Some(None) => (credentials.map(str::to_string), None),
+            None => (None, None),
+        };
+        let (host, port) = match address.rsplit_once(':') {
+            Some((host, port)) => (
+                host,
+                port.parse().map_err(|_| invalid("has an invalid port"))?,
+            ),
+            None => (address, default_port),
+        };
+        if host.is_empty() {
+            return Err(invalid("is missing a host"));
+        }
+        Ok(Self {
+            scheme: scheme.to_string(),
+            host: host.to_string(),
+            port,
+            username,
+            password,
+            path,
+        })
+    }
+}
+
+/// Something events can be published to.
+#[async_trait]
+pub trait Publisher: Send + Sync {
+    /// Publishes one event.
+    ///
+    /// # Arguments
+    /// * `topic` - The event's type, such as `purge.completed`
+    /// * `payload` - The event as JSON
+    async fn publish(&self, topic: &str, payload: &[u8]) -> Result<(), EuleError>;
+}
+
+/// Creates the publisher for a bus URL.
+///
+/// # Arguments
+/// * `url` - A `nats://` or `redis://` URL
+/// * `subject` - The NATS subject prefix or Redis stream name
+pub fn publisher(url: &str, subject: &str) -> Result<Arc<dyn Publisher>, EuleError> {
+    let url = BusUrl::parse(url)?;
+    Ok(match url.scheme.as_str() {
+        "nats" => Arc::new(NatsPublisher::new(url, subject)),
+        _ => Arc::new(RedisPublisher::new(url, subject)),
+    })
+}
+
+fn bus_error(e: impl std::fmt::Display) -> EuleError {
+    EuleError::Bus(e.to_string())
+}
+
+async fn connect(url: &BusUrl) -> Result<TcpStream, EuleError> {
+    timeout(
+        IO_TIMEOUT,
+        TcpStream::connect((url.host.as_str(), url.port)),
+    )
+    .await
+    .map_err(|_| bus_error("timed out connecting"))?
+    .map_err(bus_error)
+}
+
+async fn read_line(reader: &mut BufReader<OwnedReadHalf>) -> Result<String, EuleError> {
+    let mut line = String::new();
+    let read = timeout(IO_TIMEOUT, reader.read_line(&mut line))
+        .await
+        .map_err(|_| bus_error("timed out waiting for a reply"))?
+        .map_err(bus_error)?;
+    if read == 0 {
+        return Err(bus_error("connection closed"));
+    }
+    Ok(line.trim_end().to_string())
+}
+
+/// Publishes to NATS subjects under a prefix.
+pub struct NatsPublisher {
+    url: BusUrl,
+    subject: String,
+    writer: Arc<Mutex<Option<OwnedWriteHalf>>>,
+}
+
+impl NatsPublisher {
+    pub fn new(url: BusUrl, subject: &str) -> Self {
+        Self {
+            url,
+            subject: subject.to_string(),
+            writer: Arc::new(Mutex::new(None)),
+        }
+    }
+
+    /// Connects and starts answering the server's keep-alive pings.
+    async fn connect(&self) -> Result<OwnedWriteHalf, EuleError> {
+        let (reader, mut writer) = connect(&self.url).await?.into_split();
+        let mut reader = BufReader::new(reader);
+        let info = read_line(&mut reader).await?;
+        if !info.starts_with("INFO ") {
+            return Err(bus_error(format!("unexpected greeting: {}", info)));
+        }
+
+        let mut options = serde_json::json!({
+            "verbose": false,
+            "pedantic": false,
+            "name": "eule",
+            "lang": "rust",
+            "version": env!("CARGO_PKG_VERSION"),
+        });
+        match (&self.url.username, &self.url.password) {
+            (Some(user), Some(pass)) => {
+                options["user"] = user.as_str().into();
+                options["pass"] = pass.as_str().into();
+            }
+            (Some(token), None) => options["auth_token"] = token.as_str().into(),
+            _ => {}
+        }
+        writer
+            .write_all(format!("CONNECT {}\r\nPING\r\n", options).as_bytes())
+            .await
+            .map_err(bus_error)?;
+        match read_line(&mut reader).await?.as_str() {
+            "PONG" => {}
+            reply => return Err(bus_error(reply)),
+        }
+
+        let writer_slot = Arc::clone(&self.writer);
+        tokio::spawn(async move {
+            let mut line = String::new();
+            loop {
+                line.clear();
+                match reader.read_line(&mut line).await {
+                    Ok(0) | Err(_) => break,
+                    Ok(_) if line.starts_with("PING") => {
+                        if let Some(writer) = writer_slot.lock().await.as_mut() {
+                            let _ = writer.write_all(b"PONG\r\n").await;
+                        }
+                    }
+                    Ok(_) if line.starts_with("-ERR") => {
+                        tracing::warn!("NATS reported an error: {}", line.trim_end());
+                    }
+                    Ok(_) => {}
+                }
+            }
+            // Force a reconnect on the next publish
+            writer_slot.lock().await.take();
+        });
+        Ok(writer)
+    }
+}
+
+#[async_trait]
+impl Publisher for NatsPublisher {
+    async fn publish(&self, topic: &str, payload: &[u8]) -> Result<(), EuleError> {
+        let mut message =
+            format!("PUB {}.{} {}\r\n", self.subject, topic, payload.len()).into_bytes();
+        message.extend_from_slice(payload);
+        message.extend_from_slice(b"\r\n");
+
+        let mut slot = self.writer.lock().await;
+        if slot.is_none() {
+            // Release the slot while connecting, since the ping task locks it
+            drop(slot);
+            let writer = self.connect().await?;
+            slot = self.writer.lock().await;
+            *slot = Some(writer);
+        }
+        let writer = slot.as_mut().expect("connected above");
+        if let Err(e) = writer.write_all(&message).await {
+            slot.take();
+            return Err(bus_error(e));
+        }
+        Ok(())
+    }
+}
+
+/// A reply to a Redis command.
+#[derive(Clone, Debug, PartialEq, Eq)]
+pub(crate) enum RedisReply {
+    /// A status such as `OK`.
+    Status(String),
+    Integer(i64),
+    /// A bulk string, or `None` for a nil reply.
+    Bulk(Option<String>),
+}
+
+/// Encodes a command as a RESP array of bulk strings.
+fn resp_command(parts: &[&[u8]]) -> Vec<u8> {
+    let mut command = format!("*{}\r\n", parts.len()).into_bytes();
+    for part in parts {
+        command.extend_from_slice(format!("${}\r\n", part.len()).as_bytes());
+        command.extend_from_slice(part);
+        command.extend_from_slice(b"\r\n");
+    }
+    command
+}
+
+type RedisStream = (BufReader<OwnedReadHalf>, OwnedWriteHalf);
+
+/// A lazily opened connection to a Redis server, reopened after any error.
+pub(crate) struct RedisConnection {
+    url: BusUrl,
+    connection: Mutex<Option<RedisStream>>,
+}
+
+impl RedisConnection {
+    pub(crate) fn new(url: BusUrl) -> Self {
+        Self {
+            url,
+            connection: Mutex::new(None),
+        }
+    }
+
+    /// Sends a command and reads its reply, failing on error replies.
+    async fn call_on(
+        (reader, writer): &mut RedisStream,
+        parts: &[&[u8]],
+    ) -> Result<RedisReply, EuleError> {
+        writer
+            .write_all(&resp_command(parts))
+            .await
+            .map_err(bus_error)?;
+        let reply = read_line(reader).await?;
+        match reply.chars().next() {
+            Some('-') => Err(bus_error(&reply[1..])),
+            Some('+') => Ok(RedisReply::Status(reply[1..].to_string())),
+            Some(':') => Ok(RedisReply::Integer(reply[1..].parse().map_err(bus_error)?)),
+            Some('$') => {
+                let len: i64 = reply[1..].parse().map_err(bus_error)?;
+                if len < 0 {
+                    return Ok(RedisReply::Bulk(None));
+                }
+                Ok(RedisReply::Bulk(Some(read_line(reader).await?)))
+            }
+            _ => Err(bus_error(format!("unexpected reply: {}", reply))),
+        }
+    }
+
+    async fn connect(&self) -> Result<RedisStream, EuleError> {
+        let (reader, writer) = connect(&self.url).await?.into_split();
+        let mut connection = (BufReader::new(reader), writer);
+        if let Some(password) = &self.url.password {
+            match &self.url.username {
+                Some(user) => {
+                    Self::call_on(
+                        &mut connection,
+                        &[b"AUTH", user.as_bytes(), password.as_bytes()],
+                    )
+                    .await?
+                }
+                None => Self::call_on(&mut connection, &[b"AUTH", password.as_bytes()]).await?,
+            };
+        }
+        if let Some(db) = &self.url.path {
+            Self::call_on(&mut connection, &[b"SELECT", db.as_bytes()]).await?;
+        }
+        Ok(connection)
+    }
+
+    /// Sends a command, connecting first if needed.
+    pub(crate) async fn call(&self, parts: &[&[u8]]) -> Result<RedisReply, EuleError> {
+        let mut slot = self.connection.lock().await;
+        // Only put the connection back once its reply has been read, so a call
+        // that fails or is cancelled midway never leaves a reply behind
+        let mut connection = match slot.take() {
+            Some(connection) => connection,
+            None => self.connect().await?,
+        };
+        let reply = Self::call_on(&mut connection, parts).await?;
+        *slot = Some(connection);
+        Ok(reply)
+    }
+}
+
+/// Appends to a Redis stream.
+pub struct RedisPublisher {
+    connection: RedisConnection,
+    stream: String,
+}
+
+impl RedisPublisher {
+    pub fn new(url: BusUrl, stream: &str) -> Self {
+        Self {
+            connection: RedisConnection::new(url),
+            stream: stream.to_string(),
+        }
+    }
+}
+
+#[async_trait]
+impl Publisher for RedisPublisher {
+    async fn publish(&self, topic: &str, payload: &[u8]) -> Result<(), EuleError> {
+        let maxlen = REDIS_STREAM_MAXLEN.to_string();
+        self.connection
+            .call(&[
+                b"XADD",
+                self.stream.as_bytes(),
+                b"MAXLEN",
+                b"~",
+                maxlen.as_bytes(),
+                b"*",
+                b"type",
+                topic.as_bytes(),
+                b"payload",
+                payload,
+            ])
+            .await?;
+        Ok(())
+    }
+}
+
+fn kind_name(value: &impl Serialize) -> String {
+    serde_json::to_value(value)
+        .ok()
+        .and_then(|value| value.as_str().map(str::to_string))
+        .unwrap_or_default()
+}
+
+/// Publishes the manager's events to a bus until the manager goes away.
+///
+/// Purge events are published as `purge.{started,completed,failed}` and task
+/// changes as `task.{added,updated,removed}`.
+///
+/// # Arguments
+/// * `publisher` - The bus to publish to
+/// * `purges` - A subscription to the manager's purge events
+/// * `changes` - A subscription to the manager's task changes
+pub async fn run(
+    publisher: Arc<dyn Publisher>,
+    mut purges: broadcast::Receiver<PurgeEvent>,
+    mut changes: broadcast::Receiver<TaskChange>,
+) {
+    loop {
+        let (topic, payload) = tokio::select! {
+            event = purges.recv() => match event {
+                Ok(event) => (format!("purge.{}", kind_name(&event.kind)), serde_json::to_vec(&event)),
+                Err(RecvError::Lagged(missed)) => {
+                    tracing::warn!("Message bus fell behind and skipped {} purge events", missed);
+                    continue;
+                }
+                Err(RecvError::Closed) => break,
+            },
+            change = changes.recv() => match change {
+                Ok(change) => (format!("task.{}", kind_name(&change.kind)), serde_json::to_vec(&change)),
+                Err(RecvError::Lagged(missed)) => {
+                    tracing::warn!("Message bus fell behind and skipped {} task changes", missed);
+                    continue;
+                }
+                Err(RecvError::Closed) => break,
+            },
+        };
+        let payload = match payload {
+            Ok(payload) => payload,
+            Err(e) => {
+                tracing::error!("Failed to serialize {} event: {:?}", topic, e);
+                continue;
+            }
+        };
+        if let Err(e) = publisher.publish(&topic, &payload).await {
+            tracing::warn!("Failed to publish {} event: {}", topic, e);
+        }
+    }
+}
@@ -0,0 +1,324 @@
+//! Scheduled email reports of purge activity.
+//!
+//! Each configured guild gets a weekly or monthly summary of its cleanups:
+//! how many ran, what they deleted, and which failed. Tallies are kept in the
+//! key-value store so a restart doesn't lose the period's activity, and a
+//! report is sent on the first check after its period ends.
+//!
+//! Discord doesn't share members' email addresses, so recipients are listed in
+//! the configuration rather than looked up from the server owner.
+
+use crate::{
+    config::{EmailReportConfig, ReportPeriod, SmtpConfig},
+    error::EuleError,
+    store::KvStore,
+    tasks::{PurgeEvent, PurgeEventKind},
+    utils::{humanize, serializable_instant::civil_date, SerializableInstant},
+};
+use async_trait::async_trait;
+use lettre::{
+    message::{header::ContentType, Mailbox},
+    transport::smtp::authentication::Credentials,
+    AsyncSmtpTransport, AsyncTransport, Message, Tokio1Executor,
+};
+use poise::serenity_prelude::GuildId;
+use serde::{Deserialize, Serialize};
+use std::{collections::HashMap, sync::Arc};
+use tokio::{
+    sync::broadcast::{self, error::RecvError},
+    time::Duration,
+};
+
+/// The key the tallies are stored under.
+const TALLIES_KEY: &str = "email_report_tallies";
+/// How often to check whether a report is due.
+const CHECK_INTERVAL: Duration = Duration::from_secs(60 * 60);
+/// How many distinct errors a report lists.
+const MAX_ERRORS: usize = 5;
+
+/// Something that can send an email.
+#[async_trait]
+pub trait Mailer: Send + Sync {
+    /// Sends a plain-text email.
+    async fn send(&self, to: &[String], subject: &str, body: &str) -> Result<(), EuleError>;
+}
+
+fn email_error(e: impl std::fmt::Display) -> EuleError {
+    EuleError::Email(e.to_string())
+}
+
+/// Sends email through an SMTP relay.
+pub struct SmtpMailer {
+    transport: AsyncSmtpTransport<Tokio1Executor>,
+    from: Mailbox,
+}
+
+impl SmtpMailer {
+    /// Creates a mailer for a relay.
+    ///
+    /// # Arguments
+    /// * `config` - The relay's address and sender
+    /// * `password` - The password to authenticate with, if the relay needs one
+    ///
+    /// # Errors
+    ///
+    /// Returns `EuleError::InvalidConfig` if the sender address is malformed.
+    pub fn new(config: &SmtpConfig, password: Option<String>) -> Result<Self, EuleError> {
+        let from = config.from.parse().map_err(|e| {
+            EuleError::InvalidConfig(format!("email.smtp.from is not an address: {}", e))
+        })?;
+        let builder = if config.starttls {
+            AsyncSmtpTransport::<Tokio1Executor>::starttls_relay(&config.host)
+        } else {
+            AsyncSmtpTransport::<Tokio1Executor>::relay(&config.host)
+        }
+        .map_err(email_error)?
+        .port(config.port);
+        let builder = match (&config.username, password) {
+            (Some(username), Some(password)) => {
+                builder.credentials(Credentials::new(username.clone(), password))
+            }
+            _ => builder,
+        };
+        Ok(Self {
+            transport: builder.build(),
+            from,
+        })
+    }
+}
+
+#[async_trait]
+impl Mailer for SmtpMailer {
+    async fn send(&self, to: &[String], subject: &str, body: &str) -> Result<(), EuleError> {
+        let mut message = Message::builder().from(self.from.clone()).subject(subject);
+        for recipient in to {
+            message = message.to(recipient.parse().map_err(email_error)?);
+        }
+        let message = message
+            .header(ContentType::TEXT_PLAIN)
+            .body(body.to_string())
+            .map_err(email_error)?;
+        self.transport.send(message).await.map_err(email_error)?;
+        Ok(())
+    }
+}
+
+/// A guild's purge activity since its last report.
+#[derive(Clone, Debug, Default, PartialEq, Eq, Serialize, Deserialize)]
+pub struct ReportTally {
+    /// The UTC day the period started on.
+    pub since_day: u64,
+    pub runs: u64,
+    pub deleted: u64,
+    pub cancelled: u64,
+    pub failures: u64,
+    /// The most recent distinct errors, oldest first.
+    pub errors: Vec<String>,
+}
+
+impl ReportTally {
+    fn starting(day: u64) -> Self {
+        Self {
+            since_day: day,
+            ..Self::default()
+        }
+    }
+
+    fn record(&mut self, event: &PurgeEvent) {
+        match event.kind {
+            PurgeEventKind::Started => {}
+            PurgeEventKind::Completed => {
+                self.runs += 1;
+                self.deleted += event.deleted as u64;
+                if event.cancelled {
+                    self.cancelled += 1;
+                }
+            }
+            PurgeEventKind::Failed => {
+                self.runs += 1;
+                self.failures += 1;
+                let error = event.error.clone().unwrap_or_default();
+                if !self.errors.contains(&error) {
+                    if self.errors.len() >= MAX_ERRORS {
+                        self.errors.remove(0);
+                    }
+                    self.errors.push(error);
+                }
+            }
+        }
+    }
+}
+
+fn format_date(day: u64) -> String {
+    let (year, month, day) = civil_date(day);
+    format!("{:04}-{:02}-{:02}", year, month, day)
+}
+
+/// Returns whether a period that started on `since_day` has ended by `today`.
+///
+/// Weekly periods last seven days; monthly periods end when the calendar month
+/// changes.
+pub fn period_ended(period: ReportPeriod, since_day: u64, today: u64) -> bool {
+    match period {
+        ReportPeriod::Weekly => today >= since_day + 7,
+        ReportPeriod::Monthly => {
+            let (year, month, _) = civil_date(since_day);
+            let (this_year, this_month, _) = civil_date(today);
+            (this_year, this_month) != (year, month)
+        }
+    }
+}
+
+/// Renders a report's subject and body.
+pub fn render(
+    guild_id: GuildId,
+    period: ReportPeriod,
+    tally: &ReportTally,
+    today: u64,
+) -> (String, String) {
+    let label = match period {
+        ReportPeriod::Weekly => "Weekly",
+        ReportPeriod::Monthly => "Monthly",
+    };
+    let from = format_date(tally.since_day);
+    let to = format_date(today.saturating_sub(1).max(tally.since_day));
+    let subject = format!(
+        "{} eule report for guild {}: {} to {}",
+        label, guild_id, from, to
+    );
+
+    let mut body = format!(
+        "Purge activity in guild {} from {} to {} (UTC).\n\n\
+         Cleanups run:       {}\n\
+         Messages deleted:   {}\n\
+         Cleanups cancelled: {}\n\
+         Cleanups failed:    {}\n",
+        guild_id,
+        from,
+        to,
+        humanize::count(tally.runs),
+        humanize::count(tally.deleted),
+        humanize::count(tally.cancelled),
+        humanize::count(tally.failures)
+    );
+    if !tally.errors.is_empty() {
+        body.push_str("\nErrors:\n");
+        for error in &tally.errors {
+            body.push_str(&format!("  - {}\n", error));
+        }
+    }
+    (subject, body)
+}
+
+/// Keeps tallies of purge activity and emails them when their period ends.
+pub struct EmailReporter {
+    store: Arc<KvStore>,
+    mailer: Arc<dyn Mailer>,
+    reports: HashMap<GuildId, EmailReportConfig>,
+}
+
+impl EmailReporter {
+    /// Creates a reporter.
+    ///
+    /// # Arguments
+    /// * `store` - Where tallies are kept between restarts
+    /// * `mailer` - What reports are sent with
+    /// * `reports` - The guilds to report on and who to send their reports to
+    pub fn new(
+        store: Arc<KvStore>,
+        mailer: Arc<dyn Mailer>,
+        reports: &[EmailReportConfig],
+    ) -> Self {
+        Self {
+            store,
+            mailer,
+            reports: reports
+                .iter()
+                .map(|report| (GuildId::new(report.guild_id), report.clone()))
+                .collect(),
+        }
+    }
+
+    /// Returns the stored tallies.
+    pub async fn tallies(&self) -> Result<HashMap<GuildId, ReportTally>, EuleError> {
+        match self
+            .store
+            .get(TALLIES_KEY)
+            .await
+            .map_err(EuleError::Miette)?
+        {
+            Some(serialized) => serde_json::from_str(&serialized).map_err(EuleError::Serialization),
+            None => Ok(HashMap::new()),
+        }
+    }
+
+    async fn save(&self, tallies: &HashMap<GuildId, ReportTally>) -> Result<(), EuleError> {
+        let serialized = serde_json::to_string(tallies).map_err(EuleError::Serialization)?;
+        self.store
+            .set(TALLIES_KEY, &serialized)
+            .await
+            .map_err(EuleError::Miette)
+    }
+
+    /// Adds a purge event to its guild's tally, if the guild gets reports.
+    pub async fn record(&self, event: &PurgeEvent) -> Result<(), EuleError> {
+        if !self.reports.contains_key(&event.guild_id) || event.kind == PurgeEventKind::Started {
+            return Ok(());
+        }
+        let mut tallies = self.tallies().await?;
+        let today = event.at.utc_day();
+        tallies
+            .entry(event.guild_id)
+            .or_insert_with(|| ReportTally::starting(today))
+            .record(event);
+        self.save(&tallies).await
+    }
+
+    /// Sends the reports whose period has ended and starts new periods for them.
+    ///
+    /// A report that fails to send is kept and retried on the next check.
+    ///
+    /// # Arguments
+    /// * `today` - The current UTC day
+    pub async fn send_due(&self, today: u64) -> Result<(), EuleError> {
+        let mut tallies = self.tallies().await?;
+        for (guild_id, report) in &self.reports {
+            let tally = tallies
+                .entry(*guild_id)
+                .or_insert_with(|| ReportTally::starting(today));
+            if !period_ended(report.period, tally.since_day, today) {
+                continue;
+            }
+            let (subject, body) = render(*guild_id, report.period, tally, today);
+            match self.mailer.send(&report.to, &subject, &body).await {
+                Ok(()) => *tally = ReportTally::starting(today),
+                Err(e) => tracing::warn!("Failed to email report for guild {}: {}", guild_id, e),
+            }
+        }
+        self.save(&tallies).await
+    }
+
+    /// Records events and sends reports until the manager goes away.
+    ///
+    /// # Arguments
+    /// * `events` - A subscription to the manager's purge events
+    pub async fn run(self, mut events: broadcast::Receiver<PurgeEvent>) {
+        let mut check = tokio::time::interval(CHECK_INTERVAL);
+        loop {
+            let result = tokio::select! {
+                event = events.recv() => match event {
+                    Ok(event) => self.record(&event).await,
+                    Err(RecvError::Lagged(missed)) => {
+                        tracing::warn!("Email reports fell behind and missed {} purge events", missed);
+                        Ok(())
+                    }
+                    Err(RecvError::Closed) => break,
+                },
+                _ = check.tick() => self.send_due(SerializableInstant::now().utc_day()).await,
+            };
+            if let Err(e) = result {
+                tracing::error!("Email reports failed: {:?}", e);
+            }
+        }
+    }
+}
@@ -0,0 +1,11 @@
+//! Notifications about purge activity for systems outside Discord.
+//!
+//! Notifiers subscribe to the manager's purge events and forward them
+//! elsewhere, each on its own task so a slow endpoint never holds up a purge.
+
+pub mod alerts;
+pub mod bus;
+pub mod email;
+pub mod webhook;
+
+pub use webhook::{sign, WebhookNotifier, SIGNATURE_HEADER, TIMESTAMP_HEADER};
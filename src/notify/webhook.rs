@@ -0,0 +1,135 @@
+//! Signed webhook deliveries of purge events.
+//!
+//! Each event is POSTed as JSON to every configured URL. Receivers verify a
+//! delivery by computing the HMAC-SHA256 of `{timestamp}.{body}` with the shared
+//! secret and comparing it to the signature header, and can reject old
+//! timestamps to guard against replays.
+
+use crate::tasks::PurgeEvent;
+use hmac::{Hmac, Mac};
+use sha2::Sha256;
+use std::time::{Duration, SystemTime, UNIX_EPOCH};
+use tokio::sync::broadcast::{self, error::RecvError};
+
+/// The header carrying the payload's signature, as `sha256=<hex>`.
+pub const SIGNATURE_HEADER: &str = "X-Eule-Signature";
+/// The header carrying the Unix time the payload was signed at.
+pub const TIMESTAMP_HEADER: &str = "X-Eule-Timestamp";
+
+/// How long to wait for an endpoint to answer.
+const REQUEST_TIMEOUT: Duration = Duration::from_secs(10);
+/// How many times a delivery is attempted before it is given up.
+const MAX_ATTEMPTS: u32 = 3;
+
+/// Signs a webhook payload.
+///
+/// # Arguments
+/// * `secret` - The secret shared with the receiver
+/// * `timestamp` - The value sent in the timestamp header
+/// * `body` - The exact bytes of the request body
+///
+/// # Returns
+/// The value of the signature header, `sha256=` followed by the lowercase hex MAC.
+pub fn sign(secret: &str, timestamp: u64, body: &[u8]) -> String {
+    let mut mac =
+        Hmac::<Sha256>::new_from_slice(secret.as_bytes()).expect("HMAC accepts keys of any length");
+    mac.update(timestamp.to_string().as_bytes());
+    mac.update(b".");
+    mac.update(body);
+    let digest: String = mac
+        .finalize()
+        .into_bytes()
+        .iter()
+        .map(|byte| format!("{:02x}", byte))
+        .collect();
+    format!("sha256={}", digest)
+}
+
+/// Delivers purge events to webhook URLs.
+pub struct WebhookNotifier {
+    client: reqwest::Client,
+    urls: Vec<String>,
+    secret: String,
+}
+
+impl WebhookNotifier {
+    /// Creates a notifier.
+    ///
+    /// # Arguments
+    /// * `urls` - The endpoints every event is sent to
+    /// * `secret` - The secret payloads are signed with
+    pub fn new(urls: Vec<String>, secret: String) -> Self {
+        Self {
+            client: crate::proxy::client(),
+            urls,
+            secret,
+        }
+    }
+
+    /// Delivers events until the manager goes away.
+    ///
+    /// Events are delivered in order. If deliveries fall far enough behind that
+    /// events are missed, a warning is logged and delivery carries on with the
+    /// oldest event still buffered.
+    ///
+    /// # Arguments
+    /// * `events` - A subscription to the manager's purge events
+    pub async fn run(self, mut events: broadcast::Receiver<PurgeEvent>) {
+        loop {
+            match events.recv().await {
+                Ok(event) => self.deliver(&event).await,
+                Err(RecvError::Lagged(missed)) => {
+                    tracing::warn!("Webhooks fell behind and skipped {} purge events", missed);
+                }
+                Err(RecvError::Closed) => break,
+            }
+        }
+    }
+
+    async fn deliver(&self, event: &PurgeEvent) {
+        let body = match serde_json::to_vec(event) {
+            Ok(body) => body,
+            Err(e) => {
+                tracing::error!("Failed to serialize purge event: {:?}", e);
+                return;
+            }
+        };
+        for url in &self.urls {
+            for attempt in 1..=MAX_ATTEMPTS {
+                match self.post(url, &body).await {
+                    Ok(()) => break,
+                    Err(e) if attempt == MAX_ATTEMPTS => {
+                        tracing::warn!(
+                            "Giving up on webhook delivery to {} after {} attempts: {}",
+                            url,
+                            attempt,
+                            e
+                        );
+                    }
+                    Err(e) => {
+                        tracing::debug!("Webhook delivery to {} failed: {}", url, e);
+                        tokio::time::sleep(Duration::from_secs(1 << attempt)).await;
+                    }
+                }
+            }
+        }
+    }
+
+    async fn post(&self, url: &str, body: &[u8]) -> Result<(), reqwest::Error> {
+        let timestamp = SystemTime::now()
+            .duration_since(UNIX_EPOCH)
+            .unwrap_or_default()
+            .as_secs();
+        self.client
+            .post(url)
+            .timeout(REQUEST_TIMEOUT)
+            .header("Content-Type", "application/json")
+            .header(TIMESTAMP_HEADER, timestamp.to_string())
+            .header(SIGNATURE_HEADER, sign(&self.secret, timestamp, body))
+            .body(body.to_vec())
+            .send()
+            .await
+            .and_then(reqwest::Response::error_for_status)
+            .map(|_| ())
+    }
+}
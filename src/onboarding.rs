@@ -0,0 +1,106 @@
+//! Welcoming guilds that just added the bot.
+//!
+//! When the bot joins a guild it verifies its command registration, creates
+//! the guild's settings record, and sends the guild owner a direct message
+//! with the permissions it needs and the commands to start with. The gateway
+//! doesn't say who invited the bot, so the owner gets the message.
+//!
+//! The settings record marks the guild as onboarded, so leaving and rejoining
+//! doesn't send the message again.
+//!
+//! Getting there starts with the invite link, which `invite_url` builds with
+//! every permission the bot's commands and tasks use. The link is logged at
+//! startup and served by the admin API at `/api/invite`, so self-hosters
+//! don't have to put it together by hand and miss a permission.
+
+use crate::{
+    commands::sync::sync_commands,
+    i18n::{self, Language},
+    Data, EuleError,
+};
+use poise::serenity_prelude::{self as serenity, ApplicationId, CreateMessage, Guild, Permissions};
+
+/// Discord's page for adding an application to a guild.
+const AUTHORIZE_URL: &str = "https://discord.com/oauth2/authorize";
+
+/// The OAuth2 scopes the bot is invited with: `bot` to join the guild and
+/// `applications.commands` for its slash commands.
+pub const INVITE_SCOPES: [&str; 2] = ["bot", "applications.commands"];
+
+/// Returns the permissions to ask for when the bot is invited.
+///
+/// Any task setting can be turned on from Discord, so this covers what any
+/// task may need (see `CleanupTask::required_permissions`): `SEND_MESSAGES`
+/// for warnings and the log channel, `MANAGE_THREADS` for threads and forums,
+/// `MANAGE_CHANNELS` for topics, slowmode and channel copies, and
+/// `MANAGE_ROLES` for locking channels. `/automod` adds `MANAGE_GUILD`.
+pub fn invite_permissions() -> Permissions {
+    Permissions::VIEW_CHANNEL
+        | Permissions::READ_MESSAGE_HISTORY
+        | Permissions::SEND_MESSAGES
+        | Permissions::MANAGE_MESSAGES
+        | Permissions::MANAGE_THREADS
+        | Permissions::MANAGE_CHANNELS
+        | Permissions::MANAGE_ROLES
+        | Permissions::MANAGE_GUILD
+}
+
+/// Builds the link that adds the bot to a guild.
+///
+/// # Arguments
+/// * `application_id` - The bot's application
+/// * `permissions` - The permissions to ask for, usually `invite_permissions()`
+pub fn invite_url(application_id: ApplicationId, permissions: Permissions) -> String {
+    format!(
+        "{}?client_id={}&scope={}&permissions={}",
+        AUTHORIZE_URL,
+        application_id,
+        INVITE_SCOPES.join("%20"),
+        permissions.bits()
+    )
+}
+
+/// Writes the setup summary sent to a guild's owner.
+///
+/// # Arguments
+/// * `language` - The language to write it in
+/// * `guild` - The guild's name
+pub fn setup_summary(language: Language, guild: &str) -> String {
+    [
+        i18n::text(language, "onboarding.welcome", &[("guild", guild)]),
+        i18n::text(language, "onboarding.permissions", &[]),
+        i18n::text(language, "onboarding.quickstart", &[]),
+    ]
+    .join("\n\n")
+}
+
+/// Onboards a guild the bot just joined.
+///
+/// Failing to reach the owner is only logged, since owners may not accept
+/// direct messages from server members.
+///
+/// # Arguments
+/// * `ctx` - The Serenity context for the shard that received the guild
+/// * `framework` - The framework context, for the command definitions
+/// * `data` - The shared bot data
+/// * `guild` - The guild that was joined
+pub async fn welcome(
+    ctx: &serenity::Context,
+    framework: poise::FrameworkContext<'_, Data, EuleError>,
+    data: &Data,
+    guild: &Guild,
+) -> Result<(), EuleError> {
+    if !data.autoclean_manager.onboard_guild(guild.id).await? {
+        return Ok(());
+    }
+
+    let commands = poise::builtins::create_application_commands(&framework.options().commands);
+    sync_commands(&ctx.http, data.bot.dev_guild(), commands).await?;
+
+    let language = Language::from_locale(&guild.preferred_locale).unwrap_or_default();
+    let message = CreateMessage::new().content(setup_summary(language, &guild.name));
+    if let Err(e) = guild.owner_id.direct_message(ctx, message).await {
+        tracing::warn!("Failed to send a new guild's setup summary: {}", e);
+    }
+    Ok(())
+}
@@ -0,0 +1,187 @@
+//! Deleting links to phishing and malware sites.
+//!
+//! Operators point `phishing.feed_url` at a list of known phishing domains,
+//! which the bot fetches on startup and again every `phishing.refresh_secs`.
+//! The feed is either a JSON array of domains or a text file with one domain
+//! per line, such as a hosts file; blank lines and `#` comments are skipped.
+//! A feed that can't be fetched or is empty leaves the last list in place.
+//!
+//! Scheduled cleanups delete messages linking to a listed domain, or one of
+//! its subdomains, whatever their task would keep: pinned, starred or recent
+//! messages and those with a keep keyword all go. Each guild's log channel is
+//! told how many went. With `phishing.live` set, such messages are also
+//! deleted as soon as they are posted, which needs the privileged message
+//! content intent. Channels on hold are left alone either way.
+
+use crate::{
+    error::EuleError,
+    i18n::{self, Language},
+    purge::{BlockedDomains, DiscordApi},
+    tasks::{AutocleanManager, PurgeEvent, PurgeEventKind},
+    utils::humanize,
+};
+use poise::serenity_prelude::ChannelId;
+use std::{
+    sync::{Arc, PoisonError, RwLock},
+    time::Duration,
+};
+use tokio::sync::broadcast::{self, error::RecvError};
+
+/// How often the feed is fetched unless configured otherwise.
+pub const DEFAULT_REFRESH: Duration = Duration::from_secs(60 * 60);
+
+/// The domains of the last feed fetched, if any.
+static DOMAINS: RwLock<Option<BlockedDomains>> = RwLock::new(None);
+
+/// Returns the phishing domains messages are deleted for, which is empty
+/// until a feed was fetched.
+pub fn blocked_domains() -> BlockedDomains {
+    DOMAINS
+        .read()
+        .unwrap_or_else(PoisonError::into_inner)
+        .clone()
+        .unwrap_or_default()
+}
+
+/// Deletes messages linking to `domains` from now on, in place of the last
+/// list.
+pub fn set_blocked_domains(domains: BlockedDomains) {
+    *DOMAINS.write().unwrap_or_else(PoisonError::into_inner) = Some(domains);
+}
+
+/// Reads the domains of a feed.
+///
+/// # Examples
+///
+/// ```
+/// use eule::phishing::parse_feed;
+///
+/// assert_eq!(parse_feed(r#"["evil.example", "bad.example"]"#).len(), 2);
+/// assert_eq!(parse_feed("# hosts\n0.0.0.0 evil.example\nbad.example\n").len(), 2);
+/// ```
+pub fn parse_feed(body: &str) -> BlockedDomains {
+    if let Ok(domains) = serde_json::from_str::<Vec<String>>(body) {
+        return BlockedDomains::new(domains);
+    }
+    BlockedDomains::new(
+        body.lines()
+            .map(|line| line.split('#').next().unwrap_or_default())
+            // Hosts files put an address before the domain
+            .filter_map(|line| line.split_whitespace().last()),
+    )
+}
+
+/// Fetches and reads the feed at `url`.
+///
+/// # Errors
+///
+/// Returns `EuleError::Feed` if the feed can't be fetched or lists no
+/// domains.
+pub async fn fetch(client: &reqwest::Client, url: &str) -> Result<BlockedDomains, EuleError> {
+    let body = client
+        .get(url)
+        .header("User-Agent", concat!("eule/", env!("CARGO_PKG_VERSION")))
+        .send()
+        .await
+        .and_then(reqwest::Response::error_for_status)
+        .map_err(|e| EuleError::Feed(e.to_string()))?
+        .text()
+        .await
+        .map_err(|e| EuleError::Feed(e.to_string()))?;
+    let domains = parse_feed(&body);
+    if domains.is_empty() {
+        return Err(EuleError::Feed(format!("{} lists no domains", url)));
+    }
+    Ok(domains)
+}
+
+/// Fetches the feed at `url` every `every`, for as long as the bot runs.
+///
+/// # Arguments
+/// * `url` - The feed's address
+/// * `every` - How often to fetch it
+pub async fn refresh(url: String, every: Duration) {
+    let client = crate::proxy::client();
+    let mut interval = tokio::time::interval(every);
+    loop {
+        interval.tick().await;
+        match fetch(&client, &url).await {
+            Ok(domains) => {
+                tracing::info!("Fetched {} phishing domains", domains.len());
+                set_blocked_domains(domains);
+            }
+            Err(e) => tracing::warn!("Keeping the last phishing domains: {}", e),
+        }
+    }
+}
+
+/// Writes the report of the phishing links a cleanup deleted.
+///
+/// # Arguments
+/// * `language` - The language to write it in
+/// * `channel_id` - The cleaned channel
+/// * `count` - How many messages with phishing links were deleted
+pub fn cleanup_text(language: Language, channel_id: ChannelId, count: usize) -> String {
+    i18n::text(
+        language,
+        "phishing.cleanup_deleted",
+        &[
+            ("channel", &format!("<#{}>", channel_id)),
+            ("count", &humanize::count(count as u64)),
+        ],
+    )
+}
+
+/// Tells a guild's log channel about the phishing links a finished cleanup
+/// deleted.
+///
+/// Guilds without a log channel aren't told, and failing to post is only
+/// logged.
+///
+/// # Arguments
+/// * `manager` - The manager holding the guild's settings
+/// * `api` - The Discord client
+/// * `event` - The event of the finished cleanup
+pub async fn report_cleanup<A: DiscordApi + ?Sized>(
+    manager: &AutocleanManager,
+    api: &A,
+    event: &PurgeEvent,
+) {
+    if event.kind != PurgeEventKind::Completed || event.blocked_links == 0 {
+        return;
+    }
+    let settings = manager.guild_settings(event.guild_id).await;
+    let Some(log_channel) = settings.log_channel else {
+        return;
+    };
+    let text = cleanup_text(
+        settings.language.unwrap_or_default(),
+        event.channel_id,
+        event.blocked_links,
+    );
+    if let Err(e) = api.send_message(log_channel, &text, None).await {
+        tracing::warn!("Failed to report deleted phishing links: {}", e);
+    }
+}
+
+/// Reports the phishing links cleanups delete until the manager goes away.
+///
+/// # Arguments
+/// * `manager` - The manager whose cleanups to report
+/// * `api` - The Discord client
+/// * `purges` - A subscription to the manager's purge events
+pub async fn run(
+    manager: AutocleanManager,
+    api: Arc<dyn DiscordApi>,
+    mut purges: broadcast::Receiver<PurgeEvent>,
+) {
+    loop {
+        match purges.recv().await {
+            Ok(event) => report_cleanup(&manager, &*api, &event).await,
+            Err(RecvError::Lagged(missed)) => {
+                tracing::warn!("Phishing reports fell behind and skipped {} events", missed);
+            }
+            Err(RecvError::Closed) => break,
+        }
+    }
+}
@@ -0,0 +1,91 @@
+//! Rotating presence.
+//!
+//! The bot cycles through the activities configured under `[presence]`, filling
+//! in placeholders with live statistics each time an activity is shown.
+
+use crate::{
+    config::{ActivityKind, PresenceActivity, PresenceConfig},
+    tasks::AutocleanManager,
+    utils::humanize,
+};
+use poise::serenity_prelude::{self as serenity, ActivityData};
+use tokio::time::{self, Duration};
+
+/// The shortest allowed rotation interval, to stay clear of gateway rate limits.
+pub const MIN_INTERVAL: Duration = Duration::from_secs(30);
+
+/// The values available to activity templates.
+#[derive(Clone, Copy, Debug, Default, PartialEq, Eq)]
+pub struct PresenceStats {
+    /// The number of guilds the bot is in, for `{guilds}`.
+    pub guilds: usize,
+    /// The number of scheduled cleanup tasks, for `{tasks}`.
+    pub tasks: usize,
+    /// The number of messages deleted today, for `{purged_today}`.
+    pub purged_today: u64,
+}
+
+impl PresenceStats {
+    /// Collects the current statistics.
+    ///
+    /// # Arguments
+    /// * `ctx` - The Serenity context, for the guild cache
+    /// * `manager` - The autoclean manager, for task and purge counts
+    pub async fn collect(ctx: &serenity::Context, manager: &AutocleanManager) -> Self {
+        Self {
+            guilds: ctx.cache.guild_count(),
+            tasks: manager.total_task_count().await,
+            purged_today: manager.purged_today().await,
+        }
+    }
+}
+
+/// Fills in the placeholders of an activity text.
+///
+/// # Arguments
+/// * `template` - The text, possibly containing `{guilds}`, `{tasks}` or `{purged_today}`
+/// * `stats` - The values to substitute
+pub fn render(template: &str, stats: &PresenceStats) -> String {
+    template
+        .replace("{guilds}", &humanize::count(stats.guilds as u64))
+        .replace("{tasks}", &humanize::count(stats.tasks as u64))
+        .replace("{purged_today}", &humanize::count(stats.purged_today))
+}
+
+/// Builds the activity to show for a configured entry.
+///
+/// # Arguments
+/// * `activity` - The configured activity
+/// * `stats` - The values to substitute into its text
+pub fn activity_data(activity: &PresenceActivity, stats: &PresenceStats) -> ActivityData {
+    let text = render(&activity.text, stats);
+    match activity.kind {
+        ActivityKind::Playing => ActivityData::playing(text),
+        ActivityKind::Listening => ActivityData::listening(text),
+        ActivityKind::Watching => ActivityData::watching(text),
+        ActivityKind::Competing => ActivityData::competing(text),
+        ActivityKind::Custom => ActivityData::custom(text),
+    }
+}
+
+/// Cycles through the configured activities until the task is aborted.
+///
+/// The first activity is shown immediately; each following one after the
+/// configured interval, wrapping around at the end of the list.
+///
+/// # Arguments
+/// * `ctx` - The Serenity context whose presence is updated
+/// * `config` - The presence rotation
+/// * `manager` - The autoclean manager, for template statistics
+pub async fn rotate(ctx: serenity::Context, config: PresenceConfig, manager: AutocleanManager) {
+    if config.activities.is_empty() {
+        return;
+    }
+    let period = Duration::from_secs(config.interval_secs).max(MIN_INTERVAL);
+    let mut interval = time::interval(period);
+    for activity in config.activities.iter().cycle() {
+        interval.tick().await;
+        let stats = PresenceStats::collect(&ctx, &manager).await;
+        ctx.set_activity(Some(activity_data(activity, &stats)));
+    }
+}
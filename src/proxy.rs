@@ -0,0 +1,128 @@
+//! Reaching Discord and other services through an outbound proxy.
+//!
+//! Networks that only let traffic out through a proxy set `proxy.url` to an
+//! HTTP, HTTPS or SOCKS5 proxy. Every HTTP request the bot makes then goes
+//! through it: Discord's REST API, webhooks, alerts, and the dashboard's
+//! OAuth. Serenity opens the gateway's websocket itself and can't be pointed
+//! at a proxy, so the gateway (`gateway.discord.gg`) still has to be reachable
+//! directly or through a transparent proxy; `check_gateway` makes sure it is
+//! before the bot connects.
+//!
+//! Every client gives up on requests that take too long, so a hung connection
+//! can't hold up a purge, or the worker running it, for good.
+
+use crate::error::{ConnectionError, EuleError};
+use poise::serenity_prelude::{Http, HttpBuilder};
+use std::{
+    sync::{PoisonError, RwLock},
+    time::Duration,
+};
+use tokio::net::TcpStream;
+
+/// How long a single request may take unless configured otherwise.
+pub const DEFAULT_REQUEST_TIMEOUT: Duration = Duration::from_secs(30);
+
+/// The host Serenity opens the gateway's websocket to.
+pub const GATEWAY_HOST: &str = "gateway.discord.gg";
+
+/// How long `check_gateway` waits for the gateway to take a connection.
+const GATEWAY_CHECK_TIMEOUT: Duration = Duration::from_secs(10);
+
+/// The proxy HTTP requests go through, if any.
+static PROXY: RwLock<Option<reqwest::Proxy>> = RwLock::new(None);
+
+/// Checks a proxy URL.
+///
+/// # Errors
+///
+/// Returns `EuleError::InvalidConfig` if the URL isn't one of a proxy.
+pub fn parse(url: &str) -> Result<reqwest::Proxy, EuleError> {
+    reqwest::Proxy::all(url)
+        .map_err(|e| EuleError::InvalidConfig(format!("invalid proxy.url \"{}\": {}", url, e)))
+}
+
+/// Sends the process's HTTP requests through the proxy at `url` from now on,
+/// or straight to their destination if `url` is `None`.
+///
+/// Clients created before keep going the way they went.
+///
+/// # Errors
+///
+/// Returns `EuleError::InvalidConfig` if the URL isn't one of a proxy.
+pub fn set_proxy(url: Option<&str>) -> Result<(), EuleError> {
+    let proxy = url.map(parse).transpose()?;
+    *PROXY.write().unwrap_or_else(PoisonError::into_inner) = proxy;
+    Ok(())
+}
+
+/// Returns whether HTTP requests go through a proxy.
+pub fn is_set() -> bool {
+    PROXY
+        .read()
+        .unwrap_or_else(PoisonError::into_inner)
+        .is_some()
+}
+
+/// Checks that the gateway can be reached without the proxy, if one is set.
+///
+/// Without this, a network that only lets traffic out through the proxy
+/// leaves the bot retrying its gateway connection for good.
+///
+/// # Errors
+///
+/// Returns `EuleError::Connection` if the gateway doesn't take a connection.
+pub async fn check_gateway() -> Result<(), EuleError> {
+    if !is_set() {
+        return Ok(());
+    }
+    let unreachable = |reason: String| {
+        EuleError::Connection(ConnectionError::FailedConnectionAttempt(format!(
+            "{} can't be reached without the proxy ({}); the gateway doesn't go \
+             through proxy.url, so it must be reachable directly or through a \
+             transparent proxy",
+            GATEWAY_HOST, reason
+        )))
+    };
+    match tokio::time::timeout(
+        GATEWAY_CHECK_TIMEOUT,
+        TcpStream::connect((GATEWAY_HOST, 443)),
+    )
+    .await
+    {
+        Ok(Ok(_)) => Ok(()),
+        Ok(Err(e)) => Err(unreachable(e.to_string())),
+        Err(_) => Err(unreachable(format!(
+            "no answer within {:?}",
+            GATEWAY_CHECK_TIMEOUT
+        ))),
+    }
+}
+
+/// Creates an HTTP client that goes through the proxy, if one is set, and
+/// gives up on requests after `DEFAULT_REQUEST_TIMEOUT`.
+pub fn client() -> reqwest::Client {
+    client_with_timeout(DEFAULT_REQUEST_TIMEOUT)
+}
+
+/// Creates an HTTP client that goes through the proxy, if one is set, and
+/// gives up on requests that take longer than `timeout`.
+fn client_with_timeout(timeout: Duration) -> reqwest::Client {
+    let mut builder = reqwest::Client::builder().timeout(timeout);
+    if let Some(proxy) = PROXY.read().unwrap_or_else(PoisonError::into_inner).clone() {
+        builder = builder.proxy(proxy);
+    }
+    // Only fails where `reqwest::Client::new` would panic too
+    builder.build().expect("Failed to create the HTTP client")
+}
+
+/// Creates a Discord REST client for `token` that goes through the proxy, if
+/// one is set.
+///
+/// Requests that take longer than `timeout` fail with
+/// `EuleError::TimedOut` once they reach the purge API. They aren't retried
+/// right away, since they may have gone through.
+pub fn discord_http(token: &str, timeout: Duration) -> Http {
+    HttpBuilder::new(token)
+        .client(client_with_timeout(timeout))
+        .build()
+}
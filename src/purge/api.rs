@@ -0,0 +1,797 @@
+//! Abstraction over the Discord REST calls used by the purge engine.
+//!
+//! The engine only talks to Discord through the `DiscordApi` trait, so it can
+//! be driven by the real `Http` client or by a simulated API in tests.
+//!
+//! A `SharedHttp` with simulated deletes turned on logs its deletes and
+//! reports them as done without sending them, so the whole bot can be tried
+//! against a real server without losing anything.
+
+use crate::{
+    error::EuleError,
+    purge::{kind::ChannelSupport, starboard::message_links},
+    utils::{serializable_instant::SerializableInstant, snowflake},
+};
+use async_trait::async_trait;
+use poise::serenity_prelude::{
+    self as serenity, ChannelId, CreateAllowedMentions, CreateMessage, EditChannel, EditThread,
+    ForumTagId, GetMessages, Http, MessageId, PermissionOverwrite, PermissionOverwriteType,
+    Permissions, RoleId, UserId,
+};
+use std::sync::{
+    atomic::{AtomicU64, Ordering},
+    Arc, PoisonError,
+};
+use tokio::time::Duration;
+
+/// A message as seen by the cleanup pipeline.
+#[derive(Clone, Debug)]
+pub struct ChannelMessage {
+    /// The ID of the message.
+    pub id: MessageId,
+    /// The ID of the message author.
+    pub author_id: UserId,
+    /// Whether the message is pinned.
+    pub pinned: bool,
+    /// The message's text. Empty unless the bot has the message content intent.
+    pub content: String,
+    /// The message's reactions.
+    pub reactions: Vec<ReactionCount>,
+    /// The messages that jump links in the message's text and embeds point to.
+    pub links: Vec<MessageId>,
+}
+
+/// How often a message was reacted to with one emoji.
+#[derive(Clone, Debug, PartialEq, Eq)]
+pub struct ReactionCount {
+    /// The emoji, as a unicode emoji or `<:name:id>` for custom emoji.
+    pub emoji: String,
+    /// The number of reactions.
+    pub count: u64,
+}
+
+impl ChannelMessage {
+    /// Returns the time the message was posted, derived from its ID.
+    pub fn created_at(&self) -> SerializableInstant {
+        snowflake::to_instant(self.id.get())
+    }
+}
+
+impl From<&serenity::Message> for ChannelMessage {
+    fn from(message: &serenity::Message) -> Self {
+        let reactions = message
+            .reactions
+            .iter()
+            .map(|reaction| ReactionCount {
+                emoji: match &reaction.reaction_type {
+                    serenity::ReactionType::Custom { id, name, .. } => {
+                        format!("<:{}:{}>", name.as_deref().unwrap_or_default(), id)
+                    }
+                    serenity::ReactionType::Unicode(emoji) => emoji.clone(),
+                    _ => String::new(),
+                },
+                count: reaction.count,
+            })
+            .collect();
+        let embed_text = message.embeds.iter().flat_map(|embed| {
+            [embed.url.as_deref(), embed.description.as_deref()]
+                .into_iter()
+                .flatten()
+                .chain(embed.fields.iter().map(|field| field.value.as_str()))
+        });
+        let links = std::iter::once(message.content.as_str())
+            .chain(embed_text)
+            .flat_map(message_links)
+            .collect();
+        Self {
+            id: message.id,
+            author_id: message.author.id,
+            pinned: message.pinned,
+            content: message.content.clone(),
+            reactions,
+            links,
+        }
+    }
+}
+
+/// A thread under a channel, as seen by the purge engine.
+///
+/// Forum posts are threads too.
+#[derive(Clone, Debug)]
+pub struct ThreadInfo {
+    /// The ID of the thread.
+    pub id: ChannelId,
+    /// Whether the thread is archived. Messages in archived threads cannot be
+    /// deleted until the thread is unarchived.
+    pub archived: bool,
+    /// The ID of the most recent message in the thread, if any.
+    pub last_message_id: Option<MessageId>,
+    /// The forum tags applied to the thread.
+    pub applied_tags: Vec<ForumTagId>,
+}
+
+impl ThreadInfo {
+    /// Returns the time the thread was created, derived from its ID.
+    pub fn created_at(&self) -> SerializableInstant {
+        snowflake::to_instant(self.id.get())
+    }
+
+    /// Returns the time of the last activity in the thread.
+    ///
+    /// This is the time of the most recent message, or the creation time for an
+    /// empty thread.
+    pub fn last_activity(&self) -> SerializableInstant {
+        snowflake::to_instant(self.last_message_id.map_or(self.id.get(), MessageId::get))
+    }
+}
+
+impl From<&serenity::GuildChannel> for ThreadInfo {
+    fn from(thread: &serenity::GuildChannel) -> Self {
+        Self {
+            id: thread.id,
+            archived: thread
+                .thread_metadata
+                .as_ref()
+                .is_some_and(|metadata| metadata.archived),
+            last_message_id: thread.last_message_id,
+            applied_tags: thread.applied_tags.clone(),
+        }
+    }
+}
+
+/// What a channel's permission overwrite for @everyone says about sending messages.
+#[derive(Clone, Copy, Debug, Default, PartialEq, Eq)]
+pub enum SendPermission {
+    /// The overwrite doesn't mention it, so the server's role permissions apply.
+    #[default]
+    Inherit,
+    /// The overwrite allows sending messages.
+    Allow,
+    /// The overwrite denies sending messages.
+    Deny,
+}
+
+/// The subset of the Discord REST API needed to clean a channel.
+#[async_trait]
+pub trait DiscordApi: Send + Sync {
+    /// Fetches up to `limit` messages, newest first, optionally older than `before`.
+    async fn messages(
+        &self,
+        channel_id: ChannelId,
+        before: Option<MessageId>,
+        limit: u8,
+    ) -> Result<Vec<ChannelMessage>, EuleError>;
+
+    /// Fetches up to `limit` messages newer than `after`, oldest first.
+    async fn messages_after(
+        &self,
+        channel_id: ChannelId,
+        after: MessageId,
+        limit: u8,
+    ) -> Result<Vec<ChannelMessage>, EuleError>;
+
+    /// Deletes between 2 and 100 messages younger than 14 days in a single request.
+    async fn delete_messages(
+        &self,
+        channel_id: ChannelId,
+        message_ids: &[MessageId],
+    ) -> Result<(), EuleError>;
+
+    /// Deletes a single message.
+    async fn delete_message(
+        &self,
+        channel_id: ChannelId,
+        message_id: MessageId,
+    ) -> Result<(), EuleError>;
+
+    /// Lists the active and archived threads whose parent is `channel_id`.
+    async fn threads(&self, channel_id: ChannelId) -> Result<Vec<ThreadInfo>, EuleError>;
+
+    /// Archives or unarchives a thread.
+    async fn set_archived(&self, thread_id: ChannelId, archived: bool) -> Result<(), EuleError>;
+
+    /// Deletes a thread, including all of its messages.
+    async fn delete_thread(&self, thread_id: ChannelId) -> Result<(), EuleError>;
+
+    /// Fetches a channel's topic, or `None` if it has none.
+    async fn topic(&self, channel_id: ChannelId) -> Result<Option<String>, EuleError>;
+
+    /// Replaces a channel's topic.
+    async fn set_topic(&self, channel_id: ChannelId, topic: &str) -> Result<(), EuleError>;
+
+    /// Fetches a channel's slowmode in seconds, `0` if it has none.
+    async fn slowmode(&self, channel_id: ChannelId) -> Result<u16, EuleError>;
+
+    /// Sets a channel's slowmode in seconds, `0` to turn it off.
+    async fn set_slowmode(&self, channel_id: ChannelId, seconds: u16) -> Result<(), EuleError>;
+
+    /// Fetches whether @everyone may send messages in a channel, according to
+    /// the channel's permission overwrite for @everyone.
+    async fn everyone_send(&self, channel_id: ChannelId) -> Result<SendPermission, EuleError>;
+
+    /// Changes whether @everyone may send messages in a channel, leaving the
+    /// rest of the channel's permission overwrite for @everyone alone.
+    async fn set_everyone_send(
+        &self,
+        channel_id: ChannelId,
+        permission: SendPermission,
+    ) -> Result<(), EuleError>;
+
+    /// Posts a message, notifying the members of `ping` if it is mentioned.
+    ///
+    /// Mentions of anyone else in `content` are shown but don't notify.
+    async fn send_message(
+        &self,
+        channel_id: ChannelId,
+        content: &str,
+        ping: Option<RoleId>,
+    ) -> Result<MessageId, EuleError>;
+
+    /// Returns the pause after each delete of a message older than 14 days
+    /// for purges that don't set their own `old_message_delay`.
+    ///
+    /// Bot accounts shared between many channels can trade purge speed for
+    /// fewer rate limits this way. There is no pause unless the client says so.
+    fn old_message_delay(&self) -> Duration {
+        Duration::ZERO
+    }
+}
+
+/// Fetches a server channel and its permission overwrite for @everyone, if it
+/// has one.
+async fn everyone_overwrite(
+    http: &Http,
+    channel_id: ChannelId,
+) -> Result<(serenity::GuildChannel, Option<PermissionOverwrite>), EuleError> {
+    let Some(channel) = channel_id
+        .to_channel(http)
+        .await
+        .map_err(map_http_error)?
+        .guild()
+    else {
+        return Err(EuleError::UnsupportedChannel(
+            "only server channels can be locked".to_string(),
+        ));
+    };
+    let everyone = PermissionOverwriteType::Role(channel.guild_id.everyone_role());
+    let overwrite = channel
+        .permission_overwrites
+        .iter()
+        .find(|overwrite| overwrite.kind == everyone)
+        .cloned();
+    Ok((channel, overwrite))
+}
+
+/// Converts a Serenity error into an `EuleError`, surfacing rate limits,
+/// missing permissions and timeouts explicitly.
+pub(crate) fn map_http_error(error: serenity::Error) -> EuleError {
+    if let serenity::Error::Http(serenity::HttpError::Request(e)) = &error {
+        if e.is_timeout() {
+            return EuleError::TimedOut(e.to_string());
+        }
+    }
+    let status = match &error {
+        serenity::Error::Http(e) => e.status_code().map(|s| s.as_u16()),
+        _ => None,
+    };
+    match status {
+        Some(429) => EuleError::RateLimited(Duration::from_secs(1)),
+        Some(403) => EuleError::MissingPermissions(error.to_string()),
+        _ => EuleError::DiscordApi(error),
+    }
+}
+
+#[async_trait]
+impl DiscordApi for Http {
+    async fn messages(
+        &self,
+        channel_id: ChannelId,
+        before: Option<MessageId>,
+        limit: u8,
+    ) -> Result<Vec<ChannelMessage>, EuleError> {
+        let mut request = GetMessages::new().limit(limit);
+        if let Some(before) = before {
+            request = request.before(before);
+        }
+        let messages = channel_id
+            .messages(self, request)
+            .await
+            .map_err(map_http_error)?;
+        Ok(messages.iter().map(ChannelMessage::from).collect())
+    }
+
+    async fn messages_after(
+        &self,
+        channel_id: ChannelId,
+        after: MessageId,
+        limit: u8,
+    ) -> Result<Vec<ChannelMessage>, EuleError> {
+        let messages = channel_id
+            .messages(self, GetMessages::new().after(after).limit(limit))
+            .await
+            .map_err(map_http_error)?;
+        // Discord lists them newest first, like any other page
+        let mut messages: Vec<ChannelMessage> = messages.iter().map(ChannelMessage::from).collect();
+        messages.sort_by_key(|message| message.id);
+        Ok(messages)
+    }
+
+    async fn delete_messages(
+        &self,
+        channel_id: ChannelId,
+        message_ids: &[MessageId],
+    ) -> Result<(), EuleError> {
+        channel_id
+            .delete_messages(self, message_ids)
+            .await
+            .map_err(map_http_error)
+    }
+
+    async fn delete_message(
+        &self,
+        channel_id: ChannelId,
+        message_id: MessageId,
+    ) -> Result<(), EuleError> {
+        channel_id
+            .delete_message(self, message_id)
+            .await
+            .map_err(map_http_error)
+    }
+
+    async fn threads(&self, channel_id: ChannelId) -> Result<Vec<ThreadInfo>, EuleError> {
+        let Some(channel) = channel_id
+            .to_channel(self)
+            .await
+            .map_err(map_http_error)?
+            .guild()
+        else {
+            return Ok(Vec::new());
+        };
+        // Voice and stage chats have no threads, and Discord rejects listing them
+        if !ChannelSupport::of(channel.kind).has_threads() {
+            return Ok(Vec::new());
+        }
+
+        let mut threads: Vec<ThreadInfo> = channel
+            .guild_id
+            .get_active_threads(self)
+            .await
+            .map_err(map_http_error)?
+            .threads
+            .into_iter()
+            .filter(|thread| thread.parent_id == Some(channel_id))
+            .map(|thread| ThreadInfo::from(&thread))
+            .collect();
+
+        threads.extend(archived_threads(self, channel_id, false).await?);
+        // Listing private archived threads needs MANAGE_THREADS; skip them without it
+        match archived_threads(self, channel_id, true).await {
+            Ok(private) => threads.extend(private),
+            Err(e) => tracing::warn!(
+                "Skipping private archived threads of channel {:x}: {:?}",
+                channel_id.get(),
+                e
+            ),
+        }
+        Ok(threads)
+    }
+
+    async fn set_archived(&self, thread_id: ChannelId, archived: bool) -> Result<(), EuleError> {
+        thread_id
+            .edit_thread(self, EditThread::new().archived(archived))
+            .await
+            .map(|_| ())
+            .map_err(map_http_error)
+    }
+
+    async fn delete_thread(&self, thread_id: ChannelId) -> Result<(), EuleError> {
+        thread_id
+            .delete(self)
+            .await
+            .map(|_| ())
+            .map_err(map_http_error)
+    }
+
+    async fn topic(&self, channel_id: ChannelId) -> Result<Option<String>, EuleError> {
+        Ok(channel_id
+            .to_channel(self)
+            .await
+            .map_err(map_http_error)?
+            .guild()
+            .and_then(|channel| channel.topic)
+            .filter(|topic| !topic.is_empty()))
+    }
+
+    async fn set_topic(&self, channel_id: ChannelId, topic: &str) -> Result<(), EuleError> {
+        channel_id
+            .edit(self, EditChannel::new().topic(topic))
+            .await
+            .map(|_| ())
+            .map_err(map_http_error)
+    }
+
+    async fn slowmode(&self, channel_id: ChannelId) -> Result<u16, EuleError> {
+        Ok(channel_id
+            .to_channel(self)
+            .await
+            .map_err(map_http_error)?
+            .guild()
+            .and_then(|channel| channel.rate_limit_per_user)
+            .unwrap_or(0))
+    }
+
+    async fn set_slowmode(&self, channel_id: ChannelId, seconds: u16) -> Result<(), EuleError> {
+        channel_id
+            .edit(self, EditChannel::new().rate_limit_per_user(seconds))
+            .await
+            .map(|_| ())
+            .map_err(map_http_error)
+    }
+
+    async fn everyone_send(&self, channel_id: ChannelId) -> Result<SendPermission, EuleError> {
+        let (_, overwrite) = everyone_overwrite(self, channel_id).await?;
+        Ok(match overwrite {
+            Some(overwrite) if overwrite.deny.contains(Permissions::SEND_MESSAGES) => {
+                SendPermission::Deny
+            }
+            Some(overwrite) if overwrite.allow.contains(Permissions::SEND_MESSAGES) => {
+                SendPermission::Allow
+            }
+            _ => SendPermission::Inherit,
+        })
+    }
+
+    async fn set_everyone_send(
+        &self,
+        channel_id: ChannelId,
+        permission: SendPermission,
+    ) -> Result<(), EuleError> {
+        let (channel, existing) = everyone_overwrite(self, channel_id).await?;
+        let kind = PermissionOverwriteType::Role(channel.guild_id.everyone_role());
+        let mut overwrite = existing.clone().unwrap_or(PermissionOverwrite {
+            allow: Permissions::empty(),
+            deny: Permissions::empty(),
+            kind,
+        });
+        overwrite.allow = overwrite.allow - Permissions::SEND_MESSAGES;
+        overwrite.deny = overwrite.deny - Permissions::SEND_MESSAGES;
+        match permission {
+            SendPermission::Inherit => {}
+            SendPermission::Allow => overwrite.allow |= Permissions::SEND_MESSAGES,
+            SendPermission::Deny => overwrite.deny |= Permissions::SEND_MESSAGES,
+        }
+
+        let empty =
+            overwrite.allow == Permissions::empty() && overwrite.deny == Permissions::empty();
+        if !empty {
+            channel_id.create_permission(self, overwrite).await
+        } else if existing.is_some() {
+            channel_id.delete_permission(self, kind).await
+        } else {
+            Ok(())
+        }
+        .map_err(map_http_error)
+    }
+
+    async fn send_message(
+        &self,
+        channel_id: ChannelId,
+        content: &str,
+        ping: Option<RoleId>,
+    ) -> Result<MessageId, EuleError> {
+        let mentions = CreateAllowedMentions::new().roles(ping);
+        channel_id
+            .send_message(
+                self,
+                CreateMessage::new()
+                    .content(content)
+                    .allowed_mentions(mentions),
+            )
+            .await
+            .map(|message| message.id)
+            .map_err(map_http_error)
+    }
+}
+
+/// Pages through the public or private archived threads of a channel.
+async fn archived_threads(
+    http: &Http,
+    channel_id: ChannelId,
+    private: bool,
+) -> Result<Vec<ThreadInfo>, EuleError> {
+    let mut threads = Vec::new();
+    let mut before = None;
+    loop {
+        let page = if private {
+            channel_id
+                .get_archived_private_threads(http, before, Some(100))
+                .await
+        } else {
+            channel_id
+                .get_archived_public_threads(http, before, Some(100))
+                .await
+        }
+        .map_err(map_http_error)?;
+
+        before = page
+            .threads
+            .last()
+            .and_then(|thread| thread.thread_metadata.as_ref())
+            .and_then(|metadata| metadata.archive_timestamp)
+            .map(|timestamp| timestamp.unix_timestamp() as u64);
+        threads.extend(page.threads.iter().map(|thread| ThreadInfo {
+            archived: true,
+            ..ThreadInfo::from(thread)
+        }));
+        if !page.has_more || before.is_none() {
+            return Ok(threads);
+        }
+    }
+}
+
+#[async_trait]
+impl<T: DiscordApi + ?Sized> DiscordApi for Arc<T> {
+    async fn messages(
+        &self,
+        channel_id: ChannelId,
+        before: Option<MessageId>,
+        limit: u8,
+    ) -> Result<Vec<ChannelMessage>, EuleError> {
+        (**self).messages(channel_id, before, limit).await
+    }
+
+    async fn messages_after(
+        &self,
+        channel_id: ChannelId,
+        after: MessageId,
+        limit: u8,
+    ) -> Result<Vec<ChannelMessage>, EuleError> {
+        (**self).messages_after(channel_id, after, limit).await
+    }
+
+    async fn delete_messages(
+        &self,
+        channel_id: ChannelId,
+        message_ids: &[MessageId],
+    ) -> Result<(), EuleError> {
+        (**self).delete_messages(channel_id, message_ids).await
+    }
+
+    async fn delete_message(
+        &self,
+        channel_id: ChannelId,
+        message_id: MessageId,
+    ) -> Result<(), EuleError> {
+        (**self).delete_message(channel_id, message_id).await
+    }
+
+    async fn threads(&self, channel_id: ChannelId) -> Result<Vec<ThreadInfo>, EuleError> {
+        (**self).threads(channel_id).await
+    }
+
+    async fn set_archived(&self, thread_id: ChannelId, archived: bool) -> Result<(), EuleError> {
+        (**self).set_archived(thread_id, archived).await
+    }
+
+    async fn delete_thread(&self, thread_id: ChannelId) -> Result<(), EuleError> {
+        (**self).delete_thread(thread_id).await
+    }
+
+    async fn topic(&self, channel_id: ChannelId) -> Result<Option<String>, EuleError> {
+        (**self).topic(channel_id).await
+    }
+
+    async fn set_topic(&self, channel_id: ChannelId, topic: &str) -> Result<(), EuleError> {
+        (**self).set_topic(channel_id, topic).await
+    }
+
+    async fn slowmode(&self, channel_id: ChannelId) -> Result<u16, EuleError> {
+        (**self).slowmode(channel_id).await
+    }
+
+    async fn set_slowmode(&self, channel_id: ChannelId, seconds: u16) -> Result<(), EuleError> {
+        (**self).set_slowmode(channel_id, seconds).await
+    }
+
+    async fn everyone_send(&self, channel_id: ChannelId) -> Result<SendPermission, EuleError> {
+        (**self).everyone_send(channel_id).await
+    }
+
+    async fn set_everyone_send(
+        &self,
+        channel_id: ChannelId,
+        permission: SendPermission,
+    ) -> Result<(), EuleError> {
+        (**self).set_everyone_send(channel_id, permission).await
+    }
+
+    async fn send_message(
+        &self,
+        channel_id: ChannelId,
+        content: &str,
+        ping: Option<RoleId>,
+    ) -> Result<MessageId, EuleError> {
+        (**self).send_message(channel_id, content, ping).await
+    }
+
+    fn old_message_delay(&self) -> Duration {
+        (**self).old_message_delay()
+    }
+}
+
+/// An `Http` client that can be replaced while it is in use, such as when the
+/// bot's token is rotated. Clones share the client.
+///
+/// Every call goes to the client that is current when the call is made, so a
+/// long purge switches to a new client between two requests.
+///
+/// With simulated deletes, messages, threads, and channels are all left in
+/// place: deletes are logged and reported as done without being sent, and
+/// copying a channel returns the channel itself. Purges count the messages
+/// they would have deleted.
+#[derive(Clone)]
+pub struct SharedHttp {
+    http: Arc<std::sync::RwLock<Arc<Http>>>,
+    simulated_deletes: bool,
+    /// The pause after each delete of an old message, in milliseconds.
+    old_message_delay_ms: Arc<AtomicU64>,
+}
+
+impl SharedHttp {
+    /// Creates a shared client starting out with `http`.
+    pub fn new(http: Arc<Http>) -> Self {
+        Self {
+            http: Arc::new(std::sync::RwLock::new(http)),
+            simulated_deletes: false,
+            old_message_delay_ms: Arc::new(AtomicU64::new(0)),
+        }
+    }
+
+    /// Makes the client only pretend to delete, or stop pretending.
+    pub fn with_simulated_deletes(mut self, enabled: bool) -> Self {
+        self.simulated_deletes = enabled;
+        self
+    }
+
+    /// Returns whether the client only pretends to delete.
+    pub fn simulates_deletes(&self) -> bool {
+        self.simulated_deletes
+    }
+
+    /// Sets the pause after each delete of a message older than 14 days for
+    /// every purge through the client that doesn't set its own. Clones share
+    /// the pause, so a reloaded configuration reaches purges already running.
+    pub fn set_old_message_delay(&self, delay: Duration) {
+        self.old_message_delay_ms
+            .store(delay.as_millis() as u64, Ordering::Relaxed);
+    }
+
+    /// Returns the client calls go to right now.
+    pub fn current(&self) -> Arc<Http> {
+        match self.http.read() {
+            Ok(http) => Arc::clone(&http),
+            Err(poisoned) => Arc::clone(&poisoned.into_inner()),
+        }
+    }
+
+    /// Sends all calls from now on to `http`.
+    pub fn replace(&self, http: Arc<Http>) {
+        *self.http.write().unwrap_or_else(PoisonError::into_inner) = http;
+    }
+}
+
+#[async_trait]
+impl DiscordApi for SharedHttp {
+    async fn messages(
+        &self,
+        channel_id: ChannelId,
+        before: Option<MessageId>,
+        limit: u8,
+    ) -> Result<Vec<ChannelMessage>, EuleError> {
+        self.current().messages(channel_id, before, limit).await
+    }
+
+    async fn messages_after(
+        &self,
+        channel_id: ChannelId,
+        after: MessageId,
+        limit: u8,
+    ) -> Result<Vec<ChannelMessage>, EuleError> {
+        self.current()
+            .messages_after(channel_id, after, limit)
+            .await
+    }
+
+    async fn delete_messages(
+        &self,
+        channel_id: ChannelId,
+        message_ids: &[MessageId],
+    ) -> Result<(), EuleError> {
+        if self.simulated_deletes {
+            tracing::info!(
+                "Dry run: would delete {} messages in channel {:x}",
+                message_ids.len(),
+                channel_id.get()
+            );
+            return Ok(());
+        }
+        self.current()
+            .delete_messages(channel_id, message_ids)
+            .await
+    }
+
+    async fn delete_message(
+        &self,
+        channel_id: ChannelId,
+        message_id: MessageId,
+    ) -> Result<(), EuleError> {
+        if self.simulated_deletes {
+            tracing::info!(
+                "Dry run: would delete message {:x} in channel {:x}",
+                message_id.get(),
+                channel_id.get()
+            );
+            return Ok(());
+        }
+        self.current().delete_message(channel_id, message_id).await
+    }
+
+    async fn threads(&self, channel_id: ChannelId) -> Result<Vec<ThreadInfo>, EuleError> {
+        self.current().threads(channel_id).await
+    }
+
+    async fn set_archived(&self, thread_id: ChannelId, archived: bool) -> Result<(), EuleError> {
+        self.current().set_archived(thread_id, archived).await
+    }
+
+    async fn delete_thread(&self, thread_id: ChannelId) -> Result<(), EuleError> {
+        if self.simulated_deletes {
+            tracing::info!("Dry run: would delete thread {:x}", thread_id.get());
+            return Ok(());
+        }
+        self.current().delete_thread(thread_id).await
+    }
+
+    async fn topic(&self, channel_id: ChannelId) -> Result<Option<String>, EuleError> {
+        self.current().topic(channel_id).await
+    }
+
+    async fn set_topic(&self, channel_id: ChannelId, topic: &str) -> Result<(), EuleError> {
+        self.current().set_topic(channel_id, topic).await
+    }
+
+    async fn slowmode(&self, channel_id: ChannelId) -> Result<u16, EuleError> {
+        self.current().slowmode(channel_id).await
+    }
+
+    async fn set_slowmode(&self, channel_id: ChannelId, seconds: u16) -> Result<(), EuleError> {
+        self.current().set_slowmode(channel_id, seconds).await
+    }
+
+    async fn everyone_send(&self, channel_id: ChannelId) -> Result<SendPermission, EuleError> {
+        self.current().everyone_send(channel_id).await
+    }
+
+    async fn set_everyone_send(
+        &self,
+        channel_id: ChannelId,
+        permission: SendPermission,
+    ) -> Result<(), EuleError> {
+        self.current()
+            .set_everyone_send(channel_id, permission)
+            .await
+    }
+
+    async fn send_message(
+        &self,
+        channel_id: ChannelId,
+        content: &str,
+        ping: Option<RoleId>,
+    ) -> Result<MessageId, EuleError> {
+        self.current().send_message(channel_id, content, ping).await
+    }
+
+    fn old_message_delay(&self) -> Duration {
+        Duration::from_millis(self.old_message_delay_ms.load(Ordering::Relaxed))
+    }
+}
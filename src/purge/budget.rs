@@ -0,0 +1,81 @@
+//! Caps on how many messages purges may delete per window of time.
+//!
+//! A budget is shared by every purge that should count against the same cap,
+//! such as all purges in one guild. Once a window's deletions are used up,
+//! purges pause until the next window starts, so one busy guild can't take
+//! all of the bot's rate capacity.
+
+use tokio::{
+    sync::Mutex,
+    time::{Duration, Instant},
+};
+
+/// A number of deletions allowed per fixed window of time.
+#[derive(Debug)]
+pub struct DeletionBudget {
+    /// The window deletions are counted over.
+    window: Duration,
+    /// The cap and the current window's use of it.
+    state: Mutex<BudgetState>,
+}
+
+#[derive(Debug)]
+struct BudgetState {
+    /// The deletions allowed per window.
+    limit: u32,
+    /// When the current window started.
+    started: Instant,
+    /// The deletions claimed in the current window.
+    used: u32,
+}
+
+impl DeletionBudget {
+    /// Creates a budget allowing `limit` deletions per `window`, starting now.
+    ///
+    /// # Parameters
+    /// - `limit`: The deletions allowed per window, at least one.
+    /// - `window`: The length of each window.
+    pub fn new(limit: u32, window: Duration) -> Self {
+        Self {
+            window,
+            state: Mutex::new(BudgetState {
+                limit: limit.max(1),
+                started: Instant::now(),
+                used: 0,
+            }),
+        }
+    }
+
+    /// Changes the deletions allowed per window, including the current one.
+    pub async fn set_limit(&self, limit: u32) {
+        self.state.lock().await.limit = limit.max(1);
+    }
+
+    /// Returns the deletions allowed per window.
+    pub async fn limit(&self) -> u32 {
+        self.state.lock().await.limit
+    }
+
+    /// Claims up to `wanted` deletions from the current window.
+    ///
+    /// # Returns
+    /// The number of deletions granted, at least one unless `wanted` is zero,
+    /// or how long until the next window if the current one is used up.
+    pub async fn claim(&self, wanted: usize) -> Result<usize, Duration> {
+        let mut state = self.state.lock().await;
+        let elapsed = state.started.elapsed();
+        if elapsed >= self.window {
+            // Whole windows only, so windows keep their boundaries while idle
+            let windows = (elapsed.as_secs_f64() / self.window.as_secs_f64()).floor();
+            state.started += self.window.mul_f64(windows);
+            state.used = 0;
+        }
+        let left = state.limit.saturating_sub(state.used) as usize;
+        if left == 0 && wanted > 0 {
+            return Err(self.window.saturating_sub(state.started.elapsed()));
+        }
+        let granted = wanted.min(left);
+        state.used += granted as u32;
+        Ok(granted)
+    }
+}
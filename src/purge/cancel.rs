@@ -0,0 +1,65 @@
+//! Cooperative cancellation of running purges.
+
+use std::sync::{
+    atomic::{AtomicBool, Ordering},
+    Arc,
+};
+use tokio::sync::Notify;
+
+/// A handle that stops a purge before its next request, dropping any request
+/// it has in flight.
+///
+/// Clones share the same state, so one clone can be handed to the purge while
+/// another is kept to cancel it. The default token is never cancelled unless
+/// `cancel` is called on it or one of its clones.
+///
+/// # Examples
+///
+/// ```
+/// use eule::purge::CancelToken;
+///
+/// let token = CancelToken::new();
+/// let handle = token.clone();
+/// handle.cancel();
+/// assert!(token.is_cancelled());
+/// ```
+#[derive(Clone, Debug, Default)]
+pub struct CancelToken(Arc<Cancellation>);
+
+/// The state clones of a token share.
+#[derive(Debug, Default)]
+struct Cancellation {
+    cancelled: AtomicBool,
+    notify: Notify,
+}
+
+impl CancelToken {
+    /// Creates a token that has not been cancelled.
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Requests that the purge stop before its next delete request.
+    pub fn cancel(&self) {
+        self.0.cancelled.store(true, Ordering::SeqCst);
+        self.0.notify.notify_waiters();
+    }
+
+    /// Returns `true` once `cancel` has been called on this token or a clone.
+    pub fn is_cancelled(&self) -> bool {
+        self.0.cancelled.load(Ordering::SeqCst)
+    }
+
+    /// Waits until `cancel` is called on this token or a clone, so a request in
+    /// flight can be dropped instead of waited out.
+    pub async fn cancelled(&self) {
+        let notified = self.0.notify.notified();
+        tokio::pin!(notified);
+        // Registered before checking, so a cancel in between isn't missed
+        notified.as_mut().enable();
+        if self.is_cancelled() {
+            return;
+        }
+        notified.await;
+    }
+}
@@ -0,0 +1,847 @@
+//! The purge loop: pagination, the 14-day split, and request pacing.
+
+use crate::{
+    error::EuleError,
+    purge::{
+        api::{ChannelMessage, DiscordApi, SendPermission},
+        budget::DeletionBudget,
+        cancel::CancelToken,
+        filter::MessageFilter,
+        metrics,
+        retry::{is_retryable, DeferredDeletes},
+    },
+    utils::{rate_limiter::RateLimiter, snowflake, SerializableInstant},
+};
+use poise::serenity_prelude::{ChannelId, MessageId};
+use serde::{Deserialize, Serialize};
+use std::sync::Arc;
+use tokio::{sync::watch, time::Duration};
+
+/// Messages younger than this can be removed with a bulk delete.
+///
+/// Discord rejects bulk deletes of messages older than 14 days; the limit is kept
+/// one minute short of that to allow for clock skew.
+pub const BULK_DELETE_MAX_AGE: Duration = Duration::from_secs(14 * 24 * 60 * 60 - 60);
+
+/// The maximum number of messages fetched or bulk-deleted per request.
+pub(crate) const PAGE_SIZE: u8 = 100;
+
+/// How often a purge paused by its deletion budget checks whether it was
+/// cancelled.
+const BUDGET_POLL: Duration = Duration::from_secs(5);
+
+/// The messages between two messages, both included.
+///
+/// A purge bounded by a range starts paging just after its newest message and
+/// stops once it reaches messages older than its oldest, instead of walking the
+/// whole history.
+#[derive(Clone, Copy, Debug, PartialEq, Eq)]
+pub struct MessageRange {
+    /// The oldest message in the range.
+    pub first: MessageId,
+    /// The newest message in the range.
+    pub last: MessageId,
+}
+
+impl MessageRange {
+    /// Creates the range between two messages, given in either order.
+    ///
+    /// # Examples
+    ///
+    /// ```
+    /// use eule::purge::MessageRange;
+    /// use poise::serenity_prelude::MessageId;
+    ///
+    /// let range = MessageRange::new(MessageId::new(30), MessageId::new(10));
+    /// assert!(range.contains(MessageId::new(10)));
+    /// assert!(range.contains(MessageId::new(30)));
+    /// assert!(!range.contains(MessageId::new(31)));
+    /// ```
+    pub fn new(a: MessageId, b: MessageId) -> Self {
+        Self {
+            first: a.min(b),
+            last: a.max(b),
+        }
+    }
+
+    /// Creates the range of messages posted from `from` up to but not
+    /// including `to`, using the creation time every message ID carries.
+    ///
+    /// # Returns
+    /// The range, or `None` if no message can have been posted in the window.
+    ///
+    /// # Examples
+    ///
+    /// ```
+    /// use eule::{purge::MessageRange, utils::SerializableInstant};
+    /// use std::time::Duration;
+    ///
+    /// let from = SerializableInstant::now();
+    /// let range = MessageRange::posted_between(from, from + Duration::from_secs(60)).unwrap();
+    /// assert!(range.first < range.last);
+    /// assert_eq!(MessageRange::posted_between(from, from), None);
+    /// ```
+    pub fn posted_between(from: SerializableInstant, to: SerializableInstant) -> Option<Self> {
+        let first = snowflake::from_instant(from).max(1);
+        let last = snowflake::from_instant(to).checked_sub(1)?;
+        (first <= last).then(|| Self {
+            first: MessageId::new(first),
+            last: MessageId::new(last),
+        })
+    }
+
+    /// Returns whether a message is in the range.
+    pub fn contains(&self, message_id: MessageId) -> bool {
+        (self.first..=self.last).contains(&message_id)
+    }
+}
+
+/// The order a purge deletes a channel's messages in.
+#[derive(Clone, Copy, Debug, Default, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum DeletionOrder {
+    /// Delete the newest messages first, so what people see goes first. Suits
+    /// emergency cleanups.
+    #[default]
+    NewestFirst,
+    /// Delete the oldest messages first, so a purge cut short by a budget or a
+    /// cancellation always leaves the newest messages behind.
+    OldestFirst,
+}
+
+/// Settings controlling a single purge.
+#[derive(Clone, Debug)]
+pub struct PurgeOptions {
+    /// Selects the messages to delete.
+    pub filter: MessageFilter,
+    /// How many times a rate-limited request is retried before giving up.
+    pub max_rate_limit_retries: u32,
+    /// The number of delete requests allowed per `rate_window`.
+    pub rate: u32,
+    /// The window over which `rate` delete requests are allowed.
+    pub rate_window: Duration,
+    /// Also purge the channel's active and archived threads.
+    pub include_threads: bool,
+    /// Leave the channel's oldest message in place, such as a rules or intro
+    /// post, even if the filter matches it. Threads are purged in full.
+    pub keep_first_message: bool,
+    /// Raise the channel's slowmode to at least this many seconds while the
+    /// purge runs, so new messages trickle in instead of shifting the pages
+    /// being deleted. The previous slowmode is restored afterwards.
+    pub slowmode: Option<u16>,
+    /// Deny @everyone Send Messages in the channel while the purge runs, so the
+    /// channel is empty when it finishes. The previous overwrite is restored
+    /// afterwards.
+    pub lock_channel: bool,
+    /// Only purge messages in this range, if set. The channel's oldest
+    /// message isn't held back from a bounded purge.
+    pub range: Option<MessageRange>,
+    /// The order messages are deleted in. Oldest first leaves out messages
+    /// posted after the purge started; the next purge picks them up.
+    pub order: DeletionOrder,
+    /// Stops the purge once it has deleted this many messages, if set. The
+    /// rest is left for the next purge, with `capped` set on the report.
+    pub max_deletions: Option<usize>,
+    /// Caps the deletions per window this purge shares with others, if set.
+    /// Once the cap is reached the purge pauses until the next window.
+    pub budget: Option<Arc<DeletionBudget>>,
+    /// The pause after each delete of a message older than 14 days, on top of
+    /// the rate. Falls back to the client's `DiscordApi::old_message_delay`
+    /// if unset.
+    pub old_message_delay: Option<Duration>,
+    /// Sets aside the messages whose deletes fail for a passing reason, such
+    /// as a server error, instead of failing the purge, if set.
+    pub deferred: Option<DeferredDeletes>,
+    /// Stops the purge before its next delete request once cancelled.
+    pub cancel: CancelToken,
+    /// Receives the running report after every request, for progress displays.
+    pub progress: Option<watch::Sender<PurgeReport>>,
+}
+
+impl Default for PurgeOptions {
+    fn default() -> Self {
+        Self {
+            filter: MessageFilter::default(),
+            max_rate_limit_retries: 5,
+            rate: 5,
+            rate_window: Duration::from_secs(10),
+            include_threads: false,
+            keep_first_message: false,
+            slowmode: None,
+            lock_channel: false,
+            range: None,
+            order: DeletionOrder::default(),
+            max_deletions: None,
+            budget: None,
+            old_message_delay: None,
+            deferred: None,
+            cancel: CancelToken::default(),
+            progress: None,
+        }
+    }
+}
+
+impl PurgeOptions {
+    /// Returns the pause after each delete of a message older than 14 days
+    /// when purging through `api`.
+    pub fn old_message_delay<A: DiscordApi + ?Sized>(&self, api: &A) -> Duration {
+        self.old_message_delay
+            .unwrap_or_else(|| api.old_message_delay())
+    }
+}
+
+/// The outcome of a purge.
+#[derive(Clone, Copy, Debug, Default, PartialEq, Eq, Serialize)]
+pub struct PurgeReport {
+    /// Messages fetched from the channel.
+    pub scanned: usize,
+    /// Messages deleted.
+    pub deleted: usize,
+    /// Bulk delete requests made.
+    pub bulk_requests: usize,
+    /// Single-message delete requests made.
+    pub single_requests: usize,
+    /// Threads purged in addition to the channel itself.
+    pub threads: usize,
+    /// Messages found that match the filter but have not been deleted yet.
+    ///
+    /// While the purge runs this is a lower bound on the work left, since later
+    /// pages of history have not been fetched.
+    pub pending: usize,
+    /// Messages set aside after their deletes failed for a passing reason.
+    pub deferred: usize,
+    /// Messages found linking to a blocked domain, see
+    /// `MessageFilter::delete_blocked_links`.
+    pub blocked_links: usize,
+    /// Whether the purge was cancelled before it finished.
+    pub cancelled: bool,
+    /// Whether the purge stopped at `max_deletions` before it finished.
+    pub capped: bool,
+}
+
+/// Runs a Discord API call, waiting out and retrying rate-limited attempts.
+///
+/// Every rate-limited attempt is recorded under `bucket`, the kind of request,
+/// and the channel it was for. Attempts that time out aren't retried, since
+/// they may have gone through; the client's own timeout applies, see
+/// `proxy::discord_http`.
+pub(crate) async fn with_retry<T, F, Fut>(
+    max_retries: u32,
+    bucket: &str,
+    channel_id: ChannelId,
+    mut call: F,
+) -> Result<T, EuleError>
+where
+    F: FnMut() -> Fut,
+    Fut: std::future::Future<Output = Result<T, EuleError>>,
+{
+    let mut attempts = 0;
+    loop {
+        match call().await {
+            Err(EuleError::RateLimited(retry_after)) => {
+                metrics::record_throttled(bucket, channel_id, retry_after);
+                if attempts >= max_retries {
+                    return Err(EuleError::RateLimited(retry_after));
+                }
+                attempts += 1;
+                tracing::debug!("Retrying in {:?} (attempt {})", retry_after, attempts);
+                tokio::time::sleep(retry_after).await;
+            }
+            result => return result,
+        }
+    }
+}
+
+/// How long a purge waits when its own rate limiter has no allowance left.
+const PACE_WAIT: Duration = Duration::from_secs(2);
+
+/// Waits briefly if the local rate limiter has no allowance left.
+pub(crate) async fn pace(rate_limiter: &RateLimiter) {
+    if rate_limiter.check().await.is_err() {
+        tracing::warn!("Rate limit reached, waiting before next deletion attempt");
+        metrics::record_paced(PACE_WAIT);
+        tokio::time::sleep(PACE_WAIT).await;
+    }
+}
+
+/// Deletes every message in a channel that matches the options' filter.
+///
+/// Pages through the whole channel history, bulk-deleting messages younger than
+/// 14 days and deleting older ones individually, since Discord refuses to bulk
+/// delete those. With `include_threads` set, the channel's threads are purged
+/// too; archived threads are unarchived for the purge and archived again after.
+/// With `slowmode` set, the channel's slowmode is raised for the purge and
+/// restored after, even if the purge fails. `lock_channel` does the same for
+/// @everyone's permission to send messages. With a `budget`, the purge pauses
+/// whenever the budget's current window is used up. Deletes of messages older
+/// than 14 days are spaced out by the options' `old_message_delay`.
+///
+/// Cancelling the options' token stops the purge before its next delete request.
+/// The purge then returns successfully, with `cancelled` set on the report.
+/// Reaching `max_deletions` stops it the same way, with `capped` set.
+///
+/// # Parameters
+/// - `api`: The Discord API client used to fetch and delete messages.
+/// - `channel_id`: The ID of the channel to purge.
+/// - `options`: The filter and pacing settings for this purge.
+///
+/// # Returns
+/// A report of the work done, or the first error that could not be retried.
+pub async fn purge_channel<A: DiscordApi + ?Sized>(
+    api: &A,
+    channel_id: ChannelId,
+    options: &PurgeOptions,
+) -> Result<PurgeReport, EuleError> {
+    let retries = options.max_rate_limit_retries;
+    let restore = match options.slowmode {
+        Some(seconds) => {
+            let previous =
+                with_retry(retries, "slowmode", channel_id, || api.slowmode(channel_id)).await?;
+            if previous < seconds {
+                with_retry(retries, "set_slowmode", channel_id, || {
+                    api.set_slowmode(channel_id, seconds)
+                })
+                .await?;
+                Some(previous)
+            } else {
+                None
+            }
+        }
+        None => None,
+    };
+
+    let result = purge_locked(api, channel_id, options).await;
+    if let Some(previous) = restore {
+        with_retry(retries, "set_slowmode", channel_id, || {
+            api.set_slowmode(channel_id, previous)
+        })
+        .await?;
+    }
+    result
+}
+
+/// Purges a channel, denying @everyone Send Messages meanwhile if
+/// `lock_channel` is set.
+async fn purge_locked<A: DiscordApi + ?Sized>(
+    api: &A,
+    channel_id: ChannelId,
+    options: &PurgeOptions,
+) -> Result<PurgeReport, EuleError> {
+    let retries = options.max_rate_limit_retries;
+    // A channel that already denies sending is left as it is
+    let restore = match options.lock_channel {
+        true => Some(
+            with_retry(retries, "everyone_send", channel_id, || {
+                api.everyone_send(channel_id)
+            })
+            .await?,
+        )
+        .filter(|previous| *previous != SendPermission::Deny),
+        false => None,
+    };
+    if restore.is_some() {
+        with_retry(retries, "set_everyone_send", channel_id, || {
+            api.set_everyone_send(channel_id, SendPermission::Deny)
+        })
+        .await?;
+    }
+
+    let result = purge_with_threads(api, channel_id, options).await;
+    if let Some(previous) = restore {
+        with_retry(retries, "set_everyone_send", channel_id, || {
+            api.set_everyone_send(channel_id, previous)
+        })
+        .await?;
+    }
+    result
+}
+
+/// Purges a channel and, with `include_threads` set, its threads.
+async fn purge_with_threads<A: DiscordApi + ?Sized>(
+    api: &A,
+    channel_id: ChannelId,
+    options: &PurgeOptions,
+) -> Result<PurgeReport, EuleError> {
+    let retries = options.max_rate_limit_retries;
+    let rate_limiter = RateLimiter::new(options.rate, options.rate_window);
+    let mut report = PurgeReport::default();
+
+    let keep_first = options.keep_first_message && options.range.is_none();
+    purge_until_cancelled(
+        api,
+        channel_id,
+        options,
+        keep_first,
+        &rate_limiter,
+        &mut report,
+    )
+    .await?;
+
+    if options.include_threads {
+        for thread in with_retry(retries, "threads", channel_id, || api.threads(channel_id)).await?
+        {
+            if report.cancelled || report.capped {
+                break;
+            }
+            if thread.archived {
+                with_retry(retries, "set_archived", thread.id, || {
+                    api.set_archived(thread.id, false)
+                })
+                .await?;
+            }
+            let result =
+                purge_until_cancelled(api, thread.id, options, false, &rate_limiter, &mut report)
+                    .await;
+            if thread.archived {
+                with_retry(retries, "set_archived", thread.id, || {
+                    api.set_archived(thread.id, true)
+                })
+                .await?;
+            }
+            result?;
+            report.threads += 1;
+        }
+    }
+
+    Ok(report)
+}
+
+/// Purges the messages of a single channel or thread like `purge_messages`,
+/// but drops the request in flight, or the wait for the rate limit or the
+/// budget, as soon as the purge is cancelled.
+///
+/// Anything the purge changed about the channel is still restored by the
+/// caller afterwards.
+async fn purge_until_cancelled<A: DiscordApi + ?Sized>(
+    api: &A,
+    channel_id: ChannelId,
+    options: &PurgeOptions,
+    keep_first: bool,
+    rate_limiter: &RateLimiter,
+    report: &mut PurgeReport,
+) -> Result<(), EuleError> {
+    tokio::select! {
+        result = purge_messages(api, channel_id, options, keep_first, rate_limiter, report) => result,
+        _ = options.cancel.cancelled() => {
+            report.cancelled = true;
+            publish(options, report);
+            Ok(())
+        }
+    }
+}
+
+/// Purges the messages of a single channel or thread, leaving its oldest
+/// message in place if `keep_first` is set.
+///
+/// The next page is fetched while the current one is being deleted, so a
+/// purge spends no time waiting on fetches between pages. Only one page is
+/// fetched ahead and deletes are paced as before, so the purge is no more
+/// bursty for it.
+async fn purge_messages<A: DiscordApi + ?Sized>(
+    api: &A,
+    channel_id: ChannelId,
+    options: &PurgeOptions,
+    keep_first: bool,
+    rate_limiter: &RateLimiter,
+    report: &mut PurgeReport,
+) -> Result<(), EuleError> {
+    if options.order == DeletionOrder::OldestFirst {
+        return purge_oldest_first(api, channel_id, options, keep_first, rate_limiter, report)
+            .await;
+    }
+    let retries = options.max_rate_limit_retries;
+    let fetch = |before: Option<MessageId>| {
+        with_retry(retries, "messages", channel_id, move || {
+            api.messages(channel_id, before, PAGE_SIZE)
+        })
+    };
+    let before = options
+        .range
+        .map(|range| MessageId::new(range.last.get().saturating_add(1)));
+    if cancelled(options, report) {
+        return Ok(());
+    }
+    let mut messages = fetch(before).await?;
+    // The oldest message fetched so far, held back until a later page shows
+    // that it isn't the oldest in the channel
+    let mut held = None;
+
+    while let Some(last) = messages.last() {
+        let before = Some(last.id);
+        report.scanned += messages.len();
+        let mut exhausted = messages.len() < PAGE_SIZE as usize;
+        if let Some(range) = options.range {
+            exhausted |= last.id < range.first;
+            messages.retain(|message| range.contains(message.id));
+        }
+        if keep_first {
+            let oldest = messages.pop();
+            messages.extend(held.take());
+            if !exhausted {
+                held = oldest;
+            }
+        }
+
+        let (recent, old) = select(options, &messages);
+        report.pending += recent.len() + old.len();
+        report.blocked_links += blocked_links(options, &messages);
+        publish(options, report);
+
+        let next = async {
+            match exhausted {
+                true => Ok(Vec::new()),
+                false => fetch(before).await,
+            }
+        };
+        let deleting = delete_page(
+            api,
+            channel_id,
+            options,
+            &recent,
+            &old,
+            rate_limiter,
+            report,
+        );
+        let (deleted, next) = tokio::join!(deleting, next);
+        deleted?;
+        if report.cancelled || report.capped {
+            return Ok(());
+        }
+        messages = next?;
+    }
+
+    Ok(())
+}
+
+/// Purges the messages of a single channel or thread oldest first, leaving
+/// its oldest message in place if `keep_first` is set.
+///
+/// Paging stops at the newest message posted before the purge started, or at
+/// the end of the range, so a busy channel can't keep the purge going. Like
+/// `purge_messages`, the next page is fetched while the current one is being
+/// deleted.
+async fn purge_oldest_first<A: DiscordApi + ?Sized>(
+    api: &A,
+    channel_id: ChannelId,
+    options: &PurgeOptions,
+    keep_first: bool,
+    rate_limiter: &RateLimiter,
+    report: &mut PurgeReport,
+) -> Result<(), EuleError> {
+    let retries = options.max_rate_limit_retries;
+    let fetch = |after: MessageId| {
+        with_retry(retries, "messages", channel_id, move || {
+            api.messages_after(channel_id, after, PAGE_SIZE)
+        })
+    };
+    let (after, newest) = match options.range {
+        Some(range) => (
+            MessageId::new(range.first.get().saturating_sub(1).max(1)),
+            range.last,
+        ),
+        None => (
+            MessageId::new(1),
+            MessageId::new(snowflake::from_instant(SerializableInstant::now()).max(1)),
+        ),
+    };
+    if cancelled(options, report) {
+        return Ok(());
+    }
+    let mut messages = fetch(after).await?;
+    let mut keep_first = keep_first;
+
+    while let Some(last) = messages.last() {
+        let after = last.id;
+        report.scanned += messages.len();
+        let exhausted = messages.len() < PAGE_SIZE as usize || after >= newest;
+        messages.retain(|message| message.id <= newest);
+        if let Some(range) = options.range {
+            messages.retain(|message| range.contains(message.id));
+        }
+        // The first page starts with the channel's oldest message
+        if std::mem::take(&mut keep_first) && !messages.is_empty() {
+            messages.remove(0);
+        }
+
+        let (recent, old) = select(options, &messages);
+        report.pending += recent.len() + old.len();
+        report.blocked_links += blocked_links(options, &messages);
+        publish(options, report);
+
+        let next = async {
+            match exhausted {
+                true => Ok(Vec::new()),
+                false => fetch(after).await,
+            }
+        };
+        let deleting = delete_page(
+            api,
+            channel_id,
+            options,
+            &recent,
+            &old,
+            rate_limiter,
+            report,
+        );
+        let (deleted, next) = tokio::join!(deleting, next);
+        deleted?;
+        if report.cancelled || report.capped {
+            return Ok(());
+        }
+        messages = next?;
+    }
+
+    Ok(())
+}
+
+/// Picks the messages of a page that match the options' filter, split into
+/// those young enough to bulk delete and older ones, in the page's order.
+fn select(options: &PurgeOptions, messages: &[ChannelMessage]) -> (Vec<MessageId>, Vec<MessageId>) {
+    messages
+        .iter()
+        .filter(|message| options.filter.matches(message))
+        .map(|message| message.id)
+        .partition(|message_id| bulk_deletable(*message_id))
+}
+
+/// Whether a message is still young enough to be bulk deleted.
+fn bulk_deletable(message_id: MessageId) -> bool {
+    snowflake::to_instant(message_id.get()).elapsed() < BULK_DELETE_MAX_AGE
+}
+
+/// Counts the messages of a page that link to a blocked domain.
+fn blocked_links(options: &PurgeOptions, messages: &[ChannelMessage]) -> usize {
+    messages
+        .iter()
+        .filter(|message| options.filter.links_blocked(message))
+        .count()
+}
+
+/// Deletes one page's worth of messages, bulk deleting the recent ones and
+/// deleting the old ones one at a time. The old ones go first when the purge
+/// deletes oldest first.
+///
+/// Stops early, with `cancelled` set on the report, if the purge is cancelled.
+async fn delete_page<A: DiscordApi + ?Sized>(
+    api: &A,
+    channel_id: ChannelId,
+    options: &PurgeOptions,
+    recent: &[MessageId],
+    old: &[MessageId],
+    rate_limiter: &RateLimiter,
+    report: &mut PurgeReport,
+) -> Result<(), EuleError> {
+    match options.order {
+        DeletionOrder::NewestFirst => {
+            delete_recent(api, channel_id, options, recent, rate_limiter, report).await?;
+            delete_old(api, channel_id, options, old, rate_limiter, report).await
+        }
+        DeletionOrder::OldestFirst => {
+            delete_old(api, channel_id, options, old, rate_limiter, report).await?;
+            delete_recent(api, channel_id, options, recent, rate_limiter, report).await
+        }
+    }
+}
+
+/// Bulk deletes messages younger than 14 days, in batches the deletion budget
+/// allows. Messages that pass the limit while the purge waits for the budget
+/// are deleted one at a time instead.
+async fn delete_recent<A: DiscordApi + ?Sized>(
+    api: &A,
+    channel_id: ChannelId,
+    options: &PurgeOptions,
+    recent: &[MessageId],
+    rate_limiter: &RateLimiter,
+    report: &mut PurgeReport,
+) -> Result<(), EuleError> {
+    let retries = options.max_rate_limit_retries;
+    let mut unclaimed = recent;
+    while !unclaimed.is_empty() {
+        if cancelled(options, report) {
+            return Ok(());
+        }
+        let Some(granted) = claim(options, report, unclaimed.len()).await else {
+            return Ok(());
+        };
+        let (batch, rest) = unclaimed.split_at(granted);
+        // Waiting for the budget can take long enough for messages fetched
+        // just short of the limit to pass it, and one of those fails the
+        // whole bulk delete
+        let (batch, aged): (Vec<MessageId>, Vec<MessageId>) = batch
+            .iter()
+            .partition(|message_id| bulk_deletable(**message_id));
+        for message_id in aged {
+            delete_one(api, channel_id, options, message_id, rate_limiter, report).await?;
+        }
+        if batch.is_empty() {
+            unclaimed = rest;
+            continue;
+        }
+        pace(rate_limiter).await;
+        let result = match batch.as_slice() {
+            [message_id] => {
+                report.single_requests += 1;
+                with_retry(retries, "delete_message", channel_id, || {
+                    api.delete_message(channel_id, *message_id)
+                })
+                .await
+            }
+            _ => {
+                report.bulk_requests += 1;
+                let result = with_retry(retries, "delete_messages", channel_id, || {
+                    api.delete_messages(channel_id, &batch)
+                })
+                .await;
+                if result.is_ok() {
+                    tracing::debug!(
+                        "Bulk deleted {} messages in channel {:x}",
+                        batch.len(),
+                        channel_id.get()
+                    );
+                }
+                result
+            }
+        };
+        settle(options, channel_id, &batch, result, report)?;
+        publish(options, report);
+        unclaimed = rest;
+    }
+    Ok(())
+}
+
+/// Deletes messages older than 14 days one at a time, spaced out by the
+/// options' `old_message_delay`.
+async fn delete_old<A: DiscordApi + ?Sized>(
+    api: &A,
+    channel_id: ChannelId,
+    options: &PurgeOptions,
+    old: &[MessageId],
+    rate_limiter: &RateLimiter,
+    report: &mut PurgeReport,
+) -> Result<(), EuleError> {
+    let delay = options.old_message_delay(api);
+    for (index, &message_id) in old.iter().enumerate() {
+        if index > 0 && !delay.is_zero() {
+            metrics::record_paced(delay);
+            tokio::time::sleep(delay).await;
+        }
+        if cancelled(options, report) {
+            return Ok(());
+        }
+        if claim(options, report, 1).await.is_none() {
+            return Ok(());
+        }
+        delete_one(api, channel_id, options, message_id, rate_limiter, report).await?;
+    }
+    Ok(())
+}
+
+/// Deletes a single message whose deletion the budget has already granted.
+async fn delete_one<A: DiscordApi + ?Sized>(
+    api: &A,
+    channel_id: ChannelId,
+    options: &PurgeOptions,
+    message_id: MessageId,
+    rate_limiter: &RateLimiter,
+    report: &mut PurgeReport,
+) -> Result<(), EuleError> {
+    pace(rate_limiter).await;
+    report.single_requests += 1;
+    let result = with_retry(
+        options.max_rate_limit_retries,
+        "delete_message",
+        channel_id,
+        || api.delete_message(channel_id, message_id),
+    )
+    .await;
+    settle(options, channel_id, &[message_id], result, report)?;
+    publish(options, report);
+    Ok(())
+}
+
+/// Counts the outcome of a delete request on the report.
+///
+/// A delete that failed for a passing reason sets its messages aside if the
+/// options collect those, and fails the purge otherwise, as does any other
+/// failure.
+fn settle(
+    options: &PurgeOptions,
+    channel_id: ChannelId,
+    message_ids: &[MessageId],
+    result: Result<(), EuleError>,
+    report: &mut PurgeReport,
+) -> Result<(), EuleError> {
+    match (result, &options.deferred) {
+        (Ok(()), _) => report.deleted += message_ids.len(),
+        (Err(e), Some(deferred)) if is_retryable(&e) => {
+            tracing::warn!(
+                "Setting aside {} messages in channel {:x} to retry later: {}",
+                message_ids.len(),
+                channel_id.get(),
+                e
+            );
+            deferred.defer(channel_id, message_ids);
+            report.deferred += message_ids.len();
+        }
+        (Err(e), _) => return Err(e),
+    }
+    report.pending -= message_ids.len();
+    Ok(())
+}
+
+/// Waits until the options' deletion budget, if any, allows deleting some of
+/// `wanted` messages, within what is left of `max_deletions`.
+///
+/// # Returns
+/// How many of the messages may be deleted now, or `None` if the purge was
+/// cancelled while it waited for the next window or has reached
+/// `max_deletions`, which sets `capped` on the report.
+async fn claim(options: &PurgeOptions, report: &mut PurgeReport, wanted: usize) -> Option<usize> {
+    let wanted = match options.max_deletions {
+        Some(max) => match max.saturating_sub(report.deleted + report.deferred) {
+            0 => {
+                report.capped = true;
+                return None;
+            }
+            left => wanted.min(left),
+        },
+        None => wanted,
+    };
+    let Some(budget) = &options.budget else {
+        return Some(wanted);
+    };
+    let mut paused = false;
+    loop {
+        match budget.claim(wanted).await {
+            Ok(granted) => return Some(granted),
+            Err(wait) => {
+                if !paused {
+                    tracing::info!("Deletion budget used up, pausing for {:?}", wait);
+                    paused = true;
+                }
+                // Woken early now and then, so cancelling doesn't wait out the window
+                let wait = wait.min(BUDGET_POLL);
+                metrics::record_paced(wait);
+                tokio::time::sleep(wait).await;
+                if cancelled(options, report) {
+                    return None;
+                }
+            }
+        }
+    }
+}
+
+/// Sends the running report to the options' progress channel, if any.
+fn publish(options: &PurgeOptions, report: &PurgeReport) {
+    if let Some(progress) = &options.progress {
+        progress.send_replace(*report);
+    }
+}
+
+/// Marks the report as cancelled if the options' token has been cancelled.
+fn cancelled(options: &PurgeOptions, report: &mut PurgeReport) -> bool {
+    if options.cancel.is_cancelled() {
+        report.cancelled = true;
+    }
+    report.cancelled
+}
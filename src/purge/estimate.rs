@@ -0,0 +1,148 @@
+//! Estimates of how long a purge will take.
+//!
+//! Messages younger than 14 days are deleted a hundred at a time, but older
+//! ones each need a request of their own, so a channel with a long history can
+//! keep a purge busy for hours. An estimate samples the newest pages of a
+//! channel's history. If there is more, the rest of the history, back to when
+//! the channel was created, is assumed to be as busy as the sample.
+
+use crate::{
+    error::EuleError,
+    purge::{
+        api::DiscordApi,
+        engine::{with_retry, PurgeOptions, BULK_DELETE_MAX_AGE, PAGE_SIZE},
+    },
+    utils::{snowflake, SerializableInstant},
+};
+use poise::serenity_prelude::ChannelId;
+use tokio::time::Duration;
+
+/// How many pages of history are sampled for an estimate by default.
+pub const ESTIMATE_PAGES: usize = 10;
+
+/// Purges expected to run at least this long are worth a warning.
+pub const LONG_PURGE: Duration = Duration::from_secs(60 * 60);
+
+/// The expected work of purging a channel.
+#[derive(Clone, Copy, Debug, Default, PartialEq, Eq)]
+pub struct PurgeEstimate {
+    /// Messages fetched for the sample.
+    pub sampled: usize,
+    /// Whether the sample covered the channel's whole history, so the counts
+    /// are exact rather than extrapolated.
+    pub complete: bool,
+    /// Messages expected to be deleted in bulk.
+    pub recent: u64,
+    /// Messages older than 14 days, which are deleted one at a time.
+    pub old: u64,
+    /// The pause the purge makes after deleting each message older than 14
+    /// days.
+    pub old_message_delay: Duration,
+}
+
+impl PurgeEstimate {
+    /// Returns the number of messages expected to be deleted.
+    pub fn messages(&self) -> u64 {
+        self.recent + self.old
+    }
+
+    /// Returns the number of delete requests the purge is expected to make.
+    ///
+    /// # Examples
+    ///
+    /// ```
+    /// use eule::purge::PurgeEstimate;
+    ///
+    /// let estimate = PurgeEstimate { recent: 250, old: 40, ..Default::default() };
+    /// assert_eq!(estimate.requests(), 43);
+    /// ```
+    pub fn requests(&self) -> u64 {
+        let bulk = match self.recent {
+            0 | 1 => self.recent,
+            recent => recent.div_ceil(u64::from(PAGE_SIZE)),
+        };
+        bulk + self.old
+    }
+
+    /// Returns how long the purge is expected to take when paced by the
+    /// options' rate and the estimate's delay between deletes of old messages.
+    ///
+    /// # Parameters
+    /// - `options`: The pacing settings the purge will run with.
+    pub fn duration(&self, options: &PurgeOptions) -> Duration {
+        let per_request = options.rate_window.as_secs_f64() / f64::from(options.rate.max(1));
+        let delays = self.old_message_delay.as_secs_f64() * self.old as f64;
+        Duration::from_secs_f64(self.requests() as f64 * per_request + delays)
+    }
+
+    /// Returns whether the purge is expected to run for at least `LONG_PURGE`.
+    pub fn is_long(&self, options: &PurgeOptions) -> bool {
+        self.duration(options) >= LONG_PURGE
+    }
+}
+
+/// Estimates the work of purging a channel's messages that match the options'
+/// filter. Threads aren't counted.
+///
+/// # Parameters
+/// - `api`: The Discord API client used to fetch messages.
+/// - `channel_id`: The ID of the channel to estimate.
+/// - `options`: The filter and retry settings the purge will run with.
+/// - `max_pages`: The most pages of 100 messages to sample.
+///
+/// # Returns
+/// The estimate, or the first error that could not be retried.
+pub async fn estimate_purge<A: DiscordApi + ?Sized>(
+    api: &A,
+    channel_id: ChannelId,
+    options: &PurgeOptions,
+    max_pages: usize,
+) -> Result<PurgeEstimate, EuleError> {
+    let retries = options.max_rate_limit_retries;
+    let now = SerializableInstant::now();
+    let mut estimate = PurgeEstimate {
+        old_message_delay: options.old_message_delay(api),
+        ..Default::default()
+    };
+    let mut before = None;
+    let mut oldest = None;
+
+    for _ in 0..max_pages {
+        let messages = with_retry(retries, "messages", channel_id, || {
+            api.messages(channel_id, before, PAGE_SIZE)
+        })
+        .await?;
+        let Some(last) = messages.last() else {
+            estimate.complete = true;
+            break;
+        };
+        before = Some(last.id);
+        oldest = Some(last.created_at());
+        estimate.sampled += messages.len();
+        for message in messages.iter().filter(|m| options.filter.matches(m)) {
+            match message.created_at().elapsed() < BULK_DELETE_MAX_AGE {
+                true => estimate.recent += 1,
+                false => estimate.old += 1,
+            }
+        }
+        if messages.len() < PAGE_SIZE as usize {
+            estimate.complete = true;
+            break;
+        }
+    }
+
+    if let (false, Some(oldest)) = (estimate.complete, oldest) {
+        // Messages per second over the sampled stretch of history
+        let sampled_secs = now.unix_secs().saturating_sub(oldest.unix_secs()).max(1);
+        let density = estimate.messages() as f64 / sampled_secs as f64;
+        let created = snowflake::to_instant(channel_id.get()).unix_secs();
+        let cutoff = now
+            .unix_secs()
+            .saturating_sub(BULK_DELETE_MAX_AGE.as_secs());
+        let recent_secs = oldest.unix_secs().saturating_sub(created.max(cutoff));
+        let old_secs = oldest.unix_secs().min(cutoff).saturating_sub(created);
+        estimate.recent += (density * recent_secs as f64) as u64;
+        estimate.old += (density * old_secs as f64) as u64;
+    }
+    Ok(estimate)
+}
@@ -0,0 +1,152 @@
+//! Message selection for purges.
+
+use crate::purge::{api::ChannelMessage, links::BlockedDomains, starboard::StarboardOptions};
+use poise::serenity_prelude::{MessageId, UserId};
+use std::collections::HashSet;
+use tokio::time::Duration;
+
+/// Decides which messages a purge removes.
+///
+/// The default filter matches every message.
+///
+/// # Examples
+///
+/// ```
+/// use eule::purge::MessageFilter;
+/// use tokio::time::Duration;
+///
+/// let filter = MessageFilter::new()
+///     .keep_pinned()
+///     .older_than(Duration::from_secs(3600));
+/// ```
+#[derive(Clone, Debug, Default)]
+pub struct MessageFilter {
+    keep_pinned: bool,
+    authors: Option<HashSet<UserId>>,
+    skip_authors: HashSet<UserId>,
+    min_age: Option<Duration>,
+    keep: HashSet<MessageId>,
+    keywords: Vec<String>,
+    starred: Option<StarboardOptions>,
+    blocked: Option<BlockedDomains>,
+}
+
+impl MessageFilter {
+    /// Creates a filter that matches every message.
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Leaves pinned messages in place.
+    pub fn keep_pinned(mut self) -> Self {
+        self.keep_pinned = true;
+        self
+    }
+
+    /// Only matches messages written by one of the given users.
+    pub fn from_authors(mut self, authors: impl IntoIterator<Item = UserId>) -> Self {
+        self.authors = Some(authors.into_iter().collect());
+        self
+    }
+
+    /// Leaves messages written by any of the given users in place.
+    pub fn skip_authors(mut self, authors: impl IntoIterator<Item = UserId>) -> Self {
+        self.skip_authors.extend(authors);
+        self
+    }
+
+    /// Only matches messages at least `age` old.
+    pub fn older_than(mut self, age: Duration) -> Self {
+        self.min_age = Some(age);
+        self
+    }
+
+    /// Leaves the given messages in place, such as a progress message posted
+    /// in the channel being purged.
+    pub fn keep_messages(mut self, message_ids: impl IntoIterator<Item = MessageId>) -> Self {
+        self.keep.extend(message_ids);
+        self
+    }
+
+    /// Leaves messages containing any of the given keywords in place, such as
+    /// `#keep`. Keywords match anywhere in a message's text, ignoring case.
+    pub fn keep_keywords(mut self, keywords: impl IntoIterator<Item = impl AsRef<str>>) -> Self {
+        self.keywords.extend(
+            keywords
+                .into_iter()
+                .map(|keyword| keyword.as_ref().to_lowercase())
+                .filter(|keyword| !keyword.is_empty()),
+        );
+        self
+    }
+
+    /// Leaves messages with enough star reactions in place.
+    ///
+    /// Only the options' emoji and threshold are used; messages linked from the
+    /// starboard channel are kept with `keep_messages`.
+    pub fn keep_starred(mut self, starboard: StarboardOptions) -> Self {
+        self.starred = Some(starboard);
+        self
+    }
+
+    /// Deletes messages linking to any of the blocked domains, even those the
+    /// filter would otherwise leave in place. Messages given to
+    /// `keep_messages` are still kept.
+    pub fn delete_blocked_links(mut self, blocked: BlockedDomains) -> Self {
+        self.blocked = Some(blocked);
+        self
+    }
+
+    /// Returns whether the message is deleted for linking to a blocked domain.
+    pub fn links_blocked(&self, message: &ChannelMessage) -> bool {
+        !self.keep.contains(&message.id)
+            && self
+                .blocked
+                .as_ref()
+                .is_some_and(|blocked| blocked.blocked_domain(&message.content).is_some())
+    }
+
+    /// Returns whether the message should be deleted.
+    pub fn matches(&self, message: &ChannelMessage) -> bool {
+        if self.keep.contains(&message.id) {
+            return false;
+        }
+        if self.links_blocked(message) {
+            return true;
+        }
+        if self.keep_pinned && message.pinned {
+            return false;
+        }
+        if self
+            .starred
+            .as_ref()
+            .is_some_and(|starboard| starboard.is_starred(message))
+        {
+            return false;
+        }
+        if !self.keywords.is_empty() {
+            let content = message.content.to_lowercase();
+            if self
+                .keywords
+                .iter()
+                .any(|keyword| content.contains(keyword.as_str()))
+            {
+                return false;
+            }
+        }
+        if self.skip_authors.contains(&message.author_id) {
+            return false;
+        }
+        if let Some(authors) = &self.authors {
+            if !authors.contains(&message.author_id) {
+                return false;
+            }
+        }
+        if let Some(min_age) = self.min_age {
+            if message.created_at().elapsed() < min_age {
+                return false;
+            }
+        }
+        true
+    }
+}
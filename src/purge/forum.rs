@@ -0,0 +1,143 @@
+//! Cleanup for forum channels.
+//!
+//! Forum channels have no messages of their own; their content lives in posts,
+//! which are threads. Instead of deleting messages, old posts are archived or
+//! deleted as a whole.
+
+use crate::{
+    error::EuleError,
+    purge::{
+        api::{DiscordApi, ThreadInfo},
+        engine::{pace, with_retry},
+    },
+    utils::rate_limiter::RateLimiter,
+};
+use poise::serenity_prelude::{ChannelId, ForumTagId};
+use serde::{Deserialize, Serialize};
+use tokio::time::Duration;
+
+/// What happens to a forum post selected for cleanup.
+#[derive(Clone, Copy, Debug, Default, PartialEq, Eq, Serialize, Deserialize)]
+pub enum ForumAction {
+    /// Archive the post, keeping its messages.
+    #[default]
+    Archive,
+    /// Delete the post and all of its messages.
+    Delete,
+}
+
+/// Selects which forum posts are cleaned up and how.
+#[derive(Clone, Debug, PartialEq, Eq, Serialize, Deserialize)]
+pub struct ForumOptions {
+    /// Only posts created at least this long ago are cleaned up.
+    pub max_age: Duration,
+    /// Whether selected posts are archived or deleted.
+    pub action: ForumAction,
+    /// Only posts with at least one of these tags are cleaned up. Empty means any post.
+    #[serde(default)]
+    pub tags: Vec<ForumTagId>,
+    /// Posts with any of these tags are left alone, even if they have one of `tags`.
+    #[serde(default)]
+    pub skip_tags: Vec<ForumTagId>,
+    /// Only posts without messages for at least this long are cleaned up.
+    #[serde(default)]
+    pub inactive_for: Option<Duration>,
+}
+
+impl ForumOptions {
+    /// Creates options that archive posts older than `max_age`.
+    pub fn new(max_age: Duration) -> Self {
+        Self {
+            max_age,
+            action: ForumAction::Archive,
+            tags: Vec::new(),
+            skip_tags: Vec::new(),
+            inactive_for: None,
+        }
+    }
+
+    /// Returns whether the post should be cleaned up.
+    pub fn matches(&self, post: &ThreadInfo) -> bool {
+        if self.action == ForumAction::Archive && post.archived {
+            return false;
+        }
+        if post.created_at().elapsed() < self.max_age {
+            return false;
+        }
+        if !self.tags.is_empty() && !post.applied_tags.iter().any(|t| self.tags.contains(t)) {
+            return false;
+        }
+        if post.applied_tags.iter().any(|t| self.skip_tags.contains(t)) {
+            return false;
+        }
+        if let Some(inactive_for) = self.inactive_for {
+            if post.last_activity().elapsed() < inactive_for {
+                return false;
+            }
+        }
+        true
+    }
+}
+
+/// The outcome of a forum cleanup.
+#[derive(Clone, Copy, Debug, Default, PartialEq, Eq)]
+pub struct ForumReport {
+    /// Posts examined.
+    pub scanned: usize,
+    /// Posts archived.
+    pub archived: usize,
+    /// Posts deleted.
+    pub deleted: usize,
+}
+
+/// Archives or deletes the posts of a forum channel selected by `options`.
+///
+/// # Parameters
+/// - `api`: The Discord API client.
+/// - `forum_id`: The ID of the forum channel.
+/// - `options`: Which posts to clean up and how.
+///
+/// # Returns
+/// A report of the work done, or the first error that could not be retried.
+pub async fn prune_forum<A: DiscordApi + ?Sized>(
+    api: &A,
+    forum_id: ChannelId,
+    options: &ForumOptions,
+) -> Result<ForumReport, EuleError> {
+    const RETRIES: u32 = 5;
+    let rate_limiter = RateLimiter::new(5, Duration::from_secs(10));
+    let mut report = ForumReport::default();
+
+    for post in with_retry(RETRIES, "threads", forum_id, || api.threads(forum_id)).await? {
+        report.scanned += 1;
+        if !options.matches(&post) {
+            continue;
+        }
+        pace(&rate_limiter).await;
+        match options.action {
+            ForumAction::Archive => {
+                with_retry(RETRIES, "set_archived", post.id, || {
+                    api.set_archived(post.id, true)
+                })
+                .await?;
+                report.archived += 1;
+            }
+            ForumAction::Delete => {
+                with_retry(RETRIES, "delete_thread", post.id, || {
+                    api.delete_thread(post.id)
+                })
+                .await?;
+                report.deleted += 1;
+            }
+        }
+    }
+
+    tracing::debug!(
+        "Forum {:x}: {} posts scanned, {} archived, {} deleted",
+        forum_id.get(),
+        report.scanned,
+        report.archived,
+        report.deleted
+    );
+    Ok(report)
+}
@@ -0,0 +1,41 @@
+//! Which channel types can be purged, and how.
+
+use poise::serenity_prelude::ChannelType;
+
+/// How a channel of a given type is cleaned.
+#[derive(Clone, Copy, Debug, PartialEq, Eq)]
+pub enum ChannelSupport {
+    /// The channel holds messages that are purged directly.
+    Messages {
+        /// Whether the channel can have threads of its own.
+        threads: bool,
+    },
+    /// The channel is a forum whose posts are archived or deleted.
+    Forum,
+    /// The channel holds no messages that can be purged.
+    Unsupported,
+}
+
+impl ChannelSupport {
+    /// Classifies a channel type.
+    ///
+    /// Voice and stage channels have a built-in text chat that is purged like any
+    /// other channel, but they cannot have threads.
+    pub fn of(kind: ChannelType) -> Self {
+        match kind {
+            ChannelType::Text | ChannelType::News => Self::Messages { threads: true },
+            ChannelType::Voice
+            | ChannelType::Stage
+            | ChannelType::PublicThread
+            | ChannelType::PrivateThread
+            | ChannelType::NewsThread => Self::Messages { threads: false },
+            ChannelType::Forum => Self::Forum,
+            _ => Self::Unsupported,
+        }
+    }
+
+    /// Returns `true` if the channel can have threads.
+    pub fn has_threads(self) -> bool {
+        matches!(self, Self::Messages { threads: true } | Self::Forum)
+    }
+}
@@ -0,0 +1,138 @@
+//! Links to blocked domains, such as known phishing sites.
+
+use std::{collections::HashSet, sync::Arc};
+
+/// The characters that end a link's host.
+const HOST_END: &[char] = &[
+    '/', '?', '#', '\\', '<', '>', '(', ')', '[', ']', '"', '\'', '|',
+];
+
+/// Returns the hosts of the `http` and `https` links in a text, lowercased
+/// and without their port, in the order they appear.
+///
+/// # Examples
+///
+/// ```
+/// use eule::purge::link_hosts;
+///
+/// let hosts = link_hosts("See <https://Example.com:8443/a> and http://a.b.org?x=1");
+/// assert_eq!(hosts, vec!["example.com", "a.b.org"]);
+/// ```
+pub fn link_hosts(text: &str) -> Vec<String> {
+    let text = text.to_ascii_lowercase();
+    let mut hosts = Vec::new();
+    let mut rest = text.as_str();
+    while let Some(start) = rest.find("http") {
+        rest = &rest[start + 4..];
+        let Some(after) = rest
+            .strip_prefix("s://")
+            .or_else(|| rest.strip_prefix("://"))
+        else {
+            continue;
+        };
+        let end = after
+            .find(|c: char| c.is_whitespace() || HOST_END.contains(&c))
+            .unwrap_or(after.len());
+        let authority = &after[..end];
+        rest = &after[end..];
+        // Anything before an `@` is a user name, which phishing links use to
+        // pass for another site
+        let host = authority.rsplit('@').next().unwrap_or_default();
+        let host = host.split(':').next().unwrap_or_default();
+        let host = host.trim_end_matches('.');
+        if !host.is_empty() {
+            hosts.push(host.to_string());
+        }
+    }
+    hosts
+}
+
+/// A list of domains whose links are unwanted. Clones share the list.
+///
+/// A domain blocks its subdomains too, so blocking `example.com` also blocks
+/// links to `login.example.com`.
+///
+/// # Examples
+///
+/// ```
+/// use eule::purge::BlockedDomains;
+///
+/// let blocked = BlockedDomains::new(["example.com"]);
+/// assert_eq!(
+///     blocked.blocked_domain("Free nitro: https://login.example.com/gift"),
+///     Some("login.example.com".to_string())
+/// );
+/// assert_eq!(blocked.blocked_domain("https://example.org"), None);
+/// ```
+#[derive(Clone, Debug, Default)]
+pub struct BlockedDomains(Arc<HashSet<String>>);
+
+impl BlockedDomains {
+    /// Creates a list of the given domains.
+    ///
+    /// Domains are lowercased, and a leading scheme, `*.` or trailing path is
+    /// dropped. Entries without a dot, which would block a whole top-level
+    /// domain, are skipped.
+    pub fn new(domains: impl IntoIterator<Item = impl AsRef<str>>) -> Self {
+        let domains = domains
+            .into_iter()
+            .filter_map(|domain| normalize(domain.as_ref()))
+            .collect();
+        Self(Arc::new(domains))
+    }
+
+    /// Returns the number of domains on the list.
+    pub fn len(&self) -> usize {
+        self.0.len()
+    }
+
+    /// Returns whether the list is empty.
+    pub fn is_empty(&self) -> bool {
+        self.0.is_empty()
+    }
+
+    /// Returns the domains on the list, in no particular order.
+    pub fn iter(&self) -> impl Iterator<Item = &str> {
+        self.0.iter().map(String::as_str)
+    }
+
+    /// Returns whether `domain`, or a domain it belongs to, is on the list.
+    pub fn contains(&self, domain: &str) -> bool {
+        let mut domain = domain;
+        loop {
+            if self.0.contains(domain) {
+                return true;
+            }
+            match domain.split_once('.') {
+                Some((_, parent)) if parent.contains('.') => domain = parent,
+                _ => return false,
+            }
+        }
+    }
+
+    /// Returns the host of the first link in a text to a blocked domain, if
+    /// any.
+    pub fn blocked_domain(&self, text: &str) -> Option<String> {
+        if self.is_empty() {
+            return None;
+        }
+        link_hosts(text)
+            .into_iter()
+            .find(|host| self.contains(host))
+    }
+}
+
+/// Brings a domain from a list into the form links are matched in.
+fn normalize(domain: &str) -> Option<String> {
+    let domain = domain.trim().to_ascii_lowercase();
+    let domain = domain
+        .split_once("://")
+        .map_or(domain.as_str(), |(_, rest)| rest);
+    let domain = domain.trim_start_matches("*.");
+    let domain = domain
+        .split(|c: char| c == ':' || HOST_END.contains(&c))
+        .next()
+        .unwrap_or_default()
+        .trim_end_matches('.');
+    domain.contains('.').then(|| domain.to_string())
+}
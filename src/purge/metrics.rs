@@ -0,0 +1,87 @@
+//! Counters of the time purges spend waiting on rate limits.
+//!
+//! A slow purge is either held back by its own pacing, when its rate limiter
+//! or deletion budget runs dry, or by Discord answering 429 Too Many Requests.
+//! Both are counted here for the whole process, and every 429 is logged with
+//! its bucket, wait and channel, so operators can tell the two apart.
+
+use poise::serenity_prelude::ChannelId;
+use serde::Serialize;
+use std::{collections::BTreeMap, sync::Mutex};
+use tokio::time::Duration;
+
+/// How often something waited, and for how long in total.
+#[derive(Clone, Copy, Debug, Default, PartialEq, Eq, Serialize)]
+pub struct WaitStats {
+    /// The number of waits.
+    pub count: u64,
+    /// The total time waited, in milliseconds.
+    pub waited_ms: u64,
+}
+
+impl WaitStats {
+    const ZERO: Self = Self {
+        count: 0,
+        waited_ms: 0,
+    };
+
+    fn add(&mut self, wait: Duration) {
+        self.count += 1;
+        self.waited_ms += wait.as_millis() as u64;
+    }
+}
+
+/// The rate limiting seen since the process started.
+#[derive(Clone, Debug, Default, PartialEq, Eq, Serialize)]
+pub struct RateLimitStats {
+    /// 429 responses from Discord.
+    pub throttled: WaitStats,
+    /// Waits imposed by the purges' own rate limiters and deletion budgets.
+    pub paced: WaitStats,
+    /// 429 responses by bucket, the kind of request that was limited.
+    pub buckets: BTreeMap<String, WaitStats>,
+}
+
+static STATS: Mutex<RateLimitStats> = Mutex::new(RateLimitStats {
+    throttled: WaitStats::ZERO,
+    paced: WaitStats::ZERO,
+    buckets: BTreeMap::new(),
+});
+
+/// Records a 429 response and logs it.
+///
+/// # Parameters
+/// - `bucket`: The kind of request that was limited, such as `delete_message`.
+/// - `channel_id`: The channel the request was for.
+/// - `wait`: How long Discord asked to wait before retrying.
+pub fn record_throttled(bucket: &str, channel_id: ChannelId, wait: Duration) {
+    tracing::warn!(
+        bucket,
+        channel = %format!("{:x}", channel_id.get()),
+        wait_ms = wait.as_millis() as u64,
+        "Rate limited by Discord"
+    );
+    if let Ok(mut stats) = STATS.lock() {
+        stats.throttled.add(wait);
+        stats
+            .buckets
+            .entry(bucket.to_string())
+            .or_default()
+            .add(wait);
+    }
+}
+
+/// Records a wait imposed by a purge's own pacing.
+///
+/// # Parameters
+/// - `wait`: How long the purge waited.
+pub fn record_paced(wait: Duration) {
+    if let Ok(mut stats) = STATS.lock() {
+        stats.paced.add(wait);
+    }
+}
+
+/// Returns the rate limiting seen since the process started.
+pub fn rate_limit_stats() -> RateLimitStats {
+    STATS.lock().map(|stats| stats.clone()).unwrap_or_default()
+}
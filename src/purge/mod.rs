@@ -0,0 +1,64 @@
+//! Reusable channel purging engine.
+//!
+//! This module contains everything needed to empty a Discord channel: paging
+//! through its history, splitting messages into those that can be bulk deleted
+//! and those older than 14 days, filtering, and pacing requests around rate
+//! limits. Threads and forum posts are cleaned up by archiving or deleting
+//! them, and a purge can be estimated or previewed before it runs.
+//!
+//! The module has no dependency on the bot's scheduler or storage, so other
+//! Serenity-based bots can use it directly:
+//!
+//! ```no_run
+//! use eule::purge::{purge_channel, MessageFilter, PurgeOptions};
+//! use poise::serenity_prelude::{ChannelId, Http};
+//!
+//! # async fn example(http: Http) -> Result<(), eule::EuleError> {
+//! let options = PurgeOptions {
+//!     filter: MessageFilter::new().keep_pinned(),
+//!     ..Default::default()
+//! };
+//! let report = purge_channel(&http, ChannelId::new(1234), &options).await?;
+//! println!("deleted {} messages", report.deleted);
+//! # Ok(())
+//! # }
+//! ```
+
+mod api;
+mod budget;
+mod cancel;
+mod engine;
+mod estimate;
+mod filter;
+mod forum;
+mod kind;
+mod links;
+mod metrics;
+mod preview;
+mod retry;
+mod starboard;
+mod threads;
+
+pub(crate) use api::map_http_error;
+pub use api::{ChannelMessage, DiscordApi, ReactionCount, SendPermission, SharedHttp, ThreadInfo};
+pub use budget::DeletionBudget;
+pub use cancel::CancelToken;
+pub(crate) use engine::with_retry;
+pub use engine::{
+    purge_channel, DeletionOrder, MessageRange, PurgeOptions, PurgeReport, BULK_DELETE_MAX_AGE,
+};
+pub use estimate::{estimate_purge, PurgeEstimate, ESTIMATE_PAGES, LONG_PURGE};
+pub use filter::MessageFilter;
+pub use forum::{prune_forum, ForumAction, ForumOptions, ForumReport};
+pub use kind::ChannelSupport;
+pub use links::{link_hosts, BlockedDomains};
+pub use metrics::{rate_limit_stats, RateLimitStats, WaitStats};
+pub use preview::{preview_purge, PurgePreview, PREVIEW_PAGES, PREVIEW_SAMPLES};
+pub use retry::{
+    is_retryable, merge_deferred, retry_deletes, DeferredDeletes, RetryEntry, RetryReport,
+    MAX_RETRY_ATTEMPTS,
+};
+pub use starboard::{
+    message_links, normalize_emoji, starboarded_messages, StarboardOptions, DEFAULT_STAR,
+};
+pub use threads::{prune_threads, ThreadAction, ThreadOptions, ThreadReport};
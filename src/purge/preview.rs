@@ -0,0 +1,112 @@
+//! Previews of what a purge would delete, without deleting anything.
+//!
+//! A preview pages through a channel's history like a purge does and applies
+//! the same filter, range and first-message setting, but only counts the
+//! messages a purge would delete. Threads aren't looked at.
+
+use crate::{
+    error::EuleError,
+    purge::{
+        api::DiscordApi,
+        engine::{with_retry, PurgeOptions, PAGE_SIZE},
+    },
+};
+use poise::serenity_prelude::{ChannelId, MessageId, UserId};
+use std::collections::HashMap;
+
+/// How many pages of history a preview looks at by default.
+pub const PREVIEW_PAGES: usize = 50;
+
+/// How many of the messages a purge would delete are kept as examples.
+pub const PREVIEW_SAMPLES: usize = 5;
+
+/// What a purge of a channel would delete.
+#[derive(Clone, Debug, Default, PartialEq, Eq)]
+pub struct PurgePreview {
+    /// Messages looked at.
+    pub scanned: usize,
+    /// Whether the preview covered the channel's whole history, so `matched`
+    /// is exact rather than a lower bound.
+    pub complete: bool,
+    /// Messages a purge would delete.
+    pub matched: usize,
+    /// The number of members whose messages a purge would delete.
+    pub authors: usize,
+    /// The newest messages a purge would delete, newest first, at most
+    /// `PREVIEW_SAMPLES` of them.
+    pub samples: Vec<MessageId>,
+}
+
+/// Finds the messages a purge with the given options would delete.
+///
+/// # Parameters
+/// - `api`: The Discord API client used to fetch messages.
+/// - `channel_id`: The ID of the channel to preview.
+/// - `options`: The filter and other settings the purge would run with.
+/// - `max_pages`: The most pages of 100 messages to look at.
+///
+/// # Returns
+/// The preview, or the first error that could not be retried.
+pub async fn preview_purge<A: DiscordApi + ?Sized>(
+    api: &A,
+    channel_id: ChannelId,
+    options: &PurgeOptions,
+    max_pages: usize,
+) -> Result<PurgePreview, EuleError> {
+    let retries = options.max_rate_limit_retries;
+    let mut preview = PurgePreview::default();
+    let mut authors: HashMap<UserId, usize> = HashMap::new();
+    let mut before = options
+        .range
+        .map(|range| MessageId::new(range.last.get().saturating_add(1)));
+    // The oldest message in range so far and its author, if a purge would
+    // delete it, since a purge keeping the first message spares it
+    let mut oldest = None;
+
+    for _ in 0..max_pages {
+        if options.cancel.is_cancelled() {
+            break;
+        }
+        let mut messages = with_retry(retries, "messages", channel_id, || {
+            api.messages(channel_id, before, PAGE_SIZE)
+        })
+        .await?;
+        let Some(last) = messages.last() else {
+            preview.complete = true;
+            break;
+        };
+        before = Some(last.id);
+        preview.scanned += messages.len();
+        let mut exhausted = messages.len() < PAGE_SIZE as usize;
+        if let Some(range) = options.range {
+            exhausted |= last.id < range.first;
+            messages.retain(|message| range.contains(message.id));
+        }
+        if let Some(last) = messages.last() {
+            oldest = Some((last.id, last.author_id)).filter(|_| options.filter.matches(last));
+        }
+        for message in messages.iter().filter(|m| options.filter.matches(m)) {
+            preview.matched += 1;
+            *authors.entry(message.author_id).or_default() += 1;
+            if preview.samples.len() < PREVIEW_SAMPLES {
+                preview.samples.push(message.id);
+            }
+        }
+        if exhausted {
+            preview.complete = true;
+            break;
+        }
+    }
+
+    if let (true, true, Some((first, author))) =
+        (options.keep_first_message, preview.complete, oldest)
+    {
+        preview.matched -= 1;
+        preview.samples.retain(|sample| *sample != first);
+        if let Some(count) = authors.get_mut(&author) {
+            *count -= 1;
+        }
+    }
+    preview.authors = authors.values().filter(|count| **count > 0).count();
+    Ok(preview)
+}
@@ -0,0 +1,158 @@
+//! Deletes that failed for a passing reason, kept to be tried again.
+//!
+//! A purge given a `DeferredDeletes` doesn't fail when Discord keeps rate
+//! limiting a delete, answers it with a server error or can't be reached. It
+//! sets the messages aside and carries on, and the caller can try them again
+//! later with `retry_deletes`, such as on a task's next cleanup. Messages that
+//! still can't be deleted after `MAX_RETRY_ATTEMPTS` tries are given up on.
+
+use crate::{
+    error::EuleError,
+    purge::{
+        api::DiscordApi,
+        engine::{pace, with_retry, PurgeOptions},
+    },
+    utils::rate_limiter::RateLimiter,
+};
+use poise::serenity_prelude::{self as serenity, ChannelId, MessageId};
+use serde::{Deserialize, Serialize};
+use std::{
+    collections::HashSet,
+    sync::{Arc, Mutex},
+};
+
+/// How many times a set-aside message is tried again before it is given up on.
+pub const MAX_RETRY_ATTEMPTS: u32 = 5;
+
+/// A message whose delete failed for a passing reason.
+#[derive(Serialize, Deserialize, Clone, Copy, Debug, PartialEq, Eq)]
+pub struct RetryEntry {
+    /// The channel or thread the message is in.
+    pub channel_id: ChannelId,
+    /// The message to delete.
+    pub message_id: MessageId,
+    /// How many times deleting it was tried again.
+    #[serde(default)]
+    pub attempts: u32,
+}
+
+/// Collects the messages a purge set aside. Clones share the collection.
+#[derive(Clone, Debug, Default)]
+pub struct DeferredDeletes(Arc<Mutex<Vec<RetryEntry>>>);
+
+impl DeferredDeletes {
+    /// Sets messages aside to be tried again.
+    pub(crate) fn defer(&self, channel_id: ChannelId, message_ids: &[MessageId]) {
+        if let Ok(mut entries) = self.0.lock() {
+            entries.extend(message_ids.iter().map(|&message_id| RetryEntry {
+                channel_id,
+                message_id,
+                attempts: 0,
+            }));
+        }
+    }
+
+    /// Returns the messages set aside so far, leaving the collection empty.
+    pub fn take(&self) -> Vec<RetryEntry> {
+        self.0
+            .lock()
+            .map(|mut entries| std::mem::take(&mut *entries))
+            .unwrap_or_default()
+    }
+}
+
+/// Returns whether a failed request is worth trying again later: it was rate
+/// limited, Discord answered with a server error, or it never got an answer.
+pub fn is_retryable(error: &EuleError) -> bool {
+    match error {
+        EuleError::RateLimited(_) | EuleError::TimedOut(_) => true,
+        EuleError::DiscordApi(serenity::Error::Http(e)) => e
+            .status_code()
+            .map_or(true, |status| status.is_server_error()),
+        _ => false,
+    }
+}
+
+/// Adds newly set-aside messages to those still to be retried.
+///
+/// A message still to be retried is found and set aside again by the next
+/// purge of its channel, so messages already queued keep their entry and its
+/// count of attempts.
+///
+/// # Parameters
+/// - `queue`: The messages still to be retried.
+/// - `deferred`: The messages a purge just set aside.
+pub fn merge_deferred(mut queue: Vec<RetryEntry>, deferred: Vec<RetryEntry>) -> Vec<RetryEntry> {
+    let queued: HashSet<MessageId> = queue.iter().map(|entry| entry.message_id).collect();
+    queue.extend(
+        deferred
+            .into_iter()
+            .filter(|entry| !queued.contains(&entry.message_id)),
+    );
+    queue
+}
+
+/// The outcome of trying set-aside messages again.
+#[derive(Clone, Debug, Default, PartialEq, Eq)]
+pub struct RetryReport {
+    /// Messages deleted.
+    pub deleted: usize,
+    /// Messages that were gone already.
+    pub gone: usize,
+    /// Messages given up on after `MAX_RETRY_ATTEMPTS` tries.
+    pub given_up: usize,
+    /// Messages to try again another time, including those not tried because
+    /// the purge was cancelled.
+    pub remaining: Vec<RetryEntry>,
+}
+
+/// Tries set-aside messages again, one delete request each.
+///
+/// # Parameters
+/// - `api`: The Discord API client used to delete messages.
+/// - `entries`: The messages to try again.
+/// - `options`: The pacing and cancellation settings to delete with.
+///
+/// # Returns
+/// What became of the messages, or the first error that isn't worth trying
+/// again, such as missing permissions.
+pub async fn retry_deletes<A: DiscordApi + ?Sized>(
+    api: &A,
+    entries: Vec<RetryEntry>,
+    options: &PurgeOptions,
+) -> Result<RetryReport, EuleError> {
+    let retries = options.max_rate_limit_retries;
+    let rate_limiter = RateLimiter::new(options.rate, options.rate_window);
+    let mut report = RetryReport::default();
+    let mut entries = entries.into_iter();
+
+    for mut entry in entries.by_ref() {
+        if options.cancel.is_cancelled() {
+            report.remaining.push(entry);
+            break;
+        }
+        pace(&rate_limiter).await;
+        let result = with_retry(retries, "delete_message", entry.channel_id, || {
+            api.delete_message(entry.channel_id, entry.message_id)
+        })
+        .await;
+        match result {
+            Ok(()) => report.deleted += 1,
+            Err(EuleError::DiscordApi(serenity::Error::Http(e)))
+                if e.status_code().is_some_and(|status| status.as_u16() == 404) =>
+            {
+                report.gone += 1
+            }
+            Err(e) if is_retryable(&e) => {
+                entry.attempts += 1;
+                match entry.attempts >= MAX_RETRY_ATTEMPTS {
+                    true => report.given_up += 1,
+                    false => report.remaining.push(entry),
+                }
+            }
+            Err(e) => return Err(e),
+        }
+    }
+    report.remaining.extend(entries);
+    Ok(report)
+}
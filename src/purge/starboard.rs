@@ -0,0 +1,126 @@
+//! Protection for messages the community has starred.
+//!
+//! A message counts as starboarded if it has enough reactions with the star
+//! emoji, or if a post in the guild's starboard channel links to it. Starboard
+//! bots repost highlights with a jump link to the original, so the originals
+//! are found by reading the links in the starboard channel's recent history.
+
+use crate::{
+    error::EuleError,
+    purge::{
+        api::{ChannelMessage, DiscordApi},
+        engine::with_retry,
+    },
+};
+use poise::serenity_prelude::{ChannelId, MessageId};
+use serde::{Deserialize, Serialize};
+use std::collections::HashSet;
+
+/// The emoji starboards count by default.
+pub const DEFAULT_STAR: &str = "⭐";
+/// Pages of the starboard channel read before each cleanup, newest first.
+const SCAN_PAGES: usize = 10;
+/// Retries of a rate-limited request before giving up.
+const RETRIES: u32 = 5;
+
+/// Selects the starboarded messages a cleanup leaves in place.
+#[derive(Clone, Debug, PartialEq, Eq, Serialize, Deserialize)]
+pub struct StarboardOptions {
+    /// The reaction that stars a message, as a unicode emoji or `<:name:id>`.
+    pub emoji: String,
+    /// Messages with at least this many `emoji` reactions are kept. `None`
+    /// ignores reactions.
+    pub threshold: Option<u64>,
+    /// Messages linked from this channel are kept.
+    #[serde(default)]
+    pub channel: Option<ChannelId>,
+}
+
+impl Default for StarboardOptions {
+    fn default() -> Self {
+        Self {
+            emoji: DEFAULT_STAR.to_string(),
+            threshold: None,
+            channel: None,
+        }
+    }
+}
+
+impl StarboardOptions {
+    /// Returns whether the message has enough star reactions to be kept.
+    pub fn is_starred(&self, message: &ChannelMessage) -> bool {
+        let Some(threshold) = self.threshold else {
+            return false;
+        };
+        let emoji = normalize_emoji(&self.emoji);
+        message
+            .reactions
+            .iter()
+            .any(|reaction| reaction.emoji == emoji && reaction.count >= threshold)
+    }
+}
+
+/// Brings an emoji into the form reactions are reported in.
+///
+/// Animated custom emoji are written `<a:name:id>` when typed, but their
+/// animation doesn't matter for matching.
+pub fn normalize_emoji(emoji: &str) -> String {
+    let emoji = emoji.trim();
+    match emoji.strip_prefix("<a:") {
+        Some(rest) => format!("<:{}", rest),
+        None => emoji.to_string(),
+    }
+}
+
+/// Returns the IDs of the messages that jump links in `text` point to.
+///
+/// # Examples
+///
+/// ```
+/// use eule::purge::message_links;
+/// use poise::serenity_prelude::MessageId;
+///
+/// let text = "[Jump!](https://discord.com/channels/1/2/3) and https://ptb.discord.com/channels/1/2/4";
+/// assert_eq!(message_links(text), vec![MessageId::new(3), MessageId::new(4)]);
+/// ```
+pub fn message_links(text: &str) -> Vec<MessageId> {
+    text.match_indices("/channels/")
+        .filter_map(|(start, marker)| {
+            let mut ids = text[start + marker.len()..].split('/').take(3).map(|part| {
+                let digits: String = part.chars().take_while(char::is_ascii_digit).collect();
+                digits.parse::<u64>().ok().filter(|id| *id != 0)
+            });
+            // Guild IDs are `@me` in links to direct messages, which never need protecting
+            let (_guild, _channel, message) = (ids.next()??, ids.next()??, ids.next()??);
+            Some(MessageId::new(message))
+        })
+        .collect()
+}
+
+/// Collects the messages linked from a starboard channel's recent posts.
+///
+/// # Parameters
+/// - `api`: The Discord API client used to read the starboard.
+/// - `channel_id`: The starboard channel.
+pub async fn starboarded_messages<A: DiscordApi + ?Sized>(
+    api: &A,
+    channel_id: ChannelId,
+) -> Result<HashSet<MessageId>, EuleError> {
+    let mut linked = HashSet::new();
+    let mut before = None;
+    for _ in 0..SCAN_PAGES {
+        let page = with_retry(RETRIES, "messages", channel_id, || {
+            api.messages(channel_id, before, 100)
+        })
+        .await?;
+        let Some(oldest) = page.last() else {
+            break;
+        };
+        before = Some(oldest.id);
+        linked.extend(page.iter().flat_map(|post| post.links.iter().copied()));
+        if page.len() < 100 {
+            break;
+        }
+    }
+    Ok(linked)
+}
@@ -0,0 +1,127 @@
+//! Cleanup of stale threads.
+//!
+//! Busy help channels collect threads that nobody answers anymore. Instead of
+//! purging messages, a thread cleanup archives threads once they go quiet and
+//! can delete archived threads once they have been quiet for longer still.
+
+use crate::{
+    error::EuleError,
+    purge::{
+        api::{DiscordApi, ThreadInfo},
+        engine::{pace, with_retry},
+    },
+    utils::rate_limiter::RateLimiter,
+};
+use poise::serenity_prelude::ChannelId;
+use serde::{Deserialize, Serialize};
+use tokio::time::Duration;
+
+/// Selects which of a channel's threads are archived or deleted.
+#[derive(Clone, Debug, PartialEq, Eq, Serialize, Deserialize)]
+pub struct ThreadOptions {
+    /// Open threads without messages for at least this long are archived.
+    pub archive_after: Duration,
+    /// Archived threads without messages for at least this long are deleted.
+    /// `None` never deletes threads.
+    #[serde(default)]
+    pub delete_after: Option<Duration>,
+}
+
+/// What a thread cleanup does with one thread.
+#[derive(Clone, Copy, Debug, PartialEq, Eq)]
+pub enum ThreadAction {
+    /// Archive the thread, keeping its messages.
+    Archive,
+    /// Delete the thread and all of its messages.
+    Delete,
+}
+
+impl ThreadOptions {
+    /// Creates options that archive threads inactive for `archive_after`.
+    pub fn new(archive_after: Duration) -> Self {
+        Self {
+            archive_after,
+            delete_after: None,
+        }
+    }
+
+    /// Returns what to do with a thread, or `None` to leave it alone.
+    ///
+    /// An open thread that is due for both is deleted right away rather than
+    /// archived first.
+    pub fn action(&self, thread: &ThreadInfo) -> Option<ThreadAction> {
+        let inactive = thread.last_activity().elapsed();
+        let archived = thread.archived || inactive >= self.archive_after;
+        if !archived {
+            return None;
+        }
+        match self.delete_after {
+            Some(delete_after) if inactive >= delete_after => Some(ThreadAction::Delete),
+            _ if thread.archived => None,
+            _ => Some(ThreadAction::Archive),
+        }
+    }
+}
+
+/// The outcome of a thread cleanup.
+#[derive(Clone, Copy, Debug, Default, PartialEq, Eq)]
+pub struct ThreadReport {
+    /// Threads examined.
+    pub scanned: usize,
+    /// Threads archived.
+    pub archived: usize,
+    /// Threads deleted.
+    pub deleted: usize,
+}
+
+/// Archives or deletes the threads of a channel selected by `options`.
+///
+/// # Parameters
+/// - `api`: The Discord API client.
+/// - `channel_id`: The ID of the channel whose threads to clean up.
+/// - `options`: Which threads to archive and delete.
+///
+/// # Returns
+/// A report of the work done, or the first error that could not be retried.
+pub async fn prune_threads<A: DiscordApi + ?Sized>(
+    api: &A,
+    channel_id: ChannelId,
+    options: &ThreadOptions,
+) -> Result<ThreadReport, EuleError> {
+    const RETRIES: u32 = 5;
+    let rate_limiter = RateLimiter::new(5, Duration::from_secs(10));
+    let mut report = ThreadReport::default();
+
+    for thread in with_retry(RETRIES, "threads", channel_id, || api.threads(channel_id)).await? {
+        report.scanned += 1;
+        let Some(action) = options.action(&thread) else {
+            continue;
+        };
+        pace(&rate_limiter).await;
+        match action {
+            ThreadAction::Archive => {
+                with_retry(RETRIES, "set_archived", thread.id, || {
+                    api.set_archived(thread.id, true)
+                })
+                .await?;
+                report.archived += 1;
+            }
+            ThreadAction::Delete => {
+                with_retry(RETRIES, "delete_thread", thread.id, || {
+                    api.delete_thread(thread.id)
+                })
+                .await?;
+                report.deleted += 1;
+            }
+        }
+    }
+
+    tracing::debug!(
+        "Channel {:x}: {} threads scanned, {} archived, {} deleted",
+        channel_id.get(),
+        report.scanned,
+        report.archived,
+        report.deleted
+    );
+    Ok(report)
+}
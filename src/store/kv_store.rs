@@ -302,10 +302,11 @@ impl KvStore {
     /// * `encryption_key`
     /// * `api_key`
     /// * `auth_token`
+    /// * `calendar_key`
     fn is_sensitive_key(key: &str) -> bool {
         matches!(
             key,
-            "discord_token" | "encryption_key" | "api_key" | "auth_token"
+            "discord_token" | "encryption_key" | "api_key" | "auth_token" | "calendar_key"
         )
     }
 }
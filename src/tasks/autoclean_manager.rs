@@ -3,15 +3,54 @@
 //!
 use crate::{
     error::EuleError,
+    lifecycle, phishing,
+    purge::{
+        merge_deferred, prune_forum, prune_threads, purge_channel, retry_deletes,
+        starboarded_messages, CancelToken, ChannelSupport, DeferredDeletes, DeletionBudget,
+        DeletionOrder, DiscordApi, ForumOptions, PurgeOptions, PurgeReport, StarboardOptions,
+        ThreadOptions,
+    },
     store::KvStore,
-    tasks::{cleanup_task::CleanupTask, worker_pool::WorkerPool},
-    utils::{rate_limiter::RateLimiter, serializable_instant::SerializableInstant},
+    tasks::{
+        automod::AutomodRule,
+        bot_api::BotApi,
+        cleanup_task::{
+            CleanupTask, FailureKind, PurgeWarning, RunRecord, TaskState, MAX_CONSECUTIVE_FAILURES,
+            MAX_RETRY_QUEUE,
+        },
+        dry_run,
+        events::{
+            PurgeEvent, PurgeEventKind, PurgeEvents, TaskChange, TaskChangeKind, TaskChanges,
+        },
+        expiry, failures,
+        guild_settings::{GuildSettings, HoldRecord, LegalHold, MAX_HOLD_LOG},
+        message_trigger::MessageCounts,
+        nuke::nuke_channel,
+        old_messages, permissions,
+        policy::{matches_pattern, PatternOutcome, Policy},
+        spam_burst::{Burst, BurstRule, SpamBursts},
+        warning,
+        worker_pool::WorkerPool,
+    },
+    utils::{
+        clock::{Clock, SystemClock},
+        serializable_instant::SerializableInstant,
+    },
 };
 use miette::Result;
-use poise::serenity_prelude::{ChannelId, GuildId, Http};
-use std::{collections::HashMap, sync::Arc};
+use poise::serenity_prelude::{
+    ChannelId, ChannelType, GuildId, MessageId, Permissions, RuleId, ScheduledEventId, UserId,
+};
+use std::{
+    collections::{BTreeSet, HashMap, HashSet},
+    sync::{
+        atomic::{AtomicBool, Ordering},
+        Arc,
+    },
+    time::Instant,
+};
 use tokio::{
-    sync::{Mutex, RwLock},
+    sync::{watch, Mutex, Notify, RwLock},
     time::Duration,
 };
 
@@ -29,8 +68,68 @@ pub struct AutocleanManager {
     kv_store: Arc<KvStore>,
     /// Mutex for ensuring thread-safe task saving.
     save_lock: Arc<Mutex<()>>,
+    /// Source of the current time for scheduling decisions.
+    clock: Arc<dyn Clock>,
+    /// Wakes the scheduler loop before its next regular tick.
+    wake: Arc<Notify>,
+    /// Cancellation handles of purges started from commands, by channel.
+    interactive: Arc<Mutex<HashMap<ChannelId, CancelToken>>>,
+    /// Published to as cleanups start and finish.
+    events: PurgeEvents,
+    /// Published to as tasks are added, changed and removed.
+    changes: TaskChanges,
+    /// The store key the task map is saved under.
+    tasks_key: String,
+    /// Settings of guilds that changed any, by guild.
+    settings: Arc<RwLock<HashMap<GuildId, GuildSettings>>>,
+    /// The store key guild settings are saved under.
+    settings_key: String,
+    /// One-shot purges waiting for their time, by guild and channel.
+    one_shots: Arc<RwLock<HashMap<GuildId, HashMap<ChannelId, SerializableInstant>>>>,
+    /// The store key one-shot purges are saved under.
+    one_shots_key: String,
+    /// Deletion budgets of guilds that cap their deletions, by guild.
+    budgets: DeletionBudgets,
+    /// Set once the bot is shutting down, so no new cleanups are queued.
+    draining: Arc<AtomicBool>,
+    /// Messages posted in channels with a message trigger since their last cleanup.
+    message_counts: MessageCounts,
+    /// Recent messages of members in guilds with a spam burst rule.
+    spam_bursts: SpamBursts,
+}
+
+/// Deletion budgets shared by all purges in a guild, by guild.
+pub type DeletionBudgets = Arc<RwLock<HashMap<GuildId, Arc<DeletionBudget>>>>;
+
+/// The window a guild's deletion budget is counted over.
+pub const BUDGET_WINDOW: Duration = Duration::from_secs(60 * 60);
+
+/// What came of moving a task to another channel.
+#[derive(Clone, Copy, Debug, PartialEq, Eq)]
+pub enum TaskMove {
+    /// The task belongs to the new channel now.
+    Moved,
+    /// The old channel has no task to move.
+    NoTask,
+    /// The new channel already has a task of its own.
+    Occupied,
+    /// A cleanup of the old channel is running and has to finish first.
+    Running,
 }
 
+/// How often a draining manager checks whether its purges have finished.
+const DRAIN_POLL: Duration = Duration::from_secs(1);
+
+/// How long purges still running when a drain times out get to stop.
+const DRAIN_GRACE: Duration = Duration::from_secs(30);
+
+/// The store key tasks are saved under by default.
+const TASKS_KEY: &str = "cleanup_tasks";
+/// The store key guild settings are saved under by default.
+const SETTINGS_KEY: &str = "guild_settings";
+/// The store key one-shot purges are saved under by default.
+const ONE_SHOTS_KEY: &str = "one_shot_purges";
+
 /// Obfuscates an ID for logging purposes.
 ///
 /// # Parameters
@@ -51,164 +150,2563 @@ impl Default for AutocleanManager {
                 KvStore::new("eule_data/blobs/db").expect("Failed to create KvStore"),
             ),
             save_lock: Arc::new(Mutex::new(())),
+            clock: Arc::new(SystemClock),
+            wake: Arc::new(Notify::new()),
+            interactive: Arc::new(Mutex::new(HashMap::new())),
+            events: PurgeEvents::new(),
+            changes: TaskChanges::new(),
+            tasks_key: TASKS_KEY.to_string(),
+            settings: Default::default(),
+            settings_key: SETTINGS_KEY.to_string(),
+            one_shots: Default::default(),
+            one_shots_key: ONE_SHOTS_KEY.to_string(),
+            budgets: Default::default(),
+            draining: Default::default(),
+            message_counts: Default::default(),
+            spam_bursts: Default::default(),
+        }
+    }
+}
+
+impl AutocleanManager {
+    pub fn new(kv_store: Arc<KvStore>) -> Self {
+        Self::with_clock(kv_store, Arc::new(SystemClock))
+    }
+
+    /// Creates a new AutocleanManager that reads time from the given clock.
+    ///
+    /// # Parameters
+    /// - `kv_store`: The key-value store used to persist tasks.
+    /// - `clock`: The clock used for all scheduling decisions.
+    pub fn with_clock(kv_store: Arc<KvStore>, clock: Arc<dyn Clock>) -> Self {
+        Self {
+            tasks: Arc::new(RwLock::new(HashMap::new())),
+            worker_pool: None,
+            kv_store,
+            save_lock: Arc::new(Mutex::new(())),
+            clock,
+            wake: Arc::new(Notify::new()),
+            interactive: Arc::new(Mutex::new(HashMap::new())),
+            events: PurgeEvents::new(),
+            changes: TaskChanges::new(),
+            tasks_key: TASKS_KEY.to_string(),
+            settings: Default::default(),
+            settings_key: SETTINGS_KEY.to_string(),
+            one_shots: Default::default(),
+            one_shots_key: ONE_SHOTS_KEY.to_string(),
+            budgets: Default::default(),
+            draining: Default::default(),
+            message_counts: Default::default(),
+            spam_bursts: Default::default(),
+        }
+    }
+
+    /// Keeps this manager's tasks apart from those of other managers sharing the store.
+    ///
+    /// # Parameters
+    /// - `namespace`: A name unique among the managers sharing the store.
+    pub fn with_namespace(mut self, namespace: &str) -> Self {
+        self.tasks_key = format!("{}:{}", TASKS_KEY, namespace);
+        self.settings_key = format!("{}:{}", SETTINGS_KEY, namespace);
+        self.one_shots_key = format!("{}:{}", ONE_SHOTS_KEY, namespace);
+        self
+    }
+
+    /// Adds a new cleanup task for a specific channel in a guild.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild where the task should be added.
+    /// - `channel_id`: The ID of the channel to be cleaned.
+    /// - `interval`: The time interval between cleanups.
+    ///
+    /// The task starts with the guild's task defaults. This method is safe to
+    /// call from multiple threads as it uses a RwLock.
+    pub async fn add_task(
+        &self,
+        guild_id: GuildId,
+        channel_id: ChannelId,
+        interval: Duration,
+    ) -> Result<()> {
+        let mut task = CleanupTask::starting_at(interval, self.clock.now());
+        self.guild_settings(guild_id)
+            .await
+            .task_defaults
+            .apply(&mut task);
+        let change = self.change(TaskChangeKind::Added, guild_id, channel_id, Some(&task));
+        {
+            let mut tasks = self.tasks.write().await;
+            tasks
+                .entry(guild_id)
+                .or_insert_with(HashMap::new)
+                .insert(channel_id, task);
+        }
+        self.save_tasks().await?;
+        self.changes.publish(change);
+        let obfuscated_guild = obfuscate_id(guild_id.get());
+        let obfuscated_channel = obfuscate_id(channel_id.get());
+        tracing::info!(
+            "Added cleanup task for guild {} channel {} with interval {:?}",
+            obfuscated_guild,
+            obfuscated_channel,
+            interval
+        );
+        Ok(())
+    }
+
+    /// Gives channels the settings of their policies, creating tasks for
+    /// channels that have none.
+    ///
+    /// All changes are saved together, so either every policy is applied or,
+    /// if saving fails, the error is returned.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild containing the channels.
+    /// - `policies`: Each channel and the policy to apply to it.
+    ///
+    /// # Returns
+    /// The number of tasks created and the number of tasks updated.
+    pub async fn apply_policies(
+        &self,
+        guild_id: GuildId,
+        policies: &[(ChannelId, Policy)],
+    ) -> Result<(usize, usize)> {
+        let now = self.clock.now();
+        let changes: Vec<TaskChange> = {
+            let mut tasks = self.tasks.write().await;
+            let guild_tasks = tasks.entry(guild_id).or_insert_with(HashMap::new);
+            // Every task is checked before any changes, so none does if one can't
+            let updated = policies
+                .iter()
+                .map(|(channel_id, policy)| {
+                    let kind = match guild_tasks.get(channel_id) {
+                        Some(_) => TaskChangeKind::Updated,
+                        None => TaskChangeKind::Added,
+                    };
+                    let mut task = guild_tasks
+                        .get(channel_id)
+                        .cloned()
+                        .unwrap_or_else(|| CleanupTask::starting_at(policy.interval, now));
+                    policy.apply(&mut task);
+                    task.policy = None;
+                    task.check_settings().map(|_| (kind, *channel_id, task))
+                })
+                .collect::<std::result::Result<Vec<_>, EuleError>>()?;
+            updated
+                .into_iter()
+                .map(|(kind, channel_id, task)| {
+                    let change = self.change(kind, guild_id, channel_id, Some(&task));
+                    guild_tasks.insert(channel_id, task);
+                    change
+                })
+                .collect()
+        };
+        self.save_tasks().await?;
+        let created = changes
+            .iter()
+            .filter(|change| change.kind == TaskChangeKind::Added)
+            .count();
+        let updated = changes.len() - created;
+        for change in changes {
+            self.changes.publish(change);
+        }
+        tracing::info!(
+            "Applied policies to guild {}: {} tasks created, {} updated",
+            obfuscate_id(guild_id.get()),
+            created,
+            updated
+        );
+        Ok((created, updated))
+    }
+
+    /// Creates or replaces a guild's named policy and gives its settings to
+    /// every task that follows it.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild that owns the policy.
+    /// - `name`: The policy's name.
+    /// - `policy`: The policy's settings.
+    ///
+    /// # Returns
+    /// The number of tasks that follow the policy.
+    pub async fn set_policy(&self, guild_id: GuildId, name: &str, policy: Policy) -> Result<usize> {
+        // Neither is changed unless every task can follow the policy
+        policy.check()?;
+        let count = self
+            .update_policy_tasks(guild_id, name, |task| policy.apply(task))
+            .await?;
+        self.update_guild_settings(guild_id, |settings| {
+            settings.policies.insert(name.to_string(), policy.clone());
+        })
+        .await?;
+        Ok(count)
+    }
+
+    /// Removes a guild's named policy. Tasks that followed it keep their
+    /// current settings.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild that owns the policy.
+    /// - `name`: The policy's name.
+    ///
+    /// # Returns
+    /// `true` if the policy existed, `false` otherwise.
+    pub async fn remove_policy(&self, guild_id: GuildId, name: &str) -> Result<bool> {
+        let mut removed = false;
+        self.update_guild_settings(guild_id, |settings| {
+            removed = settings.policies.remove(name).is_some();
+        })
+        .await?;
+        if removed {
+            self.update_policy_tasks(guild_id, name, |task| task.policy = None)
+                .await?;
+        }
+        Ok(removed)
+    }
+
+    /// Makes a channel follow a guild's named policy, creating its task if it
+    /// has none.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild containing the channel.
+    /// - `channel_id`: The ID of the channel.
+    /// - `name`: The policy's name.
+    ///
+    /// # Returns
+    /// `true` if the policy exists and was applied, `false` otherwise.
+    pub async fn apply_named_policy(
+        &self,
+        guild_id: GuildId,
+        channel_id: ChannelId,
+        name: &str,
+    ) -> Result<bool> {
+        self.follow_policy(guild_id, channel_id, name, |task| task.auto = false)
+            .await
+    }
+
+    /// Makes every task with a label follow a guild's named policy.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild containing the tasks.
+    /// - `label`: The label the tasks have.
+    /// - `name`: The policy's name.
+    /// - `threaded`: The channels that can have threads. The others' tasks
+    ///   don't clean threads, whatever the policy says.
+    ///
+    /// # Returns
+    /// The channels that now follow the policy, or `None` if the policy
+    /// doesn't exist.
+    pub async fn apply_named_policy_to_label(
+        &self,
+        guild_id: GuildId,
+        label: &str,
+        name: &str,
+        threaded: &HashSet<ChannelId>,
+    ) -> Result<Option<Vec<ChannelId>>> {
+        if !self
+            .guild_settings(guild_id)
+            .await
+            .policies
+            .contains_key(name)
+        {
+            return Ok(None);
+        }
+        let channels: Vec<ChannelId> = self
+            .guild_tasks(guild_id)
+            .await
+            .into_iter()
+            .filter(|(_, task)| task.has_label(Some(label)))
+            .map(|(channel_id, _)| channel_id)
+            .collect();
+        for channel_id in &channels {
+            let threads = threaded.contains(channel_id);
+            self.follow_policy(guild_id, *channel_id, name, |task| {
+                task.auto = false;
+                task.include_threads &= threads;
+            })
+            .await?;
+        }
+        Ok(Some(channels))
+    }
+
+    /// Schedules or unschedules a channel by the guild's name patterns.
+    ///
+    /// A channel whose name matches a pattern follows the pattern's policy,
+    /// getting a task if it has none. Tasks created this way are removed again
+    /// once the name stops matching. Tasks set up by hand are left alone, and
+    /// patterns whose policy was removed are skipped.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild containing the channel.
+    /// - `channel_id`: The ID of the channel.
+    /// - `name`: The channel's current name.
+    /// - `kind`: The channel's type.
+    ///
+    /// # Returns
+    /// What changed for the channel.
+    pub async fn match_channel_name(
+        &self,
+        guild_id: GuildId,
+        channel_id: ChannelId,
+        name: &str,
+        kind: ChannelType,
+    ) -> Result<PatternOutcome> {
+        let ChannelSupport::Messages { threads } = ChannelSupport::of(kind) else {
+            return Ok(PatternOutcome::Unchanged);
+        };
+        let current = self.task(guild_id, channel_id).await;
+        if current.as_ref().is_some_and(|task| !task.auto) {
+            return Ok(PatternOutcome::Unchanged);
+        }
+        let settings = self.guild_settings(guild_id).await;
+        let policy = settings
+            .patterns
+            .iter()
+            .filter(|(_, policy)| settings.policies.contains_key(*policy))
+            .find(|(pattern, _)| matches_pattern(pattern, name))
+            .map(|(_, policy)| policy);
+
+        match (policy, current) {
+            (Some(policy), Some(task)) if task.policy.as_ref() == Some(policy) => {
+                Ok(PatternOutcome::Unchanged)
+            }
+            (Some(policy), _) => {
+                self.follow_policy(guild_id, channel_id, policy, |task| {
+                    task.auto = true;
+                    task.include_threads &= threads;
+                })
+                .await?;
+                Ok(PatternOutcome::Followed(policy.clone()))
+            }
+            (None, Some(_)) => {
+                self.remove_task(guild_id, channel_id).await?;
+                Ok(PatternOutcome::Removed)
+            }
+            (None, None) => Ok(PatternOutcome::Unchanged),
+        }
+    }
+
+    /// Schedules a newly created channel by its category's default policy.
+    ///
+    /// Channels that already have a task, such as one from a name pattern, are
+    /// left alone, as are categories whose policy was removed.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild containing the channel.
+    /// - `channel_id`: The ID of the channel.
+    /// - `category_id`: The ID of the channel's category, if it has one.
+    /// - `kind`: The channel's type.
+    ///
+    /// # Returns
+    /// The name of the policy the channel now follows, if any.
+    pub async fn apply_category_default(
+        &self,
+        guild_id: GuildId,
+        channel_id: ChannelId,
+        category_id: Option<ChannelId>,
+        kind: ChannelType,
+    ) -> Result<Option<String>> {
+        let ChannelSupport::Messages { threads } = ChannelSupport::of(kind) else {
+            return Ok(None);
+        };
+        let Some(category_id) = category_id else {
+            return Ok(None);
+        };
+        if self.task(guild_id, channel_id).await.is_some() {
+            return Ok(None);
+        }
+        let Some(name) = self
+            .guild_settings(guild_id)
+            .await
+            .category_policies
+            .remove(&category_id)
+        else {
+            return Ok(None);
+        };
+        let followed = self
+            .follow_policy(guild_id, channel_id, &name, |task| {
+                task.auto = false;
+                task.include_threads &= threads;
+            })
+            .await?;
+        Ok(followed.then_some(name))
+    }
+
+    /// Makes a channel follow a guild's named policy, creating its task if it
+    /// has none, and applies `configure` to the task afterwards.
+    ///
+    /// # Returns
+    /// `true` if the policy exists and was applied, `false` otherwise.
+    async fn follow_policy(
+        &self,
+        guild_id: GuildId,
+        channel_id: ChannelId,
+        name: &str,
+        configure: impl FnOnce(&mut CleanupTask),
+    ) -> Result<bool> {
+        let Some(policy) = self.guild_settings(guild_id).await.policies.remove(name) else {
+            return Ok(false);
+        };
+        let now = self.clock.now();
+        let change = {
+            let mut tasks = self.tasks.write().await;
+            let guild_tasks = tasks.entry(guild_id).or_insert_with(HashMap::new);
+            let kind = match guild_tasks.get(&channel_id) {
+                Some(_) => TaskChangeKind::Updated,
+                None => TaskChangeKind::Added,
+            };
+            let mut task = guild_tasks
+                .get(&channel_id)
+                .cloned()
+                .unwrap_or_else(|| CleanupTask::starting_at(policy.interval, now));
+            policy.apply(&mut task);
+            task.policy = Some(name.to_string());
+            configure(&mut task);
+            task.check_settings()?;
+            let change = self.change(kind, guild_id, channel_id, Some(&task));
+            guild_tasks.insert(channel_id, task);
+            change
+        };
+        self.save_tasks().await?;
+        self.changes.publish(change);
+        tracing::info!(
+            "Applied policy {} to guild {} channel {}",
+            name,
+            obfuscate_id(guild_id.get()),
+            obfuscate_id(channel_id.get())
+        );
+        Ok(true)
+    }
+
+    /// Applies `update` to every task of a guild that follows the named policy
+    /// and saves the task map.
+    ///
+    /// # Returns
+    /// The number of tasks updated.
+    async fn update_policy_tasks(
+        &self,
+        guild_id: GuildId,
+        name: &str,
+        update: impl Fn(&mut CleanupTask),
+    ) -> Result<usize> {
+        let changes: Vec<TaskChange> = {
+            let mut tasks = self.tasks.write().await;
+            let Some(guild_tasks) = tasks.get_mut(&guild_id) else {
+                return Ok(0);
+            };
+            // Every task is checked before any changes, so none does if one can't
+            let updated = guild_tasks
+                .iter()
+                .filter(|(_, task)| task.policy.as_deref() == Some(name))
+                .map(|(channel_id, task)| {
+                    let mut task = task.clone();
+                    update(&mut task);
+                    task.check_settings().map(|_| (*channel_id, task))
+                })
+                .collect::<std::result::Result<Vec<_>, EuleError>>()?;
+            updated
+                .into_iter()
+                .map(|(channel_id, task)| {
+                    let change =
+                        self.change(TaskChangeKind::Updated, guild_id, channel_id, Some(&task));
+                    guild_tasks.insert(channel_id, task);
+                    change
+                })
+                .collect()
+        };
+        if changes.is_empty() {
+            return Ok(0);
+        }
+        self.save_tasks().await?;
+        let count = changes.len();
+        for change in changes {
+            self.changes.publish(change);
+        }
+        Ok(count)
+    }
+
+    /// Removes a cleanup task for a specific channel in a guild.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild where the task should be removed.
+    /// - `channel_id`: The ID of the channel whose task should be removed.
+    ///
+    /// # Returns
+    /// `true` if a task was removed, `false` if no task was found.
+    pub async fn remove_task(&self, guild_id: GuildId, channel_id: ChannelId) -> Result<bool> {
+        // Release the write lock before saving, which takes a read lock
+        let removed = {
+            let mut tasks = self.tasks.write().await;
+            if let Some(guild_tasks) = tasks.get_mut(&guild_id) {
+                guild_tasks.remove(&channel_id).is_some()
+            } else {
+                false
+            }
+        };
+        if removed {
+            self.message_counts.forget(channel_id);
+            self.save_tasks().await?;
+            self.changes
+                .publish(self.change(TaskChangeKind::Removed, guild_id, channel_id, None));
+            let obfuscated_guild = obfuscate_id(guild_id.get());
+            let obfuscated_channel = obfuscate_id(channel_id.get());
+            tracing::info!(
+                "Removed cleanup task for guild {} channel {}",
+                obfuscated_guild,
+                obfuscated_channel
+            );
+        }
+        Ok(removed)
+    }
+
+    /// Removes every task and scheduled purge of a guild at once, for guilds
+    /// retiring the bot or starting their retention setup over.
+    ///
+    /// Cleanups in progress are cancelled, and purges bound to scheduled
+    /// events are forgotten along with one-shot purges. Named policies,
+    /// patterns and category defaults are kept, so channels created or renamed
+    /// later can still get tasks from them.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild whose tasks to remove.
+    ///
+    /// # Returns
+    /// The number of tasks and the number of scheduled purges removed.
+    pub async fn remove_guild_tasks(&self, guild_id: GuildId) -> Result<(usize, usize)> {
+        // Release the write lock before saving, which takes a read lock
+        let removed: Vec<(ChannelId, CleanupTask)> = self
+            .tasks
+            .write()
+            .await
+            .remove(&guild_id)
+            .map(|guild_tasks| guild_tasks.into_iter().collect())
+            .unwrap_or_default();
+        for (channel_id, task) in &removed {
+            if let Some(token) = &task.running {
+                token.cancel();
+            }
+            self.message_counts.forget(*channel_id);
+        }
+        if !removed.is_empty() {
+            self.save_tasks().await?;
+            for (channel_id, _) in &removed {
+                self.changes.publish(self.change(
+                    TaskChangeKind::Removed,
+                    guild_id,
+                    *channel_id,
+                    None,
+                ));
+            }
+        }
+
+        let one_shots = self
+            .one_shots
+            .write()
+            .await
+            .remove(&guild_id)
+            .map_or(0, |guild_purges| guild_purges.len());
+        if one_shots > 0 {
+            self.save_one_shots().await?;
+        }
+        let event_purges = self.guild_settings(guild_id).await.event_purges.len();
+        if event_purges > 0 {
+            self.update_guild_settings(guild_id, |settings| settings.event_purges.clear())
+                .await?;
+        }
+
+        tracing::info!(
+            "Removed {} cleanup tasks and {} scheduled purges of guild {}",
+            removed.len(),
+            one_shots + event_purges,
+            obfuscate_id(guild_id.get())
+        );
+        Ok((removed.len(), one_shots + event_purges))
+    }
+
+    /// Moves a task to another channel of the same guild, with its schedule,
+    /// settings, history and statistics, for channels that were recreated and
+    /// got a new ID.
+    ///
+    /// Messages set aside for retrying belong to the old channel and are
+    /// dropped. Summaries posted in the old channel and a one-shot purge
+    /// scheduled for it move along with the task.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild containing both channels.
+    /// - `from`: The channel the task belongs to.
+    /// - `to`: The channel to move it to.
+    ///
+    /// # Returns
+    /// Whether the task was moved, or why not.
+    pub async fn move_task(
+        &self,
+        guild_id: GuildId,
+        from: ChannelId,
+        to: ChannelId,
+    ) -> Result<TaskMove> {
+        let changes = {
+            let mut tasks = self.tasks.write().await;
+            let Some(guild_tasks) = tasks.get_mut(&guild_id) else {
+                return Ok(TaskMove::NoTask);
+            };
+            let Some(mut task) = guild_tasks.remove(&from) else {
+                return Ok(TaskMove::NoTask);
+            };
+            let refused = match (guild_tasks.contains_key(&to), task.running.is_some()) {
+                (_, true) => Some(TaskMove::Running),
+                (true, false) => Some(TaskMove::Occupied),
+                (false, false) => None,
+            };
+            if let Some(refused) = refused {
+                guild_tasks.insert(from, task);
+                return Ok(refused);
+            }
+            task.retry_queue.clear();
+            task.permissions_reported = false;
+            // The new channel's name may not match the pattern that created the task
+            task.auto = false;
+            if task.summary == Some(from) {
+                task.summary = Some(to);
+            }
+            let changes = [
+                self.change(TaskChangeKind::Removed, guild_id, from, None),
+                self.change(TaskChangeKind::Added, guild_id, to, Some(&task)),
+            ];
+            guild_tasks.insert(to, task);
+            changes
+        };
+        self.message_counts.forget(from);
+        self.save_tasks().await?;
+        for change in changes {
+            self.changes.publish(change);
+        }
+
+        let one_shot = {
+            let mut one_shots = self.one_shots.write().await;
+            one_shots.get_mut(&guild_id).and_then(|guild_purges| {
+                let at = guild_purges.remove(&from)?;
+                guild_purges.insert(to, at);
+                Some(at)
+            })
+        };
+        if one_shot.is_some() {
+            self.save_one_shots().await?;
+        }
+
+        tracing::info!(
+            "Moved cleanup task of guild {} from channel {} to channel {}",
+            obfuscate_id(guild_id.get()),
+            obfuscate_id(from.get()),
+            obfuscate_id(to.get())
+        );
+        Ok(TaskMove::Moved)
+    }
+
+    /// Applies `update` to an existing task and saves the task map.
+    ///
+    /// # Returns
+    /// `true` if the task exists and was updated, `false` otherwise.
+    async fn update_task(
+        &self,
+        guild_id: GuildId,
+        channel_id: ChannelId,
+        update: impl FnOnce(&mut CleanupTask),
+    ) -> Result<bool> {
+        let change = {
+            let mut tasks = self.tasks.write().await;
+            let Some(task) = tasks
+                .get_mut(&guild_id)
+                .and_then(|guild_tasks| guild_tasks.get_mut(&channel_id))
+            else {
+                return Ok(false);
+            };
+            // Settings that can't work together leave the task as it was
+            let mut updated = task.clone();
+            update(&mut updated);
+            updated.check_settings()?;
+            *task = updated;
+            self.change(TaskChangeKind::Updated, guild_id, channel_id, Some(task))
+        };
+        self.save_tasks().await?;
+        self.changes.publish(change);
+        Ok(true)
+    }
+
+    fn change(
+        &self,
+        kind: TaskChangeKind,
+        guild_id: GuildId,
+        channel_id: ChannelId,
+        task: Option<&CleanupTask>,
+    ) -> TaskChange {
+        TaskChange {
+            kind,
+            guild_id,
+            channel_id,
+            at: self.clock.now(),
+            interval_secs: task.map(|task| task.interval.as_secs()),
+            include_threads: task.map(|task| task.include_threads),
+            created_by: task.and_then(|task| task.created_by),
+            edited_by: task.and_then(|task| task.edited_by),
+        }
+    }
+
+    /// Records that a member created a task with a command, so others know
+    /// whom to ask about it.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild containing the task.
+    /// - `channel_id`: The ID of the task's channel.
+    /// - `user_id`: The ID of the member who created it.
+    ///
+    /// # Returns
+    /// `true` if the task exists and was updated, `false` otherwise.
+    pub async fn record_creator(
+        &self,
+        guild_id: GuildId,
+        channel_id: ChannelId,
+        user_id: UserId,
+    ) -> Result<bool> {
+        let now = self.clock.now();
+        self.update_task(guild_id, channel_id, |task| {
+            task.created_by = Some(user_id);
+            task.edited_by = Some(user_id);
+            task.edited_at = Some(now);
+        })
+        .await
+    }
+
+    /// Sets whether a task's creator gets a direct message after each of its
+    /// cleanups that finishes or fails.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild containing the task.
+    /// - `channel_id`: The ID of the task's channel.
+    /// - `enabled`: Whether the creator is told.
+    ///
+    /// # Returns
+    /// `true` if the task exists and was updated, `false` otherwise.
+    pub async fn set_notify_creator(
+        &self,
+        guild_id: GuildId,
+        channel_id: ChannelId,
+        enabled: bool,
+    ) -> Result<bool> {
+        self.update_task(guild_id, channel_id, |task| task.notify_creator = enabled)
+            .await
+    }
+
+    /// Records that a member changed a task with a command.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild containing the task.
+    /// - `channel_id`: The ID of the task's channel.
+    /// - `user_id`: The ID of the member who changed it.
+    ///
+    /// # Returns
+    /// `true` if the task exists and was updated, `false` otherwise.
+    pub async fn record_editor(
+        &self,
+        guild_id: GuildId,
+        channel_id: ChannelId,
+        user_id: UserId,
+    ) -> Result<bool> {
+        let now = self.clock.now();
+        self.update_task(guild_id, channel_id, |task| {
+            task.edited_by = Some(user_id);
+            task.edited_at = Some(now);
+        })
+        .await
+    }
+
+    /// Sets whether a task also cleans the threads under its channel.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild containing the task.
+    /// - `channel_id`: The ID of the task's channel.
+    /// - `include_threads`: Whether threads should be cleaned.
+    ///
+    /// # Returns
+    /// `true` if the task exists and was updated, `false` otherwise.
+    pub async fn set_include_threads(
+        &self,
+        guild_id: GuildId,
+        channel_id: ChannelId,
+        include_threads: bool,
+    ) -> Result<bool> {
+        self.update_task(guild_id, channel_id, |task| {
+            task.include_threads = include_threads
+        })
+        .await
+    }
+
+    /// Sets whether a task's cleanups leave the channel's oldest message in place.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild containing the task.
+    /// - `channel_id`: The ID of the task's channel.
+    /// - `keep_first_message`: Whether the oldest message should be kept.
+    ///
+    /// # Returns
+    /// `true` if the task exists and was updated, `false` otherwise.
+    pub async fn set_keep_first_message(
+        &self,
+        guild_id: GuildId,
+        channel_id: ChannelId,
+        keep_first_message: bool,
+    ) -> Result<bool> {
+        self.update_task(guild_id, channel_id, |task| {
+            task.keep_first_message = keep_first_message
+        })
+        .await
+    }
+
+    /// Sets whether a task's cleanups leave pinned messages in place.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild containing the task.
+    /// - `channel_id`: The ID of the task's channel.
+    /// - `keep_pinned`: Whether pinned messages should be kept.
+    ///
+    /// # Returns
+    /// `true` if the task exists and was updated, `false` otherwise.
+    pub async fn set_keep_pinned(
+        &self,
+        guild_id: GuildId,
+        channel_id: ChannelId,
+        keep_pinned: bool,
+    ) -> Result<bool> {
+        self.update_task(guild_id, channel_id, |task| task.keep_pinned = keep_pinned)
+            .await
+    }
+
+    /// Sets the keywords that keep messages out of a task's cleanups.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild containing the task.
+    /// - `channel_id`: The ID of the task's channel.
+    /// - `keywords`: The keywords, replacing any set before.
+    ///
+    /// # Returns
+    /// `true` if the task exists and was updated, `false` otherwise.
+    pub async fn set_keep_keywords(
+        &self,
+        guild_id: GuildId,
+        channel_id: ChannelId,
+        keywords: Vec<String>,
+    ) -> Result<bool> {
+        self.update_task(guild_id, channel_id, |task| task.keep_keywords = keywords)
+            .await
+    }
+
+    /// Sets a task's labels.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild containing the task.
+    /// - `channel_id`: The ID of the task's channel.
+    /// - `labels`: The labels, in lowercase, replacing any set before.
+    ///
+    /// # Returns
+    /// `true` if the task exists and was updated, `false` otherwise.
+    pub async fn set_labels(
+        &self,
+        guild_id: GuildId,
+        channel_id: ChannelId,
+        labels: BTreeSet<String>,
+    ) -> Result<bool> {
+        self.update_task(guild_id, channel_id, |task| task.labels = labels)
+            .await
+    }
+
+    /// Sets whether a task's cleanups replace the channel with an empty copy.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild containing the task.
+    /// - `channel_id`: The ID of the task's channel.
+    /// - `nuke`: Whether the channel should be replaced instead of purged.
+    ///
+    /// # Returns
+    /// `true` if the task exists and was updated, `false` otherwise.
+    pub async fn set_nuke(
+        &self,
+        guild_id: GuildId,
+        channel_id: ChannelId,
+        nuke: bool,
+    ) -> Result<bool> {
+        self.update_task(guild_id, channel_id, |task| task.nuke = nuke)
+            .await
+    }
+
+    /// Sets the slowmode a task's channel is held at while it is cleaned.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild containing the task.
+    /// - `channel_id`: The ID of the task's channel.
+    /// - `slowmode`: The slowmode in seconds, or `None` to leave it alone.
+    ///
+    /// # Returns
+    /// `true` if the task exists and was updated, `false` otherwise.
+    pub async fn set_slowmode(
+        &self,
+        guild_id: GuildId,
+        channel_id: ChannelId,
+        slowmode: Option<u16>,
+    ) -> Result<bool> {
+        self.update_task(guild_id, channel_id, |task| task.slowmode = slowmode)
+            .await
+    }
+
+    /// Sets how many new messages in a task's channel trigger a cleanup ahead
+    /// of its interval.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild containing the task.
+    /// - `channel_id`: The ID of the task's channel.
+    /// - `threshold`: The number of messages, or `None` to only clean on the interval.
+    ///
+    /// # Returns
+    /// `true` if the task exists and was updated, `false` otherwise.
+    pub async fn set_message_trigger(
+        &self,
+        guild_id: GuildId,
+        channel_id: ChannelId,
+        threshold: Option<u32>,
+    ) -> Result<bool> {
+        self.message_counts.forget(channel_id);
+        self.update_task(guild_id, channel_id, |task| {
+            task.message_trigger = threshold
+        })
+        .await
+    }
+
+    /// Counts a message posted in a channel towards its task's message
+    /// trigger, and schedules a cleanup right away once the trigger is reached.
+    ///
+    /// Channels without a trigger don't count messages. A trigger reached
+    /// while the task can't run, because it is running, paused, expired or on
+    /// hold, is let go.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild the message was posted in.
+    /// - `channel_id`: The ID of the channel the message was posted in.
+    ///
+    /// # Returns
+    /// `true` if the message triggered a cleanup.
+    pub async fn count_message(&self, guild_id: GuildId, channel_id: ChannelId) -> Result<bool> {
+        let now = self.clock.now();
+        let Some((threshold, since, state)) = self
+            .tasks
+            .read()
+            .await
+            .get(&guild_id)
+            .and_then(|guild_tasks| guild_tasks.get(&channel_id))
+            .and_then(|task| {
+                Some((
+                    task.message_trigger?,
+                    task.last_cleanup,
+                    task.state_at(now, false),
+                ))
+            })
+        else {
+            return Ok(false);
+        };
+        if !self.message_counts.count(channel_id, since, threshold) {
+            return Ok(false);
+        }
+        if !matches!(state, TaskState::Scheduled | TaskState::Failed)
+            || self.is_held(guild_id, channel_id).await
+        {
+            return Ok(false);
+        }
+        self.schedule_purge(guild_id, channel_id, now).await?;
+        self.wake();
+        Ok(true)
+    }
+
+    /// Sets how many messages from one member in how short a time make a
+    /// spam burst in the guild, or stops watching for bursts.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild to watch.
+    /// - `rule`: The burst rule, or `None` to stop watching.
+    pub async fn set_spam_burst(&self, guild_id: GuildId, rule: Option<BurstRule>) -> Result<()> {
+        self.update_guild_settings(guild_id, |settings| settings.spam_burst = rule)
+            .await
+    }
+
+    /// Remembers the AutoMod rule the bot set up in a guild for a filter, or
+    /// forgets it.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild the rule belongs to.
+    /// - `kind`: The filter the rule enforces.
+    /// - `rule_id`: The ID of the rule, or `None` once it is deleted.
+    pub async fn set_automod_rule(
+        &self,
+        guild_id: GuildId,
+        kind: AutomodRule,
+        rule_id: Option<RuleId>,
+    ) -> Result<()> {
+        self.update_guild_settings(guild_id, |settings| match rule_id {
+            Some(rule_id) => {
+                settings.automod_rules.insert(kind, rule_id);
+            }
+            None => {
+                settings.automod_rules.remove(&kind);
+            }
+        })
+        .await
+    }
+
+    /// Watches a message posted in a guild for spam bursts, and deletes the
+    /// burst it belongs to, if any.
+    ///
+    /// Guilds without a burst rule aren't watched, and nothing is deleted from
+    /// channels on hold.
+    ///
+    /// # Parameters
+    /// - `api`: The Discord API to delete the burst with.
+    /// - `guild_id`: The ID of the guild the message was posted in.
+    /// - `channel_id`: The ID of the channel the message was posted in.
+    /// - `user_id`: The ID of the message's author.
+    /// - `message_id`: The ID of the message.
+    ///
+    /// # Returns
+    /// The burst the message belongs to, whose messages were deleted.
+    pub async fn check_spam_burst<A: DiscordApi + ?Sized>(
+        &self,
+        api: &A,
+        guild_id: GuildId,
+        channel_id: ChannelId,
+        user_id: UserId,
+        message_id: MessageId,
+    ) -> Result<Burst> {
+        let Some(rule) = self
+            .settings
+            .read()
+            .await
+            .get(&guild_id)
+            .and_then(|settings| settings.spam_burst)
+        else {
+            return Ok(Burst::None);
+        };
+        let burst =
+            self.spam_bursts
+                .record(channel_id, user_id, message_id, self.clock.now(), rule);
+        if burst == Burst::None || self.is_held(guild_id, channel_id).await {
+            return Ok(Burst::None);
+        }
+        match &burst {
+            Burst::Started(messages) => api.delete_messages(channel_id, messages).await?,
+            Burst::Continued(message) => api.delete_message(channel_id, *message).await?,
+            Burst::None => {}
+        }
+        tracing::info!(
+            "Deleted spam burst messages of user {} in channel {} of guild {}",
+            obfuscate_id(user_id.get()),
+            obfuscate_id(channel_id.get()),
+            obfuscate_id(guild_id.get())
+        );
+        Ok(burst)
+    }
+
+    /// Deletes a message posted in a guild if it links to a phishing site.
+    ///
+    /// Nothing is deleted from channels on hold.
+    ///
+    /// # Parameters
+    /// - `api`: The Discord API to delete the message with.
+    /// - `guild_id`: The ID of the guild the message was posted in.
+    /// - `channel_id`: The ID of the channel the message was posted in.
+    /// - `message_id`: The ID of the message.
+    /// - `content`: The message's text.
+    ///
+    /// # Returns
+    /// The host of the phishing link if the message was deleted, `None`
+    /// otherwise.
+    pub async fn delete_phishing<A: DiscordApi + ?Sized>(
+        &self,
+        api: &A,
+        guild_id: GuildId,
+        channel_id: ChannelId,
+        message_id: MessageId,
+        content: &str,
+    ) -> Result<Option<String>> {
+        let Some(host) = phishing::blocked_domains().blocked_domain(content) else {
+            return Ok(None);
+        };
+        if self.is_held(guild_id, channel_id).await {
+            return Ok(None);
+        }
+        api.delete_message(channel_id, message_id).await?;
+        tracing::info!(
+            "Deleted a phishing link in channel {} of guild {}",
+            obfuscate_id(channel_id.get()),
+            obfuscate_id(guild_id.get())
+        );
+        Ok(Some(host))
+    }
+
+    /// Sets the pause after each delete of a message older than 14 days in a
+    /// task's channel.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild containing the task.
+    /// - `channel_id`: The ID of the task's channel.
+    /// - `delay`: The pause, or `None` to use the bot's default.
+    ///
+    /// # Returns
+    /// `true` if the task exists and was updated, `false` otherwise.
+    pub async fn set_old_message_delay(
+        &self,
+        guild_id: GuildId,
+        channel_id: ChannelId,
+        delay: Option<Duration>,
+    ) -> Result<bool> {
+        self.update_task(guild_id, channel_id, |task| task.old_message_delay = delay)
+            .await
+    }
+
+    /// Sets the order a task's cleanups delete messages in.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild containing the task.
+    /// - `channel_id`: The ID of the task's channel.
+    /// - `order`: The order to delete messages in.
+    ///
+    /// # Returns
+    /// `true` if the task exists and was updated, `false` otherwise.
+    pub async fn set_deletion_order(
+        &self,
+        guild_id: GuildId,
+        channel_id: ChannelId,
+        order: DeletionOrder,
+    ) -> Result<bool> {
+        self.update_task(guild_id, channel_id, |task| task.deletion_order = order)
+            .await
+    }
+
+    /// Caps how many messages a single cleanup of a task's channel deletes.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild containing the task.
+    /// - `channel_id`: The ID of the task's channel.
+    /// - `max`: The most messages per cleanup, or `None` for no cap.
+    ///
+    /// # Returns
+    /// `true` if the task exists and was updated, `false` otherwise.
+    pub async fn set_max_deletions(
+        &self,
+        guild_id: GuildId,
+        channel_id: ChannelId,
+        max: Option<u32>,
+    ) -> Result<bool> {
+        self.update_task(guild_id, channel_id, |task| task.max_deletions = max)
+            .await
+    }
+
+    /// Sets whether a task's next scheduled cleanup is a dry run, which only
+    /// reports what it would delete.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild containing the task.
+    /// - `channel_id`: The ID of the task's channel.
+    /// - `dry_run`: Whether the next cleanup should be a dry run.
+    ///
+    /// # Returns
+    /// `true` if the task exists and was updated, `false` otherwise.
+    pub async fn set_dry_run(
+        &self,
+        guild_id: GuildId,
+        channel_id: ChannelId,
+        dry_run: bool,
+    ) -> Result<bool> {
+        self.update_task(guild_id, channel_id, |task| task.dry_run = dry_run)
+            .await
+    }
+
+    /// Sets whether @everyone is denied Send Messages while a task's channel is cleaned.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild containing the task.
+    /// - `channel_id`: The ID of the task's channel.
+    /// - `lock_channel`: Whether the channel should be locked during cleanups.
+    ///
+    /// # Returns
+    /// `true` if the task exists and was updated, `false` otherwise.
+    pub async fn set_lock_channel(
+        &self,
+        guild_id: GuildId,
+        channel_id: ChannelId,
+        lock_channel: bool,
+    ) -> Result<bool> {
+        self.update_task(guild_id, channel_id, |task| {
+            task.lock_channel = lock_channel
+        })
+        .await
+    }
+
+    /// Sets where a summary is posted after each of a task's cleanups.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild containing the task.
+    /// - `channel_id`: The ID of the task's channel.
+    /// - `summary`: The channel to post summaries in, or `None` for no summaries.
+    ///
+    /// # Returns
+    /// `true` if the task exists and was updated, `false` otherwise.
+    pub async fn set_summary(
+        &self,
+        guild_id: GuildId,
+        channel_id: ChannelId,
+        summary: Option<ChannelId>,
+    ) -> Result<bool> {
+        self.update_task(guild_id, channel_id, |task| task.summary = summary)
+            .await
+    }
+
+    /// Sets the interval between a task's cleanups.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild containing the task.
+    /// - `channel_id`: The ID of the task's channel.
+    /// - `interval`: The new time interval between cleanups.
+    ///
+    /// # Returns
+    /// `true` if the task exists and was updated, `false` otherwise.
+    pub async fn set_interval(
+        &self,
+        guild_id: GuildId,
+        channel_id: ChannelId,
+        interval: Duration,
+    ) -> Result<bool> {
+        self.update_task(guild_id, channel_id, |task| task.interval = interval)
+            .await
+    }
+
+    /// Sets the forum post policy of a task.
+    ///
+    /// With a policy set, the task archives or deletes old forum posts instead of
+    /// purging messages.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild containing the task.
+    /// - `channel_id`: The ID of the forum channel.
+    /// - `forum`: The post policy, or `None` to purge messages as usual.
+    ///
+    /// # Returns
+    /// `true` if the task exists and was updated, `false` otherwise.
+    pub async fn set_forum_options(
+        &self,
+        guild_id: GuildId,
+        channel_id: ChannelId,
+        forum: Option<ForumOptions>,
+    ) -> Result<bool> {
+        self.update_task(guild_id, channel_id, |task| task.forum = forum)
+            .await
+    }
+
+    /// Sets the thread policy of a task.
+    ///
+    /// With a policy set, the task archives or deletes the channel's stale
+    /// threads instead of purging messages.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild containing the task.
+    /// - `channel_id`: The ID of the channel whose threads to clean up.
+    /// - `threads`: The thread policy, or `None` to purge messages as usual.
+    ///
+    /// # Returns
+    /// `true` if the task exists and was updated, `false` otherwise.
+    pub async fn set_thread_options(
+        &self,
+        guild_id: GuildId,
+        channel_id: ChannelId,
+        threads: Option<ThreadOptions>,
+    ) -> Result<bool> {
+        self.update_task(guild_id, channel_id, |task| task.threads = threads)
+            .await
+    }
+
+    /// Sets whether a task keeps its channel topic suffixed with the next cleanup time.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild containing the task.
+    /// - `channel_id`: The ID of the task's channel.
+    /// - `show_in_topic`: Whether the topic should show the next cleanup.
+    ///
+    /// # Returns
+    /// `true` if the task exists and was updated, `false` otherwise.
+    pub async fn set_show_in_topic(
+        &self,
+        guild_id: GuildId,
+        channel_id: ChannelId,
+        show_in_topic: bool,
+    ) -> Result<bool> {
+        self.update_task(guild_id, channel_id, |task| {
+            task.show_in_topic = show_in_topic
+        })
+        .await
+    }
+
+    /// Sets which starboarded messages a task's cleanups leave in place.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild containing the task.
+    /// - `channel_id`: The ID of the task's channel.
+    /// - `starboard`: The starboard settings, or `None` to clean starred messages too.
+    ///
+    /// # Returns
+    /// `true` if the task exists and was updated, `false` otherwise.
+    pub async fn set_starboard(
+        &self,
+        guild_id: GuildId,
+        channel_id: ChannelId,
+        starboard: Option<StarboardOptions>,
+    ) -> Result<bool> {
+        self.update_task(guild_id, channel_id, |task| task.starboard = starboard)
+            .await
+    }
+
+    /// Sets the warning posted before a task's cleanups.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild containing the task.
+    /// - `channel_id`: The ID of the task's channel.
+    /// - `warning`: The warning, or `None` to clean without warning.
+    ///
+    /// # Returns
+    /// `true` if the task exists and was updated, `false` otherwise.
+    pub async fn set_warning(
+        &self,
+        guild_id: GuildId,
+        channel_id: ChannelId,
+        warning: Option<PurgeWarning>,
+    ) -> Result<bool> {
+        self.update_task(guild_id, channel_id, |task| task.warning = warning)
+            .await
+    }
+
+    /// Sets when a task removes itself.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild containing the task.
+    /// - `channel_id`: The ID of the task's channel.
+    /// - `expires`: When the task expires, or `None` to keep it indefinitely.
+    ///
+    /// # Returns
+    /// `true` if the task exists and was updated, `false` otherwise.
+    pub async fn set_expires(
+        &self,
+        guild_id: GuildId,
+        channel_id: ChannelId,
+        expires: Option<SerializableInstant>,
+    ) -> Result<bool> {
+        self.update_task(guild_id, channel_id, |task| task.expires = expires)
+            .await
+    }
+
+    /// Removes the tasks that have expired.
+    ///
+    /// # Returns
+    /// The guild and channel of each removed task.
+    pub async fn take_expired(&self) -> Result<Vec<(GuildId, ChannelId)>> {
+        let now = self.clock.now();
+        let expired: Vec<(GuildId, ChannelId)> = {
+            let mut tasks = self.tasks.write().await;
+            let mut expired = Vec::new();
+            for (guild_id, guild_tasks) in tasks.iter_mut() {
+                guild_tasks.retain(|channel_id, task| {
+                    let keep = !task.is_expired_at(now);
+                    if !keep {
+                        expired.push((*guild_id, *channel_id));
+                    }
+                    keep
+                });
+            }
+            expired
+        };
+        if expired.is_empty() {
+            return Ok(expired);
+        }
+        self.save_tasks().await?;
+        for (guild_id, channel_id) in &expired {
+            self.changes.publish(self.change(
+                TaskChangeKind::Removed,
+                *guild_id,
+                *channel_id,
+                None,
+            ));
+            tracing::info!(
+                "Cleanup task for guild {} channel {} expired",
+                obfuscate_id(guild_id.get()),
+                obfuscate_id(channel_id.get())
+            );
+        }
+        Ok(expired)
+    }
+
+    /// Schedules a single purge of a channel, replacing any purge already
+    /// scheduled for it. The channel doesn't need a task; if it has one, the
+    /// purge uses the task's settings and counts as one of its runs.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild containing the channel.
+    /// - `channel_id`: The ID of the channel to purge.
+    /// - `at`: When to purge it.
+    pub async fn schedule_purge(
+        &self,
+        guild_id: GuildId,
+        channel_id: ChannelId,
+        at: SerializableInstant,
+    ) -> Result<()> {
+        self.one_shots
+            .write()
+            .await
+            .entry(guild_id)
+            .or_default()
+            .insert(channel_id, at);
+        self.save_one_shots().await?;
+        tracing::info!(
+            "Scheduled a one-shot purge for guild {} channel {}",
+            obfuscate_id(guild_id.get()),
+            obfuscate_id(channel_id.get())
+        );
+        Ok(())
+    }
+
+    /// Cancels a channel's scheduled one-shot purge.
+    ///
+    /// # Returns
+    /// When the cancelled purge would have run, if one was scheduled.
+    pub async fn cancel_scheduled_purge(
+        &self,
+        guild_id: GuildId,
+        channel_id: ChannelId,
+    ) -> Result<Option<SerializableInstant>> {
+        let cancelled = {
+            let mut one_shots = self.one_shots.write().await;
+            let cancelled = one_shots
+                .get_mut(&guild_id)
+                .and_then(|guild_purges| guild_purges.remove(&channel_id));
+            one_shots.retain(|_, guild_purges| !guild_purges.is_empty());
+            cancelled
+        };
+        if cancelled.is_some() {
+            self.save_one_shots().await?;
+        }
+        Ok(cancelled)
+    }
+
+    /// Returns a guild's scheduled one-shot purges, soonest first.
+    pub async fn scheduled_purges(
+        &self,
+        guild_id: GuildId,
+    ) -> Vec<(ChannelId, SerializableInstant)> {
+        let mut purges: Vec<_> = self
+            .one_shots
+            .read()
+            .await
+            .get(&guild_id)
+            .map(|guild_purges| guild_purges.iter().map(|(c, at)| (*c, *at)).collect())
+            .unwrap_or_default();
+        purges.sort_by_key(|(_, at)| *at);
+        purges
+    }
+
+    /// Returns every guild's scheduled one-shot purges, soonest first.
+    pub async fn all_scheduled_purges(&self) -> Vec<(GuildId, ChannelId, SerializableInstant)> {
+        let mut purges: Vec<_> = self
+            .one_shots
+            .read()
+            .await
+            .iter()
+            .flat_map(|(guild_id, guild_purges)| {
+                guild_purges
+                    .iter()
+                    .map(move |(channel_id, at)| (*guild_id, *channel_id, *at))
+            })
+            .collect();
+        purges.sort_by_key(|(_, _, at)| *at);
+        purges
+    }
+
+    /// Schedules the purge bound to a scheduled event that just ended, and
+    /// forgets the binding, since an ended event doesn't start again.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild the event belongs to.
+    /// - `event_id`: The ID of the event that ended.
+    ///
+    /// # Returns
+    /// The channel that will be purged and when, if the event had a purge.
+    pub async fn end_event(
+        &self,
+        guild_id: GuildId,
+        event_id: ScheduledEventId,
+    ) -> Result<Option<(ChannelId, SerializableInstant)>> {
+        let Some(purge) = self
+            .guild_settings(guild_id)
+            .await
+            .event_purges
+            .remove(&event_id)
+        else {
+            return Ok(None);
+        };
+        self.update_guild_settings(guild_id, |settings| {
+            settings.event_purges.remove(&event_id);
+        })
+        .await?;
+        let at = self.clock.now() + purge.delay;
+        self.schedule_purge(guild_id, purge.channel, at).await?;
+        Ok(Some((purge.channel, at)))
+    }
+
+    /// Claims the one-shot purges that are due, so each runs only once.
+    ///
+    /// # Returns
+    /// The guild and channel of each purge to run.
+    pub async fn take_due_purges(&self) -> Result<Vec<(GuildId, ChannelId)>> {
+        let now = self.clock.now();
+        let due: Vec<(GuildId, ChannelId)> = {
+            let mut one_shots = self.one_shots.write().await;
+            let mut due = Vec::new();
+            for (guild_id, guild_purges) in one_shots.iter_mut() {
+                guild_purges.retain(|channel_id, at| {
+                    let pending = *at > now;
+                    if !pending {
+                        due.push((*guild_id, *channel_id));
+                    }
+                    pending
+                });
+            }
+            one_shots.retain(|_, guild_purges| !guild_purges.is_empty());
+            due
+        };
+        if !due.is_empty() {
+            self.save_one_shots().await?;
+        }
+        Ok(due)
+    }
+
+    /// Saves the scheduled one-shot purges to persistent storage.
+    async fn save_one_shots(&self) -> Result<()> {
+        let _lock = self.save_lock.lock().await;
+        let one_shots = self.one_shots.read().await;
+        let serialized = serde_json::to_string(&*one_shots).map_err(EuleError::Serialization)?;
+        self.kv_store.set(&self.one_shots_key, &serialized).await?;
+        Ok(())
+    }
+
+    /// Claims the warnings that are due, so each cleanup is announced only once.
+    ///
+    /// # Returns
+    /// The channels to warn, each with its warning and the cleanup to announce.
+    pub async fn take_due_warnings(
+        &self,
+    ) -> Result<Vec<(GuildId, ChannelId, PurgeWarning, SerializableInstant)>> {
+        let now = self.clock.now();
+        let due: Vec<_> = {
+            let mut tasks = self.tasks.write().await;
+            tasks
+                .iter_mut()
+                .flat_map(|(guild_id, guild_tasks)| {
+                    guild_tasks
+                        .iter_mut()
+                        .map(move |(channel_id, task)| (*guild_id, *channel_id, task))
+                })
+                .filter(|(_, _, task)| task.warning_due_at(now))
+                .filter_map(|(guild_id, channel_id, task)| {
+                    let next = task.next_cleanup();
+                    task.warned_for = Some(next);
+                    Some((guild_id, channel_id, task.warning.clone()?, next))
+                })
+                .collect()
+        };
+        if !due.is_empty() {
+            self.save_tasks().await?;
+        }
+        Ok(due)
+    }
+
+    /// Pauses the tasks whose last `MAX_CONSECUTIVE_FAILURES` cleanups all
+    /// failed, so they stop failing every time they are due.
+    ///
+    /// # Returns
+    /// Each task paused, with what failed its last cleanup.
+    pub async fn pause_failing_tasks(&self) -> Result<Vec<(GuildId, ChannelId, FailureKind)>> {
+        let paused: Vec<_> = {
+            let mut tasks = self.tasks.write().await;
+            tasks
+                .iter_mut()
+                .flat_map(|(guild_id, guild_tasks)| {
+                    guild_tasks
+                        .iter_mut()
+                        .map(move |(channel_id, task)| (*guild_id, *channel_id, task))
+                })
+                .filter(|(_, _, task)| task.pause_if_failing())
+                .map(|(guild_id, channel_id, task)| {
+                    let failure = task.failure.unwrap_or(FailureKind::Other);
+                    (guild_id, channel_id, failure)
+                })
+                .collect()
+        };
+        if paused.is_empty() {
+            return Ok(paused);
+        }
+        self.save_tasks().await?;
+        for (guild_id, channel_id, failure) in &paused {
+            tracing::warn!(
+                "Paused cleanup task for guild {} channel {} after {} failed cleanups ({:?})",
+                obfuscate_id(guild_id.get()),
+                obfuscate_id(channel_id.get()),
+                MAX_CONSECUTIVE_FAILURES,
+                failure
+            );
+        }
+        Ok(paused)
+    }
+
+    /// Claims the tasks whose last cleanup failed for lack of permissions and
+    /// whose guild hasn't been told yet, marking them as told.
+    ///
+    /// # Returns
+    /// Each task claimed, with the permissions its cleanups need.
+    pub async fn claim_permission_losses(&self) -> Result<Vec<(GuildId, ChannelId, Permissions)>> {
+        let lost: Vec<_> = {
+            let mut tasks = self.tasks.write().await;
+            tasks
+                .iter_mut()
+                .flat_map(|(guild_id, guild_tasks)| {
+                    guild_tasks
+                        .iter_mut()
+                        .map(move |(channel_id, task)| (*guild_id, *channel_id, task))
+                })
+                .filter(|(_, _, task)| {
+                    task.failure == Some(FailureKind::MissingPermissions)
+                        && !task.permissions_reported
+                })
+                .map(|(guild_id, channel_id, task)| {
+                    task.permissions_reported = true;
+                    (guild_id, channel_id, task.required_permissions())
+                })
+                .collect()
+        };
+        if !lost.is_empty() {
+            self.save_tasks().await?;
+        }
+        Ok(lost)
+    }
+
+    /// Resumes a task that was paused after failing repeatedly, giving its
+    /// cleanups a fresh start.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild containing the task.
+    /// - `channel_id`: The ID of the task's channel.
+    ///
+    /// # Returns
+    /// `true` if the task exists and was paused, `false` otherwise.
+    pub async fn resume_task(&self, guild_id: GuildId, channel_id: ChannelId) -> Result<bool> {
+        let paused = self
+            .task(guild_id, channel_id)
+            .await
+            .is_some_and(|task| task.state == TaskState::Paused);
+        if !paused {
+            return Ok(false);
+        }
+        self.update_task(guild_id, channel_id, |task| {
+            task.resume();
+        })
+        .await
+    }
+
+    /// Sets whether members may keep their own messages out of a task's cleanups.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild containing the task.
+    /// - `channel_id`: The ID of the task's channel.
+    /// - `allow_opt_out`: Whether `/exclude_me` is allowed in the channel.
+    ///
+    /// # Returns
+    /// `true` if the task exists and was updated, `false` otherwise.
+    pub async fn set_allow_opt_out(
+        &self,
+        guild_id: GuildId,
+        channel_id: ChannelId,
+        allow_opt_out: bool,
+    ) -> Result<bool> {
+        self.update_task(guild_id, channel_id, |task| {
+            task.allow_opt_out = allow_opt_out
+        })
+        .await
+    }
+
+    /// Adds a member to, or removes them from, the members whose messages a
+    /// task leaves in place.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild containing the task.
+    /// - `channel_id`: The ID of the task's channel.
+    /// - `user_id`: The member changing their choice.
+    /// - `excluded`: Whether the member's messages should be left in place.
+    ///
+    /// # Returns
+    /// `true` if the task exists and allows opting out, `false` otherwise.
+    pub async fn set_opted_out(
+        &self,
+        guild_id: GuildId,
+        channel_id: ChannelId,
+        user_id: UserId,
+        excluded: bool,
+    ) -> Result<bool> {
+        let allowed = self
+            .task(guild_id, channel_id)
+            .await
+            .is_some_and(|task| task.allow_opt_out);
+        if !allowed {
+            return Ok(false);
+        }
+        self.update_task(guild_id, channel_id, |task| {
+            if excluded {
+                task.opted_out.insert(user_id);
+            } else {
+                task.opted_out.remove(&user_id);
+            }
+        })
+        .await
+    }
+
+    /// Ranks a guild's channels by the number of messages their tasks deleted.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild whose channels to rank.
+    /// - `first_day`: The UTC day counting starts on.
+    /// - `limit`: The most channels to return.
+    /// - `label`: Only ranks the tasks with this label, if given.
+    ///
+    /// # Returns
+    /// Channels and their deletion counts, most deleted first. Channels that had
+    /// nothing deleted are left out.
+    pub async fn top_channels(
+        &self,
+        guild_id: GuildId,
+        first_day: u64,
+        limit: usize,
+        label: Option<&str>,
+    ) -> Vec<(ChannelId, u64)> {
+        let mut ranking: Vec<(ChannelId, u64)> = self
+            .guild_tasks(guild_id)
+            .await
+            .into_iter()
+            .filter(|(_, task)| task.has_label(label))
+            .map(|(channel_id, task)| (channel_id, task.deleted_since(first_day)))
+            .filter(|(_, deleted)| *deleted > 0)
+            .collect();
+        ranking.sort_by(|a, b| b.1.cmp(&a.1).then(a.0.cmp(&b.0)));
+        ranking.truncate(limit);
+        ranking
+    }
+
+    /// Lists all cleanup tasks for a specific guild.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild whose tasks should be listed.
+    ///
+    /// # Returns
+    /// A vector of tuples, each containing a channel ID and its cleanup interval.
+    pub async fn list_tasks(&self, guild_id: GuildId) -> Vec<(ChannelId, Duration)> {
+        let tasks = self.tasks.read().await;
+        tasks
+            .get(&guild_id)
+            .map(|guild_tasks| {
+                guild_tasks
+                    .iter()
+                    .map(|(channel_id, task)| (*channel_id, task.interval))
+                    .collect()
+            })
+            .unwrap_or_default()
+    }
+
+    /// Returns copies of the cleanup tasks for a specific guild, sorted by channel.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild whose tasks should be returned.
+    ///
+    /// # Returns
+    /// A vector of (channel, task) pairs, including each task's schedule.
+    pub async fn guild_tasks(&self, guild_id: GuildId) -> Vec<(ChannelId, CleanupTask)> {
+        let tasks = self.tasks.read().await;
+        let mut guild_tasks: Vec<_> = tasks
+            .get(&guild_id)
+            .map(|guild_tasks| {
+                guild_tasks
+                    .iter()
+                    .map(|(channel_id, task)| (*channel_id, task.clone()))
+                    .collect()
+            })
+            .unwrap_or_default();
+        guild_tasks.sort_by_key(|(channel_id, _)| *channel_id);
+        guild_tasks
+    }
+
+    /// Returns a copy of the cleanup task for a channel.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild containing the task.
+    /// - `channel_id`: The ID of the task's channel.
+    ///
+    /// # Returns
+    /// The task, or `None` if the channel has no cleanup task.
+    pub async fn task(&self, guild_id: GuildId, channel_id: ChannelId) -> Option<CleanupTask> {
+        let tasks = self.tasks.read().await;
+        tasks
+            .get(&guild_id)
+            .and_then(|guild_tasks| guild_tasks.get(&channel_id))
+            .cloned()
+    }
+
+    /// Lists every cleanup task across all guilds.
+    ///
+    /// # Returns
+    /// A vector of (guild, channel, interval) tuples, sorted by guild and channel.
+    pub async fn all_tasks(&self) -> Vec<(GuildId, ChannelId, Duration)> {
+        let tasks = self.tasks.read().await;
+        let mut all: Vec<_> = tasks
+            .iter()
+            .flat_map(|(guild_id, guild_tasks)| {
+                guild_tasks
+                    .iter()
+                    .map(move |(channel_id, task)| (*guild_id, *channel_id, task.interval))
+            })
+            .collect();
+        all.sort();
+        all
+    }
+
+    /// Returns copies of every cleanup task across all guilds, sorted by guild
+    /// and channel.
+    pub async fn every_task(&self) -> Vec<(GuildId, ChannelId, CleanupTask)> {
+        let tasks = self.tasks.read().await;
+        let mut all: Vec<_> = tasks
+            .iter()
+            .flat_map(|(guild_id, guild_tasks)| {
+                guild_tasks
+                    .iter()
+                    .map(move |(channel_id, task)| (*guild_id, *channel_id, task.clone()))
+            })
+            .collect();
+        all.sort_by_key(|(guild_id, channel_id, _)| (*guild_id, *channel_id));
+        all
+    }
+
+    /// Returns the number of cleanup tasks for a specific guild.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild whose task count should be returned.
+    /// # Returns
+    /// The number of cleanup tasks for the specified guild.
+    ///
+    /// This method is safe to call from multiple threads as it uses a RwLock.
+    pub async fn task_count(&self, guild_id: GuildId) -> usize {
+        let tasks = self.tasks.read().await;
+        tasks
+            .get(&guild_id)
+            .map(|guild_tasks| guild_tasks.len())
+            .unwrap_or(0)
+    }
+
+    /// Returns the total number of messages deleted today across all tasks.
+    ///
+    /// Days are UTC days according to the manager's clock.
+    pub async fn purged_today(&self) -> u64 {
+        let today = self.clock.now().utc_day();
+        let tasks = self.tasks.read().await;
+        tasks
+            .values()
+            .flat_map(|guild_tasks| guild_tasks.values())
+            .map(|task| task.deleted_on(today))
+            .sum()
+    }
+
+    /// Returns the total number of cleanup tasks across all guilds.
+    pub async fn total_task_count(&self) -> usize {
+        let tasks = self.tasks.read().await;
+        tasks.values().map(|guild_tasks| guild_tasks.len()).sum()
+    }
+
+    /// Returns every task that is due according to the manager's clock.
+    /// Paused tasks are never due, and neither are tasks whose cleanup is
+    /// still running, so a long purge isn't queued again behind itself.
+    ///
+    /// # Returns
+    /// A vector of (guild, channel) pairs whose cleanup is due.
+    pub async fn due_tasks(&self) -> Vec<(GuildId, ChannelId)> {
+        let now = self.clock.now();
+        let tasks = self.tasks.read().await;
+        tasks
+            .iter()
+            .flat_map(|(guild_id, guild_tasks)| {
+                guild_tasks
+                    .iter()
+                    .filter(move |(_, task)| {
+                        task.state != TaskState::Paused
+                            && task.running.is_none()
+                            && task.is_due_at(now)
+                    })
+                    .map(move |(channel_id, _)| (*guild_id, *channel_id))
+            })
+            .collect()
+    }
+
+    /// Returns every task the scheduler should have started a while ago but
+    /// hasn't.
+    ///
+    /// A task is overdue once both its next cleanup and its last run, failed
+    /// or not, lie more than `grace` in the past and no cleanup is running.
+    /// Paused and held tasks are never overdue, and no task is while the bot
+    /// is in maintenance mode or shutting down.
+    ///
+    /// # Parameters
+    /// - `grace`: How late a cleanup may start before it counts as overdue.
+    ///
+    /// # Returns
+    /// A vector of (guild, channel, how long the cleanup has been due) triples.
+    pub async fn overdue_tasks(&self, grace: Duration) -> Vec<(GuildId, ChannelId, Duration)> {
+        if self.draining.load(Ordering::SeqCst) || lifecycle::in_maintenance() {
+            return Vec::new();
+        }
+        let now = self.clock.now();
+        let settings = self.settings.read().await;
+        let tasks = self.tasks.read().await;
+        let mut overdue: Vec<_> = tasks
+            .iter()
+            .flat_map(|(guild_id, guild_tasks)| {
+                let holds = settings.get(guild_id).map(|settings| &settings.holds);
+                guild_tasks
+                    .iter()
+                    .filter(move |(channel_id, task)| {
+                        let last_run = task.history.back().map(|run| run.at);
+                        let waiting_since = task
+                            .next_cleanup()
+                            .max(last_run.unwrap_or(task.last_cleanup));
+                        task.state != TaskState::Paused
+                            && task.running.is_none()
+                            && !holds.is_some_and(|holds| holds.contains_key(channel_id))
+                            && now >= waiting_since + grace
+                    })
+                    .map(move |(channel_id, task)| {
+                        (
+                            *guild_id,
+                            *channel_id,
+                            now.duration_since(task.next_cleanup()),
+                        )
+                    })
+            })
+            .collect();
+        overdue.sort_by_key(|(guild_id, channel_id, _)| (*guild_id, *channel_id));
+        overdue
+    }
+
+    /// Returns how many messages each task has set aside to retry, leaving out
+    /// tasks with none.
+    ///
+    /// # Returns
+    /// A vector of (guild, channel, set-aside messages) triples.
+    pub async fn retry_queues(&self) -> Vec<(GuildId, ChannelId, usize)> {
+        self.every_task()
+            .await
+            .into_iter()
+            .filter(|(_, _, task)| !task.retry_queue.is_empty())
+            .map(|(guild_id, channel_id, task)| (guild_id, channel_id, task.retry_queue.len()))
+            .collect()
+    }
+
+    /// Saves the current task map to persistent storage.
+    /// This method is called automatically by add_task and remove_task.
+    ///
+    /// # Returns
+    /// A Result indicating success or failure of the save operation.
+    ///
+    pub async fn save_tasks(&self) -> Result<()> {
+        let _lock = self.save_lock.lock().await;
+        let tasks = self.tasks.read().await;
+        let serialized = serde_json::to_string(&*tasks).map_err(EuleError::Serialization)?;
+        self.kv_store.set(&self.tasks_key, &serialized).await?;
+        Ok(())
+    }
+
+    /// Returns a guild's settings.
+    pub async fn guild_settings(&self, guild_id: GuildId) -> GuildSettings {
+        self.settings
+            .read()
+            .await
+            .get(&guild_id)
+            .cloned()
+            .unwrap_or_default()
+    }
+
+    /// Applies `update` to a guild's settings and saves them.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild whose settings to change.
+    /// - `update`: The change to make.
+    pub async fn update_guild_settings(
+        &self,
+        guild_id: GuildId,
+        update: impl FnOnce(&mut GuildSettings),
+    ) -> Result<()> {
+        let serialized = {
+            let mut settings = self.settings.write().await;
+            let guild_settings = settings.entry(guild_id).or_default();
+            update(guild_settings);
+            if *guild_settings == GuildSettings::default() {
+                settings.remove(&guild_id);
+            }
+            serde_json::to_string(&*settings).map_err(EuleError::Serialization)?
+        };
+        let _lock = self.save_lock.lock().await;
+        self.kv_store.set(&self.settings_key, &serialized).await?;
+        Ok(())
+    }
+
+    /// Caps how many messages the guild's purges may delete per hour, or lifts
+    /// the cap. Purges that use up the hour's deletions pause until the next
+    /// hour starts.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild to cap.
+    /// - `per_hour`: The deletions allowed per hour, or `None` for no cap.
+    pub async fn set_deletion_budget(
+        &self,
+        guild_id: GuildId,
+        per_hour: Option<u32>,
+    ) -> Result<()> {
+        self.update_guild_settings(guild_id, |settings| {
+            settings.deletions_per_hour = per_hour;
+        })
+        .await?;
+        self.sync_budget(guild_id, per_hour).await;
+        Ok(())
+    }
+
+    /// Returns the deletion budget the guild's purges share, if it has a cap.
+    pub async fn deletion_budget(&self, guild_id: GuildId) -> Option<Arc<DeletionBudget>> {
+        self.budgets.read().await.get(&guild_id).cloned()
+    }
+
+    /// Brings a guild's deletion budget in line with its cap. A changed cap
+    /// keeps the current window's count, so changing it doesn't reset it.
+    async fn sync_budget(&self, guild_id: GuildId, per_hour: Option<u32>) {
+        let mut budgets = self.budgets.write().await;
+        match (per_hour, budgets.get(&guild_id)) {
+            (Some(limit), Some(budget)) => budget.set_limit(limit).await,
+            (Some(limit), None) => {
+                budgets.insert(
+                    guild_id,
+                    Arc::new(DeletionBudget::new(limit, BUDGET_WINDOW)),
+                );
+            }
+            (None, _) => {
+                budgets.remove(&guild_id);
+            }
+        }
+    }
+
+    /// Places a legal hold on a channel, so nothing deletes from it until the
+    /// hold is lifted, and cancels any purge running in it.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild containing the channel.
+    /// - `channel_id`: The ID of the channel to hold.
+    /// - `by`: The member placing the hold, for the hold log.
+    /// - `reason`: Why the hold is placed, if given.
+    ///
+    /// # Returns
+    /// `true` if the hold was placed, `false` if the channel was already held.
+    pub async fn place_hold(
+        &self,
+        guild_id: GuildId,
+        channel_id: ChannelId,
+        by: UserId,
+        reason: Option<String>,
+    ) -> Result<bool> {
+        let now = self.clock.now();
+        let mut placed = false;
+        self.update_guild_settings(guild_id, |settings| {
+            if settings.holds.contains_key(&channel_id) {
+                return;
+            }
+            settings.holds.insert(
+                channel_id,
+                LegalHold {
+                    placed_by: by,
+                    placed_at: now,
+                    reason: reason.clone(),
+                },
+            );
+            record_hold(settings, channel_id, by, now, true, reason);
+            placed = true;
+        })
+        .await?;
+        if placed {
+            self.cancel_purge(guild_id, channel_id).await;
+            tracing::info!(
+                "{} placed a hold on channel {} of guild {}",
+                by,
+                obfuscate_id(channel_id.get()),
+                obfuscate_id(guild_id.get())
+            );
+        }
+        Ok(placed)
+    }
+
+    /// Lifts a channel's legal hold, so its cleanups run again.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild containing the channel.
+    /// - `channel_id`: The ID of the held channel.
+    /// - `by`: The member lifting the hold, for the hold log.
+    ///
+    /// # Returns
+    /// `true` if the hold was lifted, `false` if the channel wasn't held.
+    pub async fn lift_hold(
+        &self,
+        guild_id: GuildId,
+        channel_id: ChannelId,
+        by: UserId,
+    ) -> Result<bool> {
+        let now = self.clock.now();
+        let mut lifted = false;
+        self.update_guild_settings(guild_id, |settings| {
+            if settings.holds.remove(&channel_id).is_some() {
+                record_hold(settings, channel_id, by, now, false, None);
+                lifted = true;
+            }
+        })
+        .await?;
+        if lifted {
+            tracing::info!(
+                "{} lifted the hold on channel {} of guild {}",
+                by,
+                obfuscate_id(channel_id.get()),
+                obfuscate_id(guild_id.get())
+            );
+        }
+        Ok(lifted)
+    }
+
+    /// Returns whether a channel is under a legal hold.
+    pub async fn is_held(&self, guild_id: GuildId, channel_id: ChannelId) -> bool {
+        self.settings
+            .read()
+            .await
+            .get(&guild_id)
+            .is_some_and(|settings| settings.holds.contains_key(&channel_id))
+    }
+
+    /// Returns the state a task is in, taking legal holds and its expiry into
+    /// account.
+    ///
+    /// # Arguments
+    /// * `guild_id` - The guild the task belongs to
+    /// * `channel_id` - The task's channel
+    /// * `task` - The task, as returned by `task` or `guild_tasks`
+    pub async fn task_state(
+        &self,
+        guild_id: GuildId,
+        channel_id: ChannelId,
+        task: &CleanupTask,
+    ) -> TaskState {
+        let held = self.is_held(guild_id, channel_id).await;
+        task.state_at(self.clock.now(), held)
+    }
+
+    /// Fails with `EuleError::OnHold` if a channel is under a legal hold.
+    async fn check_hold(&self, guild_id: GuildId, channel_id: ChannelId) -> Result<()> {
+        if self.is_held(guild_id, channel_id).await {
+            return Err(EuleError::OnHold(channel_id.to_string()).into());
         }
+        Ok(())
     }
-}
 
-impl AutocleanManager {
-    pub fn new(kv_store: Arc<KvStore>) -> Self {
-        Self {
-            tasks: Arc::new(RwLock::new(HashMap::new())),
-            worker_pool: None,
-            kv_store,
-            save_lock: Arc::new(Mutex::new(())),
+    /// Creates a guild's settings record when the bot joins it, so it is
+    /// only welcomed once.
+    ///
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild the bot joined.
+    ///
+    /// # Returns
+    /// `true` if the guild had never been onboarded, `false` otherwise.
+    pub async fn onboard_guild(&self, guild_id: GuildId) -> Result<bool> {
+        let now = self.clock.now();
+        let mut joined = false;
+        self.update_guild_settings(guild_id, |settings| {
+            if settings.joined_at.is_none() {
+                settings.joined_at = Some(now);
+                joined = true;
+            }
+        })
+        .await?;
+        if joined {
+            tracing::info!("Onboarded guild {}", obfuscate_id(guild_id.get()));
         }
+        Ok(joined)
     }
 
-    /// Adds a new cleanup task for a specific channel in a guild.
+    /// Cleans a channel immediately, outside the regular schedule.
+    ///
+    /// If the channel has a cleanup task, its last cleanup time is updated and
+    /// saved. Channels under a legal hold fail with `EuleError::OnHold`.
     ///
     /// # Parameters
-    /// - `guild_id`: The ID of the guild where the task should be added.
-    /// - `channel_id`: The ID of the channel to be cleaned.
-    /// - `interval`: The time interval between cleanups.
+    /// - `api`: The Discord API client used to fetch and delete messages.
+    /// - `guild_id`: The ID of the guild containing the channel.
+    /// - `channel_id`: The ID of the channel to clean.
+    pub async fn purge_now<A: BotApi + ?Sized>(
+        &self,
+        api: &A,
+        guild_id: GuildId,
+        channel_id: ChannelId,
+    ) -> Result<()> {
+        self.check_hold(guild_id, channel_id).await?;
+        cleanup_channel_with_progress(
+            api,
+            guild_id,
+            channel_id,
+            &self.tasks,
+            &*self.clock,
+            None,
+            Some(&self.events),
+            self.deletion_budget(guild_id).await,
+        )
+        .await?;
+        self.save_tasks().await
+    }
+
+    /// Cleans a channel immediately, reporting progress as messages are deleted.
     ///
-    /// This method is safe to call from multiple threads as it uses a RwLock.
-    pub async fn add_task(
+    /// Behaves like `purge_now`, except that the running report is sent to
+    /// `progress` after every request. Forum cleanups report no progress.
+    ///
+    /// # Parameters
+    /// - `api`: The Discord API client used to fetch and delete messages.
+    /// - `guild_id`: The ID of the guild containing the channel.
+    /// - `channel_id`: The ID of the channel to clean.
+    /// - `progress`: Receives the purge's running report.
+    pub async fn purge_now_with_progress<A: BotApi + ?Sized>(
         &self,
+        api: &A,
         guild_id: GuildId,
         channel_id: ChannelId,
-        interval: Duration,
+        progress: watch::Sender<PurgeReport>,
     ) -> Result<()> {
-        {
-            let mut tasks = self.tasks.write().await;
-            tasks
-                .entry(guild_id)
-                .or_insert_with(HashMap::new)
-                .insert(channel_id, CleanupTask::new(interval).await);
-        }
-        self.save_tasks().await?;
-        let obfuscated_guild = obfuscate_id(guild_id.get());
-        let obfuscated_channel = obfuscate_id(channel_id.get());
-        tracing::info!(
-            "Added cleanup task for guild {} channel {} with interval {:?}",
-            obfuscated_guild,
-            obfuscated_channel,
-            interval
-        );
-        Ok(())
+        self.check_hold(guild_id, channel_id).await?;
+        cleanup_channel_with_progress(
+            api,
+            guild_id,
+            channel_id,
+            &self.tasks,
+            &*self.clock,
+            Some(progress),
+            Some(&self.events),
+            self.deletion_budget(guild_id).await,
+        )
+        .await?;
+        self.save_tasks().await
     }
 
-    /// Removes a cleanup task for a specific channel in a guild.
+    /// Registers a purge started from a command.
     ///
     /// # Parameters
-    /// - `guild_id`: The ID of the guild where the task should be removed.
-    /// - `channel_id`: The ID of the channel whose task should be removed.
+    /// - `channel_id`: The channel about to be purged.
     ///
     /// # Returns
-    /// `true` if a task was removed, `false` if no task was found.
-    pub async fn remove_task(&self, guild_id: GuildId, channel_id: ChannelId) -> Result<bool> {
-        let mut tasks = self.tasks.write().await;
-        let removed = if let Some(guild_tasks) = tasks.get_mut(&guild_id) {
-            guild_tasks.remove(&channel_id).is_some()
-        } else {
-            false
-        };
-        if removed {
-            self.save_tasks().await?;
-            let obfuscated_guild = obfuscate_id(guild_id.get());
-            let obfuscated_channel = obfuscate_id(channel_id.get());
-            tracing::info!(
-                "Removed cleanup task for guild {} channel {}",
-                obfuscated_guild,
-                obfuscated_channel
-            );
+    /// The token the purge should watch, or `None` if a purge started from a
+    /// command is already running in the channel.
+    pub async fn begin_purge(&self, channel_id: ChannelId) -> Option<CancelToken> {
+        let mut interactive = self.interactive.lock().await;
+        if interactive.contains_key(&channel_id) {
+            return None;
         }
-        Ok(removed)
+        let token = CancelToken::new();
+        interactive.insert(channel_id, token.clone());
+        Some(token)
     }
 
-    /// Lists all cleanup tasks for a specific guild.
+    /// Returns the channels purges started from commands are running in.
+    pub async fn interactive_purges(&self) -> Vec<ChannelId> {
+        let mut channels: Vec<_> = self.interactive.lock().await.keys().copied().collect();
+        channels.sort();
+        channels
+    }
+
+    /// Unregisters a purge started with `begin_purge` once it has finished.
+    pub async fn end_purge(&self, channel_id: ChannelId) {
+        self.interactive.lock().await.remove(&channel_id);
+    }
+
+    /// Starts a task's cleanup as a dry run, if it is meant to be one.
+    ///
+    /// The schedule moves on as if the channel had been cleaned, so the
+    /// cleanup after the dry run deletes for real. Tasks that clean forum posts
+    /// or threads, or replace their channel, skip their dry run.
     ///
     /// # Parameters
-    /// - `guild_id`: The ID of the guild whose tasks should be listed.
+    /// - `guild_id`: The ID of the guild containing the task.
+    /// - `channel_id`: The ID of the task's channel.
     ///
     /// # Returns
-    /// A vector of tuples, each containing a channel ID and its cleanup interval.
-    pub async fn list_tasks(&self, guild_id: GuildId) -> Vec<(ChannelId, Duration)> {
-        let tasks = self.tasks.read().await;
-        tasks
-            .get(&guild_id)
-            .map(|guild_tasks| {
-                guild_tasks
-                    .iter()
-                    .map(|(channel_id, task)| (*channel_id, task.interval))
-                    .collect()
-            })
-            .unwrap_or_default()
+    /// The settings to preview the purge with, or `None` if the cleanup should
+    /// run as usual.
+    pub async fn begin_dry_run(
+        &self,
+        guild_id: GuildId,
+        channel_id: ChannelId,
+    ) -> Option<PurgeOptions> {
+        let now = self.clock.now();
+        let mut tasks = self.tasks.write().await;
+        let task = tasks
+            .get_mut(&guild_id)
+            .and_then(|guild_tasks| guild_tasks.get_mut(&channel_id))?;
+        if !std::mem::take(&mut task.dry_run)
+            || task.nuke
+            || task.forum.is_some()
+            || task.threads.is_some()
+        {
+            return None;
+        }
+        let cancel = CancelToken::new();
+        task.start(cancel.clone());
+        task.last_cleanup = now;
+        Some(task.purge_options(cancel))
     }
 
-    /// Returns the number of cleanup tasks for a specific guild.
+    /// Finishes a dry run started with `begin_dry_run` and saves the tasks.
     ///
     /// # Parameters
-    /// - `guild_id`: The ID of the guild whose task count should be returned.
-    /// # Returns
-    /// The number of cleanup tasks for the specified guild.
-    ///
-    /// This method is safe to call from multiple threads as it uses a RwLock.
-    pub async fn task_count(&self, guild_id: GuildId) -> usize {
-        let tasks = self.tasks.read().await;
-        tasks
-            .get(&guild_id)
-            .map(|guild_tasks| guild_tasks.len())
-            .unwrap_or(0)
+    /// - `guild_id`: The ID of the guild containing the task.
+    /// - `channel_id`: The ID of the task's channel.
+    /// - `completed`: Whether the dry run got to report what it would delete.
+    ///   If not, the next cleanup is a dry run again.
+    pub async fn end_dry_run(
+        &self,
+        guild_id: GuildId,
+        channel_id: ChannelId,
+        completed: bool,
+    ) -> Result<()> {
+        if let Some(task) = self
+            .tasks
+            .write()
+            .await
+            .get_mut(&guild_id)
+            .and_then(|guild_tasks| guild_tasks.get_mut(&channel_id))
+        {
+            // A dry run isn't a cleanup, so it leaves no failure behind
+            task.running = None;
+            task.state = TaskState::Scheduled;
+            task.dry_run |= !completed;
+        }
+        self.save_tasks().await
     }
 
-    /// Saves the current task map to persistent storage.
-    /// This method is called automatically by add_task and remove_task.
+    /// Cancels the purge running in a channel, whether started from a command
+    /// or by the schedule.
     ///
-    /// # Returns
-    /// A Result indicating success or failure of the save operation.
+    /// # Parameters
+    /// - `guild_id`: The ID of the guild containing the channel.
+    /// - `channel_id`: The ID of the channel being purged.
     ///
-    pub async fn save_tasks(&self) -> Result<()> {
-        let _lock = self.save_lock.lock().await;
-        let tasks = self.tasks.read().await;
-        let serialized = serde_json::to_string(&*tasks).map_err(EuleError::Serialization)?;
-        self.kv_store.set("cleanup_tasks", &serialized).await?;
-        Ok(())
+    /// # Returns
+    /// `true` if a running purge was found and asked to stop.
+    pub async fn cancel_purge(&self, guild_id: GuildId, channel_id: ChannelId) -> bool {
+        let mut found = false;
+        if let Some(token) = self.interactive.lock().await.get(&channel_id) {
+            token.cancel();
+            found = true;
+        }
+        let scheduled = self
+            .tasks
+            .read()
+            .await
+            .get(&guild_id)
+            .and_then(|guild_tasks| guild_tasks.get(&channel_id))
+            .and_then(|task| task.running.clone());
+        if let Some(token) = scheduled {
+            token.cancel();
+            found = true;
+        }
+        found
+    }
+
+    /// Returns a receiver for the events of cleanups started from now on,
+    /// whether scheduled or run on demand.
+    pub fn subscribe_events(&self) -> tokio::sync::broadcast::Receiver<PurgeEvent> {
+        self.events.subscribe()
+    }
+
+    /// Returns a receiver for task changes made from now on.
+    pub fn subscribe_changes(&self) -> tokio::sync::broadcast::Receiver<TaskChange> {
+        self.changes.subscribe()
     }
 
     /// Starts the AutocleanManager, initializing the worker pool.
     ///
     /// # Parameters
-    /// - `http`: An Arc-wrapped Http client for making Discord API calls.
+    /// - `http`: The Discord client cleanups make their API calls with.
     ///
     /// This method spawns a new tokio task for scheduling cleanup operations.
     ///
-    pub async fn start(&mut self, http: Arc<Http>) {
+    pub async fn start(&mut self, http: Arc<dyn BotApi>) {
         let tasks = Arc::clone(&self.tasks);
-        let worker_pool = Arc::new(WorkerPool::new(4, http.clone(), tasks.clone()));
+        let worker_pool = Arc::new(WorkerPool::with_events(
+            4,
+            http.clone(),
+            tasks.clone(),
+            self.events.clone(),
+            Arc::clone(&self.budgets),
+            Arc::clone(&self.clock),
+        ));
         self.worker_pool = Some(Arc::clone(&worker_pool));
+        old_messages::watch(self.clone(), Arc::clone(&http));
+        let manager = self.clone();
 
         tokio::spawn(async move {
             let mut interval = tokio::time::interval(Duration::from_secs(60));
             loop {
-                interval.tick().await;
-                let tasks_read = tasks.read().await;
-                for (guild_id, guild_tasks) in tasks_read.iter() {
-                    for (channel_id, task) in guild_tasks.iter() {
-                        if task.is_due().await {
+                tokio::select! {
+                    _ = interval.tick() => {}
+                    _ = manager.wake.notified() => {
+                        tracing::info!("Scheduler woken early, checking for overdue tasks");
+                    }
+                }
+                // Tasks stay as they are, they just aren't run until it's over
+                if manager.draining.load(Ordering::SeqCst) || lifecycle::in_maintenance() {
+                    continue;
+                }
+                expiry::remove_expired(&manager, &*http).await;
+                permissions::report_lost(&manager, &*http).await;
+                failures::pause_failing(&manager, &*http).await;
+                warning::send_due_warnings(&manager, &*http).await;
+                match manager.take_due_purges().await {
+                    Ok(due) => {
+                        for (guild_id, channel_id) in due {
+                            if manager.is_held(guild_id, channel_id).await {
+                                tracing::info!(
+                                    "Dropping one-shot purge for guild {} channel {}, which is on hold",
+                                    guild_id,
+                                    channel_id
+                                );
+                                continue;
+                            }
                             tracing::info!(
-                                "Queueing cleanup task for guild {} channel {}",
+                                "Queueing one-shot purge for guild {} channel {}",
                                 guild_id,
                                 channel_id
                             );
-                            worker_pool.queue_task(*guild_id, *channel_id).await;
+                            worker_pool.queue_task(guild_id, channel_id).await;
                         }
                     }
+                    Err(e) => tracing::warn!("Failed to claim one-shot purges: {}", e),
+                }
+                for (guild_id, channel_id) in manager.due_tasks().await {
+                    // Held channels catch up once their hold is lifted
+                    if manager.is_held(guild_id, channel_id).await {
+                        continue;
+                    }
+                    if let Some(options) = manager.begin_dry_run(guild_id, channel_id).await {
+                        tracing::info!(
+                            "Starting dry run for guild {} channel {}",
+                            guild_id,
+                            channel_id
+                        );
+                        let (manager, http) = (manager.clone(), Arc::clone(&http));
+                        tokio::spawn(async move {
+                            dry_run::rehearse(&manager, &*http, guild_id, channel_id, options)
+                                .await;
+                        });
+                        continue;
+                    }
+                    tracing::info!(
+                        "Queueing cleanup task for guild {} channel {}",
+                        guild_id,
+                        channel_id
+                    );
+                    worker_pool.queue_task(guild_id, channel_id).await;
                 }
             }
         });
     }
 
+    /// Makes the scheduler check for due tasks immediately.
+    ///
+    /// Used after a long gateway disconnect so overdue cleanups catch up without
+    /// waiting for the next regular tick.
+    pub fn wake(&self) {
+        self.wake.notify_one();
+    }
+
+    /// Returns the number of purges running, whether started from a command or
+    /// by the schedule.
+    pub async fn running_purges(&self) -> usize {
+        let scheduled = self
+            .tasks
+            .read()
+            .await
+            .values()
+            .flat_map(HashMap::values)
+            .filter(|task| task.running.is_some())
+            .count();
+        scheduled + self.interactive.lock().await.len()
+    }
+
+    /// Cancels every running purge, whether started from a command or by the
+    /// schedule.
+    async fn cancel_all_purges(&self) {
+        for token in self.interactive.lock().await.values() {
+            token.cancel();
+        }
+        for task in self.tasks.read().await.values().flat_map(HashMap::values) {
+            if let Some(token) = &task.running {
+                token.cancel();
+            }
+        }
+    }
+
+    /// Prepares the manager for the bot to stop: stops queueing cleanups, waits
+    /// for running purges to finish and saves the tasks.
+    ///
+    /// Purges still running after `timeout` are cancelled, so they stop before
+    /// their next delete request.
+    ///
+    /// # Parameters
+    /// - `timeout`: How long to wait for running purges to finish.
+    ///
+    /// # Returns
+    /// The number of purges that had to be cancelled.
+    pub async fn drain(&self, timeout: Duration) -> Result<usize> {
+        self.draining.store(true, Ordering::SeqCst);
+        let started = tokio::time::Instant::now();
+        let mut cancelled = 0;
+        loop {
+            let running = self.running_purges().await;
+            if running == 0 {
+                break;
+            }
+            if cancelled == 0 && started.elapsed() >= timeout {
+                tracing::warn!("Cancelling {} purges to shut down", running);
+                self.cancel_all_purges().await;
+                cancelled = running;
+            }
+            if started.elapsed() >= timeout + DRAIN_GRACE {
+                tracing::warn!("Shutting down with {} purges still stopping", running);
+                break;
+            }
+            tokio::time::sleep(DRAIN_POLL).await;
+        }
+        self.save_tasks().await?;
+        Ok(cancelled)
+    }
+
     /// Shuts down the AutocleanManager, stopping the worker pool.
     ///
     /// This method is safe to call from multiple threads, but should only be called once.
@@ -233,22 +2731,39 @@ impl AutocleanManager {
     /// A Result indicating success or failure of the load operation.
     ///
     pub async fn load_tasks(&self) -> Result<()> {
-        let serialized = self.kv_store.get("cleanup_tasks").await?;
+        let serialized = self.kv_store.get(&self.tasks_key).await?;
         if let Some(serialized) = serialized {
             let loaded_tasks: HashMap<GuildId, HashMap<ChannelId, CleanupTask>> =
                 serde_json::from_str(&serialized).map_err(EuleError::Serialization)?;
             let mut tasks = self.tasks.write().await;
             *tasks = loaded_tasks;
+            for task in tasks.values_mut().flat_map(HashMap::values_mut) {
+                task.restore();
+            }
             tracing::info!("Loaded {} guild tasks from persistent storage", tasks.len());
         } else {
             tracing::info!("No tasks found in persistent storage");
         }
+        if let Some(serialized) = self.kv_store.get(&self.settings_key).await? {
+            let loaded: HashMap<GuildId, GuildSettings> =
+                serde_json::from_str(&serialized).map_err(EuleError::Serialization)?;
+            for (guild_id, settings) in &loaded {
+                self.sync_budget(*guild_id, settings.deletions_per_hour)
+                    .await;
+            }
+            *self.settings.write().await = loaded;
+        }
+        if let Some(serialized) = self.kv_store.get(&self.one_shots_key).await? {
+            let loaded: HashMap<GuildId, HashMap<ChannelId, SerializableInstant>> =
+                serde_json::from_str(&serialized).map_err(EuleError::Serialization)?;
+            *self.one_shots.write().await = loaded;
+        }
         Ok(())
     }
 
     pub fn new_worker_pool(
         num_workers: usize,
-        http: Arc<Http>,
+        http: Arc<dyn BotApi>,
         tasks: Arc<RwLock<HashMap<GuildId, HashMap<ChannelId, CleanupTask>>>>,
     ) -> Arc<WorkerPool> {
         Arc::new(WorkerPool::new(num_workers, http, tasks))
@@ -262,10 +2777,33 @@ impl AutocleanManager {
     }
 }
 
+/// Adds an entry to a guild's hold log, dropping the oldest beyond `MAX_HOLD_LOG`.
+fn record_hold(
+    settings: &mut GuildSettings,
+    channel: ChannelId,
+    by: UserId,
+    at: SerializableInstant,
+    placed: bool,
+    reason: Option<String>,
+) {
+    settings.hold_log.push_back(HoldRecord {
+        channel,
+        by,
+        at,
+        placed,
+        reason,
+    });
+    while settings.hold_log.len() > MAX_HOLD_LOG {
+        settings.hold_log.pop_front();
+    }
+}
+
 /// Performs the actual cleanup of messages in a channel.
 ///
+/// Purges the channel with the shared purge engine and records the cleanup time.
+///
 /// # Parameters
-/// - `http`: The Http client for making Discord API calls.
+/// - `api`: The Discord API client used to fetch and delete messages.
 /// - `guild_id`: The ID of the guild where the cleanup is occurring.
 /// - `channel_id`: The ID of the channel to be cleaned.
 /// - `tasks`: The shared task map for updating task status.
@@ -276,11 +2814,102 @@ impl AutocleanManager {
 /// # Concurrency
 /// This function is designed to be called concurrently by multiple workers.
 ///
-pub async fn cleanup_channel(
-    http: &Http,
+pub async fn cleanup_channel<A: BotApi + ?Sized>(
+    api: &A,
+    guild_id: GuildId,
+    channel_id: ChannelId,
+    tasks: &Arc<RwLock<HashMap<GuildId, HashMap<ChannelId, CleanupTask>>>>,
+) -> Result<()> {
+    cleanup_channel_with_progress(
+        api,
+        guild_id,
+        channel_id,
+        tasks,
+        &SystemClock,
+        None,
+        None,
+        None,
+    )
+    .await
+}
+
+/// Marks a cleanup that panicked as failed, as if it had returned an error.
+///
+/// The panic unwound past the bookkeeping at the end of
+/// `cleanup_channel_with_progress`, which would otherwise leave the task
+/// running for good. The failure is published like any other, so operators
+/// are alerted to it and the task is paused if it keeps panicking.
+///
+/// # Parameters
+/// - `guild_id`: The ID of the guild where the cleanup was occurring.
+/// - `channel_id`: The ID of the channel that was being cleaned.
+/// - `tasks`: The shared task map for updating task status.
+/// - `clock`: The clock the failure is recorded at.
+/// - `events`: Receives the failure, if given.
+/// - `panic`: The message the cleanup panicked with.
+/// - `duration`: How long the cleanup ran before it panicked.
+pub(crate) async fn fail_panicked_cleanup(
+    guild_id: GuildId,
+    channel_id: ChannelId,
+    tasks: &Arc<RwLock<HashMap<GuildId, HashMap<ChannelId, CleanupTask>>>>,
+    clock: &dyn Clock,
+    events: Option<&PurgeEvents>,
+    panic: String,
+    duration: Duration,
+) {
+    let error = EuleError::Panicked(panic);
+    let now = clock.now();
+    if let Some(task) = tasks
+        .write()
+        .await
+        .get_mut(&guild_id)
+        .and_then(|guild_tasks| guild_tasks.get_mut(&channel_id))
+    {
+        task.finish(Some(FailureKind::of(&error)));
+        task.record_run(RunRecord {
+            at: now,
+            deleted: 0,
+            cancelled: false,
+            error: Some(error.to_string()),
+        });
+    }
+    if let Some(events) = events {
+        events.publish(PurgeEvent {
+            kind: PurgeEventKind::Failed,
+            guild_id,
+            channel_id,
+            at: now,
+            deleted: 0,
+            blocked_links: 0,
+            cancelled: false,
+            duration_ms: duration.as_millis() as u64,
+            error: Some(error.to_string()),
+            missing_permissions: false,
+        });
+    }
+}
+
+/// Performs a cleanup like `cleanup_channel`, sending the running report to
+/// `progress` after every request of a message purge.
+///
+/// # Parameters
+/// - `api`: The Discord API client used to fetch and delete messages.
+/// - `guild_id`: The ID of the guild where the cleanup is occurring.
+/// - `channel_id`: The ID of the channel to be cleaned.
+/// - `tasks`: The shared task map for updating task status.
+/// - `clock`: The clock the cleanup's events and run are recorded at.
+/// - `progress`: Receives the purge's running report, if given.
+/// - `events`: Receives events as the cleanup starts and finishes, if given.
+/// - `budget`: The guild's deletion budget, if it caps its deletions.
+pub async fn cleanup_channel_with_progress<A: BotApi + ?Sized>(
+    api: &A,
     guild_id: GuildId,
     channel_id: ChannelId,
     tasks: &Arc<RwLock<HashMap<GuildId, HashMap<ChannelId, CleanupTask>>>>,
+    clock: &dyn Clock,
+    progress: Option<watch::Sender<PurgeReport>>,
+    events: Option<&PurgeEvents>,
+    budget: Option<Arc<DeletionBudget>>,
 ) -> Result<()> {
     let obfuscated_guild = obfuscate_id(guild_id.get());
     let obfuscated_channel = obfuscate_id(channel_id.get());
@@ -290,71 +2919,227 @@ pub async fn cleanup_channel(
         obfuscated_guild
     );
 
-    let messages = match channel_id
-        .messages(http, poise::serenity_prelude::GetMessages::new().limit(100))
-        .await
-    {
-        Ok(msgs) => msgs,
-        Err(e) => {
-            tracing::error!(
-                "Failed to fetch messages for channel {} in guild {}: {:?}",
-                obfuscated_channel,
-                obfuscated_guild,
-                e
-            );
-            return Err(EuleError::from(e).into());
+    let cancel = CancelToken::new();
+    // The progress is kept with the task, so it can be looked at while it runs
+    let (progress, watched) = match progress {
+        Some(progress) => {
+            let watched = progress.subscribe();
+            (progress, watched)
         }
+        None => watch::channel(PurgeReport::default()),
     };
+    let (nuke, forum, threads, starboard, retry_queue, options) = tasks
+        .write()
+        .await
+        .get_mut(&guild_id)
+        .and_then(|guild_tasks| guild_tasks.get_mut(&channel_id))
+        .map(|task| {
+            task.start(cancel.clone());
+            task.progress = Some(watched);
+            let options = PurgeOptions {
+                progress: Some(progress),
+                ..task.purge_options(cancel.clone())
+            };
+            // Tasks saved before such settings were refused are purged instead
+            let conflicts = task.nuke_conflicts();
+            if !conflicts.is_empty() {
+                tracing::warn!(
+                    "Purging channel {} of guild {} instead of replacing it, since it keeps {}",
+                    obfuscated_channel,
+                    obfuscated_guild,
+                    conflicts.join(", ")
+                );
+            }
+            (
+                task.nuke && conflicts.is_empty(),
+                task.forum.clone(),
+                task.threads.clone(),
+                task.starboard.clone(),
+                task.retry_queue.clone(),
+                options,
+            )
+        })
+        .unwrap_or_default();
+    // Purges of channels without a task count against the budget too
+    let mut options = PurgeOptions { budget, ..options };
+    // Links to phishing sites go whatever the task keeps
+    let blocked = phishing::blocked_domains();
+    if !blocked.is_empty() {
+        options.filter = options.filter.delete_blocked_links(blocked);
+    }
 
-    tracing::info!(
-        "Found {} messages to delete in channel {} of guild {}",
-        messages.len(),
-        obfuscated_channel,
-        obfuscated_guild
-    );
-
-    let rate_limiter = RateLimiter::new(5, Duration::from_secs(10));
-    let mut deleted_count = 0;
-
-    for chunk in messages.chunks(100) {
-        if rate_limiter.check().await.is_err() {
-            tracing::warn!("Rate limit reached, waiting before next deletion attempt");
-            tokio::time::sleep(Duration::from_secs(2)).await;
+    let started = Instant::now();
+    let event = |kind, deleted, blocked_links, cancelled, error: Option<&EuleError>| {
+        if let Some(events) = events {
+            events.publish(PurgeEvent {
+                kind,
+                guild_id,
+                channel_id,
+                at: clock.now(),
+                deleted,
+                blocked_links,
+                cancelled,
+                duration_ms: started.elapsed().as_millis() as u64,
+                error: error.map(ToString::to_string),
+                missing_permissions: matches!(error, Some(EuleError::MissingPermissions(_))),
+            });
         }
-        match channel_id.delete_messages(http, chunk).await {
-            Ok(_) => {
-                deleted_count += chunk.len();
+    };
+    event(PurgeEventKind::Started, 0, 0, false, None);
+
+    // A nuked channel lives on under the ID of its copy
+    let mut replacement = None;
+    let mut requeue = None;
+    let mut blocked_links = 0;
+    let result = match (forum, threads) {
+        (Some(forum), _) => prune_forum(api, channel_id, &forum).await.map(|report| {
+            tracing::info!(
+                "Forum cleanup: archived {} and deleted {} posts in channel {} of guild {}",
+                report.archived,
+                report.deleted,
+                obfuscated_channel,
+                obfuscated_guild
+            );
+            (report.deleted, false)
+        }),
+        (None, Some(threads)) => prune_threads(api, channel_id, &threads)
+            .await
+            .map(|report| {
                 tracing::info!(
-                    "Deleted {} messages in channel {} of guild {}",
-                    chunk.len(),
+                    "Thread cleanup: archived {} and deleted {} threads in channel {} of guild {}",
+                    report.archived,
+                    report.deleted,
                     obfuscated_channel,
                     obfuscated_guild
                 );
-            }
-            Err(e) => {
-                tracing::error!(
-                    "Error deleting messages in channel {} of guild {}: {:?}",
-                    obfuscated_channel,
-                    obfuscated_guild,
-                    e
-                );
-                return Err(EuleError::from(e).into());
-            }
+                (report.deleted, false)
+            }),
+        (None, None) if nuke => nuke_channel(api, channel_id).await.map(|new_channel| {
+            tracing::info!(
+                "Replaced channel {} of guild {} with an empty copy",
+                obfuscated_channel,
+                obfuscated_guild
+            );
+            replacement = Some(new_channel);
+            (0, false)
+        }),
+        (None, None) => {
+            let purge = async {
+                let mut options = options;
+                if let Some(starboard) = starboard.and_then(|starboard| starboard.channel) {
+                    // A starboard that can't be read fails the run rather than risk its highlights
+                    options.filter = options
+                        .filter
+                        .keep_messages(starboarded_messages(api, starboard).await?);
+                }
+                // Messages set aside last time go first, so they aren't held up by new ones
+                let retried = retry_deletes(api, retry_queue, &options).await?;
+                if retried.given_up > 0 {
+                    tracing::warn!(
+                        "Gave up on deleting {} messages in channel {} of guild {}",
+                        retried.given_up,
+                        obfuscated_channel,
+                        obfuscated_guild
+                    );
+                }
+                // Retried messages count against the run's cap
+                options.max_deletions = options
+                    .max_deletions
+                    .map(|max| max.saturating_sub(retried.deleted));
+                let deferred = DeferredDeletes::default();
+                options.deferred = Some(deferred.clone());
+                let mut report = purge_channel(api, channel_id, &options).await?;
+                report.deleted += retried.deleted;
+                let queue = merge_deferred(retried.remaining, deferred.take());
+                Ok::<_, EuleError>((report, queue))
+            };
+            purge.await.map(|(report, queue)| {
+                requeue = Some(queue);
+                blocked_links = report.blocked_links;
+                if report.cancelled {
+                    tracing::info!(
+                        "Cleanup of channel {} in guild {} cancelled after {} messages",
+                        obfuscated_channel,
+                        obfuscated_guild,
+                        report.deleted
+                    );
+                }
+                if report.capped {
+                    tracing::info!(
+                        "Cleanup of channel {} in guild {} stopped at its cap of {} messages, leaving the rest for the next run",
+                        obfuscated_channel,
+                        obfuscated_guild,
+                        report.deleted
+                    );
+                }
+                (report.deleted, report.cancelled)
+            })
         }
-    }
+    };
 
-    // Update last_cleanup time
-    let mut tasks_write = tasks.write().await;
-    if let Some(guild_tasks) = tasks_write.get_mut(&guild_id) {
-        if let Some(task) = guild_tasks.get_mut(&channel_id) {
-            task.last_cleanup = SerializableInstant::now();
+    let now = clock.now();
+    {
+        let mut tasks = tasks.write().await;
+        let task = match (tasks.get_mut(&guild_id), replacement) {
+            (Some(guild_tasks), Some(new_channel)) => guild_tasks
+                .remove(&channel_id)
+                .map(|task| guild_tasks.entry(new_channel).or_insert(task)),
+            (Some(guild_tasks), None) => guild_tasks.get_mut(&channel_id),
+            (None, _) => None,
+        };
+        if let Some(task) = task {
+            task.finish(result.as_ref().err().map(FailureKind::of));
+            let (deleted, cancelled) = result.as_ref().copied().unwrap_or_default();
+            task.record_run(RunRecord {
+                at: now,
+                deleted,
+                cancelled,
+                error: result.as_ref().err().map(ToString::to_string),
+            });
+            if result.is_ok() {
+                task.last_cleanup = now;
+                task.record_deleted(deleted, now.utc_day());
+                if let Some(mut queue) = requeue {
+                    if queue.len() > MAX_RETRY_QUEUE {
+                        tracing::warn!(
+                            "Dropping {} set-aside messages in channel {} of guild {} over the cap",
+                            queue.len() - MAX_RETRY_QUEUE,
+                            obfuscated_channel,
+                            obfuscated_guild
+                        );
+                        queue.drain(..queue.len() - MAX_RETRY_QUEUE);
+                    }
+                    task.retry_queue = queue;
+                }
+            }
         }
     }
+    let deleted = match result {
+        Ok((deleted, cancelled)) => {
+            event(
+                PurgeEventKind::Completed,
+                deleted,
+                blocked_links,
+                cancelled,
+                None,
+            );
+            deleted
+        }
+        Err(e) => {
+            event(PurgeEventKind::Failed, 0, 0, false, Some(&e));
+            tracing::error!(
+                "Error cleaning channel {} of guild {}: {:?}",
+                obfuscated_channel,
+                obfuscated_guild,
+                e
+            );
+            return Err(e.into());
+        }
+    };
 
     tracing::info!(
-        "Cleanup completed. Deleted {} out of {} messages in channel {} of guild {}",
-        deleted_count,
-        messages.len(),
+        "Cleanup completed. Deleted {} messages in channel {} of guild {}",
+        deleted,
         obfuscated_channel,
         obfuscated_guild
     );
@@ -0,0 +1,114 @@
+//! Discord AutoMod rules the bot manages.
+//!
+//! Cleanups remove unwanted messages after the fact; AutoMod keeps them from
+//! being posted at all. `/automod` sets up one rule per filter, so both are
+//! configured through the bot: blocked keywords, Discord invite links, and
+//! links to the domains of the bot's phishing feed. Each rule blocks matching
+//! messages and, if the guild has a log channel, reports them there.
+//!
+//! The bot remembers the rules it created by ID, so they can be renamed in the
+//! server settings. A rule deleted there is created anew the next time it is
+//! set up.
+
+use crate::purge::BlockedDomains;
+use poise::serenity_prelude::{
+    automod::{Action, Trigger},
+    ChannelId,
+};
+use serde::{Deserialize, Serialize};
+use std::collections::BTreeSet;
+
+/// The most keywords an AutoMod rule can hold.
+pub const MAX_KEYWORDS: usize = 1000;
+/// The longest keyword an AutoMod rule can hold, wildcards included.
+pub const MAX_KEYWORD_LENGTH: usize = 60;
+/// Matches Discord invite links.
+pub const INVITE_PATTERN: &str = r"(discord\.gg|discord(app)?\.com/invite)/[a-z0-9-]+";
+
+/// A rule the bot manages.
+#[derive(Serialize, Deserialize, Clone, Copy, Debug, PartialEq, Eq, PartialOrd, Ord)]
+#[serde(rename_all = "snake_case")]
+pub enum AutomodRule {
+    /// Blocks messages containing any of the guild's keywords.
+    Keywords,
+    /// Blocks Discord invite links.
+    Invites,
+    /// Blocks links to the phishing feed's domains.
+    Phishing,
+}
+
+impl AutomodRule {
+    /// Returns the name the rule is created with.
+    pub fn name(self) -> &'static str {
+        match self {
+            AutomodRule::Keywords => "Eule: blocked keywords",
+            AutomodRule::Invites => "Eule: invite links",
+            AutomodRule::Phishing => "Eule: phishing links",
+        }
+    }
+
+    /// Returns the trigger of the rule.
+    ///
+    /// # Parameters
+    /// - `keywords`: The keywords the rule matches, as made by
+    ///   `keyword_filters` or `phishing_filters`. Unused for invite links.
+    pub fn trigger(self, keywords: Vec<String>) -> Trigger {
+        match self {
+            AutomodRule::Keywords | AutomodRule::Phishing => Trigger::Keyword {
+                strings: keywords,
+                regex_patterns: Vec::new(),
+                allow_list: Vec::new(),
+            },
+            AutomodRule::Invites => Trigger::Keyword {
+                strings: Vec::new(),
+                regex_patterns: vec![INVITE_PATTERN.to_string()],
+                allow_list: Vec::new(),
+            },
+        }
+    }
+}
+
+/// Returns what a rule does with a matching message: block it, and report it
+/// in the log channel if there is one.
+pub fn actions(log_channel: Option<ChannelId>) -> Vec<Action> {
+    let mut actions = vec![Action::BlockMessage {
+        custom_message: None,
+    }];
+    actions.extend(log_channel.map(Action::Alert));
+    actions
+}
+
+/// Turns keywords into AutoMod keywords that match anywhere in a message,
+/// like the bot's own keywords do.
+///
+/// Keywords too long for AutoMod are dropped, and only the first
+/// `MAX_KEYWORDS` are kept.
+///
+/// # Examples
+///
+/// ```
+/// use eule::tasks::automod::keyword_filters;
+///
+/// assert_eq!(keyword_filters(["Spam", "spam", ""]), vec!["*spam*"]);
+/// ```
+pub fn keyword_filters(keywords: impl IntoIterator<Item = impl AsRef<str>>) -> Vec<String> {
+    let mut seen = BTreeSet::new();
+    keywords
+        .into_iter()
+        .map(|keyword| keyword.as_ref().trim().to_lowercase())
+        .filter(|keyword| !keyword.is_empty() && keyword.len() + 2 <= MAX_KEYWORD_LENGTH)
+        .filter(|keyword| seen.insert(keyword.clone()))
+        .map(|keyword| format!("*{}*", keyword))
+        .take(MAX_KEYWORDS)
+        .collect()
+}
+
+/// Turns the phishing feed's domains into AutoMod keywords, in alphabetical
+/// order so a rule only changes when the feed does.
+///
+/// Feeds with more than `MAX_KEYWORDS` domains are cut short; the bot still
+/// deletes links to the rest.
+pub fn phishing_filters(domains: &BlockedDomains) -> Vec<String> {
+    let sorted: BTreeSet<&str> = domains.iter().collect();
+    keyword_filters(sorted)
+}
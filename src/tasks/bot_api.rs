@@ -0,0 +1,186 @@
+//! The Discord calls the bot makes besides purging.
+//!
+//! `DiscordApi` only covers what the purge engine needs. Replacing channels,
+//! messaging members, and checking the bot's own permissions are the bot's
+//! business, so they live in `BotApi`, which builds on it.
+
+use crate::{
+    error::EuleError,
+    purge::{map_http_error, DiscordApi, SharedHttp},
+};
+use async_trait::async_trait;
+use poise::serenity_prelude::{
+    ChannelId, CreateChannel, CreateMessage, GuildId, Http, Permissions, UserId,
+};
+use std::sync::Arc;
+
+/// The Discord REST calls the bot needs on top of cleaning channels.
+#[async_trait]
+pub trait BotApi: DiscordApi {
+    /// Creates an empty copy of a channel, with its name, topic, permissions,
+    /// category, and position, and returns the copy's ID.
+    async fn clone_channel(&self, channel_id: ChannelId) -> Result<ChannelId, EuleError>;
+
+    /// Deletes a channel and all of its messages.
+    async fn delete_channel(&self, channel_id: ChannelId) -> Result<(), EuleError>;
+
+    /// Sends a direct message to the owner of a guild.
+    async fn message_owner(&self, guild_id: GuildId, content: &str) -> Result<(), EuleError>;
+
+    /// Sends a direct message to a user.
+    async fn message_user(&self, user_id: UserId, content: &str) -> Result<(), EuleError>;
+
+    /// Works out the permissions the bot holds in a server channel, with its
+    /// roles and the channel's overwrites applied. A channel the bot can't
+    /// see at all yields no permissions.
+    async fn bot_permissions(&self, channel_id: ChannelId) -> Result<Permissions, EuleError>;
+}
+
+#[async_trait]
+impl BotApi for Http {
+    async fn clone_channel(&self, channel_id: ChannelId) -> Result<ChannelId, EuleError> {
+        let Some(channel) = channel_id
+            .to_channel(self)
+            .await
+            .map_err(map_http_error)?
+            .guild()
+        else {
+            return Err(EuleError::UnsupportedChannel(
+                "only server channels can be cloned".to_string(),
+            ));
+        };
+
+        let mut builder = CreateChannel::new(channel.name.clone())
+            .kind(channel.kind)
+            .permissions(channel.permission_overwrites.clone())
+            .position(channel.position)
+            .nsfw(channel.nsfw);
+        if let Some(topic) = &channel.topic {
+            builder = builder.topic(topic);
+        }
+        if let Some(parent_id) = channel.parent_id {
+            builder = builder.category(parent_id);
+        }
+        if let Some(slowmode) = channel.rate_limit_per_user {
+            builder = builder.rate_limit_per_user(slowmode);
+        }
+        if let Some(bitrate) = channel.bitrate {
+            builder = builder.bitrate(bitrate);
+        }
+        if let Some(user_limit) = channel.user_limit {
+            builder = builder.user_limit(user_limit);
+        }
+        channel
+            .guild_id
+            .create_channel(self, builder)
+            .await
+            .map(|clone| clone.id)
+            .map_err(map_http_error)
+    }
+
+    async fn delete_channel(&self, channel_id: ChannelId) -> Result<(), EuleError> {
+        channel_id
+            .delete(self)
+            .await
+            .map(|_| ())
+            .map_err(map_http_error)
+    }
+
+    async fn message_owner(&self, guild_id: GuildId, content: &str) -> Result<(), EuleError> {
+        let guild = guild_id
+            .to_partial_guild(self)
+            .await
+            .map_err(map_http_error)?;
+        guild
+            .owner_id
+            .direct_message(self, CreateMessage::new().content(content))
+            .await
+            .map(|_| ())
+            .map_err(map_http_error)
+    }
+
+    async fn message_user(&self, user_id: UserId, content: &str) -> Result<(), EuleError> {
+        user_id
+            .direct_message(self, CreateMessage::new().content(content))
+            .await
+            .map(|_| ())
+            .map_err(map_http_error)
+    }
+
+    async fn bot_permissions(&self, channel_id: ChannelId) -> Result<Permissions, EuleError> {
+        let channel = match channel_id.to_channel(self).await.map_err(map_http_error) {
+            Ok(channel) => channel,
+            // Without View Channel, fetching the channel is forbidden as well
+            Err(EuleError::MissingPermissions(_)) => return Ok(Permissions::empty()),
+            Err(e) => return Err(e),
+        };
+        let Some(channel) = channel.guild() else {
+            return Err(EuleError::UnsupportedChannel(
+                "only server channels have permissions".to_string(),
+            ));
+        };
+        let guild = channel
+            .guild_id
+            .to_partial_guild(self)
+            .await
+            .map_err(map_http_error)?;
+        let bot = self.get_current_user().await.map_err(map_http_error)?;
+        let member = guild.member(self, bot.id).await.map_err(map_http_error)?;
+        Ok(guild.user_permissions_in(&channel, &member))
+    }
+}
+
+#[async_trait]
+impl<T: BotApi + ?Sized> BotApi for Arc<T> {
+    async fn clone_channel(&self, channel_id: ChannelId) -> Result<ChannelId, EuleError> {
+        (**self).clone_channel(channel_id).await
+    }
+
+    async fn delete_channel(&self, channel_id: ChannelId) -> Result<(), EuleError> {
+        (**self).delete_channel(channel_id).await
+    }
+
+    async fn message_owner(&self, guild_id: GuildId, content: &str) -> Result<(), EuleError> {
+        (**self).message_owner(guild_id, content).await
+    }
+
+    async fn message_user(&self, user_id: UserId, content: &str) -> Result<(), EuleError> {
+        (**self).message_user(user_id, content).await
+    }
+
+    async fn bot_permissions(&self, channel_id: ChannelId) -> Result<Permissions, EuleError> {
+        (**self).bot_permissions(channel_id).await
+    }
+}
+
+#[async_trait]
+impl BotApi for SharedHttp {
+    async fn clone_channel(&self, channel_id: ChannelId) -> Result<ChannelId, EuleError> {
+        // The channel stands in for its own copy, which is never deleted
+        if self.simulates_deletes() {
+            tracing::info!("Dry run: would copy channel {:x}", channel_id.get());
+            return Ok(channel_id);
+        }
+        self.current().clone_channel(channel_id).await
+    }
+
+    async fn delete_channel(&self, channel_id: ChannelId) -> Result<(), EuleError> {
+        if self.simulates_deletes() {
+            tracing::info!("Dry run: would delete channel {:x}", channel_id.get());
+            return Ok(());
+        }
+        self.current().delete_channel(channel_id).await
+    }
+
+    async fn message_owner(&self, guild_id: GuildId, content: &str) -> Result<(), EuleError> {
+        self.current().message_owner(guild_id, content).await
+    }
+
+    async fn message_user(&self, user_id: UserId, content: &str) -> Result<(), EuleError> {
+        self.current().message_user(user_id, content).await
+    }
+
+    async fn bot_permissions(&self, channel_id: ChannelId) -> Result<Permissions, EuleError> {
+        self.current().bot_permissions(channel_id).await
+    }
+}
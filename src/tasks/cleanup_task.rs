@@ -1,6 +1,131 @@
-use crate::utils::serializable_instant::SerializableInstant;
+use crate::{
+    error::EuleError,
+    i18n::{self, Language},
+    purge::{
+        CancelToken, DeletionOrder, ForumOptions, MessageFilter, PurgeOptions, PurgeReport,
+        RetryEntry, StarboardOptions, ThreadOptions,
+    },
+    utils::{
+        clock::{Clock, SystemClock},
+        serializable_instant::SerializableInstant,
+    },
+};
+use poise::serenity_prelude::{self as serenity, ChannelId, Permissions, RoleId, UserId};
 use serde::{Deserialize, Serialize};
-use tokio::time::Duration;
+use std::collections::{BTreeSet, VecDeque};
+use tokio::{sync::watch, time::Duration};
+
+/// The number of past runs kept in a task's history.
+pub const MAX_HISTORY: usize = 20;
+/// The number of days of deletion counts kept per task.
+pub const STATS_DAYS: u64 = 90;
+/// The number of cleanups in a row that may fail before a task is paused.
+pub const MAX_CONSECUTIVE_FAILURES: u32 = 5;
+/// The most set-aside messages a task keeps to retry.
+pub const MAX_RETRY_QUEUE: usize = 1000;
+/// The most labels a task can have.
+pub const MAX_LABELS: usize = 10;
+
+/// The number of messages a task deleted on one day.
+#[derive(Serialize, Deserialize, Clone, Copy, Debug, PartialEq, Eq)]
+pub struct DayTally {
+    /// The UTC day, as returned by `SerializableInstant::utc_day`.
+    pub day: u64,
+    /// The number of messages or forum posts deleted that day.
+    pub deleted: u64,
+}
+
+/// A message posted in a channel shortly before it is cleaned.
+#[derive(Serialize, Deserialize, Clone, Debug, PartialEq, Eq)]
+pub struct PurgeWarning {
+    /// How long before each cleanup the warning is posted.
+    pub lead: Duration,
+    /// The role mentioned in the warning, so its members are notified.
+    #[serde(default)]
+    pub role: Option<RoleId>,
+}
+
+/// Where a task is in its life.
+///
+/// `Scheduled`, `Running`, `Failed` and `Paused` are saved with the task and
+/// change as its cleanups run. `OnHold` and `Expired` come from outside the
+/// task's runs, the guild's legal holds and the task's expiry, so they are
+/// only worked out by `CleanupTask::state_at`.
+#[derive(Serialize, Deserialize, Clone, Copy, Debug, Default, PartialEq, Eq)]
+#[serde(rename_all = "snake_case")]
+pub enum TaskState {
+    /// Waiting for its next cleanup.
+    #[default]
+    Scheduled,
+    /// A cleanup is in progress.
+    Running,
+    /// The last cleanup failed. The next one runs when it is due.
+    Failed,
+    /// Paused after `MAX_CONSECUTIVE_FAILURES` failed cleanups in a row, and
+    /// not run until it is resumed.
+    Paused,
+    /// Past its expiry, and removed on the scheduler's next pass.
+    Expired,
+    /// The channel is under a legal hold, so cleanups wait until it's lifted.
+    OnHold,
+}
+
+impl TaskState {
+    /// Describes the state in a few words, for task listings.
+    pub fn describe(self, language: Language) -> &'static str {
+        let key = match self {
+            TaskState::Scheduled => "task.state_scheduled",
+            TaskState::Running => "task.state_running",
+            TaskState::Failed => "task.state_failed",
+            TaskState::Paused => "task.state_paused",
+            TaskState::Expired => "task.state_expired",
+            TaskState::OnHold => "task.state_on_hold",
+        };
+        i18n::raw(language, key)
+    }
+}
+
+/// What kept a task's cleanups from working.
+#[derive(Serialize, Deserialize, Clone, Copy, Debug, PartialEq, Eq)]
+#[serde(rename_all = "snake_case")]
+pub enum FailureKind {
+    /// The bot lacks permissions in the channel.
+    MissingPermissions,
+    /// The channel no longer exists.
+    ChannelGone,
+    /// Anything else, such as Discord errors that keep coming back.
+    Other,
+}
+
+impl FailureKind {
+    /// Works out what an error that failed a cleanup says about the channel.
+    pub fn of(error: &EuleError) -> Self {
+        match error {
+            EuleError::MissingPermissions(_) => FailureKind::MissingPermissions,
+            EuleError::DiscordApi(serenity::Error::Http(e))
+                if e.status_code().is_some_and(|status| status.as_u16() == 404) =>
+            {
+                FailureKind::ChannelGone
+            }
+            _ => FailureKind::Other,
+        }
+    }
+}
+
+/// The outcome of a single cleanup run.
+#[derive(Serialize, Deserialize, Clone, Debug, PartialEq, Eq)]
+pub struct RunRecord {
+    /// When the run finished.
+    pub at: SerializableInstant,
+    /// The number of messages or forum posts deleted.
+    pub deleted: usize,
+    /// Whether the run was cancelled before it finished.
+    #[serde(default)]
+    pub cancelled: bool,
+    /// The error that ended the run, if it failed.
+    #[serde(default)]
+    pub error: Option<String>,
+}
 
 /// Represents a single cleanup task for a channel.
 #[derive(Serialize, Deserialize, Clone)]
@@ -9,6 +134,143 @@ pub struct CleanupTask {
     pub interval: Duration,
     /// The time of the last cleanup.
     pub last_cleanup: SerializableInstant,
+    /// Whether the channel's threads are cleaned as well.
+    #[serde(default)]
+    pub include_threads: bool,
+    /// For forum channels, which posts to archive or delete instead of purging messages.
+    #[serde(default)]
+    pub forum: Option<ForumOptions>,
+    /// Which threads to archive or delete instead of purging messages, if any.
+    #[serde(default)]
+    pub threads: Option<ThreadOptions>,
+    /// Whether the channel topic is kept suffixed with the next cleanup time.
+    #[serde(default)]
+    pub show_in_topic: bool,
+    /// Whether members may keep their own messages out of cleanups with `/exclude_me`.
+    #[serde(default)]
+    pub allow_opt_out: bool,
+    /// Members whose messages are left in place while `allow_opt_out` is set.
+    #[serde(default)]
+    pub opted_out: BTreeSet<UserId>,
+    /// Which starboarded messages cleanups leave in place, if any.
+    #[serde(default)]
+    pub starboard: Option<StarboardOptions>,
+    /// Whether cleanups leave the channel's oldest message, such as a rules post, in place.
+    #[serde(default)]
+    pub keep_first_message: bool,
+    /// Whether cleanups leave pinned messages in place.
+    #[serde(default)]
+    pub keep_pinned: bool,
+    /// Messages younger than this are left in place, if set.
+    #[serde(default)]
+    pub keep_newer_than: Option<Duration>,
+    /// Keywords, such as `#keep`, that keep the messages containing them out of cleanups.
+    #[serde(default)]
+    pub keep_keywords: Vec<String>,
+    /// Whether cleanups replace the channel with an empty copy instead of purging messages.
+    #[serde(default)]
+    pub nuke: bool,
+    /// The slowmode in seconds the channel is held at while a cleanup runs, if any.
+    #[serde(default)]
+    pub slowmode: Option<u16>,
+    /// Whether @everyone is denied Send Messages while a cleanup runs.
+    #[serde(default)]
+    pub lock_channel: bool,
+    /// The pause after each delete of a message older than 14 days, if the
+    /// task overrides the bot's default.
+    #[serde(default)]
+    pub old_message_delay: Option<Duration>,
+    /// The order cleanups delete messages in.
+    #[serde(default)]
+    pub deletion_order: DeletionOrder,
+    /// The most messages a single cleanup deletes, if capped. The rest is left
+    /// for the next cleanup.
+    #[serde(default)]
+    pub max_deletions: Option<u32>,
+    /// The channel a summary is posted in after each cleanup, if any.
+    #[serde(default)]
+    pub summary: Option<ChannelId>,
+    /// The named policy the task follows, if any. Changing the policy changes
+    /// the task along with it.
+    #[serde(default)]
+    pub policy: Option<String>,
+    /// Free-form labels, such as `event` or `compliance`, that tasks can be
+    /// listed and changed by. Kept in lowercase.
+    #[serde(default)]
+    pub labels: BTreeSet<String>,
+    /// The member who created the task, if it was created with a command.
+    #[serde(default)]
+    pub created_by: Option<UserId>,
+    /// The member who last changed the task with a command, if any.
+    #[serde(default)]
+    pub edited_by: Option<UserId>,
+    /// When `edited_by` last changed the task.
+    #[serde(default)]
+    pub edited_at: Option<SerializableInstant>,
+    /// Whether `created_by` gets a direct message after each cleanup that
+    /// finishes or fails.
+    #[serde(default)]
+    pub notify_creator: bool,
+    /// Whether the task was created because the channel's name matched one of
+    /// the guild's patterns. Such tasks are removed once the name stops matching.
+    #[serde(default)]
+    pub auto: bool,
+    /// When the task removes itself, if ever.
+    #[serde(default)]
+    pub expires: Option<SerializableInstant>,
+    /// The warning posted before each cleanup, if any.
+    #[serde(default)]
+    pub warning: Option<PurgeWarning>,
+    /// The cleanup the last warning announced, so each one is only announced once.
+    #[serde(default)]
+    pub warned_for: Option<SerializableInstant>,
+    /// Messages deleted on `deleted_day`.
+    #[serde(default)]
+    pub deleted_today: u64,
+    /// The UTC day, as returned by `SerializableInstant::utc_day`, that `deleted_today` counts.
+    #[serde(default)]
+    pub deleted_day: u64,
+    /// Messages deleted per day, oldest first, for at most the last `STATS_DAYS` days.
+    #[serde(default)]
+    pub daily: VecDeque<DayTally>,
+    /// The most recent runs, oldest first, at most `MAX_HISTORY` of them.
+    #[serde(default)]
+    pub history: VecDeque<RunRecord>,
+    /// Whether the next scheduled cleanup is a dry run, which only reports what
+    /// it would delete. New tasks start with one; tasks saved before dry runs
+    /// existed don't get one.
+    #[serde(default)]
+    pub dry_run: bool,
+    /// The number of cleanups in a row that failed.
+    #[serde(default)]
+    pub failures: u32,
+    /// What failed the last cleanup, while cleanups keep failing.
+    #[serde(default)]
+    pub failure: Option<FailureKind>,
+    /// Where the task is in its life, as far as its own runs go.
+    #[serde(default)]
+    pub state: TaskState,
+    /// How many new messages in the channel trigger a cleanup ahead of the
+    /// interval, if any.
+    #[serde(default)]
+    pub message_trigger: Option<u32>,
+    /// Whether the task was paused, as saved before tasks had a `state`.
+    #[serde(default, rename = "paused", skip_serializing)]
+    legacy_paused: bool,
+    /// Whether the guild was told the bot lost its permissions in the channel,
+    /// so it is only told once until a cleanup succeeds again.
+    #[serde(default)]
+    pub permissions_reported: bool,
+    /// Messages whose deletes failed for a passing reason, oldest first, at
+    /// most `MAX_RETRY_QUEUE` of them. They are tried again on the next cleanup.
+    #[serde(default)]
+    pub retry_queue: Vec<RetryEntry>,
+    /// Cancels the cleanup while one is in progress.
+    #[serde(skip)]
+    pub running: Option<CancelToken>,
+    /// The running report of the cleanup in progress, if it purges messages.
+    #[serde(skip)]
+    pub progress: Option<watch::Receiver<PurgeReport>>,
 }
 
 impl CleanupTask {
@@ -20,17 +282,361 @@ impl CleanupTask {
     /// # Returns
     /// A new CleanupTask instance.
     pub async fn new(interval: Duration) -> Self {
+        Self::starting_at(interval, SystemClock.now())
+    }
+
+    /// Creates a new CleanupTask whose interval is counted from `start`.
+    ///
+    /// # Parameters
+    /// - `interval`: The time interval between cleanups.
+    /// - `start`: The instant treated as the last cleanup.
+    pub fn starting_at(interval: Duration, start: SerializableInstant) -> Self {
         Self {
             interval,
-            last_cleanup: SerializableInstant::now(),
+            last_cleanup: start,
+            include_threads: false,
+            forum: None,
+            threads: None,
+            show_in_topic: false,
+            allow_opt_out: false,
+            opted_out: BTreeSet::new(),
+            starboard: None,
+            keep_first_message: false,
+            keep_pinned: false,
+            keep_newer_than: None,
+            keep_keywords: Vec::new(),
+            nuke: false,
+            slowmode: None,
+            lock_channel: false,
+            old_message_delay: None,
+            deletion_order: DeletionOrder::default(),
+            max_deletions: None,
+            summary: None,
+            policy: None,
+            labels: BTreeSet::new(),
+            created_by: None,
+            edited_by: None,
+            edited_at: None,
+            notify_creator: false,
+            auto: false,
+            expires: None,
+            warning: None,
+            warned_for: None,
+            deleted_today: 0,
+            deleted_day: 0,
+            daily: VecDeque::new(),
+            history: VecDeque::new(),
+            dry_run: true,
+            message_trigger: None,
+            failures: 0,
+            failure: None,
+            state: TaskState::Scheduled,
+            legacy_paused: false,
+            permissions_reported: false,
+            retry_queue: Vec::new(),
+            running: None,
+            progress: None,
+        }
+    }
+
+    /// Works out the task's state at an instant, including what the saved
+    /// `state` leaves out.
+    ///
+    /// A running cleanup outranks everything else. A hold on the channel
+    /// comes next, then a pause, then the task's expiry.
+    ///
+    /// # Parameters
+    /// - `now`: The instant to evaluate the expiry against.
+    /// - `held`: Whether the channel is under a legal hold.
+    pub fn state_at(&self, now: SerializableInstant, held: bool) -> TaskState {
+        match self.state {
+            TaskState::Running => TaskState::Running,
+            _ if held => TaskState::OnHold,
+            TaskState::Paused => TaskState::Paused,
+            _ if self.expires.is_some_and(|expires| now >= expires) => TaskState::Expired,
+            state => state,
+        }
+    }
+
+    /// Marks a cleanup as started.
+    ///
+    /// # Parameters
+    /// - `cancel`: The token the cleanup can be cancelled with.
+    pub fn start(&mut self, cancel: CancelToken) {
+        self.running = Some(cancel);
+        self.state = TaskState::Running;
+    }
+
+    /// Marks a cleanup as finished, scheduling the next one if it succeeded and
+    /// counting the failure if not.
+    ///
+    /// # Parameters
+    /// - `failure`: What failed the cleanup, or `None` if it succeeded.
+    pub fn finish(&mut self, failure: Option<FailureKind>) {
+        self.running = None;
+        self.progress = None;
+        match failure {
+            None => {
+                self.state = TaskState::Scheduled;
+                self.failures = 0;
+                self.failure = None;
+                self.permissions_reported = false;
+            }
+            Some(kind) => {
+                self.state = TaskState::Failed;
+                self.failures += 1;
+                self.failure = Some(kind);
+            }
+        }
+    }
+
+    /// Pauses the task if its last `MAX_CONSECUTIVE_FAILURES` cleanups all
+    /// failed.
+    ///
+    /// # Returns
+    /// Whether the task was paused just now.
+    pub fn pause_if_failing(&mut self) -> bool {
+        if self.state != TaskState::Failed || self.failures < MAX_CONSECUTIVE_FAILURES {
+            return false;
+        }
+        self.state = TaskState::Paused;
+        true
+    }
+
+    /// Resumes a paused task, giving its cleanups a fresh start.
+    ///
+    /// # Returns
+    /// Whether the task was paused.
+    pub fn resume(&mut self) -> bool {
+        if self.state != TaskState::Paused {
+            return false;
+        }
+        self.state = TaskState::Scheduled;
+        self.failures = 0;
+        self.failure = None;
+        self.permissions_reported = false;
+        true
+    }
+
+    /// Brings a task loaded from storage into a state it can be in after a
+    /// restart: a cleanup that was running when the bot stopped no longer
+    /// is, and a task saved before it had a `state` keeps its pause.
+    pub fn restore(&mut self) {
+        if std::mem::take(&mut self.legacy_paused) {
+            self.state = TaskState::Paused;
+        }
+        if self.state == TaskState::Running {
+            self.state = match self.failures {
+                0 => TaskState::Scheduled,
+                _ => TaskState::Failed,
+            };
         }
     }
 
+    /// Returns the members whose messages cleanups must leave in place.
+    ///
+    /// Opt-outs only count while the channel allows them, so turning
+    /// `allow_opt_out` off suspends them without forgetting them.
+    pub fn excluded_authors(&self) -> impl Iterator<Item = UserId> + '_ {
+        self.opted_out
+            .iter()
+            .copied()
+            .filter(|_| self.allow_opt_out)
+    }
+
+    /// Returns the settings that keep messages but would be ignored since
+    /// the task replaces its channel instead of purging it, such as
+    /// `keep_keywords`. Empty unless `nuke` is set.
+    pub fn nuke_conflicts(&self) -> Vec<&'static str> {
+        if !self.nuke {
+            return Vec::new();
+        }
+        [
+            ("keep_keywords", !self.keep_keywords.is_empty()),
+            ("allow_opt_out", self.allow_opt_out),
+            ("starboard", self.starboard.is_some()),
+            ("keep_first_message", self.keep_first_message),
+            ("keep_pinned", self.keep_pinned),
+            ("keep_newer_than", self.keep_newer_than.is_some()),
+        ]
+        .into_iter()
+        .filter_map(|(setting, set)| set.then_some(setting))
+        .collect()
+    }
+
+    /// Fails with `EuleError::InvalidConfig` if the task's settings can't
+    /// work together, such as `nuke` with a setting that keeps messages.
+    pub fn check_settings(&self) -> Result<(), EuleError> {
+        let conflicts = self.nuke_conflicts();
+        if conflicts.is_empty() {
+            return Ok(());
+        }
+        Err(EuleError::InvalidConfig(format!(
+            "nuke replaces the whole channel, so it can't be combined with {}",
+            conflicts.join(", ")
+        )))
+    }
+
+    /// Returns the settings the task's message purges run with.
+    ///
+    /// # Parameters
+    /// - `cancel`: Cancels the purge.
+    pub fn purge_options(&self, cancel: CancelToken) -> PurgeOptions {
+        let mut filter = MessageFilter::new()
+            .skip_authors(self.excluded_authors())
+            .keep_keywords(&self.keep_keywords);
+        if self.keep_pinned {
+            filter = filter.keep_pinned();
+        }
+        if let Some(age) = self.keep_newer_than {
+            filter = filter.older_than(age);
+        }
+        if let Some(starboard) = &self.starboard {
+            filter = filter.keep_starred(starboard.clone());
+        }
+        PurgeOptions {
+            include_threads: self.include_threads,
+            keep_first_message: self.keep_first_message,
+            slowmode: self.slowmode,
+            lock_channel: self.lock_channel,
+            old_message_delay: self.old_message_delay,
+            order: self.deletion_order,
+            max_deletions: self.max_deletions.map(|max| max as usize),
+            filter,
+            cancel,
+            ..Default::default()
+        }
+    }
+
+    /// Returns the permissions the bot needs in the channel for the task's
+    /// cleanups, given what they are set to do.
+    pub fn required_permissions(&self) -> Permissions {
+        let mut required = Permissions::VIEW_CHANNEL
+            | Permissions::READ_MESSAGE_HISTORY
+            | Permissions::MANAGE_MESSAGES;
+        if self.include_threads || self.threads.is_some() || self.forum.is_some() {
+            required |= Permissions::MANAGE_THREADS;
+        }
+        if self.nuke || self.show_in_topic || self.slowmode.is_some() {
+            required |= Permissions::MANAGE_CHANNELS;
+        }
+        if self.lock_channel {
+            required |= Permissions::MANAGE_ROLES;
+        }
+        if self.warning.is_some() {
+            required |= Permissions::SEND_MESSAGES;
+        }
+        required
+    }
+
+    /// Returns the instant at which the next cleanup is due.
+    pub fn next_cleanup(&self) -> SerializableInstant {
+        self.last_cleanup + self.interval
+    }
+
+    /// Returns whether the warning for the next cleanup should be posted now.
+    ///
+    /// # Parameters
+    /// - `now`: The instant to evaluate the schedule against.
+    pub fn warning_due_at(&self, now: SerializableInstant) -> bool {
+        let Some(warning) = &self.warning else {
+            return false;
+        };
+        let next = self.next_cleanup();
+        // A cleanup that is already due is about to run; warning now would be too late
+        now + warning.lead >= next && now < next && self.warned_for != Some(next)
+    }
+
+    /// Returns whether the task has expired at the given instant.
+    ///
+    /// # Parameters
+    /// - `now`: The instant to evaluate the expiry against.
+    pub fn is_expired_at(&self, now: SerializableInstant) -> bool {
+        self.expires.is_some_and(|expires| now >= expires)
+    }
+
     /// Checks if it's time to perform a cleanup based on the interval and last cleanup time.
     ///
     /// # Returns
     /// `true` if it's time to perform a cleanup, `false` otherwise.
     pub async fn is_due(&self) -> bool {
-        self.last_cleanup.elapsed() >= self.interval
+        self.is_due_at(SystemClock.now())
+    }
+
+    /// Checks if a cleanup is due at the given instant.
+    ///
+    /// # Parameters
+    /// - `now`: The instant to evaluate the schedule against.
+    pub fn is_due_at(&self, now: SerializableInstant) -> bool {
+        now >= self.next_cleanup()
+    }
+
+    /// Adds deleted messages to the daily tally, starting a new tally on a new day.
+    ///
+    /// # Parameters
+    /// - `count`: The number of messages deleted.
+    /// - `day`: The UTC day the messages were deleted on.
+    pub fn record_deleted(&mut self, count: usize, day: u64) {
+        if self.deleted_day != day {
+            self.deleted_day = day;
+            self.deleted_today = 0;
+        }
+        self.deleted_today += count as u64;
+
+        match self.daily.back_mut() {
+            Some(tally) if tally.day == day => tally.deleted += count as u64,
+            _ => self.daily.push_back(DayTally {
+                day,
+                deleted: count as u64,
+            }),
+        }
+        while self
+            .daily
+            .front()
+            .is_some_and(|tally| tally.day + STATS_DAYS <= day)
+        {
+            self.daily.pop_front();
+        }
+    }
+
+    /// Returns whether the task has a label, ignoring case.
+    ///
+    /// Passing `None` matches every task, so an optional filter can be
+    /// passed straight through.
+    pub fn has_label(&self, label: Option<&str>) -> bool {
+        label.map_or(true, |label| {
+            self.labels.contains(&label.trim().to_lowercase())
+        })
+    }
+
+    /// Returns the number of messages deleted from `first_day` on.
+    ///
+    /// Only the last `STATS_DAYS` days are kept, so earlier days count as zero.
+    pub fn deleted_since(&self, first_day: u64) -> u64 {
+        self.daily
+            .iter()
+            .filter(|tally| tally.day >= first_day)
+            .map(|tally| tally.deleted)
+            .sum()
+    }
+
+    /// Returns the number of messages deleted on the given UTC day.
+    pub fn deleted_on(&self, day: u64) -> u64 {
+        if self.deleted_day == day {
+            self.deleted_today
+        } else {
+            0
+        }
+    }
+
+    /// Appends a run to the history, dropping the oldest run once it is full.
+    ///
+    /// # Parameters
+    /// - `record`: The outcome of the run.
+    pub fn record_run(&mut self, record: RunRecord) {
+        if self.history.len() >= MAX_HISTORY {
+            self.history.pop_front();
+        }
+        self.history.push_back(record);
     }
 }
@@ -0,0 +1,110 @@
+//! Direct messages to the members who created tasks.
+//!
+//! A task's creator can ask to be told about its cleanups with
+//! `/autoclean notify`, instead of watching a log channel. They then get a
+//! direct message after each cleanup that runs to the end, saying how many
+//! messages were deleted, and after each one that fails, saying why. Members
+//! who don't accept direct messages from the bot are skipped.
+
+use crate::{
+    i18n::{self, Language},
+    tasks::{AutocleanManager, BotApi, PurgeEvent, PurgeEventKind},
+    utils::{discord_time, humanize, SerializableInstant},
+};
+use std::sync::Arc;
+use tokio::sync::broadcast::{self, error::RecvError};
+
+/// Writes the notice of a finished or failed cleanup, or `None` for events
+/// creators aren't told about.
+///
+/// # Arguments
+/// * `language` - The language to write it in
+/// * `event` - The event of the cleanup
+/// * `next` - The channel's next scheduled cleanup
+pub fn notice_text(
+    language: Language,
+    event: &PurgeEvent,
+    next: SerializableInstant,
+) -> Option<String> {
+    let channel = format!("<#{}>", event.channel_id);
+    match event.kind {
+        PurgeEventKind::Completed if !event.cancelled => Some(i18n::text(
+            language,
+            "creator_notice.completed",
+            &[
+                ("channel", &channel),
+                ("count", &humanize::count(event.deleted as u64)),
+                ("next_purge", &discord_time::relative(next)),
+            ],
+        )),
+        PurgeEventKind::Failed => {
+            let error = event
+                .error
+                .clone()
+                .unwrap_or_else(|| i18n::text(language, "creator_notice.unknown_error", &[]));
+            Some(i18n::text(
+                language,
+                "creator_notice.failed",
+                &[("channel", &channel), ("error", &error)],
+            ))
+        }
+        _ => None,
+    }
+}
+
+/// Tells the creator of a task about its cleanup, if they asked to be told.
+///
+/// Failing to send the message is only logged.
+///
+/// # Arguments
+/// * `manager` - The manager holding the task
+/// * `api` - The Discord client
+/// * `event` - The event of the cleanup
+pub async fn notify_creator<A: BotApi + ?Sized>(
+    manager: &AutocleanManager,
+    api: &A,
+    event: &PurgeEvent,
+) {
+    if event.kind == PurgeEventKind::Started {
+        return;
+    }
+    let Some(task) = manager.task(event.guild_id, event.channel_id).await else {
+        return;
+    };
+    let (true, Some(creator)) = (task.notify_creator, task.created_by) else {
+        return;
+    };
+    let language = manager
+        .guild_settings(event.guild_id)
+        .await
+        .language
+        .unwrap_or_default();
+    let Some(text) = notice_text(language, event, task.next_cleanup()) else {
+        return;
+    };
+    if let Err(e) = api.message_user(creator, &text).await {
+        tracing::debug!("Failed to tell a task's creator about a cleanup: {}", e);
+    }
+}
+
+/// Tells task creators about cleanups until the manager goes away.
+///
+/// # Arguments
+/// * `manager` - The manager whose tasks to follow
+/// * `api` - The Discord client
+/// * `purges` - A subscription to the manager's purge events
+pub async fn run(
+    manager: AutocleanManager,
+    api: Arc<dyn BotApi>,
+    mut purges: broadcast::Receiver<PurgeEvent>,
+) {
+    loop {
+        match purges.recv().await {
+            Ok(event) => notify_creator(&manager, &*api, &event).await,
+            Err(RecvError::Lagged(missed)) => {
+                tracing::warn!("Creator notices fell behind and skipped {} events", missed);
+            }
+            Err(RecvError::Closed) => break,
+        }
+    }
+}
@@ -0,0 +1,136 @@
+//! Dry runs of new tasks' first cleanups.
+//!
+//! A new task's first scheduled cleanup deletes nothing. It only works out
+//! what it would have deleted, so a misconfigured filter shows up before it
+//! wipes the wrong messages, and the cleanups after it delete for real. The
+//! result goes to the guild's log channel if it has one, and in a direct
+//! message to its owner otherwise.
+
+use crate::{
+    i18n::{self, Language},
+    purge::{preview_purge, starboarded_messages, PurgeOptions, PurgePreview, PREVIEW_PAGES},
+    tasks::{AutocleanManager, BotApi},
+    utils::{discord_time, humanize, SerializableInstant},
+};
+use poise::serenity_prelude::{ChannelId, GuildId};
+
+/// Writes the report of a dry run.
+///
+/// Some of the messages that would have been deleted are linked, so they can
+/// be looked at.
+///
+/// # Arguments
+/// * `language` - The language to write it in
+/// * `guild_id` - The guild the channel belongs to
+/// * `channel_id` - The channel the dry run was for
+/// * `preview` - What the cleanup would have deleted
+/// * `next` - When the channel is cleaned for real
+pub fn dry_run_text(
+    language: Language,
+    guild_id: GuildId,
+    channel_id: ChannelId,
+    preview: &PurgePreview,
+    next: SerializableInstant,
+) -> String {
+    let key = match (preview.matched, preview.complete) {
+        (0, _) => "autoclean.dry_run_nothing",
+        (_, true) => "autoclean.dry_run_exact",
+        (_, false) => "autoclean.dry_run_partial",
+    };
+    let text = i18n::text(
+        language,
+        key,
+        &[
+            ("channel", &format!("<#{}>", channel_id)),
+            ("count", &humanize::count(preview.matched as u64)),
+            ("scanned", &humanize::count(preview.scanned as u64)),
+            ("authors", &humanize::count(preview.authors as u64)),
+            ("next_purge", &discord_time::relative(next)),
+        ],
+    );
+    let links: Vec<String> = preview
+        .samples
+        .iter()
+        .map(|message_id| {
+            format!(
+                "https://discord.com/channels/{}/{}/{}",
+                guild_id, channel_id, message_id
+            )
+        })
+        .collect();
+    match links.is_empty() {
+        true => text,
+        false => format!("{}\n{}", text, links.join("\n")),
+    }
+}
+
+/// Runs a task's cleanup as a dry run and tells the guild what it would have
+/// deleted.
+///
+/// # Arguments
+/// * `manager` - The manager holding the task
+/// * `api` - The Discord client
+/// * `guild_id` - The guild the channel belongs to
+/// * `channel_id` - The task's channel
+/// * `options` - The settings from `AutocleanManager::begin_dry_run`
+///
+/// # Returns
+/// Whether the guild was told. If the dry run failed or was cancelled, the
+/// next cleanup is a dry run again.
+pub async fn rehearse<A: BotApi + ?Sized>(
+    manager: &AutocleanManager,
+    api: &A,
+    guild_id: GuildId,
+    channel_id: ChannelId,
+    mut options: PurgeOptions,
+) -> bool {
+    let starboard = manager
+        .task(guild_id, channel_id)
+        .await
+        .and_then(|task| task.starboard)
+        .and_then(|starboard| starboard.channel);
+    let preview = async {
+        if let Some(starboard) = starboard {
+            options.filter = options
+                .filter
+                .keep_messages(starboarded_messages(api, starboard).await?);
+        }
+        preview_purge(api, channel_id, &options, PREVIEW_PAGES).await
+    }
+    .await;
+    let preview = match preview {
+        Ok(preview) if !options.cancel.is_cancelled() => Some(preview),
+        Ok(_) => None,
+        Err(e) => {
+            tracing::warn!("Dry run of channel {} failed: {}", channel_id, e);
+            None
+        }
+    };
+    if let Err(e) = manager
+        .end_dry_run(guild_id, channel_id, preview.is_some())
+        .await
+    {
+        tracing::warn!("Failed to save the tasks after a dry run: {}", e);
+    }
+    let (Some(preview), Some(task)) = (preview, manager.task(guild_id, channel_id).await) else {
+        return false;
+    };
+
+    let settings = manager.guild_settings(guild_id).await;
+    let text = dry_run_text(
+        settings.language.unwrap_or_default(),
+        guild_id,
+        channel_id,
+        &preview,
+        task.next_cleanup(),
+    );
+    let result = match settings.log_channel {
+        Some(log_channel) => api.send_message(log_channel, &text, None).await.map(drop),
+        None => api.message_owner(guild_id, &text).await,
+    };
+    if let Err(e) = result {
+        tracing::warn!("Failed to report a dry run: {}", e);
+        return false;
+    }
+    true
+}
@@ -0,0 +1,125 @@
+//! Events published as cleanups start and finish and as tasks change.
+//!
+//! The manager publishes on broadcast channels so that any number of
+//! notifiers can follow its activity without slowing down the purges
+//! themselves. Events are dropped when nobody is subscribed, and a subscriber
+//! that falls too far behind skips the oldest ones.
+
+use crate::utils::SerializableInstant;
+use poise::serenity_prelude::{ChannelId, GuildId, UserId};
+use serde::Serialize;
+use tokio::sync::broadcast;
+
+/// How many events a subscriber may fall behind before it misses some.
+const EVENT_BUFFER: usize = 256;
+
+/// What happened to a cleanup.
+#[derive(Clone, Copy, Debug, PartialEq, Eq, Serialize)]
+#[serde(rename_all = "snake_case")]
+pub enum PurgeEventKind {
+    Started,
+    Completed,
+    Failed,
+}
+
+/// A cleanup starting or finishing in a channel.
+///
+/// Serialized as the payload sent to webhooks, with IDs as strings and times as
+/// Unix timestamps in seconds.
+#[derive(Clone, Debug, PartialEq, Eq, Serialize)]
+pub struct PurgeEvent {
+    pub kind: PurgeEventKind,
+    pub guild_id: GuildId,
+    pub channel_id: ChannelId,
+    #[serde(serialize_with = "serialize_unix_secs")]
+    pub at: SerializableInstant,
+    /// Messages or forum posts deleted; zero when the cleanup starts.
+    pub deleted: usize,
+    /// Messages found linking to phishing sites, which were deleted whatever
+    /// the task keeps; zero unless the cleanup completed.
+    pub blocked_links: usize,
+    /// Whether the cleanup was cancelled before it finished.
+    pub cancelled: bool,
+    /// How long the cleanup took, in milliseconds; zero when it starts.
+    pub duration_ms: u64,
+    /// Why the cleanup failed, for `Failed` events.
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub error: Option<String>,
+    /// Whether the cleanup failed because the bot lacks permissions in the channel.
+    pub missing_permissions: bool,
+}
+
+/// How a task changed.
+#[derive(Clone, Copy, Debug, PartialEq, Eq, Serialize)]
+#[serde(rename_all = "snake_case")]
+pub enum TaskChangeKind {
+    Added,
+    Updated,
+    Removed,
+}
+
+/// A cleanup task being added, changed or removed.
+#[derive(Clone, Debug, PartialEq, Eq, Serialize)]
+pub struct TaskChange {
+    pub kind: TaskChangeKind,
+    pub guild_id: GuildId,
+    pub channel_id: ChannelId,
+    #[serde(serialize_with = "serialize_unix_secs")]
+    pub at: SerializableInstant,
+    /// The task's interval after the change; absent once it is removed.
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub interval_secs: Option<u64>,
+    /// Whether the task cleans threads after the change; absent once it is removed.
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub include_threads: Option<bool>,
+    /// The member who created the task, if known; absent once it is removed.
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub created_by: Option<UserId>,
+    /// The member who last changed the task with a command, if any; absent
+    /// once it is removed.
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub edited_by: Option<UserId>,
+}
+
+fn serialize_unix_secs<S: serde::Serializer>(
+    at: &SerializableInstant,
+    serializer: S,
+) -> Result<S::Ok, S::Error> {
+    serializer.serialize_u64(at.unix_secs())
+}
+
+/// The sending half of an event channel, shared by everything that publishes
+/// to it.
+#[derive(Clone, Debug)]
+pub struct EventChannel<T> {
+    sender: broadcast::Sender<T>,
+}
+
+/// The channel of purge events, shared by everything that runs cleanups.
+pub type PurgeEvents = EventChannel<PurgeEvent>;
+
+/// The channel of task changes.
+pub type TaskChanges = EventChannel<TaskChange>;
+
+impl<T: Clone> Default for EventChannel<T> {
+    fn default() -> Self {
+        Self::new()
+    }
+}
+
+impl<T: Clone> EventChannel<T> {
+    pub fn new() -> Self {
+        let (sender, _) = broadcast::channel(EVENT_BUFFER);
+        Self { sender }
+    }
+
+    /// Returns a receiver for events published from now on.
+    pub fn subscribe(&self) -> broadcast::Receiver<T> {
+        self.sender.subscribe()
+    }
+
+    /// Publishes an event to current subscribers, if there are any.
+    pub fn publish(&self, event: T) {
+        let _ = self.sender.send(event);
+    }
+}
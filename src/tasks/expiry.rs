@@ -0,0 +1,56 @@
+//! Tasks that remove themselves after a given date.
+//!
+//! A task can be set to expire, for example to clean an event channel hourly
+//! until the event ends. Once the date passes the task is removed and the
+//! guild is told, in its log channel if it has one and otherwise wherever it
+//! wants its announcements.
+
+use crate::{
+    i18n::{self, Language},
+    purge::DiscordApi,
+    tasks::AutocleanManager,
+};
+use poise::serenity_prelude::ChannelId;
+
+/// Writes the notice that a channel's task expired.
+///
+/// # Arguments
+/// * `language` - The language to write it in
+/// * `channel_id` - The channel whose task expired
+pub fn expiry_text(language: Language, channel_id: ChannelId) -> String {
+    i18n::text(
+        language,
+        "autoclean.expired",
+        &[("channel", &format!("<#{}>", channel_id))],
+    )
+}
+
+/// Removes the tasks that have expired and tells their guilds.
+///
+/// A notice that fails to post is not retried, since the task is already gone.
+///
+/// # Arguments
+/// * `manager` - The manager whose tasks to expire
+/// * `api` - The Discord client
+pub async fn remove_expired<A: DiscordApi + ?Sized>(manager: &AutocleanManager, api: &A) {
+    let expired = match manager.take_expired().await {
+        Ok(expired) => expired,
+        Err(e) => {
+            tracing::warn!("Failed to remove expired tasks: {}", e);
+            return;
+        }
+    };
+    for (guild_id, channel_id) in expired {
+        let settings = manager.guild_settings(guild_id).await;
+        let Some(target) = settings
+            .log_channel
+            .or_else(|| settings.announcement_target(channel_id))
+        else {
+            continue;
+        };
+        let text = expiry_text(settings.language.unwrap_or_default(), channel_id);
+        if let Err(e) = api.send_message(target, &text, None).await {
+            tracing::warn!("Failed to post a task expiry notice: {}", e);
+        }
+    }
+}
@@ -0,0 +1,66 @@
+//! Pausing tasks whose cleanups keep failing.
+//!
+//! A task whose channel was deleted, or in which the bot lost its permissions,
+//! would otherwise fail every time it is due. Once `MAX_CONSECUTIVE_FAILURES`
+//! cleanups in a row have failed, the task is paused and the guild is told
+//! why, in its log channel if it has one and in a direct message to its owner
+//! otherwise. The task runs again once it is resumed with
+//! `/autoclean resume`.
+
+use crate::{
+    i18n::{self, Language},
+    tasks::{AutocleanManager, BotApi, FailureKind, MAX_CONSECUTIVE_FAILURES},
+};
+use poise::serenity_prelude::ChannelId;
+
+/// Writes the notice that a channel's task was paused.
+///
+/// # Arguments
+/// * `language` - The language to write it in
+/// * `channel_id` - The channel whose task was paused
+/// * `failure` - What failed the task's last cleanup
+pub fn paused_text(language: Language, channel_id: ChannelId, failure: FailureKind) -> String {
+    let reason = match failure {
+        FailureKind::MissingPermissions => "autoclean.failed_permissions",
+        FailureKind::ChannelGone => "autoclean.failed_gone",
+        FailureKind::Other => "autoclean.failed_other",
+    };
+    i18n::text(
+        language,
+        "autoclean.paused",
+        &[
+            ("channel", &format!("<#{}>", channel_id)),
+            ("count", &MAX_CONSECUTIVE_FAILURES.to_string()),
+            ("reason", &i18n::text(language, reason, &[])),
+        ],
+    )
+}
+
+/// Pauses the tasks that keep failing and tells their guilds.
+///
+/// A notice that fails to post is only logged, since the task is paused
+/// either way.
+///
+/// # Arguments
+/// * `manager` - The manager whose tasks to pause
+/// * `api` - The Discord client
+pub async fn pause_failing<A: BotApi + ?Sized>(manager: &AutocleanManager, api: &A) {
+    let paused = match manager.pause_failing_tasks().await {
+        Ok(paused) => paused,
+        Err(e) => {
+            tracing::warn!("Failed to pause failing tasks: {}", e);
+            return;
+        }
+    };
+    for (guild_id, channel_id, failure) in paused {
+        let settings = manager.guild_settings(guild_id).await;
+        let text = paused_text(settings.language.unwrap_or_default(), channel_id, failure);
+        let result = match settings.log_channel {
+            Some(log_channel) => api.send_message(log_channel, &text, None).await.map(drop),
+            None => api.message_owner(guild_id, &text).await,
+        };
+        if let Err(e) = result {
+            tracing::warn!("Failed to report a paused task: {}", e);
+        }
+    }
+}
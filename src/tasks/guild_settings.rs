@@ -0,0 +1,300 @@
+//! Settings that apply to a whole guild rather than to one task.
+
+use crate::{
+    i18n::{self, Language},
+    tasks::{automod::AutomodRule, policy::Policy, spam_burst::BurstRule, CleanupTask},
+    utils::serializable_instant::SerializableInstant,
+};
+use poise::serenity_prelude::{ChannelId, RoleId, RuleId, ScheduledEventId, UserId};
+use serde::{Deserialize, Serialize};
+use std::{
+    collections::{BTreeMap, BTreeSet, VecDeque},
+    time::Duration,
+};
+
+/// A guild's settings. Every field has a default, so guilds that never changed
+/// anything have no entry at all.
+#[derive(Serialize, Deserialize, Clone, Debug, Default, PartialEq, Eq)]
+pub struct GuildSettings {
+    /// Replacements for the bot's announcement messages.
+    #[serde(default)]
+    pub templates: MessageTemplates,
+    /// The language to respond in, regardless of members' own languages.
+    #[serde(default)]
+    pub language: Option<Language>,
+    /// Named policies that channels can follow, by name.
+    #[serde(default)]
+    pub policies: BTreeMap<String, Policy>,
+    /// Channel name patterns, such as `temp-*`, and the policy channels whose
+    /// names match them follow.
+    #[serde(default)]
+    pub patterns: BTreeMap<String, String>,
+    /// Categories and the policy channels created in them follow.
+    #[serde(default)]
+    pub category_policies: BTreeMap<ChannelId, String>,
+    /// The channel the bot reports what it did on its own in, if any.
+    #[serde(default)]
+    pub log_channel: Option<ChannelId>,
+    /// When the bot was first added to the guild, for guilds that joined after
+    /// onboarding was introduced.
+    #[serde(default)]
+    pub joined_at: Option<SerializableInstant>,
+    /// Scheduled events and the purge that runs once each of them ends.
+    #[serde(default)]
+    pub event_purges: BTreeMap<ScheduledEventId, EventPurge>,
+    /// How many messages the guild's purges may delete per hour, if capped.
+    #[serde(default)]
+    pub deletions_per_hour: Option<u32>,
+    /// Channels under a legal hold, which nothing deletes from until it is lifted.
+    #[serde(default)]
+    pub holds: BTreeMap<ChannelId, LegalHold>,
+    /// Who placed and lifted holds and when, oldest first, at most
+    /// `MAX_HOLD_LOG` entries.
+    #[serde(default)]
+    pub hold_log: VecDeque<HoldRecord>,
+    /// The guild's offset from UTC in minutes, which dates given to commands
+    /// are read in.
+    #[serde(default)]
+    pub utc_offset: i32,
+    /// Where announcements for a cleaned channel, such as warnings, are posted.
+    #[serde(default)]
+    pub announcements: Announcements,
+    /// Whether replies are only shown to whoever used the command.
+    #[serde(default)]
+    pub ephemeral_replies: bool,
+    /// Roles whose members may change tasks. Without any, Discord permissions
+    /// alone decide.
+    #[serde(default)]
+    pub admin_roles: BTreeSet<RoleId>,
+    /// Whether members other than server administrators may only change or
+    /// cancel the tasks they created.
+    #[serde(default)]
+    pub own_tasks_only: bool,
+    /// The settings new tasks start with.
+    #[serde(default)]
+    pub task_defaults: TaskDefaults,
+    /// How many messages from one member make a spam burst that is deleted
+    /// right away, if the guild watches for bursts.
+    #[serde(default)]
+    pub spam_burst: Option<BurstRule>,
+    /// The AutoMod rules the bot set up in the guild.
+    #[serde(default)]
+    pub automod_rules: BTreeMap<AutomodRule, RuleId>,
+}
+
+impl GuildSettings {
+    /// Returns whether a member may change or cancel a task.
+    ///
+    /// Unless the guild limits members to their own tasks, anyone who may use
+    /// the command may. Otherwise only the task's creator and server
+    /// administrators may, so tasks whose creator isn't known are left to
+    /// administrators.
+    ///
+    /// # Parameters
+    /// - `task`: The task to manage.
+    /// - `user_id`: The member who wants to manage it.
+    /// - `administrator`: Whether the member is a server administrator.
+    pub fn may_manage(&self, task: &CleanupTask, user_id: UserId, administrator: bool) -> bool {
+        !self.own_tasks_only || administrator || task.created_by == Some(user_id)
+    }
+
+    /// Returns where an announcement for a cleaned channel is posted, if
+    /// anywhere.
+    pub fn announcement_target(&self, channel_id: ChannelId) -> Option<ChannelId> {
+        match self.announcements {
+            Announcements::Channel => Some(channel_id),
+            Announcements::LogChannel => self.log_channel,
+            Announcements::Off => None,
+        }
+    }
+}
+
+/// Where a guild's announcements for cleaned channels are posted.
+#[derive(
+    Serialize, Deserialize, Clone, Copy, Debug, Default, PartialEq, Eq, poise::ChoiceParameter,
+)]
+#[serde(rename_all = "snake_case")]
+pub enum Announcements {
+    /// In the channel being cleaned.
+    #[default]
+    #[name = "in the channel"]
+    Channel,
+    /// In the guild's log channel, or nowhere if it has none.
+    #[name = "in the log channel"]
+    LogChannel,
+    /// Nowhere.
+    #[name = "off"]
+    Off,
+}
+
+/// The settings a guild's new tasks start with, before any changed with
+/// `/autoclean` or applied from a policy.
+#[derive(Serialize, Deserialize, Clone, Copy, Debug, Default, PartialEq, Eq)]
+#[serde(default)]
+pub struct TaskDefaults {
+    /// Leave pinned messages in place.
+    pub keep_pinned: bool,
+    /// Leave the channel's oldest message in place.
+    pub keep_first_message: bool,
+    /// Show the next cleanup time in the channel topic.
+    pub show_in_topic: bool,
+}
+
+impl TaskDefaults {
+    /// Gives a new task these settings.
+    pub fn apply(&self, task: &mut CleanupTask) {
+        task.keep_pinned = self.keep_pinned;
+        task.keep_first_message = self.keep_first_message;
+        task.show_in_topic = self.show_in_topic;
+    }
+}
+
+/// The number of entries kept in a guild's hold log.
+pub const MAX_HOLD_LOG: usize = 100;
+
+/// A hold that keeps a channel's messages from being deleted, such as while
+/// an investigation needs them.
+#[derive(Serialize, Deserialize, Clone, Debug, PartialEq, Eq)]
+pub struct LegalHold {
+    /// The member who placed the hold.
+    pub placed_by: UserId,
+    /// When the hold was placed.
+    pub placed_at: SerializableInstant,
+    /// Why the hold was placed, if given.
+    #[serde(default)]
+    pub reason: Option<String>,
+}
+
+/// A hold being placed on or lifted from a channel.
+#[derive(Serialize, Deserialize, Clone, Debug, PartialEq, Eq)]
+pub struct HoldRecord {
+    /// The channel.
+    pub channel: ChannelId,
+    /// The member who placed or lifted the hold.
+    pub by: UserId,
+    /// When it happened.
+    pub at: SerializableInstant,
+    /// Whether the hold was placed rather than lifted.
+    pub placed: bool,
+    /// Why the hold was placed, if given.
+    #[serde(default)]
+    pub reason: Option<String>,
+}
+
+/// A purge that runs once a guild scheduled event ends, so the event's chat
+/// channel resets itself.
+#[derive(Serialize, Deserialize, Clone, Copy, Debug, PartialEq, Eq)]
+pub struct EventPurge {
+    /// The channel to purge.
+    pub channel: ChannelId,
+    /// How long after the event ends the channel is purged.
+    pub delay: Duration,
+}
+
+/// An announcement message that guilds can reword.
+#[derive(Clone, Copy, Debug, PartialEq, Eq, poise::ChoiceParameter)]
+pub enum TemplateKind {
+    /// The warning posted before a cleanup.
+    #[name = "warning"]
+    Warning,
+    /// The progress of a purge started with `/purge now`.
+    #[name = "progress"]
+    Progress,
+    /// The summary posted when a purge started with `/purge now` ends.
+    #[name = "completion"]
+    Completion,
+    /// The summary posted after a scheduled cleanup, for tasks that ask for one.
+    #[name = "summary"]
+    Summary,
+}
+
+impl TemplateKind {
+    /// Returns the placeholders the message fills in.
+    pub fn placeholders(self) -> &'static [&'static str] {
+        match self {
+            TemplateKind::Warning => &["{channel}", "{next_purge}"],
+            TemplateKind::Progress => &["{channel}", "{count}", "{remaining}", "{rate}"],
+            TemplateKind::Completion | TemplateKind::Summary => {
+                &["{channel}", "{count}", "{next_purge}"]
+            }
+        }
+    }
+
+    /// Returns the wording used by guilds that didn't choose their own.
+    pub fn default_template(self, language: Language) -> &'static str {
+        let key = match self {
+            TemplateKind::Warning => "template.warning",
+            TemplateKind::Progress => "template.progress",
+            TemplateKind::Completion => "template.completion",
+            TemplateKind::Summary => "template.summary",
+        };
+        i18n::raw(language, key)
+    }
+}
+
+/// A guild's wording for its announcement messages. `None` keeps the default.
+#[derive(Serialize, Deserialize, Clone, Debug, Default, PartialEq, Eq)]
+#[serde(default)]
+pub struct MessageTemplates {
+    /// The warning posted before a cleanup.
+    pub warning: Option<String>,
+    /// The progress of a purge started with `/purge now`.
+    pub progress: Option<String>,
+    /// The summary posted when a purge started with `/purge now` ends.
+    pub completion: Option<String>,
+    /// The summary posted after a scheduled cleanup.
+    pub summary: Option<String>,
+}
+
+impl MessageTemplates {
+    /// Returns the wording of a message, falling back to the default in `language`.
+    pub fn template(&self, kind: TemplateKind, language: Language) -> &str {
+        self.get(kind)
+            .unwrap_or_else(|| kind.default_template(language))
+    }
+
+    /// Returns the guild's wording of a message, if it has one.
+    pub fn get(&self, kind: TemplateKind) -> Option<&str> {
+        match kind {
+            TemplateKind::Warning => self.warning.as_deref(),
+            TemplateKind::Progress => self.progress.as_deref(),
+            TemplateKind::Completion => self.completion.as_deref(),
+            TemplateKind::Summary => self.summary.as_deref(),
+        }
+    }
+
+    /// Sets the guild's wording of a message, or restores the default with `None`.
+    pub fn set(&mut self, kind: TemplateKind, template: Option<String>) {
+        let slot = match kind {
+            TemplateKind::Warning => &mut self.warning,
+            TemplateKind::Progress => &mut self.progress,
+            TemplateKind::Completion => &mut self.completion,
+            TemplateKind::Summary => &mut self.summary,
+        };
+        *slot = template;
+    }
+}
+
+/// Fills in a template's placeholders.
+///
+/// Unknown placeholders are left as they are.
+///
+/// # Arguments
+/// * `template` - The text, containing placeholders such as `{channel}`
+/// * `values` - Each placeholder's name, without braces, and its value
+///
+/// # Examples
+///
+/// ```
+/// use eule::tasks::guild_settings::render;
+///
+/// let text = render("{count} gone from {channel} {oops}", &[("channel", "<#1>"), ("count", "12")]);
+/// assert_eq!(text, "12 gone from <#1> {oops}");
+/// ```
+pub fn render(template: &str, values: &[(&str, &str)]) -> String {
+    values
+        .iter()
+        .fold(template.to_string(), |text, (name, value)| {
+            text.replace(&format!("{{{}}}", name), value)
+        })
+}
@@ -0,0 +1,67 @@
+//! Cleanups triggered by how busy a channel is.
+//!
+//! A task with a message trigger is also cleaned as soon as its channel has
+//! received a set number of messages since its last cleanup, for channels
+//! whose traffic comes in bursts that no fixed interval matches. Messages are
+//! counted as the gateway delivers them, which needs the `GUILD_MESSAGES`
+//! intent, see `features.message_triggers`. Counts are only kept in memory and
+//! start over when the bot restarts.
+
+use crate::utils::SerializableInstant;
+use poise::serenity_prelude::ChannelId;
+use std::{
+    collections::HashMap,
+    sync::{Arc, Mutex},
+};
+
+/// The messages counted in one channel.
+#[derive(Clone, Copy, Debug)]
+struct MessageCount {
+    /// The cleanup the messages were posted after.
+    since: SerializableInstant,
+    /// The messages posted since.
+    count: u32,
+}
+
+/// Counts the messages posted in channels with a message trigger. Clones share
+/// the counts.
+#[derive(Clone, Debug, Default)]
+pub struct MessageCounts(Arc<Mutex<HashMap<ChannelId, MessageCount>>>);
+
+impl MessageCounts {
+    /// Counts a message posted in a channel.
+    ///
+    /// # Parameters
+    /// - `channel_id`: The channel the message was posted in.
+    /// - `since`: The channel's last cleanup. Messages counted before it are
+    ///   forgotten.
+    /// - `threshold`: How many messages trigger a cleanup.
+    ///
+    /// # Returns
+    /// Whether this message reached `threshold`, in which case the count
+    /// starts over.
+    pub fn count(&self, channel_id: ChannelId, since: SerializableInstant, threshold: u32) -> bool {
+        let Ok(mut counts) = self.0.lock() else {
+            return false;
+        };
+        let entry = counts
+            .entry(channel_id)
+            .or_insert(MessageCount { since, count: 0 });
+        if entry.since != since {
+            *entry = MessageCount { since, count: 0 };
+        }
+        entry.count += 1;
+        if entry.count < threshold {
+            return false;
+        }
+        counts.remove(&channel_id);
+        true
+    }
+
+    /// Forgets the messages counted in a channel.
+    pub fn forget(&self, channel_id: ChannelId) {
+        if let Ok(mut counts) = self.0.lock() {
+            counts.remove(&channel_id);
+        }
+    }
+}
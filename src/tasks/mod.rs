@@ -1,7 +1,35 @@
 mod autoclean_manager;
+pub mod automod;
+mod bot_api;
 mod cleanup_task;
+pub mod creator_notice;
+pub mod dry_run;
+pub mod events;
+pub mod expiry;
+pub mod failures;
+pub mod guild_settings;
+pub mod message_trigger;
+mod nuke;
+pub mod old_messages;
+pub mod permissions;
+pub mod policy;
+pub mod spam_burst;
+pub mod state_dump;
+pub mod summary;
+pub mod topic;
+pub mod warning;
 mod worker_pool;
 
-pub use autoclean_manager::AutocleanManager;
-pub use cleanup_task::CleanupTask;
+pub use autoclean_manager::{
+    cleanup_channel, cleanup_channel_with_progress, AutocleanManager, TaskMove,
+};
+pub use bot_api::BotApi;
+pub use cleanup_task::{
+    CleanupTask, DayTally, FailureKind, PurgeWarning, RunRecord, TaskState,
+    MAX_CONSECUTIVE_FAILURES, MAX_HISTORY, MAX_LABELS, MAX_RETRY_QUEUE, STATS_DAYS,
+};
+pub use events::{
+    EventChannel, PurgeEvent, PurgeEventKind, PurgeEvents, TaskChange, TaskChangeKind, TaskChanges,
+};
+pub use nuke::nuke_channel;
 pub use worker_pool::WorkerPool;
@@ -0,0 +1,59 @@
+//! Emptying a channel by replacing it with a copy.
+//!
+//! Deleting tens of thousands of messages takes hours at Discord's rate limits,
+//! most of it one request per message older than 14 days. Cloning the channel
+//! and deleting the original takes two requests. The copy keeps the channel's
+//! name, topic, permissions, category, and position, but gets a new ID, so
+//! links to the channel and its messages break, and its pins, webhooks, and
+//! threads are gone.
+
+use crate::{error::EuleError, purge::with_retry, tasks::BotApi};
+use poise::serenity_prelude::ChannelId;
+
+/// Replaces a channel with an empty copy of itself.
+///
+/// The copy is created before the original is deleted, so a failure never
+/// leaves the server without the channel. If deleting the original fails, the
+/// copy is deleted again and the error returned.
+///
+/// # Parameters
+/// - `api`: The Discord API client.
+/// - `channel_id`: The ID of the channel to replace.
+///
+/// # Returns
+/// The ID of the copy, or the first error that could not be retried.
+pub async fn nuke_channel<A: BotApi + ?Sized>(
+    api: &A,
+    channel_id: ChannelId,
+) -> Result<ChannelId, EuleError> {
+    const RETRIES: u32 = 5;
+    let clone_id = with_retry(RETRIES, "clone_channel", channel_id, || {
+        api.clone_channel(channel_id)
+    })
+    .await?;
+    if let Err(e) = with_retry(RETRIES, "delete_channel", channel_id, || {
+        api.delete_channel(channel_id)
+    })
+    .await
+    {
+        if let Err(cleanup) = with_retry(RETRIES, "delete_channel", clone_id, || {
+            api.delete_channel(clone_id)
+        })
+        .await
+        {
+            tracing::warn!(
+                "Failed to delete the copy {:x} of channel {:x}: {:?}",
+                clone_id.get(),
+                channel_id.get(),
+                cleanup
+            );
+        }
+        return Err(e);
+    }
+    tracing::debug!(
+        "Replaced channel {:x} with its copy {:x}",
+        channel_id.get(),
+        clone_id.get()
+    );
+    Ok(clone_id)
+}
@@ -0,0 +1,125 @@
+//! Warnings about channels whose history is too old to purge quickly.
+//!
+//! Discord only bulk deletes messages younger than 14 days, so every older
+//! message takes a request of its own. When a channel gets a task while it
+//! still holds many such messages, its first cleanup is far slower than
+//! expected, so the guild is told how many there are and roughly how long the
+//! cleanup will take: in its log channel if it has one, and in a direct
+//! message to its owner otherwise.
+
+use crate::{
+    i18n::{self, Language},
+    purge::{estimate_purge, PurgeEstimate, PurgeOptions, ESTIMATE_PAGES},
+    tasks::{events::TaskChangeKind, AutocleanManager, BotApi},
+    utils::humanize,
+};
+use poise::serenity_prelude::{ChannelId, GuildId};
+use std::sync::Arc;
+use tokio::sync::broadcast::error::RecvError;
+
+/// Channels holding at least this many messages older than 14 days are
+/// reported when they get a task.
+pub const OLD_MESSAGE_THRESHOLD: u64 = 1_000;
+
+/// Writes the warning about a channel's old messages.
+///
+/// # Arguments
+/// * `language` - The language to write it in
+/// * `channel_id` - The channel the warning is about
+/// * `estimate` - The estimate for purging the channel
+/// * `options` - The pacing settings the purge will run with
+pub fn old_messages_text(
+    language: Language,
+    channel_id: ChannelId,
+    estimate: &PurgeEstimate,
+    options: &PurgeOptions,
+) -> String {
+    i18n::text(
+        language,
+        "autoclean.old_messages",
+        &[
+            ("channel", &format!("<#{}>", channel_id)),
+            ("count", &humanize::count(estimate.old)),
+            ("eta", &humanize::duration(estimate.duration(options))),
+        ],
+    )
+}
+
+/// Estimates a channel that just got a task and warns its guild if many of
+/// its messages are older than 14 days.
+///
+/// # Arguments
+/// * `manager` - The manager holding the guild's settings
+/// * `api` - The Discord client
+/// * `guild_id` - The guild the channel belongs to
+/// * `channel_id` - The channel that got a task
+///
+/// # Returns
+/// Whether the guild was warned.
+pub async fn check_new_task<A: BotApi + ?Sized>(
+    manager: &AutocleanManager,
+    api: &A,
+    guild_id: GuildId,
+    channel_id: ChannelId,
+) -> bool {
+    let options = PurgeOptions::default();
+    let estimate = match estimate_purge(api, channel_id, &options, ESTIMATE_PAGES).await {
+        Ok(estimate) => estimate,
+        Err(e) => {
+            tracing::debug!("Failed to estimate a new task's first cleanup: {}", e);
+            return false;
+        }
+    };
+    if estimate.old < OLD_MESSAGE_THRESHOLD {
+        return false;
+    }
+
+    let settings = manager.guild_settings(guild_id).await;
+    let text = old_messages_text(
+        settings.language.unwrap_or_default(),
+        channel_id,
+        &estimate,
+        &options,
+    );
+    let result = match settings.log_channel {
+        Some(log_channel) => api.send_message(log_channel, &text, None).await.map(drop),
+        None => api.message_owner(guild_id, &text).await,
+    };
+    if let Err(e) = result {
+        tracing::warn!("Failed to warn about a channel's old messages: {}", e);
+        return false;
+    }
+    true
+}
+
+/// Checks every channel that gets a task from now on, in the background.
+///
+/// # Arguments
+/// * `manager` - The manager whose task changes to follow
+/// * `api` - The Discord client
+pub fn watch<A: BotApi + ?Sized + 'static>(manager: AutocleanManager, api: Arc<A>) {
+    let mut changes = manager.subscribe_changes();
+    tokio::spawn(async move {
+        loop {
+            let change = match changes.recv().await {
+                Ok(change) => change,
+                Err(RecvError::Lagged(missed)) => {
+                    tracing::warn!(
+                        "Missed {} task changes while checking for old messages",
+                        missed
+                    );
+                    continue;
+                }
+                Err(RecvError::Closed) => break,
+            };
+            if change.kind != TaskChangeKind::Added {
+                continue;
+            }
+            // Sampling takes a while, so checks don't hold up each other
+            let (manager, api) = (manager.clone(), Arc::clone(&api));
+            tokio::spawn(async move {
+                check_new_task(&manager, &*api, change.guild_id, change.channel_id).await;
+            });
+        }
+    });
+}
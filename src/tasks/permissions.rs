@@ -0,0 +1,79 @@
+//! Telling guilds when the bot lost its permissions in a cleaned channel.
+//!
+//! A cleanup that Discord refuses for lack of permissions would otherwise only
+//! show up as a failed request in the bot's logs. Instead, the guild is told
+//! which of the permissions the task needs the bot is missing, in its log
+//! channel if it has one and in a direct message to its owner otherwise. It
+//! is told once, and again only after a cleanup has succeeded in between.
+
+use crate::{
+    i18n::{self, Language},
+    tasks::{AutocleanManager, BotApi},
+};
+use poise::serenity_prelude::{ChannelId, Permissions};
+
+/// Writes the notice that the bot can't clean a channel for lack of
+/// permissions.
+///
+/// # Arguments
+/// * `language` - The language to write it in
+/// * `channel_id` - The channel the bot can't clean
+/// * `missing` - The permissions the bot is missing there, empty if that
+///   couldn't be worked out
+pub fn permissions_lost_text(
+    language: Language,
+    channel_id: ChannelId,
+    missing: Permissions,
+) -> String {
+    let channel = format!("<#{}>", channel_id);
+    if missing.is_empty() {
+        return i18n::text(
+            language,
+            "autoclean.permissions_lost_unknown",
+            &[("channel", &channel)],
+        );
+    }
+    i18n::text(
+        language,
+        "autoclean.permissions_lost",
+        &[
+            ("channel", &channel),
+            ("permissions", &missing.get_permission_names().join(", ")),
+        ],
+    )
+}
+
+/// Tells the guilds of tasks that just failed for lack of permissions which
+/// permissions the bot is missing.
+///
+/// # Arguments
+/// * `manager` - The manager whose tasks to check
+/// * `api` - The Discord client
+pub async fn report_lost<A: BotApi + ?Sized>(manager: &AutocleanManager, api: &A) {
+    let lost = match manager.claim_permission_losses().await {
+        Ok(lost) => lost,
+        Err(e) => {
+            tracing::warn!("Failed to check for lost permissions: {}", e);
+            return;
+        }
+    };
+    for (guild_id, channel_id, required) in lost {
+        let missing = match api.bot_permissions(channel_id).await {
+            Ok(granted) => required - granted,
+            Err(e) => {
+                tracing::debug!("Failed to look up permissions in a channel: {}", e);
+                Permissions::empty()
+            }
+        };
+        let settings = manager.guild_settings(guild_id).await;
+        let text =
+            permissions_lost_text(settings.language.unwrap_or_default(), channel_id, missing);
+        let result = match settings.log_channel {
+            Some(log_channel) => api.send_message(log_channel, &text, None).await.map(drop),
+            None => api.message_owner(guild_id, &text).await,
+        };
+        if let Err(e) = result {
+            tracing::warn!("Failed to report lost permissions: {}", e);
+        }
+    }
+}
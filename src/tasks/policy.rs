@@ -0,0 +1,470 @@
+//! Retention policies: a task's settings, written down so they can be applied
+//! to many channels at once.
+//!
+//! A bulk upload is a JSON array with one object per channel. Each object names
+//! its `channel` and holds the policy's fields next to it:
+//!
+//! ```json
+//! [
+//!   { "channel": "123456789012345678", "interval": "6h", "keep_first_message": true },
+//!   { "channel": "234567890123456789", "interval": "7d", "keep_keywords": ["#keep"] }
+//! ]
+//! ```
+//!
+//! Uploads are checked in full before anything is applied, so a typo in one
+//! entry never leaves the server half migrated.
+
+use crate::{
+    error::EuleError, purge::ChannelSupport, tasks::CleanupTask,
+    utils::serializable_instant::SerializableInstant,
+};
+use poise::serenity_prelude::{ChannelId, ChannelType};
+use serde::{Deserialize, Serialize};
+use serde_json::Value;
+use std::collections::{HashMap, HashSet};
+use tokio::time::Duration;
+
+/// Units an interval may be written in, largest first.
+const UNITS: [(char, u64); 4] = [('w', 7 * 86_400), ('d', 86_400), ('h', 3600), ('m', 60)];
+
+/// The settings a policy gives a channel's cleanup task.
+///
+/// Settings left out of a policy keep their defaults, and applying a policy
+/// overwrites the task's earlier values for every one of them.
+#[derive(Serialize, Deserialize, Clone, Debug, PartialEq, Eq)]
+#[serde(deny_unknown_fields)]
+pub struct Policy {
+    /// The interval between cleanups, written like `30m`, `6h`, `7d` or `2w`.
+    #[serde(with = "interval_format")]
+    pub interval: Duration,
+    /// Whether the channel's threads are cleaned as well.
+    #[serde(default)]
+    pub include_threads: bool,
+    /// Whether the channel topic shows the next cleanup time.
+    #[serde(default)]
+    pub show_in_topic: bool,
+    /// Whether members may keep their own messages with `/exclude_me`.
+    #[serde(default)]
+    pub allow_opt_out: bool,
+    /// Whether pinned messages are left in place.
+    #[serde(default)]
+    pub keep_pinned: bool,
+    /// Messages younger than this are left in place, written like `interval`.
+    #[serde(default, with = "optional_interval_format")]
+    pub keep_newer_than: Option<Duration>,
+    /// Whether the channel's oldest message is left in place.
+    #[serde(default)]
+    pub keep_first_message: bool,
+    /// Keywords that keep the messages containing them.
+    #[serde(default)]
+    pub keep_keywords: Vec<String>,
+    /// The slowmode in seconds the channel is held at during cleanups.
+    #[serde(default)]
+    pub slowmode: Option<u16>,
+    /// Whether @everyone is denied Send Messages during cleanups.
+    #[serde(default)]
+    pub lock_channel: bool,
+    /// Whether the channel is replaced with an empty copy instead of purged.
+    #[serde(default)]
+    pub nuke: bool,
+}
+
+impl Policy {
+    /// Creates a policy that cleans at `interval` with default settings.
+    pub fn new(interval: Duration) -> Self {
+        Self {
+            interval,
+            include_threads: false,
+            show_in_topic: false,
+            allow_opt_out: false,
+            keep_pinned: false,
+            keep_newer_than: None,
+            keep_first_message: false,
+            keep_keywords: Vec::new(),
+            slowmode: None,
+            lock_channel: false,
+            nuke: false,
+        }
+    }
+
+    /// Gives a task this policy's settings, keeping its schedule and history.
+    pub fn apply(&self, task: &mut CleanupTask) {
+        task.interval = self.interval;
+        task.include_threads = self.include_threads;
+        task.show_in_topic = self.show_in_topic;
+        task.allow_opt_out = self.allow_opt_out;
+        task.keep_pinned = self.keep_pinned;
+        task.keep_newer_than = self.keep_newer_than;
+        task.keep_first_message = self.keep_first_message;
+        task.keep_keywords = self.keep_keywords.clone();
+        task.slowmode = self.slowmode;
+        task.lock_channel = self.lock_channel;
+        task.nuke = self.nuke;
+    }
+
+    /// Fails with `EuleError::InvalidConfig` if the policy's settings can't
+    /// work together, see `CleanupTask::check_settings`.
+    pub fn check(&self) -> Result<(), EuleError> {
+        let mut task = CleanupTask::starting_at(self.interval, SerializableInstant::now());
+        self.apply(&mut task);
+        task.check_settings()
+    }
+}
+
+/// Parses an interval such as `30m`, `6h`, `7d` or `2w`.
+///
+/// # Examples
+///
+/// ```
+/// use eule::tasks::policy::parse_interval;
+/// use std::time::Duration;
+///
+/// assert_eq!(parse_interval("6h"), Some(Duration::from_secs(6 * 3600)));
+/// assert_eq!(parse_interval("0d"), None);
+/// assert_eq!(parse_interval("6 hours"), None);
+/// ```
+pub fn parse_interval(text: &str) -> Option<Duration> {
+    let text = text.trim();
+    let unit = text.chars().last()?.to_ascii_lowercase();
+    let (_, unit_secs) = UNITS.iter().find(|(name, _)| *name == unit)?;
+    let amount: u64 = text[..text.len() - 1].parse().ok()?;
+    let secs = amount.checked_mul(*unit_secs)?;
+    (secs > 0).then(|| Duration::from_secs(secs))
+}
+
+/// Writes an interval in the largest unit that divides it, such as `6h`.
+pub fn format_interval(interval: Duration) -> String {
+    let secs = interval.as_secs();
+    UNITS
+        .iter()
+        .find(|(_, unit_secs)| secs % unit_secs == 0)
+        .map_or_else(
+            || format!("{}m", secs / 60),
+            |(name, unit_secs)| format!("{}{}", secs / unit_secs, name),
+        )
+}
+
+/// The longest channel name pattern, which is also Discord's longest channel name.
+pub const MAX_PATTERN_LENGTH: usize = 100;
+
+/// Checks whether a channel name matches a pattern such as `temp-*` or
+/// `*-spam`. A `*` stands for any run of characters, including none, and
+/// letters match regardless of case.
+///
+/// # Examples
+///
+/// ```
+/// use eule::tasks::policy::matches_pattern;
+///
+/// assert!(matches_pattern("temp-*", "temp-raid-night"));
+/// assert!(matches_pattern("*-spam", "bot-spam"));
+/// assert!(!matches_pattern("*-spam", "spam-talk"));
+/// ```
+pub fn matches_pattern(pattern: &str, name: &str) -> bool {
+    let pattern = pattern.to_lowercase();
+    let name = name.to_lowercase();
+    let mut parts = pattern.split('*');
+    let first = parts.next().unwrap_or_default();
+    let Some(mut rest) = name.strip_prefix(first) else {
+        return false;
+    };
+    let mut parts: Vec<&str> = parts.collect();
+    let Some(last) = parts.pop() else {
+        // No wildcard, so the whole name must be the pattern
+        return rest.is_empty();
+    };
+    for part in parts {
+        match rest.find(part) {
+            Some(index) => rest = &rest[index + part.len()..],
+            None => return false,
+        }
+    }
+    rest.ends_with(last)
+}
+
+/// What matching a channel's name against the guild's patterns did.
+#[derive(Clone, Debug, PartialEq, Eq)]
+pub enum PatternOutcome {
+    /// The channel now follows the named policy.
+    Followed(String),
+    /// The channel's name stopped matching, so its automatic task was removed.
+    Removed,
+    /// Nothing changed.
+    Unchanged,
+}
+
+mod interval_format {
+    use super::{format_interval, parse_interval};
+    use serde::{de::Error, Deserialize, Deserializer, Serializer};
+    use tokio::time::Duration;
+
+    pub fn serialize<S: Serializer>(interval: &Duration, serializer: S) -> Result<S::Ok, S::Error> {
+        serializer.serialize_str(&format_interval(*interval))
+    }
+
+    pub fn deserialize<'de, D: Deserializer<'de>>(deserializer: D) -> Result<Duration, D::Error> {
+        let text = String::deserialize(deserializer)?;
+        parse_interval(&text).ok_or_else(|| {
+            D::Error::custom(format!(
+                "invalid interval \"{}\", expected something like 30m, 6h, 7d or 2w",
+                text
+            ))
+        })
+    }
+}
+
+mod optional_interval_format {
+    use serde::{Deserialize, Deserializer, Serializer};
+    use tokio::time::Duration;
+
+    pub fn serialize<S: Serializer>(
+        interval: &Option<Duration>,
+        serializer: S,
+    ) -> Result<S::Ok, S::Error> {
+        match interval {
+            Some(interval) => super::interval_format::serialize(interval, serializer),
+            None => serializer.serialize_none(),
+        }
+    }
+
+    pub fn deserialize<'de, D: Deserializer<'de>>(
+        deserializer: D,
+    ) -> Result<Option<Duration>, D::Error> {
+        #[derive(Deserialize)]
+        struct Interval(#[serde(with = "super::interval_format")] Duration);
+        Ok(Option::<Interval>::deserialize(deserializer)?.map(|Interval(interval)| interval))
+    }
+}
+
+/// Why an entry of a bulk upload can't be applied.
+#[derive(Clone, Debug, PartialEq, Eq)]
+pub enum BulkError {
+    /// The entry isn't a valid policy; holds the parser's explanation.
+    Invalid(String),
+    /// The channel isn't on the server.
+    UnknownChannel(ChannelId),
+    /// The channel has no messages that can be cleaned, such as a category or forum.
+    Unsupported(ChannelId),
+    /// An earlier entry already names the channel.
+    Duplicate(ChannelId),
+}
+
+/// An entry of a bulk upload that can't be applied.
+#[derive(Clone, Debug, PartialEq, Eq)]
+pub struct BulkFailure {
+    /// The entry's position in the upload, counting from 1, or `None` if the
+    /// upload as a whole couldn't be read.
+    pub entry: Option<usize>,
+    /// What is wrong with it.
+    pub error: BulkError,
+}
+
+/// Reads and checks a bulk upload against the server's channels.
+///
+/// Threads are only cleaned in channels that can have them, so
+/// `include_threads` is turned off for the others rather than failing.
+///
+/// # Arguments
+/// * `json` - The upload, a JSON array of entries
+/// * `channels` - The server's channels and their types
+///
+/// # Returns
+/// Every entry's channel and policy, or every entry that can't be applied.
+pub fn parse_bulk(
+    json: &str,
+    channels: &HashMap<ChannelId, ChannelType>,
+) -> Result<Vec<(ChannelId, Policy)>, Vec<BulkFailure>> {
+    let whole = |error| {
+        vec![BulkFailure {
+            entry: None,
+            error: BulkError::Invalid(error),
+        }]
+    };
+    let entries = match serde_json::from_str::<Value>(json) {
+        Ok(Value::Array(entries)) => entries,
+        Ok(_) => return Err(whole("expected a JSON array of entries".to_string())),
+        Err(e) => return Err(whole(e.to_string())),
+    };
+
+    let mut parsed = Vec::with_capacity(entries.len());
+    let mut failures = Vec::new();
+    let mut seen = HashSet::new();
+    for (index, entry) in entries.into_iter().enumerate() {
+        match parse_entry(entry, channels, &mut seen) {
+            Ok(entry) => parsed.push(entry),
+            Err(error) => failures.push(BulkFailure {
+                entry: Some(index + 1),
+                error,
+            }),
+        }
+    }
+    if failures.is_empty() {
+        Ok(parsed)
+    } else {
+        Err(failures)
+    }
+}
+
+/// Reads and checks one entry of a bulk upload.
+fn parse_entry(
+    entry: Value,
+    channels: &HashMap<ChannelId, ChannelType>,
+    seen: &mut HashSet<ChannelId>,
+) -> Result<(ChannelId, Policy), BulkError> {
+    let Value::Object(mut fields) = entry else {
+        return Err(BulkError::Invalid("expected an object".to_string()));
+    };
+    let channel_id = match fields.remove("channel") {
+        Some(Value::String(id)) => id.parse().ok(),
+        Some(Value::Number(id)) => id.as_u64(),
+        _ => None,
+    }
+    .filter(|id| *id != 0)
+    .map(ChannelId::new)
+    .ok_or_else(|| BulkError::Invalid("missing or invalid \"channel\"".to_string()))?;
+    let mut policy: Policy = serde_json::from_value(Value::Object(fields))
+        .map_err(|e| BulkError::Invalid(e.to_string()))?;
+    policy.check().map_err(|e| match e {
+        EuleError::InvalidConfig(reason) => BulkError::Invalid(reason),
+        e => BulkError::Invalid(e.to_string()),
+    })?;
+
+    let kind = channels
+        .get(&channel_id)
+        .ok_or(BulkError::UnknownChannel(channel_id))?;
+    let ChannelSupport::Messages { threads } = ChannelSupport::of(*kind) else {
+        return Err(BulkError::Unsupported(channel_id));
+    };
+    if !seen.insert(channel_id) {
+        return Err(BulkError::Duplicate(channel_id));
+    }
+    policy.include_threads &= threads;
+    Ok((channel_id, policy))
+}
+
+/// How a channel's messages are retained, as listed in a compliance report.
+#[derive(Clone, Debug, PartialEq, Eq)]
+pub enum Retention {
+    /// The channel's task follows a named policy.
+    Policy(String),
+    /// The channel has a task set up by hand.
+    Custom,
+    /// Nothing ever cleans the channel, so its messages are kept forever.
+    Unlimited,
+}
+
+/// A channel and its effective retention, one row of a compliance report.
+#[derive(Clone, Debug, PartialEq, Eq)]
+pub struct RetentionRow {
+    /// The channel.
+    pub channel_id: ChannelId,
+    /// The channel's name, or an empty string for channels, such as threads,
+    /// that weren't listed with the server's channels.
+    pub name: String,
+    /// How the channel's messages are retained.
+    pub retention: Retention,
+    /// The time between cleanups, if the channel has a task.
+    pub interval: Option<Duration>,
+    /// How long messages are kept at least, if the task keeps recent ones.
+    pub keep_newer_than: Option<Duration>,
+}
+
+impl RetentionRow {
+    /// Returns whether the channel has no retention at all.
+    pub fn flagged(&self) -> bool {
+        self.retention == Retention::Unlimited
+    }
+}
+
+/// Lists every channel of a server with its effective retention.
+///
+/// Channels that hold no messages, such as categories, are left out. Tasks
+/// of channels missing from `channels`, such as threads, are listed too.
+///
+/// # Arguments
+/// * `channels` - The server's channels, with their names and types
+/// * `tasks` - The server's tasks
+///
+/// # Returns
+/// One row per channel, channels without retention first, then by name.
+pub fn retention_report(
+    channels: impl IntoIterator<Item = (ChannelId, String, ChannelType)>,
+    tasks: &[(ChannelId, CleanupTask)],
+) -> Vec<RetentionRow> {
+    let tasks: HashMap<ChannelId, &CleanupTask> = tasks
+        .iter()
+        .map(|(channel_id, task)| (*channel_id, task))
+        .collect();
+    let mut names: HashMap<ChannelId, String> = channels
+        .into_iter()
+        .filter(|(_, _, kind)| ChannelSupport::of(*kind) != ChannelSupport::Unsupported)
+        .map(|(channel_id, name, _)| (channel_id, name))
+        .collect();
+    for channel_id in tasks.keys() {
+        names.entry(*channel_id).or_default();
+    }
+    let mut rows: Vec<RetentionRow> = names
+        .into_iter()
+        .map(|(channel_id, name)| {
+            let task = tasks.get(&channel_id);
+            RetentionRow {
+                channel_id,
+                name,
+                retention: match task {
+                    Some(task) => task
+                        .policy
+                        .clone()
+                        .map_or(Retention::Custom, Retention::Policy),
+                    None => Retention::Unlimited,
+                },
+                interval: task.map(|task| task.interval),
+                keep_newer_than: task.and_then(|task| task.keep_newer_than),
+            }
+        })
+        .collect();
+    rows.sort_by(|a, b| {
+        (!a.flagged(), &a.name, a.channel_id).cmp(&(!b.flagged(), &b.name, b.channel_id))
+    });
+    rows
+}
+
+/// Writes a compliance report as CSV, with a header row.
+///
+/// # Examples
+///
+/// ```
+/// use eule::tasks::policy::{retention_csv, Retention, RetentionRow};
+/// use poise::serenity_prelude::ChannelId;
+///
+/// let rows = [RetentionRow {
+///     channel_id: ChannelId::new(10),
+///     name: "general".to_string(),
+///     retention: Retention::Unlimited,
+///     interval: None,
+///     keep_newer_than: None,
+/// }];
+/// assert_eq!(
+///     retention_csv(&rows),
+///     "channel_id,channel,policy,interval,keeps_newer_than,flagged\n10,\"general\",none,,,yes\n"
+/// );
+/// ```
+pub fn retention_csv(rows: &[RetentionRow]) -> String {
+    let mut csv = String::from("channel_id,channel,policy,interval,keeps_newer_than,flagged\n");
+    for row in rows {
+        let policy = match &row.retention {
+            Retention::Policy(name) => name.as_str(),
+            Retention::Custom => "custom",
+            Retention::Unlimited => "none",
+        };
+        csv.push_str(&format!(
+            "{},\"{}\",{},{},{},{}\n",
+            row.channel_id,
+            row.name.replace('"', "\"\""),
+            policy,
+            row.interval.map(format_interval).unwrap_or_default(),
+            row.keep_newer_than.map(format_interval).unwrap_or_default(),
+            if row.flagged() { "yes" } else { "no" }
+        ));
+    }
+    csv
+}
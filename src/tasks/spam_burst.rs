@@ -0,0 +1,112 @@
+//! Deleting bursts of spam as they are posted.
+//!
+//! A guild with a burst rule has the bot watch for members who post many
+//! messages in one channel within a few seconds. Once a member reaches the
+//! rule, the messages of the burst are deleted right away, and so is every
+//! message they post there until they slow down, instead of staying up until
+//! the channel's next cleanup. Messages are seen as the gateway delivers
+//! them, which needs the `GUILD_MESSAGES` intent, see `features.spam_bursts`.
+//! Recent messages are only kept in memory.
+
+use crate::utils::SerializableInstant;
+use poise::serenity_prelude::{ChannelId, MessageId, UserId};
+use serde::{Deserialize, Serialize};
+use std::{
+    collections::{HashMap, VecDeque},
+    sync::{Arc, Mutex},
+    time::Duration,
+};
+
+/// The longest time a burst rule can count messages over.
+pub const MAX_BURST_WINDOW: Duration = Duration::from_secs(60);
+
+/// Members watched at once before the quiet ones are forgotten.
+const SWEEP_AT: usize = 10_000;
+
+/// How many messages from one member in how short a time make a burst.
+#[derive(Serialize, Deserialize, Clone, Copy, Debug, PartialEq, Eq)]
+pub struct BurstRule {
+    /// The messages that make a burst.
+    pub messages: u32,
+    /// The time the messages are posted within.
+    pub window: Duration,
+}
+
+/// What a message means for its author's burst.
+#[derive(Clone, Debug, PartialEq, Eq)]
+pub enum Burst {
+    /// The author isn't bursting.
+    None,
+    /// The message made a burst; holds every message of it, oldest first.
+    Started(Vec<MessageId>),
+    /// The author was already bursting, so the message belongs to the burst.
+    Continued(MessageId),
+}
+
+/// One member's recent messages in one channel.
+#[derive(Debug, Default)]
+struct Poster {
+    /// The messages posted within the rule's window, oldest first.
+    recent: VecDeque<(MessageId, SerializableInstant)>,
+    /// Whether the member reached the rule and hasn't slowed down since.
+    bursting: bool,
+}
+
+/// Watches the messages members post for bursts. Clones share what they saw.
+#[derive(Clone, Debug, Default)]
+pub struct SpamBursts(Arc<Mutex<HashMap<(ChannelId, UserId), Poster>>>);
+
+impl SpamBursts {
+    /// Records a message and tells whether it is part of a burst.
+    ///
+    /// A burst ends once its author has posted nothing in the channel for the
+    /// rule's window.
+    ///
+    /// # Parameters
+    /// - `channel_id`: The channel the message was posted in.
+    /// - `user_id`: The message's author.
+    /// - `message_id`: The message.
+    /// - `at`: When the message was posted.
+    /// - `rule`: The guild's burst rule.
+    pub fn record(
+        &self,
+        channel_id: ChannelId,
+        user_id: UserId,
+        message_id: MessageId,
+        at: SerializableInstant,
+        rule: BurstRule,
+    ) -> Burst {
+        let Ok(mut posters) = self.0.lock() else {
+            return Burst::None;
+        };
+        if posters.len() >= SWEEP_AT {
+            posters.retain(|_, poster| {
+                poster
+                    .recent
+                    .back()
+                    .is_some_and(|(_, sent)| at.duration_since(*sent) <= MAX_BURST_WINDOW)
+            });
+        }
+        let poster = posters.entry((channel_id, user_id)).or_default();
+        while poster
+            .recent
+            .front()
+            .is_some_and(|(_, sent)| at.duration_since(*sent) > rule.window)
+        {
+            poster.recent.pop_front();
+        }
+        if poster.recent.is_empty() {
+            poster.bursting = false;
+        }
+        poster.recent.push_back((message_id, at));
+
+        if poster.bursting {
+            return Burst::Continued(message_id);
+        }
+        if poster.recent.len() < rule.messages as usize {
+            return Burst::None;
+        }
+        poster.bursting = true;
+        Burst::Started(poster.recent.iter().map(|(id, _)| *id).collect())
+    }
+}
@@ -0,0 +1,209 @@
+//! A snapshot of the scheduler's internal state, for troubleshooting.
+//!
+//! The snapshot brings together what is otherwise spread over the manager,
+//! the tasks and the purge engine: every task and when it runs next, what is
+//! queued, what is running and how far it got, the errors of recent runs and
+//! how much time purges have spent waiting on rate limits. The usage of the
+//! bot's commands is included as well.
+
+use crate::{
+    commands::usage::{command_usage, CommandStats},
+    lifecycle,
+    purge::{rate_limit_stats, PurgeReport, RateLimitStats},
+    tasks::{AutocleanManager, TaskState},
+    utils::SerializableInstant,
+};
+use poise::serenity_prelude::{ChannelId, GuildId};
+use serde::Serialize;
+use std::collections::BTreeMap;
+
+/// The number of failed runs included in a snapshot.
+pub const RECENT_ERRORS: usize = 20;
+
+/// The scheduler's state at one point in time.
+#[derive(Clone, Debug, Serialize)]
+pub struct StateDump {
+    /// When the snapshot was taken.
+    pub taken_at: SerializableInstant,
+    /// The number of workers cleanups are run on.
+    pub workers: usize,
+    /// Whether the bot is in maintenance mode, with scheduled cleanups paused.
+    pub maintenance: bool,
+    /// Whether deletes are only logged and counted, see `--dry-run`.
+    pub simulated_deletes: bool,
+    /// Every task, by guild.
+    pub guilds: Vec<GuildDump>,
+    /// Cleanups that are due or scheduled, soonest first.
+    pub queue: Vec<QueuedPurge>,
+    /// Purges running right now.
+    pub running: Vec<RunningPurge>,
+    /// The most recent failed runs, newest first.
+    pub recent_errors: Vec<FailedRun>,
+    /// Rate limiting seen since the process started.
+    pub rate_limits: RateLimitStats,
+    /// The invocations of each command since the process started, by name.
+    pub commands: BTreeMap<String, CommandStats>,
+}
+
+/// A guild's tasks.
+#[derive(Clone, Debug, Serialize)]
+pub struct GuildDump {
+    /// The guild.
+    pub guild_id: GuildId,
+    /// The guild's tasks, by channel.
+    pub tasks: Vec<TaskDump>,
+}
+
+/// The schedule of one task.
+#[derive(Clone, Debug, Serialize)]
+pub struct TaskDump {
+    /// The task's channel.
+    pub channel_id: ChannelId,
+    /// Seconds between cleanups.
+    pub interval_secs: u64,
+    /// When the last cleanup ran.
+    pub last_cleanup: SerializableInstant,
+    /// When the next cleanup is due.
+    pub next_cleanup: SerializableInstant,
+    /// Whether a cleanup is running.
+    pub running: bool,
+    /// The number of cleanups in a row that failed.
+    pub failures: u32,
+    /// The state the task is in.
+    pub state: TaskState,
+    /// Messages set aside to retry on the next cleanup.
+    pub retry_queue: usize,
+}
+
+/// A cleanup waiting for its time.
+#[derive(Clone, Debug, Serialize)]
+pub struct QueuedPurge {
+    /// The guild the channel belongs to.
+    pub guild_id: GuildId,
+    /// The channel to clean.
+    pub channel_id: ChannelId,
+    /// When the cleanup is due.
+    pub due: SerializableInstant,
+    /// Whether this is a one-shot purge rather than a task's regular cleanup.
+    pub one_shot: bool,
+}
+
+/// A purge in progress.
+#[derive(Clone, Debug, Serialize)]
+pub struct RunningPurge {
+    /// The guild the channel belongs to, if the purge belongs to a task.
+    pub guild_id: Option<GuildId>,
+    /// The channel being purged.
+    pub channel_id: ChannelId,
+    /// Whether the purge was started from a command.
+    pub interactive: bool,
+    /// The purge's running report, if it is purging messages.
+    pub progress: Option<PurgeReport>,
+}
+
+/// A run that ended with an error.
+#[derive(Clone, Debug, Serialize)]
+pub struct FailedRun {
+    /// The guild the channel belongs to.
+    pub guild_id: GuildId,
+    /// The task's channel.
+    pub channel_id: ChannelId,
+    /// When the run ended.
+    pub at: SerializableInstant,
+    /// What went wrong.
+    pub error: String,
+}
+
+/// Takes a snapshot of a manager's state.
+///
+/// # Arguments
+/// * `manager` - The manager to take the snapshot of
+/// * `simulated_deletes` - Whether the manager's client only pretends to delete
+pub async fn state_dump(manager: &AutocleanManager, simulated_deletes: bool) -> StateDump {
+    let tasks = manager.every_task().await;
+    let interactive = manager.interactive_purges().await;
+
+    let mut guilds: Vec<GuildDump> = Vec::new();
+    let mut queue = Vec::new();
+    let mut running = Vec::new();
+    let mut recent_errors = Vec::new();
+    for (guild_id, channel_id, task) in &tasks {
+        let (guild_id, channel_id) = (*guild_id, *channel_id);
+        let dump = TaskDump {
+            channel_id,
+            interval_secs: task.interval.as_secs(),
+            last_cleanup: task.last_cleanup,
+            next_cleanup: task.next_cleanup(),
+            running: task.running.is_some(),
+            failures: task.failures,
+            state: manager.task_state(guild_id, channel_id, task).await,
+            retry_queue: task.retry_queue.len(),
+        };
+        match guilds.last_mut() {
+            Some(guild) if guild.guild_id == guild_id => guild.tasks.push(dump),
+            _ => guilds.push(GuildDump {
+                guild_id,
+                tasks: vec![dump],
+            }),
+        }
+        queue.push(QueuedPurge {
+            guild_id,
+            channel_id,
+            due: task.next_cleanup(),
+            one_shot: false,
+        });
+        if task.running.is_some() {
+            running.push(RunningPurge {
+                guild_id: Some(guild_id),
+                channel_id,
+                interactive: interactive.contains(&channel_id),
+                progress: task.progress.as_ref().map(|progress| *progress.borrow()),
+            });
+        }
+        recent_errors.extend(task.history.iter().filter_map(|run| {
+            run.error.as_ref().map(|error| FailedRun {
+                guild_id,
+                channel_id,
+                at: run.at,
+                error: error.clone(),
+            })
+        }));
+    }
+
+    queue.extend(manager.all_scheduled_purges().await.into_iter().map(
+        |(guild_id, channel_id, due)| QueuedPurge {
+            guild_id,
+            channel_id,
+            due,
+            one_shot: true,
+        },
+    ));
+    queue.sort_by_key(|queued| queued.due);
+    // Purges of channels without a task only show up as interactive ones
+    let untracked: Vec<_> = interactive
+        .into_iter()
+        .filter(|channel_id| !running.iter().any(|r| r.channel_id == *channel_id))
+        .map(|channel_id| RunningPurge {
+            guild_id: None,
+            channel_id,
+            interactive: true,
+            progress: None,
+        })
+        .collect();
+    running.extend(untracked);
+    recent_errors.sort_by(|a, b| b.at.cmp(&a.at));
+    recent_errors.truncate(RECENT_ERRORS);
+
+    StateDump {
+        taken_at: SerializableInstant::now(),
+        workers: manager.worker_count().await,
+        maintenance: lifecycle::in_maintenance(),
+        simulated_deletes,
+        guilds,
+        queue,
+        running,
+        recent_errors,
+        rate_limits: rate_limit_stats(),
+        commands: command_usage(),
+    }
+}
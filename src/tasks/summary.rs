@@ -0,0 +1,108 @@
+//! Summaries posted after scheduled cleanups.
+//!
+//! Tasks with a summary target get a short message there after every cleanup
+//! that runs to the end, saying how many messages were deleted and when the
+//! channel is cleaned next. The target is the cleaned channel itself or a log
+//! channel. Guilds can reword the message with the summary template.
+
+use crate::{
+    i18n::{self, Language},
+    purge::DiscordApi,
+    tasks::{
+        guild_settings::{render, MessageTemplates, TemplateKind},
+        AutocleanManager, PurgeEvent, PurgeEventKind,
+    },
+    utils::{discord_time, humanize, SerializableInstant},
+};
+use poise::serenity_prelude::ChannelId;
+use std::sync::Arc;
+use tokio::sync::broadcast::{self, error::RecvError};
+
+/// Writes the summary of a cleanup that deleted `deleted` messages.
+///
+/// # Arguments
+/// * `templates` - The guild's message templates
+/// * `language` - The language of the default wording
+/// * `channel_id` - The cleaned channel
+/// * `deleted` - How many messages were deleted
+/// * `next` - The channel's next scheduled cleanup, if it has a task
+pub fn summary_text(
+    templates: &MessageTemplates,
+    language: Language,
+    channel_id: ChannelId,
+    deleted: usize,
+    next: Option<SerializableInstant>,
+) -> String {
+    render(
+        templates.template(TemplateKind::Summary, language),
+        &[
+            ("channel", &format!("<#{}>", channel_id)),
+            ("count", &humanize::count(deleted as u64)),
+            (
+                "next_purge",
+                &next.map_or_else(
+                    || i18n::text(language, "template.not_scheduled", &[]),
+                    discord_time::relative,
+                ),
+            ),
+        ],
+    )
+}
+
+/// Posts the summary of a finished cleanup, if its task asks for one.
+///
+/// # Arguments
+/// * `manager` - The manager whose tasks to summarize
+/// * `api` - The Discord client
+/// * `event` - The event of the finished cleanup
+pub async fn post_summary<A: DiscordApi + ?Sized>(
+    manager: &AutocleanManager,
+    api: &A,
+    event: &PurgeEvent,
+) {
+    if event.kind != PurgeEventKind::Completed || event.cancelled {
+        return;
+    }
+    let Some(task) = manager.task(event.guild_id, event.channel_id).await else {
+        return;
+    };
+    let Some(target) = task.summary else {
+        return;
+    };
+    let settings = manager.guild_settings(event.guild_id).await;
+    let text = summary_text(
+        &settings.templates,
+        settings.language.unwrap_or_default(),
+        event.channel_id,
+        event.deleted,
+        Some(task.next_cleanup()),
+    );
+    if let Err(e) = api.send_message(target, &text, None).await {
+        tracing::warn!("Failed to post a cleanup summary: {}", e);
+    }
+}
+
+/// Posts cleanup summaries until the manager goes away.
+///
+/// # Arguments
+/// * `manager` - The manager whose tasks to summarize
+/// * `api` - The Discord client
+/// * `purges` - A subscription to the manager's purge events
+pub async fn run(
+    manager: AutocleanManager,
+    api: Arc<dyn DiscordApi>,
+    mut purges: broadcast::Receiver<PurgeEvent>,
+) {
+    loop {
+        match purges.recv().await {
+            Ok(event) => post_summary(&manager, &*api, &event).await,
+            Err(RecvError::Lagged(missed)) => {
+                tracing::warn!(
+                    "Cleanup summaries fell behind and skipped {} events",
+                    missed
+                );
+            }
+            Err(RecvError::Closed) => break,
+        }
+    }
+}
@@ -0,0 +1,175 @@
+//! Channel topics that show when the channel is cleaned next.
+//!
+//! Tasks with `show_in_topic` set keep their channel's topic suffixed with
+//! `🧹 next purge: <date>`. The suffix is rewritten after every cleanup and
+//! whenever the task changes, and removed again when the task is removed or
+//! the option is turned off. Whatever the topic said before the suffix is left
+//! alone.
+
+use crate::{
+    error::EuleError,
+    purge::DiscordApi,
+    tasks::{AutocleanManager, PurgeEvent, PurgeEventKind, TaskChange, TaskChangeKind},
+    utils::{serializable_instant::civil_date, SerializableInstant},
+};
+use poise::serenity_prelude::ChannelId;
+use std::sync::Arc;
+use tokio::sync::broadcast::{self, error::RecvError};
+
+/// Marks the start of the suffix, so it can be found and replaced later.
+pub const TOPIC_MARKER: &str = "🧹 next purge: ";
+/// Separates the suffix from the rest of the topic.
+const SEPARATOR: &str = " | ";
+/// The longest topic Discord accepts, in characters.
+const MAX_TOPIC_CHARS: usize = 1024;
+
+/// Formats an instant as a UTC date and time, such as `2026-10-16 09:30 UTC`.
+///
+/// Topics don't render Discord's timestamp markdown everywhere, so the time is
+/// written out in UTC instead.
+pub fn format_time(at: SerializableInstant) -> String {
+    let secs = at.unix_secs();
+    let (year, month, day) = civil_date(secs / 86_400);
+    let minutes = secs % 86_400 / 60;
+    format!(
+        "{:04}-{:02}-{:02} {:02}:{:02} UTC",
+        year,
+        month,
+        day,
+        minutes / 60,
+        minutes % 60
+    )
+}
+
+/// Returns a topic with its suffix removed.
+pub fn strip_suffix(topic: &str) -> &str {
+    match topic.rfind(TOPIC_MARKER) {
+        Some(start) => topic[..start].trim_end_matches(SEPARATOR).trim_end(),
+        None => topic,
+    }
+}
+
+/// Returns `topic` with its suffix set to show `next`, or removed if `next` is
+/// `None`.
+///
+/// The original topic is shortened if the suffix would push it past Discord's
+/// length limit.
+///
+/// # Examples
+///
+/// ```
+/// use eule::{tasks::topic::with_next_purge, utils::SerializableInstant};
+/// use std::time::{Duration, UNIX_EPOCH};
+///
+/// let at = SerializableInstant::from_system_time(UNIX_EPOCH + Duration::from_secs(1_792_143_000));
+/// let topic = with_next_purge("Memes only", Some(at));
+/// assert_eq!(topic, "Memes only | 🧹 next purge: 2026-10-16 09:30 UTC");
+/// assert_eq!(with_next_purge(&topic, None), "Memes only");
+/// ```
+pub fn with_next_purge(topic: &str, next: Option<SerializableInstant>) -> String {
+    let base = strip_suffix(topic);
+    let Some(next) = next else {
+        return base.to_string();
+    };
+    let suffix = format!("{}{}", TOPIC_MARKER, format_time(next));
+    if base.is_empty() {
+        return suffix;
+    }
+    let room = MAX_TOPIC_CHARS - suffix.chars().count() - SEPARATOR.chars().count();
+    let base = if base.chars().count() > room {
+        let kept: String = base.chars().take(room - 1).collect();
+        format!("{}…", kept.trim_end())
+    } else {
+        base.to_string()
+    };
+    format!("{}{}{}", base, SEPARATOR, suffix)
+}
+
+/// Rewrites a channel's topic to show `next`, or removes the suffix if `next`
+/// is `None`.
+///
+/// The topic is only edited if it would change, since Discord allows just two
+/// channel edits per ten minutes.
+///
+/// # Arguments
+/// * `api` - The Discord client
+/// * `channel_id` - The channel whose topic to update
+/// * `next` - The time of the channel's next cleanup
+pub async fn update_topic<A: DiscordApi + ?Sized>(
+    api: &A,
+    channel_id: ChannelId,
+    next: Option<SerializableInstant>,
+) -> Result<(), EuleError> {
+    let current = api.topic(channel_id).await?.unwrap_or_default();
+    if next.is_none() && !current.contains(TOPIC_MARKER) {
+        return Ok(());
+    }
+    let updated = with_next_purge(&current, next);
+    if updated != current {
+        api.set_topic(channel_id, &updated).await?;
+    }
+    Ok(())
+}
+
+/// Brings a channel's topic in line with its task.
+async fn sync_channel<A: DiscordApi + ?Sized>(
+    manager: &AutocleanManager,
+    api: &A,
+    change: &TaskChange,
+) -> Result<(), EuleError> {
+    let task = manager.task(change.guild_id, change.channel_id).await;
+    match task {
+        Some(task) if task.show_in_topic => {
+            update_topic(api, change.channel_id, Some(task.next_cleanup())).await
+        }
+        // A task that was just added can't have put a suffix in the topic yet
+        _ if change.kind == TaskChangeKind::Added => Ok(()),
+        _ => update_topic(api, change.channel_id, None).await,
+    }
+}
+
+/// Keeps channel topics up to date until the manager goes away.
+///
+/// # Arguments
+/// * `manager` - The manager whose tasks to follow
+/// * `api` - The Discord client
+/// * `purges` - A subscription to the manager's purge events
+/// * `changes` - A subscription to the manager's task changes
+pub async fn run(
+    manager: AutocleanManager,
+    api: Arc<dyn DiscordApi>,
+    mut purges: broadcast::Receiver<PurgeEvent>,
+    mut changes: broadcast::Receiver<TaskChange>,
+) {
+    loop {
+        let result = tokio::select! {
+            event = purges.recv() => match event {
+                Ok(event) if event.kind == PurgeEventKind::Completed => {
+                    match manager.task(event.guild_id, event.channel_id).await {
+                        Some(task) if task.show_in_topic => {
+                            update_topic(&*api, event.channel_id, Some(task.next_cleanup())).await
+                        }
+                        _ => Ok(()),
+                    }
+                }
+                Ok(_) => Ok(()),
+                Err(RecvError::Lagged(missed)) => {
+                    tracing::warn!("Topic updates fell behind and skipped {} purge events", missed);
+                    Ok(())
+                }
+                Err(RecvError::Closed) => break,
+            },
+            change = changes.recv() => match change {
+                Ok(change) => sync_channel(&manager, &*api, &change).await,
+                Err(RecvError::Lagged(missed)) => {
+                    tracing::warn!("Topic updates fell behind and skipped {} task changes", missed);
+                    Ok(())
+                }
+                Err(RecvError::Closed) => break,
+            },
+        };
+        if let Err(e) = result {
+            tracing::warn!("Failed to update a channel topic: {}", e);
+        }
+    }
+}
@@ -0,0 +1,84 @@
+//! Warnings posted in a channel shortly before it is cleaned.
+//!
+//! Tasks with a warning get a message announcing the cleanup some time before
+//! it runs, optionally mentioning a role so its members are notified. The
+//! warning is recent enough to be deleted by the cleanup it announces. Guilds
+//! can have warnings posted in their log channel instead, without the
+//! mention, or not at all.
+
+use crate::{
+    i18n::Language,
+    purge::DiscordApi,
+    tasks::{
+        guild_settings::{render, MessageTemplates, TemplateKind},
+        AutocleanManager,
+    },
+    utils::{discord_time, SerializableInstant},
+};
+use poise::serenity_prelude::{ChannelId, RoleId};
+
+/// Writes the warning for a cleanup at `next`.
+///
+/// The role mention is put before the guild's wording, so a custom template
+/// can't drop it.
+///
+/// # Arguments
+/// * `templates` - The guild's message templates
+/// * `language` - The language of the default wording
+/// * `channel_id` - The channel to be cleaned
+/// * `next` - When the cleanup runs
+/// * `role` - The role to mention, if any
+pub fn warning_text(
+    templates: &MessageTemplates,
+    language: Language,
+    channel_id: ChannelId,
+    next: SerializableInstant,
+    role: Option<RoleId>,
+) -> String {
+    let mention = role.map_or(String::new(), |role| format!("<@&{}> ", role));
+    let text = render(
+        templates.template(TemplateKind::Warning, language),
+        &[
+            ("channel", &format!("<#{}>", channel_id)),
+            ("next_purge", &discord_time::relative(next)),
+        ],
+    );
+    format!("{}{}", mention, text)
+}
+
+/// Posts the warnings that are due, wherever the guild wants its
+/// announcements.
+///
+/// A warning that fails to post is not retried, so a channel the bot can't
+/// write to isn't tried again every minute.
+///
+/// # Arguments
+/// * `manager` - The manager whose tasks to warn for
+/// * `api` - The Discord client
+pub async fn send_due_warnings<A: DiscordApi + ?Sized>(manager: &AutocleanManager, api: &A) {
+    let due = match manager.take_due_warnings().await {
+        Ok(due) => due,
+        Err(e) => {
+            tracing::warn!("Failed to record purge warnings: {}", e);
+            return;
+        }
+    };
+    for (guild_id, channel_id, warning, next) in due {
+        let settings = manager.guild_settings(guild_id).await;
+        let Some(target) = settings.announcement_target(channel_id) else {
+            continue;
+        };
+        // Only the members of the channel being cleaned need the ping
+        let role = warning.role.filter(|_| target == channel_id);
+        let text = warning_text(
+            &settings.templates,
+            settings.language.unwrap_or_default(),
+            channel_id,
+            next,
+            role,
+        );
+        if let Err(e) = api.send_message(target, &text, role).await {
+            tracing::warn!("Failed to post a purge warning: {}", e);
+        }
+    }
+}
@@ -1,9 +1,26 @@
-use crate::tasks::{autoclean_manager::cleanup_channel, cleanup_task::CleanupTask};
-use poise::serenity_prelude::{ChannelId, GuildId, Http};
-use std::{collections::HashMap, sync::Arc};
+use crate::{
+    tasks::{
+        autoclean_manager::{
+            cleanup_channel_with_progress, fail_panicked_cleanup, DeletionBudgets,
+        },
+        bot_api::BotApi,
+        cleanup_task::CleanupTask,
+        events::PurgeEvents,
+    },
+    utils::{
+        clock::{Clock, SystemClock},
+        panics,
+    },
+};
+use poise::serenity_prelude::{ChannelId, GuildId};
+use std::{
+    collections::{HashMap, HashSet},
+    sync::Arc,
+};
 use tokio::{
-    sync::{mpsc, RwLock},
+    sync::{mpsc, Mutex, RwLock},
     task::JoinHandle,
+    time::Instant,
 };
 
 pub struct WorkerCleanupTask {
@@ -17,6 +34,9 @@ pub struct WorkerPool {
     sender: mpsc::Sender<WorkerCleanupTask>,
     /// Handles for worker threads.
     workers: Vec<JoinHandle<()>>,
+    /// The channels whose cleanup is queued or running, so the scheduler
+    /// doesn't queue a cleanup twice while it waits for a free worker.
+    queued: Arc<Mutex<HashSet<(GuildId, ChannelId)>>>,
     /// Number of worker threads in the pool.
     worker_count: usize,
 }
@@ -26,18 +46,46 @@ impl WorkerPool {
     ///
     /// # Parameters
     /// - `num_workers`: The number of worker threads to spawn.
-    /// - `http`: An Arc-wrapped Http client for making Discord API calls.
+    /// - `http`: The Discord client cleanups make their API calls with.
     /// - `tasks`: The shared task map for updating task status.
     ///
     /// # Returns
     /// A new WorkerPool instance.
     pub fn new(
         num_workers: usize,
-        http: Arc<Http>,
+        http: Arc<dyn BotApi>,
+        tasks: Arc<RwLock<HashMap<GuildId, HashMap<ChannelId, CleanupTask>>>>,
+    ) -> Self {
+        Self::with_events(
+            num_workers,
+            http,
+            tasks,
+            PurgeEvents::new(),
+            Default::default(),
+            Arc::new(SystemClock),
+        )
+    }
+
+    /// Creates a new WorkerPool whose cleanups publish to `events`.
+    ///
+    /// # Parameters
+    /// - `num_workers`: The number of worker threads to spawn.
+    /// - `http`: The Discord client cleanups make their API calls with.
+    /// - `tasks`: The shared task map for updating task status.
+    /// - `events`: Receives events as cleanups start and finish.
+    /// - `budgets`: The deletion budgets of guilds that cap their deletions.
+    /// - `clock`: The clock cleanups record their runs at.
+    pub fn with_events(
+        num_workers: usize,
+        http: Arc<dyn BotApi>,
         tasks: Arc<RwLock<HashMap<GuildId, HashMap<ChannelId, CleanupTask>>>>,
+        events: PurgeEvents,
+        budgets: DeletionBudgets,
+        clock: Arc<dyn Clock>,
     ) -> Self {
         let (sender, receiver) = mpsc::channel::<WorkerCleanupTask>(100);
-        let receiver = Arc::new(tokio::sync::Mutex::new(receiver));
+        let receiver = Arc::new(Mutex::new(receiver));
+        let queued = Arc::new(Mutex::new(HashSet::new()));
 
         let mut workers = Vec::with_capacity(num_workers);
 
@@ -45,25 +93,91 @@ impl WorkerPool {
             let worker_receiver = Arc::clone(&receiver);
             let worker_http = Arc::clone(&http);
             let worker_tasks = Arc::clone(&tasks);
+            let worker_events = events.clone();
+            let worker_budgets = Arc::clone(&budgets);
+            let worker_queued = Arc::clone(&queued);
+            let worker_clock = Arc::clone(&clock);
 
             let handle = tokio::spawn(async move {
-                while let Some(task) = worker_receiver.lock().await.recv().await {
+                loop {
+                    // The lock is released at the end of the statement, so
+                    // other workers can take tasks while this one runs
+                    let next = worker_receiver.lock().await.recv().await;
+                    let Some(task) = next else {
+                        break;
+                    };
                     tracing::info!(
                         "Worker processing cleanup task for guild {} channel {}",
                         task.guild_id,
                         task.channel_id
                     );
-                    if let Err(e) =
-                        cleanup_channel(&worker_http, task.guild_id, task.channel_id, &worker_tasks)
-                            .await
-                    {
-                        tracing::error!(
-                            "Error cleaning up channel {} in guild {}: {:?}",
-                            task.channel_id,
-                            task.guild_id,
-                            e
+                    let budget = worker_budgets.read().await.get(&task.guild_id).cloned();
+                    // Run on a task of its own, so a panic only ends this cleanup
+                    let started = Instant::now();
+                    let cleanup = tokio::spawn({
+                        let (http, tasks, events, clock) = (
+                            Arc::clone(&worker_http),
+                            Arc::clone(&worker_tasks),
+                            worker_events.clone(),
+                            Arc::clone(&worker_clock),
                         );
+                        async move {
+                            cleanup_channel_with_progress(
+                                &http,
+                                task.guild_id,
+                                task.channel_id,
+                                &tasks,
+                                &*clock,
+                                None,
+                                Some(&events),
+                                budget,
+                            )
+                            .await
+                        }
+                    });
+                    match cleanup.await {
+                        Ok(Ok(())) => {}
+                        Ok(Err(e)) => {
+                            tracing::error!(
+                                "Error cleaning up channel {} in guild {}: {:?}",
+                                task.channel_id,
+                                task.guild_id,
+                                e
+                            );
+                        }
+                        Err(e) if e.is_panic() => {
+                            let panic = panics::message(e.into_panic().as_ref());
+                            tracing::error!(
+                                "Cleanup of channel {} in guild {} panicked: {}",
+                                task.channel_id,
+                                task.guild_id,
+                                panic
+                            );
+                            fail_panicked_cleanup(
+                                task.guild_id,
+                                task.channel_id,
+                                &worker_tasks,
+                                &*worker_clock,
+                                Some(&worker_events),
+                                panic,
+                                started.elapsed(),
+                            )
+                            .await;
+                        }
+                        Err(e) => {
+                            tracing::error!(
+                                "Cleanup of channel {} in guild {} was aborted: {}",
+                                task.channel_id,
+                                task.guild_id,
+                                e
+                            );
+                        }
                     }
+                    // Only now that the run is recorded may it be queued again
+                    worker_queued
+                        .lock()
+                        .await
+                        .remove(&(task.guild_id, task.channel_id));
                 }
             });
 
@@ -73,6 +187,7 @@ impl WorkerPool {
         WorkerPool {
             sender,
             workers,
+            queued,
             worker_count: num_workers,
         }
     }
@@ -81,7 +196,8 @@ impl WorkerPool {
         self.worker_count
     }
 
-    /// Queues a task for execution.
+    /// Queues a task for execution, unless the channel's cleanup is already
+    /// queued or running.
     ///
     /// # Parameters
     /// - `guild_id`: The ID of the guild where the task should occur.
@@ -89,11 +205,20 @@ impl WorkerPool {
     ///
     /// This method is safe to call from multiple threads.
     pub async fn queue_task(&self, guild_id: GuildId, channel_id: ChannelId) {
+        if !self.queued.lock().await.insert((guild_id, channel_id)) {
+            tracing::debug!(
+                "Cleanup for guild {} channel {} is already queued",
+                guild_id,
+                channel_id
+            );
+            return;
+        }
         let task = WorkerCleanupTask {
             guild_id,
             channel_id,
         };
         if let Err(e) = self.sender.send(task).await {
+            self.queued.lock().await.remove(&(guild_id, channel_id));
             tracing::error!("Failed to queue cleanup task: {:?}", e);
         }
     }
@@ -101,10 +226,14 @@ impl WorkerPool {
     /// Shuts down the worker pool, stopping all worker threads.
     ///
     /// This method should only be called once, typically when shutting down the bot.
+    /// A worker that panicked or was aborted is logged, and the others are
+    /// still waited for.
     pub async fn shutdown(self) {
         drop(self.sender);
         for worker in self.workers {
-            worker.await.unwrap();
+            if let Err(e) = worker.await {
+                tracing::error!("Worker ended abnormally during shutdown: {}", e);
+            }
         }
     }
 }
@@ -0,0 +1,77 @@
+//! Clock abstraction for scheduling logic.
+//!
+//! The scheduler asks a `Clock` for the current time instead of reading the
+//! system clock directly, so due-time computations can be driven
+//! deterministically in tests without sleeping.
+
+use crate::utils::serializable_instant::SerializableInstant;
+use std::sync::Mutex;
+use tokio::time::Duration;
+
+/// A source of the current time.
+pub trait Clock: Send + Sync {
+    /// Returns the current point in time.
+    fn now(&self) -> SerializableInstant;
+}
+
+/// A `Clock` backed by the system wall clock.
+#[derive(Clone, Copy, Debug, Default)]
+pub struct SystemClock;
+
+impl Clock for SystemClock {
+    fn now(&self) -> SerializableInstant {
+        SerializableInstant::now()
+    }
+}
+
+/// A manually driven `Clock` for tests.
+///
+/// Time only moves when `advance` or `set` is called.
+///
+/// # Examples
+///
+/// ```
+/// use eule::utils::clock::{Clock, MockClock};
+/// use std::time::Duration;
+///
+/// let clock = MockClock::default();
+/// let start = clock.now();
+/// clock.advance(Duration::from_secs(90));
+/// assert_eq!(clock.now().duration_since(start), Duration::from_secs(90));
+/// ```
+#[derive(Debug)]
+pub struct MockClock {
+    now: Mutex<SerializableInstant>,
+}
+
+impl MockClock {
+    /// Creates a new MockClock frozen at the given instant.
+    pub fn new(start: SerializableInstant) -> Self {
+        Self {
+            now: Mutex::new(start),
+        }
+    }
+
+    /// Moves the clock forward by the given duration.
+    pub fn advance(&self, duration: Duration) {
+        let mut now = self.now.lock().unwrap();
+        *now = *now + duration;
+    }
+
+    /// Sets the clock to the given instant.
+    pub fn set(&self, instant: SerializableInstant) {
+        *self.now.lock().unwrap() = instant;
+    }
+}
+
+impl Default for MockClock {
+    fn default() -> Self {
+        Self::new(SerializableInstant::now())
+    }
+}
+
+impl Clock for MockClock {
+    fn now(&self) -> SerializableInstant {
+        *self.now.lock().unwrap()
+    }
+}
@@ -117,4 +117,24 @@ impl Crypto {
         salt_bytes.copy_from_slice(&salt.as_str().as_bytes()[..16]);
         Ok(salt_bytes)
     }
+
+    /// Generates an unguessable token, such as a session ID.
+    ///
+    /// # Returns
+    ///
+    /// 32 random bytes from the operating system, hex encoded.
+    ///
+    /// # Examples
+    ///
+    /// ```
+    /// use eule::utils::crypto::Crypto;
+    ///
+    /// let token = Crypto::random_token();
+    /// assert_eq!(token.len(), 64);
+    /// assert_ne!(token, Crypto::random_token());
+    /// ```
+    pub fn random_token() -> String {
+        let bytes = Aes256Gcm::generate_key(&mut OsRng);
+        bytes.iter().map(|byte| format!("{:02x}", byte)).collect()
+    }
 }
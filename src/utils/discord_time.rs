@@ -0,0 +1,36 @@
+//! Discord timestamp markdown.
+//!
+//! Times in replies are written as `<t:UNIX:STYLE>` tags, which Discord renders
+//! in each viewer's own time zone and locale instead of the server's.
+
+use crate::utils::serializable_instant::SerializableInstant;
+
+/// Formats an instant as a relative time, such as "in 5 minutes" or "2 hours ago".
+///
+/// # Examples
+///
+/// ```
+/// use eule::utils::{discord_time, SerializableInstant};
+/// use std::time::{Duration, UNIX_EPOCH};
+///
+/// let at = SerializableInstant::from_system_time(UNIX_EPOCH + Duration::from_secs(1_700_000_000));
+/// assert_eq!(discord_time::relative(at), "<t:1700000000:R>");
+/// ```
+pub fn relative(at: SerializableInstant) -> String {
+    format!("<t:{}:R>", at.unix_secs())
+}
+
+/// Formats an instant as a full date and time, including the day of the week.
+///
+/// # Examples
+///
+/// ```
+/// use eule::utils::{discord_time, SerializableInstant};
+/// use std::time::{Duration, UNIX_EPOCH};
+///
+/// let at = SerializableInstant::from_system_time(UNIX_EPOCH + Duration::from_secs(1_700_000_000));
+/// assert_eq!(discord_time::full(at), "<t:1700000000:F>");
+/// ```
+pub fn full(at: SerializableInstant) -> String {
+    format!("<t:{}:F>", at.unix_secs())
+}
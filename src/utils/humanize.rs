@@ -0,0 +1,75 @@
+//! Durations and counts written for people rather than for logs.
+
+use std::time::Duration;
+
+/// Units durations are written in, largest first.
+const UNITS: [(u64, &str); 5] = [
+    (7 * 24 * 60 * 60, "week"),
+    (24 * 60 * 60, "day"),
+    (60 * 60, "hour"),
+    (60, "minute"),
+    (1, "second"),
+];
+
+/// Writes a duration in its two largest units, such as "3 weeks, 2 days".
+///
+/// Smaller units are dropped rather than rounded, and durations under a second
+/// are written as "0 seconds".
+///
+/// # Examples
+///
+/// ```
+/// use eule::utils::humanize;
+/// use std::time::Duration;
+///
+/// assert_eq!(humanize::duration(Duration::from_secs(2_023_392)), "3 weeks, 2 days");
+/// assert_eq!(humanize::duration(Duration::from_secs(3600)), "1 hour");
+/// assert_eq!(humanize::duration(Duration::from_secs(90)), "1 minute, 30 seconds");
+/// ```
+pub fn duration(duration: Duration) -> String {
+    let mut secs = duration.as_secs();
+    let parts: Vec<String> = UNITS
+        .iter()
+        .filter_map(|(unit_secs, name)| {
+            let amount = secs / unit_secs;
+            secs %= unit_secs;
+            (amount > 0).then(|| plural(amount, name))
+        })
+        .take(2)
+        .collect();
+    if parts.is_empty() {
+        return plural(0, "second");
+    }
+    parts.join(", ")
+}
+
+/// Writes a number with thousands separators, such as "1,234,567".
+///
+/// # Examples
+///
+/// ```
+/// use eule::utils::humanize;
+///
+/// assert_eq!(humanize::count(1_234_567), "1,234,567");
+/// assert_eq!(humanize::count(999), "999");
+/// ```
+pub fn count(count: u64) -> String {
+    let digits = count.to_string();
+    let mut grouped = String::with_capacity(digits.len() + digits.len() / 3);
+    for (i, digit) in digits.chars().enumerate() {
+        if i > 0 && (digits.len() - i) % 3 == 0 {
+            grouped.push(',');
+        }
+        grouped.push(digit);
+    }
+    grouped
+}
+
+/// Writes an amount of something, such as "1 day" or "3 days".
+fn plural(amount: u64, unit: &str) -> String {
+    if amount == 1 {
+        format!("1 {}", unit)
+    } else {
+        format!("{} {}s", count(amount), unit)
+    }
+}
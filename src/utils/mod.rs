@@ -1,8 +1,14 @@
+pub mod clock;
 pub mod connection_handler;
 pub mod crypto;
+pub mod discord_time;
+pub mod humanize;
+pub mod panics;
 pub mod rate_limiter;
 pub mod serializable_instant;
+pub mod snowflake;
 
+pub use clock::{Clock, MockClock, SystemClock};
 pub use connection_handler::ConnectionHandler;
 pub use crypto::Crypto;
 pub use rate_limiter::RateLimiter;
@@ -0,0 +1,43 @@
+//! Keeping panics from going unnoticed.
+//!
+//! A panic in a spawned task only ends that task, and Poise answers a command
+//! that panics with an error, so one bad channel or command never takes the
+//! bot down. The hook installed by `log_panics` makes sure each panic still
+//! ends up in the log with its backtrace.
+
+use std::{any::Any, backtrace::Backtrace, panic};
+
+/// Logs every panic with its location and backtrace, then hands it on to the
+/// hook that was installed before.
+pub fn log_panics() {
+    let previous = panic::take_hook();
+    panic::set_hook(Box::new(move |info| {
+        tracing::error!(
+            "Panic at {}: {}\n{}",
+            info.location().map(ToString::to_string).unwrap_or_default(),
+            message(info.payload()),
+            Backtrace::force_capture()
+        );
+        previous(info);
+    }));
+}
+
+/// Returns the message a panic was raised with.
+///
+/// # Examples
+///
+/// ```
+/// use eule::utils::panics::message;
+///
+/// let payload = std::panic::catch_unwind(|| panic!("out of {}", "cheese")).unwrap_err();
+/// assert_eq!(message(payload.as_ref()), "out of cheese");
+/// ```
+pub fn message(payload: &(dyn Any + Send)) -> String {
+    match payload.downcast_ref::<&str>() {
+        Some(message) => message.to_string(),
+        None => payload
+            .downcast_ref::<String>()
+            .cloned()
+            .unwrap_or_else(|| "unknown panic".to_string()),
+    }
+}
@@ -5,9 +5,197 @@
 //! or sending them over the network.
 
 use serde::{Deserialize, Serialize};
+use std::ops::Add;
 use std::time::{Duration, SystemTime, UNIX_EPOCH};
 use tokio::time::Instant;
 
+/// Converts a day count since the Unix epoch into a `(year, month, day)` date.
+///
+/// # Examples
+///
+/// ```
+/// # use eule::utils::serializable_instant::civil_date;
+/// assert_eq!(civil_date(0), (1970, 1, 1));
+/// assert_eq!(civil_date(20_742), (2026, 10, 16));
+/// ```
+pub fn civil_date(day: u64) -> (i64, u32, u32) {
+    // Howard Hinnant's days-to-civil algorithm
+    let z = day as i64 + 719_468;
+    let era = z.div_euclid(146_097);
+    let day_of_era = z.rem_euclid(146_097);
+    let year_of_era =
+        (day_of_era - day_of_era / 1460 + day_of_era / 36_524 - day_of_era / 146_096) / 365;
+    let day_of_year = day_of_era - (365 * year_of_era + year_of_era / 4 - year_of_era / 100);
+    let mp = (5 * day_of_year + 2) / 153;
+    let day = (day_of_year - (153 * mp + 2) / 5 + 1) as u32;
+    let month = (if mp < 10 { mp + 3 } else { mp - 9 }) as u32;
+    let year = year_of_era + era * 400 + i64::from(month <= 2);
+    (year, month, day)
+}
+
+/// Converts a `(year, month, day)` date into a day count since the Unix epoch,
+/// the inverse of [`civil_date`].
+///
+/// # Returns
+/// The day count, or `None` if the date doesn't exist or is before 1970.
+///
+/// # Examples
+///
+/// ```
+/// # use eule::utils::serializable_instant::days_from_civil;
+/// assert_eq!(days_from_civil(1970, 1, 1), Some(0));
+/// assert_eq!(days_from_civil(2026, 10, 16), Some(20_742));
+/// assert_eq!(days_from_civil(2026, 2, 29), None);
+/// ```
+pub fn days_from_civil(year: i64, month: u32, day: u32) -> Option<u64> {
+    let leap = year % 4 == 0 && (year % 100 != 0 || year % 400 == 0);
+    let month_days = match month {
+        2 if leap => 29,
+        2 => 28,
+        4 | 6 | 9 | 11 => 30,
+        1..=12 => 31,
+        _ => return None,
+    };
+    if day == 0 || day > month_days {
+        return None;
+    }
+    // Howard Hinnant's days-from-civil algorithm
+    let year = year - i64::from(month <= 2);
+    let era = year.div_euclid(400);
+    let year_of_era = year.rem_euclid(400);
+    let mp = i64::from((month + 9) % 12);
+    let day_of_year = (153 * mp + 2) / 5 + i64::from(day) - 1;
+    let day_of_era = year_of_era * 365 + year_of_era / 4 - year_of_era / 100 + day_of_year;
+    u64::try_from(era * 146_097 + day_of_era - 719_468).ok()
+}
+
+/// Parses a UTC date, such as `2026-06-01`, or date and time, such as
+/// `2026-06-01 03:00`. A date alone means midnight at its start.
+///
+/// # Examples
+///
+/// ```
+/// # use eule::utils::serializable_instant::parse_utc;
+/// let at = parse_utc("2026-10-16 09:30").unwrap();
+/// assert_eq!(at.utc_basic(), "20261016T093000Z");
+/// assert_eq!(parse_utc("2026-10-16T09:30"), Some(at));
+/// assert_eq!(parse_utc("16.10.2026"), None);
+/// ```
+pub fn parse_utc(text: &str) -> Option<SerializableInstant> {
+    let text = text.trim();
+    let (date, time) = match text.split_once([' ', 'T']) {
+        Some((date, time)) => (date, Some(time.trim())),
+        None => (text, None),
+    };
+    let mut fields = date.splitn(3, '-');
+    let year = fields.next()?.parse().ok()?;
+    let month = fields.next()?.parse().ok()?;
+    let day = fields.next()?.parse().ok()?;
+    let days = days_from_civil(year, month, day)?;
+    let secs = match time {
+        Some(time) => {
+            let (hours, minutes) = time.split_once(':')?;
+            let hours: u64 = hours.parse().ok()?;
+            let minutes: u64 = minutes.parse().ok()?;
+            if hours > 23 || minutes > 59 {
+                return None;
+            }
+            hours * 3600 + minutes * 60
+        }
+        None => 0,
+    };
+    Some(SerializableInstant::from_system_time(
+        UNIX_EPOCH + Duration::from_secs(days * 86_400 + secs),
+    ))
+}
+
+/// Parses an offset from UTC, such as `+02:00`, `-5` or `UTC+5:30`, into
+/// minutes east of UTC. `UTC` alone means no offset.
+///
+/// # Examples
+///
+/// ```
+/// # use eule::utils::serializable_instant::parse_utc_offset;
+/// assert_eq!(parse_utc_offset("+02:00"), Some(120));
+/// assert_eq!(parse_utc_offset("UTC-5:30"), Some(-330));
+/// assert_eq!(parse_utc_offset("utc"), Some(0));
+/// assert_eq!(parse_utc_offset("+15"), None);
+/// assert_eq!(parse_utc_offset("Europe/Berlin"), None);
+/// ```
+pub fn parse_utc_offset(text: &str) -> Option<i32> {
+    let text = text.trim();
+    let text = match text.get(..3) {
+        Some(prefix) if prefix.eq_ignore_ascii_case("utc") => &text[3..],
+        _ => text,
+    };
+    if text.is_empty() {
+        return Some(0);
+    }
+    let (sign, rest) = match (text.strip_prefix('+'), text.strip_prefix('-')) {
+        (Some(rest), _) => (1, rest),
+        (_, Some(rest)) => (-1, rest),
+        _ => return None,
+    };
+    let (hours, minutes) = rest.split_once(':').unwrap_or((rest, "0"));
+    let hours: i32 = hours.parse().ok()?;
+    let minutes: i32 = minutes.parse().ok()?;
+    if hours < 0 || !(0..60).contains(&minutes) || hours * 60 + minutes > 14 * 60 {
+        return None;
+    }
+    Some(sign * (hours * 60 + minutes))
+}
+
+/// Writes an offset in minutes east of UTC, such as `UTC+05:30`.
+///
+/// # Examples
+///
+/// ```
+/// # use eule::utils::serializable_instant::format_utc_offset;
+/// assert_eq!(format_utc_offset(0), "UTC");
+/// assert_eq!(format_utc_offset(-330), "UTC-05:30");
+/// ```
+pub fn format_utc_offset(minutes: i32) -> String {
+    match minutes {
+        0 => "UTC".to_string(),
+        _ => format!(
+            "UTC{}{:02}:{:02}",
+            if minutes < 0 { '-' } else { '+' },
+            minutes.unsigned_abs() / 60,
+            minutes.unsigned_abs() % 60
+        ),
+    }
+}
+
+/// Turns a time read as UTC into the time it stands for at an offset from
+/// UTC, so `09:00` at `UTC+02:00` becomes `07:00` UTC.
+///
+/// # Arguments
+/// * `at` - The time, as if it were in UTC
+/// * `offset_minutes` - The offset it was meant in, in minutes east of UTC
+pub fn from_local(at: SerializableInstant, offset_minutes: i32) -> SerializableInstant {
+    let offset = Duration::from_secs(u64::from(offset_minutes.unsigned_abs()) * 60);
+    let time = at.to_system_time();
+    let shifted = match offset_minutes >= 0 {
+        true => time.checked_sub(offset),
+        false => time.checked_add(offset),
+    };
+    SerializableInstant::from_system_time(shifted.unwrap_or(time))
+}
+
+/// Parses a date or date and time like [`parse_utc`] does, but meant at an
+/// offset from UTC.
+///
+/// # Examples
+///
+/// ```
+/// # use eule::utils::serializable_instant::parse_local;
+/// let at = parse_local("2026-10-16 09:30", 120).unwrap();
+/// assert_eq!(at.utc_basic(), "20261016T073000Z");
+/// ```
+pub fn parse_local(text: &str, offset_minutes: i32) -> Option<SerializableInstant> {
+    parse_utc(text).map(|at| from_local(at, offset_minutes))
+}
+
 /// A serializable representation of a point in time.
 ///
 /// # Examples
@@ -39,7 +227,7 @@ use tokio::time::Instant;
 /// let back_to_system_time: SystemTime = serializable.into();
 /// # }
 /// ```
-#[derive(Serialize, Deserialize, Clone, Copy, Debug)]
+#[derive(Serialize, Deserialize, Clone, Copy, Debug, PartialEq, Eq, PartialOrd, Ord)]
 pub struct SerializableInstant {
     secs: u64,
     nanos: u32,
@@ -96,6 +284,50 @@ impl SerializableInstant {
         now.duration_since(*self)
     }
 
+    /// Returns the UTC calendar day of this instant, counted from the Unix epoch.
+    ///
+    /// # Examples
+    ///
+    /// ```
+    /// # use eule::utils::serializable_instant::SerializableInstant;
+    /// # use std::time::{Duration, UNIX_EPOCH};
+    /// let instant = SerializableInstant::from_system_time(UNIX_EPOCH + Duration::from_secs(86_400 * 3 + 5));
+    /// assert_eq!(instant.utc_day(), 3);
+    /// ```
+    pub fn utc_day(&self) -> u64 {
+        self.secs / 86_400
+    }
+
+    /// Returns the Unix timestamp of this instant in whole seconds.
+    pub fn unix_secs(&self) -> u64 {
+        self.secs
+    }
+
+    /// Formats this instant as a UTC date and time in the iCalendar basic
+    /// format, such as `20261016T093000Z`.
+    ///
+    /// # Examples
+    ///
+    /// ```
+    /// # use eule::utils::serializable_instant::SerializableInstant;
+    /// # use std::time::{Duration, UNIX_EPOCH};
+    /// let instant = SerializableInstant::from_system_time(UNIX_EPOCH + Duration::from_secs(1_792_143_000));
+    /// assert_eq!(instant.utc_basic(), "20261016T093000Z");
+    /// ```
+    pub fn utc_basic(&self) -> String {
+        let (year, month, day) = civil_date(self.utc_day());
+        let secs = self.secs % 86_400;
+        format!(
+            "{:04}{:02}{:02}T{:02}{:02}{:02}Z",
+            year,
+            month,
+            day,
+            secs / 3600,
+            secs / 60 % 60,
+            secs % 60
+        )
+    }
+
     /// Calculates the duration elapsed since another instant.
     ///
     /// # Arguments
@@ -236,6 +468,14 @@ impl SerializableInstant {
     }
 }
 
+impl Add<Duration> for SerializableInstant {
+    type Output = SerializableInstant;
+
+    fn add(self, duration: Duration) -> Self::Output {
+        Self::from_system_time(self.to_system_time() + duration)
+    }
+}
+
 impl From<SystemTime> for SerializableInstant {
     fn from(time: SystemTime) -> Self {
         Self::from_system_time(time)
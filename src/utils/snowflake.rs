@@ -0,0 +1,37 @@
+//! Helpers for converting between Discord snowflake IDs and points in time.
+//!
+//! Every Discord ID embeds its creation time, which lets the purge pipeline
+//! determine a message's age without fetching anything else.
+
+use crate::utils::serializable_instant::SerializableInstant;
+use std::time::{Duration, UNIX_EPOCH};
+
+/// The Discord epoch (2015-01-01T00:00:00Z) in milliseconds since the Unix epoch.
+pub const DISCORD_EPOCH_MS: u64 = 1_420_070_400_000;
+
+/// Returns the creation time encoded in a snowflake ID.
+///
+/// # Examples
+///
+/// ```
+/// use eule::utils::snowflake;
+///
+/// let id = snowflake::from_instant(eule::utils::SerializableInstant::now());
+/// assert!(snowflake::to_instant(id).elapsed().as_secs() < 1);
+/// ```
+pub fn to_instant(id: u64) -> SerializableInstant {
+    let millis = (id >> 22) + DISCORD_EPOCH_MS;
+    SerializableInstant::from_system_time(UNIX_EPOCH + Duration::from_millis(millis))
+}
+
+/// Returns the smallest snowflake ID that could have been created at `instant`.
+///
+/// Instants before the Discord epoch map to zero.
+pub fn from_instant(instant: SerializableInstant) -> u64 {
+    let millis = instant
+        .to_system_time()
+        .duration_since(UNIX_EPOCH)
+        .map(|d| d.as_millis() as u64)
+        .unwrap_or(0);
+    millis.saturating_sub(DISCORD_EPOCH_MS) << 22
+}
@@ -0,0 +1,218 @@
+mod test_utils;
+
+use eule::{
+    admin::{handle, AdminListeners, AdminState, ApiResponse, RunView, TaskView},
+    config::BotConfig,
+    credentials::TokenRotation,
+    store::KvStore,
+    tasks::AutocleanManager,
+    EuleError,
+};
+use hyper::{Method, StatusCode};
+use poise::serenity_prelude::{ApplicationId, ChannelId, GuildId};
+use std::sync::Arc;
+use test_utils::{mock_discord::MockDiscord, unique_test_path, TestCleanup};
+use tokio::time::Duration;
+
+const TOKEN: &str = "Bearer secret";
+
+fn admin_state(api: Arc<MockDiscord>) -> (AdminState, TestCleanup) {
+    let path = unique_test_path();
+    let cleanup = TestCleanup::new(path.clone()).unwrap();
+    let kv_store = Arc::new(KvStore::new(path).unwrap());
+    let state = AdminState {
+        manager: AutocleanManager::new(kv_store),
+        api,
+        token: Some("secret".to_string()),
+        dashboard: None,
+        calendar_key: None,
+        rotation: None,
+    };
+    (state, cleanup)
+}
+
+async fn request(state: &AdminState, method: Method, path: &str, body: &str) -> ApiResponse {
+    handle(state, &method, path, Some(TOKEN), body.as_bytes()).await
+}
+
+#[tokio::test]
+async fn test_admin_rejects_missing_or_wrong_token() {
+    let (state, _cleanup) = admin_state(Arc::new(MockDiscord::new()));
+
+    let missing = handle(&state, &Method::GET, "/api/tasks", None, b"").await;
+    let wrong = handle(
+        &state,
+        &Method::GET,
+        "/api/tasks",
+        Some("Bearer guess"),
+        b"",
+    )
+    .await;
+
+    assert_eq!(missing.status, StatusCode::UNAUTHORIZED);
+    assert_eq!(wrong.status, StatusCode::UNAUTHORIZED);
+}
+
+#[tokio::test]
+async fn test_admin_task_lifecycle() {
+    let (state, _cleanup) = admin_state(Arc::new(MockDiscord::new()));
+
+    let created = request(
+        &state,
+        Method::POST,
+        "/api/tasks",
+        r#"{"guild_id": "1", "channel_id": "2", "interval_secs": 3600, "include_threads": true}"#,
+    )
+    .await;
+    assert_eq!(created.status, StatusCode::CREATED);
+    let task: TaskView = serde_json::from_value(created.body).unwrap();
+    assert_eq!(task.interval_secs, 3600);
+    assert!(task.include_threads);
+
+    let duplicate = request(
+        &state,
+        Method::POST,
+        "/api/tasks",
+        r#"{"guild_id": "1", "channel_id": "2", "interval_secs": 3600}"#,
+    )
+    .await;
+    assert_eq!(duplicate.status, StatusCode::CONFLICT);
+
+    let updated = request(
+        &state,
+        Method::PATCH,
+        "/api/tasks/1/2",
+        r#"{"interval_secs": 7200}"#,
+    )
+    .await;
+    assert_eq!(updated.status, StatusCode::OK);
+    let task: TaskView = serde_json::from_value(updated.body).unwrap();
+    assert_eq!(task.interval_secs, 7200);
+    assert!(task.include_threads);
+
+    let listed = request(&state, Method::GET, "/api/tasks", "").await;
+    let tasks: Vec<TaskView> = serde_json::from_value(listed.body).unwrap();
+    assert_eq!(tasks.len(), 1);
+    assert_eq!(tasks[0].channel_id, ChannelId::new(2));
+
+    let deleted = request(&state, Method::DELETE, "/api/tasks/1/2", "").await;
+    assert_eq!(deleted.status, StatusCode::OK);
+    let missing = request(&state, Method::GET, "/api/tasks/1/2", "").await;
+    assert_eq!(missing.status, StatusCode::NOT_FOUND);
+}
+
+#[tokio::test]
+async fn test_admin_validates_requests() {
+    let (state, _cleanup) = admin_state(Arc::new(MockDiscord::new()));
+
+    let short = request(
+        &state,
+        Method::POST,
+        "/api/tasks",
+        r#"{"guild_id": "1", "channel_id": "2", "interval_secs": 5}"#,
+    )
+    .await;
+    let malformed = request(&state, Method::POST, "/api/tasks", "{").await;
+    let bad_id = request(&state, Method::GET, "/api/tasks/one/2", "").await;
+    let wrong_method = request(&state, Method::PUT, "/api/tasks", "").await;
+
+    assert_eq!(short.status, StatusCode::BAD_REQUEST);
+    assert_eq!(malformed.status, StatusCode::BAD_REQUEST);
+    assert_eq!(bad_id.status, StatusCode::BAD_REQUEST);
+    assert_eq!(wrong_method.status, StatusCode::METHOD_NOT_ALLOWED);
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_admin_purge_is_recorded_in_history() {
+    let api = Arc::new(MockDiscord::new());
+    let channel_id = ChannelId::new(2);
+    api.add_messages(channel_id, 30, Duration::from_secs(60));
+    let (state, _cleanup) = admin_state(Arc::clone(&api));
+    state
+        .manager
+        .add_task(GuildId::new(1), channel_id, Duration::from_secs(3600))
+        .await
+        .unwrap();
+
+    let started = request(&state, Method::POST, "/api/tasks/1/2/purge", "").await;
+    assert_eq!(started.status, StatusCode::ACCEPTED);
+    while api.remaining(channel_id) > 0
+        || state
+            .manager
+            .task(GuildId::new(1), channel_id)
+            .await
+            .is_some_and(|task| task.history.is_empty())
+    {
+        tokio::time::sleep(Duration::from_millis(10)).await;
+    }
+
+    let history = request(&state, Method::GET, "/api/tasks/1/2/history", "").await;
+    let runs: Vec<RunView> = serde_json::from_value(history.body).unwrap();
+    assert_eq!(runs.len(), 1);
+    assert_eq!(runs[0].deleted, 30);
+    assert_eq!(runs[0].error, None);
+}
+
+#[tokio::test]
+async fn test_grpc_listener_requires_token() {
+    let config = BotConfig::from_toml("[admin]\ngrpc_listen = \"127.0.0.1:0\"\n").unwrap();
+    assert_eq!(
+        config.admin.grpc_listen,
+        Some("127.0.0.1:0".parse().unwrap())
+    );
+
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let store = KvStore::new(path).unwrap();
+    let result = AdminListeners::bind(&config, &store).await;
+    assert!(matches!(result, Err(EuleError::InvalidConfig(_))));
+    assert!(AdminListeners::bind(&BotConfig::default(), &store)
+        .await
+        .unwrap()
+        .is_none());
+}
+
+#[tokio::test]
+async fn test_admin_token_rotation() {
+    let (mut state, _cleanup) = admin_state(Arc::new(MockDiscord::new()));
+    let body = r#"{"token": "current"}"#;
+
+    let unavailable = request(&state, Method::POST, "/api/token", body).await;
+    assert_eq!(unavailable.status, StatusCode::NOT_FOUND);
+
+    state.rotation = Some(Arc::new(TokenRotation::new("current".to_string())));
+    let unchanged = request(&state, Method::POST, "/api/token", body).await;
+    let empty = request(&state, Method::POST, "/api/token", r#"{"token": " "}"#).await;
+    let wrong_method = request(&state, Method::GET, "/api/token", "").await;
+
+    assert_eq!(unchanged.status, StatusCode::OK);
+    assert_eq!(unchanged.body["rotated"], false);
+    assert_eq!(empty.status, StatusCode::BAD_REQUEST);
+    assert_eq!(wrong_method.status, StatusCode::METHOD_NOT_ALLOWED);
+}
+
+#[tokio::test]
+async fn test_admin_invite_link() {
+    let (mut state, _cleanup) = admin_state(Arc::new(MockDiscord::new()));
+    let rotation = Arc::new(TokenRotation::new("current".to_string()));
+    state.rotation = Some(Arc::clone(&rotation));
+
+    let connecting = request(&state, Method::GET, "/api/invite", "").await;
+    assert_eq!(connecting.status, StatusCode::SERVICE_UNAVAILABLE);
+
+    rotation.connected_as(ApplicationId::new(42));
+    let invite = request(&state, Method::GET, "/api/invite", "").await;
+    assert_eq!(invite.status, StatusCode::OK);
+    assert!(invite.body["url"]
+        .as_str()
+        .unwrap()
+        .starts_with("https://discord.com/oauth2/authorize?client_id=42&"));
+    assert_eq!(
+        invite.body["scopes"],
+        serde_json::json!(["bot", "applications.commands"])
+    );
+    assert!(invite.body["permission_names"]
+        .as_array()
+        .unwrap()
+        .contains(&"Manage Messages".into()));
+}
@@ -0,0 +1,85 @@
+mod test_utils;
+
+use eule::{
+    notify::alerts::{is_newer, Alert, AlertFilter, OverdueFilter, OVERDUE_AFTER},
+    store::KvStore,
+    tasks::{AutocleanManager, PurgeEventKind},
+    utils::{MockClock, SerializableInstant},
+};
+use poise::serenity_prelude::{ChannelId, GuildId};
+use std::sync::Arc;
+use test_utils::{mock_discord::MockDiscord, unique_test_path, TestCleanup};
+use tokio::time::Duration;
+
+#[tokio::test]
+async fn test_permission_loss_alerts_once_until_recovery() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let manager = AutocleanManager::new(Arc::new(KvStore::new(path).unwrap()));
+    let api = MockDiscord::new();
+    let (guild_id, channel_id) = (GuildId::new(1), ChannelId::new(2));
+    api.add_messages(channel_id, 5, Duration::from_secs(60));
+    manager
+        .add_task(guild_id, channel_id, Duration::from_secs(3600))
+        .await
+        .unwrap();
+    let mut events = manager.subscribe_events();
+    let mut filter = AlertFilter::default();
+    let mut alerts = Vec::new();
+    api.revoke_access(channel_id);
+    let _ = manager.purge_now(&api, guild_id, channel_id).await;
+    let _ = manager.purge_now(&api, guild_id, channel_id).await;
+    api.restore_access(channel_id);
+    let _ = manager.purge_now(&api, guild_id, channel_id).await;
+    api.revoke_access(channel_id);
+    let _ = manager.purge_now(&api, guild_id, channel_id).await;
+
+    while let Ok(event) = events.try_recv() {
+        if event.kind == PurgeEventKind::Failed {
+            assert!(event.missing_permissions);
+        }
+        alerts.extend(filter.alert_for(&event));
+    }
+    let lost = Alert::PermissionLost {
+        guild_id,
+        channel_id,
+    };
+    assert_eq!(alerts, vec![lost.clone(), lost]);
+    assert!(alerts[0].message().contains("lost permission"));
+}
+
+#[tokio::test]
+async fn test_overdue_task_alerts_once_until_it_catches_up() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let clock = Arc::new(MockClock::new(SerializableInstant::now()));
+    let manager =
+        AutocleanManager::with_clock(Arc::new(KvStore::new(path).unwrap()), clock.clone());
+    let (guild_id, channel_id) = (GuildId::new(1), ChannelId::new(2));
+    let hour = Duration::from_secs(3600);
+    manager.add_task(guild_id, channel_id, hour).await.unwrap();
+    let mut filter = OverdueFilter::default();
+
+    clock.advance(hour + OVERDUE_AFTER / 2);
+    assert!(manager.overdue_tasks(OVERDUE_AFTER).await.is_empty());
+
+    clock.advance(OVERDUE_AFTER);
+    let overdue = manager.overdue_tasks(OVERDUE_AFTER).await;
+    assert_eq!(overdue, vec![(guild_id, channel_id, OVERDUE_AFTER * 3 / 2)]);
+    let alerts = filter.alerts_for(&overdue);
+    assert_eq!(alerts.len(), 1);
+    assert!(alerts[0].message().contains("scheduler may be stuck"));
+    assert!(filter.alerts_for(&overdue).is_empty());
+
+    assert!(filter.alerts_for(&[]).is_empty());
+    assert_eq!(filter.alerts_for(&overdue).len(), 1);
+}
+
+#[test]
+fn test_is_newer() {
+    assert!(is_newer("v0.2.0", "0.1.0"));
+    assert!(is_newer("0.10.0", "0.9.3"));
+    assert!(is_newer("1.0.0-rc.1", "0.9.0"));
+    assert!(!is_newer("v0.1.0", "0.1.0"));
+    assert!(!is_newer("0.0.9", "0.1.0"));
+}
@@ -0,0 +1,32 @@
+use eule::{
+    commands::autoclean::{format_labels, overwrite_warning, parse_keywords, parse_labels},
+    i18n::Language,
+    tasks::MAX_LABELS,
+};
+use poise::serenity_prelude::ChannelId;
+
+#[test]
+fn test_overwrite_warning_names_channel_and_option() {
+    let warning = overwrite_warning(Language::En, ChannelId::new(42));
+    assert!(warning.contains("<#42>"));
+    assert!(warning.contains("`overwrite: true`"));
+}
+
+#[test]
+fn test_parse_keywords_drops_blanks_and_duplicates() {
+    assert_eq!(
+        parse_keywords(" #keep, !archive,, #KEEP ,"),
+        vec!["#keep".to_string(), "!archive".to_string()]
+    );
+    assert!(parse_keywords("").is_empty());
+}
+
+#[test]
+fn test_parse_labels_lowercases_and_caps() {
+    let labels = parse_labels(" Event, spam,, EVENT ,");
+    assert_eq!(format_labels(&labels), "`event`, `spam`");
+    assert!(parse_labels("").is_empty());
+
+    let many = (0..20).map(|i| format!("l{}", i)).collect::<Vec<_>>();
+    assert_eq!(parse_labels(&many.join(",")).len(), MAX_LABELS);
+}
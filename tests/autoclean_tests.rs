@@ -1,5 +1,14 @@
-use eule::{store::KvStore, tasks::AutocleanManager};
-use poise::serenity_prelude::{ChannelId, GuildId};
+use eule::{
+    i18n::Language,
+    store::KvStore,
+    tasks::{
+        guild_settings::{EventPurge, TemplateKind},
+        policy::Policy,
+        AutocleanManager, TaskMove,
+    },
+    utils::SerializableInstant,
+};
+use poise::serenity_prelude::{ChannelId, GuildId, ScheduledEventId};
 use std::{
     fs,
     path::PathBuf,
@@ -101,3 +110,249 @@ fn test_save_and_load_tasks() {
         assert_eq!(new_cleanup_manager.task_count(GuildId::new(1)).await, 2);
     });
 }
+
+#[test]
+fn test_all_tasks_across_guilds() {
+    let rt = Runtime::new().unwrap();
+    rt.block_on(async {
+        let db_path = unique_test_db();
+        let _cleanup = TestCleanup {
+            path: db_path.clone(),
+        };
+        let kv_store = Arc::new(KvStore::new(db_path).unwrap());
+        let cleanup_manager = AutocleanManager::new(Arc::clone(&kv_store));
+
+        cleanup_manager
+            .add_task(
+                GuildId::new(2),
+                ChannelId::new(20),
+                Duration::from_secs(600),
+            )
+            .await
+            .unwrap();
+        cleanup_manager
+            .add_task(
+                GuildId::new(1),
+                ChannelId::new(10),
+                Duration::from_secs(3600),
+            )
+            .await
+            .unwrap();
+
+        assert_eq!(
+            cleanup_manager.all_tasks().await,
+            vec![
+                (
+                    GuildId::new(1),
+                    ChannelId::new(10),
+                    Duration::from_secs(3600)
+                ),
+                (
+                    GuildId::new(2),
+                    ChannelId::new(20),
+                    Duration::from_secs(600)
+                ),
+            ]
+        );
+    });
+}
+
+#[test]
+fn test_guild_templates_persist() {
+    let rt = Runtime::new().unwrap();
+    rt.block_on(async {
+        let db_path = unique_test_db();
+        let _cleanup = TestCleanup {
+            path: db_path.clone(),
+        };
+        let kv_store = Arc::new(KvStore::new(db_path).unwrap());
+        let cleanup_manager = AutocleanManager::new(Arc::clone(&kv_store));
+        let guild_id = GuildId::new(1);
+
+        cleanup_manager
+            .update_guild_settings(guild_id, |settings| {
+                settings
+                    .templates
+                    .set(TemplateKind::Completion, Some("{count} gone".to_string()))
+            })
+            .await
+            .unwrap();
+
+        let new_cleanup_manager = AutocleanManager::new(Arc::clone(&kv_store));
+        new_cleanup_manager.load_tasks().await.unwrap();
+        let settings = new_cleanup_manager.guild_settings(guild_id).await;
+        assert_eq!(
+            settings
+                .templates
+                .template(TemplateKind::Completion, Language::En),
+            "{count} gone"
+        );
+        assert_eq!(
+            settings
+                .templates
+                .template(TemplateKind::Warning, Language::De),
+            TemplateKind::Warning.default_template(Language::De)
+        );
+
+        // Restoring every default drops the guild's entry
+        new_cleanup_manager
+            .update_guild_settings(guild_id, |settings| {
+                settings.templates.set(TemplateKind::Completion, None)
+            })
+            .await
+            .unwrap();
+        assert_eq!(
+            new_cleanup_manager.guild_settings(guild_id).await,
+            Default::default()
+        );
+    });
+}
+
+#[test]
+fn test_deletion_budget_follows_settings() {
+    let rt = Runtime::new().unwrap();
+    rt.block_on(async {
+        let db_path = unique_test_db();
+        let _cleanup = TestCleanup {
+            path: db_path.clone(),
+        };
+        let kv_store = Arc::new(KvStore::new(db_path).unwrap());
+        let cleanup_manager = AutocleanManager::new(Arc::clone(&kv_store));
+        let guild_id = GuildId::new(1);
+
+        assert!(cleanup_manager.deletion_budget(guild_id).await.is_none());
+        cleanup_manager
+            .set_deletion_budget(guild_id, Some(500))
+            .await
+            .unwrap();
+
+        let new_cleanup_manager = AutocleanManager::new(Arc::clone(&kv_store));
+        new_cleanup_manager.load_tasks().await.unwrap();
+        let budget = new_cleanup_manager.deletion_budget(guild_id).await.unwrap();
+        assert_eq!(budget.limit().await, 500);
+
+        // A changed cap keeps the budget the running purges share
+        new_cleanup_manager
+            .set_deletion_budget(guild_id, Some(200))
+            .await
+            .unwrap();
+        assert_eq!(budget.limit().await, 200);
+
+        new_cleanup_manager
+            .set_deletion_budget(guild_id, None)
+            .await
+            .unwrap();
+        assert!(new_cleanup_manager
+            .deletion_budget(guild_id)
+            .await
+            .is_none());
+    });
+}
+
+#[test]
+fn test_remove_guild_tasks() {
+    let rt = Runtime::new().unwrap();
+    rt.block_on(async {
+        let db_path = unique_test_db();
+        let _cleanup = TestCleanup {
+            path: db_path.clone(),
+        };
+        let kv_store = Arc::new(KvStore::new(db_path).unwrap());
+        let manager = AutocleanManager::new(Arc::clone(&kv_store));
+        let (guild_id, other_guild) = (GuildId::new(1), GuildId::new(2));
+        let interval = Duration::from_secs(3600);
+        for channel in [10, 11] {
+            manager
+                .add_task(guild_id, ChannelId::new(channel), interval)
+                .await
+                .unwrap();
+        }
+        manager
+            .add_task(other_guild, ChannelId::new(20), interval)
+            .await
+            .unwrap();
+        manager
+            .schedule_purge(guild_id, ChannelId::new(12), SerializableInstant::now())
+            .await
+            .unwrap();
+        manager
+            .set_policy(guild_id, "spam", Policy::new(interval))
+            .await
+            .unwrap();
+        manager
+            .update_guild_settings(guild_id, |settings| {
+                settings.event_purges.insert(
+                    ScheduledEventId::new(3),
+                    EventPurge {
+                        channel: ChannelId::new(13),
+                        delay: interval,
+                    },
+                );
+            })
+            .await
+            .unwrap();
+
+        assert_eq!(manager.remove_guild_tasks(guild_id).await.unwrap(), (2, 2));
+
+        assert_eq!(manager.task_count(guild_id).await, 0);
+        assert!(manager.scheduled_purges(guild_id).await.is_empty());
+        let settings = manager.guild_settings(guild_id).await;
+        assert!(settings.event_purges.is_empty());
+        assert!(settings.policies.contains_key("spam"));
+        assert_eq!(manager.task_count(other_guild).await, 1);
+        assert_eq!(manager.remove_guild_tasks(guild_id).await.unwrap(), (0, 0));
+
+        let reloaded = AutocleanManager::new(Arc::clone(&kv_store));
+        reloaded.load_tasks().await.unwrap();
+        assert_eq!(reloaded.task_count(guild_id).await, 0);
+        assert!(reloaded.scheduled_purges(guild_id).await.is_empty());
+        assert_eq!(reloaded.task_count(other_guild).await, 1);
+    });
+}
+
+#[test]
+fn test_move_task() {
+    let rt = Runtime::new().unwrap();
+    rt.block_on(async {
+        let db_path = unique_test_db();
+        let _cleanup = TestCleanup {
+            path: db_path.clone(),
+        };
+        let kv_store = Arc::new(KvStore::new(db_path).unwrap());
+        let manager = AutocleanManager::new(Arc::clone(&kv_store));
+        let guild_id = GuildId::new(1);
+        let (old, new, taken) = (ChannelId::new(10), ChannelId::new(11), ChannelId::new(12));
+        let interval = Duration::from_secs(6 * 3600);
+        manager.add_task(guild_id, old, interval).await.unwrap();
+        manager.add_task(guild_id, taken, interval).await.unwrap();
+        manager.set_keep_pinned(guild_id, old, true).await.unwrap();
+        manager.set_summary(guild_id, old, Some(old)).await.unwrap();
+        let at = SerializableInstant::now();
+        manager.schedule_purge(guild_id, old, at).await.unwrap();
+
+        assert_eq!(
+            manager.move_task(guild_id, old, taken).await.unwrap(),
+            TaskMove::Occupied
+        );
+        assert_eq!(
+            manager.move_task(guild_id, new, old).await.unwrap(),
+            TaskMove::NoTask
+        );
+        assert_eq!(
+            manager.move_task(guild_id, old, new).await.unwrap(),
+            TaskMove::Moved
+        );
+
+        assert!(manager.task(guild_id, old).await.is_none());
+        let task = manager.task(guild_id, new).await.unwrap();
+        assert_eq!(task.interval, interval);
+        assert!(task.keep_pinned);
+        assert_eq!(task.summary, Some(new));
+        assert_eq!(manager.scheduled_purges(guild_id).await, vec![(new, at)]);
+
+        let reloaded = AutocleanManager::new(Arc::clone(&kv_store));
+        reloaded.load_tasks().await.unwrap();
+        assert!(reloaded.task(guild_id, new).await.is_some());
+        assert_eq!(reloaded.task_count(guild_id).await, 2);
+    });
+}
@@ -0,0 +1,81 @@
+use eule::{
+    purge::BlockedDomains,
+    tasks::{
+        automod::{
+            actions, keyword_filters, phishing_filters, AutomodRule, INVITE_PATTERN, MAX_KEYWORDS,
+            MAX_KEYWORD_LENGTH,
+        },
+        guild_settings::GuildSettings,
+    },
+};
+use poise::serenity_prelude::{
+    automod::{Action, Trigger},
+    ChannelId, RuleId,
+};
+
+#[test]
+fn test_keyword_filters_match_anywhere() {
+    let long = "x".repeat(MAX_KEYWORD_LENGTH);
+    assert_eq!(
+        keyword_filters([
+            "Free Nitro",
+            " free nitro ",
+            "",
+            long.as_str(),
+            "steam gift"
+        ]),
+        vec!["*free nitro*", "*steam gift*"]
+    );
+
+    let many: Vec<String> = (0..MAX_KEYWORDS + 10).map(|i| format!("k{}", i)).collect();
+    assert_eq!(keyword_filters(&many).len(), MAX_KEYWORDS);
+}
+
+#[test]
+fn test_phishing_filters_are_sorted() {
+    let domains = BlockedDomains::new(["https://b.example", "a.example", "tld"]);
+    assert_eq!(
+        phishing_filters(&domains),
+        vec!["*a.example*", "*b.example*"]
+    );
+}
+
+#[test]
+fn test_rules_block_and_alert() {
+    assert!(matches!(
+        AutomodRule::Invites.trigger(Vec::new()),
+        Trigger::Keyword { strings, regex_patterns, .. }
+            if strings.is_empty() && regex_patterns == vec![INVITE_PATTERN.to_string()]
+    ));
+    assert!(matches!(
+        AutomodRule::Keywords.trigger(vec!["*spam*".to_string()]),
+        Trigger::Keyword { strings, regex_patterns, .. }
+            if strings == vec!["*spam*".to_string()] && regex_patterns.is_empty()
+    ));
+
+    assert!(matches!(
+        actions(None).as_slice(),
+        [Action::BlockMessage { .. }]
+    ));
+    assert!(matches!(
+        actions(Some(ChannelId::new(3))).as_slice(),
+        [Action::BlockMessage { .. }, Action::Alert(channel)] if *channel == ChannelId::new(3)
+    ));
+}
+
+#[test]
+fn test_rule_ids_are_saved() {
+    let mut settings = GuildSettings::default();
+    settings
+        .automod_rules
+        .insert(AutomodRule::Phishing, RuleId::new(42));
+
+    let json = serde_json::to_string(&settings).unwrap();
+    assert!(json.contains(r#""phishing":"42""#));
+    let loaded: GuildSettings = serde_json::from_str(&json).unwrap();
+    assert_eq!(loaded.automod_rules, settings.automod_rules);
+
+    // Settings saved before AutoMod rules existed still load
+    let old: GuildSettings = serde_json::from_str("{}").unwrap();
+    assert!(old.automod_rules.is_empty());
+}
@@ -0,0 +1,75 @@
+use eule::{
+    bot_lists::{stats_body, stats_url, DEFAULT_INTERVAL},
+    config::BotConfig,
+    EuleError,
+};
+use poise::serenity_prelude::UserId;
+use serde_json::json;
+use tokio::time::Duration;
+
+const SITES: &str = r#"
+[bot_lists]
+interval_secs = 900
+
+[[bot_lists.sites]]
+name = "topgg"
+url = "https://top.gg/api/bots/{bot_id}/stats"
+token = "secret"
+shard_field = "shard_count"
+
+[[bot_lists.sites]]
+name = "bots-gg"
+url = "https://discord.bots.gg/api/v1/bots/{bot_id}/stats"
+guild_field = "guildCount"
+"#;
+
+#[test]
+fn test_stats_are_posted_in_each_sites_fields() {
+    let config = BotConfig::from_toml(SITES).unwrap();
+    let [topgg, bots_gg] = config.bot_lists.sites.as_slice() else {
+        panic!("expected two sites");
+    };
+
+    assert_eq!(
+        stats_url(topgg, UserId::new(42)),
+        "https://top.gg/api/bots/42/stats"
+    );
+    assert_eq!(
+        stats_body(topgg, 1204, 2),
+        json!({ "server_count": 1204, "shard_count": 2 })
+    );
+    assert_eq!(stats_body(bots_gg, 1204, 2), json!({ "guildCount": 1204 }));
+}
+
+#[test]
+fn test_bot_list_config() {
+    let config = BotConfig::from_toml(SITES).unwrap();
+    assert_eq!(config.bot_lists.interval(), Duration::from_secs(900));
+    assert_eq!(BotConfig::default().bot_lists.interval(), DEFAULT_INTERVAL);
+    assert!(BotConfig::default().bot_lists.sites.is_empty());
+
+    let [topgg, bots_gg] = config.bot_lists.sites.as_slice() else {
+        panic!("expected two sites");
+    };
+    assert_eq!(bots_gg.token_env_var(), "EULE_BOT_LIST_TOKEN_BOTS_GG");
+    assert_eq!(
+        topgg.resolve_token(Some("env".to_string())).as_deref(),
+        Some("secret")
+    );
+    assert_eq!(
+        bots_gg.resolve_token(Some(" env ".to_string())).as_deref(),
+        Some("env")
+    );
+    assert_eq!(bots_gg.resolve_token(None), None);
+
+    for invalid in [
+        "[bot_lists]\ninterval_secs = 10\n",
+        "[[bot_lists.sites]]\nname = \"a\"\nurl = \"top.gg/api\"\n",
+        "[[bot_lists.sites]]\nname = \"a\"\nurl = \"https://top.gg\"\nidentity = \"missing\"\n",
+    ] {
+        assert!(matches!(
+            BotConfig::from_toml(invalid),
+            Err(EuleError::InvalidConfig(_))
+        ));
+    }
+}
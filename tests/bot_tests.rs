@@ -1,6 +1,7 @@
 mod test_utils;
 
-use eule::{store::KvStore, Bot, EuleError};
+use eule::{resolve_token, store::KvStore, Bot, EuleError};
+use poise::serenity_prelude::{ChannelId, GuildId};
 use std::sync::Arc;
 use test_utils::{unique_test_path, TestCleanup};
 use tokio::time::Duration;
@@ -27,6 +28,31 @@ async fn test_bot_creation() -> Result<(), EuleError> {
     Ok(())
 }
 
+#[tokio::test]
+async fn test_identities_keep_separate_tasks() -> Result<(), EuleError> {
+    let test_path = unique_test_path();
+    let _cleanup = TestCleanup::new(test_path.clone())?;
+    let kv_store = Arc::new(KvStore::new(&test_path)?);
+    let (guild_id, channel_id) = (GuildId::new(1), ChannelId::new(2));
+
+    let first = Bot::for_identity(Arc::clone(&kv_store), "community-a").await?;
+    assert_eq!(first.identity(), Some("community-a"));
+    first
+        .autoclean_manager()
+        .add_task(guild_id, channel_id, Duration::from_secs(3600))
+        .await?;
+
+    let second = Bot::for_identity(Arc::clone(&kv_store), "community-b").await?;
+    assert_eq!(second.autoclean_manager().total_task_count().await, 0);
+    let reloaded = Bot::for_identity(Arc::clone(&kv_store), "community-a").await?;
+    assert_eq!(reloaded.autoclean_manager().total_task_count().await, 1);
+    let single = Bot::with_store(kv_store).await?;
+    assert_eq!(single.identity(), None);
+    assert_eq!(single.autoclean_manager().total_task_count().await, 0);
+
+    Ok(())
+}
+
 #[tokio::test]
 async fn test_bot_uptime() -> Result<(), EuleError> {
     let (bot, _cleanup) = setup_test_bot().await?;
@@ -39,3 +65,76 @@ async fn test_bot_uptime() -> Result<(), EuleError> {
 
     Ok(())
 }
+
+#[tokio::test]
+async fn test_reload_config_keeps_old_config_on_error() -> Result<(), EuleError> {
+    let (bot, _cleanup) = setup_test_bot().await?;
+    let path = unique_test_path().with_extension("toml");
+    let bot = bot.with_config_path(Some(path.clone()));
+    assert_eq!(bot.config().replies.delete_after(), None);
+
+    std::fs::write(&path, "[replies]\ndelete_after_secs = 30\n")?;
+    bot.reload_config()?;
+    assert_eq!(
+        bot.config().replies.delete_after(),
+        Some(Duration::from_secs(30))
+    );
+
+    std::fs::write(&path, "[replies]\ndelete_after = 30\n")?;
+    assert!(bot.reload_config().is_err());
+    assert_eq!(
+        bot.config().replies.delete_after(),
+        Some(Duration::from_secs(30))
+    );
+    std::fs::remove_file(&path)?;
+
+    Ok(())
+}
+
+#[test]
+fn test_resolve_token_precedence() -> Result<(), EuleError> {
+    let test_path = unique_test_path();
+    let _cleanup = TestCleanup::new(test_path.clone())?;
+    let token_file = test_path.join("token");
+    std::fs::write(&token_file, "file_token\n")?;
+
+    let token = resolve_token(
+        Some("flag_token".to_string()),
+        Some(&token_file),
+        Some("env_token".to_string()),
+    )?;
+    assert_eq!(token.as_deref(), Some("flag_token"));
+
+    let token = resolve_token(None, Some(&token_file), Some("env_token".to_string()))?;
+    assert_eq!(token.as_deref(), Some("file_token"));
+
+    let token = resolve_token(None, None, Some("env_token".to_string()))?;
+    assert_eq!(token.as_deref(), Some("env_token"));
+
+    assert!(resolve_token(None, None, None)?.is_none());
+
+    Ok(())
+}
+
+#[test]
+fn test_resolve_token_rejects_empty_sources() -> Result<(), EuleError> {
+    let test_path = unique_test_path();
+    let _cleanup = TestCleanup::new(test_path.clone())?;
+    let token_file = test_path.join("token");
+    std::fs::write(&token_file, "  \n")?;
+
+    assert!(matches!(
+        resolve_token(None, Some(&token_file), None),
+        Err(EuleError::AuthenticationFailed(_))
+    ));
+    assert!(matches!(
+        resolve_token(None, Some(&test_path.join("missing")), None),
+        Err(EuleError::AuthenticationFailed(_))
+    ));
+    assert!(matches!(
+        resolve_token(None, None, Some(String::new())),
+        Err(EuleError::AuthenticationFailed(_))
+    ));
+
+    Ok(())
+}
@@ -0,0 +1,110 @@
+mod test_utils;
+
+use eule::{
+    admin::calendar::{calendar_key, feed_token, feed_url, handle, render},
+    config::BotConfig,
+    store::KvStore,
+    tasks::CleanupTask,
+    utils::SerializableInstant,
+};
+use hyper::StatusCode;
+use poise::serenity_prelude::{ChannelId, GuildId};
+use std::time::{Duration, UNIX_EPOCH};
+use test_utils::{unique_test_path, TestCleanup};
+
+fn at(unix_secs: u64) -> SerializableInstant {
+    SerializableInstant::from_system_time(UNIX_EPOCH + Duration::from_secs(unix_secs))
+}
+
+#[test]
+fn test_render_lists_purges_within_horizon() {
+    // 2026-10-16 09:30:00 UTC
+    let now = at(1_792_143_000);
+    let daily = CleanupTask::starting_at(Duration::from_secs(24 * 60 * 60), now);
+    let tasks = vec![(ChannelId::new(42), daily)];
+
+    let feed = render(GuildId::new(7), &tasks, now);
+
+    assert!(feed.starts_with("BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"));
+    assert!(feed.ends_with("END:VCALENDAR\r\n"));
+    assert_eq!(feed.matches("BEGIN:VEVENT").count(), 30);
+    assert!(feed.contains("DTSTART:20261017T093000Z\r\n"));
+    assert!(feed.contains("UID:42-1792229400@eule\r\n"));
+    assert!(feed.contains("SUMMARY:Purge of channel 42\r\n"));
+    assert!(!feed.contains("DTSTART:20261116T093000Z"));
+}
+
+#[test]
+fn test_render_caps_short_intervals() {
+    let now = at(1_792_143_000);
+    let tasks = vec![(
+        ChannelId::new(42),
+        CleanupTask::starting_at(Duration::from_secs(60), now),
+    )];
+    let feed = render(GuildId::new(7), &tasks, now);
+    assert_eq!(feed.matches("BEGIN:VEVENT").count(), 200);
+}
+
+#[test]
+fn test_feed_tokens_are_per_guild() {
+    let token = feed_token("key", GuildId::new(1));
+    assert_eq!(token.len(), 32);
+    assert_eq!(token, feed_token("key", GuildId::new(1)));
+    assert_ne!(token, feed_token("key", GuildId::new(2)));
+    assert_ne!(token, feed_token("other", GuildId::new(1)));
+    assert_eq!(
+        feed_url("https://eule.example.com/", "key", GuildId::new(1)),
+        format!("https://eule.example.com/calendar/1.ics?token={}", token)
+    );
+}
+
+#[tokio::test]
+async fn test_handle_checks_token() {
+    let guild_id = GuildId::new(5);
+    let token = feed_token("key", guild_id);
+    let tasks = |_| async { Vec::new() };
+
+    let path = format!("/calendar/5.ics?token={}", token);
+    let (status, body) = handle(Some("key"), tasks, &path).await;
+    assert_eq!(status, StatusCode::OK);
+    assert!(body.contains("BEGIN:VCALENDAR"));
+
+    for path in [
+        "/calendar/5.ics".to_string(),
+        "/calendar/5.ics?token=wrong".to_string(),
+        format!("/calendar/6.ics?token={}", token),
+        format!("/calendar/five.ics?token={}", token),
+    ] {
+        let (status, _) = handle(Some("key"), tasks, &path).await;
+        assert_eq!(status, StatusCode::NOT_FOUND, "{}", path);
+    }
+    let (status, _) = handle(None, tasks, &format!("/calendar/5.ics?token={}", token)).await;
+    assert_eq!(status, StatusCode::NOT_FOUND);
+}
+
+#[tokio::test]
+async fn test_calendar_key_is_kept() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let store = KvStore::new(path).unwrap();
+    let key = calendar_key(&store).await.unwrap();
+    assert!(!key.is_empty());
+    assert_eq!(calendar_key(&store).await.unwrap(), key);
+}
+
+#[test]
+fn test_calendar_base_url() {
+    let config = BotConfig::from_toml(
+        "[admin]\nlisten = \"127.0.0.1:8080\"\n\n[dashboard]\nclient_id = 1\npublic_url = \"https://eule.example.com\"\n",
+    )
+    .unwrap();
+    assert_eq!(config.calendar_base_url(), Some("https://eule.example.com"));
+
+    let config = BotConfig::from_toml(
+        "[admin]\nlisten = \"127.0.0.1:8080\"\npublic_url = \"https://ops.example.com\"\n",
+    )
+    .unwrap();
+    assert_eq!(config.calendar_base_url(), Some("https://ops.example.com"));
+
+    assert_eq!(BotConfig::default().calendar_base_url(), None);
+}
@@ -0,0 +1,704 @@
+mod test_utils;
+
+use eule::{
+    purge::{PurgeReport, ThreadOptions},
+    store::KvStore,
+    tasks::{
+        cleanup_channel, state_dump::state_dump, AutocleanManager, CleanupTask, PurgeEventKind,
+        STATS_DAYS,
+    },
+    utils::SerializableInstant,
+};
+use poise::serenity_prelude::{ChannelId, GuildId, UserId};
+use std::{
+    collections::{BTreeSet, HashMap},
+    sync::{atomic::Ordering, Arc},
+};
+use test_utils::{
+    discord_server::DiscordServer, mock_discord::MockDiscord, unique_test_path, TestCleanup,
+};
+use tokio::{
+    sync::{watch, RwLock},
+    time::Duration,
+};
+
+const DAY: Duration = Duration::from_secs(24 * 60 * 60);
+
+type Tasks = Arc<RwLock<HashMap<GuildId, HashMap<ChannelId, CleanupTask>>>>;
+
+async fn tasks_for(guild_id: GuildId, channel_id: ChannelId) -> Tasks {
+    let mut guild_tasks = HashMap::new();
+    guild_tasks.insert(
+        channel_id,
+        CleanupTask::new(Duration::from_secs(3600)).await,
+    );
+    let mut tasks = HashMap::new();
+    tasks.insert(guild_id, guild_tasks);
+    Arc::new(RwLock::new(tasks))
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_pipeline_paginates_whole_channel() {
+    let api = MockDiscord::new();
+    let guild_id = GuildId::new(1);
+    let channel_id = ChannelId::new(2);
+    api.add_messages(channel_id, 250, Duration::from_secs(60));
+    let tasks = tasks_for(guild_id, channel_id).await;
+
+    cleanup_channel(&api, guild_id, channel_id, &tasks)
+        .await
+        .unwrap();
+
+    assert_eq!(api.remaining(channel_id), 0);
+    assert_eq!(api.bulk_deletes.load(Ordering::SeqCst), 3);
+    assert_eq!(api.fetches.load(Ordering::SeqCst), 3);
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_pipeline_deletes_old_messages_individually() {
+    let api = MockDiscord::new();
+    let guild_id = GuildId::new(1);
+    let channel_id = ChannelId::new(2);
+    api.add_messages(channel_id, 3, DAY * 30);
+    api.add_messages(channel_id, 10, Duration::from_secs(60));
+    let tasks = tasks_for(guild_id, channel_id).await;
+
+    cleanup_channel(&api, guild_id, channel_id, &tasks)
+        .await
+        .unwrap();
+
+    assert_eq!(api.remaining(channel_id), 0);
+    assert_eq!(api.bulk_deletes.load(Ordering::SeqCst), 1);
+    assert_eq!(api.single_deletes.load(Ordering::SeqCst), 3);
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_pipeline_single_recent_message() {
+    let api = MockDiscord::new();
+    let guild_id = GuildId::new(1);
+    let channel_id = ChannelId::new(2);
+    api.add_messages(channel_id, 1, Duration::from_secs(60));
+    let tasks = tasks_for(guild_id, channel_id).await;
+
+    cleanup_channel(&api, guild_id, channel_id, &tasks)
+        .await
+        .unwrap();
+
+    assert_eq!(api.remaining(channel_id), 0);
+    assert_eq!(api.bulk_deletes.load(Ordering::SeqCst), 0);
+    assert_eq!(api.single_deletes.load(Ordering::SeqCst), 1);
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_pipeline_retries_rate_limited_requests() {
+    let api = MockDiscord::with_rate_limit_every(3);
+    let guild_id = GuildId::new(1);
+    let channel_id = ChannelId::new(2);
+    api.add_messages(channel_id, 5, DAY * 20);
+    api.add_messages(channel_id, 150, Duration::from_secs(60));
+    let tasks = tasks_for(guild_id, channel_id).await;
+
+    cleanup_channel(&api, guild_id, channel_id, &tasks)
+        .await
+        .unwrap();
+
+    assert_eq!(api.remaining(channel_id), 0);
+    assert!(api.rate_limited.load(Ordering::SeqCst) > 0);
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_pipeline_empty_channel() {
+    let api = MockDiscord::new();
+    let guild_id = GuildId::new(1);
+    let channel_id = ChannelId::new(2);
+    let tasks = tasks_for(guild_id, channel_id).await;
+
+    cleanup_channel(&api, guild_id, channel_id, &tasks)
+        .await
+        .unwrap();
+
+    assert_eq!(api.fetches.load(Ordering::SeqCst), 1);
+    assert_eq!(api.bulk_deletes.load(Ordering::SeqCst), 0);
+}
+
+// The tests against `DiscordServer` run in real time, since they go through
+// sockets and Serenity's own rate limiter
+#[tokio::test]
+async fn test_http_client_paginates_whole_channel() {
+    let server = DiscordServer::start().await;
+    let http = server.http();
+    let guild_id = GuildId::new(1);
+    let channel_id = ChannelId::new(2);
+    server.add_messages(channel_id, 250, Duration::from_secs(60));
+    let tasks = tasks_for(guild_id, channel_id).await;
+
+    cleanup_channel(&http, guild_id, channel_id, &tasks)
+        .await
+        .unwrap();
+
+    assert_eq!(server.remaining(channel_id), 0);
+    assert_eq!(server.bulk_deletes(), 3);
+    assert_eq!(server.rejected(), 0);
+}
+
+#[tokio::test]
+async fn test_http_client_keeps_to_bulk_delete_limits() {
+    let server = DiscordServer::start().await;
+    let http = server.http();
+    let guild_id = GuildId::new(1);
+    let channel_id = ChannelId::new(2);
+    server.add_messages(channel_id, 3, DAY * 30);
+    server.add_messages(channel_id, 10, Duration::from_secs(60));
+    let tasks = tasks_for(guild_id, channel_id).await;
+
+    cleanup_channel(&http, guild_id, channel_id, &tasks)
+        .await
+        .unwrap();
+
+    assert_eq!(server.remaining(channel_id), 0);
+    assert_eq!(server.bulk_deletes(), 1);
+    assert_eq!(server.single_deletes(), 3);
+    assert_eq!(server.rejected(), 0);
+}
+
+#[tokio::test]
+async fn test_http_client_waits_out_rate_limits() {
+    let server = DiscordServer::with_rate_limit_every(3).await;
+    let http = server.http();
+    let guild_id = GuildId::new(1);
+    let channel_id = ChannelId::new(2);
+    server.add_messages(channel_id, 150, Duration::from_secs(60));
+    let tasks = tasks_for(guild_id, channel_id).await;
+
+    cleanup_channel(&http, guild_id, channel_id, &tasks)
+        .await
+        .unwrap();
+
+    assert_eq!(server.remaining(channel_id), 0);
+    assert!(server.rate_limited() > 0);
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_purge_now_records_cleanup() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let kv_store = Arc::new(KvStore::new(path).unwrap());
+    let manager = AutocleanManager::new(Arc::clone(&kv_store));
+    let api = MockDiscord::new();
+    let guild_id = GuildId::new(1);
+    let channel_id = ChannelId::new(2);
+    api.add_messages(channel_id, 20, Duration::from_secs(60));
+
+    manager.purge_now(&api, guild_id, channel_id).await.unwrap();
+
+    assert_eq!(api.remaining(channel_id), 0);
+    assert!(kv_store.get("cleanup_tasks").await.unwrap().is_some());
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_purged_today_counts_scheduled_tasks() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let manager = AutocleanManager::new(Arc::new(KvStore::new(path).unwrap()));
+    let api = MockDiscord::new();
+    let guild_id = GuildId::new(1);
+    manager
+        .add_task(guild_id, ChannelId::new(2), Duration::from_secs(3600))
+        .await
+        .unwrap();
+    manager
+        .add_task(guild_id, ChannelId::new(3), Duration::from_secs(3600))
+        .await
+        .unwrap();
+    api.add_messages(ChannelId::new(2), 20, Duration::from_secs(60));
+    api.add_messages(ChannelId::new(3), 5, Duration::from_secs(60));
+
+    manager
+        .purge_now(&api, guild_id, ChannelId::new(2))
+        .await
+        .unwrap();
+    manager
+        .purge_now(&api, guild_id, ChannelId::new(3))
+        .await
+        .unwrap();
+
+    assert_eq!(manager.purged_today().await, 25);
+    assert_eq!(manager.total_task_count().await, 2);
+}
+
+#[tokio::test]
+async fn test_daily_tally_resets_on_new_day() {
+    let mut task = CleanupTask::new(Duration::from_secs(3600)).await;
+
+    task.record_deleted(5, 100);
+    task.record_deleted(3, 100);
+    assert_eq!(task.deleted_on(100), 8);
+
+    task.record_deleted(2, 101);
+    assert_eq!(task.deleted_on(100), 0);
+    assert_eq!(task.deleted_on(101), 2);
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_task_include_threads() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let kv_store = Arc::new(KvStore::new(path).unwrap());
+    let manager = AutocleanManager::new(Arc::clone(&kv_store));
+    let api = MockDiscord::new();
+    let guild_id = GuildId::new(1);
+    let channel_id = ChannelId::new(2);
+    let thread = api.add_thread(channel_id, false);
+    api.add_messages(thread, 5, Duration::from_secs(60));
+
+    manager
+        .add_task(guild_id, channel_id, Duration::from_secs(3600))
+        .await
+        .unwrap();
+    manager.purge_now(&api, guild_id, channel_id).await.unwrap();
+    assert_eq!(api.remaining(thread), 5);
+
+    assert!(manager
+        .set_include_threads(guild_id, channel_id, true)
+        .await
+        .unwrap());
+    manager.purge_now(&api, guild_id, channel_id).await.unwrap();
+    assert_eq!(api.remaining(thread), 0);
+}
+
+#[tokio::test]
+async fn test_interactive_purges_are_exclusive_and_cancellable() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let manager = AutocleanManager::new(Arc::new(KvStore::new(path).unwrap()));
+    let guild_id = GuildId::new(1);
+    let channel_id = ChannelId::new(2);
+
+    assert!(!manager.cancel_purge(guild_id, channel_id).await);
+    let token = manager.begin_purge(channel_id).await.unwrap();
+    assert!(manager.begin_purge(channel_id).await.is_none());
+
+    assert!(manager.cancel_purge(guild_id, channel_id).await);
+    assert!(token.is_cancelled());
+
+    manager.end_purge(channel_id).await;
+    assert!(manager.begin_purge(channel_id).await.is_some());
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_drain_waits_for_purges_then_cancels_them() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let manager = AutocleanManager::new(Arc::new(KvStore::new(path).unwrap()));
+    let finished = ChannelId::new(2);
+    let stuck = ChannelId::new(3);
+    assert_eq!(manager.drain(Duration::from_secs(60)).await.unwrap(), 0);
+
+    manager.begin_purge(finished).await.unwrap();
+    let token = manager.begin_purge(stuck).await.unwrap();
+    assert_eq!(manager.running_purges().await, 2);
+    let ending = manager.clone();
+    tokio::spawn(async move {
+        tokio::time::sleep(Duration::from_secs(10)).await;
+        ending.end_purge(finished).await;
+    });
+
+    assert_eq!(manager.drain(Duration::from_secs(60)).await.unwrap(), 1);
+    assert!(token.is_cancelled());
+}
+
+#[tokio::test]
+async fn test_state_dump_lists_tasks_queue_and_running_purges() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let manager = AutocleanManager::new(Arc::new(KvStore::new(path).unwrap()));
+    let guild_id = GuildId::new(1);
+    let (scheduled, interactive) = (ChannelId::new(2), ChannelId::new(3));
+    manager
+        .add_task(guild_id, scheduled, Duration::from_secs(3600))
+        .await
+        .unwrap();
+    let soon = SerializableInstant::now() + Duration::from_secs(60);
+    manager
+        .schedule_purge(guild_id, interactive, soon)
+        .await
+        .unwrap();
+    manager.begin_purge(interactive).await.unwrap();
+
+    let dump = state_dump(&manager, false).await;
+
+    assert_eq!(dump.guilds.len(), 1);
+    assert_eq!(dump.guilds[0].tasks[0].channel_id, scheduled);
+    assert_eq!(dump.queue.len(), 2);
+    assert!(dump.queue[0].one_shot);
+    assert_eq!(dump.running.len(), 1);
+    assert_eq!(dump.running[0].channel_id, interactive);
+    assert_eq!(dump.running[0].guild_id, None);
+    assert!(dump.recent_errors.is_empty());
+    assert!(serde_json::to_string(&dump).is_ok());
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_purge_now_reports_progress_and_history() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let manager = AutocleanManager::new(Arc::new(KvStore::new(path).unwrap()));
+    let api = MockDiscord::new();
+    let guild_id = GuildId::new(1);
+    let channel_id = ChannelId::new(2);
+    api.add_messages(channel_id, 150, Duration::from_secs(60));
+    manager
+        .add_task(guild_id, channel_id, Duration::from_secs(3600))
+        .await
+        .unwrap();
+
+    let (progress, updates) = watch::channel(PurgeReport::default());
+    manager
+        .purge_now_with_progress(&api, guild_id, channel_id, progress)
+        .await
+        .unwrap();
+
+    assert_eq!(updates.borrow().deleted, 150);
+    let task = manager.task(guild_id, channel_id).await.unwrap();
+    assert_eq!(task.history.len(), 1);
+    assert_eq!(task.history[0].deleted, 150);
+    assert!(task.history[0].error.is_none());
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_purge_publishes_events() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let manager = AutocleanManager::new(Arc::new(KvStore::new(path).unwrap()));
+    let api = MockDiscord::new();
+    let guild_id = GuildId::new(1);
+    let channel_id = ChannelId::new(2);
+    api.add_messages(channel_id, 30, Duration::from_secs(60));
+    manager
+        .add_task(guild_id, channel_id, Duration::from_secs(3600))
+        .await
+        .unwrap();
+
+    let mut events = manager.subscribe_events();
+    manager.purge_now(&api, guild_id, channel_id).await.unwrap();
+
+    let started = events.recv().await.unwrap();
+    assert_eq!(started.kind, PurgeEventKind::Started);
+    assert_eq!(
+        (started.guild_id, started.channel_id),
+        (guild_id, channel_id)
+    );
+    let completed = events.recv().await.unwrap();
+    assert_eq!(completed.kind, PurgeEventKind::Completed);
+    assert_eq!(completed.deleted, 30);
+    assert!(!completed.cancelled);
+    assert!(completed.error.is_none());
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_opted_out_members_are_kept_while_allowed() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let manager = AutocleanManager::new(Arc::new(KvStore::new(path).unwrap()));
+    let api = MockDiscord::new();
+    let guild_id = GuildId::new(1);
+    let channel_id = ChannelId::new(2);
+    let member = UserId::new(7);
+    manager
+        .add_task(guild_id, channel_id, Duration::from_secs(3600))
+        .await
+        .unwrap();
+
+    // Opting out is refused until the channel allows it
+    assert!(!manager
+        .set_opted_out(guild_id, channel_id, member, true)
+        .await
+        .unwrap());
+    assert!(manager
+        .set_allow_opt_out(guild_id, channel_id, true)
+        .await
+        .unwrap());
+    assert!(manager
+        .set_opted_out(guild_id, channel_id, member, true)
+        .await
+        .unwrap());
+
+    for _ in 0..3 {
+        api.post(channel_id, member, Duration::from_secs(60), false);
+    }
+    api.add_messages(channel_id, 4, Duration::from_secs(60));
+    manager.purge_now(&api, guild_id, channel_id).await.unwrap();
+    assert_eq!(api.remaining(channel_id), 3);
+
+    // Disallowing opt-outs suspends them without forgetting them
+    manager
+        .set_allow_opt_out(guild_id, channel_id, false)
+        .await
+        .unwrap();
+    let task = manager.task(guild_id, channel_id).await.unwrap();
+    assert!(task.opted_out.contains(&member));
+    manager.purge_now(&api, guild_id, channel_id).await.unwrap();
+    assert_eq!(api.remaining(channel_id), 0);
+}
+
+#[tokio::test]
+async fn test_daily_tallies_expire_after_stats_days() {
+    let mut task = CleanupTask::new(Duration::from_secs(3600)).await;
+
+    task.record_deleted(5, 10);
+    task.record_deleted(7, 10);
+    task.record_deleted(3, 50);
+    assert_eq!(task.deleted_since(0), 15);
+    assert_eq!(task.deleted_since(11), 3);
+
+    task.record_deleted(1, 10 + STATS_DAYS);
+    assert_eq!(task.deleted_since(0), 4);
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_top_channels_ranks_by_deletions() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let manager = AutocleanManager::new(Arc::new(KvStore::new(path).unwrap()));
+    let api = MockDiscord::new();
+    let guild_id = GuildId::new(1);
+    for (channel, count) in [(2, 5), (3, 20), (4, 0)] {
+        let channel_id = ChannelId::new(channel);
+        manager
+            .add_task(guild_id, channel_id, Duration::from_secs(3600))
+            .await
+            .unwrap();
+        api.add_messages(channel_id, count, Duration::from_secs(60));
+        manager.purge_now(&api, guild_id, channel_id).await.unwrap();
+    }
+
+    let ranking = manager.top_channels(guild_id, 0, 10, None).await;
+
+    assert_eq!(
+        ranking,
+        vec![(ChannelId::new(3), 20), (ChannelId::new(2), 5)]
+    );
+    assert_eq!(manager.top_channels(guild_id, 0, 1, None).await.len(), 1);
+
+    manager
+        .set_labels(
+            guild_id,
+            ChannelId::new(2),
+            BTreeSet::from(["spam".to_string()]),
+        )
+        .await
+        .unwrap();
+    assert_eq!(
+        manager.top_channels(guild_id, 0, 10, Some("Spam")).await,
+        vec![(ChannelId::new(2), 5)]
+    );
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_task_keep_first_message() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let kv_store = Arc::new(KvStore::new(path).unwrap());
+    let manager = AutocleanManager::new(Arc::clone(&kv_store));
+    let api = MockDiscord::new();
+    let guild_id = GuildId::new(1);
+    let channel_id = ChannelId::new(2);
+    let rules = api.post(channel_id, UserId::new(1), DAY * 30, false);
+    api.add_messages(channel_id, 5, Duration::from_secs(60));
+
+    manager
+        .add_task(guild_id, channel_id, Duration::from_secs(3600))
+        .await
+        .unwrap();
+    assert!(manager
+        .set_keep_first_message(guild_id, channel_id, true)
+        .await
+        .unwrap());
+    manager.purge_now(&api, guild_id, channel_id).await.unwrap();
+    assert!(api.has_message(channel_id, rules));
+    assert_eq!(api.remaining(channel_id), 1);
+
+    // Later cleanups keep the same message, since nothing is older
+    api.add_messages(channel_id, 3, Duration::from_secs(60));
+    manager.purge_now(&api, guild_id, channel_id).await.unwrap();
+    assert!(api.has_message(channel_id, rules));
+    assert_eq!(api.remaining(channel_id), 1);
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_thread_task_archives_instead_of_purging() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let kv_store = Arc::new(KvStore::new(path).unwrap());
+    let manager = AutocleanManager::new(Arc::clone(&kv_store));
+    let api = MockDiscord::new();
+    let guild_id = GuildId::new(1);
+    let channel_id = ChannelId::new(2);
+    api.add_messages(channel_id, 5, Duration::from_secs(60));
+    let stale = api.add_forum_post(channel_id, DAY * 10, Vec::new(), false);
+
+    manager.add_task(guild_id, channel_id, DAY).await.unwrap();
+    assert!(manager
+        .set_thread_options(guild_id, channel_id, Some(ThreadOptions::new(DAY * 7)))
+        .await
+        .unwrap());
+    manager.purge_now(&api, guild_id, channel_id).await.unwrap();
+
+    assert!(api.is_archived(stale));
+    assert_eq!(api.remaining(channel_id), 5);
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_nuke_task_follows_channel_copy() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let kv_store = Arc::new(KvStore::new(path).unwrap());
+    let manager = AutocleanManager::new(Arc::clone(&kv_store));
+    let api = MockDiscord::new();
+    let guild_id = GuildId::new(1);
+    let channel_id = ChannelId::new(2);
+    api.add_messages(channel_id, 5, Duration::from_secs(60));
+
+    manager.add_task(guild_id, channel_id, DAY).await.unwrap();
+    assert!(manager.set_nuke(guild_id, channel_id, true).await.unwrap());
+    manager.purge_now(&api, guild_id, channel_id).await.unwrap();
+
+    assert!(api.is_deleted(channel_id));
+    assert!(manager.task(guild_id, channel_id).await.is_none());
+    let tasks = manager.list_tasks(guild_id).await;
+    assert_eq!(tasks.len(), 1);
+    let (copy, _) = tasks[0];
+    assert!(!api.is_deleted(copy));
+    let task = manager.task(guild_id, copy).await.unwrap();
+    assert!(task.nuke);
+    assert_eq!(task.history.len(), 1);
+}
+
+#[tokio::test]
+async fn test_nuke_refuses_settings_that_keep_messages() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let manager = AutocleanManager::new(Arc::new(KvStore::new(path).unwrap()));
+    let guild_id = GuildId::new(1);
+    let channel_id = ChannelId::new(2);
+    manager.add_task(guild_id, channel_id, DAY).await.unwrap();
+
+    assert!(manager
+        .set_keep_keywords(guild_id, channel_id, vec!["#keep".to_string()])
+        .await
+        .unwrap());
+    assert!(manager.set_nuke(guild_id, channel_id, true).await.is_err());
+    assert!(!manager.task(guild_id, channel_id).await.unwrap().nuke);
+
+    assert!(manager
+        .set_keep_keywords(guild_id, channel_id, Vec::new())
+        .await
+        .unwrap());
+    assert!(manager.set_nuke(guild_id, channel_id, true).await.unwrap());
+    assert!(manager
+        .set_keep_first_message(guild_id, channel_id, true)
+        .await
+        .is_err());
+    let task = manager.task(guild_id, channel_id).await.unwrap();
+    assert!(task.nuke);
+    assert!(!task.keep_first_message);
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_nuke_task_keeping_messages_is_purged_instead() {
+    let api = MockDiscord::new();
+    let guild_id = GuildId::new(1);
+    let channel_id = ChannelId::new(2);
+    api.add_messages(channel_id, 5, Duration::from_secs(60));
+    let tasks = tasks_for(guild_id, channel_id).await;
+    // As saved before such settings were refused
+    if let Some(task) = tasks
+        .write()
+        .await
+        .get_mut(&guild_id)
+        .unwrap()
+        .get_mut(&channel_id)
+    {
+        task.nuke = true;
+        task.keep_first_message = true;
+    }
+
+    cleanup_channel(&api, guild_id, channel_id, &tasks)
+        .await
+        .unwrap();
+
+    assert!(!api.is_deleted(channel_id));
+    assert_eq!(api.remaining(channel_id), 1);
+}
+
+#[tokio::test]
+async fn test_held_channel_is_never_purged() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let manager = AutocleanManager::new(Arc::new(KvStore::new(path).unwrap()));
+    let api = MockDiscord::new();
+    let guild_id = GuildId::new(1);
+    let channel_id = ChannelId::new(2);
+    let (moderator, admin) = (UserId::new(7), UserId::new(8));
+    api.add_messages(channel_id, 10, Duration::from_secs(60));
+    manager
+        .add_task(guild_id, channel_id, Duration::from_secs(3600))
+        .await
+        .unwrap();
+    let running = manager.begin_purge(channel_id).await.unwrap();
+
+    let reason = Some("investigation".to_string());
+    assert!(manager
+        .place_hold(guild_id, channel_id, moderator, reason.clone())
+        .await
+        .unwrap());
+    assert!(!manager
+        .place_hold(guild_id, channel_id, admin, None)
+        .await
+        .unwrap());
+    assert!(running.is_cancelled());
+    assert!(manager.is_held(guild_id, channel_id).await);
+    assert!(manager.purge_now(&api, guild_id, channel_id).await.is_err());
+    assert_eq!(api.remaining(channel_id), 10);
+
+    assert!(manager
+        .lift_hold(guild_id, channel_id, admin)
+        .await
+        .unwrap());
+    assert!(!manager
+        .lift_hold(guild_id, channel_id, admin)
+        .await
+        .unwrap());
+    manager.purge_now(&api, guild_id, channel_id).await.unwrap();
+    assert_eq!(api.remaining(channel_id), 0);
+
+    let log = manager.guild_settings(guild_id).await.hold_log;
+    assert_eq!(log.len(), 2);
+    assert!(log[0].placed);
+    assert_eq!((log[0].by, &log[0].reason), (moderator, &reason));
+    assert!(!log[1].placed);
+    assert_eq!(log[1].by, admin);
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_pipeline_leaves_messages_over_the_cap_for_the_next_run() {
+    let api = MockDiscord::new();
+    let guild_id = GuildId::new(1);
+    let channel_id = ChannelId::new(2);
+    api.add_messages(channel_id, 250, Duration::from_secs(60));
+    let tasks = tasks_for(guild_id, channel_id).await;
+    tasks
+        .write()
+        .await
+        .get_mut(&guild_id)
+        .and_then(|guild_tasks| guild_tasks.get_mut(&channel_id))
+        .unwrap()
+        .max_deletions = Some(120);
+
+    for remaining in [130, 10, 0] {
+        cleanup_channel(&api, guild_id, channel_id, &tasks)
+            .await
+            .unwrap();
+        assert_eq!(api.remaining(channel_id), remaining);
+    }
+}
@@ -0,0 +1,198 @@
+mod test_utils;
+
+use eule::{
+    store::KvStore,
+    tasks::{guild_settings::EventPurge, AutocleanManager, CleanupTask},
+    utils::clock::{Clock, MockClock},
+};
+use poise::serenity_prelude::{ChannelId, GuildId, ScheduledEventId};
+use std::sync::Arc;
+use test_utils::{mock_discord::MockDiscord, unique_test_path, TestCleanup};
+use tokio::time::Duration;
+
+#[test]
+fn test_mock_clock_advance() {
+    let clock = MockClock::default();
+    let start = clock.now();
+
+    clock.advance(Duration::from_secs(3600));
+
+    assert_eq!(clock.now().duration_since(start), Duration::from_secs(3600));
+}
+
+#[test]
+fn test_cleanup_task_next_cleanup() {
+    let clock = MockClock::default();
+    let task = CleanupTask::starting_at(Duration::from_secs(600), clock.now());
+
+    assert!(!task.is_due_at(clock.now()));
+
+    clock.advance(Duration::from_secs(599));
+    assert!(!task.is_due_at(clock.now()));
+
+    clock.advance(Duration::from_secs(1));
+    assert!(task.is_due_at(clock.now()));
+    assert_eq!(task.next_cleanup(), clock.now());
+}
+
+#[tokio::test]
+async fn test_due_tasks_follow_clock() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let kv_store = Arc::new(KvStore::new(path).unwrap());
+    let clock = Arc::new(MockClock::default());
+    let manager = AutocleanManager::with_clock(kv_store, clock.clone());
+
+    let guild_id = GuildId::new(1);
+    let hourly = ChannelId::new(10);
+    let daily = ChannelId::new(20);
+    manager
+        .add_task(guild_id, hourly, Duration::from_secs(3600))
+        .await
+        .unwrap();
+    manager
+        .add_task(guild_id, daily, Duration::from_secs(86400))
+        .await
+        .unwrap();
+
+    assert!(manager.due_tasks().await.is_empty());
+
+    clock.advance(Duration::from_secs(3600));
+    assert_eq!(manager.due_tasks().await, vec![(guild_id, hourly)]);
+
+    clock.advance(Duration::from_secs(86400));
+    let mut due = manager.due_tasks().await;
+    due.sort();
+    assert_eq!(due, vec![(guild_id, hourly), (guild_id, daily)]);
+}
+
+#[tokio::test]
+async fn test_running_tasks_are_not_due_again() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let kv_store = Arc::new(KvStore::new(path).unwrap());
+    let clock = Arc::new(MockClock::default());
+    let manager = AutocleanManager::with_clock(kv_store, clock.clone());
+    let (guild_id, channel_id) = (GuildId::new(1), ChannelId::new(2));
+    let hour = Duration::from_secs(3600);
+    manager.add_task(guild_id, channel_id, hour).await.unwrap();
+    manager
+        .set_dry_run(guild_id, channel_id, true)
+        .await
+        .unwrap();
+
+    clock.advance(hour);
+    assert!(manager.begin_dry_run(guild_id, channel_id).await.is_some());
+    // The run outlasts its interval, but isn't queued again while it runs
+    clock.advance(hour * 2);
+    assert!(manager.due_tasks().await.is_empty());
+}
+
+#[tokio::test]
+async fn test_cleanups_are_recorded_at_the_clock_time() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let kv_store = Arc::new(KvStore::new(path).unwrap());
+    let clock = Arc::new(MockClock::default());
+    let manager = AutocleanManager::with_clock(kv_store, clock.clone());
+    let (guild_id, channel_id) = (GuildId::new(1), ChannelId::new(2));
+    let hour = Duration::from_secs(3600);
+    manager.add_task(guild_id, channel_id, hour).await.unwrap();
+    let api = MockDiscord::new();
+    api.add_messages(channel_id, 3, Duration::from_secs(60));
+
+    clock.advance(hour);
+    manager.purge_now(&api, guild_id, channel_id).await.unwrap();
+
+    let task = manager.task(guild_id, channel_id).await.unwrap();
+    assert_eq!(task.last_cleanup, clock.now());
+    assert_eq!(task.history.back().unwrap().at, clock.now());
+    assert!(manager.due_tasks().await.is_empty());
+}
+
+#[tokio::test]
+async fn test_one_shot_purge_is_claimed_once_when_due() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let kv_store = Arc::new(KvStore::new(path).unwrap());
+    let clock = Arc::new(MockClock::default());
+    let manager = AutocleanManager::with_clock(Arc::clone(&kv_store), clock.clone());
+    let guild_id = GuildId::new(1);
+    let reset = ChannelId::new(2);
+    let later = ChannelId::new(3);
+    let hour = Duration::from_secs(3600);
+    manager
+        .schedule_purge(guild_id, later, clock.now() + hour * 48)
+        .await
+        .unwrap();
+    manager
+        .schedule_purge(guild_id, reset, clock.now() + hour * 24)
+        .await
+        .unwrap();
+
+    let scheduled = manager.scheduled_purges(guild_id).await;
+    assert_eq!(
+        scheduled.iter().map(|(c, _)| *c).collect::<Vec<_>>(),
+        vec![reset, later]
+    );
+    assert!(manager.due_tasks().await.is_empty());
+
+    clock.advance(hour * 24);
+    let reloaded = AutocleanManager::with_clock(Arc::clone(&kv_store), clock.clone());
+    reloaded.load_tasks().await.unwrap();
+    assert_eq!(
+        reloaded.take_due_purges().await.unwrap(),
+        vec![(guild_id, reset)]
+    );
+    assert!(reloaded.take_due_purges().await.unwrap().is_empty());
+    assert!(reloaded.task(guild_id, reset).await.is_none());
+
+    assert!(reloaded
+        .cancel_scheduled_purge(guild_id, later)
+        .await
+        .unwrap()
+        .is_some());
+    assert!(reloaded.scheduled_purges(guild_id).await.is_empty());
+}
+
+#[tokio::test]
+async fn test_ended_event_schedules_its_purge_once() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let kv_store = Arc::new(KvStore::new(path).unwrap());
+    let clock = Arc::new(MockClock::default());
+    let manager = AutocleanManager::with_clock(kv_store, clock.clone());
+    let guild_id = GuildId::new(1);
+    let channel = ChannelId::new(2);
+    let event_id = ScheduledEventId::new(3);
+    let delay = Duration::from_secs(1800);
+    manager
+        .update_guild_settings(guild_id, |settings| {
+            settings
+                .event_purges
+                .insert(event_id, EventPurge { channel, delay });
+        })
+        .await
+        .unwrap();
+
+    assert_eq!(
+        manager.end_event(guild_id, event_id).await.unwrap(),
+        Some((channel, clock.now() + delay))
+    );
+    assert!(manager
+        .end_event(guild_id, event_id)
+        .await
+        .unwrap()
+        .is_none());
+    assert!(manager
+        .guild_settings(guild_id)
+        .await
+        .event_purges
+        .is_empty());
+
+    clock.advance(delay);
+    assert_eq!(
+        manager.take_due_purges().await.unwrap(),
+        vec![(guild_id, channel)]
+    );
+}
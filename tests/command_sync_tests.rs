@@ -0,0 +1,63 @@
+use eule::commands::sync::plan;
+use poise::serenity_prelude::{CommandId, CreateCommand};
+use serde_json::json;
+
+#[test]
+fn test_plan_creates_missing_commands() {
+    let desired = vec![CreateCommand::new("status").description("Show status")];
+
+    let sync_plan = plan(&[], desired);
+
+    assert_eq!(sync_plan.create.len(), 1);
+    assert!(sync_plan.update.is_empty());
+    assert!(sync_plan.delete.is_empty());
+}
+
+#[test]
+fn test_plan_skips_unchanged_commands() {
+    let registered = vec![(
+        CommandId::new(1),
+        json!({
+            "id": "1",
+            "name": "status",
+            "description": "Show status",
+            "options": [],
+            "nsfw": false,
+            "version": "42",
+        }),
+    )];
+    let desired = vec![CreateCommand::new("status").description("Show status")];
+
+    let sync_plan = plan(&registered, desired);
+
+    assert!(sync_plan.is_empty());
+    assert_eq!(sync_plan.unchanged, 1);
+}
+
+#[test]
+fn test_plan_updates_changed_and_deletes_stale_commands() {
+    let registered = vec![
+        (
+            CommandId::new(1),
+            json!({ "name": "status", "description": "Old description" }),
+        ),
+        (
+            CommandId::new(2),
+            json!({ "name": "purge", "description": "Renamed to clean" }),
+        ),
+    ];
+    let desired = vec![
+        CreateCommand::new("status").description("Show status"),
+        CreateCommand::new("clean").description("Clean a channel"),
+    ];
+
+    let sync_plan = plan(&registered, desired);
+
+    assert_eq!(sync_plan.create.len(), 1);
+    assert_eq!(sync_plan.update.len(), 1);
+    assert_eq!(sync_plan.update[0].0, CommandId::new(1));
+    assert_eq!(
+        sync_plan.delete,
+        vec![(CommandId::new(2), "purge".to_string())]
+    );
+}
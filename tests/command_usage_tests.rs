@@ -0,0 +1,56 @@
+mod test_utils;
+
+use eule::{
+    admin::{handle, AdminState},
+    commands::usage::{command_usage, record},
+    store::KvStore,
+    tasks::AutocleanManager,
+};
+use hyper::{Method, StatusCode};
+use std::sync::Arc;
+use test_utils::{mock_discord::MockDiscord, unique_test_path, TestCleanup};
+use tokio::time::Duration;
+
+#[test]
+fn test_invocations_are_counted_per_command() {
+    record("autoclean add", Duration::from_millis(100), false);
+    record("autoclean add", Duration::from_millis(300), true);
+    record("status", Duration::from_millis(50), false);
+
+    let usage = command_usage();
+    let add = usage["autoclean add"];
+    assert_eq!(add.invocations, 2);
+    assert_eq!(add.errors, 1);
+    assert_eq!(add.error_rate, 0.5);
+    assert_eq!(add.average_ms, 200);
+    assert_eq!(add.max_ms, 300);
+    assert_eq!(usage["status"].errors, 0);
+    assert!(!usage.contains_key("purge"));
+}
+
+#[tokio::test]
+async fn test_admin_api_serves_command_usage() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let state = AdminState {
+        manager: AutocleanManager::new(Arc::new(KvStore::new(path).unwrap())),
+        api: Arc::new(MockDiscord::new()),
+        token: Some("secret".to_string()),
+        dashboard: None,
+        calendar_key: None,
+        rotation: None,
+    };
+    record("language", Duration::from_millis(10), true);
+
+    let response = handle(
+        &state,
+        &Method::GET,
+        "/api/commands",
+        Some("Bearer secret"),
+        b"",
+    )
+    .await;
+    assert_eq!(response.status, StatusCode::OK);
+    assert_eq!(response.body["language"]["errors"], 1);
+    assert!(response.body["language"].get("total_ms").is_none());
+}
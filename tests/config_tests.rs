@@ -0,0 +1,121 @@
+mod test_utils;
+
+use eule::{
+    commands::config::config_overview,
+    i18n::Language,
+    store::KvStore,
+    tasks::{
+        guild_settings::{Announcements, GuildSettings, TaskDefaults},
+        AutocleanManager, CleanupTask,
+    },
+    utils::serializable_instant::{parse_local, parse_utc, parse_utc_offset},
+};
+use poise::serenity_prelude::{ChannelId, GuildId, RoleId, UserId};
+use std::sync::Arc;
+use test_utils::{unique_test_path, TestCleanup};
+use tokio::time::Duration;
+
+#[test]
+fn test_parse_utc_offset_accepts_common_spellings() {
+    assert_eq!(parse_utc_offset("+2"), Some(120));
+    assert_eq!(parse_utc_offset("UTC+05:30"), Some(330));
+    assert_eq!(parse_utc_offset(" -03:00 "), Some(-180));
+    assert_eq!(parse_utc_offset("+14:00"), Some(840));
+    assert_eq!(parse_utc_offset("+14:30"), None);
+    assert_eq!(parse_utc_offset("+2:75"), None);
+    assert_eq!(parse_utc_offset("2"), None);
+    assert_eq!(parse_utc_offset("ü"), None);
+}
+
+#[test]
+fn test_dates_are_read_in_the_guilds_time_zone() {
+    let utc = parse_utc("2026-06-01 12:00").unwrap();
+
+    assert_eq!(parse_local("2026-06-01 12:00", 0), Some(utc));
+    assert_eq!(
+        parse_local("2026-06-01 14:00", 120),
+        Some(utc),
+        "14:00 at UTC+2 is noon UTC"
+    );
+    assert_eq!(parse_local("2026-06-01 07:00", -300), Some(utc));
+}
+
+#[test]
+fn test_announcement_target_follows_the_setting() {
+    let channel_id = ChannelId::new(2);
+    let mut settings = GuildSettings::default();
+    assert_eq!(settings.announcement_target(channel_id), Some(channel_id));
+
+    settings.announcements = Announcements::LogChannel;
+    assert_eq!(settings.announcement_target(channel_id), None);
+    settings.log_channel = Some(ChannelId::new(3));
+    assert_eq!(
+        settings.announcement_target(channel_id),
+        Some(ChannelId::new(3))
+    );
+
+    settings.announcements = Announcements::Off;
+    assert_eq!(settings.announcement_target(channel_id), None);
+}
+
+#[test]
+fn test_config_overview_lists_settings() {
+    let mut settings = GuildSettings::default();
+    settings.utc_offset = 60;
+    settings.admin_roles.insert(RoleId::new(7));
+    settings.task_defaults.keep_pinned = true;
+
+    let overview = config_overview(Language::En, &settings);
+
+    assert!(overview.contains("Time zone: UTC+01:00"));
+    assert!(overview.contains("Admin roles: <@&7>"));
+    assert!(overview.contains("New tasks keep pinned messages: yes"));
+    assert!(overview.contains("New tasks keep the first message: no"));
+    assert!(overview.contains("Members only manage their own tasks: no"));
+}
+
+#[tokio::test]
+async fn test_members_may_only_manage_their_own_tasks() {
+    let mut settings = GuildSettings::default();
+    let mut task = CleanupTask::new(Duration::from_secs(3600)).await;
+    let (creator, other) = (UserId::new(7), UserId::new(8));
+    assert!(settings.may_manage(&task, other, false));
+
+    settings.own_tasks_only = true;
+    // Nobody but administrators owns tasks whose creator isn't known
+    assert!(!settings.may_manage(&task, creator, false));
+    assert!(settings.may_manage(&task, other, true));
+
+    task.created_by = Some(creator);
+    assert!(settings.may_manage(&task, creator, false));
+    assert!(!settings.may_manage(&task, other, false));
+    assert!(settings.may_manage(&task, other, true));
+}
+
+#[tokio::test]
+async fn test_new_tasks_start_with_guild_defaults() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let manager = AutocleanManager::new(Arc::new(KvStore::new(path).unwrap()));
+    let guild_id = GuildId::new(1);
+    manager
+        .update_guild_settings(guild_id, |settings| {
+            settings.task_defaults = TaskDefaults {
+                keep_pinned: true,
+                keep_first_message: false,
+                show_in_topic: true,
+            }
+        })
+        .await
+        .unwrap();
+
+    manager
+        .add_task(guild_id, ChannelId::new(2), Duration::from_secs(3600))
+        .await
+        .unwrap();
+
+    let task = manager.task(guild_id, ChannelId::new(2)).await.unwrap();
+    assert!(task.keep_pinned);
+    assert!(!task.keep_first_message);
+    assert!(task.show_in_topic);
+}
@@ -0,0 +1,103 @@
+mod test_utils;
+
+use eule::{
+    i18n::Language,
+    store::KvStore,
+    tasks::{
+        creator_notice::{notice_text, notify_creator},
+        AutocleanManager, PurgeEvent, PurgeEventKind,
+    },
+    utils::SerializableInstant,
+};
+use poise::serenity_prelude::{ChannelId, GuildId, UserId};
+use std::sync::Arc;
+use test_utils::{mock_discord::MockDiscord, unique_test_path, TestCleanup};
+use tokio::time::Duration;
+
+const DAY: Duration = Duration::from_secs(24 * 60 * 60);
+
+fn event(kind: PurgeEventKind, deleted: usize, error: Option<&str>) -> PurgeEvent {
+    PurgeEvent {
+        kind,
+        guild_id: GuildId::new(1),
+        channel_id: ChannelId::new(2),
+        at: SerializableInstant::now(),
+        deleted,
+        blocked_links: 0,
+        cancelled: false,
+        duration_ms: 1000,
+        error: error.map(str::to_string),
+        missing_permissions: false,
+    }
+}
+
+#[test]
+fn test_notice_text_reports_counts_and_errors() {
+    let next = SerializableInstant::now();
+
+    let completed = notice_text(
+        Language::En,
+        &event(PurgeEventKind::Completed, 1204, None),
+        next,
+    );
+    assert!(completed
+        .unwrap()
+        .starts_with("🧹 The cleanup of <#2> finished and deleted 1,204 messages."));
+
+    let failed = notice_text(
+        Language::En,
+        &event(PurgeEventKind::Failed, 0, Some("Missing Access")),
+        next,
+    );
+    assert_eq!(
+        failed.as_deref(),
+        Some("❌ The cleanup of <#2> failed: Missing Access")
+    );
+
+    assert_eq!(
+        notice_text(Language::En, &event(PurgeEventKind::Started, 0, None), next),
+        None
+    );
+    let mut cancelled = event(PurgeEventKind::Completed, 3, None);
+    cancelled.cancelled = true;
+    assert_eq!(notice_text(Language::En, &cancelled, next), None);
+}
+
+#[tokio::test]
+async fn test_only_creators_who_opted_in_are_told() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let manager = AutocleanManager::new(Arc::new(KvStore::new(path).unwrap()));
+    let api = MockDiscord::new();
+    let (guild_id, channel_id, creator) = (GuildId::new(1), ChannelId::new(2), UserId::new(7));
+    let completed = event(PurgeEventKind::Completed, 12, None);
+    let failed = event(PurgeEventKind::Failed, 0, Some("Missing Access"));
+
+    manager.add_task(guild_id, channel_id, DAY).await.unwrap();
+    assert!(manager
+        .set_notify_creator(guild_id, channel_id, true)
+        .await
+        .unwrap());
+    // Tasks without a known creator have nobody to tell
+    notify_creator(&manager, &api, &completed).await;
+    assert!(api.user_messages().is_empty());
+
+    manager
+        .record_creator(guild_id, channel_id, creator)
+        .await
+        .unwrap();
+    notify_creator(&manager, &api, &completed).await;
+    notify_creator(&manager, &api, &failed).await;
+    let messages = api.user_messages();
+    assert_eq!(messages.len(), 2);
+    assert!(messages.iter().all(|(user, _)| *user == creator));
+    assert!(messages[0].1.contains("deleted 12 messages"));
+    assert!(messages[1].1.ends_with("failed: Missing Access"));
+
+    manager
+        .set_notify_creator(guild_id, channel_id, false)
+        .await
+        .unwrap();
+    notify_creator(&manager, &api, &completed).await;
+    assert_eq!(api.user_messages().len(), 2);
+}
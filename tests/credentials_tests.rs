@@ -0,0 +1,53 @@
+use eule::{credentials::TokenRotation, purge::SharedHttp, EuleError};
+use poise::serenity_prelude::Http;
+use std::{fs, sync::Arc};
+
+#[tokio::test]
+async fn test_rotating_to_the_same_token_changes_nothing() {
+    let rotation = TokenRotation::new("current".to_string());
+    let mut rotations = rotation.subscribe();
+
+    assert!(!rotation.rotate(" current\n").await.unwrap());
+    assert!(!rotations.has_changed().unwrap());
+    assert_eq!(rotation.token(), "current");
+}
+
+#[tokio::test]
+async fn test_empty_tokens_are_rejected() {
+    let rotation = TokenRotation::new("current".to_string());
+
+    let result = rotation.rotate("  ").await;
+
+    assert!(matches!(result, Err(EuleError::AuthenticationFailed(_))));
+    assert_eq!(rotation.token(), "current");
+}
+
+#[tokio::test]
+async fn test_reload_file_reads_the_token_file() {
+    let path = std::env::temp_dir().join(format!("eule_token_{}", std::process::id()));
+    fs::write(&path, "current\n").unwrap();
+
+    let without_file = TokenRotation::new("current".to_string());
+    let with_file = TokenRotation::new("current".to_string()).with_file(path.clone());
+    let missing = TokenRotation::new("current".to_string()).with_file(path.join("missing"));
+
+    assert!(!without_file.reload_file().await.unwrap());
+    assert!(!with_file.reload_file().await.unwrap());
+    assert!(matches!(
+        missing.reload_file().await,
+        Err(EuleError::InvalidConfig(_))
+    ));
+    fs::remove_file(path).unwrap();
+}
+
+#[test]
+fn test_shared_http_follows_replacements() {
+    let old = Arc::new(Http::new("old"));
+    let new = Arc::new(Http::new("new"));
+    let shared = SharedHttp::new(Arc::clone(&old));
+    let clone = shared.clone();
+
+    shared.replace(Arc::clone(&new));
+
+    assert!(Arc::ptr_eq(&clone.current(), &new));
+}
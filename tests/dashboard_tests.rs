@@ -0,0 +1,222 @@
+mod test_utils;
+
+use async_trait::async_trait;
+use eule::{
+    admin::{
+        html::{escape, parse_query},
+        AdminState, Dashboard, DashboardResponse, OAuthApi, OAuthUser, UserGuild,
+    },
+    store::KvStore,
+    tasks::AutocleanManager,
+    EuleError,
+};
+use hyper::{Method, StatusCode};
+use poise::serenity_prelude::{ChannelId, GuildId, UserId};
+use std::sync::Arc;
+use test_utils::{mock_discord::MockDiscord, unique_test_path, TestCleanup};
+use tokio::time::Duration;
+
+/// Logs everyone in as the same user, who manages guild 1 but not guild 2.
+struct MockOAuth;
+
+#[async_trait]
+impl OAuthApi for MockOAuth {
+    fn authorize_url(&self, state: &str) -> String {
+        format!("https://discord.test/authorize?state={}", state)
+    }
+
+    async fn exchange_code(&self, code: &str) -> Result<String, EuleError> {
+        match code {
+            "good" => Ok("access".to_string()),
+            _ => Err(EuleError::OAuth("invalid_grant".to_string())),
+        }
+    }
+
+    async fn current_user(&self, _access_token: &str) -> Result<OAuthUser, EuleError> {
+        Ok(OAuthUser {
+            id: UserId::new(7),
+            username: "<owl>".to_string(),
+        })
+    }
+
+    async fn user_guilds(&self, _access_token: &str) -> Result<Vec<UserGuild>, EuleError> {
+        Ok(vec![
+            UserGuild {
+                id: GuildId::new(1),
+                name: "Parliament".to_string(),
+                owner: false,
+                permissions: (1u64 << 13).to_string(),
+            },
+            UserGuild {
+                id: GuildId::new(2),
+                name: "Elsewhere".to_string(),
+                owner: false,
+                permissions: "0".to_string(),
+            },
+        ])
+    }
+}
+
+async fn setup() -> (AdminState, Arc<Dashboard>, TestCleanup) {
+    let path = unique_test_path();
+    let cleanup = TestCleanup::new(path.clone()).unwrap();
+    let manager = AutocleanManager::new(Arc::new(KvStore::new(path).unwrap()));
+    for guild in [1, 2] {
+        manager
+            .add_task(
+                GuildId::new(guild),
+                ChannelId::new(10 + guild),
+                Duration::from_secs(3600),
+            )
+            .await
+            .unwrap();
+    }
+    let dashboard = Arc::new(Dashboard::new(Arc::new(MockOAuth), true));
+    let state = AdminState {
+        manager,
+        api: Arc::new(MockDiscord::new()),
+        token: None,
+        dashboard: Some(Arc::clone(&dashboard)),
+        calendar_key: None,
+        rotation: None,
+    };
+    (state, dashboard, cleanup)
+}
+
+async fn get(state: &AdminState, path: &str, cookie: Option<&str>) -> DashboardResponse {
+    let dashboard = state.dashboard.as_ref().unwrap();
+    dashboard
+        .handle(state, &Method::GET, path, cookie, b"")
+        .await
+}
+
+/// Completes a login and returns the session cookie to send.
+async fn log_in(state: &AdminState) -> String {
+    let login = get(state, "/login", None).await;
+    let location = login.location.unwrap();
+    let oauth_state = &parse_query(location.split_once('?').unwrap().1)["state"];
+
+    let callback = get(
+        state,
+        &format!("/callback?code=good&state={}", oauth_state),
+        None,
+    )
+    .await;
+    assert_eq!(callback.status, StatusCode::SEE_OTHER);
+    let cookie = callback.set_cookie.unwrap();
+    assert!(cookie.contains("HttpOnly"));
+    assert!(cookie.contains("Secure"));
+    cookie.split(';').next().unwrap().to_string()
+}
+
+#[tokio::test]
+async fn test_dashboard_login_shows_manageable_guilds() {
+    let (state, _dashboard, _cleanup) = setup().await;
+
+    let anonymous = get(&state, "/", None).await;
+    assert!(anonymous.body.contains("Log in with Discord"));
+
+    let cookie = log_in(&state).await;
+    let home = get(&state, "/", Some(&cookie)).await;
+    assert!(home.body.contains("Parliament"));
+    assert!(!home.body.contains("Elsewhere"));
+    assert!(home.body.contains(&escape("<owl>")));
+}
+
+#[tokio::test]
+async fn test_dashboard_rejects_unknown_login_state() {
+    let (state, _dashboard, _cleanup) = setup().await;
+
+    let forged = get(&state, "/callback?code=good&state=forged", None).await;
+    assert_eq!(forged.status, StatusCode::BAD_REQUEST);
+    assert!(forged.set_cookie.is_none());
+}
+
+#[tokio::test]
+async fn test_dashboard_checks_guild_permissions() {
+    let (state, _dashboard, _cleanup) = setup().await;
+
+    let anonymous = get(&state, "/guilds/1", None).await;
+    assert_eq!(anonymous.location.as_deref(), Some("/login"));
+
+    let cookie = log_in(&state).await;
+    let allowed = get(&state, "/guilds/1", Some(&cookie)).await;
+    assert_eq!(allowed.status, StatusCode::OK);
+    assert!(allowed.body.contains("#11"));
+    let forbidden = get(&state, "/guilds/2", Some(&cookie)).await;
+    assert_eq!(forbidden.status, StatusCode::FORBIDDEN);
+}
+
+#[tokio::test]
+async fn test_dashboard_edits_schedules() {
+    let (state, dashboard, _cleanup) = setup().await;
+    let cookie = log_in(&state).await;
+
+    let saved = dashboard
+        .handle(
+            &state,
+            &Method::POST,
+            "/guilds/1/tasks/11",
+            Some(&cookie),
+            b"interval_minutes=30&include_threads=on",
+        )
+        .await;
+    assert_eq!(saved.location.as_deref(), Some("/guilds/1"));
+    let task = state
+        .manager
+        .task(GuildId::new(1), ChannelId::new(11))
+        .await
+        .unwrap();
+    assert_eq!(task.interval, Duration::from_secs(30 * 60));
+    assert!(task.include_threads);
+
+    // Intervals the other APIs refuse, or that would overflow, are refused here too
+    for form in [
+        "interval_minutes=0".to_string(),
+        format!("interval_minutes={}", u64::MAX),
+    ] {
+        let refused = dashboard
+            .handle(
+                &state,
+                &Method::POST,
+                "/guilds/1/tasks/11",
+                Some(&cookie),
+                form.as_bytes(),
+            )
+            .await;
+        assert_eq!(refused.status, StatusCode::BAD_REQUEST);
+    }
+
+    // Another guild's task can't be reached through a guild the user manages
+    let foreign = dashboard
+        .handle(
+            &state,
+            &Method::POST,
+            "/guilds/1/tasks/12/delete",
+            Some(&cookie),
+            b"",
+        )
+        .await;
+    assert_eq!(foreign.status, StatusCode::NOT_FOUND);
+    assert!(state
+        .manager
+        .task(GuildId::new(2), ChannelId::new(12))
+        .await
+        .is_some());
+
+    let removed = dashboard
+        .handle(
+            &state,
+            &Method::POST,
+            "/guilds/1/tasks/11/delete",
+            Some(&cookie),
+            b"",
+        )
+        .await;
+    assert_eq!(removed.status, StatusCode::SEE_OTHER);
+    assert!(state
+        .manager
+        .task(GuildId::new(1), ChannelId::new(11))
+        .await
+        .is_none());
+}
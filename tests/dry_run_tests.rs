@@ -0,0 +1,91 @@
+mod test_utils;
+
+use eule::{
+    purge::{preview_purge, PurgeOptions, PREVIEW_PAGES},
+    store::KvStore,
+    tasks::{dry_run::rehearse, AutocleanManager},
+};
+use poise::serenity_prelude::{ChannelId, GuildId};
+use std::sync::Arc;
+use test_utils::{mock_discord::MockDiscord, unique_test_path, TestCleanup};
+use tokio::time::Duration;
+
+const DAY: Duration = Duration::from_secs(24 * 60 * 60);
+
+#[tokio::test]
+async fn test_preview_counts_without_deleting() {
+    let api = MockDiscord::new();
+    let channel_id = ChannelId::new(2);
+    api.add_messages(channel_id, 250, DAY);
+
+    let options = PurgeOptions {
+        keep_first_message: true,
+        ..Default::default()
+    };
+    let preview = preview_purge(&api, channel_id, &options, PREVIEW_PAGES)
+        .await
+        .unwrap();
+
+    assert!(preview.complete);
+    assert_eq!(preview.scanned, 250);
+    assert_eq!(preview.matched, 249);
+    assert_eq!(preview.samples.len(), 5);
+    assert_eq!(api.remaining(channel_id), 250);
+
+    let partial = preview_purge(&api, channel_id, &options, 1).await.unwrap();
+    assert!(!partial.complete);
+    assert_eq!(partial.matched, 100);
+}
+
+#[tokio::test]
+async fn test_first_scheduled_cleanup_is_a_dry_run() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let manager = AutocleanManager::new(Arc::new(KvStore::new(path).unwrap()));
+    let api = MockDiscord::new();
+    let guild_id = GuildId::new(1);
+    let channel_id = ChannelId::new(2);
+    api.add_messages(channel_id, 30, DAY);
+    manager
+        .add_task(guild_id, channel_id, Duration::from_secs(3600))
+        .await
+        .unwrap();
+
+    let options = manager.begin_dry_run(guild_id, channel_id).await.unwrap();
+    assert_eq!(manager.running_purges().await, 1);
+    assert!(rehearse(&manager, &api, guild_id, channel_id, options).await);
+
+    assert_eq!(api.remaining(channel_id), 30);
+    let owner_messages = api.owner_messages();
+    assert_eq!(owner_messages.len(), 1);
+    assert!(owner_messages[0].1.contains("<#2>"));
+    assert!(owner_messages[0]
+        .1
+        .contains("https://discord.com/channels/1/2/"));
+    assert_eq!(manager.running_purges().await, 0);
+    // Only the first cleanup is a dry run
+    assert!(manager.begin_dry_run(guild_id, channel_id).await.is_none());
+    assert!(!manager.task(guild_id, channel_id).await.unwrap().dry_run);
+}
+
+#[tokio::test]
+async fn test_failed_dry_run_is_tried_again() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let manager = AutocleanManager::new(Arc::new(KvStore::new(path).unwrap()));
+    let api = MockDiscord::new();
+    let guild_id = GuildId::new(1);
+    let channel_id = ChannelId::new(2);
+    api.add_messages(channel_id, 30, DAY);
+    api.revoke_access(channel_id);
+    manager
+        .add_task(guild_id, channel_id, Duration::from_secs(3600))
+        .await
+        .unwrap();
+
+    let options = manager.begin_dry_run(guild_id, channel_id).await.unwrap();
+    assert!(!rehearse(&manager, &api, guild_id, channel_id, options).await);
+
+    assert!(api.owner_messages().is_empty());
+    assert!(manager.task(guild_id, channel_id).await.unwrap().dry_run);
+}
@@ -0,0 +1,149 @@
+mod test_utils;
+
+use async_trait::async_trait;
+use eule::{
+    config::{BotConfig, EmailReportConfig, ReportPeriod},
+    notify::email::{period_ended, render, EmailReporter, Mailer, ReportTally},
+    store::KvStore,
+    tasks::{PurgeEvent, PurgeEventKind},
+    utils::SerializableInstant,
+    EuleError,
+};
+use poise::serenity_prelude::{ChannelId, GuildId};
+use std::sync::{Arc, Mutex};
+use test_utils::{unique_test_path, TestCleanup};
+
+/// Records emails instead of sending them.
+#[derive(Default)]
+struct MockMailer {
+    sent: Mutex<Vec<(Vec<String>, String, String)>>,
+}
+
+#[async_trait]
+impl Mailer for MockMailer {
+    async fn send(&self, to: &[String], subject: &str, body: &str) -> Result<(), EuleError> {
+        self.sent
+            .lock()
+            .unwrap()
+            .push((to.to_vec(), subject.to_string(), body.to_string()));
+        Ok(())
+    }
+}
+
+// 2026-10-01 and 2026-11-01 as days since the Unix epoch
+const OCTOBER_1: u64 = 20_727;
+const NOVEMBER_1: u64 = 20_758;
+
+fn event(guild: u64, kind: PurgeEventKind, deleted: usize, error: Option<&str>) -> PurgeEvent {
+    PurgeEvent {
+        kind,
+        guild_id: GuildId::new(guild),
+        channel_id: ChannelId::new(10),
+        at: SerializableInstant::now(),
+        deleted,
+        blocked_links: 0,
+        cancelled: false,
+        duration_ms: 0,
+        error: error.map(str::to_string),
+        missing_permissions: false,
+    }
+}
+
+#[test]
+fn test_report_periods() {
+    assert!(!period_ended(
+        ReportPeriod::Weekly,
+        OCTOBER_1,
+        OCTOBER_1 + 6
+    ));
+    assert!(period_ended(ReportPeriod::Weekly, OCTOBER_1, OCTOBER_1 + 7));
+    assert!(!period_ended(
+        ReportPeriod::Monthly,
+        OCTOBER_1,
+        NOVEMBER_1 - 1
+    ));
+    assert!(period_ended(ReportPeriod::Monthly, OCTOBER_1, NOVEMBER_1));
+    // Across a year boundary
+    assert!(period_ended(ReportPeriod::Monthly, 20_818, 20_820));
+}
+
+#[test]
+fn test_render_report() {
+    let tally = ReportTally {
+        since_day: OCTOBER_1,
+        runs: 31,
+        deleted: 1200,
+        cancelled: 0,
+        failures: 1,
+        errors: vec!["Missing Access".to_string()],
+    };
+    let (subject, body) = render(GuildId::new(1), ReportPeriod::Monthly, &tally, NOVEMBER_1);
+
+    assert_eq!(
+        subject,
+        "Monthly eule report for guild 1: 2026-10-01 to 2026-10-31"
+    );
+    assert!(body.contains("Messages deleted:   1,200"));
+    assert!(body.contains("  - Missing Access"));
+}
+
+#[tokio::test]
+async fn test_reporter_tallies_and_sends_due_reports() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let mailer = Arc::new(MockMailer::default());
+    let reports = [EmailReportConfig {
+        guild_id: 1,
+        to: vec!["owner@example.com".to_string()],
+        period: ReportPeriod::Weekly,
+    }];
+    let reporter = EmailReporter::new(
+        Arc::new(KvStore::new(path).unwrap()),
+        Arc::clone(&mailer) as Arc<dyn Mailer>,
+        &reports,
+    );
+
+    reporter
+        .record(&event(1, PurgeEventKind::Completed, 40, None))
+        .await
+        .unwrap();
+    reporter
+        .record(&event(1, PurgeEventKind::Failed, 0, Some("Missing Access")))
+        .await
+        .unwrap();
+    // Guilds without a report aren't tallied
+    reporter
+        .record(&event(2, PurgeEventKind::Completed, 5, None))
+        .await
+        .unwrap();
+
+    let tallies = reporter.tallies().await.unwrap();
+    assert_eq!(tallies.len(), 1);
+    let tally = &tallies[&GuildId::new(1)];
+    assert_eq!((tally.runs, tally.deleted, tally.failures), (2, 40, 1));
+
+    let today = SerializableInstant::now().utc_day();
+    reporter.send_due(today + 6).await.unwrap();
+    assert!(mailer.sent.lock().unwrap().is_empty());
+
+    reporter.send_due(today + 7).await.unwrap();
+    let sent = mailer.sent.lock().unwrap().clone();
+    assert_eq!(sent.len(), 1);
+    assert_eq!(sent[0].0, vec!["owner@example.com"]);
+    assert!(sent[0].2.contains("Cleanups failed:    1"));
+    let tally = &reporter.tallies().await.unwrap()[&GuildId::new(1)];
+    assert_eq!((tally.since_day, tally.runs), (today + 7, 0));
+}
+
+#[test]
+fn test_email_config() {
+    let config = BotConfig::from_toml(
+        "[email.smtp]\nhost = \"smtp.example.com\"\nfrom = \"eule@example.com\"\n\n[[email.reports]]\nguild_id = 1\nto = [\"owner@example.com\"]\nperiod = \"weekly\"\n",
+    )
+    .unwrap();
+    let smtp = config.email.smtp.unwrap();
+    assert_eq!((smtp.port, smtp.starttls), (587, true));
+    assert_eq!(config.email.reports[0].period, ReportPeriod::Weekly);
+
+    assert!(BotConfig::from_toml("[[email.reports]]\nguild_id = 1\nto = [\"a@b.c\"]\n").is_err());
+}
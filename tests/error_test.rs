@@ -1,7 +1,7 @@
 use eule::error::{create_report, ConnectionError, EuleError};
 use miette::Report;
 use poise::serenity_prelude;
-use std::io;
+use std::{io, time::Duration};
 
 #[test]
 fn test_all_error_variants() {
@@ -32,6 +32,16 @@ fn test_all_error_variants() {
         EuleError::Connection(ConnectionError::UnexpectedShutdown),
         EuleError::Connection(ConnectionError::TaskJoinError("Task join error".into())),
         EuleError::Connection(ConnectionError::HandlerError("Handler error".into())),
+        EuleError::RateLimited(Duration::from_secs(1)),
+        EuleError::InvalidConfig("Unknown field".into()),
+        EuleError::UnsupportedChannel("Category".into()),
+        EuleError::OAuth("invalid_grant".into()),
+        EuleError::Bus("connection refused".into()),
+        EuleError::MissingPermissions("Missing Access".into()),
+        EuleError::Alert("HTTP 404".into()),
+        EuleError::Email("connection refused".into()),
+        EuleError::LeadershipLost("lease expired".into()),
+        EuleError::OnHold("123".into()),
     ];
 
     for error in errors {
@@ -74,6 +84,15 @@ fn test_error_display() {
         EuleError::Connection(ConnectionError::CommandSendError("Send error".into())),
         EuleError::Connection(ConnectionError::CommandReceiveError("Receive error".into())),
         EuleError::Connection(ConnectionError::UnexpectedShutdown),
+        EuleError::RateLimited(Duration::from_secs(1)),
+        EuleError::InvalidConfig("Unknown field".into()),
+        EuleError::UnsupportedChannel("Category".into()),
+        EuleError::OAuth("invalid_grant".into()),
+        EuleError::Bus("connection refused".into()),
+        EuleError::MissingPermissions("Missing Access".into()),
+        EuleError::Alert("HTTP 404".into()),
+        EuleError::Email("connection refused".into()),
+        EuleError::LeadershipLost("lease expired".into()),
     ];
 
     for error in errors {
@@ -123,6 +142,19 @@ fn test_error_display() {
             EuleError::Connection(ConnectionError::HandlerError(_)) => {
                 assert!(error_string.contains("Handler error"))
             }
+            EuleError::RateLimited(_) => assert!(error_string.contains("Rate limited")),
+            EuleError::InvalidConfig(_) => assert!(error_string.contains("Invalid configuration")),
+            EuleError::UnsupportedChannel(_) => {
+                assert!(error_string.contains("Unsupported channel"))
+            }
+            EuleError::OAuth(_) => assert!(error_string.contains("OAuth error")),
+            EuleError::Bus(_) => assert!(error_string.contains("Message bus error")),
+            EuleError::MissingPermissions(_) => {
+                assert!(error_string.contains("Missing permissions"))
+            }
+            EuleError::Alert(_) => assert!(error_string.contains("Alert error")),
+            EuleError::Email(_) => assert!(error_string.contains("Email error")),
+            EuleError::LeadershipLost(_) => assert!(error_string.contains("Leadership lost")),
         }
     }
 }
@@ -0,0 +1,65 @@
+mod test_utils;
+
+use eule::{
+    i18n::Language,
+    store::KvStore,
+    tasks::{
+        expiry::{expiry_text, remove_expired},
+        AutocleanManager,
+    },
+    utils::{MockClock, SerializableInstant},
+};
+use poise::serenity_prelude::{ChannelId, GuildId};
+use std::sync::Arc;
+use test_utils::{mock_discord::MockDiscord, unique_test_path, TestCleanup};
+use tokio::time::Duration;
+
+const HOUR: Duration = Duration::from_secs(3600);
+
+#[test]
+fn test_expiry_text_names_channel() {
+    assert_eq!(
+        expiry_text(Language::En, ChannelId::new(2)),
+        "The autoclean task for <#2> has expired and was removed. ⌛"
+    );
+}
+
+#[tokio::test]
+async fn test_task_removes_itself_once_expired() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let start = SerializableInstant::now();
+    let clock = Arc::new(MockClock::new(start));
+    let manager =
+        AutocleanManager::with_clock(Arc::new(KvStore::new(path).unwrap()), clock.clone());
+    let api = MockDiscord::new();
+    let guild_id = GuildId::new(1);
+    let event = ChannelId::new(2);
+    let forever = ChannelId::new(3);
+    let log = ChannelId::new(9);
+    manager.add_task(guild_id, event, HOUR).await.unwrap();
+    manager.add_task(guild_id, forever, HOUR).await.unwrap();
+    assert!(manager
+        .set_expires(guild_id, event, Some(start + HOUR * 5))
+        .await
+        .unwrap());
+
+    clock.advance(HOUR * 4);
+    remove_expired(&manager, &api).await;
+    assert!(manager.task(guild_id, event).await.is_some());
+    assert!(api.sent().is_empty());
+
+    manager
+        .update_guild_settings(guild_id, |settings| settings.log_channel = Some(log))
+        .await
+        .unwrap();
+    clock.advance(HOUR);
+    remove_expired(&manager, &api).await;
+    remove_expired(&manager, &api).await;
+    assert!(manager.task(guild_id, event).await.is_none());
+    assert!(manager.task(guild_id, forever).await.is_some());
+    let sent = api.sent();
+    assert_eq!(sent.len(), 1);
+    assert_eq!(sent[0].0, log);
+    assert!(sent[0].1.contains("<#2>"));
+}
@@ -0,0 +1,97 @@
+mod test_utils;
+
+use eule::{
+    i18n::Language,
+    store::KvStore,
+    tasks::{
+        failures::{pause_failing, paused_text},
+        AutocleanManager, FailureKind, TaskState, MAX_CONSECUTIVE_FAILURES,
+    },
+    utils::{MockClock, SerializableInstant},
+};
+use poise::serenity_prelude::{ChannelId, GuildId};
+use std::sync::Arc;
+use test_utils::{mock_discord::MockDiscord, unique_test_path, TestCleanup};
+use tokio::time::Duration;
+
+const HOUR: Duration = Duration::from_secs(3600);
+
+#[test]
+fn test_paused_text_says_why() {
+    let text = paused_text(
+        Language::En,
+        ChannelId::new(2),
+        FailureKind::MissingPermissions,
+    );
+
+    assert!(text.contains("<#2>"));
+    assert!(text.contains("missing permissions"));
+    assert!(text.contains("`/autoclean resume`"));
+}
+
+#[tokio::test]
+async fn test_task_is_paused_after_repeated_failures_until_resumed() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let clock = Arc::new(MockClock::new(SerializableInstant::now()));
+    let manager =
+        AutocleanManager::with_clock(Arc::new(KvStore::new(path).unwrap()), clock.clone());
+    let api = MockDiscord::new();
+    let (guild_id, channel_id) = (GuildId::new(1), ChannelId::new(2));
+    api.add_messages(channel_id, 5, Duration::from_secs(60));
+    manager.add_task(guild_id, channel_id, HOUR).await.unwrap();
+    api.revoke_access(channel_id);
+
+    for _ in 1..MAX_CONSECUTIVE_FAILURES {
+        let _ = manager.purge_now(&api, guild_id, channel_id).await;
+    }
+    pause_failing(&manager, &api).await;
+    assert_eq!(
+        manager.task(guild_id, channel_id).await.unwrap().state,
+        TaskState::Failed
+    );
+
+    let _ = manager.purge_now(&api, guild_id, channel_id).await;
+    pause_failing(&manager, &api).await;
+    pause_failing(&manager, &api).await;
+    let task = manager.task(guild_id, channel_id).await.unwrap();
+    assert_eq!(task.state, TaskState::Paused);
+    assert_eq!(task.failure, Some(FailureKind::MissingPermissions));
+    let notices = api.owner_messages();
+    assert_eq!(notices.len(), 1);
+    assert!(notices[0].1.contains("missing permissions"));
+
+    clock.advance(HOUR * 2);
+    assert!(manager.due_tasks().await.is_empty());
+
+    api.restore_access(channel_id);
+    assert!(manager.resume_task(guild_id, channel_id).await.unwrap());
+    assert!(!manager.resume_task(guild_id, channel_id).await.unwrap());
+    let task = manager.task(guild_id, channel_id).await.unwrap();
+    assert_eq!(task.failures, 0);
+    assert_eq!(manager.due_tasks().await, vec![(guild_id, channel_id)]);
+}
+
+#[tokio::test]
+async fn test_successful_cleanup_resets_failures() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let manager = AutocleanManager::new(Arc::new(KvStore::new(path).unwrap()));
+    let api = MockDiscord::new();
+    let (guild_id, channel_id) = (GuildId::new(1), ChannelId::new(2));
+    api.add_messages(channel_id, 5, Duration::from_secs(60));
+    manager.add_task(guild_id, channel_id, HOUR).await.unwrap();
+
+    api.revoke_access(channel_id);
+    let _ = manager.purge_now(&api, guild_id, channel_id).await;
+    assert_eq!(
+        manager.task(guild_id, channel_id).await.unwrap().failures,
+        1
+    );
+
+    api.restore_access(channel_id);
+    manager.purge_now(&api, guild_id, channel_id).await.unwrap();
+    let task = manager.task(guild_id, channel_id).await.unwrap();
+    assert_eq!(task.failures, 0);
+    assert_eq!(task.failure, None);
+}
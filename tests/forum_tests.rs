@@ -0,0 +1,77 @@
+#[allow(dead_code)]
+mod test_utils;
+
+use eule::purge::{prune_forum, ForumAction, ForumOptions};
+use poise::serenity_prelude::{ChannelId, ForumTagId};
+use test_utils::mock_discord::MockDiscord;
+use tokio::time::Duration;
+
+const DAY: Duration = Duration::from_secs(24 * 60 * 60);
+
+#[tokio::test(start_paused = true)]
+async fn test_prune_forum_archives_old_posts() {
+    let api = MockDiscord::new();
+    let forum = ChannelId::new(2);
+    let old = api.add_forum_post(forum, DAY * 40, Vec::new(), false);
+    let recent = api.add_forum_post(forum, DAY, Vec::new(), false);
+
+    let report = prune_forum(&api, forum, &ForumOptions::new(DAY * 30))
+        .await
+        .unwrap();
+
+    assert_eq!(report.scanned, 2);
+    assert_eq!(report.archived, 1);
+    assert!(api.is_archived(old));
+    assert!(!api.is_archived(recent));
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_prune_forum_deletes_tagged_posts_only() {
+    let api = MockDiscord::new();
+    let forum = ChannelId::new(2);
+    let solved = ForumTagId::new(7);
+    let tagged = api.add_forum_post(forum, DAY * 40, vec![solved], true);
+    let untagged = api.add_forum_post(forum, DAY * 40, vec![ForumTagId::new(8)], false);
+
+    let mut options = ForumOptions::new(DAY * 30);
+    options.action = ForumAction::Delete;
+    options.tags = vec![solved];
+    let report = prune_forum(&api, forum, &options).await.unwrap();
+
+    assert_eq!(report.deleted, 1);
+    assert!(!api.has_thread(tagged));
+    assert!(api.has_thread(untagged));
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_prune_forum_respects_recent_activity() {
+    let api = MockDiscord::new();
+    let forum = ChannelId::new(2);
+    let post = api.add_forum_post(forum, DAY * 40, Vec::new(), false);
+    api.add_messages(post, 1, Duration::from_secs(60));
+
+    let mut options = ForumOptions::new(DAY * 30);
+    options.inactive_for = Some(DAY * 7);
+    let report = prune_forum(&api, forum, &options).await.unwrap();
+
+    assert_eq!(report.archived, 0);
+    assert!(!api.is_archived(post));
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_prune_forum_skips_posts_with_kept_tags() {
+    let api = MockDiscord::new();
+    let forum = ChannelId::new(2);
+    let pinned = ForumTagId::new(9);
+    let kept = api.add_forum_post(forum, DAY * 40, vec![ForumTagId::new(7), pinned], false);
+    let cleaned = api.add_forum_post(forum, DAY * 40, vec![ForumTagId::new(7)], false);
+
+    let mut options = ForumOptions::new(DAY * 30);
+    options.action = ForumAction::Delete;
+    options.skip_tags = vec![pinned];
+    let report = prune_forum(&api, forum, &options).await.unwrap();
+
+    assert_eq!(report.deleted, 1);
+    assert!(api.has_thread(kept));
+    assert!(!api.has_thread(cleaned));
+}
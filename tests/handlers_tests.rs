@@ -0,0 +1,22 @@
+use eule::handlers::cooldown_message;
+use std::time::Duration;
+
+#[test]
+fn test_cooldown_message_rounds_up() {
+    assert_eq!(
+        cooldown_message(Duration::from_millis(4200)),
+        "Slow down! Try again in 5s. ⏳"
+    );
+    assert_eq!(
+        cooldown_message(Duration::from_secs(3)),
+        "Slow down! Try again in 3s. ⏳"
+    );
+}
+
+#[test]
+fn test_cooldown_message_never_says_zero() {
+    assert_eq!(
+        cooldown_message(Duration::ZERO),
+        "Slow down! Try again in 1s. ⏳"
+    );
+}
@@ -0,0 +1,63 @@
+use eule::{
+    i18n::{self, Language},
+    tasks::guild_settings::TemplateKind,
+};
+
+/// Returns the placeholders in a string, in order.
+fn placeholders(text: &str) -> Vec<&str> {
+    text.match_indices('{')
+        .filter_map(|(start, _)| {
+            let end = text[start..].find('}')?;
+            Some(&text[start..=start + end])
+        })
+        .collect()
+}
+
+#[test]
+fn test_every_language_translates_every_string() {
+    let mut english = i18n::keys(Language::En);
+    english.sort_unstable();
+    for language in Language::ALL {
+        let mut keys = i18n::keys(language);
+        keys.sort_unstable();
+        assert_eq!(keys, english, "{} is incomplete", language.code());
+    }
+}
+
+#[test]
+fn test_translations_keep_placeholders() {
+    for key in i18n::keys(Language::En) {
+        let mut expected = placeholders(i18n::raw(Language::En, key));
+        expected.sort_unstable();
+        for language in Language::ALL {
+            let mut found = placeholders(i18n::raw(language, key));
+            found.sort_unstable();
+            assert_eq!(found, expected, "{} in {}", key, language.code());
+        }
+    }
+}
+
+#[test]
+fn test_text_fills_placeholders_and_falls_back() {
+    let text = i18n::text(Language::De, "purge.started", &[("channel", "<#1>")]);
+    assert_eq!(text, "Leeren von <#1> gestartet! 🧹");
+
+    assert_eq!(i18n::raw(Language::De, "missing.key"), "missing.key");
+}
+
+#[test]
+fn test_language_from_discord_locale() {
+    assert_eq!(Language::from_locale("en-US"), Some(Language::En));
+    assert_eq!(Language::from_locale("de"), Some(Language::De));
+    assert_eq!(Language::from_locale("fr"), None);
+}
+
+#[test]
+fn test_default_templates_are_translated() {
+    assert!(TemplateKind::Warning
+        .default_template(Language::De)
+        .starts_with("⚠️ Dieser Kanal"));
+    assert!(TemplateKind::Warning
+        .default_template(Language::En)
+        .starts_with("⚠️ This channel"));
+}
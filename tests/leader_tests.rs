@@ -0,0 +1,159 @@
+use async_trait::async_trait;
+use eule::{
+    config::BotConfig,
+    error::EuleError,
+    leader::{LeaderElection, LeaseStore, RedisLease},
+};
+use std::sync::{Arc, Mutex};
+use tokio::{
+    io::{AsyncReadExt, AsyncWriteExt},
+    net::TcpListener,
+    time::{Duration, Instant},
+};
+
+/// A lease kept in memory, shared by the elections of one test.
+#[derive(Clone, Default)]
+struct MemoryLease {
+    lease: Arc<Mutex<Option<(String, Instant)>>>,
+    unreachable: Arc<Mutex<bool>>,
+}
+
+impl MemoryLease {
+    fn holder(&self) -> Option<String> {
+        self.lease
+            .lock()
+            .unwrap()
+            .as_ref()
+            .filter(|(_, expires)| *expires > Instant::now())
+            .map(|(holder, _)| holder.clone())
+    }
+
+    fn check(&self) -> Result<(), EuleError> {
+        if *self.unreachable.lock().unwrap() {
+            return Err(EuleError::Bus("connection refused".to_string()));
+        }
+        Ok(())
+    }
+}
+
+#[async_trait]
+impl LeaseStore for MemoryLease {
+    async fn acquire(&self, holder: &str, ttl: Duration) -> Result<bool, EuleError> {
+        self.check()?;
+        if self.holder().is_some() {
+            return Ok(false);
+        }
+        *self.lease.lock().unwrap() = Some((holder.to_string(), Instant::now() + ttl));
+        Ok(true)
+    }
+
+    async fn renew(&self, holder: &str, ttl: Duration) -> Result<bool, EuleError> {
+        self.check()?;
+        if self.holder().as_deref() != Some(holder) {
+            return Ok(false);
+        }
+        *self.lease.lock().unwrap() = Some((holder.to_string(), Instant::now() + ttl));
+        Ok(true)
+    }
+
+    async fn release(&self, holder: &str) -> Result<(), EuleError> {
+        self.check()?;
+        if self.holder().as_deref() == Some(holder) {
+            self.lease.lock().unwrap().take();
+        }
+        Ok(())
+    }
+}
+
+const TTL: Duration = Duration::from_secs(15);
+
+#[tokio::test(start_paused = true)]
+async fn test_standby_takes_over_when_leader_stops() {
+    let lease = MemoryLease::default();
+    let leader = LeaderElection::new(Box::new(lease.clone()), TTL);
+    let standby = Arc::new(LeaderElection::new(Box::new(lease.clone()), TTL));
+    assert_ne!(leader.holder(), standby.holder());
+
+    leader.acquire().await;
+    let waiting = tokio::spawn({
+        let standby = Arc::clone(&standby);
+        async move { standby.acquire().await }
+    });
+
+    // The standby keeps waiting while the leader renews its lease
+    let holding = tokio::time::timeout(Duration::from_secs(60), leader.hold()).await;
+    assert!(holding.is_err(), "the leader should still hold the lease");
+    assert!(!waiting.is_finished());
+    assert_eq!(lease.holder().as_deref(), Some(leader.holder()));
+
+    // Once the leader stops renewing, the lease expires and the standby takes over
+    let crashed = Instant::now();
+    waiting.await.unwrap();
+    assert!(crashed.elapsed() <= TTL + TTL / 3);
+    assert_eq!(lease.holder().as_deref(), Some(standby.holder()));
+
+    // The old leader notices on its next renewal
+    assert!(matches!(leader.hold().await, EuleError::LeadershipLost(_)));
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_leader_steps_down_before_lease_can_expire() {
+    let lease = MemoryLease::default();
+    let leader = LeaderElection::new(Box::new(lease.clone()), TTL);
+    leader.acquire().await;
+    let acquired = Instant::now();
+
+    *lease.unreachable.lock().unwrap() = true;
+    let lost = leader.hold().await;
+    assert!(matches!(lost, EuleError::LeadershipLost(_)));
+    assert!(acquired.elapsed() < TTL);
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_release_hands_over_immediately() {
+    let lease = MemoryLease::default();
+    let leader = LeaderElection::new(Box::new(lease.clone()), TTL);
+    leader.acquire().await;
+    leader.release().await;
+    assert_eq!(lease.holder(), None);
+}
+
+#[tokio::test]
+async fn test_redis_lease_sets_key_only_if_absent() {
+    let listener = TcpListener::bind("127.0.0.1:0").await.unwrap();
+    let port = listener.local_addr().unwrap().port();
+    let server = tokio::spawn(async move {
+        let (mut stream, _) = listener.accept().await.unwrap();
+        let mut buffer = [0u8; 1024];
+        let read = stream.read(&mut buffer).await.unwrap();
+        // Someone else holds the lease
+        stream.write_all(b"$-1\r\n").await.unwrap();
+        String::from_utf8(buffer[..read].to_vec()).unwrap()
+    });
+
+    let lease = RedisLease::new(&format!("redis://127.0.0.1:{}", port), "eule:leader").unwrap();
+    assert!(!lease.acquire("replica-a", TTL).await.unwrap());
+    let command = server.await.unwrap();
+    assert_eq!(
+        command,
+        "*6\r\n$3\r\nSET\r\n$11\r\neule:leader\r\n$9\r\nreplica-a\r\n$2\r\nNX\r\n$2\r\nPX\r\n$5\r\n15000\r\n"
+    );
+
+    assert!(RedisLease::new("nats://127.0.0.1:4222", "eule:leader").is_err());
+}
+
+#[test]
+fn test_leader_config() {
+    let config = BotConfig::from_toml("[leader]\nurl = \"redis://127.0.0.1\"\n").unwrap();
+    assert_eq!(config.leader.key, "eule:leader");
+    assert_eq!(config.leader.ttl_secs, 15);
+    assert!(LeaderElection::from_config(&config.leader)
+        .unwrap()
+        .is_some());
+    assert!(LeaderElection::from_config(&BotConfig::default().leader)
+        .unwrap()
+        .is_none());
+
+    let result = BotConfig::from_toml("[leader]\nurl = \"redis://127.0.0.1\"\nttl_secs = 1\n");
+    assert!(matches!(result, Err(EuleError::InvalidConfig(_))));
+}
@@ -0,0 +1,106 @@
+mod test_utils;
+
+use eule::{
+    admin::{handle, AdminState},
+    commands::maintenance::blocked_in_maintenance,
+    lifecycle,
+    store::KvStore,
+    tasks::AutocleanManager,
+};
+use hyper::{Method, StatusCode};
+use poise::serenity_prelude::{ChannelId, GuildId};
+use std::sync::Arc;
+use test_utils::{mock_discord::MockDiscord, unique_test_path, TestCleanup};
+use tokio::{sync::Mutex, time::Duration};
+
+// Maintenance mode holds for the whole process, so tests that turn it on take turns
+static MAINTENANCE: Mutex<()> = Mutex::const_new(());
+
+#[test]
+fn test_maintenance_blocks_only_commands_that_change_tasks() {
+    assert!(blocked_in_maintenance("autoclean add"));
+    assert!(blocked_in_maintenance("purge"));
+    assert!(blocked_in_maintenance("clean"));
+    assert!(blocked_in_maintenance("policy set"));
+    assert!(blocked_in_maintenance("setup"));
+    assert!(!blocked_in_maintenance("autoclean list"));
+    assert!(!blocked_in_maintenance("purge estimate"));
+    assert!(!blocked_in_maintenance("status"));
+    assert!(!blocked_in_maintenance("maintenance"));
+}
+
+#[tokio::test]
+async fn test_maintenance_mode_is_kept_in_the_store() {
+    let _turn = MAINTENANCE.lock().await;
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let store = KvStore::new(path).unwrap();
+
+    lifecycle::save_maintenance(&store, true).await.unwrap();
+    assert!(lifecycle::in_maintenance());
+    lifecycle::set_maintenance(false);
+    lifecycle::load_maintenance(&store).await.unwrap();
+    assert!(lifecycle::in_maintenance());
+
+    lifecycle::save_maintenance(&store, false).await.unwrap();
+    assert!(!lifecycle::in_maintenance());
+    lifecycle::load_maintenance(&store).await.unwrap();
+    assert!(!lifecycle::in_maintenance());
+}
+
+#[tokio::test]
+async fn test_admin_api_changes_nothing_in_maintenance() {
+    let _turn = MAINTENANCE.lock().await;
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let manager = AutocleanManager::new(Arc::new(KvStore::new(path).unwrap()));
+    let (guild_id, channel_id) = (GuildId::new(1), ChannelId::new(2));
+    manager
+        .add_task(guild_id, channel_id, Duration::from_secs(3600))
+        .await
+        .unwrap();
+    let state = AdminState {
+        manager: manager.clone(),
+        api: Arc::new(MockDiscord::new()),
+        token: Some("secret".to_string()),
+        dashboard: None,
+        calendar_key: None,
+        rotation: None,
+    };
+    let request = |method: Method, path: &'static str, body: &'static str| {
+        let state = state.clone();
+        async move {
+            handle(
+                &state,
+                &method,
+                path,
+                Some("Bearer secret"),
+                body.as_bytes(),
+            )
+            .await
+            .status
+        }
+    };
+
+    lifecycle::set_maintenance(true);
+    let created = request(
+        Method::POST,
+        "/api/tasks",
+        r#"{"guild_id":"1","channel_id":"3","interval_secs":3600}"#,
+    )
+    .await;
+    let updated = request(Method::PATCH, "/api/tasks/1/2", r#"{"interval_secs":60}"#).await;
+    let deleted = request(Method::DELETE, "/api/tasks/1/2", "").await;
+    let purged = request(Method::POST, "/api/tasks/1/2/purge", "").await;
+    let listed = request(Method::GET, "/api/tasks", "").await;
+    lifecycle::set_maintenance(false);
+
+    assert_eq!(created, StatusCode::SERVICE_UNAVAILABLE);
+    assert_eq!(updated, StatusCode::SERVICE_UNAVAILABLE);
+    assert_eq!(deleted, StatusCode::SERVICE_UNAVAILABLE);
+    assert_eq!(purged, StatusCode::SERVICE_UNAVAILABLE);
+    assert_eq!(listed, StatusCode::OK);
+    assert_eq!(manager.task_count(guild_id).await, 1);
+    let task = manager.task(guild_id, channel_id).await.unwrap();
+    assert_eq!(task.interval, Duration::from_secs(3600));
+}
@@ -0,0 +1,102 @@
+mod test_utils;
+
+use eule::{
+    store::KvStore,
+    tasks::AutocleanManager,
+    utils::{Clock, MockClock, SerializableInstant},
+};
+use poise::serenity_prelude::{ChannelId, GuildId, UserId};
+use std::sync::Arc;
+use test_utils::{mock_discord::MockDiscord, unique_test_path, TestCleanup};
+use tokio::time::Duration;
+
+const HOUR: Duration = Duration::from_secs(3600);
+
+async fn count(manager: &AutocleanManager, guild_id: GuildId, channel_id: ChannelId, n: usize) {
+    for _ in 0..n {
+        assert!(!manager.count_message(guild_id, channel_id).await.unwrap());
+    }
+}
+
+#[tokio::test]
+async fn test_enough_messages_schedule_a_cleanup() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let clock = Arc::new(MockClock::new(SerializableInstant::now()));
+    let manager =
+        AutocleanManager::with_clock(Arc::new(KvStore::new(path).unwrap()), clock.clone());
+    let (guild_id, channel_id) = (GuildId::new(1), ChannelId::new(2));
+    manager.add_task(guild_id, channel_id, HOUR).await.unwrap();
+    assert!(manager
+        .set_message_trigger(guild_id, channel_id, Some(3))
+        .await
+        .unwrap());
+
+    count(&manager, guild_id, channel_id, 2).await;
+    assert!(manager.scheduled_purges(guild_id).await.is_empty());
+    assert!(manager.count_message(guild_id, channel_id).await.unwrap());
+    assert_eq!(
+        manager.scheduled_purges(guild_id).await,
+        vec![(channel_id, clock.now())]
+    );
+
+    // The count starts over once the trigger is reached
+    count(&manager, guild_id, channel_id, 2).await;
+}
+
+#[tokio::test]
+async fn test_channels_without_a_trigger_are_not_counted() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let manager = AutocleanManager::new(Arc::new(KvStore::new(path).unwrap()));
+    let (guild_id, channel_id) = (GuildId::new(1), ChannelId::new(2));
+    manager.add_task(guild_id, channel_id, HOUR).await.unwrap();
+
+    count(&manager, guild_id, channel_id, 5).await;
+    count(&manager, guild_id, ChannelId::new(3), 5).await;
+    assert!(manager.scheduled_purges(guild_id).await.is_empty());
+}
+
+#[tokio::test]
+async fn test_cleanup_starts_the_count_over() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let clock = Arc::new(MockClock::new(SerializableInstant::now()));
+    let manager =
+        AutocleanManager::with_clock(Arc::new(KvStore::new(path).unwrap()), clock.clone());
+    let api = MockDiscord::new();
+    let (guild_id, channel_id) = (GuildId::new(1), ChannelId::new(2));
+    manager.add_task(guild_id, channel_id, HOUR).await.unwrap();
+    manager
+        .set_message_trigger(guild_id, channel_id, Some(3))
+        .await
+        .unwrap();
+
+    api.add_messages(channel_id, 2, Duration::from_secs(60));
+    count(&manager, guild_id, channel_id, 2).await;
+    clock.advance(Duration::from_secs(60));
+    manager.purge_now(&api, guild_id, channel_id).await.unwrap();
+
+    count(&manager, guild_id, channel_id, 2).await;
+    assert!(manager.count_message(guild_id, channel_id).await.unwrap());
+}
+
+#[tokio::test]
+async fn test_held_channels_let_the_trigger_go() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let manager = AutocleanManager::new(Arc::new(KvStore::new(path).unwrap()));
+    let (guild_id, channel_id) = (GuildId::new(1), ChannelId::new(2));
+    manager.add_task(guild_id, channel_id, HOUR).await.unwrap();
+    manager
+        .set_message_trigger(guild_id, channel_id, Some(2))
+        .await
+        .unwrap();
+    manager
+        .place_hold(guild_id, channel_id, UserId::new(9), None)
+        .await
+        .unwrap();
+
+    count(&manager, guild_id, channel_id, 4).await;
+    assert!(manager.scheduled_purges(guild_id).await.is_empty());
+}
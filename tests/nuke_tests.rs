@@ -0,0 +1,35 @@
+#[allow(dead_code)]
+mod test_utils;
+
+use eule::{purge::DiscordApi, tasks::nuke_channel};
+use poise::serenity_prelude::ChannelId;
+use test_utils::mock_discord::MockDiscord;
+use tokio::time::Duration;
+
+#[tokio::test(start_paused = true)]
+async fn test_nuke_channel_replaces_channel_with_empty_copy() {
+    let api = MockDiscord::new();
+    let channel = ChannelId::new(2);
+    api.add_messages(channel, 500, Duration::from_secs(60));
+    api.set_topic(channel, "House rules").await.unwrap();
+
+    let copy = nuke_channel(&api, channel).await.unwrap();
+
+    assert_ne!(copy, channel);
+    assert!(api.is_deleted(channel));
+    assert!(!api.is_deleted(copy));
+    assert_eq!(api.remaining(copy), 0);
+    assert_eq!(api.topic_of(copy).as_deref(), Some("House rules"));
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_nuke_channel_keeps_original_without_access() {
+    let api = MockDiscord::new();
+    let channel = ChannelId::new(2);
+    api.add_messages(channel, 5, Duration::from_secs(60));
+    api.revoke_access(channel);
+
+    assert!(nuke_channel(&api, channel).await.is_err());
+    assert!(!api.is_deleted(channel));
+    assert_eq!(api.remaining(channel), 5);
+}
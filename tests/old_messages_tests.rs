@@ -0,0 +1,40 @@
+mod test_utils;
+
+use eule::{store::KvStore, tasks::old_messages::check_new_task, tasks::AutocleanManager};
+use poise::serenity_prelude::{ChannelId, GuildId};
+use std::sync::Arc;
+use test_utils::{mock_discord::MockDiscord, unique_test_path, TestCleanup};
+use tokio::time::Duration;
+
+const DAY: Duration = Duration::from_secs(24 * 60 * 60);
+
+#[tokio::test]
+async fn test_old_history_is_reported_to_the_owner_or_log_channel() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let manager = AutocleanManager::new(Arc::new(KvStore::new(path).unwrap()));
+    let api = MockDiscord::new();
+    let guild_id = GuildId::new(1);
+    let archive = ChannelId::new(2);
+    let chat = ChannelId::new(3);
+    let log = ChannelId::new(9);
+    api.add_messages(archive, 1200, DAY * 20);
+    api.add_messages(chat, 50, DAY * 20);
+
+    assert!(check_new_task(&manager, &api, guild_id, archive).await);
+    assert!(!check_new_task(&manager, &api, guild_id, chat).await);
+    let owner_messages = api.owner_messages();
+    assert_eq!(owner_messages.len(), 1);
+    assert_eq!(owner_messages[0].0, guild_id);
+    assert!(owner_messages[0].1.contains("<#2>"));
+
+    manager
+        .update_guild_settings(guild_id, |settings| settings.log_channel = Some(log))
+        .await
+        .unwrap();
+    assert!(check_new_task(&manager, &api, guild_id, archive).await);
+    assert_eq!(api.owner_messages().len(), 1);
+    assert!(api.sent().iter().any(|(channel, _, _)| *channel == log));
+    // Estimating never deletes anything
+    assert_eq!(api.remaining(archive), 1200);
+}
@@ -0,0 +1,62 @@
+mod test_utils;
+
+use eule::{
+    i18n::Language,
+    onboarding::{invite_permissions, invite_url, setup_summary},
+    store::KvStore,
+    tasks::AutocleanManager,
+};
+use poise::serenity_prelude::{ApplicationId, GuildId, Permissions};
+use std::sync::Arc;
+use test_utils::{unique_test_path, TestCleanup};
+
+#[test]
+fn test_setup_summary_names_guild_and_commands() {
+    let summary = setup_summary(Language::En, "Owl Parliament");
+
+    assert!(summary.starts_with("Thanks for adding me to **Owl Parliament**!"));
+    assert!(summary.contains("Manage Messages"));
+    assert!(summary.contains("`/autoclean add`"));
+
+    let german = setup_summary(Language::De, "Owl Parliament");
+    assert!(german.starts_with("Danke, dass du mich zu **Owl Parliament**"));
+}
+
+#[test]
+fn test_invite_url_asks_for_every_permission_tasks_use() {
+    let permissions = invite_permissions();
+    assert!(permissions.contains(
+        Permissions::MANAGE_MESSAGES
+            | Permissions::READ_MESSAGE_HISTORY
+            | Permissions::MANAGE_THREADS
+            | Permissions::MANAGE_CHANNELS
+            | Permissions::MANAGE_ROLES
+            | Permissions::MANAGE_GUILD
+    ));
+    assert!(!permissions.administrator());
+
+    assert_eq!(
+        invite_url(
+            ApplicationId::new(42),
+            Permissions::VIEW_CHANNEL | Permissions::MANAGE_MESSAGES
+        ),
+        "https://discord.com/oauth2/authorize?client_id=42&scope=bot%20applications.commands&permissions=9216"
+    );
+}
+
+#[tokio::test]
+async fn test_guilds_are_onboarded_once() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let kv_store = Arc::new(KvStore::new(path).unwrap());
+    let manager = AutocleanManager::new(Arc::clone(&kv_store));
+    let guild_id = GuildId::new(1);
+
+    assert!(manager.onboard_guild(guild_id).await.unwrap());
+    assert!(manager.guild_settings(guild_id).await.joined_at.is_some());
+    assert!(!manager.onboard_guild(guild_id).await.unwrap());
+
+    let reloaded = AutocleanManager::new(kv_store);
+    reloaded.load_tasks().await.unwrap();
+    assert!(!reloaded.onboard_guild(guild_id).await.unwrap());
+}
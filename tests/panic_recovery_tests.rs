@@ -0,0 +1,62 @@
+#[allow(dead_code)]
+mod test_utils;
+
+use eule::{
+    tasks::{CleanupTask, PurgeEventKind, PurgeEvents, TaskState, WorkerPool},
+    utils::clock::SystemClock,
+};
+use poise::serenity_prelude::{ChannelId, GuildId};
+use std::{collections::HashMap, sync::Arc};
+use test_utils::mock_discord::MockDiscord;
+use tokio::{sync::RwLock, time::Duration};
+
+#[tokio::test]
+async fn test_panicking_cleanup_fails_its_task_only() {
+    let api = Arc::new(MockDiscord::new());
+    let guild_id = GuildId::new(1);
+    let (broken, healthy) = (ChannelId::new(2), ChannelId::new(3));
+    api.panic_in(broken);
+    api.add_messages(healthy, 5, Duration::from_secs(60));
+    let mut guild_tasks = HashMap::new();
+    for channel_id in [broken, healthy] {
+        guild_tasks.insert(
+            channel_id,
+            CleanupTask::new(Duration::from_secs(3600)).await,
+        );
+    }
+    let tasks = Arc::new(RwLock::new(HashMap::from([(guild_id, guild_tasks)])));
+    let events = PurgeEvents::new();
+    let mut received = events.subscribe();
+    // A single worker has to survive the panic to clean the second channel
+    let pool = WorkerPool::with_events(
+        1,
+        api.clone(),
+        Arc::clone(&tasks),
+        events,
+        Default::default(),
+        Arc::new(SystemClock),
+    );
+
+    pool.queue_task(guild_id, broken).await;
+    pool.queue_task(guild_id, healthy).await;
+
+    let mut finished = HashMap::new();
+    while finished.len() < 2 {
+        let event = received.recv().await.unwrap();
+        if event.kind != PurgeEventKind::Started {
+            finished.insert(event.channel_id, event);
+        }
+    }
+    let failed = &finished[&broken];
+    assert_eq!(failed.kind, PurgeEventKind::Failed);
+    assert!(failed.error.as_deref().unwrap().contains("simulated panic"));
+    assert_eq!(finished[&healthy].kind, PurgeEventKind::Completed);
+    assert_eq!(api.remaining(healthy), 0);
+
+    let tasks = tasks.read().await;
+    let task = &tasks[&guild_id][&broken];
+    assert_eq!(task.state, TaskState::Failed);
+    assert!(task.running.is_none());
+    assert_eq!(task.failures, 1);
+    assert!(task.history.back().unwrap().error.is_some());
+}
@@ -0,0 +1,77 @@
+mod test_utils;
+
+use eule::{
+    i18n::Language,
+    store::KvStore,
+    tasks::{
+        permissions::{permissions_lost_text, report_lost},
+        AutocleanManager, CleanupTask,
+    },
+};
+use poise::serenity_prelude::{ChannelId, GuildId, Permissions};
+use std::sync::Arc;
+use test_utils::{mock_discord::MockDiscord, unique_test_path, TestCleanup};
+use tokio::time::Duration;
+
+#[test]
+fn test_permissions_lost_text_names_missing_permissions() {
+    let text = permissions_lost_text(
+        Language::En,
+        ChannelId::new(2),
+        Permissions::MANAGE_MESSAGES | Permissions::READ_MESSAGE_HISTORY,
+    );
+    assert!(text.contains("<#2>"));
+    assert!(text.contains("Manage Messages"));
+    assert!(text.contains("Read Message History"));
+
+    let unknown = permissions_lost_text(Language::En, ChannelId::new(2), Permissions::empty());
+    assert!(unknown.contains("denied me access"));
+}
+
+#[tokio::test]
+async fn test_required_permissions_follow_task_settings() {
+    let mut task = CleanupTask::new(Duration::from_secs(3600)).await;
+    assert!(!task
+        .required_permissions()
+        .contains(Permissions::MANAGE_THREADS));
+
+    task.include_threads = true;
+    task.lock_channel = true;
+    let required = task.required_permissions();
+    assert!(required.contains(Permissions::MANAGE_MESSAGES | Permissions::MANAGE_THREADS));
+    assert!(required.contains(Permissions::MANAGE_ROLES));
+}
+
+#[tokio::test]
+async fn test_permission_loss_is_reported_once_until_cleanups_succeed() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let manager = AutocleanManager::new(Arc::new(KvStore::new(path).unwrap()));
+    let api = MockDiscord::new();
+    let (guild_id, channel_id) = (GuildId::new(1), ChannelId::new(2));
+    api.add_messages(channel_id, 5, Duration::from_secs(60));
+    manager
+        .add_task(guild_id, channel_id, Duration::from_secs(3600))
+        .await
+        .unwrap();
+
+    report_lost(&manager, &api).await;
+    assert!(api.owner_messages().is_empty());
+
+    api.revoke_access(channel_id);
+    let _ = manager.purge_now(&api, guild_id, channel_id).await;
+    report_lost(&manager, &api).await;
+    let _ = manager.purge_now(&api, guild_id, channel_id).await;
+    report_lost(&manager, &api).await;
+    let notices = api.owner_messages();
+    assert_eq!(notices.len(), 1);
+    assert!(notices[0].1.contains("View Channel"));
+    assert!(notices[0].1.contains("Manage Messages"));
+
+    api.restore_access(channel_id);
+    manager.purge_now(&api, guild_id, channel_id).await.unwrap();
+    api.revoke_access(channel_id);
+    let _ = manager.purge_now(&api, guild_id, channel_id).await;
+    report_lost(&manager, &api).await;
+    assert_eq!(api.owner_messages().len(), 2);
+}
@@ -0,0 +1,179 @@
+mod test_utils;
+
+use eule::{
+    config::BotConfig,
+    i18n::Language,
+    phishing::{self, cleanup_text, parse_feed, set_blocked_domains},
+    purge::{link_hosts, purge_channel, BlockedDomains, MessageFilter, PurgeOptions},
+    store::KvStore,
+    tasks::{AutocleanManager, PurgeEventKind},
+    EuleError,
+};
+use poise::serenity_prelude::{ChannelId, GatewayIntents, GuildId, UserId};
+use std::sync::Arc;
+use test_utils::{mock_discord::MockDiscord, unique_test_path, TestCleanup};
+use tokio::time::Duration;
+
+const HOUR: Duration = Duration::from_secs(3600);
+
+#[test]
+fn test_link_hosts() {
+    assert_eq!(
+        link_hosts("[Free nitro](https://discord.com@Evil.example:443/gift) now"),
+        vec!["evil.example"]
+    );
+    assert_eq!(
+        link_hosts("http://a.example. and https://b.example?x and httpsnot://c.example"),
+        vec!["a.example", "b.example"]
+    );
+    assert!(link_hosts("no links, just http talk").is_empty());
+}
+
+#[test]
+fn test_blocked_domains_cover_subdomains() {
+    let blocked = BlockedDomains::new(["https://Evil.example/path", "*.bad.example", "com"]);
+
+    assert_eq!(blocked.len(), 2);
+    assert!(blocked.contains("evil.example"));
+    assert!(blocked.contains("login.evil.example"));
+    assert!(blocked.contains("bad.example"));
+    assert!(!blocked.contains("notevil.example"));
+    assert!(!blocked.contains("discord.com"));
+    assert_eq!(
+        blocked.blocked_domain("see https://discord.com and https://steam.bad.example/trade"),
+        Some("steam.bad.example".to_string())
+    );
+}
+
+#[test]
+fn test_parse_feed() {
+    let json = parse_feed(r#"["evil.example", "bad.example"]"#);
+    assert!(json.contains("evil.example") && json.contains("bad.example"));
+
+    let hosts = parse_feed("# blocked\n0.0.0.0 evil.example\n\nbad.example # since May\n");
+    assert_eq!(hosts.len(), 2);
+    assert!(hosts.contains("evil.example") && hosts.contains("bad.example"));
+
+    assert!(parse_feed("<html>not a feed</html>").is_empty());
+}
+
+#[test]
+fn test_phishing_config() {
+    let config = BotConfig::from_toml(
+        "[phishing]\nfeed_url = \"https://feed.example/domains.json\"\nlive = true\n",
+    )
+    .unwrap();
+    assert_eq!(config.phishing.refresh(), phishing::DEFAULT_REFRESH);
+    assert!(config.intents().contains(GatewayIntents::MESSAGE_CONTENT));
+    assert!(!BotConfig::default()
+        .intents()
+        .contains(GatewayIntents::MESSAGE_CONTENT));
+
+    for invalid in [
+        "[phishing]\nfeed_url = \"feed.example\"\n",
+        "[phishing]\nfeed_url = \"https://feed.example\"\nrefresh_secs = 5\n",
+        "[phishing]\nlive = true\n",
+    ] {
+        assert!(matches!(
+            BotConfig::from_toml(invalid),
+            Err(EuleError::InvalidConfig(_))
+        ));
+    }
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_purges_delete_blocked_links_the_filter_keeps() {
+    let api = MockDiscord::new();
+    let channel_id = ChannelId::new(2);
+    api.post_text(channel_id, Duration::from_secs(60), "https://evil.example");
+    api.post_text(
+        channel_id,
+        Duration::from_secs(60),
+        "#keep http://x.evil.example",
+    );
+    let kept = api.post_text(channel_id, Duration::from_secs(60), "#keep");
+    let young = api.post_text(channel_id, Duration::from_secs(60), "https://discord.com");
+
+    let options = PurgeOptions {
+        filter: MessageFilter::new()
+            .older_than(HOUR)
+            .keep_keywords(["#keep"])
+            .delete_blocked_links(BlockedDomains::new(["evil.example"])),
+        ..Default::default()
+    };
+    let report = purge_channel(&api, channel_id, &options).await.unwrap();
+
+    assert_eq!(report.deleted, 2);
+    assert_eq!(report.blocked_links, 2);
+    assert!(api.has_message(channel_id, kept));
+    assert!(api.has_message(channel_id, young));
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_cleanups_report_phishing_links_and_live_deletes() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let manager = AutocleanManager::new(Arc::new(KvStore::new(path).unwrap()));
+    let api = MockDiscord::new();
+    let (guild_id, channel_id, log) = (GuildId::new(1), ChannelId::new(2), ChannelId::new(3));
+    set_blocked_domains(parse_feed(r#"["evil.example"]"#));
+    manager.add_task(guild_id, channel_id, HOUR).await.unwrap();
+    manager
+        .set_keep_keywords(guild_id, channel_id, vec!["#keep".to_string()])
+        .await
+        .unwrap();
+    manager
+        .update_guild_settings(guild_id, |settings| settings.log_channel = Some(log))
+        .await
+        .unwrap();
+
+    let kept = api.post_text(channel_id, Duration::from_secs(60), "#keep");
+    api.post_text(
+        channel_id,
+        Duration::from_secs(60),
+        "#keep https://evil.example",
+    );
+    let mut events = manager.subscribe_events();
+    manager.purge_now(&api, guild_id, channel_id).await.unwrap();
+    let event = loop {
+        let event = events.recv().await.unwrap();
+        if event.kind == PurgeEventKind::Completed {
+            break event;
+        }
+    };
+    assert_eq!(event.blocked_links, 1);
+    assert!(api.has_message(channel_id, kept));
+
+    phishing::report_cleanup(&manager, &api, &event).await;
+    assert_eq!(
+        api.sent(),
+        vec![(log, cleanup_text(Language::En, channel_id, 1), None)]
+    );
+
+    // Messages posted from now on are checked one at a time
+    let posted = api.post_text(channel_id, Duration::ZERO, "https://login.evil.example");
+    let host = manager
+        .delete_phishing(
+            &api,
+            guild_id,
+            channel_id,
+            posted,
+            "https://login.evil.example",
+        )
+        .await
+        .unwrap();
+    assert_eq!(host.as_deref(), Some("login.evil.example"));
+    assert!(!api.has_message(channel_id, posted));
+
+    manager
+        .place_hold(guild_id, channel_id, UserId::new(9), None)
+        .await
+        .unwrap();
+    let posted = api.post_text(channel_id, Duration::ZERO, "https://evil.example");
+    let host = manager
+        .delete_phishing(&api, guild_id, channel_id, posted, "https://evil.example")
+        .await
+        .unwrap();
+    assert_eq!(host, None);
+    assert!(api.has_message(channel_id, posted));
+}
@@ -0,0 +1,499 @@
+mod test_utils;
+
+use eule::{
+    commands::policy::valid_name,
+    store::KvStore,
+    tasks::{
+        policy::{
+            format_interval, matches_pattern, parse_bulk, retention_csv, retention_report,
+            BulkError, PatternOutcome, Policy, Retention,
+        },
+        AutocleanManager, CleanupTask,
+    },
+};
+use poise::serenity_prelude::{ChannelId, ChannelType, GuildId, UserId};
+use std::{
+    collections::{BTreeSet, HashMap, HashSet},
+    sync::Arc,
+};
+use test_utils::{mock_discord::MockDiscord, unique_test_path, TestCleanup};
+use tokio::time::Duration;
+
+const HOUR: Duration = Duration::from_secs(3600);
+
+fn server() -> HashMap<ChannelId, ChannelType> {
+    HashMap::from([
+        (ChannelId::new(10), ChannelType::Text),
+        (ChannelId::new(11), ChannelType::Voice),
+        (ChannelId::new(12), ChannelType::Category),
+    ])
+}
+
+#[test]
+fn test_parse_bulk_reads_policies() {
+    let json = r##"[
+        {"channel": "10", "interval": "6h", "keep_first_message": true, "keep_keywords": ["#keep"]},
+        {"channel": 11, "interval": "1w", "include_threads": true}
+    ]"##;
+
+    let policies = parse_bulk(json, &server()).unwrap();
+
+    let mut spam = Policy::new(HOUR * 6);
+    spam.keep_first_message = true;
+    spam.keep_keywords = vec!["#keep".to_string()];
+    // Voice channels have no threads to include
+    let weekly = Policy::new(HOUR * 24 * 7);
+    assert_eq!(
+        policies,
+        vec![(ChannelId::new(10), spam), (ChannelId::new(11), weekly)]
+    );
+}
+
+#[test]
+fn test_parse_bulk_reports_every_problem() {
+    let json = r#"[
+        {"channel": "10", "interval": "6 hours"},
+        {"channel": "99", "interval": "6h"},
+        {"channel": "12", "interval": "6h"},
+        {"channel": "11", "interval": "6h", "keep_pins": true},
+        {"channel": "11", "interval": "6h"},
+        {"channel": "11", "interval": "6h"},
+        {"interval": "6h"}
+    ]"#;
+
+    let failures = parse_bulk(json, &server()).unwrap_err();
+
+    let entries: Vec<_> = failures.iter().map(|failure| failure.entry).collect();
+    assert_eq!(
+        entries,
+        vec![Some(1), Some(2), Some(3), Some(4), Some(6), Some(7)]
+    );
+    assert!(matches!(&failures[0].error, BulkError::Invalid(e) if e.contains("6 hours")));
+    assert_eq!(
+        failures[1].error,
+        BulkError::UnknownChannel(ChannelId::new(99))
+    );
+    assert_eq!(
+        failures[2].error,
+        BulkError::Unsupported(ChannelId::new(12))
+    );
+    assert!(matches!(&failures[3].error, BulkError::Invalid(e) if e.contains("keep_pins")));
+    assert_eq!(failures[4].error, BulkError::Duplicate(ChannelId::new(11)));
+}
+
+#[test]
+fn test_parse_bulk_rejects_other_documents() {
+    let failures = parse_bulk(r#"{"channel": "10"}"#, &server()).unwrap_err();
+    assert_eq!(failures.len(), 1);
+    assert_eq!(failures[0].entry, None);
+
+    assert!(parse_bulk("not json", &server()).is_err());
+}
+
+#[test]
+fn test_format_interval_uses_largest_unit() {
+    assert_eq!(format_interval(HOUR * 6), "6h");
+    assert_eq!(format_interval(HOUR * 48), "2d");
+    assert_eq!(format_interval(HOUR * 24 * 14), "2w");
+    assert_eq!(format_interval(Duration::from_secs(90 * 60)), "90m");
+}
+
+#[tokio::test]
+async fn test_apply_policies_creates_and_updates_tasks() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let kv_store = Arc::new(KvStore::new(path).unwrap());
+    let manager = AutocleanManager::new(Arc::clone(&kv_store));
+    let guild_id = GuildId::new(1);
+    let existing = ChannelId::new(10);
+    let new = ChannelId::new(11);
+    manager.add_task(guild_id, existing, HOUR).await.unwrap();
+    let last_cleanup = manager.task(guild_id, existing).await.unwrap().last_cleanup;
+
+    let mut policy = Policy::new(HOUR * 6);
+    policy.lock_channel = true;
+    let (created, updated) = manager
+        .apply_policies(guild_id, &[(existing, policy.clone()), (new, policy)])
+        .await
+        .unwrap();
+
+    assert_eq!((created, updated), (1, 1));
+    let task = manager.task(guild_id, existing).await.unwrap();
+    assert_eq!(task.interval, HOUR * 6);
+    assert!(task.lock_channel);
+    assert_eq!(task.last_cleanup, last_cleanup);
+    assert!(manager.task(guild_id, new).await.unwrap().lock_channel);
+
+    let reloaded = AutocleanManager::new(kv_store);
+    reloaded.load_tasks().await.unwrap();
+    assert_eq!(reloaded.task_count(guild_id).await, 2);
+}
+
+#[test]
+fn test_policy_names() {
+    assert!(valid_name("spam-channel"));
+    assert!(valid_name("archive_2"));
+    assert!(!valid_name("Archive"));
+    assert!(!valid_name("spam channel"));
+    assert!(!valid_name(""));
+    assert!(!valid_name(&"a".repeat(33)));
+}
+
+#[test]
+fn test_policy_reads_keep_newer_than() {
+    let policy: Policy = serde_json::from_str(
+        r#"{"interval": "7d", "keep_pinned": true, "keep_newer_than": "30d"}"#,
+    )
+    .unwrap();
+
+    assert!(policy.keep_pinned);
+    assert_eq!(policy.keep_newer_than, Some(HOUR * 24 * 30));
+    let written = serde_json::to_string(&policy).unwrap();
+    assert_eq!(serde_json::from_str::<Policy>(&written).unwrap(), policy);
+}
+
+#[tokio::test]
+async fn test_named_policy_changes_follow_to_channels() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let kv_store = Arc::new(KvStore::new(path).unwrap());
+    let manager = AutocleanManager::new(Arc::clone(&kv_store));
+    let guild_id = GuildId::new(1);
+    let spam = ChannelId::new(10);
+    let other = ChannelId::new(11);
+    manager.add_task(guild_id, other, HOUR).await.unwrap();
+
+    assert!(!manager
+        .apply_named_policy(guild_id, spam, "spam-channel")
+        .await
+        .unwrap());
+    let mut policy = Policy::new(HOUR * 6);
+    policy.keep_pinned = true;
+    assert_eq!(
+        manager
+            .set_policy(guild_id, "spam-channel", policy.clone())
+            .await
+            .unwrap(),
+        0
+    );
+    assert!(manager
+        .apply_named_policy(guild_id, spam, "spam-channel")
+        .await
+        .unwrap());
+    let task = manager.task(guild_id, spam).await.unwrap();
+    assert_eq!(task.interval, HOUR * 6);
+    assert!(task.keep_pinned);
+    assert_eq!(task.policy.as_deref(), Some("spam-channel"));
+
+    policy.interval = HOUR * 12;
+    assert_eq!(
+        manager
+            .set_policy(guild_id, "spam-channel", policy)
+            .await
+            .unwrap(),
+        1
+    );
+    assert_eq!(
+        manager.task(guild_id, spam).await.unwrap().interval,
+        HOUR * 12
+    );
+    assert_eq!(manager.task(guild_id, other).await.unwrap().interval, HOUR);
+
+    let reloaded = AutocleanManager::new(Arc::clone(&kv_store));
+    reloaded.load_tasks().await.unwrap();
+    assert!(reloaded
+        .guild_settings(guild_id)
+        .await
+        .policies
+        .contains_key("spam-channel"));
+
+    assert!(manager
+        .remove_policy(guild_id, "spam-channel")
+        .await
+        .unwrap());
+    let task = manager.task(guild_id, spam).await.unwrap();
+    assert_eq!(task.policy, None);
+    assert_eq!(task.interval, HOUR * 12);
+}
+
+#[tokio::test]
+async fn test_nuke_policy_refuses_settings_that_keep_messages() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let manager = AutocleanManager::new(Arc::new(KvStore::new(path).unwrap()));
+    let guild_id = GuildId::new(1);
+    let mut policy = Policy::new(HOUR);
+    policy.nuke = true;
+    policy.keep_pinned = true;
+
+    assert!(policy.check().is_err());
+    assert!(manager
+        .set_policy(guild_id, "wipe", policy.clone())
+        .await
+        .is_err());
+    assert!(!manager
+        .guild_settings(guild_id)
+        .await
+        .policies
+        .contains_key("wipe"));
+    assert!(manager
+        .apply_policies(guild_id, &[(ChannelId::new(10), policy)])
+        .await
+        .is_err());
+    assert!(manager.task(guild_id, ChannelId::new(10)).await.is_none());
+
+    let json = r#"[{"channel": "10", "interval": "6h", "nuke": true, "keep_first_message": true}]"#;
+    let failures = parse_bulk(json, &server()).unwrap_err();
+    assert!(matches!(failures[0].error, BulkError::Invalid(_)));
+}
+
+#[tokio::test]
+async fn test_named_policy_applies_to_labelled_tasks() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let manager = AutocleanManager::new(Arc::new(KvStore::new(path).unwrap()));
+    let guild_id = GuildId::new(1);
+    let (text, voice, other) = (ChannelId::new(10), ChannelId::new(11), ChannelId::new(12));
+    for channel_id in [text, voice, other] {
+        manager.add_task(guild_id, channel_id, HOUR).await.unwrap();
+    }
+    let labels = BTreeSet::from(["event".to_string()]);
+    for channel_id in [text, voice] {
+        assert!(manager
+            .set_labels(guild_id, channel_id, labels.clone())
+            .await
+            .unwrap());
+    }
+    let mut policy = Policy::new(HOUR * 6);
+    policy.include_threads = true;
+    let threaded = HashSet::from([text]);
+
+    assert_eq!(
+        manager
+            .apply_named_policy_to_label(guild_id, "event", "event", &threaded)
+            .await
+            .unwrap(),
+        None
+    );
+    manager.set_policy(guild_id, "event", policy).await.unwrap();
+    let applied = manager
+        .apply_named_policy_to_label(guild_id, " EVENT", "event", &threaded)
+        .await
+        .unwrap();
+
+    assert_eq!(applied, Some(vec![text, voice]));
+    let task = manager.task(guild_id, text).await.unwrap();
+    assert_eq!(task.interval, HOUR * 6);
+    assert!(task.include_threads);
+    assert!(!manager.task(guild_id, voice).await.unwrap().include_threads);
+    assert_eq!(manager.task(guild_id, other).await.unwrap().interval, HOUR);
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_policy_keeps_pinned_and_recent_messages() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let kv_store = Arc::new(KvStore::new(path).unwrap());
+    let manager = AutocleanManager::new(kv_store);
+    let api = MockDiscord::new();
+    let guild_id = GuildId::new(1);
+    let channel_id = ChannelId::new(2);
+    let pinned = api.post(channel_id, UserId::new(1), HOUR * 24 * 60, true);
+    api.add_messages(channel_id, 4, HOUR * 24 * 60);
+    let recent = api.post(channel_id, UserId::new(1), HOUR, false);
+
+    let mut policy = Policy::new(HOUR * 24 * 7);
+    policy.keep_pinned = true;
+    policy.keep_newer_than = Some(HOUR * 24 * 30);
+    manager
+        .set_policy(guild_id, "archive", policy)
+        .await
+        .unwrap();
+    manager
+        .apply_named_policy(guild_id, channel_id, "archive")
+        .await
+        .unwrap();
+    manager.purge_now(&api, guild_id, channel_id).await.unwrap();
+
+    assert!(api.has_message(channel_id, pinned));
+    assert!(api.has_message(channel_id, recent));
+    assert_eq!(api.remaining(channel_id), 2);
+}
+
+#[test]
+fn test_channel_name_patterns() {
+    assert!(matches_pattern("temp-*", "temp-"));
+    assert!(matches_pattern("temp-*", "Temp-Raid"));
+    assert!(matches_pattern("*-spam", "bot-spam"));
+    assert!(matches_pattern("event-*-chat", "event-2024-chat"));
+    assert!(matches_pattern("general", "general"));
+    assert!(!matches_pattern("general", "general-2"));
+    assert!(!matches_pattern("a*a", "a"));
+    assert!(!matches_pattern("*-spam", "spam-talk"));
+}
+
+#[tokio::test]
+async fn test_name_patterns_schedule_and_unschedule_channels() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let kv_store = Arc::new(KvStore::new(path).unwrap());
+    let manager = AutocleanManager::new(kv_store);
+    let guild_id = GuildId::new(1);
+    let temp = ChannelId::new(10);
+    let manual = ChannelId::new(11);
+    let mut policy = Policy::new(HOUR);
+    policy.include_threads = true;
+    manager.set_policy(guild_id, "temp", policy).await.unwrap();
+    manager
+        .update_guild_settings(guild_id, |settings| {
+            settings
+                .patterns
+                .insert("temp-*".to_string(), "temp".to_string());
+        })
+        .await
+        .unwrap();
+    manager.add_task(guild_id, manual, HOUR * 2).await.unwrap();
+
+    assert_eq!(
+        manager
+            .match_channel_name(guild_id, temp, "temp-raid", ChannelType::Voice)
+            .await
+            .unwrap(),
+        PatternOutcome::Followed("temp".to_string())
+    );
+    let task = manager.task(guild_id, temp).await.unwrap();
+    assert!(task.auto);
+    assert!(!task.include_threads);
+    assert_eq!(
+        manager
+            .match_channel_name(guild_id, temp, "temp-raid", ChannelType::Voice)
+            .await
+            .unwrap(),
+        PatternOutcome::Unchanged
+    );
+
+    assert_eq!(
+        manager
+            .match_channel_name(guild_id, manual, "temp-manual", ChannelType::Text)
+            .await
+            .unwrap(),
+        PatternOutcome::Unchanged
+    );
+    assert_eq!(
+        manager.task(guild_id, manual).await.unwrap().interval,
+        HOUR * 2
+    );
+
+    assert_eq!(
+        manager
+            .match_channel_name(guild_id, temp, "raid-archive", ChannelType::Voice)
+            .await
+            .unwrap(),
+        PatternOutcome::Removed
+    );
+    assert!(manager.task(guild_id, temp).await.is_none());
+    assert_eq!(
+        manager
+            .match_channel_name(guild_id, manual, "general", ChannelType::Text)
+            .await
+            .unwrap(),
+        PatternOutcome::Unchanged
+    );
+    assert!(manager.task(guild_id, manual).await.is_some());
+}
+
+#[tokio::test]
+async fn test_new_channels_follow_category_default() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let kv_store = Arc::new(KvStore::new(path).unwrap());
+    let manager = AutocleanManager::new(kv_store);
+    let guild_id = GuildId::new(1);
+    let events = ChannelId::new(5);
+    let channel = ChannelId::new(10);
+    let elsewhere = ChannelId::new(11);
+    manager
+        .set_policy(guild_id, "event", Policy::new(HOUR))
+        .await
+        .unwrap();
+    manager
+        .update_guild_settings(guild_id, |settings| {
+            settings
+                .category_policies
+                .insert(events, "event".to_string());
+        })
+        .await
+        .unwrap();
+
+    assert_eq!(
+        manager
+            .apply_category_default(guild_id, elsewhere, None, ChannelType::Text)
+            .await
+            .unwrap(),
+        None
+    );
+    assert_eq!(
+        manager
+            .apply_category_default(guild_id, channel, Some(events), ChannelType::Forum)
+            .await
+            .unwrap(),
+        None
+    );
+    assert_eq!(
+        manager
+            .apply_category_default(guild_id, channel, Some(events), ChannelType::Text)
+            .await
+            .unwrap(),
+        Some("event".to_string())
+    );
+    let task = manager.task(guild_id, channel).await.unwrap();
+    assert_eq!(task.policy.as_deref(), Some("event"));
+    assert!(!task.auto);
+    assert_eq!(
+        manager
+            .apply_category_default(guild_id, channel, Some(events), ChannelType::Text)
+            .await
+            .unwrap(),
+        None
+    );
+}
+
+#[tokio::test]
+async fn test_retention_report_flags_channels_without_retention() {
+    let mut followed = CleanupTask::new(HOUR * 6).await;
+    followed.policy = Some("spam-channel".to_string());
+    let mut custom = CleanupTask::new(HOUR).await;
+    custom.keep_newer_than = Some(HOUR * 24);
+    let thread = ChannelId::new(20);
+    let tasks = vec![
+        (ChannelId::new(10), followed),
+        (ChannelId::new(11), custom.clone()),
+        (thread, custom),
+    ];
+    let channels = vec![
+        (ChannelId::new(10), "spam".to_string(), ChannelType::Text),
+        (ChannelId::new(11), "chat".to_string(), ChannelType::Voice),
+        (
+            ChannelId::new(12),
+            "info".to_string(),
+            ChannelType::Category,
+        ),
+        (ChannelId::new(13), "rules".to_string(), ChannelType::Text),
+    ];
+
+    let rows = retention_report(channels, &tasks);
+
+    assert_eq!(rows.len(), 4);
+    assert_eq!(rows[0].channel_id, ChannelId::new(13));
+    assert!(rows[0].flagged());
+    assert_eq!(rows[1].channel_id, thread);
+    assert_eq!(rows[2].retention, Retention::Custom);
+    assert_eq!(
+        rows[3].retention,
+        Retention::Policy("spam-channel".to_string())
+    );
+    assert_eq!(rows.iter().filter(|row| row.flagged()).count(), 1);
+    let csv = retention_csv(&rows);
+    assert!(csv.contains("\n11,\"chat\",custom,1h,1d,no\n"));
+    assert!(csv.contains("\n13,\"rules\",none,,,yes\n"));
+}
@@ -0,0 +1,29 @@
+use eule::{config::BotConfig, proxy, EuleError};
+
+#[test]
+fn test_proxy_config() {
+    let config = BotConfig::from_toml("[proxy]\nurl = \"socks5://127.0.0.1:1080\"\n").unwrap();
+    assert_eq!(config.proxy.url.as_deref(), Some("socks5://127.0.0.1:1080"));
+    assert!(BotConfig::default().proxy.url.is_none());
+
+    assert!(matches!(
+        BotConfig::from_toml("[proxy]\nurl = \"not a url\"\n"),
+        Err(EuleError::InvalidConfig(_))
+    ));
+}
+
+#[tokio::test]
+async fn test_clients_are_created_with_and_without_a_proxy() {
+    proxy::set_proxy(Some("http://127.0.0.1:3128")).unwrap();
+    let _client = proxy::client();
+    let _http = proxy::discord_http("not a token", proxy::DEFAULT_REQUEST_TIMEOUT);
+
+    assert!(proxy::is_set());
+
+    assert!(proxy::set_proxy(Some("::")).is_err());
+    proxy::set_proxy(None).unwrap();
+    assert!(!proxy::is_set());
+    let _client = proxy::client();
+    // Nothing to check when the gateway and HTTP requests go the same way
+    proxy::check_gateway().await.unwrap();
+}
@@ -0,0 +1,151 @@
+use eule::{
+    commands::purge::{
+        format_bulk_failures, format_completion, format_leaderboard, format_progress,
+        parse_message_ref, StatsPeriod,
+    },
+    i18n::Language,
+    purge::PurgeReport,
+    tasks::{
+        guild_settings::{MessageTemplates, TemplateKind},
+        policy::{BulkError, BulkFailure},
+    },
+};
+use poise::serenity_prelude::{ChannelId, MessageId};
+use std::time::Duration;
+
+#[test]
+fn test_progress_reports_rate_and_estimate() {
+    let report = PurgeReport {
+        deleted: 300,
+        pending: 600,
+        ..Default::default()
+    };
+
+    let line = format_progress(
+        &MessageTemplates::default(),
+        Language::En,
+        ChannelId::new(7),
+        &report,
+        Duration::from_secs(600),
+    );
+
+    assert!(line.contains("<#7>"));
+    assert!(line.contains("300 deleted so far"));
+    assert!(line.contains("at least 600 remaining (~20 minutes at this rate)"));
+    assert!(line.contains("30 messages/min"));
+}
+
+#[test]
+fn test_progress_before_first_deletion() {
+    let report = PurgeReport {
+        pending: 100,
+        ..Default::default()
+    };
+
+    let line = format_progress(
+        &MessageTemplates::default(),
+        Language::En,
+        ChannelId::new(7),
+        &report,
+        Duration::ZERO,
+    );
+
+    assert!(line.contains("0 deleted so far"));
+    assert!(line.contains("at least 100 remaining,"));
+}
+
+#[test]
+fn test_progress_separates_thousands() {
+    let report = PurgeReport {
+        deleted: 12_000,
+        pending: 240_000,
+        ..Default::default()
+    };
+
+    let line = format_progress(
+        &MessageTemplates::default(),
+        Language::En,
+        ChannelId::new(7),
+        &report,
+        Duration::from_secs(600),
+    );
+
+    assert!(line.contains("12,000 deleted so far"));
+    assert!(line.contains("at least 240,000 remaining (~3 hours, 20 minutes at this rate)"));
+    assert!(line.contains("1,200 messages/min"));
+}
+
+#[test]
+fn test_completion_uses_guild_template() {
+    let report = PurgeReport {
+        deleted: 1_500,
+        ..Default::default()
+    };
+    let mut templates = MessageTemplates::default();
+
+    let line = format_completion(&templates, Language::En, ChannelId::new(7), &report, None);
+    assert_eq!(line, "✅ Purged <#7>: deleted 1,500 messages.");
+
+    templates.set(
+        TemplateKind::Completion,
+        Some("{count} messages left {channel}, next purge {next_purge}".to_string()),
+    );
+    let line = format_completion(&templates, Language::En, ChannelId::new(7), &report, None);
+    assert_eq!(line, "1,500 messages left <#7>, next purge not scheduled");
+}
+
+#[test]
+fn test_stats_period_includes_today() {
+    assert_eq!(StatsPeriod::Day.first_day(100), 100);
+    assert_eq!(StatsPeriod::Week.first_day(100), 94);
+    assert_eq!(StatsPeriod::Quarter.first_day(10), 0);
+}
+
+#[test]
+fn test_leaderboard_ranks_channels() {
+    let board = format_leaderboard(
+        Language::En,
+        &[(ChannelId::new(1), 12_500), (ChannelId::new(2), 40)],
+    );
+
+    assert_eq!(board, "🥇 <#1>: 12,500 messages\n🥈 <#2>: 40 messages");
+}
+
+#[test]
+fn test_bulk_failures_name_entries() {
+    let failures = vec![
+        BulkFailure {
+            entry: Some(2),
+            error: BulkError::UnknownChannel(ChannelId::new(99)),
+        },
+        BulkFailure {
+            entry: None,
+            error: BulkError::Invalid("expected a JSON array of entries".to_string()),
+        },
+    ];
+
+    let text = format_bulk_failures(Language::En, &failures);
+
+    assert!(text.starts_with("Nothing was applied, because 2 entries have problems"));
+    assert!(text.contains("• Entry 2: there is no channel 99 on this server"));
+    assert!(text.contains("• The file: expected a JSON array of entries"));
+}
+
+#[test]
+fn test_message_refs_from_links_and_ids() {
+    assert_eq!(
+        parse_message_ref("https://discord.com/channels/10/20/30"),
+        Some((Some(ChannelId::new(20)), MessageId::new(30)))
+    );
+    assert_eq!(
+        parse_message_ref(" https://ptb.discord.com/channels/10/20/30/ "),
+        Some((Some(ChannelId::new(20)), MessageId::new(30)))
+    );
+    assert_eq!(parse_message_ref("30"), Some((None, MessageId::new(30))));
+    assert_eq!(
+        parse_message_ref("https://discord.com/channels/10/20"),
+        None
+    );
+    assert_eq!(parse_message_ref("0"), None);
+    assert_eq!(parse_message_ref("not a message"), None);
+}
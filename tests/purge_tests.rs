@@ -0,0 +1,698 @@
+#[allow(dead_code)]
+mod test_utils;
+
+use eule::purge::{
+    estimate_purge, purge_channel, rate_limit_stats, CancelToken, DeletionBudget, DeletionOrder,
+    DiscordApi, MessageFilter, MessageRange, PurgeOptions, PurgeReport, SendPermission, SharedHttp,
+    BULK_DELETE_MAX_AGE,
+};
+use eule::utils::SerializableInstant;
+use poise::serenity_prelude::{ChannelId, Http, UserId};
+use std::{sync::Arc, time::SystemTime};
+use test_utils::mock_discord::MockDiscord;
+use tokio::time::{Duration, Instant};
+
+const DAY: Duration = Duration::from_secs(24 * 60 * 60);
+
+#[tokio::test(start_paused = true)]
+async fn test_purge_report_counts_requests() {
+    let api = MockDiscord::new();
+    let channel_id = ChannelId::new(2);
+    api.add_messages(channel_id, 2, DAY * 20);
+    api.add_messages(channel_id, 120, Duration::from_secs(60));
+
+    let report = purge_channel(&api, channel_id, &PurgeOptions::default())
+        .await
+        .unwrap();
+
+    assert_eq!(
+        report,
+        PurgeReport {
+            scanned: 122,
+            deleted: 122,
+            bulk_requests: 2,
+            single_requests: 2,
+            threads: 0,
+            pending: 0,
+            deferred: 0,
+            blocked_links: 0,
+            cancelled: false,
+            capped: false,
+        }
+    );
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_purge_filter_keeps_pinned_and_other_authors() {
+    let api = MockDiscord::new();
+    let channel_id = ChannelId::new(2);
+    let author = UserId::new(10);
+    let other = UserId::new(11);
+    api.post(channel_id, author, Duration::from_secs(60), true);
+    api.post(channel_id, other, Duration::from_secs(60), false);
+    for _ in 0..3 {
+        api.post(channel_id, author, Duration::from_secs(60), false);
+    }
+
+    let options = PurgeOptions {
+        filter: MessageFilter::new().keep_pinned().from_authors([author]),
+        ..Default::default()
+    };
+    let report = purge_channel(&api, channel_id, &options).await.unwrap();
+
+    assert_eq!(report.scanned, 5);
+    assert_eq!(report.deleted, 3);
+    assert_eq!(api.remaining(channel_id), 2);
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_purge_filter_skip_authors() {
+    let api = MockDiscord::new();
+    let channel_id = ChannelId::new(2);
+    let kept = UserId::new(9);
+    api.post(channel_id, kept, Duration::from_secs(60), false);
+    api.post(channel_id, kept, Duration::from_secs(60), false);
+    api.add_messages(channel_id, 5, Duration::from_secs(60));
+
+    let options = PurgeOptions {
+        filter: MessageFilter::new().skip_authors([kept]),
+        ..Default::default()
+    };
+    let report = purge_channel(&api, channel_id, &options).await.unwrap();
+
+    assert_eq!(report.deleted, 5);
+    assert_eq!(api.remaining(channel_id), 2);
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_purge_filter_older_than() {
+    let api = MockDiscord::new();
+    let channel_id = ChannelId::new(2);
+    api.add_messages(channel_id, 4, DAY * 2);
+    api.add_messages(channel_id, 6, Duration::from_secs(60));
+
+    let options = PurgeOptions {
+        filter: MessageFilter::new().older_than(DAY),
+        ..Default::default()
+    };
+    let report = purge_channel(&api, channel_id, &options).await.unwrap();
+
+    assert_eq!(report.deleted, 4);
+    assert_eq!(api.remaining(channel_id), 6);
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_purge_gives_up_after_retries() {
+    let api = MockDiscord::with_rate_limit_every(1);
+    let channel_id = ChannelId::new(2);
+    api.add_messages(channel_id, 3, Duration::from_secs(60));
+
+    let options = PurgeOptions {
+        max_rate_limit_retries: 2,
+        ..Default::default()
+    };
+    let before = rate_limit_stats();
+    let result = purge_channel(&api, channel_id, &options).await;
+
+    assert!(matches!(result, Err(eule::EuleError::RateLimited(_))));
+    assert_eq!(api.remaining(channel_id), 3);
+    // Other tests may be counted too, so only check that these were
+    let after = rate_limit_stats();
+    assert!(after.throttled.count >= before.throttled.count + 3);
+    assert!(after.buckets["messages"].count >= 3);
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_purge_skips_threads_by_default() {
+    let api = MockDiscord::new();
+    let channel_id = ChannelId::new(2);
+    let thread = api.add_thread(channel_id, false);
+    api.add_messages(channel_id, 5, Duration::from_secs(60));
+    api.add_messages(thread, 5, Duration::from_secs(60));
+
+    let report = purge_channel(&api, channel_id, &PurgeOptions::default())
+        .await
+        .unwrap();
+
+    assert_eq!(report.threads, 0);
+    assert_eq!(api.remaining(thread), 5);
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_purge_includes_active_and_archived_threads() {
+    let api = MockDiscord::new();
+    let channel_id = ChannelId::new(2);
+    let active = api.add_thread(channel_id, false);
+    let archived = api.add_thread(channel_id, true);
+    api.add_messages(channel_id, 3, Duration::from_secs(60));
+    api.add_messages(active, 4, Duration::from_secs(60));
+    api.add_messages(archived, 6, DAY * 30);
+
+    let options = PurgeOptions {
+        include_threads: true,
+        ..Default::default()
+    };
+    let report = purge_channel(&api, channel_id, &options).await.unwrap();
+
+    assert_eq!(report.threads, 2);
+    assert_eq!(report.deleted, 13);
+    assert_eq!(api.remaining(active), 0);
+    assert_eq!(api.remaining(archived), 0);
+    assert!(api.is_archived(archived));
+    assert!(!api.is_archived(active));
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_purge_stops_when_cancelled() {
+    let api = MockDiscord::new();
+    let channel_id = ChannelId::new(2);
+    api.add_messages(channel_id, 50, DAY * 20);
+    let cancel = CancelToken::new();
+    let options = PurgeOptions {
+        cancel: cancel.clone(),
+        ..Default::default()
+    };
+
+    let (report, _) = tokio::join!(purge_channel(&api, channel_id, &options), async {
+        tokio::time::sleep(Duration::from_secs(5)).await;
+        cancel.cancel();
+    });
+    let report = report.unwrap();
+
+    assert!(report.cancelled);
+    assert!(report.deleted > 0 && report.deleted < 50);
+    assert_eq!(api.remaining(channel_id), 50 - report.deleted);
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_purge_publishes_progress() {
+    let api = MockDiscord::new();
+    let channel_id = ChannelId::new(2);
+    api.add_messages(channel_id, 30, DAY * 20);
+    let (progress, mut progress_rx) = tokio::sync::watch::channel(PurgeReport::default());
+    let options = PurgeOptions {
+        progress: Some(progress),
+        ..Default::default()
+    };
+
+    // The progress channel closes once the purge drops its options
+    let purge = async move { purge_channel(&api, channel_id, &options).await };
+    let (report, updates) = tokio::join!(purge, async {
+        let mut updates = Vec::new();
+        while progress_rx.changed().await.is_ok() {
+            updates.push(*progress_rx.borrow_and_update());
+        }
+        updates
+    });
+
+    let report = report.unwrap();
+    assert_eq!(report.pending, 0);
+    assert!(updates.len() > 1);
+    assert!(updates
+        .iter()
+        .all(|update| update.deleted + update.pending == 30));
+    assert!(updates
+        .windows(2)
+        .all(|pair| pair[0].deleted <= pair[1].deleted));
+    assert_eq!(updates.last().unwrap().deleted, 30);
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_purge_filter_keeps_listed_messages() {
+    let api = MockDiscord::new();
+    let channel_id = ChannelId::new(2);
+    api.add_messages(channel_id, 5, Duration::from_secs(60));
+    let progress = api.post(channel_id, UserId::new(9), Duration::ZERO, false);
+    let options = PurgeOptions {
+        filter: MessageFilter::new().keep_messages([progress]),
+        ..Default::default()
+    };
+
+    let report = purge_channel(&api, channel_id, &options).await.unwrap();
+
+    assert_eq!(report.deleted, 5);
+    assert!(!report.cancelled);
+    assert_eq!(api.remaining(channel_id), 1);
+}
+
+#[test]
+fn test_channel_support_by_type() {
+    use eule::purge::ChannelSupport;
+    use poise::serenity_prelude::ChannelType;
+
+    assert_eq!(
+        ChannelSupport::of(ChannelType::Text),
+        ChannelSupport::Messages { threads: true }
+    );
+    for kind in [
+        ChannelType::Voice,
+        ChannelType::Stage,
+        ChannelType::PublicThread,
+    ] {
+        assert_eq!(
+            ChannelSupport::of(kind),
+            ChannelSupport::Messages { threads: false }
+        );
+        assert!(!ChannelSupport::of(kind).has_threads());
+    }
+    assert_eq!(
+        ChannelSupport::of(ChannelType::Forum),
+        ChannelSupport::Forum
+    );
+    assert_eq!(
+        ChannelSupport::of(ChannelType::Category),
+        ChannelSupport::Unsupported
+    );
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_purge_keeps_first_message() {
+    // Page-sized histories are the tricky case: the oldest message is only known
+    // once an empty page comes back
+    for count in [50, 100, 250] {
+        let api = MockDiscord::new();
+        let channel_id = ChannelId::new(2);
+        let first = api.post(channel_id, UserId::new(1), DAY * 30, false);
+        api.add_messages(channel_id, count - 1, Duration::from_secs(60));
+        let thread_id = api.add_thread(channel_id, false);
+        api.add_messages(thread_id, 3, Duration::from_secs(60));
+
+        let options = PurgeOptions {
+            keep_first_message: true,
+            include_threads: true,
+            ..Default::default()
+        };
+        let report = purge_channel(&api, channel_id, &options).await.unwrap();
+
+        assert_eq!(report.deleted, count - 1 + 3, "{} messages", count);
+        assert_eq!(report.pending, 0);
+        assert!(api.has_message(channel_id, first));
+        assert_eq!(api.remaining(channel_id), 1);
+        assert_eq!(api.remaining(thread_id), 0);
+    }
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_purge_raises_and_restores_slowmode() {
+    let api = MockDiscord::new();
+    let channel_id = ChannelId::new(2);
+    api.add_messages(channel_id, 10, Duration::from_secs(60));
+    api.set_slowmode(channel_id, 5).await.unwrap();
+
+    let options = PurgeOptions {
+        slowmode: Some(30),
+        ..Default::default()
+    };
+    purge_channel(&api, channel_id, &options).await.unwrap();
+
+    assert_eq!(api.remaining(channel_id), 0);
+    assert_eq!(
+        api.slowmode_edits(),
+        vec![(channel_id, 5), (channel_id, 30), (channel_id, 5)]
+    );
+    assert_eq!(api.slowmode_of(channel_id), 5);
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_purge_keeps_longer_slowmode() {
+    let api = MockDiscord::new();
+    let channel_id = ChannelId::new(2);
+    api.add_messages(channel_id, 10, Duration::from_secs(60));
+    api.set_slowmode(channel_id, 60).await.unwrap();
+
+    let options = PurgeOptions {
+        slowmode: Some(30),
+        ..Default::default()
+    };
+    purge_channel(&api, channel_id, &options).await.unwrap();
+
+    assert_eq!(api.slowmode_edits(), vec![(channel_id, 60)]);
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_purge_restores_slowmode_after_failure() {
+    let api = MockDiscord::new();
+    let channel_id = ChannelId::new(2);
+    let thread_id = api.add_thread(channel_id, false);
+    api.add_messages(thread_id, 3, Duration::from_secs(60));
+    api.revoke_access(thread_id);
+
+    let options = PurgeOptions {
+        include_threads: true,
+        slowmode: Some(30),
+        ..Default::default()
+    };
+    assert!(purge_channel(&api, channel_id, &options).await.is_err());
+
+    assert_eq!(api.slowmode_of(channel_id), 0);
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_purge_locks_channel_and_restores_permission() {
+    let api = MockDiscord::new();
+    let channel_id = ChannelId::new(2);
+    api.add_messages(channel_id, 10, Duration::from_secs(60));
+    api.set_everyone_send(channel_id, SendPermission::Allow)
+        .await
+        .unwrap();
+
+    let options = PurgeOptions {
+        lock_channel: true,
+        ..Default::default()
+    };
+    purge_channel(&api, channel_id, &options).await.unwrap();
+
+    assert_eq!(api.remaining(channel_id), 0);
+    assert_eq!(
+        api.send_permission_edits(),
+        vec![
+            (channel_id, SendPermission::Allow),
+            (channel_id, SendPermission::Deny),
+            (channel_id, SendPermission::Allow)
+        ]
+    );
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_purge_leaves_locked_channel_locked() {
+    let api = MockDiscord::new();
+    let channel_id = ChannelId::new(2);
+    api.add_messages(channel_id, 10, Duration::from_secs(60));
+    api.set_everyone_send(channel_id, SendPermission::Deny)
+        .await
+        .unwrap();
+
+    let options = PurgeOptions {
+        lock_channel: true,
+        ..Default::default()
+    };
+    purge_channel(&api, channel_id, &options).await.unwrap();
+
+    assert_eq!(api.send_permission_edits().len(), 1);
+    assert_eq!(api.send_permission_of(channel_id), SendPermission::Deny);
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_purge_keeps_messages_with_keywords() {
+    let api = MockDiscord::new();
+    let channel_id = ChannelId::new(2);
+    api.add_messages(channel_id, 5, Duration::from_secs(60));
+    let tagged = api.post_text(channel_id, Duration::from_secs(60), "Meeting notes #KEEP");
+    let archived = api.post_text(channel_id, DAY * 20, "!archive this one");
+    api.post_text(channel_id, Duration::from_secs(60), "keep it short");
+
+    let options = PurgeOptions {
+        filter: MessageFilter::new().keep_keywords(["#keep", "!archive"]),
+        ..Default::default()
+    };
+    let report = purge_channel(&api, channel_id, &options).await.unwrap();
+
+    assert_eq!(report.deleted, 6);
+    assert!(api.has_message(channel_id, tagged));
+    assert!(api.has_message(channel_id, archived));
+    assert_eq!(api.remaining(channel_id), 2);
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_purge_range_fetches_only_around_the_range() {
+    let api = MockDiscord::new();
+    let channel_id = ChannelId::new(2);
+    api.add_messages(channel_id, 150, DAY * 3);
+    let first = api.post(channel_id, UserId::new(1), DAY * 2, false);
+    api.add_messages(channel_id, 3, DAY);
+    let last = api.post(channel_id, UserId::new(1), Duration::from_secs(3600), false);
+    api.add_messages(channel_id, 250, Duration::from_secs(60));
+
+    let options = PurgeOptions {
+        range: Some(MessageRange::new(last, first)),
+        ..Default::default()
+    };
+    let report = purge_channel(&api, channel_id, &options).await.unwrap();
+
+    assert_eq!(report.deleted, 5);
+    assert_eq!(report.scanned, 100);
+    assert!(!api.has_message(channel_id, first));
+    assert!(!api.has_message(channel_id, last));
+    assert_eq!(api.remaining(channel_id), 400);
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_purge_range_by_date() {
+    let api = MockDiscord::new();
+    let channel_id = ChannelId::new(2);
+    api.add_messages(channel_id, 3, DAY * 5);
+    api.add_messages(channel_id, 4, DAY * 3);
+    api.add_messages(channel_id, 2, DAY);
+
+    let start = SerializableInstant::from_system_time(SystemTime::now() - DAY * 4);
+    let end = SerializableInstant::from_system_time(SystemTime::now() - DAY * 2);
+    let options = PurgeOptions {
+        range: MessageRange::posted_between(start, end),
+        ..Default::default()
+    };
+    let report = purge_channel(&api, channel_id, &options).await.unwrap();
+
+    assert_eq!(report.deleted, 4);
+    assert_eq!(api.remaining(channel_id), 5);
+    assert!(MessageRange::posted_between(end, start).is_none());
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_estimate_counts_single_deletes() {
+    let api = MockDiscord::new();
+    let channel_id = ChannelId::new(2);
+    api.add_messages(channel_id, 150, DAY * 20);
+    api.add_messages(channel_id, 50, Duration::from_secs(60));
+    let options = PurgeOptions::default();
+
+    let estimate = estimate_purge(&api, channel_id, &options, 10)
+        .await
+        .unwrap();
+
+    assert!(estimate.complete);
+    assert_eq!((estimate.recent, estimate.old), (50, 150));
+    assert_eq!(estimate.requests(), 151);
+    assert_eq!(estimate.duration(&options), Duration::from_secs(302));
+    assert!(!estimate.is_long(&options));
+    assert_eq!(api.remaining(channel_id), 200);
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_estimate_extrapolates_unsampled_history() {
+    let api = MockDiscord::new();
+    let channel_id = ChannelId::new(2);
+    api.add_messages(channel_id, 300, DAY * 20);
+    let options = PurgeOptions::default();
+
+    let estimate = estimate_purge(&api, channel_id, &options, 2).await.unwrap();
+
+    assert!(!estimate.complete);
+    assert_eq!(estimate.sampled, 200);
+    assert!(estimate.old > 200);
+    assert!(estimate.is_long(&options));
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_purge_pauses_when_budget_is_used_up() {
+    let api = MockDiscord::new();
+    let channel_id = ChannelId::new(2);
+    api.add_messages(channel_id, 5, Duration::from_secs(60));
+    let window = Duration::from_secs(3600);
+    let options = PurgeOptions {
+        budget: Some(Arc::new(DeletionBudget::new(3, window))),
+        ..Default::default()
+    };
+
+    let started = Instant::now();
+    let report = purge_channel(&api, channel_id, &options).await.unwrap();
+
+    assert_eq!(report.deleted, 5);
+    assert_eq!(report.bulk_requests, 2);
+    assert!(started.elapsed() >= window);
+    assert_eq!(api.remaining(channel_id), 0);
+}
+
+// Real time, since message ages are read from the system clock
+#[tokio::test]
+async fn test_purge_deletes_messages_that_aged_during_a_budget_pause_one_by_one() {
+    let api = MockDiscord::new();
+    let channel_id = ChannelId::new(2);
+    api.add_messages(channel_id, 4, BULK_DELETE_MAX_AGE - Duration::from_secs(1));
+    let options = PurgeOptions {
+        budget: Some(Arc::new(DeletionBudget::new(2, Duration::from_secs(2)))),
+        ..Default::default()
+    };
+
+    let report = purge_channel(&api, channel_id, &options).await.unwrap();
+
+    assert_eq!(report.deleted, 4);
+    assert_eq!(report.bulk_requests, 1);
+    assert_eq!(report.single_requests, 2);
+    assert_eq!(api.remaining(channel_id), 0);
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_purge_spaces_out_old_message_deletes() {
+    let api = MockDiscord::new();
+    let channel_id = ChannelId::new(2);
+    api.add_messages(channel_id, 3, DAY * 20);
+    let options = PurgeOptions {
+        rate: 100,
+        old_message_delay: Some(Duration::from_secs(5)),
+        ..Default::default()
+    };
+
+    let started = Instant::now();
+    let report = purge_channel(&api, channel_id, &options).await.unwrap();
+
+    assert_eq!(report.single_requests, 3);
+    assert!(started.elapsed() >= Duration::from_secs(10));
+    assert_eq!(api.remaining(channel_id), 0);
+}
+
+#[test]
+fn test_old_message_delay_falls_back_to_the_client() {
+    let api = SharedHttp::new(Arc::new(Http::new("not a token")));
+    let options = PurgeOptions::default();
+    assert_eq!(options.old_message_delay(&api), Duration::ZERO);
+
+    // Clones share the pause, like the bot's tasks and commands do
+    api.clone()
+        .set_old_message_delay(Duration::from_millis(500));
+    assert_eq!(options.old_message_delay(&api), Duration::from_millis(500));
+
+    let options = PurgeOptions {
+        old_message_delay: Some(Duration::from_secs(2)),
+        ..Default::default()
+    };
+    assert_eq!(options.old_message_delay(&api), Duration::from_secs(2));
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_purge_fetches_next_page_while_deleting() {
+    let api = MockDiscord::with_fetch_latency(Duration::from_secs(1));
+    let channel_id = ChannelId::new(2);
+    api.add_messages(channel_id, 300, DAY * 20);
+    let options = PurgeOptions {
+        rate: 10_000,
+        old_message_delay: Some(Duration::from_millis(10)),
+        ..Default::default()
+    };
+
+    let started = Instant::now();
+    let report = purge_channel(&api, channel_id, &options).await.unwrap();
+
+    assert_eq!(report.deleted, 300);
+    assert_eq!(api.remaining(channel_id), 0);
+    // Four fetches and three pages of deletes, about a second each, would
+    // take seven seconds one after the other
+    assert!(started.elapsed() < Duration::from_secs(5));
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_purge_oldest_first_leaves_newest_when_cut_short() {
+    let api = MockDiscord::new();
+    let channel_id = ChannelId::new(2);
+    let oldest = api.post(channel_id, UserId::new(1), DAY * 20, false);
+    api.add_messages(channel_id, 150, DAY * 3);
+    let newest = api.post(channel_id, UserId::new(1), Duration::from_secs(60), false);
+    let window = Duration::from_secs(3600);
+    let options = PurgeOptions {
+        order: DeletionOrder::OldestFirst,
+        budget: Some(Arc::new(DeletionBudget::new(120, window))),
+        ..Default::default()
+    };
+
+    let purge = purge_channel(&api, channel_id, &options);
+    assert!(tokio::time::timeout(window / 2, purge).await.is_err());
+
+    assert!(!api.has_message(channel_id, oldest));
+    assert!(api.has_message(channel_id, newest));
+    assert_eq!(api.remaining(channel_id), 32);
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_purge_oldest_first_keeps_first_message_and_range() {
+    let api = MockDiscord::new();
+    let channel_id = ChannelId::new(2);
+    let first = api.post(channel_id, UserId::new(1), DAY * 30, false);
+    api.add_messages(channel_id, 249, Duration::from_secs(60));
+    let options = PurgeOptions {
+        order: DeletionOrder::OldestFirst,
+        keep_first_message: true,
+        ..Default::default()
+    };
+
+    let report = purge_channel(&api, channel_id, &options).await.unwrap();
+
+    assert_eq!(report.deleted, 249);
+    assert_eq!(report.pending, 0);
+    assert!(api.has_message(channel_id, first));
+
+    api.add_messages(channel_id, 150, DAY * 3);
+    let start = api.post(channel_id, UserId::new(1), DAY * 2, false);
+    api.add_messages(channel_id, 3, DAY);
+    let end = api.post(channel_id, UserId::new(1), Duration::from_secs(3600), false);
+    api.add_messages(channel_id, 250, Duration::from_secs(60));
+    let options = PurgeOptions {
+        order: DeletionOrder::OldestFirst,
+        range: Some(MessageRange::new(start, end)),
+        ..Default::default()
+    };
+
+    let report = purge_channel(&api, channel_id, &options).await.unwrap();
+
+    assert_eq!(report.deleted, 5);
+    assert_eq!(report.scanned, 100);
+    assert!(!api.has_message(channel_id, start));
+    assert!(!api.has_message(channel_id, end));
+    assert_eq!(api.remaining(channel_id), 401);
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_purge_stops_at_max_deletions() {
+    let api = MockDiscord::new();
+    let channel_id = ChannelId::new(2);
+    api.add_messages(channel_id, 2, DAY * 20);
+    api.add_messages(channel_id, 150, Duration::from_secs(60));
+    let thread_id = api.add_thread(channel_id, false);
+    api.add_messages(thread_id, 3, Duration::from_secs(60));
+    let options = PurgeOptions {
+        max_deletions: Some(120),
+        include_threads: true,
+        ..Default::default()
+    };
+
+    let report = purge_channel(&api, channel_id, &options).await.unwrap();
+
+    assert!(report.capped);
+    assert!(!report.cancelled);
+    assert_eq!(report.deleted, 120);
+    assert_eq!(report.threads, 0);
+    assert_eq!(api.remaining(channel_id), 32);
+    assert_eq!(api.remaining(thread_id), 3);
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_cancelling_drops_the_request_in_flight() {
+    let api = MockDiscord::with_fetch_latency(Duration::from_secs(3600));
+    let channel_id = ChannelId::new(2);
+    api.add_messages(channel_id, 3, Duration::from_secs(60));
+    let cancel = CancelToken::new();
+    let options = PurgeOptions {
+        cancel: cancel.clone(),
+        ..Default::default()
+    };
+
+    let started = Instant::now();
+    let (report, ()) = tokio::join!(purge_channel(&api, channel_id, &options), async {
+        tokio::time::sleep(Duration::from_secs(1)).await;
+        cancel.cancel();
+    });
+    let report = report.unwrap();
+
+    assert!(report.cancelled);
+    assert_eq!(report.deleted, 0);
+    assert!(started.elapsed() < Duration::from_secs(60));
+    assert_eq!(api.remaining(channel_id), 3);
+}
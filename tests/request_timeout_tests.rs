@@ -0,0 +1,35 @@
+use eule::{
+    proxy,
+    purge::{is_retryable, DiscordApi},
+    EuleError,
+};
+use poise::serenity_prelude::ChannelId;
+use tokio::{
+    net::TcpListener,
+    time::{Duration, Instant},
+};
+
+// The proxy holds for the whole process, so it gets a test binary of its own
+#[tokio::test]
+async fn test_hung_requests_time_out() {
+    // A proxy that takes connections but never answers them
+    let listener = TcpListener::bind("127.0.0.1:0").await.unwrap();
+    let address = listener.local_addr().unwrap();
+    tokio::spawn(async move {
+        let mut connections = Vec::new();
+        while let Ok((connection, _)) = listener.accept().await {
+            connections.push(connection);
+        }
+    });
+    proxy::set_proxy(Some(&format!("http://{}", address))).unwrap();
+    let http = proxy::discord_http("not a token", Duration::from_millis(200));
+
+    let started = Instant::now();
+    let error = DiscordApi::messages(&http, ChannelId::new(2), None, 100)
+        .await
+        .unwrap_err();
+
+    assert!(matches!(error, EuleError::TimedOut(_)));
+    assert!(is_retryable(&error));
+    assert!(started.elapsed() < Duration::from_secs(10));
+}
@@ -0,0 +1,95 @@
+mod test_utils;
+
+use eule::{
+    error::EuleError,
+    purge::{
+        is_retryable, merge_deferred, purge_channel, retry_deletes, DeferredDeletes, PurgeOptions,
+        RetryEntry,
+    },
+    store::KvStore,
+    tasks::AutocleanManager,
+};
+use poise::serenity_prelude::{ChannelId, GuildId, MessageId};
+use std::sync::Arc;
+use test_utils::{mock_discord::MockDiscord, unique_test_path, TestCleanup};
+use tokio::time::Duration;
+
+#[test]
+fn test_only_passing_failures_are_retryable() {
+    assert!(is_retryable(&EuleError::RateLimited(Duration::from_secs(
+        1
+    ))));
+    assert!(!is_retryable(&EuleError::MissingPermissions(
+        "Missing Access".to_string()
+    )));
+}
+
+#[test]
+fn test_merge_keeps_queued_entries() {
+    let entry = |message_id, attempts| RetryEntry {
+        channel_id: ChannelId::new(2),
+        message_id: MessageId::new(message_id),
+        attempts,
+    };
+
+    let merged = merge_deferred(vec![entry(10, 2)], vec![entry(10, 0), entry(11, 0)]);
+
+    assert_eq!(merged, vec![entry(10, 2), entry(11, 0)]);
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_purge_sets_aside_throttled_deletes() {
+    let api = MockDiscord::new();
+    let channel_id = ChannelId::new(2);
+    api.add_messages(channel_id, 5, Duration::from_secs(60));
+    api.throttle_deletes(channel_id);
+
+    let failed = purge_channel(&api, channel_id, &PurgeOptions::default()).await;
+    assert!(matches!(failed, Err(EuleError::RateLimited(_))));
+
+    let deferred = DeferredDeletes::default();
+    let options = PurgeOptions {
+        deferred: Some(deferred.clone()),
+        ..Default::default()
+    };
+    let report = purge_channel(&api, channel_id, &options).await.unwrap();
+    assert_eq!((report.deleted, report.deferred), (0, 5));
+    let queue = deferred.take();
+    assert_eq!(queue.len(), 5);
+
+    api.stop_throttling(channel_id);
+    let retried = retry_deletes(&api, queue, &options).await.unwrap();
+    assert_eq!(retried.deleted, 5);
+    assert!(retried.remaining.is_empty());
+    assert_eq!(api.remaining(channel_id), 0);
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_task_retries_set_aside_deletes_on_next_cleanup() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let manager = AutocleanManager::new(Arc::new(KvStore::new(path).unwrap()));
+    let api = MockDiscord::new();
+    let (guild_id, channel_id) = (GuildId::new(1), ChannelId::new(2));
+    api.add_messages(channel_id, 5, Duration::from_secs(60));
+    manager
+        .add_task(guild_id, channel_id, Duration::from_secs(3600))
+        .await
+        .unwrap();
+
+    api.throttle_deletes(channel_id);
+    manager.purge_now(&api, guild_id, channel_id).await.unwrap();
+    manager.purge_now(&api, guild_id, channel_id).await.unwrap();
+    let task = manager.task(guild_id, channel_id).await.unwrap();
+    assert_eq!(task.retry_queue.len(), 5);
+    assert!(task.retry_queue.iter().all(|entry| entry.attempts == 1));
+    assert_eq!(
+        manager.retry_queues().await,
+        vec![(guild_id, channel_id, 5)]
+    );
+
+    api.stop_throttling(channel_id);
+    manager.purge_now(&api, guild_id, channel_id).await.unwrap();
+    assert!(manager.retry_queues().await.is_empty());
+    assert_eq!(api.remaining(channel_id), 0);
+}
@@ -1,4 +1,4 @@
-use eule::utils::serializable_instant::SerializableInstant;
+use eule::utils::serializable_instant::{parse_utc, SerializableInstant};
 use tokio::time::{sleep, Duration, Instant};
 
 #[tokio::test]
@@ -40,3 +40,22 @@ async fn test_serializable_instant_duration_since() {
     let duration = instant2.duration_since(instant1);
     assert!(duration >= Duration::from_millis(1000));
 }
+
+#[test]
+fn test_parse_utc_dates_and_times() {
+    let midnight = parse_utc("2026-06-01").unwrap();
+    assert_eq!(midnight.utc_basic(), "20260601T000000Z");
+    assert_eq!(
+        parse_utc(" 2026-06-01 03:00 ").unwrap().utc_basic(),
+        "20260601T030000Z"
+    );
+    assert_eq!(
+        parse_utc("2024-02-29").unwrap().utc_basic(),
+        "20240229T000000Z"
+    );
+
+    assert_eq!(parse_utc("2026-02-29"), None);
+    assert_eq!(parse_utc("2026-13-01"), None);
+    assert_eq!(parse_utc("2026-06-01 24:00"), None);
+    assert_eq!(parse_utc("June 1st"), None);
+}
@@ -0,0 +1,65 @@
+mod test_utils;
+
+use eule::{
+    commands::setup::{apply_setup, setup_review, SetupChoices, SetupFilter},
+    i18n::Language,
+    store::KvStore,
+    tasks::AutocleanManager,
+};
+use poise::serenity_prelude::{ChannelId, GuildId, UserId};
+use std::sync::Arc;
+use test_utils::{unique_test_path, TestCleanup};
+use tokio::time::Duration;
+
+fn choices() -> SetupChoices {
+    SetupChoices {
+        channels: vec![ChannelId::new(10), ChannelId::new(11)],
+        interval: Duration::from_secs(6 * 3600),
+        filters: vec![SetupFilter::KeepPinned, SetupFilter::IncludeThreads],
+        log_channel: Some(ChannelId::new(20)),
+    }
+}
+
+#[test]
+fn test_setup_filters_round_trip_through_menu_values() {
+    for filter in SetupFilter::ALL {
+        assert_eq!(SetupFilter::from_value(filter.value()), Some(filter));
+    }
+    assert_eq!(SetupFilter::from_value("nuke"), None);
+}
+
+#[test]
+fn test_setup_review_lists_every_choice() {
+    let review = setup_review(Language::En, &choices());
+
+    assert!(review.contains("<#10>, <#11>"));
+    assert!(review.contains("6 hours"));
+    assert!(review.contains("Keep pinned messages, Also clean threads"));
+    assert!(review.contains("<#20>"));
+}
+
+#[tokio::test]
+async fn test_apply_setup_adds_tasks_with_filters_and_log_channel() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let manager = AutocleanManager::new(Arc::new(KvStore::new(path).unwrap()));
+    let guild_id = GuildId::new(1);
+
+    apply_setup(&manager, guild_id, UserId::new(7), &choices())
+        .await
+        .unwrap();
+
+    for channel_id in [ChannelId::new(10), ChannelId::new(11)] {
+        let task = manager.task(guild_id, channel_id).await.unwrap();
+        assert_eq!(task.interval, Duration::from_secs(6 * 3600));
+        assert!(task.keep_pinned);
+        assert!(task.include_threads);
+        assert!(!task.keep_first_message);
+        assert!(task.dry_run);
+        assert_eq!(task.created_by, Some(UserId::new(7)));
+    }
+    assert_eq!(
+        manager.guild_settings(guild_id).await.log_channel,
+        Some(ChannelId::new(20))
+    );
+}
@@ -0,0 +1,32 @@
+use eule::{
+    purge::{DiscordApi, SharedHttp},
+    tasks::nuke_channel,
+};
+use poise::serenity_prelude::{ChannelId, Http, MessageId};
+use std::sync::Arc;
+
+// The client's token is never checked, since no request reaches Discord
+#[tokio::test]
+async fn test_simulated_deletes_never_reach_discord() {
+    let api = SharedHttp::new(Arc::new(Http::new("not a token"))).with_simulated_deletes(true);
+    assert!(api.simulates_deletes());
+    let channel_id = ChannelId::new(2);
+
+    api.delete_message(channel_id, MessageId::new(3))
+        .await
+        .unwrap();
+    api.delete_messages(channel_id, &[MessageId::new(3), MessageId::new(4)])
+        .await
+        .unwrap();
+    api.delete_thread(ChannelId::new(5)).await.unwrap();
+    assert_eq!(nuke_channel(&api, channel_id).await.unwrap(), channel_id);
+}
+
+#[test]
+fn test_deletes_are_real_unless_simulated() {
+    let api = SharedHttp::new(Arc::new(Http::new("not a token")));
+    assert!(!api.simulates_deletes());
+    // Clones share the client, but each keeps its own setting
+    assert!(api.clone().with_simulated_deletes(true).simulates_deletes());
+    assert!(!api.simulates_deletes());
+}
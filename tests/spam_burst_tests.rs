@@ -0,0 +1,146 @@
+mod test_utils;
+
+use eule::{
+    store::KvStore,
+    tasks::{
+        spam_burst::{Burst, BurstRule, SpamBursts},
+        AutocleanManager,
+    },
+    utils::{MockClock, SerializableInstant},
+};
+use poise::serenity_prelude::{ChannelId, GuildId, MessageId, UserId};
+use std::sync::Arc;
+use test_utils::{mock_discord::MockDiscord, unique_test_path, TestCleanup};
+use tokio::time::Duration;
+
+const RULE: BurstRule = BurstRule {
+    messages: 3,
+    window: Duration::from_secs(5),
+};
+
+#[test]
+fn test_a_burst_starts_once_the_rule_is_reached() {
+    let bursts = SpamBursts::default();
+    let (channel_id, user_id) = (ChannelId::new(1), UserId::new(2));
+    let start = SerializableInstant::now();
+    let record = |id: u64, secs: u64| {
+        bursts.record(
+            channel_id,
+            user_id,
+            MessageId::new(id),
+            start + Duration::from_secs(secs),
+            RULE,
+        )
+    };
+
+    assert_eq!(record(1, 0), Burst::None);
+    assert_eq!(record(2, 1), Burst::None);
+    assert_eq!(
+        record(3, 2),
+        Burst::Started(vec![
+            MessageId::new(1),
+            MessageId::new(2),
+            MessageId::new(3)
+        ])
+    );
+    // Even slower messages belong to the burst until the member goes quiet
+    assert_eq!(record(4, 6), Burst::Continued(MessageId::new(4)));
+    assert_eq!(record(5, 20), Burst::None);
+}
+
+#[test]
+fn test_slow_posters_and_other_members_do_not_burst() {
+    let bursts = SpamBursts::default();
+    let channel_id = ChannelId::new(1);
+    let start = SerializableInstant::now();
+
+    for i in 0..5 {
+        let at = start + Duration::from_secs(i * 3);
+        assert_eq!(
+            bursts.record(channel_id, UserId::new(2), MessageId::new(i + 1), at, RULE),
+            Burst::None
+        );
+        assert_eq!(
+            bursts.record(
+                channel_id,
+                UserId::new(3 + i),
+                MessageId::new(i + 10),
+                at,
+                RULE
+            ),
+            Burst::None
+        );
+    }
+}
+
+#[tokio::test]
+async fn test_manager_deletes_bursts() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let clock = Arc::new(MockClock::new(SerializableInstant::now()));
+    let manager =
+        AutocleanManager::with_clock(Arc::new(KvStore::new(path).unwrap()), clock.clone());
+    let api = MockDiscord::new();
+    let (guild_id, channel_id, user_id) = (GuildId::new(1), ChannelId::new(2), UserId::new(3));
+    let messages: Vec<MessageId> = (0..4)
+        .map(|_| api.post(channel_id, user_id, Duration::ZERO, false))
+        .collect();
+
+    // Guilds without a rule aren't watched
+    for message in &messages {
+        let burst = manager
+            .check_spam_burst(&api, guild_id, channel_id, user_id, *message)
+            .await
+            .unwrap();
+        assert_eq!(burst, Burst::None);
+    }
+
+    manager.set_spam_burst(guild_id, Some(RULE)).await.unwrap();
+    for message in &messages[..2] {
+        manager
+            .check_spam_burst(&api, guild_id, channel_id, user_id, *message)
+            .await
+            .unwrap();
+        clock.advance(Duration::from_secs(1));
+    }
+    assert!(api.has_message(channel_id, messages[0]));
+    let burst = manager
+        .check_spam_burst(&api, guild_id, channel_id, user_id, messages[2])
+        .await
+        .unwrap();
+    assert_eq!(burst, Burst::Started(messages[..3].to_vec()));
+    assert!(messages[..3]
+        .iter()
+        .all(|message| !api.has_message(channel_id, *message)));
+
+    let burst = manager
+        .check_spam_burst(&api, guild_id, channel_id, user_id, messages[3])
+        .await
+        .unwrap();
+    assert_eq!(burst, Burst::Continued(messages[3]));
+    assert!(!api.has_message(channel_id, messages[3]));
+}
+
+#[tokio::test]
+async fn test_held_channels_keep_bursts() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let manager = AutocleanManager::new(Arc::new(KvStore::new(path).unwrap()));
+    let api = MockDiscord::new();
+    let (guild_id, channel_id, user_id) = (GuildId::new(1), ChannelId::new(2), UserId::new(3));
+    manager.set_spam_burst(guild_id, Some(RULE)).await.unwrap();
+    manager
+        .place_hold(guild_id, channel_id, UserId::new(9), None)
+        .await
+        .unwrap();
+
+    for _ in 0..4 {
+        let message = api.post(channel_id, user_id, Duration::ZERO, false);
+        let burst = manager
+            .check_spam_burst(&api, guild_id, channel_id, user_id, message)
+            .await
+            .unwrap();
+        assert_eq!(burst, Burst::None);
+        assert!(api.has_message(channel_id, message));
+    }
+}
@@ -0,0 +1,127 @@
+mod test_utils;
+
+use eule::{
+    purge::{message_links, ChannelMessage, ReactionCount, StarboardOptions},
+    store::KvStore,
+    tasks::AutocleanManager,
+};
+use poise::serenity_prelude::{ChannelId, GuildId, MessageId, UserId};
+use std::sync::Arc;
+use test_utils::{mock_discord::MockDiscord, unique_test_path, TestCleanup};
+use tokio::time::Duration;
+
+fn message_with(reactions: &[(&str, u64)]) -> ChannelMessage {
+    ChannelMessage {
+        id: MessageId::new(1),
+        author_id: UserId::new(1),
+        pinned: false,
+        content: String::new(),
+        reactions: reactions
+            .iter()
+            .map(|(emoji, count)| ReactionCount {
+                emoji: emoji.to_string(),
+                count: *count,
+            })
+            .collect(),
+        links: Vec::new(),
+    }
+}
+
+#[test]
+fn test_message_links_skips_non_message_links() {
+    let text = "see https://discord.com/channels/1/2 and https://discord.com/channels/@me/2/3 \
+                but keep <https://canary.discord.com/channels/1/2/30>";
+    assert_eq!(message_links(text), vec![MessageId::new(30)]);
+}
+
+#[test]
+fn test_starred_needs_threshold_of_the_configured_emoji() {
+    let starboard = StarboardOptions {
+        threshold: Some(3),
+        ..Default::default()
+    };
+    assert!(starboard.is_starred(&message_with(&[("⭐", 3)])));
+    assert!(!starboard.is_starred(&message_with(&[("⭐", 2), ("🔥", 9)])));
+    assert!(!StarboardOptions::default().is_starred(&message_with(&[("⭐", 50)])));
+
+    let custom = StarboardOptions {
+        emoji: "<a:shiny:77>".to_string(),
+        threshold: Some(1),
+        channel: None,
+    };
+    assert!(custom.is_starred(&message_with(&[("<:shiny:77>", 1)])));
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_cleanup_keeps_starboarded_messages() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let manager = AutocleanManager::new(Arc::new(KvStore::new(path).unwrap()));
+    let api = MockDiscord::new();
+    let guild_id = GuildId::new(1);
+    let channel_id = ChannelId::new(2);
+    let starboard_id = ChannelId::new(3);
+    manager
+        .add_task(guild_id, channel_id, Duration::from_secs(3600))
+        .await
+        .unwrap();
+
+    api.add_messages(channel_id, 5, Duration::from_secs(60));
+    let starred = api.post(channel_id, UserId::new(4), Duration::from_secs(60), false);
+    api.react(channel_id, starred, "⭐", 5);
+    let featured = api.post(channel_id, UserId::new(4), Duration::from_secs(60), false);
+    api.post_link(starboard_id, featured);
+
+    assert!(manager
+        .set_starboard(
+            guild_id,
+            channel_id,
+            Some(StarboardOptions {
+                threshold: Some(3),
+                channel: Some(starboard_id),
+                ..Default::default()
+            }),
+        )
+        .await
+        .unwrap());
+    manager.purge_now(&api, guild_id, channel_id).await.unwrap();
+    assert_eq!(api.remaining(channel_id), 2);
+
+    manager
+        .set_starboard(guild_id, channel_id, None)
+        .await
+        .unwrap();
+    manager.purge_now(&api, guild_id, channel_id).await.unwrap();
+    assert_eq!(api.remaining(channel_id), 0);
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_cleanup_fails_when_starboard_is_unreadable() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let manager = AutocleanManager::new(Arc::new(KvStore::new(path).unwrap()));
+    let api = MockDiscord::new();
+    let guild_id = GuildId::new(1);
+    let channel_id = ChannelId::new(2);
+    let starboard_id = ChannelId::new(3);
+    manager
+        .add_task(guild_id, channel_id, Duration::from_secs(3600))
+        .await
+        .unwrap();
+    manager
+        .set_starboard(
+            guild_id,
+            channel_id,
+            Some(StarboardOptions {
+                channel: Some(starboard_id),
+                ..Default::default()
+            }),
+        )
+        .await
+        .unwrap();
+    api.add_messages(channel_id, 5, Duration::from_secs(60));
+    api.revoke_access(starboard_id);
+
+    assert!(manager.purge_now(&api, guild_id, channel_id).await.is_err());
+    assert_eq!(api.remaining(channel_id), 5);
+}
@@ -0,0 +1,183 @@
+use eule::{
+    commands::{
+        paginate::{paginate_fields, task_field, MAX_FIELD_VALUE, MAX_LINE_CHARS, MAX_PAGE_CHARS},
+        status::format_shard,
+    },
+    i18n::Language,
+    tasks::{CleanupTask, TaskState},
+    utils::SerializableInstant,
+};
+use poise::serenity_prelude::{ChannelId, ConnectionStage, ShardId, UserId};
+use std::time::Duration;
+
+#[test]
+fn test_format_connected_shard() {
+    let line = format_shard(
+        Language::En,
+        ShardId(0),
+        ConnectionStage::Connected,
+        Some(Duration::from_millis(42)),
+        Some(Duration::from_secs(3)),
+    );
+
+    assert!(line.starts_with("🟢 Shard 0"));
+    assert!(line.contains("latency 42 ms"));
+    assert!(line.contains("last event 3s ago"));
+}
+
+#[test]
+fn test_format_dead_shard() {
+    let line = format_shard(
+        Language::En,
+        ShardId(3),
+        ConnectionStage::Disconnected,
+        None,
+        None,
+    );
+
+    assert!(line.starts_with("🔴 Shard 3"));
+    assert!(line.contains("no heartbeat yet"));
+    assert!(line.contains("last event never"));
+}
+
+#[test]
+fn test_format_shard_in_german() {
+    let line = format_shard(
+        Language::De,
+        ShardId(3),
+        ConnectionStage::Disconnected,
+        None,
+        None,
+    );
+
+    assert!(line.starts_with("🔴 Shard 3"));
+    assert!(line.contains("noch kein Heartbeat"));
+    assert!(line.contains("letztes Ereignis nie"));
+}
+
+#[test]
+fn test_paginate_fields_splits_pages() {
+    let fields: Vec<_> = (0..23)
+        .map(|i| (format!("name {}", i), format!("value {}", i)))
+        .collect();
+
+    let pages = paginate_fields(fields, 10);
+
+    assert_eq!(pages.len(), 3);
+    assert_eq!(pages[0].len(), 10);
+    assert_eq!(pages[2].len(), 3);
+    assert_eq!(pages[2][0].0, "name 20");
+}
+
+#[test]
+fn test_paginate_no_fields_gives_one_page() {
+    let pages = paginate_fields(Vec::new(), 10);
+
+    assert_eq!(pages.len(), 1);
+    assert!(pages[0].is_empty());
+}
+
+#[tokio::test]
+async fn test_task_field_shows_schedule() {
+    let mut task = CleanupTask::new(Duration::from_secs(3600)).await;
+    task.include_threads = true;
+
+    let (name, value) = task_field(
+        Language::En,
+        ChannelId::new(42),
+        &task,
+        TaskState::Scheduled,
+    );
+
+    assert_eq!(name, "Every 1 hour");
+    assert!(value.starts_with("<#42>"));
+    assert!(value.contains("Next run: <t:"));
+    assert!(value.contains("State: Scheduled"));
+    assert!(value.contains("Includes threads"));
+}
+
+#[tokio::test]
+async fn test_task_field_in_german() {
+    let mut task = CleanupTask::new(Duration::from_secs(3600)).await;
+    task.keep_pinned = true;
+
+    let (name, value) = task_field(Language::De, ChannelId::new(42), &task, TaskState::Running);
+
+    assert!(name.starts_with("Alle "));
+    assert!(value.contains("Status: Räumt gerade auf"));
+    assert!(value.contains("Behält angepinnte Nachrichten"));
+}
+
+#[tokio::test]
+async fn test_task_field_shows_who_created_and_changed_the_task() {
+    let mut task = CleanupTask::new(Duration::from_secs(3600)).await;
+    let (name, value) = task_field(
+        Language::En,
+        ChannelId::new(42),
+        &task,
+        TaskState::Scheduled,
+    );
+    assert!(!value.contains("Created by") && !value.contains("Last changed by"));
+
+    task.created_by = Some(UserId::new(7));
+    task.edited_by = Some(UserId::new(8));
+    task.edited_at = Some(SerializableInstant::now());
+    let (_, value) = task_field(
+        Language::En,
+        ChannelId::new(42),
+        &task,
+        TaskState::Scheduled,
+    );
+
+    assert_eq!(name, "Every 1 hour");
+    assert!(value.contains("Created by <@7>"));
+    assert!(value.contains("Last changed by <@8> <t:"));
+}
+
+#[tokio::test]
+async fn test_task_field_fits_in_an_embed_field() {
+    let mut task = CleanupTask::new(Duration::from_secs(3600)).await;
+    task.keep_keywords = (0..10)
+        .map(|i| format!("{}{}", i, "k".repeat(499)))
+        .collect();
+    task.labels = (0..10)
+        .map(|i| format!("{}{}", i, "l".repeat(199)))
+        .collect();
+    task.created_by = Some(UserId::new(7));
+
+    let (_, value) = task_field(
+        Language::En,
+        ChannelId::new(42),
+        &task,
+        TaskState::Scheduled,
+    );
+
+    assert!(value.chars().count() <= MAX_FIELD_VALUE);
+    assert!(value
+        .lines()
+        .all(|line| line.chars().count() <= MAX_LINE_CHARS));
+    assert!(value.contains("Keeps messages with 0kkk"));
+    assert!(value.contains('…'));
+}
+
+#[test]
+fn test_paginate_fields_keeps_pages_under_the_embed_limit() {
+    let fields: Vec<_> = (0..10)
+        .map(|i| (format!("name {}", i), "v".repeat(2000)))
+        .collect();
+
+    let pages = paginate_fields(fields, 10);
+
+    assert!(pages.len() > 1);
+    for page in &pages {
+        let chars: usize = page
+            .iter()
+            .map(|(name, value)| name.chars().count() + value.chars().count())
+            .sum();
+        assert!(chars <= MAX_PAGE_CHARS);
+        assert!(page
+            .iter()
+            .all(|(_, value)| value.chars().count() <= MAX_FIELD_VALUE));
+    }
+    assert_eq!(pages.iter().map(Vec::len).sum::<usize>(), 10);
+}
@@ -0,0 +1,94 @@
+mod test_utils;
+
+use eule::{
+    i18n::Language,
+    store::KvStore,
+    tasks::{
+        guild_settings::MessageTemplates,
+        summary::{post_summary, summary_text},
+        AutocleanManager, PurgeEvent, PurgeEventKind,
+    },
+    utils::SerializableInstant,
+};
+use poise::serenity_prelude::{ChannelId, GuildId};
+use std::sync::Arc;
+use test_utils::{mock_discord::MockDiscord, unique_test_path, TestCleanup};
+use tokio::time::Duration;
+
+const DAY: Duration = Duration::from_secs(24 * 60 * 60);
+
+fn completed(guild_id: GuildId, channel_id: ChannelId, deleted: usize) -> PurgeEvent {
+    PurgeEvent {
+        kind: PurgeEventKind::Completed,
+        guild_id,
+        channel_id,
+        at: SerializableInstant::now(),
+        deleted,
+        blocked_links: 0,
+        cancelled: false,
+        duration_ms: 1000,
+        error: None,
+        missing_permissions: false,
+    }
+}
+
+#[test]
+fn test_summary_text_counts_deleted_messages() {
+    let text = summary_text(
+        &MessageTemplates::default(),
+        Language::En,
+        ChannelId::new(7),
+        1204,
+        Some(SerializableInstant::now()),
+    );
+
+    assert!(text.starts_with("🧹 Removed 1,204 messages from <#7>; next purge <t:"));
+}
+
+#[tokio::test]
+async fn test_summary_posted_in_log_channel() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let manager = AutocleanManager::new(Arc::new(KvStore::new(path).unwrap()));
+    let api = MockDiscord::new();
+    let guild_id = GuildId::new(1);
+    let channel_id = ChannelId::new(2);
+    let log_channel = ChannelId::new(3);
+
+    manager.add_task(guild_id, channel_id, DAY).await.unwrap();
+    post_summary(&manager, &api, &completed(guild_id, channel_id, 12)).await;
+    assert!(api.sent().is_empty());
+
+    assert!(manager
+        .set_summary(guild_id, channel_id, Some(log_channel))
+        .await
+        .unwrap());
+    post_summary(&manager, &api, &completed(guild_id, channel_id, 12)).await;
+
+    let sent = api.sent();
+    assert_eq!(sent.len(), 1);
+    assert_eq!(sent[0].0, log_channel);
+    assert!(sent[0].1.contains("<#2>"));
+    assert_eq!(sent[0].2, None);
+}
+
+#[tokio::test]
+async fn test_no_summary_for_cancelled_cleanups() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let manager = AutocleanManager::new(Arc::new(KvStore::new(path).unwrap()));
+    let api = MockDiscord::new();
+    let guild_id = GuildId::new(1);
+    let channel_id = ChannelId::new(2);
+
+    manager.add_task(guild_id, channel_id, DAY).await.unwrap();
+    manager
+        .set_summary(guild_id, channel_id, Some(channel_id))
+        .await
+        .unwrap();
+    let mut event = completed(guild_id, channel_id, 12);
+    event.cancelled = true;
+    post_summary(&manager, &api, &event).await;
+
+    assert!(api.sent().is_empty());
+}
@@ -0,0 +1,98 @@
+use eule::{
+    purge::CancelToken,
+    tasks::{CleanupTask, FailureKind, TaskState, MAX_CONSECUTIVE_FAILURES},
+    utils::SerializableInstant,
+};
+use std::time::Duration;
+
+const HOUR: Duration = Duration::from_secs(3600);
+
+fn task() -> CleanupTask {
+    CleanupTask::starting_at(HOUR, SerializableInstant::now())
+}
+
+#[test]
+fn test_cleanup_moves_through_running() {
+    let mut task = task();
+    assert_eq!(task.state, TaskState::Scheduled);
+
+    task.start(CancelToken::new());
+    assert_eq!(task.state, TaskState::Running);
+    assert!(task.running.is_some());
+
+    task.finish(Some(FailureKind::MissingPermissions));
+    assert_eq!(task.state, TaskState::Failed);
+    assert!(task.running.is_none());
+    assert_eq!(task.failures, 1);
+
+    task.start(CancelToken::new());
+    task.finish(None);
+    assert_eq!(task.state, TaskState::Scheduled);
+    assert_eq!(task.failures, 0);
+    assert_eq!(task.failure, None);
+}
+
+#[test]
+fn test_pause_only_after_repeated_failures() {
+    let mut task = task();
+    for _ in 1..MAX_CONSECUTIVE_FAILURES {
+        task.finish(Some(FailureKind::MissingPermissions));
+    }
+    assert!(!task.pause_if_failing());
+    assert_eq!(task.state, TaskState::Failed);
+
+    task.finish(Some(FailureKind::MissingPermissions));
+    assert!(task.pause_if_failing());
+    assert_eq!(task.state, TaskState::Paused);
+    assert!(!task.pause_if_failing());
+
+    assert!(task.resume());
+    assert_eq!(task.state, TaskState::Scheduled);
+    assert_eq!(task.failures, 0);
+    assert!(!task.resume());
+}
+
+#[test]
+fn test_state_at_accounts_for_holds_and_expiry() {
+    let now = SerializableInstant::now();
+    let mut task = task();
+    task.expires = Some(now);
+
+    assert_eq!(task.state_at(now, false), TaskState::Expired);
+    assert_eq!(task.state_at(now, true), TaskState::OnHold);
+    task.expires = None;
+    assert_eq!(task.state_at(now, false), TaskState::Scheduled);
+
+    task.start(CancelToken::new());
+    assert_eq!(task.state_at(now, true), TaskState::Running);
+}
+
+#[test]
+fn test_restore_ends_interrupted_cleanups() {
+    let mut task = task();
+    task.finish(Some(FailureKind::MissingPermissions));
+    task.start(CancelToken::new());
+    let json = serde_json::to_string(&task).unwrap();
+
+    let mut loaded: CleanupTask = serde_json::from_str(&json).unwrap();
+    loaded.restore();
+
+    assert_eq!(loaded.state, TaskState::Failed);
+    assert!(loaded.running.is_none());
+}
+
+#[test]
+fn test_restore_keeps_legacy_pause() {
+    let mut json = serde_json::to_value(task()).unwrap();
+    let fields = json.as_object_mut().unwrap();
+    fields.remove("state");
+    fields.insert("paused".to_string(), serde_json::Value::Bool(true));
+
+    let mut loaded: CleanupTask = serde_json::from_value(json).unwrap();
+    assert_eq!(loaded.state, TaskState::Scheduled);
+    loaded.restore();
+
+    assert_eq!(loaded.state, TaskState::Paused);
+    let saved = serde_json::to_value(&loaded).unwrap();
+    assert!(saved.get("paused").is_none());
+}
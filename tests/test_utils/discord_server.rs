@@ -0,0 +1,336 @@
+#![allow(dead_code)]
+
+//! A local HTTP server standing in for the Discord REST API.
+//!
+//! `MockDiscord` replaces the `DiscordApi` implementation; this serves
+//! Discord's REST routes instead, so the bot's real client, Serenity's `Http`
+//! with its rate limiter, can be pointed at it. It simulates channel
+//! histories with `before`-cursor pagination, the bulk-delete limits Discord
+//! enforces, and periodic 429 responses carrying a `Retry-After` header.
+
+use eule::utils::{snowflake, SerializableInstant};
+use http_body_util::{BodyExt, Full};
+use hyper::{
+    body::{Bytes, Incoming},
+    server::conn::http1,
+    service::service_fn,
+    Method, Request, Response, StatusCode,
+};
+use hyper_util::rt::TokioIo;
+use poise::serenity_prelude::{ChannelId, Http, HttpBuilder, Timestamp};
+use serde::Deserialize;
+use serde_json::{json, Value};
+use std::{
+    collections::HashMap,
+    convert::Infallible,
+    net::SocketAddr,
+    sync::{
+        atomic::{AtomicU64, AtomicUsize, Ordering},
+        Arc, Mutex,
+    },
+};
+use tokio::{net::TcpListener, task::JoinHandle, time::Duration};
+
+/// Age after which Discord refuses to bulk delete a message.
+const BULK_DELETE_LIMIT: Duration = Duration::from_secs(14 * 24 * 60 * 60);
+
+/// What the server knows and counts, shared with its connections.
+#[derive(Default)]
+struct ServerState {
+    /// Each channel's message IDs, oldest first.
+    channels: Mutex<HashMap<u64, Vec<u64>>>,
+    sequence: AtomicU64,
+    rate_limit_every: Option<usize>,
+    requests: AtomicUsize,
+    fetches: AtomicUsize,
+    bulk_deletes: AtomicUsize,
+    single_deletes: AtomicUsize,
+    rate_limited: AtomicUsize,
+    rejected: AtomicUsize,
+}
+
+/// A simulated Discord REST API listening on a local port.
+///
+/// The server stops when it is dropped.
+pub struct DiscordServer {
+    address: SocketAddr,
+    state: Arc<ServerState>,
+    server: JoinHandle<()>,
+}
+
+impl DiscordServer {
+    /// Starts a server that never rate limits.
+    pub async fn start() -> Self {
+        Self::serve(ServerState::default()).await
+    }
+
+    /// Starts a server that answers every `n`th request with a 429.
+    pub async fn with_rate_limit_every(n: usize) -> Self {
+        Self::serve(ServerState {
+            rate_limit_every: Some(n),
+            ..ServerState::default()
+        })
+        .await
+    }
+
+    async fn serve(state: ServerState) -> Self {
+        let listener = TcpListener::bind("127.0.0.1:0").await.unwrap();
+        let address = listener.local_addr().unwrap();
+        let state = Arc::new(state);
+        let server = tokio::spawn({
+            let state = Arc::clone(&state);
+            async move {
+                while let Ok((stream, _)) = listener.accept().await {
+                    let state = Arc::clone(&state);
+                    tokio::spawn(async move {
+                        let service = service_fn(move |request| {
+                            let state = Arc::clone(&state);
+                            async move { Ok::<_, Infallible>(respond(&state, request).await) }
+                        });
+                        let _ = http1::Builder::new()
+                            .serve_connection(TokioIo::new(stream), service)
+                            .await;
+                    });
+                }
+            }
+        });
+        Self {
+            address,
+            state,
+            server,
+        }
+    }
+
+    /// Creates a Discord client that sends its requests to this server.
+    pub fn http(&self) -> Http {
+        HttpBuilder::new("not a token")
+            .proxy(format!("http://{}", self.address))
+            .build()
+    }
+
+    /// Posts `count` messages of the given age to a channel.
+    pub fn add_messages(&self, channel_id: ChannelId, count: usize, age: Duration) {
+        let base = snowflake::from_instant(SerializableInstant::now())
+            .saturating_sub((age.as_millis() as u64) << 22);
+        let mut channels = self.state.channels.lock().unwrap();
+        let messages = channels.entry(channel_id.get()).or_default();
+        for _ in 0..count {
+            let seq = self.state.sequence.fetch_add(1, Ordering::SeqCst);
+            messages.push(base + seq + 1);
+        }
+        messages.sort_unstable();
+    }
+
+    /// Returns how many messages are left in a channel.
+    pub fn remaining(&self, channel_id: ChannelId) -> usize {
+        self.state
+            .channels
+            .lock()
+            .unwrap()
+            .get(&channel_id.get())
+            .map_or(0, Vec::len)
+    }
+
+    /// Returns how many pages of messages were fetched.
+    pub fn fetches(&self) -> usize {
+        self.state.fetches.load(Ordering::SeqCst)
+    }
+
+    /// Returns how many bulk deletes succeeded.
+    pub fn bulk_deletes(&self) -> usize {
+        self.state.bulk_deletes.load(Ordering::SeqCst)
+    }
+
+    /// Returns how many single messages were deleted.
+    pub fn single_deletes(&self) -> usize {
+        self.state.single_deletes.load(Ordering::SeqCst)
+    }
+
+    /// Returns how many requests were answered with a 429.
+    pub fn rate_limited(&self) -> usize {
+        self.state.rate_limited.load(Ordering::SeqCst)
+    }
+
+    /// Returns how many bulk deletes broke Discord's limits and were refused.
+    pub fn rejected(&self) -> usize {
+        self.state.rejected.load(Ordering::SeqCst)
+    }
+}
+
+impl Drop for DiscordServer {
+    fn drop(&mut self) {
+        self.server.abort();
+    }
+}
+
+#[derive(Deserialize)]
+struct BulkDelete {
+    messages: Vec<String>,
+}
+
+async fn respond(state: &ServerState, request: Request<Incoming>) -> Response<Full<Bytes>> {
+    let request_number = state.requests.fetch_add(1, Ordering::SeqCst) + 1;
+    if state
+        .rate_limit_every
+        .is_some_and(|n| request_number % n == 0)
+    {
+        state.rate_limited.fetch_add(1, Ordering::SeqCst);
+        let mut response = json_response(
+            StatusCode::TOO_MANY_REQUESTS,
+            json!({
+                "message": "You are being rate limited.",
+                "retry_after": 0.0,
+                "global": false,
+            }),
+        );
+        response
+            .headers_mut()
+            .insert("retry-after", "0".parse().unwrap());
+        return response;
+    }
+
+    let (parts, body) = request.into_parts();
+    let body = body
+        .collect()
+        .await
+        .map(|collected| collected.to_bytes())
+        .unwrap_or_default();
+    let segments: Vec<&str> = parts.uri.path().trim_matches('/').split('/').collect();
+    let query = parts.uri.query().unwrap_or_default();
+    match (&parts.method, segments.as_slice()) {
+        (&Method::GET, ["api", _, "channels", channel, "messages"]) => {
+            messages(state, parse_id(channel), query)
+        }
+        (&Method::POST, ["api", _, "channels", channel, "messages", "bulk-delete"]) => {
+            bulk_delete(state, parse_id(channel), &body)
+        }
+        (&Method::DELETE, ["api", _, "channels", channel, "messages", message]) => {
+            delete_message(state, parse_id(channel), parse_id(message))
+        }
+        _ => error(StatusCode::NOT_FOUND, 0, "404: Not Found"),
+    }
+}
+
+fn messages(state: &ServerState, channel: u64, query: &str) -> Response<Full<Bytes>> {
+    state.fetches.fetch_add(1, Ordering::SeqCst);
+    let mut limit = 50;
+    let mut before = u64::MAX;
+    for (key, value) in query.split('&').filter_map(|pair| pair.split_once('=')) {
+        match key {
+            "limit" => limit = value.parse().unwrap_or(limit),
+            "before" => before = parse_id(value),
+            _ => {}
+        }
+    }
+    let channels = state.channels.lock().unwrap();
+    // Discord lists the newest messages first
+    let page: Vec<Value> = channels
+        .get(&channel)
+        .into_iter()
+        .flatten()
+        .rev()
+        .filter(|id| **id < before)
+        .take(limit)
+        .map(|id| message_json(channel, *id))
+        .collect();
+    json_response(StatusCode::OK, Value::Array(page))
+}
+
+fn bulk_delete(state: &ServerState, channel: u64, body: &[u8]) -> Response<Full<Bytes>> {
+    let Ok(request) = serde_json::from_slice::<BulkDelete>(body) else {
+        return error(StatusCode::BAD_REQUEST, 50035, "Invalid Form Body");
+    };
+    let ids: Vec<u64> = request.messages.iter().map(|id| parse_id(id)).collect();
+    if !(2..=100).contains(&ids.len()) {
+        state.rejected.fetch_add(1, Ordering::SeqCst);
+        return error(
+            StatusCode::BAD_REQUEST,
+            50016,
+            "Bulk deletes take 2 to 100 messages",
+        );
+    }
+    if ids
+        .iter()
+        .any(|id| snowflake::to_instant(*id).elapsed() >= BULK_DELETE_LIMIT)
+    {
+        state.rejected.fetch_add(1, Ordering::SeqCst);
+        return error(
+            StatusCode::BAD_REQUEST,
+            50034,
+            "You can only bulk delete messages that are under 14 days old.",
+        );
+    }
+    if let Some(messages) = state.channels.lock().unwrap().get_mut(&channel) {
+        messages.retain(|id| !ids.contains(id));
+    }
+    state.bulk_deletes.fetch_add(1, Ordering::SeqCst);
+    empty_response()
+}
+
+fn delete_message(state: &ServerState, channel: u64, message: u64) -> Response<Full<Bytes>> {
+    let mut channels = state.channels.lock().unwrap();
+    let Some(messages) = channels.get_mut(&channel) else {
+        return error(StatusCode::NOT_FOUND, 10003, "Unknown Channel");
+    };
+    let Some(position) = messages.iter().position(|id| *id == message) else {
+        return error(StatusCode::NOT_FOUND, 10008, "Unknown Message");
+    };
+    messages.remove(position);
+    state.single_deletes.fetch_add(1, Ordering::SeqCst);
+    empty_response()
+}
+
+/// Describes a message the way Discord does, with only the fields Serenity
+/// requires.
+fn message_json(channel: u64, id: u64) -> Value {
+    let created = snowflake::to_instant(id).to_system_time();
+    let secs = created
+        .duration_since(std::time::UNIX_EPOCH)
+        .unwrap_or_default()
+        .as_secs() as i64;
+    json!({
+        "id": id.to_string(),
+        "channel_id": channel.to_string(),
+        "author": {
+            "id": "1",
+            "username": "member",
+            "discriminator": "0",
+            "global_name": null,
+            "avatar": null,
+        },
+        "content": "hello",
+        "timestamp": Timestamp::from_unix_timestamp(secs).unwrap().to_string(),
+        "edited_timestamp": null,
+        "tts": false,
+        "mention_everyone": false,
+        "mentions": [],
+        "mention_roles": [],
+        "attachments": [],
+        "embeds": [],
+        "pinned": false,
+        "type": 0,
+    })
+}
+
+fn parse_id(id: &str) -> u64 {
+    id.parse().unwrap_or_default()
+}
+
+fn json_response(status: StatusCode, body: Value) -> Response<Full<Bytes>> {
+    let mut response = Response::new(Full::new(Bytes::from(body.to_string())));
+    *response.status_mut() = status;
+    response
+        .headers_mut()
+        .insert("content-type", "application/json".parse().unwrap());
+    response
+}
+
+fn empty_response() -> Response<Full<Bytes>> {
+    let mut response = Response::new(Full::new(Bytes::new()));
+    *response.status_mut() = StatusCode::NO_CONTENT;
+    response
+}
+
+fn error(status: StatusCode, code: u32, message: &str) -> Response<Full<Bytes>> {
+    json_response(status, json!({ "code": code, "message": message }))
+}
@@ -0,0 +1,596 @@
+#![allow(dead_code)]
+
+//! An in-memory stand-in for the Discord REST API.
+//!
+//! Simulates channel histories, `before`-cursor pagination, the bulk-delete
+//! limits Discord enforces, and periodic 429 responses, so the purge
+//! engine can be exercised end to end without a network connection.
+
+use async_trait::async_trait;
+use eule::{
+    error::EuleError,
+    purge::{ChannelMessage, DiscordApi, ReactionCount, SendPermission, ThreadInfo},
+    tasks::BotApi,
+    utils::{snowflake, SerializableInstant},
+};
+use poise::serenity_prelude::{
+    self as serenity, ChannelId, ForumTagId, GuildId, MessageId, Permissions, RoleId, UserId,
+};
+use std::{
+    collections::{HashMap, HashSet},
+    sync::{
+        atomic::{AtomicU64, AtomicUsize, Ordering},
+        Mutex,
+    },
+};
+use tokio::time::Duration;
+
+/// Age after which Discord refuses to bulk delete a message.
+const BULK_DELETE_LIMIT: Duration = Duration::from_secs(14 * 24 * 60 * 60);
+
+/// A simulated Discord API holding per-channel message histories.
+#[derive(Default)]
+pub struct MockDiscord {
+    channels: Mutex<HashMap<ChannelId, Vec<ChannelMessage>>>,
+    threads: Mutex<HashMap<ChannelId, Vec<ThreadInfo>>>,
+    forbidden: Mutex<HashSet<ChannelId>>,
+    throttled: Mutex<HashSet<ChannelId>>,
+    topics: Mutex<HashMap<ChannelId, String>>,
+    slowmodes: Mutex<HashMap<ChannelId, u16>>,
+    slowmode_edits: Mutex<Vec<(ChannelId, u16)>>,
+    send_permissions: Mutex<HashMap<ChannelId, SendPermission>>,
+    send_permission_edits: Mutex<Vec<(ChannelId, SendPermission)>>,
+    sent: Mutex<Vec<(ChannelId, String, Option<RoleId>)>>,
+    owner_messages: Mutex<Vec<(GuildId, String)>>,
+    user_messages: Mutex<Vec<(UserId, String)>>,
+    deleted_channels: Mutex<HashSet<ChannelId>>,
+    panicking: Mutex<HashSet<ChannelId>>,
+    sequence: AtomicU64,
+    rate_limit_every: Option<usize>,
+    fetch_latency: Duration,
+    calls: AtomicUsize,
+    pub fetches: AtomicUsize,
+    pub bulk_deletes: AtomicUsize,
+    pub single_deletes: AtomicUsize,
+    pub rate_limited: AtomicUsize,
+    pub topic_edits: AtomicUsize,
+}
+
+impl MockDiscord {
+    /// Creates an API that never rate limits.
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Creates an API that answers every `n`th request with a 429.
+    pub fn with_rate_limit_every(n: usize) -> Self {
+        Self {
+            rate_limit_every: Some(n),
+            ..Self::default()
+        }
+    }
+
+    /// Creates an API that takes `latency` to answer each page of messages.
+    pub fn with_fetch_latency(latency: Duration) -> Self {
+        Self {
+            fetch_latency: latency,
+            ..Self::default()
+        }
+    }
+
+    /// Posts `count` messages of the given age to a channel.
+    pub fn add_messages(&self, channel_id: ChannelId, count: usize, age: Duration) {
+        for _ in 0..count {
+            self.post(channel_id, UserId::new(1), age, false);
+        }
+    }
+
+    /// Posts a single message and returns its ID.
+    pub fn post(
+        &self,
+        channel_id: ChannelId,
+        author_id: UserId,
+        age: Duration,
+        pinned: bool,
+    ) -> MessageId {
+        let base = snowflake::from_instant(SerializableInstant::now())
+            .saturating_sub((age.as_millis() as u64) << 22);
+        let seq = self.sequence.fetch_add(1, Ordering::SeqCst);
+        let id = MessageId::new(base + seq + 1);
+        self.channels
+            .lock()
+            .unwrap()
+            .entry(channel_id)
+            .or_default()
+            .push(ChannelMessage {
+                id,
+                author_id,
+                pinned,
+                content: String::new(),
+                reactions: Vec::new(),
+                links: Vec::new(),
+            });
+        for thread in self.threads.lock().unwrap().values_mut().flatten() {
+            if thread.id == channel_id {
+                thread.last_message_id = Some(id);
+            }
+        }
+        id
+    }
+
+    /// Sets how often a message was reacted to with `emoji`.
+    pub fn react(&self, channel_id: ChannelId, message_id: MessageId, emoji: &str, count: u64) {
+        for message in self.channels.lock().unwrap().entry(channel_id).or_default() {
+            if message.id == message_id {
+                message.reactions.retain(|reaction| reaction.emoji != emoji);
+                message.reactions.push(ReactionCount {
+                    emoji: emoji.to_string(),
+                    count,
+                });
+            }
+        }
+    }
+
+    /// Posts a message with the given text and returns its ID.
+    pub fn post_text(&self, channel_id: ChannelId, age: Duration, text: &str) -> MessageId {
+        let id = self.post(channel_id, UserId::new(1), age, false);
+        for message in self.channels.lock().unwrap().entry(channel_id).or_default() {
+            if message.id == id {
+                message.content = text.to_string();
+            }
+        }
+        id
+    }
+
+    /// Posts a message linking to `target`, as a starboard would, and returns its ID.
+    pub fn post_link(&self, channel_id: ChannelId, target: MessageId) -> MessageId {
+        let id = self.post(channel_id, UserId::new(1), Duration::ZERO, false);
+        for message in self.channels.lock().unwrap().entry(channel_id).or_default() {
+            if message.id == id {
+                message.links.push(target);
+            }
+        }
+        id
+    }
+
+    /// Creates a thread under `parent` and returns its ID.
+    pub fn add_thread(&self, parent: ChannelId, archived: bool) -> ChannelId {
+        self.add_forum_post(parent, Duration::ZERO, Vec::new(), archived)
+    }
+
+    /// Creates a forum post of the given age under `forum` and returns its ID.
+    pub fn add_forum_post(
+        &self,
+        forum: ChannelId,
+        age: Duration,
+        applied_tags: Vec<ForumTagId>,
+        archived: bool,
+    ) -> ChannelId {
+        let base = snowflake::from_instant(SerializableInstant::now())
+            .saturating_sub((age.as_millis() as u64) << 22);
+        let id = ChannelId::new(base + self.sequence.fetch_add(1, Ordering::SeqCst) + 1);
+        self.threads
+            .lock()
+            .unwrap()
+            .entry(forum)
+            .or_default()
+            .push(ThreadInfo {
+                id,
+                archived,
+                last_message_id: None,
+                applied_tags,
+            });
+        id
+    }
+
+    /// Returns whether a thread still exists.
+    pub fn has_thread(&self, thread_id: ChannelId) -> bool {
+        self.threads
+            .lock()
+            .unwrap()
+            .values()
+            .flatten()
+            .any(|thread| thread.id == thread_id)
+    }
+
+    /// Returns whether a thread is currently archived.
+    pub fn is_archived(&self, thread_id: ChannelId) -> bool {
+        self.threads
+            .lock()
+            .unwrap()
+            .values()
+            .flatten()
+            .any(|thread| thread.id == thread_id && thread.archived)
+    }
+
+    /// Returns the number of messages left in a channel.
+    pub fn remaining(&self, channel_id: ChannelId) -> usize {
+        self.channels
+            .lock()
+            .unwrap()
+            .get(&channel_id)
+            .map_or(0, Vec::len)
+    }
+
+    /// Returns whether a message is still in a channel.
+    pub fn has_message(&self, channel_id: ChannelId, message_id: MessageId) -> bool {
+        self.channels
+            .lock()
+            .unwrap()
+            .get(&channel_id)
+            .is_some_and(|messages| messages.iter().any(|message| message.id == message_id))
+    }
+
+    /// Makes fetching a channel's messages panic.
+    pub fn panic_in(&self, channel_id: ChannelId) {
+        self.panicking.lock().unwrap().insert(channel_id);
+    }
+
+    /// Returns whether a channel was deleted.
+    pub fn is_deleted(&self, channel_id: ChannelId) -> bool {
+        self.deleted_channels.lock().unwrap().contains(&channel_id)
+    }
+
+    /// Returns a channel's topic, if it has one.
+    pub fn topic_of(&self, channel_id: ChannelId) -> Option<String> {
+        self.topics.lock().unwrap().get(&channel_id).cloned()
+    }
+
+    /// Returns a channel's slowmode in seconds.
+    pub fn slowmode_of(&self, channel_id: ChannelId) -> u16 {
+        self.slowmodes
+            .lock()
+            .unwrap()
+            .get(&channel_id)
+            .copied()
+            .unwrap_or(0)
+    }
+
+    /// Returns every slowmode the bot set, in order.
+    pub fn slowmode_edits(&self) -> Vec<(ChannelId, u16)> {
+        self.slowmode_edits.lock().unwrap().clone()
+    }
+
+    /// Returns whether @everyone may send messages in a channel.
+    pub fn send_permission_of(&self, channel_id: ChannelId) -> SendPermission {
+        self.send_permissions
+            .lock()
+            .unwrap()
+            .get(&channel_id)
+            .copied()
+            .unwrap_or_default()
+    }
+
+    /// Returns every change the bot made to @everyone's permission to send
+    /// messages, in order.
+    pub fn send_permission_edits(&self) -> Vec<(ChannelId, SendPermission)> {
+        self.send_permission_edits.lock().unwrap().clone()
+    }
+
+    /// Returns the messages the bot posted, with the role each one pinged.
+    pub fn sent(&self) -> Vec<(ChannelId, String, Option<RoleId>)> {
+        self.sent.lock().unwrap().clone()
+    }
+
+    /// Returns the direct messages sent to guild owners, oldest first.
+    pub fn owner_messages(&self) -> Vec<(GuildId, String)> {
+        self.owner_messages.lock().unwrap().clone()
+    }
+
+    /// Returns the direct messages sent to users, oldest first.
+    pub fn user_messages(&self) -> Vec<(UserId, String)> {
+        self.user_messages.lock().unwrap().clone()
+    }
+
+    /// Makes every request for a channel fail as if the bot had lost access.
+    pub fn revoke_access(&self, channel_id: ChannelId) {
+        self.forbidden.lock().unwrap().insert(channel_id);
+    }
+
+    /// Undoes `revoke_access`.
+    pub fn restore_access(&self, channel_id: ChannelId) {
+        self.forbidden.lock().unwrap().remove(&channel_id);
+    }
+
+    /// Makes every delete in a channel fail with a 429, however often it is retried.
+    pub fn throttle_deletes(&self, channel_id: ChannelId) {
+        self.throttled.lock().unwrap().insert(channel_id);
+    }
+
+    /// Undoes `throttle_deletes`.
+    pub fn stop_throttling(&self, channel_id: ChannelId) {
+        self.throttled.lock().unwrap().remove(&channel_id);
+    }
+
+    fn check_throttled(&self, channel_id: ChannelId) -> Result<(), EuleError> {
+        if self.throttled.lock().unwrap().contains(&channel_id) {
+            return Err(EuleError::RateLimited(Duration::from_millis(500)));
+        }
+        Ok(())
+    }
+
+    fn check_access(&self, channel_id: ChannelId) -> Result<(), EuleError> {
+        let panicking = self.panicking.lock().unwrap().contains(&channel_id);
+        if panicking {
+            panic!("simulated panic in channel {}", channel_id);
+        }
+        if self.forbidden.lock().unwrap().contains(&channel_id) {
+            return Err(EuleError::MissingPermissions("Missing Access".to_string()));
+        }
+        Ok(())
+    }
+
+    fn check_rate_limit(&self) -> Result<(), EuleError> {
+        let call = self.calls.fetch_add(1, Ordering::SeqCst) + 1;
+        match self.rate_limit_every {
+            Some(n) if call % n == 0 => {
+                self.rate_limited.fetch_add(1, Ordering::SeqCst);
+                Err(EuleError::RateLimited(Duration::from_millis(500)))
+            }
+            _ => Ok(()),
+        }
+    }
+
+    fn check_writable(&self, channel_id: ChannelId) -> Result<(), EuleError> {
+        if self.is_archived(channel_id) {
+            return Err(EuleError::DiscordApi(serenity::Error::Other(
+                "Thread is archived",
+            )));
+        }
+        Ok(())
+    }
+
+    fn remove(&self, channel_id: ChannelId, message_ids: &[MessageId]) {
+        if let Some(history) = self.channels.lock().unwrap().get_mut(&channel_id) {
+            history.retain(|m| !message_ids.contains(&m.id));
+        }
+    }
+}
+
+fn rejected() -> EuleError {
+    EuleError::DiscordApi(serenity::Error::Model(
+        serenity::ModelError::BulkDeleteAmount,
+    ))
+}
+
+#[async_trait]
+impl DiscordApi for MockDiscord {
+    async fn messages(
+        &self,
+        channel_id: ChannelId,
+        before: Option<MessageId>,
+        limit: u8,
+    ) -> Result<Vec<ChannelMessage>, EuleError> {
+        self.check_rate_limit()?;
+        self.check_access(channel_id)?;
+        self.fetches.fetch_add(1, Ordering::SeqCst);
+        tokio::time::sleep(self.fetch_latency).await;
+        let channels = self.channels.lock().unwrap();
+        let mut page: Vec<ChannelMessage> = channels
+            .get(&channel_id)
+            .into_iter()
+            .flatten()
+            .filter(|m| before.map_or(true, |b| m.id < b))
+            .cloned()
+            .collect();
+        page.sort_by(|a, b| b.id.cmp(&a.id));
+        page.truncate(limit.min(100) as usize);
+        Ok(page)
+    }
+
+    async fn messages_after(
+        &self,
+        channel_id: ChannelId,
+        after: MessageId,
+        limit: u8,
+    ) -> Result<Vec<ChannelMessage>, EuleError> {
+        self.check_rate_limit()?;
+        self.check_access(channel_id)?;
+        self.fetches.fetch_add(1, Ordering::SeqCst);
+        tokio::time::sleep(self.fetch_latency).await;
+        let channels = self.channels.lock().unwrap();
+        let mut page: Vec<ChannelMessage> = channels
+            .get(&channel_id)
+            .into_iter()
+            .flatten()
+            .filter(|m| m.id > after)
+            .cloned()
+            .collect();
+        page.sort_by_key(|m| m.id);
+        page.truncate(limit.min(100) as usize);
+        Ok(page)
+    }
+
+    async fn delete_messages(
+        &self,
+        channel_id: ChannelId,
+        message_ids: &[MessageId],
+    ) -> Result<(), EuleError> {
+        self.check_rate_limit()?;
+        self.check_writable(channel_id)?;
+        self.check_throttled(channel_id)?;
+        if !(2..=100).contains(&message_ids.len()) {
+            return Err(rejected());
+        }
+        let too_old = message_ids
+            .iter()
+            .any(|id| snowflake::to_instant(id.get()).elapsed() >= BULK_DELETE_LIMIT);
+        if too_old {
+            return Err(rejected());
+        }
+        self.bulk_deletes.fetch_add(1, Ordering::SeqCst);
+        self.remove(channel_id, message_ids);
+        Ok(())
+    }
+
+    async fn delete_message(
+        &self,
+        channel_id: ChannelId,
+        message_id: MessageId,
+    ) -> Result<(), EuleError> {
+        self.check_rate_limit()?;
+        self.check_writable(channel_id)?;
+        self.check_throttled(channel_id)?;
+        self.single_deletes.fetch_add(1, Ordering::SeqCst);
+        self.remove(channel_id, &[message_id]);
+        Ok(())
+    }
+
+    async fn threads(&self, channel_id: ChannelId) -> Result<Vec<ThreadInfo>, EuleError> {
+        self.check_rate_limit()?;
+        Ok(self
+            .threads
+            .lock()
+            .unwrap()
+            .get(&channel_id)
+            .cloned()
+            .unwrap_or_default())
+    }
+
+    async fn set_archived(&self, thread_id: ChannelId, archived: bool) -> Result<(), EuleError> {
+        self.check_rate_limit()?;
+        for thread in self.threads.lock().unwrap().values_mut().flatten() {
+            if thread.id == thread_id {
+                thread.archived = archived;
+            }
+        }
+        Ok(())
+    }
+
+    async fn delete_thread(&self, thread_id: ChannelId) -> Result<(), EuleError> {
+        self.check_rate_limit()?;
+        for threads in self.threads.lock().unwrap().values_mut() {
+            threads.retain(|thread| thread.id != thread_id);
+        }
+        self.channels.lock().unwrap().remove(&thread_id);
+        Ok(())
+    }
+
+    async fn topic(&self, channel_id: ChannelId) -> Result<Option<String>, EuleError> {
+        self.check_rate_limit()?;
+        self.check_access(channel_id)?;
+        Ok(self.topics.lock().unwrap().get(&channel_id).cloned())
+    }
+
+    async fn set_topic(&self, channel_id: ChannelId, topic: &str) -> Result<(), EuleError> {
+        self.check_rate_limit()?;
+        self.check_access(channel_id)?;
+        self.topic_edits.fetch_add(1, Ordering::SeqCst);
+        let mut topics = self.topics.lock().unwrap();
+        if topic.is_empty() {
+            topics.remove(&channel_id);
+        } else {
+            topics.insert(channel_id, topic.to_string());
+        }
+        Ok(())
+    }
+
+    async fn slowmode(&self, channel_id: ChannelId) -> Result<u16, EuleError> {
+        self.check_rate_limit()?;
+        self.check_access(channel_id)?;
+        Ok(self.slowmode_of(channel_id))
+    }
+
+    async fn set_slowmode(&self, channel_id: ChannelId, seconds: u16) -> Result<(), EuleError> {
+        self.check_rate_limit()?;
+        self.check_access(channel_id)?;
+        self.slowmode_edits
+            .lock()
+            .unwrap()
+            .push((channel_id, seconds));
+        self.slowmodes.lock().unwrap().insert(channel_id, seconds);
+        Ok(())
+    }
+
+    async fn everyone_send(&self, channel_id: ChannelId) -> Result<SendPermission, EuleError> {
+        self.check_rate_limit()?;
+        self.check_access(channel_id)?;
+        Ok(self.send_permission_of(channel_id))
+    }
+
+    async fn set_everyone_send(
+        &self,
+        channel_id: ChannelId,
+        permission: SendPermission,
+    ) -> Result<(), EuleError> {
+        self.check_rate_limit()?;
+        self.check_access(channel_id)?;
+        self.send_permission_edits
+            .lock()
+            .unwrap()
+            .push((channel_id, permission));
+        self.send_permissions
+            .lock()
+            .unwrap()
+            .insert(channel_id, permission);
+        Ok(())
+    }
+
+    async fn send_message(
+        &self,
+        channel_id: ChannelId,
+        content: &str,
+        ping: Option<RoleId>,
+    ) -> Result<MessageId, EuleError> {
+        self.check_rate_limit()?;
+        self.check_access(channel_id)?;
+        let id = self.post(channel_id, UserId::new(1), Duration::ZERO, false);
+        self.sent
+            .lock()
+            .unwrap()
+            .push((channel_id, content.to_string(), ping));
+        Ok(id)
+    }
+}
+
+#[async_trait]
+impl BotApi for MockDiscord {
+    async fn clone_channel(&self, channel_id: ChannelId) -> Result<ChannelId, EuleError> {
+        self.check_rate_limit()?;
+        self.check_access(channel_id)?;
+        let base = snowflake::from_instant(SerializableInstant::now());
+        let id = ChannelId::new(base + self.sequence.fetch_add(1, Ordering::SeqCst) + 1);
+        self.channels.lock().unwrap().insert(id, Vec::new());
+        let mut topics = self.topics.lock().unwrap();
+        if let Some(topic) = topics.get(&channel_id).cloned() {
+            topics.insert(id, topic);
+        }
+        Ok(id)
+    }
+
+    async fn delete_channel(&self, channel_id: ChannelId) -> Result<(), EuleError> {
+        self.check_rate_limit()?;
+        self.check_access(channel_id)?;
+        self.channels.lock().unwrap().remove(&channel_id);
+        self.threads.lock().unwrap().remove(&channel_id);
+        self.topics.lock().unwrap().remove(&channel_id);
+        self.deleted_channels.lock().unwrap().insert(channel_id);
+        Ok(())
+    }
+
+    async fn message_owner(&self, guild_id: GuildId, content: &str) -> Result<(), EuleError> {
+        self.check_rate_limit()?;
+        self.owner_messages
+            .lock()
+            .unwrap()
+            .push((guild_id, content.to_string()));
+        Ok(())
+    }
+
+    async fn message_user(&self, user_id: UserId, content: &str) -> Result<(), EuleError> {
+        self.check_rate_limit()?;
+        self.user_messages
+            .lock()
+            .unwrap()
+            .push((user_id, content.to_string()));
+        Ok(())
+    }
+
+    async fn bot_permissions(&self, channel_id: ChannelId) -> Result<Permissions, EuleError> {
+        self.check_rate_limit()?;
+        Ok(match self.forbidden.lock().unwrap().contains(&channel_id) {
+            true => Permissions::empty(),
+            false => Permissions::all(),
+        })
+    }
+}
@@ -1,3 +1,6 @@
+pub mod discord_server;
+pub mod mock_discord;
+
 use std::{
     fs,
     path::PathBuf,
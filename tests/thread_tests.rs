@@ -0,0 +1,53 @@
+#[allow(dead_code)]
+mod test_utils;
+
+use eule::purge::{prune_threads, ThreadOptions};
+use poise::serenity_prelude::ChannelId;
+use test_utils::mock_discord::MockDiscord;
+use tokio::time::Duration;
+
+const DAY: Duration = Duration::from_secs(24 * 60 * 60);
+
+#[tokio::test(start_paused = true)]
+async fn test_prune_threads_archives_inactive_threads() {
+    let api = MockDiscord::new();
+    let channel = ChannelId::new(2);
+    let stale = api.add_forum_post(channel, DAY * 10, Vec::new(), false);
+    let busy = api.add_forum_post(channel, DAY * 10, Vec::new(), false);
+    api.add_messages(busy, 1, Duration::from_secs(60));
+    let fresh = api.add_forum_post(channel, DAY, Vec::new(), false);
+
+    let report = prune_threads(&api, channel, &ThreadOptions::new(DAY * 7))
+        .await
+        .unwrap();
+
+    assert_eq!(report.scanned, 3);
+    assert_eq!(report.archived, 1);
+    assert_eq!(report.deleted, 0);
+    assert!(api.is_archived(stale));
+    assert!(!api.is_archived(busy));
+    assert!(!api.is_archived(fresh));
+}
+
+#[tokio::test(start_paused = true)]
+async fn test_prune_threads_deletes_long_archived_threads() {
+    let api = MockDiscord::new();
+    let channel = ChannelId::new(2);
+    let old_archived = api.add_forum_post(channel, DAY * 40, Vec::new(), true);
+    let recent_archived = api.add_forum_post(channel, DAY * 10, Vec::new(), true);
+    let old_open = api.add_forum_post(channel, DAY * 40, Vec::new(), false);
+    let quiet_open = api.add_forum_post(channel, DAY * 10, Vec::new(), false);
+
+    let options = ThreadOptions {
+        archive_after: DAY * 7,
+        delete_after: Some(DAY * 30),
+    };
+    let report = prune_threads(&api, channel, &options).await.unwrap();
+
+    assert_eq!(report.deleted, 2);
+    assert_eq!(report.archived, 1);
+    assert!(!api.has_thread(old_archived));
+    assert!(!api.has_thread(old_open));
+    assert!(api.is_archived(recent_archived));
+    assert!(api.is_archived(quiet_open));
+}
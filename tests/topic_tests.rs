@@ -0,0 +1,143 @@
+mod test_utils;
+
+use eule::{
+    purge::DiscordApi,
+    store::KvStore,
+    tasks::{
+        topic::{self, format_time, update_topic, with_next_purge, TOPIC_MARKER},
+        AutocleanManager,
+    },
+    utils::SerializableInstant,
+};
+use poise::serenity_prelude::{ChannelId, GuildId};
+use std::{
+    sync::{atomic::Ordering, Arc},
+    time::UNIX_EPOCH,
+};
+use test_utils::{mock_discord::MockDiscord, unique_test_path, TestCleanup};
+use tokio::time::Duration;
+
+fn at(unix_secs: u64) -> SerializableInstant {
+    SerializableInstant::from_system_time(UNIX_EPOCH + Duration::from_secs(unix_secs))
+}
+
+#[test]
+fn test_with_next_purge_replaces_suffix() {
+    assert_eq!(format_time(at(0)), "1970-01-01 00:00 UTC");
+
+    let first = with_next_purge("", Some(at(1_792_143_000)));
+    assert_eq!(first, "🧹 next purge: 2026-10-16 09:30 UTC");
+    let second = with_next_purge(&first, Some(at(1_792_229_400)));
+    assert_eq!(second, "🧹 next purge: 2026-10-17 09:30 UTC");
+    assert_eq!(with_next_purge(&second, None), "");
+
+    let topic = with_next_purge("Rules | be nice", Some(at(0)));
+    assert_eq!(
+        topic,
+        "Rules | be nice | 🧹 next purge: 1970-01-01 00:00 UTC"
+    );
+    assert_eq!(with_next_purge(&topic, None), "Rules | be nice");
+}
+
+#[test]
+fn test_with_next_purge_fits_discord_limit() {
+    let long = "a".repeat(1024);
+    let topic = with_next_purge(&long, Some(at(0)));
+    assert_eq!(topic.chars().count(), 1024);
+    assert!(topic.contains("…"));
+    assert!(topic.ends_with("1970-01-01 00:00 UTC"));
+}
+
+#[tokio::test]
+async fn test_update_topic_skips_unchanged_topics() {
+    let api = MockDiscord::new();
+    let channel_id = ChannelId::new(1);
+    api.set_topic(channel_id, "Memes only").await.unwrap();
+
+    update_topic(&api, channel_id, Some(at(0))).await.unwrap();
+    update_topic(&api, channel_id, Some(at(0))).await.unwrap();
+    assert_eq!(api.topic_edits.load(Ordering::SeqCst), 2);
+    assert!(api
+        .topic(channel_id)
+        .await
+        .unwrap()
+        .unwrap()
+        .contains(TOPIC_MARKER));
+
+    update_topic(&api, channel_id, None).await.unwrap();
+    update_topic(&api, channel_id, None).await.unwrap();
+    assert_eq!(api.topic_edits.load(Ordering::SeqCst), 3);
+    assert_eq!(
+        api.topic(channel_id).await.unwrap().as_deref(),
+        Some("Memes only")
+    );
+}
+
+#[tokio::test]
+async fn test_topic_follows_task() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let manager = AutocleanManager::new(Arc::new(KvStore::new(path).unwrap()));
+    let api = Arc::new(MockDiscord::new());
+    let (guild_id, channel_id) = (GuildId::new(1), ChannelId::new(2));
+    api.set_topic(channel_id, "General chat").await.unwrap();
+    api.add_messages(channel_id, 5, Duration::from_secs(60));
+
+    tokio::spawn(topic::run(
+        manager.clone(),
+        api.clone(),
+        manager.subscribe_events(),
+        manager.subscribe_changes(),
+    ));
+    let topic_of = || async { api.topic(channel_id).await.unwrap().unwrap_or_default() };
+    let wait_for = |expected: bool| async move {
+        for _ in 0..100 {
+            if topic_of().await.contains(TOPIC_MARKER) == expected {
+                return;
+            }
+            tokio::time::sleep(Duration::from_millis(10)).await;
+        }
+        panic!(
+            "topic never {}",
+            if expected {
+                "gained a suffix"
+            } else {
+                "lost its suffix"
+            }
+        );
+    };
+
+    manager
+        .add_task(guild_id, channel_id, Duration::from_secs(3600))
+        .await
+        .unwrap();
+    manager
+        .set_show_in_topic(guild_id, channel_id, true)
+        .await
+        .unwrap();
+    wait_for(true).await;
+    let before = topic_of().await;
+
+    manager
+        .set_interval(guild_id, channel_id, Duration::from_secs(7200))
+        .await
+        .unwrap();
+    for _ in 0..100 {
+        if topic_of().await != before {
+            break;
+        }
+        tokio::time::sleep(Duration::from_millis(10)).await;
+    }
+    let task = manager.task(guild_id, channel_id).await.unwrap();
+    assert_eq!(
+        topic_of().await,
+        with_next_purge("General chat", Some(task.next_cleanup()))
+    );
+
+    manager.purge_now(&api, guild_id, channel_id).await.unwrap();
+    assert_eq!(api.remaining(channel_id), 0);
+
+    manager.remove_task(guild_id, channel_id).await.unwrap();
+    wait_for(false).await;
+    assert_eq!(topic_of().await, "General chat");
+}
@@ -0,0 +1,135 @@
+mod test_utils;
+
+use eule::{
+    i18n::Language,
+    store::KvStore,
+    tasks::{
+        guild_settings::{Announcements, MessageTemplates, TemplateKind},
+        warning::{send_due_warnings, warning_text},
+        AutocleanManager, PurgeWarning,
+    },
+    utils::{MockClock, SerializableInstant},
+};
+use poise::serenity_prelude::{ChannelId, GuildId, RoleId};
+use std::sync::Arc;
+use test_utils::{mock_discord::MockDiscord, unique_test_path, TestCleanup};
+use tokio::time::Duration;
+
+const HOUR: Duration = Duration::from_secs(3600);
+
+#[test]
+fn test_warning_text_mentions_role() {
+    let at = SerializableInstant::now();
+    let templates = MessageTemplates::default();
+    let channel_id = ChannelId::new(2);
+    let text = warning_text(
+        &templates,
+        Language::En,
+        channel_id,
+        at,
+        Some(RoleId::new(5)),
+    );
+
+    assert!(text.starts_with("<@&5> ⚠️ This channel will be purged <t:"));
+    assert!(!warning_text(&templates, Language::En, channel_id, at, None).contains("<@&"));
+}
+
+#[test]
+fn test_warning_text_uses_guild_template() {
+    let at = SerializableInstant::now();
+    let mut templates = MessageTemplates::default();
+    templates.set(
+        TemplateKind::Warning,
+        Some("{channel} goes away {next_purge}".to_string()),
+    );
+    let text = warning_text(
+        &templates,
+        Language::En,
+        ChannelId::new(2),
+        at,
+        Some(RoleId::new(5)),
+    );
+
+    assert!(text.starts_with("<@&5> <#2> goes away <t:"));
+}
+
+#[tokio::test]
+async fn test_warning_is_posted_once_per_cleanup() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let clock = Arc::new(MockClock::new(SerializableInstant::now()));
+    let manager =
+        AutocleanManager::with_clock(Arc::new(KvStore::new(path).unwrap()), clock.clone());
+    let api = MockDiscord::new();
+    let guild_id = GuildId::new(1);
+    let channel_id = ChannelId::new(2);
+    manager.add_task(guild_id, channel_id, HOUR).await.unwrap();
+    manager
+        .set_warning(
+            guild_id,
+            channel_id,
+            Some(PurgeWarning {
+                lead: Duration::from_secs(600),
+                role: Some(RoleId::new(5)),
+            }),
+        )
+        .await
+        .unwrap();
+
+    clock.advance(Duration::from_secs(40 * 60));
+    send_due_warnings(&manager, &api).await;
+    assert!(api.sent().is_empty());
+
+    clock.advance(Duration::from_secs(15 * 60));
+    send_due_warnings(&manager, &api).await;
+    send_due_warnings(&manager, &api).await;
+    let sent = api.sent();
+    assert_eq!(sent.len(), 1);
+    assert_eq!(sent[0].0, channel_id);
+    assert_eq!(sent[0].2, Some(RoleId::new(5)));
+
+    // Once the cleanup is due it runs right away, so there's no point warning
+    clock.advance(Duration::from_secs(10 * 60));
+    send_due_warnings(&manager, &api).await;
+    assert_eq!(api.sent().len(), 1);
+}
+
+#[tokio::test]
+async fn test_warning_goes_to_log_channel_without_mention() {
+    let path = unique_test_path();
+    let _cleanup = TestCleanup::new(path.clone()).unwrap();
+    let clock = Arc::new(MockClock::new(SerializableInstant::now()));
+    let manager =
+        AutocleanManager::with_clock(Arc::new(KvStore::new(path).unwrap()), clock.clone());
+    let api = MockDiscord::new();
+    let guild_id = GuildId::new(1);
+    let channel_id = ChannelId::new(2);
+    let log_channel = ChannelId::new(3);
+    manager.add_task(guild_id, channel_id, HOUR).await.unwrap();
+    manager
+        .set_warning(
+            guild_id,
+            channel_id,
+            Some(PurgeWarning {
+                lead: Duration::from_secs(600),
+                role: Some(RoleId::new(5)),
+            }),
+        )
+        .await
+        .unwrap();
+    manager
+        .update_guild_settings(guild_id, |settings| {
+            settings.announcements = Announcements::LogChannel;
+            settings.log_channel = Some(log_channel);
+        })
+        .await
+        .unwrap();
+
+    clock.advance(Duration::from_secs(55 * 60));
+    send_due_warnings(&manager, &api).await;
+    let sent = api.sent();
+    assert_eq!(sent.len(), 1);
+    assert_eq!(sent[0].0, log_channel);
+    assert_eq!(sent[0].2, None);
+    assert!(!sent[0].1.contains("<@&"));
+}
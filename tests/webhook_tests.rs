@@ -0,0 +1,52 @@
+use eule::{
+    notify::sign,
+    tasks::{PurgeEvent, PurgeEventKind},
+    utils::SerializableInstant,
+};
+use poise::serenity_prelude::{ChannelId, GuildId};
+use serde_json::json;
+
+#[test]
+fn test_sign_matches_hmac_sha256() {
+    assert_eq!(
+        sign("secret", 1_700_000_000, br#"{"kind":"started"}"#),
+        "sha256=f628ea4999a9d04184323cc7e3f48d3179dfb1c3781625328b5da8cb8dc43d74"
+    );
+    assert_ne!(
+        sign("secret", 1_700_000_001, br#"{"kind":"started"}"#),
+        sign("secret", 1_700_000_000, br#"{"kind":"started"}"#)
+    );
+}
+
+#[test]
+fn test_event_payload() {
+    let at = SerializableInstant::now();
+    let event = PurgeEvent {
+        kind: PurgeEventKind::Failed,
+        guild_id: GuildId::new(1),
+        channel_id: ChannelId::new(2),
+        at,
+        deleted: 0,
+        blocked_links: 0,
+        cancelled: false,
+        duration_ms: 1500,
+        error: Some("Missing Access".to_string()),
+        missing_permissions: true,
+    };
+
+    assert_eq!(
+        serde_json::to_value(&event).unwrap(),
+        json!({
+            "kind": "failed",
+            "guild_id": "1",
+            "channel_id": "2",
+            "at": at.unix_secs(),
+            "deleted": 0,
+            "blocked_links": 0,
+            "cancelled": false,
+            "duration_ms": 1500,
+            "error": "Missing Access",
+            "missing_permissions": true,
+        })
+    );
+}
@@ -1,6 +1,13 @@
-use eule::tasks::{AutocleanManager, CleanupTask};
+#[allow(dead_code)]
+mod test_utils;
+
+use eule::{
+    tasks::{AutocleanManager, CleanupTask, PurgeEventKind, PurgeEvents, WorkerPool},
+    utils::clock::SystemClock,
+};
 use poise::serenity_prelude::{ChannelId, GuildId, Http, MessageId};
 use std::{collections::HashMap, sync::Arc};
+use test_utils::mock_discord::MockDiscord;
 use tokio::{sync::RwLock, time::Duration};
 
 #[async_trait::async_trait]
@@ -129,3 +136,49 @@ async fn test_worker_pool_shutdown() {
         }
     }
 }
+
+#[tokio::test]
+async fn test_worker_pool_skips_a_cleanup_that_is_already_queued() {
+    let api = Arc::new(MockDiscord::with_fetch_latency(Duration::from_millis(100)));
+    let guild_id = GuildId::new(1);
+    let (first, second) = (ChannelId::new(2), ChannelId::new(3));
+    let mut guild_tasks = HashMap::new();
+    for channel_id in [first, second] {
+        api.add_messages(channel_id, 5, Duration::from_secs(60));
+        guild_tasks.insert(
+            channel_id,
+            CleanupTask::new(Duration::from_secs(3600)).await,
+        );
+    }
+    let tasks = Arc::new(RwLock::new(HashMap::from([(guild_id, guild_tasks)])));
+    let events = PurgeEvents::new();
+    let mut received = events.subscribe();
+    let pool = WorkerPool::with_events(
+        1,
+        api,
+        tasks,
+        events,
+        Default::default(),
+        Arc::new(SystemClock),
+    );
+
+    // The scheduler finds the first channel due again before its cleanup ran
+    pool.queue_task(guild_id, first).await;
+    pool.queue_task(guild_id, first).await;
+    pool.queue_task(guild_id, second).await;
+
+    let mut order = Vec::new();
+    while order.len() < 4 {
+        let event = received.recv().await.unwrap();
+        order.push((event.channel_id, event.kind));
+    }
+    assert_eq!(
+        order,
+        vec![
+            (first, PurgeEventKind::Started),
+            (first, PurgeEventKind::Completed),
+            (second, PurgeEventKind::Started),
+            (second, PurgeEventKind::Completed),
+        ]
+    );
+}